@@ -244,6 +244,7 @@ Rules:
 - A crash is an exception event with severity = 'fatal'. Older rows predate the severity field; for those, handled = false marks a crash, so include them when you count. Keep that legacy fallback to yourself: it is a data-backfill detail, not something to explain in an answer.
 - ANRs are their own event type and exist only on Android; an app that does not run on Android has none, so never count or mention ANRs for it.
 - Bug report status lives outside the raw tables; use the bug report tools for it.
+- For a question about one specific end user, such as a customer's user id or an installation id, use get_end_user instead of querying their events yourself.
 - Tools cover all app versions unless you pass versions or version_codes. When a question is about a specific release, resolve its exact version with get_filters and filter explicitly; never conclude there is little or no data while a version filter narrows the query.
 - Timestamps are UTC.
 - If a query fails, read the error and fix the query.
@@ -14,6 +14,7 @@ import (
 
 	"backend/libs/ambient"
 	"backend/libs/chquery"
+	"backend/libs/enduser"
 	"backend/libs/event"
 	"backend/libs/filter"
 	"backend/libs/group"
@@ -505,6 +506,15 @@ func commonTools(cfg *Config) []Tool {
 			return cfg.mcpGetSession(ctx, in)
 		}),
 
+		// get_end_user
+		newTool(&mcpsdk.Tool{
+			Name:        "get_end_user",
+			Description: "Look up one end user of an app by the user id the app sets, or by installation id: their first and last seen times, devices, app versions, recent sessions, the error groups (crashes, exceptions, ANRs) they ran into and their bug reports. Defaults to the last 30 days.",
+			InputSchema: mcpMustInferSchema[mcpGetEndUserInput](),
+		}, func(ctx context.Context, req *mcpsdk.CallToolRequest, in mcpGetEndUserInput) (*mcpsdk.CallToolResult, any, error) {
+			return cfg.mcpGetEndUser(ctx, in)
+		}),
+
 		// get_bug_reports
 		newTool(&mcpsdk.Tool{
 			Name:        "get_bug_reports",
@@ -764,8 +774,9 @@ type mcpGetAppHealthOverTimeInput struct {
 type mcpGetErrorsInput struct {
 	mcpCommonFilters
 	mcpErrorFilters
-	Limit  int `json:"limit,omitempty" jsonschema:"Maximum number of groups to return (default: 25, max: 100)"`
-	Offset int `json:"offset,omitempty" jsonschema:"Number of groups to skip for pagination (default: 0)"`
+	Statuses []string `json:"statuses,omitempty" jsonschema:"Filter by triage status: 'open', 'resolved', 'ignored' and/or 'snoozed'. Default: all"`
+	Limit    int      `json:"limit,omitempty" jsonschema:"Maximum number of groups to return (default: 25, max: 100)"`
+	Offset   int      `json:"offset,omitempty" jsonschema:"Number of groups to skip for pagination (default: 0)"`
 }
 type mcpGetErrorInput struct {
 	mcpCommonFilters
@@ -810,6 +821,13 @@ type mcpGetSessionInput struct {
 	AppID     string `json:"app_id" jsonschema:"UUID of the app"`
 	SessionID string `json:"session_id" jsonschema:"UUID of the session"`
 }
+type mcpGetEndUserInput struct {
+	AppID          string `json:"app_id" jsonschema:"UUID of the app"`
+	UserID         string `json:"user_id,omitempty" jsonschema:"User id the app sets for the end user. Pass this or installation_id"`
+	InstallationID string `json:"installation_id,omitempty" jsonschema:"UUID of the app installation. Pass this or user_id"`
+	From           string `json:"from,omitempty" jsonschema:"Start of time range (RFC3339, default: 30 days ago)"`
+	To             string `json:"to,omitempty" jsonschema:"End of time range (RFC3339, default: now)"`
+}
 type mcpGetBugReportsInput struct {
 	mcpCommonFilters
 	BugReportStatuses []int  `json:"bug_report_statuses,omitempty" jsonschema:"Filter by status: 0=OPEN, 1=CLOSED"`
@@ -1389,11 +1407,30 @@ func (c *Config) mcpGetErrors(ctx context.Context, in mcpGetErrorsInput) (*mcpsd
 	af.Limit = limit
 	af.Offset = in.Offset
 
+	for _, st := range in.Statuses {
+		if !group.Status(st).IsValid() {
+			return nil, nil, fmt.Errorf("statuses values must be any combination of: %s, %s, %s, %s", group.StatusOpen, group.StatusResolved, group.StatusIgnored, group.StatusSnoozed)
+		}
+		af.ErrorGroupStatuses = append(af.ErrorGroupStatuses, st)
+	}
+	if err := group.ResolveMerges(ctx, deps.PgPool, af); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group merges: %v", err)
+	}
+	if err := group.ResolveStatusFilter(ctx, deps.PgPool, af); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group states: %v", err)
+	}
+
 	app := &measure.App{ID: &appID, TeamId: teamID}
 	groups, _, _, groupErr := app.GetErrorGroupsWithFilter(ctx, deps.RchPool, af)
 	if groupErr != nil {
 		return nil, nil, fmt.Errorf("failed to get error groups: %v", groupErr)
 	}
+	if err := group.PopulateReleases(ctx, deps.PgPool, appID, groups); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group releases: %v", err)
+	}
+	if err := group.PopulateStates(ctx, deps.PgPool, appID, groups); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group states: %v", err)
+	}
 	data, _ := json.Marshal(groups)
 
 	return mcpTextResult(string(data)), nil, nil
@@ -1425,6 +1462,10 @@ func (c *Config) mcpGetError(ctx context.Context, in mcpGetErrorInput) (*mcpsdk.
 	af.Limit = limit
 	af.Offset = in.Offset
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, af); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group merges: %v", err)
+	}
+
 	app := &measure.App{ID: &appID, TeamId: teamID}
 	events, _, _, evErr := app.GetErrorsWithFilter(ctx, deps.RchPool, in.ErrorGroupID, af)
 	if evErr != nil {
@@ -1488,6 +1529,10 @@ func (c *Config) mcpGetErrorOverTime(ctx context.Context, in mcpGetErrorOverTime
 	af.Timezone = in.Timezone
 	af.Limit = filter.DefaultPaginationLimit
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, af); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group merges: %v", err)
+	}
+
 	app := &measure.App{ID: &appID, TeamId: teamID}
 	plotCtx := ambient.WithTeamId(ctx, teamID)
 	instances, plotErr := app.GetErrorGroupPlotInstances(plotCtx, deps.RchPool, in.ErrorGroupID, af)
@@ -1516,6 +1561,10 @@ func (c *Config) mcpGetErrorDistribution(ctx context.Context, in mcpGetErrorDist
 	}
 	af.Limit = filter.DefaultPaginationLimit
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, af); err != nil {
+		return nil, nil, fmt.Errorf("failed to get error group merges: %v", err)
+	}
+
 	app := &measure.App{ID: &appID, TeamId: teamID}
 	distCtx := ambient.WithTeamId(ctx, teamID)
 	distribution, distErr := app.GetErrorGroupAttributesDistribution(distCtx, deps.RchPool, in.ErrorGroupID, af)
@@ -1621,6 +1670,45 @@ func (c *Config) mcpGetSession(ctx context.Context, in mcpGetSessionInput) (*mcp
 	return mcpTextResult(string(data)), nil, nil
 }
 
+func (c *Config) mcpGetEndUser(ctx context.Context, in mcpGetEndUserInput) (*mcpsdk.CallToolResult, any, error) {
+	deps := c.Deps
+	appID, teamID, err := c.mcpResolveAppAccess(ctx, in.AppID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lookup := enduser.Lookup{UserID: in.UserID}
+	if in.InstallationID != "" {
+		if lookup.InstallationID, err = uuid.Parse(in.InstallationID); err != nil {
+			return nil, nil, fmt.Errorf("installation_id is not a valid UUID")
+		}
+	}
+	if err := lookup.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	from, to, err := mcpParseTimeRangeStrings(in.From, in.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	if in.From == "" {
+		from = to.Add(-enduser.DefaultLookback)
+	}
+	if err := enduser.ValidateRange(from, to); err != nil {
+		return nil, nil, err
+	}
+
+	profile, profileErr := enduser.GetProfile(ctx, deps.RchPool, teamID, appID, lookup, from, to)
+	if profileErr != nil {
+		return nil, nil, fmt.Errorf("failed to get end user: %v", profileErr)
+	}
+	if !profile.Found() {
+		return mcpTextResult(fmt.Sprintf("No activity found for the end user with %s between %s and %s", lookup, from.Format(time.RFC3339), to.Format(time.RFC3339))), nil, nil
+	}
+	data, _ := json.Marshal(profile)
+	return mcpTextResult(string(data)), nil, nil
+}
+
 func (c *Config) mcpGetBugReports(ctx context.Context, in mcpGetBugReportsInput) (*mcpsdk.CallToolResult, any, error) {
 	deps := c.Deps
 	appID, teamID, err := c.mcpResolveAppAccess(ctx, in.AppID)
@@ -1953,7 +2041,11 @@ func (c *Config) mcpGetErrorCommonPath(ctx context.Context, in mcpGetErrorCommon
 		return nil, nil, err
 	}
 
-	data, pathErr := measure.GetIssueGroupCommonPath(ctx, deps.RchPool, teamID, appID, group.GroupTypeError, in.ErrorGroupID)
+	merged, mergeErr := group.GetMergedIDs(ctx, deps.PgPool, appID, in.ErrorGroupID)
+	if mergeErr != nil {
+		return nil, nil, fmt.Errorf("failed to get merged error groups: %v", mergeErr)
+	}
+	data, pathErr := measure.GetIssueGroupCommonPath(ctx, deps.RchPool, teamID, appID, group.GroupTypeError, in.ErrorGroupID, merged...)
 	if pathErr != nil {
 		return nil, nil, fmt.Errorf("failed to get error common path: %v", pathErr)
 	}
@@ -22,6 +22,7 @@ import (
 
 	"cloud.google.com/go/pubsub/v2"
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
@@ -30,14 +31,14 @@ import (
 // once; each one runs a full LLM turn in its handler. Events carry per-thread
 // ordering keys, so the concurrent questions are always from different
 // threads; this needs the subscription created with message ordering
-// enabled. Iggy (self-host) delivers strictly one at a time, so no cap is
-// needed there.
+// enabled. Iggy and Postgres (self-host) deliver strictly one at a time, so
+// no cap is needed there.
 const maxConcurrentSlackTurns = 4
 
 // newSlackConsumer builds the bus consumer carrying Slack events published
 // to the queue by the api service. A nil consumer with a nil error means no
 // bus is configured; the agent then runs without Slack.
-func newSlackConsumer(ctx context.Context, config *server.Config) (bus.Consumer, error) {
+func newSlackConsumer(ctx context.Context, config *server.Config, pgPool *pgxpool.Pool) (bus.Consumer, error) {
 	if config.IsCloud() {
 		subscription := os.Getenv("AGENT_SLACK_PUBSUB_SUBSCRIPTION")
 		if subscription == "" {
@@ -48,6 +49,10 @@ func newSlackConsumer(ctx context.Context, config *server.Config) (bus.Consumer,
 			bus.WithPubSubReceiveSettings(pubsub.ReceiveSettings{MaxOutstandingMessages: maxConcurrentSlackTurns}))
 	}
 
+	if config.BusBackend == bus.BackendPostgres {
+		return bus.NewPostgresConsumer(pgPool, slack.AgentEventsTopic)
+	}
+
 	if config.IG.Addr == "" {
 		return nil, nil
 	}
@@ -65,10 +70,10 @@ func newSlackConsumer(ctx context.Context, config *server.Config) (bus.Consumer,
 // Bus connections die (idle session reaping, broker restarts) and Listen
 // returns once its client is beyond recovery, so build a fresh client and
 // rejoin, backing off between attempts.
-func runSlackConsumer(ctx context.Context, config *server.Config, handler func(context.Context, []byte) error) {
+func runSlackConsumer(ctx context.Context, config *server.Config, pgPool *pgxpool.Pool, handler func(context.Context, []byte) error) {
 	backoff := time.Second
 	for ctx.Err() == nil {
-		consumer, err := newSlackConsumer(ctx, config)
+		consumer, err := newSlackConsumer(ctx, config, pgPool)
 		// A nil consumer with a nil error means no bus is configured, so there
 		// is nothing to supervise; stop for good.
 		if consumer == nil && err == nil {
@@ -211,7 +216,7 @@ func main() {
 		audience := config.AgentOrigin + "/subscribe/slack"
 		r.POST("/subscribe/slack", slackPushHandler(agentConfig, audience))
 	} else {
-		go runSlackConsumer(consumerCtx, config, agentConfig.HandleSlackEvent)
+		go runSlackConsumer(consumerCtx, config, deps.PgPool, agentConfig.HandleSlackEvent)
 	}
 
 	port := os.Getenv("PORT")
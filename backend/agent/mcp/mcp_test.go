@@ -1597,6 +1597,7 @@ func TestMCPToolsList(t *testing.T) {
 		"get_errors_over_time", "get_error_over_time", "get_error_distribution",
 		"get_error_common_path",
 		"get_sessions", "get_sessions_over_time", "get_session",
+		"get_end_user",
 		"get_bug_reports", "get_bug_reports_over_time", "get_bug_report",
 		"update_bug_report_status",
 		"get_root_span_names", "get_span_instances", "get_span_metrics_over_time",
@@ -3221,6 +3222,47 @@ func TestMCPGetAlerts(t *testing.T) {
 	})
 }
 
+func TestMCPGetEndUser(t *testing.T) {
+	ctx := context.Background()
+	setupToolTest := func(t *testing.T, email string) (uuid.UUID, string) {
+		cleanupAll(ctx, t)
+		userID := uuid.New()
+		seedUser(ctx, t, userID.String(), email)
+		teamID := uuid.New()
+		seedTeam(ctx, t, teamID, email+" team")
+		seedTeamMembership(ctx, t, teamID, userID.String(), "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+		rawToken := "msr_" + email
+		seedMCPAccessToken(ctx, t, rawToken, userID.String(), "c1", time.Now().Add(90*24*time.Hour))
+		return appID, rawToken
+	}
+
+	t.Run("needs exactly one id", func(t *testing.T) {
+		appID, rawToken := setupToolTest(t, "enduser1@mcp.test")
+		for _, args := range []map[string]any{
+			{"app_id": appID.String()},
+			{"app_id": appID.String(), "user_id": "u-1", "installation_id": uuid.New().String()},
+			{"app_id": appID.String(), "installation_id": "not-a-uuid"},
+		} {
+			resp := callMCPTool(t, rawToken, "get_end_user", args)
+			if !isToolError(resp) {
+				t.Errorf("want tool error for %v", args)
+			}
+		}
+	})
+	t.Run("unknown end user", func(t *testing.T) {
+		appID, rawToken := setupToolTest(t, "enduser2@mcp.test")
+		resp := callMCPTool(t, rawToken, "get_end_user", map[string]any{"app_id": appID.String(), "user_id": "nobody"})
+		if isToolError(resp) {
+			t.Fatalf("unexpected tool error: %s", extractTextContent(t, resp))
+		}
+		if text := extractTextContent(t, resp); !strings.Contains(text, "No activity found") {
+			t.Errorf("want no activity reported, got %q", text)
+		}
+	})
+}
+
 func TestMCPGetJourney(t *testing.T) {
 	ctx := context.Background()
 	setupToolTest := func(t *testing.T, email string) (uuid.UUID, string) {
@@ -3597,6 +3639,7 @@ func TestMCPAccessControl(t *testing.T) {
 		{"get_sessions", map[string]any{"app_id": appA.String(), "from": from, "to": to}},
 		{"get_sessions_over_time", map[string]any{"app_id": appA.String(), "timezone": "UTC", "from": from, "to": to}},
 		{"get_session", map[string]any{"app_id": appA.String(), "session_id": uuid.New().String()}},
+		{"get_end_user", map[string]any{"app_id": appA.String(), "user_id": "u-1", "from": from, "to": to}},
 		{"get_bug_reports", map[string]any{"app_id": appA.String(), "from": from, "to": to}},
 		{"get_bug_reports_over_time", map[string]any{"app_id": appA.String(), "timezone": "UTC", "from": from, "to": to}},
 		{"get_bug_report", map[string]any{"app_id": appA.String(), "bug_report_id": "br-1"}},
@@ -3638,6 +3681,7 @@ func TestMCPInvalidAppIDFormat(t *testing.T) {
 		{"get_bug_reports", map[string]any{"app_id": "not-a-uuid"}},
 		{"get_root_span_names", map[string]any{"app_id": "not-a-uuid"}},
 		{"get_session", map[string]any{"app_id": "not-a-uuid", "session_id": "some-id"}},
+		{"get_end_user", map[string]any{"app_id": "not-a-uuid", "user_id": "u-1"}},
 		{"get_bug_report", map[string]any{"app_id": "not-a-uuid", "bug_report_id": "some-id"}},
 		{"get_trace", map[string]any{"app_id": "not-a-uuid", "trace_id": "some-id"}},
 		{"get_alerts", map[string]any{"app_id": "not-a-uuid"}},
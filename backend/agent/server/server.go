@@ -19,6 +19,7 @@ import (
 
 	"backend/libs/autumn"
 	"backend/libs/boot"
+	"backend/libs/bus"
 	"backend/libs/chquery"
 	"backend/libs/inet"
 	"backend/libs/posthog"
@@ -73,6 +74,7 @@ type Config struct {
 	CH                         ClickhouseConfig
 	RD                         RedisConfig
 	IG                         IggyConfig
+	BusBackend                 string
 	ServiceAccountEmail        string
 	SymbolsBucket              string
 	SymbolsBucketRegion        string
@@ -336,19 +338,21 @@ func NewConfig() *Config {
 		log.Fatalf("Invalid REDIS_PORT value: %v", err)
 	}
 
+	busBackend := bus.SelfHostBackend()
+
 	// Iggy backs the message bus on self-host only; cloud uses Pub/Sub.
 	iggyAddr := os.Getenv("IGGY_ADDR")
-	if iggyAddr == "" && !cloudEnv {
+	if iggyAddr == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_ADDR env var is not set, the Slack query agent will not work")
 	}
 
 	iggyUsername := os.Getenv("IGGY_USERNAME")
-	if iggyUsername == "" && !cloudEnv {
+	if iggyUsername == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_USERNAME env var is not set, the Slack query agent will not work")
 	}
 
 	iggyPassword := os.Getenv("IGGY_PASSWORD")
-	if iggyPassword == "" && !cloudEnv {
+	if iggyPassword == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_PASSWORD env var is not set, the Slack query agent will not work")
 	}
 
@@ -431,6 +435,7 @@ func NewConfig() *Config {
 			Username: iggyUsername,
 			Password: iggyPassword,
 		},
+		BusBackend:                 busBackend,
 		ServiceAccountEmail:        serviceAccountEmail,
 		SymbolsBucket:              symbolsBucket,
 		SymbolsBucketRegion:        symbolsBucketRegion,
@@ -3,7 +3,9 @@ package alerts
 import (
 	"cmp"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -14,12 +16,14 @@ import (
 
 	"backend/alerts/server"
 	"backend/alerts/slack"
+	"backend/alerts/webhook"
 	"backend/libs/alertmsg"
 	"backend/libs/autumn"
 	"backend/libs/config"
 	"backend/libs/email"
 	"backend/libs/numeric"
 	libslack "backend/libs/slack"
+	libwebhook "backend/libs/webhook"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -49,9 +53,17 @@ type Alert struct {
 type AlertType string
 
 const (
-	AlertTypeCrashSpike AlertType = "crash_spike"
-	AlertTypeAnrSpike   AlertType = "anr_spike"
-	AlertTypeBugReport  AlertType = "bug_report"
+	AlertTypeCrashSpike         AlertType = "crash_spike"
+	AlertTypeAnrSpike           AlertType = "anr_spike"
+	AlertTypeBugReport          AlertType = "bug_report"
+	AlertTypeNetworkLatency     AlertType = "network_latency"
+	AlertTypeNetwork5xxRate     AlertType = "network_5xx_rate"
+	AlertTypeNetwork4xxRate     AlertType = "network_4xx_rate"
+	AlertTypeNetworkTrafficDrop AlertType = "network_traffic_drop"
+	AlertTypeReleaseRegression  AlertType = "release_regression"
+	AlertTypeNewFatalGroup      AlertType = "new_fatal_group"
+	AlertTypeNewAnrGroup        AlertType = "new_anr_group"
+	AlertTypeMissingSymbols     AlertType = "missing_symbols"
 )
 
 type DailySummaryRow struct {
@@ -79,6 +91,8 @@ type DailySummaryRow struct {
 
 const errorSpikeTimePeriod = time.Hour
 const errorAlertCooldownPeriod = 7 * 24 * time.Hour // 1 week
+const newFatalGroupAlertCooldownPeriod = 90 * 24 * time.Hour
+const latestReleaseLookbackPeriod = 90 * 24 * time.Hour
 const bugReportTimePeriod = 15 * time.Minute
 const defaultErrorGoodThreshold = 95.0
 const defaultErrorCautionThreshold = 85.0
@@ -251,6 +265,7 @@ func CreateBugReportAlerts(ctx context.Context) {
 
 				scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
 				scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+				scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
 			}
 
 			if bugReportRows != nil {
@@ -306,6 +321,7 @@ func CreateDailySummary(ctx context.Context) {
 
 		dashboardURL := fmt.Sprintf("%s/%s/overview", server.Server.Config.SiteOrigin, team.ID)
 		scheduleDailySummarySlackMessageForTeamChannels(ctx, team.ID, team.Name, dashboardURL, date, appSummaries)
+		scheduleDailySummaryWebhookForTeam(ctx, team, dashboardURL, date, appSummaries)
 	}
 }
 
@@ -702,6 +718,18 @@ func scheduleEmailAlertsForteamMembers(ctx context.Context, alert Alert, message
 		subject, body = email.AnrSpikeAlertEmail(appName, message, url)
 	} else if alert.Type == string(AlertTypeBugReport) {
 		subject, body = email.BugReportAlertEmail(appName, message, url)
+	} else if isNetworkAlertType(alert.Type) {
+		subject = appName + " - Network Regression Alert"
+		body = email.RenderEmailBody(subject, email.PlainTextContent(message), "View in Dashboard", url)
+	} else if alert.Type == string(AlertTypeNewFatalGroup) || alert.Type == string(AlertTypeNewAnrGroup) {
+		subject = appName + " - New Error Alert"
+		body = email.RenderEmailBody(subject, email.PlainTextContent(message), "View in Dashboard", url)
+	} else if alert.Type == string(AlertTypeReleaseRegression) {
+		subject = appName + " - Release Regression Alert"
+		body = email.RenderEmailBody(subject, email.PlainTextContent(message), "View in Dashboard", url)
+	} else if alert.Type == string(AlertTypeMissingSymbols) {
+		subject = appName + " - Missing Symbols Alert"
+		body = email.RenderEmailBody(subject, email.PlainTextContent(message), "View in Dashboard", url)
 	} else {
 		subject = appName + " - Alert"
 		body = email.RenderEmailBody(subject, email.PlainTextContent(message), "View in Dashboard", url)
@@ -751,6 +779,14 @@ func scheduleSlackAlertsForTeamChannels(ctx context.Context, alert Alert, messag
 		title = appName + " - ANR Spike Alert"
 	} else if alert.Type == string(AlertTypeBugReport) {
 		title = appName + " - New Bug Report"
+	} else if isNetworkAlertType(alert.Type) {
+		title = appName + " - Network Regression Alert"
+	} else if alert.Type == string(AlertTypeNewFatalGroup) || alert.Type == string(AlertTypeNewAnrGroup) {
+		title = appName + " - New Error Alert"
+	} else if alert.Type == string(AlertTypeReleaseRegression) {
+		title = appName + " - Release Regression Alert"
+	} else if alert.Type == string(AlertTypeMissingSymbols) {
+		title = appName + " - Missing Symbols Alert"
 	}
 
 	slackMessage := formatSlackAlertMessage(title, message, url)
@@ -861,6 +897,86 @@ func scheduleDailySummarySlackMessageForTeamChannels(ctx context.Context, teamId
 	}
 }
 
+// scheduleWebhookAlertForTeam queues the alert for delivery to the team's
+// webhook. Teams without an active webhook are skipped.
+func scheduleWebhookAlertForTeam(ctx context.Context, alert Alert, message, url, appName string) {
+	event := libwebhook.Event{
+		Type:      alert.Type,
+		CreatedAt: time.Now().UTC(),
+		Team:      libwebhook.Team{ID: alert.TeamID.String()},
+		App:       &libwebhook.App{ID: alert.AppID.String(), Name: appName},
+		Alert: &libwebhook.Alert{
+			ID:       alert.ID.String(),
+			EntityID: alert.EntityID,
+			Message:  message,
+			URL:      url,
+		},
+	}
+
+	if err := webhook.QueueDelivery(ctx, alert.TeamID, alert.AppID, event); err != nil {
+		if errors.Is(err, webhook.ErrNoActiveWebhook) {
+			fmt.Printf("No active webhook found for team %v\n", alert.TeamID)
+			return
+		}
+		fmt.Printf("Error queueing webhook alert for team %v: %v\n", alert.TeamID, err)
+	}
+}
+
+// scheduleDailySummaryWebhookForTeam queues the team daily summary for
+// delivery to the team's webhook. Teams without an active webhook are
+// skipped.
+func scheduleDailySummaryWebhookForTeam(ctx context.Context, team Team, dashboardURL string, date time.Time, apps []email.AppDailySummary) {
+	event := libwebhook.Event{
+		Type:         libwebhook.EventDailySummary,
+		CreatedAt:    time.Now().UTC(),
+		Team:         libwebhook.Team{ID: team.ID.String(), Name: team.Name},
+		DailySummary: formatDailySummaryWebhook(dashboardURL, date, apps),
+	}
+
+	if err := webhook.QueueDelivery(ctx, team.ID, nil, event); err != nil {
+		if errors.Is(err, webhook.ErrNoActiveWebhook) {
+			fmt.Printf("No active webhook found for team %v\n", team.ID)
+			return
+		}
+		fmt.Printf("Error queueing daily summary webhook for team %v: %v\n", team.ID, err)
+	}
+}
+
+// formatDailySummaryWebhook converts the daily summary cards into the
+// webhook payload, mapping the warning and error flags to a status the way
+// the Slack message labels them.
+func formatDailySummaryWebhook(dashboardURL string, date time.Time, apps []email.AppDailySummary) *libwebhook.DailySummary {
+	summary := &libwebhook.DailySummary{
+		Date: date.Format(time.DateOnly),
+		URL:  dashboardURL,
+		Apps: make([]libwebhook.AppSummary, 0, len(apps)),
+	}
+
+	for _, app := range apps {
+		metrics := make([]libwebhook.Metric, 0, len(app.Metrics))
+		for _, metric := range app.Metrics {
+			status := "good"
+			if metric.HasError {
+				status = "poor"
+			} else if metric.HasWarning {
+				status = "caution"
+			}
+			metrics = append(metrics, libwebhook.Metric{
+				Label:    metric.Label,
+				Value:    metric.Value,
+				Subtitle: metric.Subtitle,
+				Status:   status,
+			})
+		}
+		summary.Apps = append(summary.Apps, libwebhook.AppSummary{
+			AppName: app.AppName,
+			Metrics: metrics,
+		})
+	}
+
+	return summary
+}
+
 func formatSlackAlertMessage(title, message, url string) slack.SlackMessage {
 	blocks := []slack.SlackBlock{
 		slack.SlackHeaderBlock{
@@ -1148,6 +1264,7 @@ func createCrashAlertsForApp(ctx context.Context, team Team, app App, from, to t
 
 			scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
 			scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+			scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
 		}
 	}
 
@@ -1262,6 +1379,7 @@ func createAnrAlertsForApp(ctx context.Context, team Team, app App, from, to tim
 
 			scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
 			scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+			scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
 		}
 	}
 
@@ -1270,6 +1388,187 @@ func createAnrAlertsForApp(ctx context.Context, team Team, app App, from, to tim
 	}
 }
 
+// CreateNewFatalGroupAlerts raises an alert for each crash or
+// ANR group that first appeared in the latest release of an app,
+// as marked by the ingest worker when bucketing errors.
+func CreateNewFatalGroupAlerts(ctx context.Context) {
+	fmt.Println("Checking for new fatal error group alerts...")
+	teams, err := getActiveTeams(ctx)
+	if err != nil {
+		fmt.Printf("Error fetching teams: %v\n", err)
+		return
+	}
+
+	for _, team := range teams {
+		apps, err := getAppsForTeam(ctx, team.ID)
+		if err != nil {
+			fmt.Printf("Error fetching apps for team %v: %v\n", team.ID, err)
+			continue
+		}
+
+		for _, app := range apps {
+			version, versionCode, err := getLatestRelease(ctx, app)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				fmt.Printf("Error fetching latest release for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			createNewFatalGroupAlertsForApp(ctx, team, app, version, versionCode)
+		}
+	}
+}
+
+// getLatestRelease fetches the version of
+// the app first seen most recently.
+func getLatestRelease(ctx context.Context, app App) (version, versionCode string, err error) {
+	stmt := sqlf.From(config.AppMetricsTable).
+		Select("app_version.1").
+		Select("app_version.2").
+		Where("team_id = toUUID(?)", app.TeamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("timestamp >= ?", time.Now().UTC().Add(-latestReleaseLookbackPeriod)).
+		GroupBy("app_version").
+		OrderBy("min(timestamp) desc").
+		Limit(1)
+
+	defer stmt.Close()
+
+	err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&version, &versionCode)
+
+	return
+}
+
+func createNewFatalGroupAlertsForApp(ctx context.Context, team Team, app App, version, versionCode string) {
+	groupStmt := sqlf.PostgreSQL.From("measure.error_group_releases").
+		Select("kind").
+		Select("id").
+		Where("app_id = ?", app.ID).
+		Where("state = 'new'").
+		Where("kind in ('fatal', 'anr')").
+		Where("state_version = ?", version).
+		Where("state_version_code = ?", versionCode)
+
+	defer groupStmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, groupStmt.String(), groupStmt.Args()...)
+	if err != nil {
+		fmt.Printf("Error fetching new fatal groups for app %v: %v\n", app.ID, err)
+		return
+	}
+
+	type newGroup struct {
+		kind, fingerprint string
+	}
+
+	var groups []newGroup
+	for rows.Next() {
+		var g newGroup
+		if err := rows.Scan(&g.kind, &g.fingerprint); err != nil {
+			fmt.Printf("Error scanning new fatal group row: %v\n", err)
+			continue
+		}
+		groups = append(groups, g)
+	}
+	rows.Close()
+
+	release := fmt.Sprintf("%s (%s)", version, versionCode)
+
+	for _, g := range groups {
+		createNewFatalGroupAlert(ctx, team, app, release, g.kind, g.fingerprint)
+	}
+}
+
+// createNewFatalGroupAlert alerts the team of an error group
+// new in the release, unless an alert for it is in cooldown.
+// New ANR groups are alerted as new ANR groups, so they follow
+// the app hang notification preference.
+func createNewFatalGroupAlert(ctx context.Context, team Team, app App, release, groupKind, fingerprint string) {
+	alertType := AlertTypeNewFatalGroup
+	table, kind := "fatal_exception_groups final", "crash"
+	if groupKind == "anr" {
+		alertType = AlertTypeNewAnrGroup
+		table, kind = "anr_groups final", "ANR"
+	}
+
+	inCooldown, err := isInCooldown(ctx, team.ID, app.ID, fingerprint, string(alertType), newFatalGroupAlertCooldownPeriod)
+	if err != nil {
+		fmt.Printf("Error checking cooldown for new fatal group %s: %v\n", fingerprint, err)
+		return
+	}
+
+	if inCooldown {
+		return
+	}
+
+	var errorType, fileName, methodName, message string
+	groupInfoStmt := sqlf.From(table).
+		Select("argMax(type, timestamp)").
+		Select("argMax(file_name, timestamp)").
+		Select("argMax(method_name, timestamp)").
+		Select("argMax(message, timestamp)").
+		Where("team_id = toUUID(?)", team.ID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("id = ?", fingerprint)
+
+	defer groupInfoStmt.Close()
+
+	groupInfoRow := server.Server.ChPool.QueryRow(ctx, groupInfoStmt.String(), groupInfoStmt.Args()...)
+	if err := groupInfoRow.Scan(&errorType, &fileName, &methodName, &message); err != nil {
+		fmt.Printf("Error fetching group info for %s: %v\n", fingerprint, err)
+		return
+	}
+
+	file := fileName
+	if file == "" {
+		file = "unknown_file"
+	}
+	method := methodName
+	if method == "" {
+		method = "unknown_method"
+	}
+	alertMsg := alertmsg.NewFatalGroupMessage(kind, release, file, method, message)
+	alertUrl := alertmsg.CrashSpikeURL(server.Server.Config.SiteOrigin, team.ID.String(), app.ID.String(), fingerprint, errorType, fileName)
+	if groupKind == "anr" {
+		alertUrl = alertmsg.AnrSpikeURL(server.Server.Config.SiteOrigin, team.ID.String(), app.ID.String(), fingerprint, errorType, fileName)
+	}
+
+	fmt.Printf("Inserting alert for new fatal group %s\n", fingerprint)
+
+	alertID := uuid.New()
+	alertInsert := sqlf.PostgreSQL.InsertInto("alerts").
+		Set("id", alertID).
+		Set("team_id", team.ID).
+		Set("app_id", app.ID).
+		Set("entity_id", fingerprint).
+		Set("type", string(alertType)).
+		Set("message", alertMsg).
+		Set("url", alertUrl).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer alertInsert.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, alertInsert.String(), alertInsert.Args()...); err != nil {
+		fmt.Printf("Error inserting alert for new fatal group %s: %v\n", fingerprint, err)
+		return
+	}
+
+	alert := Alert{
+		ID:       alertID,
+		TeamID:   team.ID,
+		AppID:    app.ID,
+		EntityID: fingerprint,
+		Type:     string(alertType),
+	}
+
+	scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
+}
+
 // getNotifPrefByEmail looks up a user's notification preferences by email.
 // Returns all-true defaults if user or prefs not found.
 func getNotifPrefByEmail(ctx context.Context, userEmail string) (errorSpike, appHangSpike, bugReport, dailySummary bool) {
@@ -1304,9 +1603,9 @@ func shouldSendEmail(ctx context.Context, info email.EmailInfo) bool {
 	errorSpike, appHangSpike, bugReport, dailySummary := getNotifPrefByEmail(ctx, info.To)
 
 	switch info.AlertType {
-	case string(AlertTypeCrashSpike):
+	case string(AlertTypeCrashSpike), string(AlertTypeNewFatalGroup):
 		return errorSpike
-	case string(AlertTypeAnrSpike):
+	case string(AlertTypeAnrSpike), string(AlertTypeNewAnrGroup):
 		return appHangSpike
 	case string(AlertTypeBugReport):
 		return bugReport
@@ -3286,6 +3286,26 @@ func TestNotifPrefFiltering(t *testing.T) {
 		}
 	})
 
+	t.Run("shouldSendEmail gates new ANR groups by app_hang_spike preference", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		userID := uuid.New().String()
+		th.SeedUser(ctx, t, userID, "anr@example.com")
+		_, err := th.PgPool.Exec(ctx,
+			"INSERT INTO notif_prefs (user_id, error_spike, app_hang_spike, bug_report, daily_summary) VALUES ($1, true, false, true, true)",
+			userID)
+		if err != nil {
+			t.Fatalf("insert notif_prefs: %v", err)
+		}
+
+		info := email.EmailInfo{To: "anr@example.com", AlertType: string(AlertTypeNewAnrGroup)}
+		if shouldSendEmail(ctx, info) {
+			t.Error("should NOT send new ANR group email when app_hang_spike is false")
+		}
+	})
+
 	t.Run("shouldSendEmail respects bug_report preference", func(t *testing.T) {
 		ctx := context.Background()
 		setupAlertsTest(ctx, t)
@@ -0,0 +1,197 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/alerts/network"
+	"backend/alerts/server"
+	"backend/libs/alertmsg"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const networkAlertCooldownPeriod = 24 * time.Hour
+const defaultNetworkAlertsEnabled = true
+const defaultNetworkLatencyRegressionThreshold = 50.0  // percent
+const defaultNetworkErrorRateRegressionThreshold = 5.0 // percentage points
+const defaultNetworkTrafficDropThreshold = 80.0        // percent
+const defaultNetworkMinRequestCountThreshold = 100
+
+// NetworkAlertPrefs are an app's network
+// regression alert preferences.
+type NetworkAlertPrefs struct {
+	Enabled    bool
+	Thresholds network.RegressionThresholds
+}
+
+// CreateNetworkAlerts compares the last hour of every app's
+// aggregated http metrics against the same hour over the
+// previous days and raises an alert for each URL pattern
+// whose latency, error rates or traffic regressed.
+func CreateNetworkAlerts(ctx context.Context) {
+	fmt.Println("Checking for network regression alerts...")
+	teams, err := getActiveTeams(ctx)
+	if err != nil {
+		fmt.Printf("Error fetching teams: %v\n", err)
+		return
+	}
+
+	from, to, baselineWindows := network.RegressionWindows(time.Now())
+
+	for _, team := range teams {
+		apps, err := getAppsForTeam(ctx, team.ID)
+		if err != nil {
+			fmt.Printf("Error fetching apps for team %v: %v\n", team.ID, err)
+			continue
+		}
+
+		for _, app := range apps {
+			prefs, err := getNetworkAlertPrefs(ctx, app.ID)
+			if err != nil {
+				fmt.Printf("Error fetching network alert prefs for app %v, using defaults: %v\n", app.ID, err)
+				prefs = defaultNetworkAlertPrefs()
+			}
+
+			if !prefs.Enabled {
+				continue
+			}
+
+			current, err := network.FetchPatternMetrics(ctx, app.TeamID, app.ID, [][2]time.Time{{from, to}})
+			if err != nil {
+				fmt.Printf("Error fetching network metrics for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			baseline, err := network.FetchPatternMetrics(ctx, app.TeamID, app.ID, baselineWindows)
+			if err != nil {
+				fmt.Printf("Error fetching baseline network metrics for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			regressions := network.DetectRegressions(current, baseline, len(baselineWindows), prefs.Thresholds)
+			for _, r := range regressions {
+				createNetworkAlert(ctx, team, app, r)
+			}
+		}
+	}
+}
+
+func createNetworkAlert(ctx context.Context, team Team, app App, r network.Regression) {
+	alertType, alertMsg := networkAlertTypeAndMessage(r)
+	entityID := r.EntityID()
+
+	inCooldown, err := isInCooldown(ctx, team.ID, app.ID, entityID, string(alertType), networkAlertCooldownPeriod)
+	if err != nil {
+		fmt.Printf("Error checking cooldown for network alert %s: %v\n", entityID, err)
+		return
+	}
+
+	if inCooldown {
+		return
+	}
+
+	alertUrl := alertmsg.NetworkURL(server.Server.Config.SiteOrigin, team.ID.String(), r.Domain, r.Path)
+
+	fmt.Printf("Inserting alert for network regression %s\n", entityID)
+
+	alertID := uuid.New()
+	alertInsert := sqlf.PostgreSQL.InsertInto("alerts").
+		Set("id", alertID).
+		Set("team_id", team.ID).
+		Set("app_id", app.ID).
+		Set("entity_id", entityID).
+		Set("type", string(alertType)).
+		Set("message", alertMsg).
+		Set("url", alertUrl).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer alertInsert.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, alertInsert.String(), alertInsert.Args()...); err != nil {
+		fmt.Printf("Error inserting alert for network regression %s: %v\n", entityID, err)
+		return
+	}
+
+	alert := Alert{
+		ID:       alertID,
+		TeamID:   team.ID,
+		AppID:    app.ID,
+		EntityID: entityID,
+		Type:     string(alertType),
+	}
+
+	scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
+}
+
+// networkAlertTypeAndMessage maps a regression
+// to its alert type and message.
+func networkAlertTypeAndMessage(r network.Regression) (AlertType, string) {
+	switch r.Kind {
+	case network.RegressionLatency:
+		return AlertTypeNetworkLatency, alertmsg.NetworkLatencyMessage(r.Domain, r.Path, r.Current, r.Baseline)
+	case network.Regression5xxRate:
+		return AlertTypeNetwork5xxRate, alertmsg.NetworkErrorRateMessage(r.Domain, r.Path, "5xx", r.Current, r.Baseline)
+	case network.Regression4xxRate:
+		return AlertTypeNetwork4xxRate, alertmsg.NetworkErrorRateMessage(r.Domain, r.Path, "4xx", r.Current, r.Baseline)
+	default:
+		return AlertTypeNetworkTrafficDrop, alertmsg.NetworkTrafficDropMessage(r.Domain, r.Path, r.Current, r.Baseline)
+	}
+}
+
+func isNetworkAlertType(alertType string) bool {
+	switch AlertType(alertType) {
+	case AlertTypeNetworkLatency, AlertTypeNetwork5xxRate, AlertTypeNetwork4xxRate, AlertTypeNetworkTrafficDrop:
+		return true
+	}
+	return false
+}
+
+func defaultNetworkAlertPrefs() NetworkAlertPrefs {
+	return NetworkAlertPrefs{
+		Enabled: defaultNetworkAlertsEnabled,
+		Thresholds: network.RegressionThresholds{
+			LatencyIncrease:   defaultNetworkLatencyRegressionThreshold,
+			ErrorRateIncrease: defaultNetworkErrorRateRegressionThreshold,
+			TrafficDrop:       defaultNetworkTrafficDropThreshold,
+			MinRequestCount:   defaultNetworkMinRequestCountThreshold,
+		},
+	}
+}
+
+func getNetworkAlertPrefs(ctx context.Context, appID uuid.UUID) (NetworkAlertPrefs, error) {
+	stmt := sqlf.PostgreSQL.
+		From("measure.app_threshold_prefs").
+		Select("network_alerts_enabled").
+		Select("network_latency_regression_threshold").
+		Select("network_error_rate_regression_threshold").
+		Select("network_traffic_drop_threshold").
+		Select("network_min_request_count_threshold").
+		Where("app_id = ?", appID)
+	defer stmt.Close()
+
+	var prefs NetworkAlertPrefs
+	var minRequestCount int
+	err := server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&prefs.Enabled,
+		&prefs.Thresholds.LatencyIncrease,
+		&prefs.Thresholds.ErrorRateIncrease,
+		&prefs.Thresholds.TrafficDrop,
+		&minRequestCount,
+	)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return defaultNetworkAlertPrefs(), nil
+		}
+		return NetworkAlertPrefs{}, err
+	}
+	prefs.Thresholds.MinRequestCount = uint64(minRequestCount)
+
+	return prefs, nil
+}
@@ -0,0 +1,103 @@
+//go:build integration
+
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/alerts/network"
+
+	"github.com/google/uuid"
+)
+
+// seedHttpMetrics inserts one http_metrics bucket for a pattern
+// whose latency percentiles all sit at latencyMs.
+func seedHttpMetrics(ctx context.Context, t *testing.T, teamID, appID, domain, path string, at time.Time, requests, count5xx uint64, latencyMs int64) {
+	t.Helper()
+	err := th.ChConn.Exec(ctx,
+		`INSERT INTO http_metrics (team_id, app_id, timestamp, domain, path, request_count, count_2xx, count_5xx, latency_percentiles)
+		SELECT toUUID(?), toUUID(?), ?, ?, ?, ?, ?, ?, quantilesState(0.5, 0.75, 0.90, 0.95, 0.99)(toInt64(?))`,
+		teamID, appID, at, domain, path, requests, requests-count5xx, count5xx, latencyMs)
+	if err != nil {
+		t.Fatalf("seed http_metrics: %v", err)
+	}
+}
+
+func TestCreateNetworkAlerts(t *testing.T) {
+	seed := func(ctx context.Context, t *testing.T, currentLatencyMs int64) (teamID, appID string) {
+		t.Helper()
+		teamID = uuid.New().String()
+		appID = uuid.New().String()
+		userID := uuid.New().String()
+
+		th.SeedTeam(ctx, t, teamID, "Network Team")
+		th.SeedUser(ctx, t, userID, "owner@example.com")
+		th.SeedTeamMembership(ctx, t, teamID, userID, "owner")
+		th.SeedApp(ctx, t, appID, teamID, "Network App", 30)
+
+		from, _, baseline := network.RegressionWindows(time.Now())
+		seedHttpMetrics(ctx, t, teamID, appID, "api.example.com", "/users/*", from, 500, 0, currentLatencyMs)
+		for _, w := range baseline {
+			seedHttpMetrics(ctx, t, teamID, appID, "api.example.com", "/users/*", w[0], 500, 0, 200)
+		}
+		return teamID, appID
+	}
+
+	t.Run("latency regression raises an alert", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		seed(ctx, t, 1000)
+
+		CreateNetworkAlerts(ctx)
+
+		if got := countAlertsOfType(ctx, t, string(AlertTypeNetworkLatency)); got != 1 {
+			t.Fatalf("want 1 network latency alert, got %d", got)
+		}
+		if got := countPendingByChannel(ctx, t, "email"); got != 1 {
+			t.Errorf("want 1 pending email, got %d", got)
+		}
+
+		// a second run is in cooldown
+		CreateNetworkAlerts(ctx)
+
+		if got := countAlertsOfType(ctx, t, string(AlertTypeNetworkLatency)); got != 1 {
+			t.Errorf("want 1 network latency alert with cooldown, got %d", got)
+		}
+	})
+
+	t.Run("stable latency raises no alert", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		seed(ctx, t, 210)
+
+		CreateNetworkAlerts(ctx)
+
+		if got := countAlertsOfType(ctx, t, string(AlertTypeNetworkLatency)); got != 0 {
+			t.Errorf("want 0 alerts, got %d", got)
+		}
+	})
+
+	t.Run("disabled network alerts raise no alert", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		_, appID := seed(ctx, t, 1000)
+		th.SeedAppThresholdPrefs(ctx, t, appID, 95, 85, 100, 0.5)
+		if _, err := th.PgPool.Exec(ctx, "UPDATE measure.app_threshold_prefs SET network_alerts_enabled = false WHERE app_id = $1", appID); err != nil {
+			t.Fatalf("disable network alerts: %v", err)
+		}
+
+		CreateNetworkAlerts(ctx)
+
+		if got := countAlertsOfType(ctx, t, string(AlertTypeNetworkLatency)); got != 0 {
+			t.Errorf("want 0 alerts with network alerts disabled, got %d", got)
+		}
+	})
+}
@@ -0,0 +1,156 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/alerts/server"
+	"backend/libs/alertmsg"
+	"backend/libs/config"
+	"backend/libs/release"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+const releaseAdoptionPeriod = 7 * 24 * time.Hour  // 1 week
+const releaseLookbackPeriod = 90 * 24 * time.Hour // 90 days
+const releaseHealthTimePeriod = 24 * time.Hour
+const releaseAlertCooldownPeriod = 7 * 24 * time.Hour // 1 week
+
+// releaseRegressionThresholds are the thresholds past which
+// a newly adopted release regresses against the app's other
+// releases.
+var releaseRegressionThresholds = release.Thresholds{
+	CrashFreeDrop:  1,
+	ANRFreeDrop:    1,
+	LaunchIncrease: 25,
+	MinSessions:    500,
+}
+
+// CreateReleaseRegressionAlerts compares the last day of health of
+// every release first seen within the last week against the app's
+// other releases combined, and raises an alert for each metric that
+// regressed, so a staged rollout can be halted early.
+func CreateReleaseRegressionAlerts(ctx context.Context) {
+	fmt.Println("Checking for release regression alerts...")
+	teams, err := getActiveTeams(ctx)
+	if err != nil {
+		fmt.Printf("Error fetching teams: %v\n", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-releaseHealthTimePeriod)
+
+	for _, team := range teams {
+		apps, err := getAppsForTeam(ctx, team.ID)
+		if err != nil {
+			fmt.Printf("Error fetching apps for team %v: %v\n", team.ID, err)
+			continue
+		}
+
+		for _, app := range apps {
+			versions, err := getNewReleases(ctx, app, now)
+			if err != nil {
+				fmt.Printf("Error fetching new releases for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			for _, v := range versions {
+				target, others, err := release.GetHealth(ctx, server.Server.ChPool, app.TeamID, app.ID, v, from, now)
+				if err != nil {
+					fmt.Printf("Error fetching health of release %s for app %v: %v\n", v, app.ID, err)
+					continue
+				}
+
+				for _, r := range release.Regressions(others, target, releaseRegressionThresholds) {
+					createReleaseRegressionAlert(ctx, team, app, v, r)
+				}
+			}
+		}
+	}
+}
+
+// getNewReleases fetches the releases of an app
+// first seen within the adoption period.
+func getNewReleases(ctx context.Context, app App, now time.Time) ([]release.Version, error) {
+	stmt := sqlf.From(config.AppMetricsTable).
+		Select("app_version.1").
+		Select("app_version.2").
+		Where("team_id = toUUID(?)", app.TeamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("timestamp >= ?", now.Add(-releaseLookbackPeriod)).
+		GroupBy("app_version").
+		Having("min(timestamp) >= ?", now.Add(-releaseAdoptionPeriod))
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []release.Version
+	for rows.Next() {
+		var v release.Version
+		if err := rows.Scan(&v.Name, &v.Code); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+func createReleaseRegressionAlert(ctx context.Context, team Team, app App, v release.Version, r release.Regression) {
+	entityID := v.String() + ":" + string(r.Metric)
+
+	inCooldown, err := isInCooldown(ctx, team.ID, app.ID, entityID, string(AlertTypeReleaseRegression), releaseAlertCooldownPeriod)
+	if err != nil {
+		fmt.Printf("Error checking cooldown for release regression %s: %v\n", entityID, err)
+		return
+	}
+
+	if inCooldown {
+		return
+	}
+
+	alertMsg := alertmsg.ReleaseRegressionMessage(v.String(), r.Describe())
+	alertUrl := alertmsg.ReleaseURL(server.Server.Config.SiteOrigin, team.ID.String())
+
+	fmt.Printf("Inserting alert for release regression %s\n", entityID)
+
+	alertID := uuid.New()
+	alertInsert := sqlf.PostgreSQL.InsertInto("alerts").
+		Set("id", alertID).
+		Set("team_id", team.ID).
+		Set("app_id", app.ID).
+		Set("entity_id", entityID).
+		Set("type", string(AlertTypeReleaseRegression)).
+		Set("message", alertMsg).
+		Set("url", alertUrl).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer alertInsert.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, alertInsert.String(), alertInsert.Args()...); err != nil {
+		fmt.Printf("Error inserting alert for release regression %s: %v\n", entityID, err)
+		return
+	}
+
+	alert := Alert{
+		ID:       alertID,
+		TeamID:   team.ID,
+		AppID:    app.ID,
+		EntityID: entityID,
+		Type:     string(AlertTypeReleaseRegression),
+	}
+
+	scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
+}
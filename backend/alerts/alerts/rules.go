@@ -0,0 +1,350 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"backend/alerts/server"
+	"backend/libs/alertrule"
+	"backend/libs/config"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// EvaluateAlertRules evaluates every active alert rule that is due and
+// raises an alert on the rule's channels when the rule's metric breaches
+// its threshold. Rules of teams blocked from ingesting are skipped, like
+// the built-in alerts.
+func EvaluateAlertRules(ctx context.Context) {
+	fmt.Println("Evaluating alert rules...")
+	teams, err := getActiveTeams(ctx)
+	if err != nil {
+		fmt.Printf("Error fetching teams: %v\n", err)
+		return
+	}
+
+	for _, team := range teams {
+		rules, err := getActiveAlertRulesForTeam(ctx, team.ID)
+		if err != nil {
+			fmt.Printf("Error fetching alert rules for team %v: %v\n", team.ID, err)
+			continue
+		}
+
+		now := time.Now().UTC()
+		for _, r := range rules {
+			if !r.rule.Due(now) {
+				continue
+			}
+
+			evaluateAlertRule(ctx, team, r.app, r.rule, now)
+
+			if err := markAlertRuleEvaluated(ctx, r.rule.ID, now); err != nil {
+				fmt.Printf("Error marking alert rule %v evaluated: %v\n", r.rule.ID, err)
+			}
+		}
+	}
+}
+
+// appAlertRule is an alert rule along with the app it belongs to.
+type appAlertRule struct {
+	rule alertrule.Rule
+	app  App
+}
+
+func getActiveAlertRulesForTeam(ctx context.Context, teamID uuid.UUID) ([]appAlertRule, error) {
+	stmt := sqlf.PostgreSQL.
+		From("alert_rules r").
+		Join("apps a", "a.id = r.app_id").
+		Select("r.id").
+		Select("r.team_id").
+		Select("r.app_id").
+		Select("r.name").
+		Select("r.metric").
+		Select("r.aggregation").
+		Select("r.target").
+		Select("r.app_versions").
+		Select("r.operator").
+		Select("r.threshold").
+		Select("r.comparison").
+		Select("r.baseline_offset_minutes").
+		Select("r.min_sample_count").
+		Select("r.window_minutes").
+		Select("r.interval_minutes").
+		Select("r.cooldown_minutes").
+		Select("r.channels").
+		Select("r.is_active").
+		Select("r.last_evaluated_at").
+		Select("a.app_name").
+		Where("r.team_id = ?", teamID).
+		Where("r.is_active = true")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []appAlertRule
+	for rows.Next() {
+		var r appAlertRule
+		if err := rows.Scan(
+			&r.rule.ID,
+			&r.rule.TeamID,
+			&r.rule.AppID,
+			&r.rule.Name,
+			&r.rule.Metric,
+			&r.rule.Aggregation,
+			&r.rule.Target,
+			&r.rule.AppVersions,
+			&r.rule.Operator,
+			&r.rule.Threshold,
+			&r.rule.Comparison,
+			&r.rule.BaselineOffsetMinutes,
+			&r.rule.MinSampleCount,
+			&r.rule.WindowMinutes,
+			&r.rule.IntervalMinutes,
+			&r.rule.CooldownMinutes,
+			&r.rule.Channels,
+			&r.rule.IsActive,
+			&r.rule.LastEvaluatedAt,
+			&r.app.Name,
+		); err != nil {
+			return nil, err
+		}
+		r.app.ID = r.rule.AppID
+		r.app.TeamID = r.rule.TeamID
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+func markAlertRuleEvaluated(ctx context.Context, ruleID uuid.UUID, at time.Time) error {
+	stmt := sqlf.PostgreSQL.
+		Update("alert_rules").
+		Set("last_evaluated_at", at).
+		Where("id = ?", ruleID)
+
+	defer stmt.Close()
+
+	_, err := server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	return err
+}
+
+func evaluateAlertRule(ctx context.Context, team Team, app App, rule alertrule.Rule, now time.Time) {
+	inCooldown, err := isInCooldown(ctx, team.ID, app.ID, rule.ID.String(), alertrule.AlertType, rule.Cooldown())
+	if err != nil {
+		fmt.Printf("Error checking cooldown for alert rule %v: %v\n", rule.ID, err)
+		return
+	}
+
+	if inCooldown {
+		return
+	}
+
+	from := now.Add(-rule.Window())
+	value, samples, err := queryAlertRuleMetric(ctx, rule, from, now)
+	if err != nil {
+		fmt.Printf("Error computing metric for alert rule %v: %v\n", rule.ID, err)
+		return
+	}
+
+	if samples == 0 || samples < uint64(rule.MinSampleCount) {
+		return
+	}
+
+	var baseline float64
+	if rule.Comparison == alertrule.ComparisonRelative {
+		offset := rule.BaselineOffset()
+		var baselineSamples uint64
+		baseline, baselineSamples, err = queryAlertRuleMetric(ctx, rule, from.Add(-offset), now.Add(-offset))
+		if err != nil {
+			fmt.Printf("Error computing baseline for alert rule %v: %v\n", rule.ID, err)
+			return
+		}
+
+		if baselineSamples == 0 || baselineSamples < uint64(rule.MinSampleCount) {
+			return
+		}
+	}
+
+	breached, change := rule.Evaluate(value, baseline)
+	if !breached {
+		return
+	}
+
+	alertMsg := rule.Message(value, baseline, change)
+	alertUrl := fmt.Sprintf("%s/%s/alerts", server.Server.Config.SiteOrigin, team.ID)
+
+	fmt.Printf("Inserting alert for alert rule %s\n", rule.ID)
+
+	alertID := uuid.New()
+	alertInsert := sqlf.PostgreSQL.InsertInto("alerts").
+		Set("id", alertID).
+		Set("team_id", team.ID).
+		Set("app_id", app.ID).
+		Set("entity_id", rule.ID.String()).
+		Set("type", alertrule.AlertType).
+		Set("message", alertMsg).
+		Set("url", alertUrl).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer alertInsert.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, alertInsert.String(), alertInsert.Args()...); err != nil {
+		fmt.Printf("Error inserting alert for alert rule %s: %v\n", rule.ID, err)
+		return
+	}
+
+	alert := Alert{
+		ID:       alertID,
+		TeamID:   team.ID,
+		AppID:    app.ID,
+		EntityID: rule.ID.String(),
+		Type:     alertrule.AlertType,
+	}
+
+	if slices.Contains(rule.Channels, alertrule.ChannelEmail) {
+		scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
+	}
+	if slices.Contains(rule.Channels, alertrule.ChannelSlack) {
+		scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+	}
+	if slices.Contains(rule.Channels, alertrule.ChannelWebhook) {
+		scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
+	}
+}
+
+// queryAlertRuleMetric computes the rule's metric over [from, to) along
+// with the number of samples it was computed from. Rates count sessions
+// or requests, durations and event counts count events.
+func queryAlertRuleMetric(ctx context.Context, rule alertrule.Rule, from, to time.Time) (value float64, samples uint64, err error) {
+	var stmt *sqlf.Stmt
+
+	switch rule.Metric {
+	case alertrule.MetricColdLaunch, alertrule.MetricWarmLaunch, alertrule.MetricHotLaunch:
+		column := fmt.Sprintf("`%s.duration`", rule.Metric)
+		stmt = sqlf.From("events final").
+			Select(aggregationExpr(rule.Aggregation, column)).
+			Select("count()").
+			Where("type = ?", string(rule.Metric)).
+			Where(column + " > 0")
+		eventVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricCrashRate:
+		stmt = sqlf.From("events final").
+			Select("if(uniq(session_id) = 0, 0, uniqIf(session_id, type = 'exception' and " + config.FatalExceptionExpr + ") / uniq(session_id) * 100)").
+			Select("uniq(session_id)")
+		eventVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricAnrRate:
+		stmt = sqlf.From("events final").
+			Select("if(uniq(session_id) = 0, 0, uniqIf(session_id, type = 'anr') / uniq(session_id) * 100)").
+			Select("uniq(session_id)")
+		eventVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricEventCount:
+		stmt = sqlf.From("events final").
+			Select("toFloat64(count())").
+			Select("count()").
+			Where("type = 'custom'").
+			Where("`custom.name` = ?", rule.Target)
+		eventVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricHttp5xxRate, alertrule.MetricHttp4xxRate:
+		bucket := "5xx"
+		if rule.Metric == alertrule.MetricHttp4xxRate {
+			bucket = "4xx"
+		}
+		stmt = sqlf.From("http_events").
+			Select("if(count() = 0, 0, countIf(status_code_bucket = ?) / count() * 100)", bucket).
+			Select("count()").
+			Where("status_code_bucket in ('2xx','3xx','4xx','5xx')")
+		httpTarget(stmt, rule.Target)
+		tupleVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricHttpLatency:
+		stmt = sqlf.From("http_events").
+			Select(aggregationExpr(rule.Aggregation, "latency_ms")).
+			Select("count()").
+			Where("latency_ms > 0")
+		httpTarget(stmt, rule.Target)
+		tupleVersions(stmt, rule.AppVersions)
+
+	case alertrule.MetricSpanDuration:
+		stmt = sqlf.From("spans final").
+			Select(aggregationExpr(rule.Aggregation, "dateDiff('millisecond', start_time, end_time)")).
+			Select("count()").
+			Where("span_name = ?", rule.Target)
+		tupleVersions(stmt, rule.AppVersions)
+
+	default:
+		return 0, 0, fmt.Errorf("unsupported metric %q", rule.Metric)
+	}
+
+	timeColumn := "timestamp"
+	if rule.Metric == alertrule.MetricSpanDuration {
+		timeColumn = "start_time"
+	}
+
+	stmt.
+		Where("team_id = toUUID(?)", rule.TeamID).
+		Where("app_id = toUUID(?)", rule.AppID).
+		Where(timeColumn+" >= ?", from).
+		Where(timeColumn+" < ?", to)
+
+	defer stmt.Close()
+
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&value, &samples); err != nil {
+		return 0, 0, err
+	}
+
+	// quantiles and averages of an empty window are nan
+	if math.IsNaN(value) {
+		value = 0
+	}
+
+	return value, samples, nil
+}
+
+// aggregationExpr builds the aggregate function of a duration metric.
+func aggregationExpr(aggregation alertrule.Aggregation, column string) string {
+	if q, ok := aggregation.Quantile(); ok {
+		return fmt.Sprintf("toFloat64(quantile(%v)(%s))", q, column)
+	}
+	return fmt.Sprintf("toFloat64(avg(%s))", column)
+}
+
+// eventVersions scopes a query on the events table to app versions.
+func eventVersions(stmt *sqlf.Stmt, versions []string) {
+	if len(versions) > 0 {
+		stmt.Where("`attribute.app_version` in ?", versions)
+	}
+}
+
+// tupleVersions scopes a query on a table storing the app version as a
+// (version, build) tuple to app versions.
+func tupleVersions(stmt *sqlf.Stmt, versions []string) {
+	if len(versions) > 0 {
+		stmt.Where("`attribute.app_version`.1 in ?", versions)
+	}
+}
+
+// httpTarget scopes a query on http_events to the rule's url pattern.
+func httpTarget(stmt *sqlf.Stmt, target string) {
+	if target == "" {
+		return
+	}
+	domain, pathLike := alertrule.SplitURLPattern(target)
+	stmt.Where("domain = ?", domain)
+	if pathLike != "%" {
+		stmt.Where("path like ?", pathLike)
+	}
+}
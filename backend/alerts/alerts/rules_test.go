@@ -0,0 +1,141 @@
+//go:build integration
+
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/libs/alertrule"
+
+	"github.com/google/uuid"
+)
+
+// seedColdLaunchRule inserts an active rule alerting when p95 cold launch
+// goes above thresholdMs over the last hour.
+func seedColdLaunchRule(ctx context.Context, t *testing.T, teamID, appID string, thresholdMs float64, channels []string) uuid.UUID {
+	t.Helper()
+	ruleID := uuid.New()
+	_, err := th.PgPool.Exec(ctx,
+		`INSERT INTO alert_rules (id, team_id, app_id, name, metric, aggregation, operator, threshold, comparison,
+			window_minutes, interval_minutes, cooldown_minutes, channels)
+		VALUES ($1, $2, $3, 'Slow cold launch', 'cold_launch', 'p95', 'gt', $4, 'absolute', 60, 5, 240, $5)`,
+		ruleID, teamID, appID, thresholdMs, channels)
+	if err != nil {
+		t.Fatalf("seed alert rule: %v", err)
+	}
+	return ruleID
+}
+
+func TestEvaluateAlertRules(t *testing.T) {
+	seed := func(ctx context.Context, t *testing.T, durationMs uint32) (teamID, appID string) {
+		t.Helper()
+		teamID = uuid.New().String()
+		appID = uuid.New().String()
+		userID := uuid.New().String()
+
+		th.SeedTeam(ctx, t, teamID, "Rules Team")
+		th.SeedUser(ctx, t, userID, "owner@example.com")
+		th.SeedTeamMembership(ctx, t, teamID, userID, "owner")
+		th.SeedApp(ctx, t, appID, teamID, "Rules App", 30)
+
+		now := time.Now().UTC()
+		for i := 0; i < 20; i++ {
+			th.SeedLaunchEvent(ctx, t, teamID, appID, "cold_launch", durationMs, now.Add(-10*time.Minute))
+		}
+		return teamID, appID
+	}
+
+	t.Run("breached rule raises an alert on its channels", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		teamID, appID := seed(ctx, t, 4000)
+		ruleID := seedColdLaunchRule(ctx, t, teamID, appID, 3000, []string{alertrule.ChannelEmail})
+
+		EvaluateAlertRules(ctx)
+
+		if got := countAlertsOfType(ctx, t, alertrule.AlertType); got != 1 {
+			t.Fatalf("want 1 alert rule alert, got %d", got)
+		}
+		if got := countPendingByChannel(ctx, t, "email"); got != 1 {
+			t.Errorf("want 1 pending email, got %d", got)
+		}
+		if got := countPendingByChannel(ctx, t, "slack"); got != 0 {
+			t.Errorf("want no pending slack message for an email only rule, got %d", got)
+		}
+
+		var entityID string
+		if err := th.PgPool.QueryRow(ctx, "SELECT entity_id FROM alerts").Scan(&entityID); err != nil {
+			t.Fatalf("read alert: %v", err)
+		}
+		if entityID != ruleID.String() {
+			t.Errorf("entity_id = %q, want the rule id", entityID)
+		}
+
+		var lastEvaluatedAt *time.Time
+		if err := th.PgPool.QueryRow(ctx, "SELECT last_evaluated_at FROM alert_rules WHERE id = $1", ruleID).Scan(&lastEvaluatedAt); err != nil {
+			t.Fatalf("read rule: %v", err)
+		}
+		if lastEvaluatedAt == nil {
+			t.Error("last_evaluated_at is null, want it set after evaluation")
+		}
+	})
+
+	t.Run("rule within threshold raises no alert", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		teamID, appID := seed(ctx, t, 1000)
+		seedColdLaunchRule(ctx, t, teamID, appID, 3000, []string{alertrule.ChannelEmail})
+
+		EvaluateAlertRules(ctx)
+
+		if got := countAlertsOfType(ctx, t, alertrule.AlertType); got != 0 {
+			t.Errorf("want 0 alerts, got %d", got)
+		}
+	})
+
+	t.Run("rule in cooldown raises no second alert", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		teamID, appID := seed(ctx, t, 4000)
+		ruleID := seedColdLaunchRule(ctx, t, teamID, appID, 3000, []string{alertrule.ChannelEmail})
+
+		EvaluateAlertRules(ctx)
+
+		// make the rule due again, the cooldown must still hold
+		if _, err := th.PgPool.Exec(ctx, "UPDATE alert_rules SET last_evaluated_at = NULL WHERE id = $1", ruleID); err != nil {
+			t.Fatalf("reset rule: %v", err)
+		}
+
+		EvaluateAlertRules(ctx)
+
+		if got := countAlertsOfType(ctx, t, alertrule.AlertType); got != 1 {
+			t.Errorf("want 1 alert with cooldown, got %d", got)
+		}
+	})
+
+	t.Run("inactive rule is not evaluated", func(t *testing.T) {
+		ctx := context.Background()
+		setupAlertsTest(ctx, t)
+		defer cleanupAll(ctx, t)
+
+		teamID, appID := seed(ctx, t, 4000)
+		ruleID := seedColdLaunchRule(ctx, t, teamID, appID, 3000, []string{alertrule.ChannelEmail})
+		if _, err := th.PgPool.Exec(ctx, "UPDATE alert_rules SET is_active = false WHERE id = $1", ruleID); err != nil {
+			t.Fatalf("pause rule: %v", err)
+		}
+
+		EvaluateAlertRules(ctx)
+
+		if got := countAlertsOfType(ctx, t, alertrule.AlertType); got != 0 {
+			t.Errorf("want 0 alerts for an inactive rule, got %d", got)
+		}
+	})
+}
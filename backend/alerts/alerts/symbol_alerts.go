@@ -0,0 +1,134 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"backend/alerts/server"
+	"backend/libs/alertmsg"
+	"backend/libs/release"
+	"backend/libs/symbol"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// missingSymbolsAlertCooldownPeriod is long enough for
+// a release to be alerted on just once.
+const missingSymbolsAlertCooldownPeriod = releaseLookbackPeriod
+
+// CreateMissingSymbolsAlerts raises an alert for every release
+// first seen within the last week whose errors couldn't be
+// symbolicated for lack of symbols. Apps that never uploaded a
+// mapping file are skipped, they may not need any.
+func CreateMissingSymbolsAlerts(ctx context.Context) {
+	fmt.Println("Checking for missing symbols alerts...")
+	teams, err := getActiveTeams(ctx)
+	if err != nil {
+		fmt.Printf("Error fetching teams: %v\n", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	from := now.Add(-releaseAdoptionPeriod)
+
+	for _, team := range teams {
+		apps, err := getAppsForTeam(ctx, team.ID)
+		if err != nil {
+			fmt.Printf("Error fetching apps for team %v: %v\n", team.ID, err)
+			continue
+		}
+
+		for _, app := range apps {
+			hasMappings, err := symbol.HasMappings(ctx, server.Server.PgPool, app.ID)
+			if err != nil {
+				fmt.Printf("Error checking mappings of app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			if !hasMappings {
+				continue
+			}
+
+			versions, err := getNewReleases(ctx, app, now)
+			if err != nil {
+				fmt.Printf("Error fetching new releases for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			if len(versions) == 0 {
+				continue
+			}
+
+			coverages, err := symbol.GetCoverage(ctx, server.Server.ChPool, server.Server.PgPool, app.TeamID, app.ID, from, now)
+			if err != nil {
+				fmt.Printf("Error fetching symbol coverage for app %v: %v\n", app.ID, err)
+				continue
+			}
+
+			for _, c := range coverages {
+				v := release.Version{Name: c.VersionName, Code: c.VersionCode}
+				if c.MissingSymbols && slices.Contains(versions, v) {
+					createMissingSymbolsAlert(ctx, team, app, v, c)
+				}
+			}
+		}
+	}
+}
+
+func createMissingSymbolsAlert(ctx context.Context, team Team, app App, v release.Version, c symbol.Coverage) {
+	entityID := v.String()
+
+	inCooldown, err := isInCooldown(ctx, team.ID, app.ID, entityID, string(AlertTypeMissingSymbols), missingSymbolsAlertCooldownPeriod)
+	if err != nil {
+		fmt.Printf("Error checking cooldown for missing symbols %s: %v\n", entityID, err)
+		return
+	}
+
+	if inCooldown {
+		return
+	}
+
+	var percentage float64
+	if c.SymbolicatedPercentage != nil {
+		percentage = *c.SymbolicatedPercentage
+	}
+
+	alertMsg := alertmsg.MissingSymbolsMessage(v.String(), percentage, len(c.MissingDebugIDs))
+	alertUrl := alertmsg.BuildsURL(server.Server.Config.SiteOrigin, team.ID.String())
+
+	fmt.Printf("Inserting alert for missing symbols %s\n", entityID)
+
+	alertID := uuid.New()
+	alertInsert := sqlf.PostgreSQL.InsertInto("alerts").
+		Set("id", alertID).
+		Set("team_id", team.ID).
+		Set("app_id", app.ID).
+		Set("entity_id", entityID).
+		Set("type", string(AlertTypeMissingSymbols)).
+		Set("message", alertMsg).
+		Set("url", alertUrl).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer alertInsert.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, alertInsert.String(), alertInsert.Args()...); err != nil {
+		fmt.Printf("Error inserting alert for missing symbols %s: %v\n", entityID, err)
+		return
+	}
+
+	alert := Alert{
+		ID:       alertID,
+		TeamID:   team.ID,
+		AppID:    app.ID,
+		EntityID: entityID,
+		Type:     string(AlertTypeMissingSymbols),
+	}
+
+	scheduleEmailAlertsForteamMembers(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleSlackAlertsForTeamChannels(ctx, alert, alertMsg, alertUrl, app.Name)
+	scheduleWebhookAlertForTeam(ctx, alert, alertMsg, alertUrl, app.Name)
+}
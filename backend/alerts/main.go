@@ -9,9 +9,11 @@ import (
 	"strings"
 
 	"backend/alerts/alerts"
+	"backend/alerts/metricexport"
 	"backend/alerts/network"
 	"backend/alerts/server"
 	"backend/alerts/slack"
+	"backend/alerts/webhook"
 
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
@@ -91,6 +93,13 @@ func initCron(ctx context.Context) *cron.Cron {
 
 	fmt.Println("Scheduled crash & ANR job")
 
+	// run every 15m
+	if _, err := cron.AddFunc("*/15 * * * *", func() { alerts.CreateNewFatalGroupAlerts(ctx) }); err != nil {
+		fmt.Printf("Failed to schedule new fatal error group alert job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled new fatal error group alert job")
+
 	// run every 15m
 	if _, err := cron.AddFunc("*/15 * * * *", func() { alerts.CreateBugReportAlerts(ctx) }); err != nil {
 		fmt.Printf("Failed to schedule bug report alert job: %v\n", err)
@@ -98,6 +107,13 @@ func initCron(ctx context.Context) *cron.Cron {
 
 	fmt.Println("Scheduled bug report alert job")
 
+	// run every 1m, each rule is evaluated on its own interval
+	if _, err := cron.AddFunc("@every 1m", func() { alerts.EvaluateAlertRules(ctx) }); err != nil {
+		fmt.Printf("Failed to schedule alert rules job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled alert rules job")
+
 	// run every 5m
 	if _, err := cron.AddFunc("@every 5m", func() { alerts.SendPendingAlertEmails(ctx) }); err != nil {
 		fmt.Printf("Failed to schedule email alert job: %v\n", err)
@@ -112,6 +128,14 @@ func initCron(ctx context.Context) *cron.Cron {
 
 	fmt.Println("Scheduled Slack alert job")
 
+	// run every 1m, retries back off per delivery. a slow run must not
+	// overlap the next one, or both would send the same deliveries
+	if _, err := cron.AddJob("@every 1m", skipIfStillRunning(func() { webhook.SendPendingWebhookDeliveries(ctx) })); err != nil {
+		fmt.Printf("Failed to schedule webhook delivery job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled webhook delivery job")
+
 	// run every 1 hour
 	if _, err := cron.AddFunc("@every 1h", func() { network.GeneratePatterns(ctx) }); err != nil {
 		fmt.Printf("Failed to schedule URL pattern generation job: %v\n", err)
@@ -126,10 +150,44 @@ func initCron(ctx context.Context) *cron.Cron {
 
 	fmt.Println("Scheduled HTTP metrics generation job")
 
+	// run every 15 minutes
+	if _, err := cron.AddFunc("*/15 * * * *", func() { alerts.CreateNetworkAlerts(ctx) }); err != nil {
+		fmt.Printf("Failed to schedule network regression alert job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled network regression alert job")
+
+	// run every 1 hour
+	if _, err := cron.AddFunc("0 * * * *", func() { alerts.CreateReleaseRegressionAlerts(ctx) }); err != nil {
+		fmt.Printf("Failed to schedule release regression alert job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled release regression alert job")
+
+	// run every 1 hour
+	if _, err := cron.AddFunc("0 * * * *", func() { alerts.CreateMissingSymbolsAlerts(ctx) }); err != nil {
+		fmt.Printf("Failed to schedule missing symbols alert job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled missing symbols alert job")
+
+	// run every 5m
+	if _, err := cron.AddJob("@every 5m", skipIfStillRunning(func() { metricexport.PushMetrics(ctx) })); err != nil {
+		fmt.Printf("Failed to schedule metric export job: %v\n", err)
+	}
+
+	fmt.Println("Scheduled metric export job")
+
 	cron.Start()
 	return cron
 }
 
+// skipIfStillRunning wraps fn in a job that skips a
+// run while the previous run is still going.
+func skipIfStillRunning(fn func()) cron.Job {
+	return cron.NewChain(cron.SkipIfStillRunning(cron.DefaultLogger)).Then(cron.FuncJob(fn))
+}
+
 func initTracer(otelServiceName string) func(context.Context) error {
 	otelCollectorURL := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
 	otelInsecureMode := os.Getenv("OTEL_INSECURE_MODE")
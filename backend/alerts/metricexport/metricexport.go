@@ -0,0 +1,166 @@
+package metricexport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/alerts/server"
+	"backend/libs/chquery"
+	"backend/libs/logcomment"
+	libmetricexport "backend/libs/metricexport"
+	libwebhook "backend/libs/webhook"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// client posts metrics. It refuses to connect to non-public
+// addresses, like webhook deliveries. A package var so tests
+// can swap the transport.
+var client = libwebhook.NewClient()
+
+// PushMetrics collects the metrics of every team with an active
+// OTLP export and pushes them to the team's endpoint. The
+// outcome of each push is recorded on the export.
+func PushMetrics(ctx context.Context) {
+	fmt.Println("Starting metric export job...")
+
+	exports, err := libmetricexport.GetPushExports(ctx, server.Server.PgPool)
+	if err != nil {
+		fmt.Printf("failed to fetch metric exports: %v\n", err)
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.MetricExport).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "push"))
+
+	for _, export := range exports {
+		now := time.Now().UTC()
+		pushErr := push(ctx, export, now)
+		if pushErr != nil {
+			fmt.Printf("failed to push metrics for team=%s: %v\n", export.TeamID, pushErr)
+		}
+
+		if err := libmetricexport.SetPushResult(ctx, server.Server.PgPool, export.TeamID, now, pushErr); err != nil {
+			fmt.Printf("failed to record metric push for team=%s: %v\n", export.TeamID, err)
+		}
+	}
+}
+
+// push collects the team's metrics at now
+// and posts them to the export's endpoint.
+func push(ctx context.Context, export libmetricexport.Export, now time.Time) error {
+	apps, err := libmetricexport.GetTeamApps(ctx, server.Server.PgPool, export.TeamID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch apps: %w", err)
+	}
+
+	if len(apps) == 0 {
+		return nil
+	}
+
+	samples, err := libmetricexport.Collect(ctx, server.Server.ChPool, export.TeamID, apps, now)
+	if err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+
+	body, err := proto.Marshal(newRequest(export.TeamID.String(), samples, now))
+	if err != nil {
+		return fmt.Errorf("failed to marshal metrics: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *export.OTLPEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for name, value := range export.OTLPHeaders {
+		req.Header.Set(name, value)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// the response body is not kept, it is the endpoint's
+	// to say and would be shown back on the dashboard
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded with %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// newRequest builds an OTLP export request with one gauge per
+// metric, holding a data point for each of the metric's samples.
+func newRequest(teamID string, samples []libmetricexport.Sample, now time.Time) *colmetricspb.ExportMetricsServiceRequest {
+	var metrics []*metricspb.Metric
+	gauges := map[string]*metricspb.Gauge{}
+	timestamp := uint64(now.UnixNano())
+	start := uint64(now.Add(-libmetricexport.Window).UnixNano())
+
+	for _, s := range samples {
+		gauge, ok := gauges[s.Metric.Name]
+		if !ok {
+			gauge = &metricspb.Gauge{}
+			gauges[s.Metric.Name] = gauge
+			metrics = append(metrics, &metricspb.Metric{
+				Name:        s.Metric.Name,
+				Description: s.Metric.Help,
+				Unit:        s.Metric.Unit,
+				Data:        &metricspb.Metric_Gauge{Gauge: gauge},
+			})
+		}
+
+		attrs := make([]*commonpb.KeyValue, 0, len(s.Labels))
+		for _, l := range s.Labels {
+			attrs = append(attrs, stringAttr(l.Name, l.Value))
+		}
+
+		gauge.DataPoints = append(gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:        attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      timestamp,
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: s.Value},
+		})
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{
+						stringAttr("service.name", "measure"),
+						stringAttr("measure.team_id", teamID),
+					},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope:   &commonpb.InstrumentationScope{Name: "measure"},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
@@ -0,0 +1,62 @@
+package metricexport
+
+import (
+	"testing"
+	"time"
+
+	libmetricexport "backend/libs/metricexport"
+)
+
+func TestNewRequest(t *testing.T) {
+	now := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	labels := []libmetricexport.Label{{Name: "app_id", Value: "a"}}
+
+	samples := []libmetricexport.Sample{
+		{Metric: libmetricexport.Sessions, Labels: labels, Value: 10},
+		{Metric: libmetricexport.CrashFreeSessions, Labels: labels, Value: 0.9},
+		{Metric: libmetricexport.Sessions, Labels: []libmetricexport.Label{{Name: "app_id", Value: "b"}}, Value: 4},
+	}
+
+	req := newRequest("team", samples, now)
+
+	if len(req.ResourceMetrics) != 1 {
+		t.Fatalf("Expected 1 resource, got %d", len(req.ResourceMetrics))
+	}
+
+	rm := req.ResourceMetrics[0]
+	attrs := map[string]string{}
+	for _, kv := range rm.Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+
+	if attrs["service.name"] != "measure" || attrs["measure.team_id"] != "team" {
+		t.Errorf("Expected measure service and team resource attributes, got %v", attrs)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 2 {
+		t.Fatalf("Expected 2 metrics, got %d", len(metrics))
+	}
+
+	sessions := metrics[0]
+	if sessions.Name != libmetricexport.Sessions.Name || sessions.Unit != libmetricexport.Sessions.Unit {
+		t.Errorf("Expected sessions metric first, got %s", sessions.Name)
+	}
+
+	points := sessions.GetGauge().DataPoints
+	if len(points) != 2 {
+		t.Fatalf("Expected 2 data points, got %d", len(points))
+	}
+
+	if points[1].GetAsDouble() != 4 || points[1].Attributes[0].Value.GetStringValue() != "b" {
+		t.Errorf("Expected second data point to be app b with 4 sessions, got %v", points[1])
+	}
+
+	if points[0].TimeUnixNano != uint64(now.UnixNano()) {
+		t.Errorf("Expected data point at %d, got %d", now.UnixNano(), points[0].TimeUnixNano)
+	}
+
+	if start := now.Add(-libmetricexport.Window); points[0].StartTimeUnixNano != uint64(start.UnixNano()) {
+		t.Errorf("Expected data point to start at %d, got %d", start.UnixNano(), points[0].StartTimeUnixNano)
+	}
+}
@@ -0,0 +1,267 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/alerts/server"
+	"backend/libs/chquery"
+	"backend/libs/logcomment"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	// RegressionWindow is the length of the window
+	// compared against the baseline.
+	RegressionWindow = 1 * time.Hour
+
+	// RegressionBaselineDays is the number of previous
+	// days whose same time-of-day window forms the
+	// rolling baseline.
+	RegressionBaselineDays = 7
+
+	// metricsBucketSize is the bucket size of
+	// the http_metrics table.
+	metricsBucketSize = 15 * time.Minute
+
+	// minLatencyIncreaseMs is the minimum p95 latency
+	// increase in milliseconds for a latency regression,
+	// so small absolute changes on fast endpoints don't
+	// alert.
+	minLatencyIncreaseMs = 100
+)
+
+// RegressionKind is the kind of regression
+// detected for a URL pattern.
+type RegressionKind string
+
+const (
+	RegressionLatency     RegressionKind = "latency"
+	Regression5xxRate     RegressionKind = "5xx_rate"
+	Regression4xxRate     RegressionKind = "4xx_rate"
+	RegressionTrafficDrop RegressionKind = "traffic_drop"
+)
+
+// PatternMetrics are the aggregated metrics
+// of a URL pattern over a window.
+type PatternMetrics struct {
+	Domain       string
+	Path         string
+	RequestCount uint64
+	Count4xx     uint64
+	Count5xx     uint64
+	// P95 is the p95 latency in milliseconds.
+	P95 float64
+}
+
+// rate returns the percent of requests
+// counted by count.
+func (m PatternMetrics) rate(count uint64) float64 {
+	if m.RequestCount == 0 {
+		return 0
+	}
+	return float64(count) / float64(m.RequestCount) * 100
+}
+
+// RegressionThresholds tune regression detection.
+type RegressionThresholds struct {
+	// LatencyIncrease is the percent increase of
+	// p95 latency over the baseline.
+	LatencyIncrease float64
+	// ErrorRateIncrease is the increase in percentage
+	// points of the 4xx or 5xx rate over the baseline.
+	ErrorRateIncrease float64
+	// TrafficDrop is the percent drop of the request
+	// count below the baseline.
+	TrafficDrop float64
+	// MinRequestCount is the minimum request count in
+	// the window, and in the average baseline window,
+	// for a pattern to be compared.
+	MinRequestCount uint64
+}
+
+// Regression is a regression of a URL pattern.
+type Regression struct {
+	Kind     RegressionKind
+	Domain   string
+	Path     string
+	Current  float64
+	Baseline float64
+}
+
+// EntityID identifies the regressed pattern and
+// kind, used to cool down repeated alerts.
+func (r Regression) EntityID() string {
+	return string(r.Kind) + ":" + r.Domain + r.Path
+}
+
+// DetectRegressions compares the current window metrics
+// of each URL pattern against its baseline. The baseline
+// holds the sum of the same window over baselineDays
+// previous days, so request counts are averaged per day
+// while rates and latency percentiles compare as is.
+// Regressions are sorted by pattern and kind.
+func DetectRegressions(current, baseline []PatternMetrics, baselineDays int, t RegressionThresholds) []Regression {
+	if baselineDays < 1 {
+		baselineDays = 1
+	}
+
+	currentIndex := make(map[string]PatternMetrics, len(current))
+	for _, m := range current {
+		currentIndex[m.Domain+m.Path] = m
+	}
+
+	var regressions []Regression
+	for _, base := range baseline {
+		cur, ok := currentIndex[base.Domain+base.Path]
+		if !ok {
+			cur = PatternMetrics{Domain: base.Domain, Path: base.Path}
+		}
+
+		// a pattern without enough usual traffic says
+		// nothing reliable about any regression
+		avgBaselineCount := float64(base.RequestCount) / float64(baselineDays)
+		if avgBaselineCount < float64(t.MinRequestCount) {
+			continue
+		}
+
+		if t.TrafficDrop > 0 && float64(cur.RequestCount) < avgBaselineCount*(1-t.TrafficDrop/100) {
+			regressions = append(regressions, Regression{
+				Kind:     RegressionTrafficDrop,
+				Domain:   base.Domain,
+				Path:     base.Path,
+				Current:  float64(cur.RequestCount),
+				Baseline: avgBaselineCount,
+			})
+		}
+
+		if cur.RequestCount < t.MinRequestCount {
+			continue
+		}
+
+		if t.LatencyIncrease > 0 && base.P95 > 0 &&
+			cur.P95 > base.P95*(1+t.LatencyIncrease/100) &&
+			cur.P95-base.P95 >= minLatencyIncreaseMs {
+			regressions = append(regressions, Regression{
+				Kind:     RegressionLatency,
+				Domain:   base.Domain,
+				Path:     base.Path,
+				Current:  cur.P95,
+				Baseline: base.P95,
+			})
+		}
+
+		if t.ErrorRateIncrease > 0 {
+			if cur5xx, base5xx := cur.rate(cur.Count5xx), base.rate(base.Count5xx); cur5xx-base5xx >= t.ErrorRateIncrease {
+				regressions = append(regressions, Regression{
+					Kind:     Regression5xxRate,
+					Domain:   base.Domain,
+					Path:     base.Path,
+					Current:  cur5xx,
+					Baseline: base5xx,
+				})
+			}
+
+			if cur4xx, base4xx := cur.rate(cur.Count4xx), base.rate(base.Count4xx); cur4xx-base4xx >= t.ErrorRateIncrease {
+				regressions = append(regressions, Regression{
+					Kind:     Regression4xxRate,
+					Domain:   base.Domain,
+					Path:     base.Path,
+					Current:  cur4xx,
+					Baseline: base4xx,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(regressions, func(i, j int) bool {
+		a, b := regressions[i], regressions[j]
+		if a.Domain+a.Path != b.Domain+b.Path {
+			return a.Domain+a.Path < b.Domain+b.Path
+		}
+		return a.Kind < b.Kind
+	})
+
+	return regressions
+}
+
+// RegressionWindows returns the current window and the
+// windows at the same time of day on each of the baseline
+// days before it. The current window ends a bucket before
+// the one containing now, so the metrics job has finished
+// aggregating every bucket in it.
+func RegressionWindows(now time.Time) (from, to time.Time, baseline [][2]time.Time) {
+	to = now.UTC().Truncate(metricsBucketSize).Add(-metricsBucketSize)
+	from = to.Add(-RegressionWindow)
+
+	baseline = make([][2]time.Time, 0, RegressionBaselineDays)
+	for day := 1; day <= RegressionBaselineDays; day++ {
+		offset := time.Duration(day) * 24 * time.Hour
+		baseline = append(baseline, [2]time.Time{from.Add(-offset), to.Add(-offset)})
+	}
+
+	return
+}
+
+// FetchPatternMetrics aggregates http_metrics per URL
+// pattern over the given windows for an app.
+func FetchPatternMetrics(ctx context.Context, teamID, appID uuid.UUID, windows [][2]time.Time) ([]PatternMetrics, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	stmt := sqlf.
+		Select("domain").
+		Select("path").
+		Select("sum(request_count)").
+		Select("sum(count_4xx)").
+		Select("sum(count_5xx)").
+		Select("arrayElement(quantilesMerge(0.5, 0.75, 0.90, 0.95, 0.99)(latency_percentiles), 4)").
+		From("http_metrics").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID)
+
+	conds := make([]string, 0, len(windows))
+	args := make([]any, 0, 2*len(windows))
+	for _, w := range windows {
+		conds = append(conds, "(timestamp >= ? AND timestamp < ?)")
+		args = append(args, w[0], w[1])
+	}
+	stmt.Where("("+strings.Join(conds, " OR ")+")", args...)
+
+	stmt.GroupBy("domain").GroupBy("path")
+	defer stmt.Close()
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.Network).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "fetch_pattern_metrics"))
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query http_metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var result []PatternMetrics
+	for rows.Next() {
+		var m PatternMetrics
+		if err := rows.Scan(&m.Domain, &m.Path, &m.RequestCount, &m.Count4xx, &m.Count5xx, &m.P95); err != nil {
+			return nil, fmt.Errorf("failed to scan http_metrics row: %w", err)
+		}
+		result = append(result, m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating http_metrics rows: %w", err)
+	}
+
+	return result, nil
+}
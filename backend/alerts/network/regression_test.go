@@ -0,0 +1,152 @@
+package network
+
+import (
+	"testing"
+	"time"
+)
+
+var testThresholds = RegressionThresholds{
+	LatencyIncrease:   50,
+	ErrorRateIncrease: 5,
+	TrafficDrop:       80,
+	MinRequestCount:   100,
+}
+
+func TestDetectRegressions_NoChangeRaisesNothing(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/users/*", RequestCount: 1000, Count4xx: 10, Count5xx: 5, P95: 200},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/users/*", RequestCount: 7000, Count4xx: 70, Count5xx: 35, P95: 210},
+	}
+
+	if got := DetectRegressions(current, baseline, 7, testThresholds); len(got) != 0 {
+		t.Errorf("Expected no regressions, but got %v", got)
+	}
+}
+
+func TestDetectRegressions_LatencyIncrease(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/users/*", RequestCount: 1000, P95: 600},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/users/*", RequestCount: 7000, P95: 300},
+	}
+
+	got := DetectRegressions(current, baseline, 7, testThresholds)
+	if len(got) != 1 {
+		t.Fatalf("Expected 1 regression, but got %v", got)
+	}
+	if got[0].Kind != RegressionLatency || got[0].Current != 600 || got[0].Baseline != 300 {
+		t.Errorf("Expected a latency regression from 300 to 600, but got %+v", got[0])
+	}
+}
+
+func TestDetectRegressions_SmallAbsoluteLatencyIncreaseIgnored(t *testing.T) {
+	// doubles, but by less than minLatencyIncreaseMs
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/health", RequestCount: 1000, P95: 40},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/health", RequestCount: 7000, P95: 20},
+	}
+
+	if got := DetectRegressions(current, baseline, 7, testThresholds); len(got) != 0 {
+		t.Errorf("Expected no regressions, but got %v", got)
+	}
+}
+
+func TestDetectRegressions_ErrorRateIncrease(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/orders", RequestCount: 1000, Count4xx: 100, Count5xx: 80, P95: 200},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/orders", RequestCount: 7000, Count4xx: 70, Count5xx: 70, P95: 200},
+	}
+
+	got := DetectRegressions(current, baseline, 7, testThresholds)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 regressions, but got %v", got)
+	}
+	if got[0].Kind != Regression4xxRate || got[0].Current != 10 || got[0].Baseline != 1 {
+		t.Errorf("Expected a 4xx rate regression from 1%% to 10%%, but got %+v", got[0])
+	}
+	if got[1].Kind != Regression5xxRate || got[1].Current != 8 || got[1].Baseline != 1 {
+		t.Errorf("Expected a 5xx rate regression from 1%% to 8%%, but got %+v", got[1])
+	}
+}
+
+func TestDetectRegressions_TrafficDrop(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/feed", RequestCount: 50, P95: 200},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/feed", RequestCount: 7000, P95: 200},
+		{Domain: "api.example.com", Path: "/gone", RequestCount: 1400, P95: 200},
+	}
+
+	got := DetectRegressions(current, baseline, 7, testThresholds)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 regressions, but got %v", got)
+	}
+	if got[0].Kind != RegressionTrafficDrop || got[0].Path != "/feed" || got[0].Current != 50 || got[0].Baseline != 1000 {
+		t.Errorf("Expected a traffic drop from 1000 to 50 on /feed, but got %+v", got[0])
+	}
+	// a pattern missing from the current window dropped to zero
+	if got[1].Kind != RegressionTrafficDrop || got[1].Path != "/gone" || got[1].Current != 0 {
+		t.Errorf("Expected a traffic drop to 0 on /gone, but got %+v", got[1])
+	}
+}
+
+func TestDetectRegressions_LowTrafficIgnored(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/rare", RequestCount: 10, Count5xx: 10, P95: 5000},
+	}
+	baseline := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/rare", RequestCount: 70, P95: 100},
+	}
+
+	if got := DetectRegressions(current, baseline, 7, testThresholds); len(got) != 0 {
+		t.Errorf("Expected no regressions below the minimum request count, but got %v", got)
+	}
+}
+
+func TestDetectRegressions_NewPatternIgnored(t *testing.T) {
+	current := []PatternMetrics{
+		{Domain: "api.example.com", Path: "/new", RequestCount: 1000, Count5xx: 500, P95: 5000},
+	}
+
+	if got := DetectRegressions(current, nil, 7, testThresholds); len(got) != 0 {
+		t.Errorf("Expected no regressions without a baseline, but got %v", got)
+	}
+}
+
+func TestRegressionWindows(t *testing.T) {
+	now := time.Date(2026, 10, 17, 10, 37, 12, 0, time.UTC)
+
+	from, to, baseline := RegressionWindows(now)
+
+	wantTo := time.Date(2026, 10, 17, 10, 15, 0, 0, time.UTC)
+	if !to.Equal(wantTo) {
+		t.Errorf("Expected to %v, but got %v", wantTo, to)
+	}
+	if !from.Equal(wantTo.Add(-RegressionWindow)) {
+		t.Errorf("Expected from %v, but got %v", wantTo.Add(-RegressionWindow), from)
+	}
+	if len(baseline) != RegressionBaselineDays {
+		t.Fatalf("Expected %d baseline windows, but got %d", RegressionBaselineDays, len(baseline))
+	}
+	for i, w := range baseline {
+		offset := time.Duration(i+1) * 24 * time.Hour
+		if !w[0].Equal(from.Add(-offset)) || !w[1].Equal(to.Add(-offset)) {
+			t.Errorf("Expected baseline window %d to be %v - %v, but got %v - %v", i, from.Add(-offset), to.Add(-offset), w[0], w[1])
+		}
+	}
+}
+
+func TestRegressionEntityID(t *testing.T) {
+	r := Regression{Kind: RegressionLatency, Domain: "api.example.com", Path: "/users/*"}
+	if got, want := r.EntityID(), "latency:api.example.com/users/*"; got != want {
+		t.Errorf("Expected %q, but got %q", want, got)
+	}
+}
@@ -0,0 +1,200 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/alerts/server"
+	libwebhook "backend/libs/webhook"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	statusPending   = "pending"
+	statusDelivered = "delivered"
+	statusFailed    = "failed"
+)
+
+// ErrNoActiveWebhook is returned by QueueDelivery when the team has no
+// webhook or has paused it.
+var ErrNoActiveWebhook = errors.New("no active webhook")
+
+// client posts deliveries. A package var so tests can swap the transport.
+var client = libwebhook.NewClient()
+
+// QueueDelivery queues event for delivery to the team's webhook. The event
+// id is set to the delivery id, which the receiver sees on every retry.
+func QueueDelivery(ctx context.Context, teamID uuid.UUID, appID any, event libwebhook.Event) error {
+	activeStmt := sqlf.PostgreSQL.
+		Select("is_active").
+		From("team_webhooks").
+		Where("team_id = ?", teamID)
+
+	defer activeStmt.Close()
+
+	var isActive bool
+	if err := server.Server.PgPool.QueryRow(ctx, activeStmt.String(), activeStmt.Args()...).Scan(&isActive); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoActiveWebhook
+		}
+		return err
+	}
+
+	if !isActive {
+		return ErrNoActiveWebhook
+	}
+
+	deliveryID := uuid.New()
+	event.ID = deliveryID.String()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	insertStmt := sqlf.PostgreSQL.
+		InsertInto("webhook_deliveries").
+		Set("id", deliveryID).
+		Set("team_id", teamID).
+		Set("app_id", appID).
+		Set("event_type", event.Type).
+		SetExpr("payload", "?::jsonb", string(payload)).
+		Set("status", statusPending).
+		Set("attempts", 0).
+		Set("next_attempt_at", time.Now()).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer insertStmt.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, insertStmt.String(), insertStmt.Args()...); err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// claimLease is how long claimed deliveries stay hidden from other
+// senders. It covers a full batch of attempts timing out, and lets a
+// delivery claimed by a sender that died be picked up again.
+const claimLease = time.Hour
+
+// claimQuery claims up to 250 due pending deliveries, oldest due first,
+// by pushing their next attempt past the lease. Rows locked by another
+// sender are skipped, so concurrent senders never claim the same
+// delivery.
+const claimQuery = `update webhook_deliveries d
+set next_attempt_at = $1, updated_at = now()
+from team_webhooks w
+where w.team_id = d.team_id
+and d.id in (
+	select id from webhook_deliveries
+	where status = $2 and next_attempt_at <= now()
+	order by next_attempt_at asc
+	limit 250
+	for update skip locked
+)
+returning d.id, d.event_type, d.payload, d.attempts, w.url, w.secret, w.is_active`
+
+// SendPendingWebhookDeliveries posts due webhook deliveries. It claims
+// up to 250 deliveries at a time, oldest due first. A failed attempt is
+// rescheduled with exponential backoff while it is retryable and attempts
+// remain, otherwise the delivery is marked as failed. Every attempt is
+// recorded on the delivery row, which doubles as the team's delivery log.
+func SendPendingWebhookDeliveries(ctx context.Context) error {
+	fmt.Println("Checking pending webhook deliveries...")
+	rows, err := server.Server.PgPool.Query(ctx, claimQuery, time.Now().Add(claimLease), statusPending)
+	if err != nil {
+		return fmt.Errorf("failed to claim pending webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	type pendingDelivery struct {
+		ID        uuid.UUID
+		EventType string
+		Payload   []byte
+		Attempts  int
+		URL       string
+		Secret    string
+		IsActive  bool
+	}
+
+	var deliveries []pendingDelivery
+	for rows.Next() {
+		var d pendingDelivery
+		if err := rows.Scan(&d.ID, &d.EventType, &d.Payload, &d.Attempts, &d.URL, &d.Secret, &d.IsActive); err != nil {
+			fmt.Printf("failed to scan row: %s\n", err)
+			continue
+		}
+		deliveries = append(deliveries, d)
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("row error: %w", rows.Err())
+	}
+
+	for _, d := range deliveries {
+		// a webhook paused after the delivery was queued should not
+		// receive it later when resumed, the alert is stale by then
+		if !d.IsActive {
+			recordAttempt(ctx, d.ID, d.Attempts, statusFailed, nil, "webhook is paused", time.Now())
+			continue
+		}
+
+		fmt.Printf("Sending webhook delivery %v\n", d.ID)
+		attempts := d.Attempts + 1
+		code, err := libwebhook.Post(ctx, client, d.URL, d.Secret, d.ID.String(), d.EventType, d.Payload)
+
+		var responseStatus *int
+		if code != 0 {
+			responseStatus = &code
+		}
+
+		switch {
+		case err == nil:
+			recordAttempt(ctx, d.ID, attempts, statusDelivered, responseStatus, "", time.Now())
+		case libwebhook.Retryable(err) && attempts < libwebhook.MaxAttempts:
+			fmt.Printf("failed to send webhook delivery %s, attempt %d: %s\n", d.ID, attempts, err)
+			recordAttempt(ctx, d.ID, attempts, statusPending, responseStatus, err.Error(), time.Now().Add(libwebhook.Backoff(attempts)))
+		default:
+			fmt.Printf("giving up on webhook delivery %s after attempt %d: %s\n", d.ID, attempts, err)
+			recordAttempt(ctx, d.ID, attempts, statusFailed, responseStatus, err.Error(), time.Now())
+		}
+	}
+
+	return nil
+}
+
+// recordAttempt stores the outcome of a delivery attempt. nextAttemptAt
+// only matters while the delivery stays pending.
+func recordAttempt(ctx context.Context, id uuid.UUID, attempts int, status string, responseStatus *int, lastError string, nextAttemptAt time.Time) {
+	stmt := sqlf.PostgreSQL.
+		Update("webhook_deliveries").
+		Set("attempts", attempts).
+		Set("status", status).
+		Set("response_status", responseStatus).
+		Set("next_attempt_at", nextAttemptAt).
+		Set("updated_at", time.Now()).
+		Where("id = ?", id)
+
+	if lastError == "" {
+		stmt.Set("last_error", nil)
+	} else {
+		stmt.Set("last_error", lastError)
+	}
+
+	if status == statusDelivered {
+		stmt.Set("delivered_at", time.Now())
+	}
+
+	defer stmt.Close()
+
+	if _, err := server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+		fmt.Printf("failed to record webhook delivery attempt %s: %s\n", id, err)
+	}
+}
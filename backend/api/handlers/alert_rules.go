@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/alertrule"
+	"backend/libs/measure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// maxAlertRulesPerApp is the maximum number of alert rules an app can
+// have.
+const maxAlertRulesPerApp = 50
+
+// alertRuleColumns are the columns of an alert rule in the order
+// scanAlertRule reads them.
+var alertRuleColumns = []string{
+	"id",
+	"team_id",
+	"app_id",
+	"name",
+	"metric",
+	"aggregation",
+	"target",
+	"app_versions",
+	"operator",
+	"threshold",
+	"comparison",
+	"baseline_offset_minutes",
+	"min_sample_count",
+	"window_minutes",
+	"interval_minutes",
+	"cooldown_minutes",
+	"channels",
+	"is_active",
+	"last_evaluated_at",
+	"created_at",
+	"updated_at",
+}
+
+func scanAlertRule(row pgx.Row) (rule alertrule.Rule, err error) {
+	err = row.Scan(
+		&rule.ID,
+		&rule.TeamID,
+		&rule.AppID,
+		&rule.Name,
+		&rule.Metric,
+		&rule.Aggregation,
+		&rule.Target,
+		&rule.AppVersions,
+		&rule.Operator,
+		&rule.Threshold,
+		&rule.Comparison,
+		&rule.BaselineOffsetMinutes,
+		&rule.MinSampleCount,
+		&rule.WindowMinutes,
+		&rule.IntervalMinutes,
+		&rule.CooldownMinutes,
+		&rule.Channels,
+		&rule.IsActive,
+		&rule.LastEvaluatedAt,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	return
+}
+
+func getAlertRulesByAppID(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) ([]alertrule.Rule, error) {
+	stmt := sqlf.PostgreSQL.
+		From("measure.alert_rules").
+		Where("app_id = ?", appID).
+		OrderBy("created_at ASC")
+	for _, column := range alertRuleColumns {
+		stmt.Select(column)
+	}
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []alertrule.Rule{}
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func getAlertRuleByID(ctx context.Context, pg *pgxpool.Pool, appID, ruleID uuid.UUID) (alertrule.Rule, error) {
+	stmt := sqlf.PostgreSQL.
+		From("measure.alert_rules").
+		Where("app_id = ?", appID).
+		Where("id = ?", ruleID)
+	for _, column := range alertRuleColumns {
+		stmt.Select(column)
+	}
+	defer stmt.Close()
+
+	return scanAlertRule(pg.QueryRow(ctx, stmt.String(), stmt.Args()...))
+}
+
+func countAlertRules(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (count int, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("measure.alert_rules").
+		Select("count(*)").
+		Where("app_id = ?", appID)
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count)
+	return
+}
+
+func insertAlertRule(ctx context.Context, pg *pgxpool.Pool, rule alertrule.Rule, userID string) error {
+	stmt := sqlf.PostgreSQL.
+		InsertInto("measure.alert_rules").
+		Set("id", rule.ID).
+		Set("team_id", rule.TeamID).
+		Set("app_id", rule.AppID).
+		Set("name", rule.Name).
+		Set("metric", rule.Metric).
+		Set("aggregation", rule.Aggregation).
+		Set("target", rule.Target).
+		Set("app_versions", rule.AppVersions).
+		Set("operator", rule.Operator).
+		Set("threshold", rule.Threshold).
+		Set("comparison", rule.Comparison).
+		Set("baseline_offset_minutes", rule.BaselineOffsetMinutes).
+		Set("min_sample_count", rule.MinSampleCount).
+		Set("window_minutes", rule.WindowMinutes).
+		Set("interval_minutes", rule.IntervalMinutes).
+		Set("cooldown_minutes", rule.CooldownMinutes).
+		Set("channels", rule.Channels).
+		Set("is_active", rule.IsActive).
+		Set("created_by", userID).
+		Set("created_at", rule.CreatedAt).
+		Set("updated_at", rule.UpdatedAt)
+	defer stmt.Close()
+
+	_, err := pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	return err
+}
+
+func updateAlertRule(ctx context.Context, pg *pgxpool.Pool, rule alertrule.Rule) error {
+	stmt := sqlf.PostgreSQL.
+		Update("measure.alert_rules").
+		Set("name", rule.Name).
+		Set("metric", rule.Metric).
+		Set("aggregation", rule.Aggregation).
+		Set("target", rule.Target).
+		Set("app_versions", rule.AppVersions).
+		Set("operator", rule.Operator).
+		Set("threshold", rule.Threshold).
+		Set("comparison", rule.Comparison).
+		Set("baseline_offset_minutes", rule.BaselineOffsetMinutes).
+		Set("min_sample_count", rule.MinSampleCount).
+		Set("window_minutes", rule.WindowMinutes).
+		Set("interval_minutes", rule.IntervalMinutes).
+		Set("cooldown_minutes", rule.CooldownMinutes).
+		Set("channels", rule.Channels).
+		Set("is_active", rule.IsActive).
+		Set("updated_at", rule.UpdatedAt).
+		Where("app_id = ?", rule.AppID).
+		Where("id = ?", rule.ID)
+	defer stmt.Close()
+
+	_, err := pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	return err
+}
+
+// alertRuleRequest is the payload to create or update an alert rule.
+// Fields left out of an update keep their current value.
+type alertRuleRequest struct {
+	Name                  *string                `json:"name"`
+	Metric                *alertrule.Metric      `json:"metric"`
+	Aggregation           *alertrule.Aggregation `json:"aggregation"`
+	Target                *string                `json:"target"`
+	AppVersions           []string               `json:"app_versions"`
+	Operator              *alertrule.Operator    `json:"operator"`
+	Threshold             *float64               `json:"threshold"`
+	Comparison            *alertrule.Comparison  `json:"comparison"`
+	BaselineOffsetMinutes *int                   `json:"baseline_offset_minutes"`
+	MinSampleCount        *int                   `json:"min_sample_count"`
+	WindowMinutes         *int                   `json:"window_minutes"`
+	IntervalMinutes       *int                   `json:"interval_minutes"`
+	CooldownMinutes       *int                   `json:"cooldown_minutes"`
+	Channels              []string               `json:"channels"`
+	IsActive              *bool                  `json:"is_active"`
+}
+
+// apply sets every field present in the request on the rule.
+func (req alertRuleRequest) apply(rule *alertrule.Rule) {
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Metric != nil {
+		rule.Metric = *req.Metric
+	}
+	if req.Aggregation != nil {
+		rule.Aggregation = *req.Aggregation
+	}
+	if req.Target != nil {
+		rule.Target = *req.Target
+	}
+	if req.AppVersions != nil {
+		rule.AppVersions = req.AppVersions
+	}
+	if req.Operator != nil {
+		rule.Operator = *req.Operator
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Comparison != nil {
+		rule.Comparison = *req.Comparison
+	}
+	if req.BaselineOffsetMinutes != nil {
+		rule.BaselineOffsetMinutes = *req.BaselineOffsetMinutes
+	}
+	if req.MinSampleCount != nil {
+		rule.MinSampleCount = *req.MinSampleCount
+	}
+	if req.WindowMinutes != nil {
+		rule.WindowMinutes = *req.WindowMinutes
+	}
+	if req.IntervalMinutes != nil {
+		rule.IntervalMinutes = *req.IntervalMinutes
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if req.Channels != nil {
+		rule.Channels = req.Channels
+	}
+	if req.IsActive != nil {
+		rule.IsActive = *req.IsActive
+	}
+}
+
+func (h Handlers) GetAlertRules(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAlertRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rules, err := getAlertRulesByAppID(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying alert rules: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": rules})
+}
+
+func (h Handlers) GetAlertRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `alert rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAlertRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := getAlertRuleByID(ctx, deps.PgPool, appID, ruleID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			msg := fmt.Sprintf("alert rule [%s] not found", ruleID)
+			c.JSON(http.StatusNotFound, gin.H{"error": msg})
+			return
+		}
+		msg := fmt.Sprintf("error occurred while querying alert rule: %s", ruleID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// CreateAlertRule creates an alert rule for the app. Rules are active
+// from creation unless the payload says otherwise.
+func (h Handlers) CreateAlertRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAlertAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to manage alert rules for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if req.Threshold == nil || req.WindowMinutes == nil || req.IntervalMinutes == nil || req.CooldownMinutes == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "threshold, window_minutes, interval_minutes and cooldown_minutes are required"})
+		return
+	}
+
+	now := time.Now().UTC()
+	rule := alertrule.Rule{
+		ID:        uuid.New(),
+		TeamID:    *team.ID,
+		AppID:     appID,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	req.apply(&rule)
+	rule.Normalize()
+
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := countAlertRules(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while counting alert rules: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if count >= maxAlertRulesPerApp {
+		msg := fmt.Sprintf("an app can have at most %d alert rules", maxAlertRulesPerApp)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := insertAlertRule(ctx, deps.PgPool, rule, userID); err != nil {
+		msg := fmt.Sprintf("error occurred while creating alert rule: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateAlertRule updates the fields present in the payload. The whole
+// rule is validated again after the update.
+func (h Handlers) UpdateAlertRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `alert rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAlertAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to manage alert rules for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := getAlertRuleByID(ctx, deps.PgPool, appID, ruleID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			msg := fmt.Sprintf("alert rule [%s] not found", ruleID)
+			c.JSON(http.StatusNotFound, gin.H{"error": msg})
+			return
+		}
+		msg := fmt.Sprintf("error occurred while querying alert rule: %s", ruleID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	req.apply(&rule)
+	rule.Normalize()
+	rule.UpdatedAt = time.Now().UTC()
+
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := updateAlertRule(ctx, deps.PgPool, rule); err != nil {
+		msg := fmt.Sprintf("error occurred while updating alert rule: %s", ruleID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h Handlers) DeleteAlertRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `alert rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAlertAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to manage alert rules for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		DeleteFrom("measure.alert_rules").
+		Where("app_id = ?", appID).
+		Where("id = ?", ruleID)
+	defer stmt.Close()
+
+	commandTag, err := deps.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while deleting alert rule: %s", ruleID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		msg := fmt.Sprintf("alert rule [%s] not found", ruleID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
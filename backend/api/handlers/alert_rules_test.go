@@ -0,0 +1,167 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"backend/libs/alertrule"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testAlertRulePayload = `{
+	"name": "Slow cold launch",
+	"metric": "cold_launch",
+	"operator": "gt",
+	"threshold": 3000,
+	"app_versions": ["1.2.0"],
+	"window_minutes": 60,
+	"interval_minutes": 15,
+	"cooldown_minutes": 240,
+	"channels": ["email", "slack"]
+}`
+
+func newAlertRuleContext(method, userID string, appID uuid.UUID, ruleID, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/apps/"+appID.String()+"/alertRules", strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	if ruleID != "" {
+		c.Params = append(c.Params, gin.Param{Key: "ruleId", Value: ruleID})
+	}
+	return c, w
+}
+
+func TestCreateAlertRule(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates a normalized rule", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newAlertRuleContext(http.MethodPost, userID, appID, "", testAlertRulePayload)
+		h.CreateAlertRule(c)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201, body: %s", w.Code, w.Body.String())
+		}
+
+		var rule alertrule.Rule
+		if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if rule.Aggregation != alertrule.AggregationP95 {
+			t.Errorf("aggregation = %q, want p95 by default", rule.Aggregation)
+		}
+		if !rule.IsActive {
+			t.Error("is_active = false, want a new rule active")
+		}
+		if rule.TeamID != teamID {
+			t.Errorf("team_id = %v, want the app's team", rule.TeamID)
+		}
+
+		c, w = newAlertRuleContext(http.MethodGet, userID, appID, "", "")
+		h.GetAlertRules(c)
+		var list struct {
+			Results []alertrule.Rule `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if len(list.Results) != 1 || list.Results[0].ID != rule.ID {
+			t.Errorf("results = %v, want the created rule", list.Results)
+		}
+	})
+
+	t.Run("rejects an invalid rule", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		body := strings.Replace(testAlertRulePayload, `"cold_launch"`, `"span_duration"`, 1)
+		c, w := newAlertRuleContext(http.MethodPost, userID, appID, "", body)
+		h.CreateAlertRule(c)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "target is required") {
+			t.Errorf("body = %s, want the validation error", w.Body.String())
+		}
+	})
+
+	t.Run("viewer is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newAlertRuleContext(http.MethodPost, userID, appID, "", testAlertRulePayload)
+		h.CreateAlertRule(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
+
+func TestUpdateAndDeleteAlertRule(t *testing.T) {
+	ctx := context.Background()
+	defer cleanupAll(ctx, t)
+
+	userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+	appID := uuid.New()
+	seedApp(ctx, t, appID, teamID, 30)
+
+	c, w := newAlertRuleContext(http.MethodPost, userID, appID, "", testAlertRulePayload)
+	h.CreateAlertRule(c)
+	var created alertrule.Rule
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	// a partial update keeps the other fields
+	c, w = newAlertRuleContext(http.MethodPatch, userID, appID, created.ID.String(), `{"threshold": 2500, "is_active": false}`)
+	h.UpdateAlertRule(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update status = %d, want 200, body: %s", w.Code, w.Body.String())
+	}
+
+	c, w = newAlertRuleContext(http.MethodGet, userID, appID, created.ID.String(), "")
+	h.GetAlertRule(c)
+	var updated alertrule.Rule
+	if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if updated.Threshold != 2500 || updated.IsActive {
+		t.Errorf("threshold, is_active = %v, %v, want 2500, false", updated.Threshold, updated.IsActive)
+	}
+	if updated.Name != created.Name || len(updated.Channels) != 2 {
+		t.Errorf("update changed fields left out of the payload: %+v", updated)
+	}
+
+	// an update is validated as a whole
+	c, w = newAlertRuleContext(http.MethodPatch, userID, appID, created.ID.String(), `{"channels": []}`)
+	h.UpdateAlertRule(c)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("update with no channels status = %d, want 400", w.Code)
+	}
+
+	c, w = newAlertRuleContext(http.MethodDelete, userID, appID, created.ID.String(), "")
+	h.DeleteAlertRule(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d, want 200", w.Code)
+	}
+
+	c, w = newAlertRuleContext(http.MethodGet, userID, appID, created.ID.String(), "")
+	h.GetAlertRule(c)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("get after delete status = %d, want 404", w.Code)
+	}
+}
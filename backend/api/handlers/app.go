@@ -16,13 +16,17 @@ import (
 	"backend/libs/chquery"
 	"backend/libs/config"
 	"backend/libs/event"
+	"backend/libs/exprfilter"
 	"backend/libs/filter"
+	"backend/libs/group"
+	"backend/libs/heapdump"
 	"backend/libs/journey"
 	"backend/libs/logcomment"
 	"backend/libs/measure"
 	"backend/libs/metrics"
 	"backend/libs/network"
 	"backend/libs/opsys"
+	"backend/libs/perfetto"
 	"backend/libs/timeline"
 	"backend/libs/udattr"
 
@@ -830,6 +834,11 @@ func (h Handlers) GetErrorOverview(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.ErrorGroupsEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
@@ -903,6 +912,24 @@ func (h Handlers) GetErrorOverview(c *gin.Context) {
 
 	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "errors_list"))
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group merges for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if err := group.ResolveStatusFilter(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group states for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	errGroups, next, previous, err := app.GetErrorGroupsWithFilter(ctx, deps.RchPool, &af)
 	if err != nil {
 		msg := "failed to get app's error groups with filter"
@@ -913,6 +940,24 @@ func (h Handlers) GetErrorOverview(c *gin.Context) {
 		return
 	}
 
+	if err := group.PopulateReleases(ctx, deps.PgPool, *app.ID, errGroups); err != nil {
+		msg := "failed to get release state of app's error groups"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if err := group.PopulateStates(ctx, deps.PgPool, *app.ID, errGroups); err != nil {
+		msg := "failed to get triage state of app's error groups"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	meta := gin.H{
 		"next":     next,
 		"previous": previous,
@@ -1041,6 +1086,24 @@ func (h Handlers) GetErrorOverviewPlotInstances(c *gin.Context) {
 
 	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "plots_instances"))
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group merges for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if err := group.ResolveStatusFilter(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group states for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	errorInstances, err := app.GetErrorPlotInstances(ctx, deps.RchPool, &af)
 	if err != nil {
 		msg := `failed to query error instances`
@@ -1134,6 +1197,11 @@ func (h Handlers) GetErrorDetailErrors(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.ErrorsEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
@@ -1197,6 +1265,15 @@ func (h Handlers) GetErrorDetailErrors(c *gin.Context) {
 
 	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "detail-stacktrace"))
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group merges for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	errorEvents, next, previous, err := app.GetErrorsWithFilter(ctx, deps.RchPool, errorGroupId, &af)
 	if err != nil {
 		msg := `failed to get error group's events`
@@ -1225,8 +1302,31 @@ func (h Handlers) GetErrorDetailErrors(c *gin.Context) {
 		}
 	}
 
+	var eventIDs []uuid.UUID
+	for i := range errorEvents {
+		switch ev := errorEvents[i].(type) {
+		case *event.EventException:
+			eventIDs = append(eventIDs, ev.ID)
+		case *event.EventANR:
+			eventIDs = append(eventIDs, ev.ID)
+		}
+	}
+
+	heapDumps, err := heapdump.GetEventDumps(ctx, deps.RchPool, app.TeamId, *app.ID, eventIDs)
+	if err != nil {
+		msg := `failed to get heap dumps of error group's events`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if heapDumps == nil {
+		heapDumps = []heapdump.Dump{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"results": errorEvents,
+		"results":    errorEvents,
+		"heap_dumps": heapDumps,
 		"meta": gin.H{
 			"next":     next,
 			"previous": previous,
@@ -1349,6 +1449,15 @@ func (h Handlers) GetErrorDetailPlotInstances(c *gin.Context) {
 
 	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "detail_plots_instances"))
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group merges for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	errorInstances, err := app.GetErrorGroupPlotInstances(ctx, deps.RchPool, errorGroupId, &af)
 	if err != nil {
 		msg := `failed to query data for error instances plot`
@@ -1502,6 +1611,15 @@ func (h Handlers) GetErrorDetailAttributeDistribution(c *gin.Context) {
 
 	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "plots_distribution"))
 
+	if err := group.ResolveMerges(ctx, deps.PgPool, &af); err != nil {
+		msg := "failed to get error group merges for filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	distribution, err := app.GetErrorGroupAttributesDistribution(ctx, deps.RchPool, errorGroupId, &af)
 	if err != nil {
 		msg := `failed to query data for error distribution plot`
@@ -1671,6 +1789,11 @@ func (h Handlers) GetSessionsOverview(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.SessionsEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
@@ -2307,6 +2430,34 @@ func (h Handlers) GetSession(c *gin.Context) {
 		return
 	}
 
+	heapDumps, err := heapdump.GetSessionDumps(ctx, deps.RchPool, app.TeamId, appId, sessionId)
+	if err != nil {
+		msg := `failed to fetch heap dumps for session`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if heapDumps == nil {
+		heapDumps = []heapdump.Dump{}
+	}
+
+	traceSummaries, err := perfetto.GetSessionSummaries(ctx, deps.RchPool, app.TeamId, appId, sessionId)
+	if err != nil {
+		msg := `failed to fetch perfetto trace summaries for session`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if traceSummaries == nil {
+		traceSummaries = []perfetto.TraceSummary{}
+	}
+
 	// no event rows & no traces means the session
 	// does not exist for this app
 	if session.Attribute == nil && len(sessionTraces) == 0 {
@@ -2343,6 +2494,8 @@ func (h Handlers) GetSession(c *gin.Context) {
 		"memory_usage_absolute": memoryUsageAbsolutes,
 		"threads":               threads,
 		"traces":                sessionTraces,
+		"heap_dumps":            heapDumps,
+		"trace_summaries":       traceSummaries,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -2827,6 +2980,11 @@ func (h Handlers) GetSpansForSpanName(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.SpansEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
@@ -3242,6 +3400,11 @@ func (h Handlers) GetBugReportsOverview(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.BugReportsEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
@@ -3747,6 +3910,11 @@ func (h Handlers) GetAlertsOverview(c *gin.Context) {
 		return
 	}
 
+	if err := af.BuildExprFilter(exprfilter.AlertsEntity); err != nil {
+		respondFilterError(c, err)
+		return
+	}
+
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
 		if err := af.ValidateVersions(); err != nil {
 			fmt.Println(msg, err)
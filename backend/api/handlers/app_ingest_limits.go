@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"backend/libs/measure"
+	"backend/libs/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetAppIngestLimits fetches the app's overrides of the ingest
+// rate limits. A null limit is the ingest service's default.
+func (h Handlers) GetAppIngestLimits(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(c, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	overrides, err := ratelimit.GetOverrides(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying app ingest limits: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
+
+// UpdateAppIngestLimits replaces the app's overrides of the ingest
+// rate limits. A null limit falls back to the ingest service's
+// default and 0 lifts the limit. Ingest picks up changes within
+// a minute.
+func (h Handlers) UpdateAppIngestLimits(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(c, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var overrides ratelimit.Overrides
+	if err := c.ShouldBindJSON(&overrides); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := overrides.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := ratelimit.SaveOverrides(ctx, deps.PgPool, appID, overrides); err != nil {
+		msg := fmt.Sprintf("error occurred while updating app ingest limits: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, overrides)
+}
@@ -0,0 +1,118 @@
+//go:build integration
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestAppIngestLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unset limits are null", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newTestGinContext("GET", "/apps/"+appID.String()+"/ingestLimits", nil)
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+
+		h.GetAppIngestLimits(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		for _, k := range []string{"events_per_second", "bytes_per_minute", "installation_events_per_second"} {
+			if v, ok := got[k]; !ok || v != nil {
+				t.Errorf("%s = %v, want null", k, v)
+			}
+		}
+	})
+
+	t.Run("stored limits are returned", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		body := []byte(`{"events_per_second": 0, "bytes_per_minute": 1048576, "installation_events_per_second": null}`)
+		c, w := newTestGinContext("PUT", "/apps/"+appID.String()+"/ingestLimits", bytes.NewReader(body))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+
+		h.UpdateAppIngestLimits(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		c, w = newTestGinContext("GET", "/apps/"+appID.String()+"/ingestLimits", nil)
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+
+		h.GetAppIngestLimits(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got["events_per_second"] != float64(0) {
+			t.Errorf("events_per_second = %v, want 0", got["events_per_second"])
+		}
+		if got["bytes_per_minute"] != float64(1048576) {
+			t.Errorf("bytes_per_minute = %v, want 1048576", got["bytes_per_minute"])
+		}
+		if got["installation_events_per_second"] != nil {
+			t.Errorf("installation_events_per_second = %v, want null", got["installation_events_per_second"])
+		}
+	})
+
+	t.Run("negative limits are rejected", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		body := []byte(`{"events_per_second": -1}`)
+		c, w := newTestGinContext("PUT", "/apps/"+appID.String()+"/ingestLimits", bytes.NewReader(body))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+
+		h.UpdateAppIngestLimits(c)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusBadRequest, w.Body.String())
+		}
+	})
+
+	t.Run("viewers can't change limits", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		body := []byte(`{"events_per_second": 10}`)
+		c, w := newTestGinContext("PUT", "/apps/"+appID.String()+"/ingestLimits", bytes.NewReader(body))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+
+		h.UpdateAppIngestLimits(c)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusForbidden, w.Body.String())
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"net/http"
@@ -16,13 +17,18 @@ import (
 )
 
 type AppThresholdPrefs struct {
-	AppID                       uuid.UUID `json:"app_id"`
-	ErrorGoodThreshold          float64   `json:"error_good_threshold"`
-	ErrorCautionThreshold       float64   `json:"error_caution_threshold"`
-	ErrorSpikeMinCountThreshold int       `json:"error_spike_min_count_threshold"`
-	ErrorSpikeMinRateThreshold  float64   `json:"error_spike_min_rate_threshold"`
-	CreatedAt                   time.Time `json:"created_at"`
-	UpdatedAt                   time.Time `json:"updated_at"`
+	AppID                               uuid.UUID `json:"app_id"`
+	ErrorGoodThreshold                  float64   `json:"error_good_threshold"`
+	ErrorCautionThreshold               float64   `json:"error_caution_threshold"`
+	ErrorSpikeMinCountThreshold         int       `json:"error_spike_min_count_threshold"`
+	ErrorSpikeMinRateThreshold          float64   `json:"error_spike_min_rate_threshold"`
+	NetworkAlertsEnabled                bool      `json:"network_alerts_enabled"`
+	NetworkLatencyRegressionThreshold   float64   `json:"network_latency_regression_threshold"`
+	NetworkErrorRateRegressionThreshold float64   `json:"network_error_rate_regression_threshold"`
+	NetworkTrafficDropThreshold         float64   `json:"network_traffic_drop_threshold"`
+	NetworkMinRequestCountThreshold     int       `json:"network_min_request_count_threshold"`
+	CreatedAt                           time.Time `json:"created_at"`
+	UpdatedAt                           time.Time `json:"updated_at"`
 }
 
 type AppThresholdPrefsPayload struct {
@@ -30,25 +36,42 @@ type AppThresholdPrefsPayload struct {
 	ErrorCautionThreshold       float64 `json:"error_caution_threshold"`
 	ErrorSpikeMinCountThreshold int     `json:"error_spike_min_count_threshold"`
 	ErrorSpikeMinRateThreshold  float64 `json:"error_spike_min_rate_threshold"`
+	// Network alert prefs are optional, existing values
+	// are kept for the ones left out.
+	NetworkAlertsEnabled                *bool    `json:"network_alerts_enabled,omitempty"`
+	NetworkLatencyRegressionThreshold   *float64 `json:"network_latency_regression_threshold,omitempty"`
+	NetworkErrorRateRegressionThreshold *float64 `json:"network_error_rate_regression_threshold,omitempty"`
+	NetworkTrafficDropThreshold         *float64 `json:"network_traffic_drop_threshold,omitempty"`
+	NetworkMinRequestCountThreshold     *int     `json:"network_min_request_count_threshold,omitempty"`
 }
 
 type appThresholdPrefsRequest struct {
-	ErrorGoodThreshold          *float64 `json:"error_good_threshold"`
-	ErrorCautionThreshold       *float64 `json:"error_caution_threshold"`
-	ErrorSpikeMinCountThreshold *int     `json:"error_spike_min_count_threshold"`
-	ErrorSpikeMinRateThreshold  *float64 `json:"error_spike_min_rate_threshold"`
+	ErrorGoodThreshold                  *float64 `json:"error_good_threshold"`
+	ErrorCautionThreshold               *float64 `json:"error_caution_threshold"`
+	ErrorSpikeMinCountThreshold         *int     `json:"error_spike_min_count_threshold"`
+	ErrorSpikeMinRateThreshold          *float64 `json:"error_spike_min_rate_threshold"`
+	NetworkAlertsEnabled                *bool    `json:"network_alerts_enabled"`
+	NetworkLatencyRegressionThreshold   *float64 `json:"network_latency_regression_threshold"`
+	NetworkErrorRateRegressionThreshold *float64 `json:"network_error_rate_regression_threshold"`
+	NetworkTrafficDropThreshold         *float64 `json:"network_traffic_drop_threshold"`
+	NetworkMinRequestCountThreshold     *int     `json:"network_min_request_count_threshold"`
 }
 
 func defaultAppThresholdPrefs(appID uuid.UUID) AppThresholdPrefs {
 	now := time.Now().UTC()
 	return AppThresholdPrefs{
-		AppID:                       appID,
-		ErrorGoodThreshold:          measure.DefaultErrorGoodThreshold,
-		ErrorCautionThreshold:       measure.DefaultErrorCautionThreshold,
-		ErrorSpikeMinCountThreshold: measure.DefaultErrorSpikeMinCountThreshold,
-		ErrorSpikeMinRateThreshold:  measure.DefaultErrorSpikeMinRateThreshold,
-		CreatedAt:                   now,
-		UpdatedAt:                   now,
+		AppID:                               appID,
+		ErrorGoodThreshold:                  measure.DefaultErrorGoodThreshold,
+		ErrorCautionThreshold:               measure.DefaultErrorCautionThreshold,
+		ErrorSpikeMinCountThreshold:         measure.DefaultErrorSpikeMinCountThreshold,
+		ErrorSpikeMinRateThreshold:          measure.DefaultErrorSpikeMinRateThreshold,
+		NetworkAlertsEnabled:                measure.DefaultNetworkAlertsEnabled,
+		NetworkLatencyRegressionThreshold:   measure.DefaultNetworkLatencyRegressionThreshold,
+		NetworkErrorRateRegressionThreshold: measure.DefaultNetworkErrorRateRegressionThreshold,
+		NetworkTrafficDropThreshold:         measure.DefaultNetworkTrafficDropThreshold,
+		NetworkMinRequestCountThreshold:     measure.DefaultNetworkMinRequestCountThreshold,
+		CreatedAt:                           now,
+		UpdatedAt:                           now,
 	}
 }
 
@@ -71,6 +94,22 @@ func validateAppThresholdPrefs(good, caution float64, minCount int, spikeThresho
 	return nil
 }
 
+func validateNetworkThresholdPrefs(latency, errorRate, trafficDrop float64, minCount int) error {
+	if latency <= 0 || latency > 1000 {
+		return fmt.Errorf("network_latency_regression_threshold must be between 0 (exclusive) and 1000")
+	}
+	if errorRate <= 0 || errorRate > 100 {
+		return fmt.Errorf("network_error_rate_regression_threshold must be between 0 (exclusive) and 100")
+	}
+	if trafficDrop <= 0 || trafficDrop > 100 {
+		return fmt.Errorf("network_traffic_drop_threshold must be between 0 (exclusive) and 100")
+	}
+	if minCount < 1 {
+		return fmt.Errorf("network_min_request_count_threshold must be at least 1")
+	}
+	return nil
+}
+
 func getAppThresholdPrefsByAppID(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (AppThresholdPrefs, error) {
 	prefs := AppThresholdPrefs{}
 	stmt := sqlf.PostgreSQL.
@@ -80,6 +119,11 @@ func getAppThresholdPrefsByAppID(ctx context.Context, pg *pgxpool.Pool, appID uu
 		Select("error_caution_threshold").
 		Select("error_spike_min_count_threshold").
 		Select("error_spike_min_rate_threshold").
+		Select("network_alerts_enabled").
+		Select("network_latency_regression_threshold").
+		Select("network_error_rate_regression_threshold").
+		Select("network_traffic_drop_threshold").
+		Select("network_min_request_count_threshold").
 		Select("created_at").
 		Select("updated_at").
 		Where("app_id = ?", appID)
@@ -91,6 +135,11 @@ func getAppThresholdPrefsByAppID(ctx context.Context, pg *pgxpool.Pool, appID uu
 		&prefs.ErrorCautionThreshold,
 		&prefs.ErrorSpikeMinCountThreshold,
 		&prefs.ErrorSpikeMinRateThreshold,
+		&prefs.NetworkAlertsEnabled,
+		&prefs.NetworkLatencyRegressionThreshold,
+		&prefs.NetworkErrorRateRegressionThreshold,
+		&prefs.NetworkTrafficDropThreshold,
+		&prefs.NetworkMinRequestCountThreshold,
 		&prefs.CreatedAt,
 		&prefs.UpdatedAt,
 	)
@@ -113,6 +162,11 @@ func upsertAppThresholdPrefs(ctx context.Context, pg *pgxpool.Pool, appID uuid.U
 		Set("error_caution_threshold", payload.ErrorCautionThreshold).
 		Set("error_spike_min_count_threshold", payload.ErrorSpikeMinCountThreshold).
 		Set("error_spike_min_rate_threshold", payload.ErrorSpikeMinRateThreshold).
+		Set("network_alerts_enabled", payload.NetworkAlertsEnabled).
+		Set("network_latency_regression_threshold", payload.NetworkLatencyRegressionThreshold).
+		Set("network_error_rate_regression_threshold", payload.NetworkErrorRateRegressionThreshold).
+		Set("network_traffic_drop_threshold", payload.NetworkTrafficDropThreshold).
+		Set("network_min_request_count_threshold", payload.NetworkMinRequestCountThreshold).
 		Set("created_at", time.Now().UTC()).
 		Set("updated_at", time.Now().UTC())
 	defer stmt.Close()
@@ -122,6 +176,11 @@ func upsertAppThresholdPrefs(ctx context.Context, pg *pgxpool.Pool, appID uuid.U
 		error_caution_threshold = EXCLUDED.error_caution_threshold,
 		error_spike_min_count_threshold = EXCLUDED.error_spike_min_count_threshold,
 		error_spike_min_rate_threshold = EXCLUDED.error_spike_min_rate_threshold,
+		network_alerts_enabled = EXCLUDED.network_alerts_enabled,
+		network_latency_regression_threshold = EXCLUDED.network_latency_regression_threshold,
+		network_error_rate_regression_threshold = EXCLUDED.network_error_rate_regression_threshold,
+		network_traffic_drop_threshold = EXCLUDED.network_traffic_drop_threshold,
+		network_min_request_count_threshold = EXCLUDED.network_min_request_count_threshold,
 		updated_at = NOW()`
 
 	_, err := pg.Exec(ctx, query, stmt.Args()...)
@@ -238,6 +297,27 @@ func (h Handlers) UpdateAppThresholdPrefs(c *gin.Context) {
 		return
 	}
 
+	// network alert prefs left out of the request
+	// keep their current values
+	current, err := getAppThresholdPrefsByAppID(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying app threshold prefs: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	payload.NetworkAlertsEnabled = cmp.Or(req.NetworkAlertsEnabled, &current.NetworkAlertsEnabled)
+	payload.NetworkLatencyRegressionThreshold = cmp.Or(req.NetworkLatencyRegressionThreshold, &current.NetworkLatencyRegressionThreshold)
+	payload.NetworkErrorRateRegressionThreshold = cmp.Or(req.NetworkErrorRateRegressionThreshold, &current.NetworkErrorRateRegressionThreshold)
+	payload.NetworkTrafficDropThreshold = cmp.Or(req.NetworkTrafficDropThreshold, &current.NetworkTrafficDropThreshold)
+	payload.NetworkMinRequestCountThreshold = cmp.Or(req.NetworkMinRequestCountThreshold, &current.NetworkMinRequestCountThreshold)
+
+	if err := validateNetworkThresholdPrefs(*payload.NetworkLatencyRegressionThreshold, *payload.NetworkErrorRateRegressionThreshold, *payload.NetworkTrafficDropThreshold, *payload.NetworkMinRequestCountThreshold); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	if err := upsertAppThresholdPrefs(ctx, deps.PgPool, appID, payload); err != nil {
 		msg := fmt.Sprintf("error occurred while updating app threshold prefs: %s", appID)
 		fmt.Println(msg, err)
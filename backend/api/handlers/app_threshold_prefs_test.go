@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"testing"
 
+	"backend/libs/measure"
+
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
@@ -481,4 +483,71 @@ func TestUpdateAppThresholdPrefs(t *testing.T) {
 			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
 		}
 	})
+
+	t.Run("network prefs are optional and kept when left out", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		enabled := false
+		latency := 75.0
+		first := AppThresholdPrefsPayload{ErrorGoodThreshold: 95, ErrorCautionThreshold: 85, ErrorSpikeMinCountThreshold: 100, ErrorSpikeMinRateThreshold: 0.5, NetworkAlertsEnabled: &enabled, NetworkLatencyRegressionThreshold: &latency}
+		b, _ := json.Marshal(first)
+		c, w := newTestGinContext("PATCH", "/apps/"+appID.String()+"/thresholdPrefs", bytes.NewReader(b))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+		h.UpdateAppThresholdPrefs(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+
+		// an update without network prefs keeps them
+		second := AppThresholdPrefsPayload{ErrorGoodThreshold: 96, ErrorCautionThreshold: 86, ErrorSpikeMinCountThreshold: 100, ErrorSpikeMinRateThreshold: 0.5}
+		b2, _ := json.Marshal(second)
+		c2, w2 := newTestGinContext("PATCH", "/apps/"+appID.String()+"/thresholdPrefs", bytes.NewReader(b2))
+		c2.Set("userId", userID)
+		c2.Params = gin.Params{{Key: "id", Value: appID.String()}}
+		h.UpdateAppThresholdPrefs(c2)
+		if w2.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d, body: %s", w2.Code, http.StatusOK, w2.Body.String())
+		}
+
+		prefs, err := getAppThresholdPrefsByAppID(ctx, th.PgPool, appID)
+		if err != nil {
+			t.Fatalf("get threshold prefs: %v", err)
+		}
+		if prefs.NetworkAlertsEnabled || prefs.NetworkLatencyRegressionThreshold != 75 {
+			t.Fatalf("network prefs = (%v,%v), want (false,75)", prefs.NetworkAlertsEnabled, prefs.NetworkLatencyRegressionThreshold)
+		}
+		if prefs.NetworkTrafficDropThreshold != measure.DefaultNetworkTrafficDropThreshold {
+			t.Fatalf("network_traffic_drop_threshold = %v, want the default", prefs.NetworkTrafficDropThreshold)
+		}
+	})
+
+	t.Run("invalid network prefs return bad request", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		drop := 120.0
+		payload := AppThresholdPrefsPayload{ErrorGoodThreshold: 95, ErrorCautionThreshold: 85, ErrorSpikeMinCountThreshold: 100, ErrorSpikeMinRateThreshold: 0.5, NetworkTrafficDropThreshold: &drop}
+		b, _ := json.Marshal(payload)
+		c, w := newTestGinContext("PATCH", "/apps/"+appID.String()+"/thresholdPrefs", bytes.NewReader(b))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+		h.UpdateAppThresholdPrefs(c)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+
+		var got map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if want := "network_traffic_drop_threshold must be between 0 (exclusive) and 100"; got["error"] != want {
+			t.Fatalf("error = %v, want %q", got["error"], want)
+		}
+	})
 }
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/cohort"
+	"backend/libs/filter"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type retentionCohortRequest struct {
+	GroupBy string    `form:"group_by"`
+	From    time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To      time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+type retentionStickinessRequest struct {
+	From time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To   time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+// GetAppRetentionCohorts groups the app's installations into
+// cohorts by the day, week or app version first seen on &
+// reports their return rates & churn after crashing in the
+// first session.
+func (h Handlers) GetAppRetentionCohorts(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	req := retentionCohortRequest{
+		GroupBy: cohort.GroupDay,
+	}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse retention cohort request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if req.From.IsZero() && req.To.IsZero() {
+		req.To = time.Now().UTC()
+		req.From = req.To.Add(-filter.DefaultDuration)
+	}
+
+	q := cohort.Query{
+		From:    req.From,
+		To:      req.To,
+		GroupBy: req.GroupBy,
+	}
+
+	if err := q.Validate(); err != nil {
+		msg := `retention cohort request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.Retention).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "cohorts"))
+
+	cohorts, err := cohort.GetCohorts(ctx, deps.RchPool, app.TeamId, id, q)
+	if err != nil {
+		msg := `failed to fetch retention cohorts`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if cohorts == nil {
+		cohorts = []cohort.Cohort{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": cohorts,
+	})
+}
+
+// GetAppRetentionStickiness computes the daily & monthly
+// active installations of the app, for each day in the
+// time range.
+func (h Handlers) GetAppRetentionStickiness(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req retentionStickinessRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse retention stickiness request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if req.From.IsZero() && req.To.IsZero() {
+		req.To = time.Now().UTC()
+		req.From = req.To.Add(-filter.DefaultDuration)
+	}
+
+	q := cohort.Query{
+		From:    req.From,
+		To:      req.To,
+		GroupBy: cohort.GroupDay,
+	}
+
+	if err := q.Validate(); err != nil {
+		msg := `retention stickiness request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.Retention).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "stickiness"))
+
+	stickiness, err := cohort.GetStickiness(ctx, deps.RchPool, app.TeamId, id, q.From, q.To)
+	if err != nil {
+		msg := `failed to fetch retention stickiness`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if stickiness == nil {
+		stickiness = []cohort.Stickiness{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": stickiness,
+	})
+}
@@ -0,0 +1,129 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"backend/testinfra"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newRetentionContext(callerID string, appID uuid.UUID, path string, query url.Values) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext("GET", "/apps/"+appID.String()+path+"?"+query.Encode(), nil)
+	c.Set("userId", callerID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetAppRetentionCohorts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("groups installations first seen on a day", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		// every seeded row is a new installation
+		ts := time.Now().UTC().Add(-time.Hour)
+		seedEventRows(ctx, t, teamID.String(), appID.String(), 3, testinfra.EventRow{Timestamp: ts})
+
+		c, w := newRetentionContext(ownerID, appID, "/retention/cohorts", url.Values{"group_by": {"day"}})
+		h.GetAppRetentionCohorts(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Results []struct {
+				Start         string `json:"start"`
+				Installations uint64 `json:"installations"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if len(body.Results) != 1 {
+			t.Fatalf("len(results) = %d, want 1", len(body.Results))
+		}
+		if body.Results[0].Start != ts.Format("2006-01-02") {
+			t.Errorf("start = %q, want %q", body.Results[0].Start, ts.Format("2006-01-02"))
+		}
+		if body.Results[0].Installations != 3 {
+			t.Errorf("installations = %d, want 3", body.Results[0].Installations)
+		}
+	})
+
+	t.Run("unknown group by returns 400", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		c, w := newRetentionContext(ownerID, appID, "/retention/cohorts", url.Values{"group_by": {"month"}})
+		h.GetAppRetentionCohorts(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "details", "month")
+	})
+}
+
+func TestGetAppRetentionStickiness(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("counts daily & monthly active installations", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		ts := time.Now().UTC().Add(-time.Hour)
+		seedEventRows(ctx, t, teamID.String(), appID.String(), 2, testinfra.EventRow{Timestamp: ts})
+
+		c, w := newRetentionContext(ownerID, appID, "/retention/cohorts/stickiness", url.Values{})
+		h.GetAppRetentionStickiness(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Results []struct {
+				Day        string  `json:"day"`
+				DAU        uint64  `json:"dau"`
+				MAU        uint64  `json:"mau"`
+				Stickiness float64 `json:"stickiness"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+
+		day := ts.Format("2006-01-02")
+		for _, s := range body.Results {
+			if s.Day != day {
+				continue
+			}
+			if s.DAU != 2 || s.MAU != 2 || s.Stickiness != 100 {
+				t.Errorf("stickiness = %+v, want 2 dau, 2 mau & 100%%", s)
+			}
+			return
+		}
+		t.Errorf("results = %+v, want a result for %s", body.Results, day)
+	})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/enduser"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type endUserProfileRequest struct {
+	UserID         string    `form:"user_id"`
+	InstallationID string    `form:"installation_id"`
+	From           time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To             time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+// lookup validates the request and builds
+// the end user lookup.
+func (r *endUserProfileRequest) lookup() (lookup enduser.Lookup, err error) {
+	lookup.UserID = r.UserID
+
+	if r.InstallationID != "" {
+		if lookup.InstallationID, err = uuid.Parse(r.InstallationID); err != nil {
+			return lookup, errors.New("`installation_id` must be a valid UUID")
+		}
+	}
+
+	if err = lookup.Validate(); err != nil {
+		return
+	}
+
+	if r.To.IsZero() {
+		r.To = time.Now().UTC()
+	}
+
+	if r.From.IsZero() {
+		r.From = r.To.Add(-enduser.DefaultLookback)
+	}
+
+	err = enduser.ValidateRange(r.From, r.To)
+
+	return
+}
+
+// GetEndUserProfile looks up one end user of the app by user id
+// or installation id, with their devices, app versions, sessions,
+// crashes, ANRs and bug reports.
+func (h Handlers) GetEndUserProfile(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req endUserProfileRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse end user request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	lookup, err := req.lookup()
+	if err != nil {
+		msg := `end user request validation failed`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.EndUsers).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "profile"))
+
+	profile, err := enduser.GetProfile(ctx, deps.RchPool, app.TeamId, id, lookup, req.From, req.To)
+	if err != nil {
+		msg := fmt.Sprintf(`failed to look up end user with %s`, lookup)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !profile.Found() {
+		msg := fmt.Sprintf(`no activity found for end user with %s`, lookup)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
@@ -0,0 +1,115 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/libs/enduser"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newEndUserProfileContext(userID string, appID uuid.UUID, query string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(http.MethodGet, "/apps/"+appID.String()+"/endUsers?"+query, nil)
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetEndUserProfile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("looks up an end user's sessions", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		now := time.Now().UTC()
+		seedSessionStartEvent(ctx, t, teamID.String(), appID.String(), uuid.New().String(), "u-1", now.Add(-2*time.Hour))
+		seedSessionStartEvent(ctx, t, teamID.String(), appID.String(), uuid.New().String(), "u-1", now.Add(-time.Hour))
+		seedSessionStartEvent(ctx, t, teamID.String(), appID.String(), uuid.New().String(), "u-2", now.Add(-time.Hour))
+
+		c, w := newEndUserProfileContext(userID, appID, "user_id=u-1")
+		h.GetEndUserProfile(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			UserIDs      []string `json:"user_ids"`
+			SessionCount uint64   `json:"session_count"`
+			Sessions     []any    `json:"sessions"`
+			Versions     []struct {
+				Version string `json:"version"`
+			} `json:"app_versions"`
+			Errors []any `json:"errors"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if len(body.UserIDs) != 1 || body.UserIDs[0] != "u-1" {
+			t.Errorf("user ids = %v, want [u-1]", body.UserIDs)
+		}
+		if body.SessionCount != 2 || len(body.Sessions) != 2 {
+			t.Errorf("sessions = %d listed %d, want 2", body.SessionCount, len(body.Sessions))
+		}
+		if len(body.Versions) != 1 || body.Versions[0].Version != "v1" {
+			t.Errorf("versions = %+v, want v1", body.Versions)
+		}
+		if body.Errors == nil || len(body.Errors) != 0 {
+			t.Errorf("errors = %v, want an empty list", body.Errors)
+		}
+	})
+
+	t.Run("an end user without activity is not found", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newEndUserProfileContext(userID, appID, "installation_id="+uuid.New().String())
+		h.GetEndUserProfile(c)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects a lookup by both ids", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newEndUserProfileContext(userID, appID, "user_id=u-1&installation_id="+uuid.New().String())
+		h.GetEndUserProfile(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects a time range over the max lookback", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		to := time.Now().UTC()
+		from := to.Add(-enduser.MaxLookback - time.Hour)
+		query := "user_id=u-1&from=" + from.Format("2006-01-02T15:04:05.000Z") + "&to=" + to.Format("2006-01-02T15:04:05.000Z")
+
+		c, w := newEndUserProfileContext(userID, appID, query)
+		h.GetEndUserProfile(c)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "details", "at most")
+	})
+}
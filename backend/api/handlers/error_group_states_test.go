@@ -0,0 +1,155 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const testErrorGroupID = "a1b2c3d4e5f60718"
+
+func newErrorGroupStateContext(method, userID string, appID uuid.UUID, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/apps/"+appID.String()+"/errorGroups/"+testErrorGroupID, strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = gin.Params{
+		{Key: "id", Value: appID.String()},
+		{Key: "errorGroupId", Value: testErrorGroupID},
+	}
+	return c, w
+}
+
+func decodeErrorGroupState(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var state map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &state); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return state
+}
+
+func TestGetErrorGroupState(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("untriaged group is open", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newErrorGroupStateContext(http.MethodGet, userID, appID, "")
+		h.GetErrorGroupState(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		if got := decodeErrorGroupState(t, w)["status"]; got != "open" {
+			t.Errorf("status = %v, want open", got)
+		}
+	})
+}
+
+func TestUpdateErrorGroupState(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("resolves in a version", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"status":"resolved","resolved_version":"1.1.0","resolved_version_code":"110"}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		state := decodeErrorGroupState(t, w)
+		if state["status"] != "resolved" || state["resolved_version"] != "1.1.0" {
+			t.Errorf("state = %v, want resolved in 1.1.0", state)
+		}
+		if state["updated_by"] != userID {
+			t.Errorf("updated_by = %v, want %s", state["updated_by"], userID)
+		}
+
+		c, w = newErrorGroupStateContext(http.MethodGet, userID, appID, "")
+		h.GetErrorGroupState(c)
+		if got := decodeErrorGroupState(t, w)["status"]; got != "resolved" {
+			t.Errorf("persisted status = %v, want resolved", got)
+		}
+	})
+
+	t.Run("assigns to a team member and unassigns", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"assignee_id":"`+userID+`"}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		state := decodeErrorGroupState(t, w)
+		if state["assignee_id"] != userID || state["status"] != "open" {
+			t.Errorf("state = %v, want open and assigned to %s", state, userID)
+		}
+
+		c, w = newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"assignee_id":""}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		if got := decodeErrorGroupState(t, w)["assignee_id"]; got != nil {
+			t.Errorf("assignee_id = %v, want null", got)
+		}
+	})
+
+	t.Run("rejects assigning outside the team", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		outsiderID := uuid.New().String()
+		seedUser(ctx, t, outsiderID, "outsider@test.com")
+
+		c, w := newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"assignee_id":"`+outsiderID+`"}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects snoozing without a condition", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"status":"snoozed"}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("viewer is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newErrorGroupStateContext(http.MethodPatch, userID, appID, `{"status":"ignored"}`)
+		h.UpdateErrorGroupState(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
@@ -97,10 +97,18 @@ func (h Handlers) GetFilterKeys(c *gin.Context) {
 
 	keys := entity.Keys
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"keys":       keys,
 		"key_groups": exprfilter.ListKeyGroups(keys),
-	})
+	}
+
+	// User defined attributes are filtered by keys named after them,
+	// which the filter bar writes with this prefix.
+	if entity.MatchKey != nil {
+		response["attr_key_prefix"] = exprfilter.AttrKeyPrefix
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 func (h Handlers) GetFilterValues(c *gin.Context) {
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/group"
+	"backend/libs/measure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fingerprintRuleRequest struct {
+	Type    group.RuleType `json:"type"`
+	Pattern string         `json:"pattern"`
+}
+
+type mergeErrorGroupsRequest struct {
+	TargetID string   `json:"target_id"`
+	IDs      []string `json:"ids"`
+}
+
+// validate validates the merge request.
+func (req mergeErrorGroupsRequest) validate() error {
+	if req.TargetID == "" {
+		return errors.New("`target_id` is required")
+	}
+	if len(req.IDs) == 0 {
+		return errors.New("`ids` must have at least one error group id")
+	}
+	if len(req.IDs) > group.MaxMergeGroups {
+		return fmt.Errorf("`ids` must have at most %d error group ids", group.MaxMergeGroups)
+	}
+	for _, id := range req.IDs {
+		if id == "" {
+			return errors.New("`ids` cannot have empty error group ids")
+		}
+	}
+	return nil
+}
+
+func (h Handlers) GetFingerprintRules(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rules, err := group.GetFingerprintRules(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying fingerprint rules: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": rules})
+}
+
+// CreateFingerprintRule creates a fingerprint rule for the app.
+// The rule applies to errors ingested after its creation.
+func (h Handlers) CreateFingerprintRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeErrorGroupAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to manage fingerprint rules for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var req fingerprintRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rule := group.FingerprintRule{
+		ID:        uuid.New(),
+		TeamID:    *team.ID,
+		AppID:     appID,
+		Type:      req.Type,
+		Pattern:   req.Pattern,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err := rule.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := group.GetFingerprintRules(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying fingerprint rules: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if len(rules) >= group.MaxFingerprintRulesPerApp {
+		msg := fmt.Sprintf("an app can have at most %d fingerprint rules", group.MaxFingerprintRulesPerApp)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := group.InsertFingerprintRule(ctx, deps.PgPool, rule, userUUID); err != nil {
+		msg := fmt.Sprintf("error occurred while creating fingerprint rule: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h Handlers) DeleteFingerprintRule(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleID, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `fingerprint rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeErrorGroupAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to manage fingerprint rules for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	deleted, err := group.DeleteFingerprintRule(ctx, deps.PgPool, appID, ruleID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while deleting fingerprint rule: %s", ruleID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !deleted {
+		msg := fmt.Sprintf("fingerprint rule [%s] not found", ruleID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// MergeErrorGroups merges error groups into a target error
+// group of the same kind. Error group views treat merged
+// groups as part of the target until they're split.
+func (h Handlers) MergeErrorGroups(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeErrorGroupAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to merge error groups for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var req mergeErrorGroupsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	targetID, err := group.MergeGroups(ctx, deps.PgPool, deps.RchPool, *team.ID, appID, req.TargetID, req.IDs, userUUID)
+	if errors.Is(err, group.ErrMergeIntoSelf) || errors.Is(err, group.ErrMergeAcrossKinds) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while merging error groups into: %s", req.TargetID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	merged, err := group.GetMergedIDs(ctx, deps.PgPool, appID, targetID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying error groups merged into: %s", targetID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"target_id": targetID,
+		"ids":       merged,
+	})
+}
+
+// SplitErrorGroup splits a merged error group back
+// out of the error group it was merged into.
+func (h Handlers) SplitErrorGroup(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	errorGroupID := c.Param("errorGroupId")
+	if errorGroupID == "" {
+		msg := `error group id is invalid or missing`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeErrorGroupAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to split error groups for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	split, err := group.SplitGroup(ctx, deps.PgPool, appID, errorGroupID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while splitting error group: %s", errorGroupID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !split {
+		msg := fmt.Sprintf("error group [%s] is not merged into another group", errorGroupID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
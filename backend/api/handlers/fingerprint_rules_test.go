@@ -0,0 +1,193 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newFingerprintRulesContext(method, userID string, appID uuid.UUID, body string, params ...gin.Param) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/apps/"+appID.String()+"/fingerprintRules", strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = append(gin.Params{{Key: "id", Value: appID.String()}}, params...)
+	return c, w
+}
+
+func TestFingerprintRules(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates lists and deletes a rule", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newFingerprintRulesContext(http.MethodPost, userID, appID, `{"type":"ignore_frame","pattern":"^kotlinx\\.coroutines\\."}`)
+		h.CreateFingerprintRule(c)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, want 201, body: %s", w.Code, w.Body.String())
+		}
+
+		var rule struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &rule); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+
+		c, w = newFingerprintRulesContext(http.MethodGet, userID, appID, "")
+		h.GetFingerprintRules(c)
+		var list struct {
+			Results []map[string]any `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if len(list.Results) != 1 || list.Results[0]["type"] != "ignore_frame" {
+			t.Fatalf("results = %v, want the created rule", list.Results)
+		}
+
+		c, w = newFingerprintRulesContext(http.MethodDelete, userID, appID, "", gin.Param{Key: "ruleId", Value: rule.ID})
+		h.DeleteFingerprintRule(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		c, w = newFingerprintRulesContext(http.MethodDelete, userID, appID, "", gin.Param{Key: "ruleId", Value: rule.ID})
+		h.DeleteFingerprintRule(c)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("rejects an invalid pattern", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newFingerprintRulesContext(http.MethodPost, userID, appID, `{"type":"fold_type","pattern":"("}`)
+		h.CreateFingerprintRule(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("viewer cannot create rules", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newFingerprintRulesContext(http.MethodPost, userID, appID, `{"type":"fold_type","pattern":"Error"}`)
+		h.CreateFingerprintRule(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
+
+func TestMergeAndSplitErrorGroups(t *testing.T) {
+	ctx := context.Background()
+
+	merge := func(userID string, appID uuid.UUID, body string) *httptest.ResponseRecorder {
+		c, w := newTestGinContext(http.MethodPost, "/apps/"+appID.String()+"/errorGroups/merge", strings.NewReader(body))
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+		h.MergeErrorGroups(c)
+		return w
+	}
+
+	split := func(userID string, appID uuid.UUID, id string) *httptest.ResponseRecorder {
+		c, w := newTestGinContext(http.MethodDelete, "/apps/"+appID.String()+"/errorGroups/"+id+"/merge", nil)
+		c.Set("userId", userID)
+		c.Params = gin.Params{{Key: "id", Value: appID.String()}, {Key: "errorGroupId", Value: id}}
+		h.SplitErrorGroup(c)
+		return w
+	}
+
+	t.Run("merges flatten into the final target", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		if w := merge(userID, appID, `{"target_id":"b","ids":["a"]}`); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		w := merge(userID, appID, `{"target_id":"c","ids":["b"]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			TargetID string   `json:"target_id"`
+			IDs      []string `json:"ids"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if body.TargetID != "c" || len(body.IDs) != 2 || body.IDs[0] != "a" || body.IDs[1] != "b" {
+			t.Errorf("merge = %+v, want a and b merged into c", body)
+		}
+
+		if w := split(userID, appID, "a"); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		if w := split(userID, appID, "a"); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404 splitting an unmerged group", w.Code)
+		}
+	})
+
+	t.Run("rejects merging into itself", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		if w := merge(userID, appID, `{"target_id":"b","ids":["a"]}`); w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		if w := merge(userID, appID, `{"target_id":"a","ids":["b"]}`); w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400 merging a group into itself", w.Code)
+		}
+	})
+
+	t.Run("rejects merging a crash into an ANR", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		crashID := "00000000000000000000000000000001"
+		anrID := "00000000000000000000000000000002"
+		seedFatalExceptionGroupWithCustomFlag(ctx, t, teamID.String(), appID.String(), crashID, false)
+		th.SeedAnrGroup(ctx, t, teamID.String(), appID.String(), anrID)
+
+		w := merge(userID, appID, `{"target_id":"`+anrID+`","ids":["`+crashID+`"]}`)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "error", "different kinds")
+	})
+
+	t.Run("viewer is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		if w := merge(userID, appID, `{"target_id":"b","ids":["a"]}`); w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newGetAppFunnelContext(callerID string, appID uuid.UUID, steps string) (*gin.Context, *httptest.ResponseRecorder) {
+	query := url.Values{"steps": {steps}}
+	c, w := newTestGinContext("GET", "/apps/"+appID.String()+"/funnel?"+query.Encode(), nil)
+	c.Set("userId", callerID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetAppFunnel(t *testing.T) {
+	ctx := context.Background()
+	steps := `[{"kind":"navigation","name":"home"},{"kind":"navigation","name":"settings"}]`
+
+	t.Run("converts sessions through navigation steps", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		start := time.Now().Add(-time.Hour)
+		converted := uuid.New().String()
+		seedNavigationEventInSession(ctx, t, teamID.String(), appID.String(), converted, "home", start)
+		seedNavigationEventInSession(ctx, t, teamID.String(), appID.String(), converted, "settings", start.Add(10*time.Second))
+		dropped := uuid.New().String()
+		seedNavigationEventInSession(ctx, t, teamID.String(), appID.String(), dropped, "home", start)
+
+		c, w := newGetAppFunnelContext(ownerID, appID, steps)
+		h.GetAppFunnel(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Results []struct {
+				Name     string `json:"name"`
+				Sessions uint64 `json:"sessions"`
+				DropOff  uint64 `json:"drop_off"`
+			} `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if len(body.Results) != 2 {
+			t.Fatalf("len(results) = %d, want 2", len(body.Results))
+		}
+		if body.Results[0].Sessions != 2 || body.Results[0].DropOff != 1 {
+			t.Errorf("results[0] = %+v, want 2 sessions & 1 drop off", body.Results[0])
+		}
+		if body.Results[1].Sessions != 1 {
+			t.Errorf("results[1].sessions = %d, want 1", body.Results[1].Sessions)
+		}
+	})
+
+	t.Run("malformed steps returns 400", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		c, w := newGetAppFunnelContext(ownerID, appID, `{"kind":"navigation"}`)
+		h.GetAppFunnel(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "details", "steps")
+	})
+
+	t.Run("single step returns 400", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		c, w := newGetAppFunnelContext(ownerID, appID, `[{"kind":"navigation","name":"home"}]`)
+		h.GetAppFunnel(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "details", "steps")
+	})
+}
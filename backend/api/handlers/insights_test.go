@@ -0,0 +1,127 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// seedCustomEvent inserts a single custom event with a float64 "total"
+// user defined attribute. SeedEventRows writes neither custom names nor
+// user defined attributes, so this bypasses it with a raw insert.
+func seedCustomEvent(ctx context.Context, t *testing.T, teamID, appID, name string, total float64, ts time.Time) {
+	t.Helper()
+	query := fmt.Sprintf(
+		`INSERT INTO measure.events (id, type, session_id, app_id, team_id, timestamp, user_triggered, `+
+			"`attribute.installation_id`, `attribute.app_version`, `attribute.app_build`, "+
+			"`attribute.app_unique_id`, `attribute.measure_sdk_version`, `custom.name`, `user_defined_attribute`) "+
+			`VALUES ('%s', 'custom', '%s', '%s', '%s', '%s', false, '%s', 'v1', '1', 'com.test', '0.1', '%s', map('total', ('float64', '%v')))`,
+		uuid.New().String(), uuid.New().String(), appID, teamID,
+		ts.UTC().Format("2006-01-02 15:04:05"), uuid.New().String(), name, total)
+	if err := th.ChConn.Exec(ctx, query); err != nil {
+		t.Fatalf("seed custom event: %v", err)
+	}
+}
+
+func newCustomEventInsightsContext(callerID string, appID uuid.UUID, query url.Values) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext("GET", "/apps/"+appID.String()+"/customEvents/plots/insights?"+query.Encode(), nil)
+	c.Set("userId", callerID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetCustomEventInsightsPlot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("sums a numeric attribute of the event", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		ts := time.Now().UTC().Add(-time.Hour)
+		seedCustomEvent(ctx, t, teamID.String(), appID.String(), "OrderPlaced", 9.5, ts)
+		seedCustomEvent(ctx, t, teamID.String(), appID.String(), "OrderPlaced", 20, ts)
+		seedCustomEvent(ctx, t, teamID.String(), appID.String(), "CartViewed", 100, ts)
+
+		c, w := newCustomEventInsightsContext(ownerID, appID, url.Values{
+			"timezone":        {"UTC"},
+			"plot_time_group": {"days"},
+			"event":           {"OrderPlaced"},
+			"measure":         {"sum"},
+			"attribute":       {"total"},
+		})
+		h.GetCustomEventInsightsPlot(c)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var series []struct {
+			ID   string `json:"id"`
+			Data []struct {
+				Datetime string  `json:"datetime"`
+				Value    float64 `json:"value"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &series); err != nil {
+			t.Fatalf("unmarshal response: %v", err)
+		}
+		if len(series) != 1 || len(series[0].Data) != 1 {
+			t.Fatalf("series = %+v, want 1 series of 1 point", series)
+		}
+		if series[0].ID != "OrderPlaced" {
+			t.Errorf("id = %q, want %q", series[0].ID, "OrderPlaced")
+		}
+		if series[0].Data[0].Value != 29.5 {
+			t.Errorf("value = %v, want 29.5", series[0].Data[0].Value)
+		}
+	})
+
+	t.Run("missing timezone returns 400", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		c, w := newCustomEventInsightsContext(ownerID, appID, url.Values{"event": {"OrderPlaced"}})
+		h.GetCustomEventInsightsPlot(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "error", "timezone")
+	})
+
+	t.Run("numeric measure without attribute returns 400", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 90)
+
+		c, w := newCustomEventInsightsContext(ownerID, appID, url.Values{
+			"timezone": {"UTC"},
+			"event":    {"OrderPlaced"},
+			"measure":  {"p95"},
+		})
+		h.GetCustomEventInsightsPlot(c)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+		wantJSONContains(t, w, "details", "attribute")
+	})
+}
@@ -71,7 +71,15 @@ func (h Handlers) GetErrorGroupCommonPath(c *gin.Context) {
 
 	app.TeamId = *team.ID
 
-	data, err := measure.GetIssueGroupCommonPath(ctx, deps.RchPool, *team.ID, id, group.GroupTypeError, groupId)
+	merged, err := group.GetMergedIDs(ctx, deps.PgPool, id, groupId)
+	if err != nil {
+		msg := "failed to get error groups merged into the group"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	data, err := measure.GetIssueGroupCommonPath(ctx, deps.RchPool, *team.ID, id, group.GroupTypeError, groupId, merged...)
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/libs/cache"
+	"backend/libs/chquery"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+	"backend/libs/metricexport"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// scrapeCacheTTL is how long a team's scraped metrics are served
+// from memory. Scrapers usually poll every 15-60s while metrics
+// change much slower, so repeated scrapes skip the queries.
+const scrapeCacheTTL = time.Minute
+
+// scrapeCache caches the text exposition of
+// each team's metrics.
+var scrapeCache = cache.NewLRUCache(1000)
+
+// scrapeCacheEntry is a team's text exposition
+// and when it was rendered.
+type scrapeCacheEntry struct {
+	body []byte
+	at   time.Time
+}
+
+// GetTeamMetricExport fetches the team's metric export
+// configuration. Responds with null when the team has not
+// configured one.
+func (h Handlers) GetTeamMetricExport(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	export, err := metricexport.GetExport(ctx, deps.PgPool, teamId)
+	if err != nil {
+		if errors.Is(err, metricexport.ErrExportNotFound) {
+			c.JSON(http.StatusOK, nil)
+			return
+		}
+		msg := fmt.Sprintf("error occurred while querying team metric export: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// UpdateTeamMetricExport creates or updates the OTLP endpoint
+// the team's metrics are pushed to and whether the export is
+// active. A null endpoint stops pushes while keeping scrapes.
+func (h Handlers) UpdateTeamMetricExport(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		OTLPEndpoint *string           `json:"otlp_endpoint"`
+		OTLPHeaders  map[string]string `json:"otlp_headers"`
+		IsActive     *bool             `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	export := metricexport.Export{
+		TeamID:       teamId,
+		OTLPEndpoint: payload.OTLPEndpoint,
+		OTLPHeaders:  payload.OTLPHeaders,
+		IsActive:     true,
+	}
+
+	if payload.IsActive != nil {
+		export.IsActive = *payload.IsActive
+	}
+
+	if err := export.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := export.Upsert(ctx, deps.PgPool); err != nil {
+		msg := fmt.Sprintf("error occurred while saving team metric export: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	export, err = metricexport.GetExport(ctx, deps.PgPool, teamId)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying team metric export: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, export)
+}
+
+// DeleteTeamMetricExport deletes the team's metric
+// export, stopping both pushes and scrapes.
+func (h Handlers) DeleteTeamMetricExport(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	if err := metricexport.DeleteExport(ctx, deps.PgPool, teamId); err != nil {
+		msg := fmt.Sprintf("error occurred while deleting team metric export: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// RotateTeamScrapeKey creates a new key for scraping the team's
+// metrics and returns it once. The previous key stops working.
+func (h Handlers) RotateTeamScrapeKey(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	key, err := metricexport.NewScrapeKey()
+	if err != nil {
+		msg := "failed to create scrape key"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if err := metricexport.SetScrapeKey(ctx, deps.PgPool, teamId, &key); err != nil {
+		msg := fmt.Sprintf("error occurred while saving team scrape key: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scrape_key": key})
+}
+
+// DeleteTeamScrapeKey removes the team's scrape
+// key, disabling scrapes of its metrics.
+func (h Handlers) DeleteTeamScrapeKey(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	if err := metricexport.SetScrapeKey(ctx, deps.PgPool, teamId, nil); err != nil {
+		msg := fmt.Sprintf("error occurred while deleting team scrape key: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// GetTeamPrometheusMetrics serves the team's metrics in the
+// Prometheus text exposition format. Scrapers authenticate
+// with the team's scrape key as a bearer token.
+func (h Handlers) GetTeamPrometheusMetrics(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || key == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing scrape key"})
+		return
+	}
+
+	export, err := metricexport.GetExport(ctx, deps.PgPool, teamId)
+	if err != nil && !errors.Is(err, metricexport.ErrExportNotFound) {
+		msg := fmt.Sprintf("error occurred while querying team metric export: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	// unknown teams and wrong keys look the same,
+	// so scrapes can't probe which teams exist
+	if !export.CanScrape(key) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid scrape key"})
+		return
+	}
+
+	if v, ok := scrapeCache.Get(teamId.String()); ok {
+		if entry := v.(scrapeCacheEntry); time.Since(entry.at) < scrapeCacheTTL {
+			c.Data(http.StatusOK, metricexport.PrometheusContentType, entry.body)
+			return
+		}
+	}
+
+	apps, err := metricexport.GetTeamApps(ctx, deps.PgPool, teamId)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying apps of team: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.MetricExport).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "scrape"))
+
+	now := time.Now().UTC()
+	samples, err := metricexport.Collect(ctx, deps.RchPool, teamId, apps, now)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while collecting metrics of team: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := metricexport.WritePrometheus(&buf, samples); err != nil {
+		msg := fmt.Sprintf("error occurred while writing metrics of team: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	scrapeCache.Put(teamId.String(), scrapeCacheEntry{body: buf.Bytes(), at: now})
+
+	c.Data(http.StatusOK, metricexport.PrometheusContentType, buf.Bytes())
+}
@@ -0,0 +1,154 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newMetricExportContext(method, userID string, teamID uuid.UUID, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/teams/"+teamID.String()+"/metric-export", strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: teamID.String()}}
+	return c, w
+}
+
+func newScrapeContext(teamID uuid.UUID, key string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(http.MethodGet, "/prometheus/teams/"+teamID.String()+"/metrics", nil)
+	if key != "" {
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+	}
+	c.Params = gin.Params{{Key: "id", Value: teamID.String()}}
+	return c, w
+}
+
+func TestUpdateTeamMetricExport(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("saves the endpoint and hides header values", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newMetricExportContext(http.MethodPut, userID, teamID, `{"otlp_endpoint":"https://otel.example.com/v1/metrics","otlp_headers":{"authorization":"Bearer secret"}}`)
+		h.UpdateTeamMetricExport(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("body = %s, want header values left out", w.Body.String())
+		}
+
+		var got struct {
+			OTLPEndpoint    string   `json:"otlp_endpoint"`
+			OTLPHeaderNames []string `json:"otlp_header_names"`
+			HasScrapeKey    bool     `json:"has_scrape_key"`
+			IsActive        bool     `json:"is_active"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if got.OTLPEndpoint != "https://otel.example.com/v1/metrics" {
+			t.Errorf("otlp_endpoint = %q, want the saved endpoint", got.OTLPEndpoint)
+		}
+		if len(got.OTLPHeaderNames) != 1 || got.OTLPHeaderNames[0] != "Authorization" {
+			t.Errorf("otlp_header_names = %v, want [Authorization]", got.OTLPHeaderNames)
+		}
+		if got.HasScrapeKey {
+			t.Error("has_scrape_key = true, want no scrape key")
+		}
+		if !got.IsActive {
+			t.Error("is_active = false, want a new export active")
+		}
+	})
+
+	t.Run("rejects a non-http endpoint", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newMetricExportContext(http.MethodPut, userID, teamID, `{"otlp_endpoint":"ftp://otel.example.com"}`)
+		h.UpdateTeamMetricExport(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("viewer is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+
+		c, w := newMetricExportContext(http.MethodPut, userID, teamID, `{"otlp_endpoint":"https://otel.example.com/v1/metrics"}`)
+		h.UpdateTeamMetricExport(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
+
+func TestGetTeamPrometheusMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("serves metrics to the current scrape key only", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newMetricExportContext(http.MethodPatch, userID, teamID, "")
+		h.RotateTeamScrapeKey(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("rotate status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var rotated struct {
+			ScrapeKey string `json:"scrape_key"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &rotated); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if !strings.HasPrefix(rotated.ScrapeKey, "msrmx_") {
+			t.Fatalf("scrape_key = %q, want a msrmx_ key", rotated.ScrapeKey)
+		}
+
+		c, w = newScrapeContext(teamID, rotated.ScrapeKey)
+		h.GetTeamPrometheusMetrics(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("scrape status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+			t.Errorf("content type = %q, want text/plain", ct)
+		}
+
+		c, w = newScrapeContext(teamID, rotated.ScrapeKey+"x")
+		h.GetTeamPrometheusMetrics(c)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("wrong key status = %d, want 401", w.Code)
+		}
+
+		c, w = newMetricExportContext(http.MethodDelete, userID, teamID, "")
+		h.DeleteTeamScrapeKey(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("delete key status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		c, w = newScrapeContext(teamID, rotated.ScrapeKey)
+		h.GetTeamPrometheusMetrics(c)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("deleted key status = %d, want 401", w.Code)
+		}
+	})
+
+	t.Run("missing key is unauthorized", func(t *testing.T) {
+		c, w := newScrapeContext(uuid.New(), "")
+		h.GetTeamPrometheusMetrics(c)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("status = %d, want 401", w.Code)
+		}
+	})
+}
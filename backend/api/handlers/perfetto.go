@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/filter"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+	"backend/libs/perfetto"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type perfettoStartupRequest struct {
+	Version     string    `form:"version"`
+	VersionCode string    `form:"version_code"`
+	From        time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To          time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+// GetPerfettoStartupBreakdowns breaks down the startups captured
+// by perfetto traces into phases & main thread work, by app
+// version, latest version first.
+func (h Handlers) GetPerfettoStartupBreakdowns(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req perfettoStartupRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse perfetto startup request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if req.From.IsZero() && req.To.IsZero() {
+		req.To = time.Now().UTC()
+		req.From = req.To.Add(-filter.DefaultDuration)
+	}
+
+	if !req.From.Before(req.To) {
+		msg := `perfetto startup request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": "`from` must be earlier than `to`"})
+		return
+	}
+
+	if (req.Version == "") != (req.VersionCode == "") {
+		msg := `perfetto startup request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": "`version` and `version_code` must be set together"})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.Traces).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "startup"))
+
+	breakdowns, err := perfetto.GetStartupBreakdowns(ctx, deps.RchPool, app.TeamId, id, perfetto.Query{
+		From:        req.From,
+		To:          req.To,
+		Version:     req.Version,
+		VersionCode: req.VersionCode,
+	})
+	if err != nil {
+		msg := `failed to fetch perfetto startup breakdowns`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if breakdowns == nil {
+		breakdowns = []perfetto.StartupBreakdown{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": breakdowns,
+	})
+}
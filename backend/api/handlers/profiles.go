@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/filter"
+	"backend/libs/flame"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+	"backend/libs/release"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/errgroup"
+)
+
+// profileFilters are the filters shared by
+// flamegraph requests.
+type profileFilters struct {
+	Screen     string    `form:"screen"`
+	SpanName   string    `form:"span_name"`
+	ThreadName string    `form:"thread_name"`
+	Reason     string    `form:"reason"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+// validate defaults the time range to the
+// default duration & validates it.
+func (f *profileFilters) validate() error {
+	if f.From.IsZero() && f.To.IsZero() {
+		f.To = time.Now().UTC()
+		f.From = f.To.Add(-filter.DefaultDuration)
+	}
+
+	if !f.From.Before(f.To) {
+		return errors.New("`from` must be earlier than `to`")
+	}
+
+	return nil
+}
+
+// query builds the flamegraph query
+// for the app version.
+func (f profileFilters) query(v release.Version) flame.Query {
+	return flame.Query{
+		From:        f.From,
+		To:          f.To,
+		Version:     v.Name,
+		VersionCode: v.Code,
+		Screen:      f.Screen,
+		SpanName:    f.SpanName,
+		ThreadName:  f.ThreadName,
+		Reason:      f.Reason,
+	}
+}
+
+type profileFlamegraphRequest struct {
+	profileFilters
+	Version     string `form:"version"`
+	VersionCode string `form:"version_code"`
+}
+
+type profileFlamegraphDiffRequest struct {
+	profileFilters
+	BaseVersion       string `form:"base_version"`
+	BaseVersionCode   string `form:"base_version_code"`
+	TargetVersion     string `form:"target_version"`
+	TargetVersionCode string `form:"target_version_code"`
+}
+
+func (r profileFlamegraphDiffRequest) versions() (base, target release.Version, err error) {
+	base = release.Version{Name: r.BaseVersion, Code: r.BaseVersionCode}
+	target = release.Version{Name: r.TargetVersion, Code: r.TargetVersionCode}
+
+	if err = base.Validate(); err != nil {
+		return base, target, fmt.Errorf("base: %w", err)
+	}
+	if err = target.Validate(); err != nil {
+		return base, target, fmt.Errorf("target: %w", err)
+	}
+	if base == target {
+		return base, target, errors.New("base and target versions must differ")
+	}
+	return
+}
+
+// GetProfileFlamegraph merges the stacks of the app's CPU
+// profiles into a flamegraph. Profiles can be narrowed to an
+// app version, a screen, a span name, a thread or the reason
+// they were taken for.
+func (h Handlers) GetProfileFlamegraph(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req profileFlamegraphRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse flamegraph request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	version := release.Version{Name: req.Version, Code: req.VersionCode}
+	if version.Name != "" || version.Code != "" {
+		if err := version.Validate(); err != nil {
+			msg := `flamegraph request validation failed`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+			return
+		}
+	}
+
+	if err := req.validate(); err != nil {
+		msg := `flamegraph request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	lc := logcomment.New(2)
+	settings := clickhouse.Settings{
+		"log_comment": lc.MustPut(logcomment.Root, logcomment.Profiles).String(),
+	}
+	ctx = chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "flamegraph"))
+
+	stacks, profiles, err := flame.GetStacks(ctx, deps.RchPool, app.TeamId, id, req.query(version))
+	if err != nil {
+		msg := `failed to fetch flamegraph`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"profiles":   profiles,
+		"flamegraph": flame.Merge(stacks),
+	})
+}
+
+// GetProfileFlamegraphDiff compares the flamegraph of a
+// target app version against a base app version, by each
+// function's share of time spent.
+func (h Handlers) GetProfileFlamegraphDiff(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req profileFlamegraphDiffRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse flamegraph diff request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	base, target, err := req.versions()
+	if err != nil {
+		msg := `flamegraph diff request validation failed`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := req.validate(); err != nil {
+		msg := `flamegraph diff request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var flameGroup errgroup.Group
+
+	// each go routine isolates log comment &
+	// clickhouse settings for safe concurrency
+
+	fetchFlamegraph := func(root **flame.Node, profiles *uint64, v release.Version, name string) func() error {
+		return func() (err error) {
+			lc := logcomment.New(2)
+			settings := clickhouse.Settings{
+				"log_comment": lc.MustPut(logcomment.Root, logcomment.Profiles).String(),
+			}
+			ctx := chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, name))
+
+			stacks, n, err := flame.GetStacks(ctx, deps.RchPool, app.TeamId, id, req.query(v))
+			if err != nil {
+				return fmt.Errorf("failed to fetch %s flamegraph: %w", name, err)
+			}
+
+			*root = flame.Merge(stacks)
+			*profiles = n
+
+			return nil
+		}
+	}
+
+	var baseRoot, targetRoot *flame.Node
+	var baseProfiles, targetProfiles uint64
+	flameGroup.Go(fetchFlamegraph(&baseRoot, &baseProfiles, base, "base"))
+	flameGroup.Go(fetchFlamegraph(&targetRoot, &targetProfiles, target, "target"))
+
+	if err := flameGroup.Wait(); err != nil {
+		msg := `failed to compare flamegraphs`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_profiles":   baseProfiles,
+		"target_profiles": targetProfiles,
+		"flamegraph":      flame.Diff(baseRoot, targetRoot),
+	})
+}
@@ -0,0 +1,179 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// seedProfileStack inserts a single stack
+// of a profile taken on the app version.
+func seedProfileStack(ctx context.Context, t *testing.T, teamID, appID uuid.UUID, version, versionCode string, stack []string, weight uint64) {
+	t.Helper()
+	frames := make([]string, len(stack))
+	for i, f := range stack {
+		frames[i] = "'" + f + "'"
+	}
+	query := fmt.Sprintf(
+		`INSERT INTO measure.profile_stacks (team_id, app_id, event_id, session_id, timestamp, app_version, reason, format, screen, span_names, thread_name, stack, weight, samples) `+
+			`VALUES ('%s', '%s', '%s', '%s', '%s', ('%s', '%s'), 'anr', 'pprof', 'HomeActivity', [], 'main', [%s], %d, 1)`,
+		teamID, appID, uuid.New(), uuid.New(),
+		time.Now().UTC().Add(-time.Hour).Format("2006-01-02 15:04:05"),
+		version, versionCode, strings.Join(frames, ", "), weight)
+	if err := th.ChConn.Exec(ctx, query); err != nil {
+		t.Fatalf("seed profile stack: %v", err)
+	}
+}
+
+func newProfileFlamegraphContext(userID string, appID uuid.UUID, path, query string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(http.MethodGet, "/apps/"+appID.String()+"/profiles/"+path+"?"+query, nil)
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetProfileFlamegraph(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("merges stacks of the version", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		seedProfileStack(ctx, t, teamID, appID, "1.0.0", "100", []string{"main", "a"}, 30)
+		seedProfileStack(ctx, t, teamID, appID, "1.0.0", "100", []string{"main", "b"}, 10)
+		seedProfileStack(ctx, t, teamID, appID, "1.1.0", "110", []string{"main", "c"}, 50)
+
+		c, w := newProfileFlamegraphContext(userID, appID, "flamegraph", "version=1.0.0&version_code=100&screen=HomeActivity")
+		h.GetProfileFlamegraph(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Profiles   uint64 `json:"profiles"`
+			Flamegraph struct {
+				Total    uint64 `json:"total"`
+				Children []struct {
+					Name     string `json:"name"`
+					Children []struct {
+						Name string `json:"name"`
+					} `json:"children"`
+				} `json:"children"`
+			} `json:"flamegraph"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if body.Profiles != 2 {
+			t.Errorf("profiles = %d, want 2", body.Profiles)
+		}
+		if body.Flamegraph.Total != 40 {
+			t.Errorf("total = %d, want 40", body.Flamegraph.Total)
+		}
+		if len(body.Flamegraph.Children) != 1 || len(body.Flamegraph.Children[0].Children) != 2 {
+			t.Errorf("flamegraph = %+v, want main with a & b", body.Flamegraph)
+		}
+	})
+
+	t.Run("requires both version fields", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newProfileFlamegraphContext(userID, appID, "flamegraph", "version=1.0.0")
+		h.GetProfileFlamegraph(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("non member is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		_, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		outsiderID := uuid.New().String()
+		seedUser(ctx, t, outsiderID, "outsider@test.com")
+
+		c, w := newProfileFlamegraphContext(outsiderID, appID, "flamegraph", "")
+		h.GetProfileFlamegraph(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
+
+func TestGetProfileFlamegraphDiff(t *testing.T) {
+	ctx := context.Background()
+	const query = "base_version=1.0.0&base_version_code=100&target_version=1.1.0&target_version_code=110"
+
+	t.Run("compares two versions", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		seedProfileStack(ctx, t, teamID, appID, "1.0.0", "100", []string{"main", "a"}, 50)
+		seedProfileStack(ctx, t, teamID, appID, "1.0.0", "100", []string{"main", "b"}, 50)
+		seedProfileStack(ctx, t, teamID, appID, "1.1.0", "110", []string{"main", "a"}, 100)
+
+		c, w := newProfileFlamegraphContext(userID, appID, "flamegraph/diff", query)
+		h.GetProfileFlamegraphDiff(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			BaseProfiles   uint64 `json:"base_profiles"`
+			TargetProfiles uint64 `json:"target_profiles"`
+			Flamegraph     struct {
+				Children []struct {
+					Name     string `json:"name"`
+					Children []struct {
+						Name  string  `json:"name"`
+						Delta float64 `json:"delta"`
+					} `json:"children"`
+				} `json:"children"`
+			} `json:"flamegraph"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if body.BaseProfiles != 2 || body.TargetProfiles != 1 {
+			t.Errorf("profiles = %d -> %d, want 2 -> 1", body.BaseProfiles, body.TargetProfiles)
+		}
+		if len(body.Flamegraph.Children) != 1 || len(body.Flamegraph.Children[0].Children) != 2 {
+			t.Fatalf("flamegraph = %+v, want main with a & b", body.Flamegraph)
+		}
+		if a := body.Flamegraph.Children[0].Children[0]; a.Name != "a" || a.Delta != 50 {
+			t.Errorf("a = %+v, want delta of 50", a)
+		}
+	})
+
+	t.Run("rejects comparing a version with itself", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newProfileFlamegraphContext(userID, appID, "flamegraph/diff", "base_version=1.0.0&base_version_code=100&target_version=1.0.0&target_version_code=100")
+		h.GetProfileFlamegraphDiff(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+	"backend/libs/filter"
+	"backend/libs/group"
+	"backend/libs/logcomment"
+	"backend/libs/measure"
+	"backend/libs/opsys"
+	"backend/libs/release"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/sync/errgroup"
+)
+
+// maxNewErrorGroups is the number of new error
+// groups returned by a release comparison.
+const maxNewErrorGroups = 10
+
+type releaseComparisonRequest struct {
+	BaseVersion       string    `form:"base_version"`
+	BaseVersionCode   string    `form:"base_version_code"`
+	TargetVersion     string    `form:"target_version"`
+	TargetVersionCode string    `form:"target_version_code"`
+	From              time.Time `form:"from" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+	To                time.Time `form:"to" time_format:"2006-01-02T15:04:05.000Z" time_utc:"1"`
+}
+
+func (r releaseComparisonRequest) versions() (base, target release.Version, err error) {
+	base = release.Version{Name: r.BaseVersion, Code: r.BaseVersionCode}
+	target = release.Version{Name: r.TargetVersion, Code: r.TargetVersionCode}
+
+	if err = base.Validate(); err != nil {
+		return base, target, fmt.Errorf("base: %w", err)
+	}
+	if err = target.Validate(); err != nil {
+		return base, target, fmt.Errorf("target: %w", err)
+	}
+	if base == target {
+		return base, target, errors.New("base and target versions must differ")
+	}
+	return
+}
+
+// GetReleaseComparison compares the health of a target release
+// against a base release side by side, along with the error groups
+// new in the target release.
+func (h Handlers) GetReleaseComparison(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req releaseComparisonRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		msg := `failed to parse release comparison request`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	base, target, err := req.versions()
+	if err != nil {
+		msg := `release comparison request validation failed`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if req.From.IsZero() && req.To.IsZero() {
+		req.To = time.Now().UTC()
+		req.From = req.To.Add(-filter.DefaultDuration)
+	}
+
+	if !req.From.Before(req.To) {
+		msg := `release comparison request validation failed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": "`from` must be earlier than `to`"})
+		return
+	}
+
+	app := measure.App{
+		ID: &id,
+	}
+
+	if err := app.Populate(ctx, deps.PgPool); err != nil {
+		msg := `failed to fetch app details`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf(`app with id %q does not exist`, app.ID)
+		}
+
+		c.JSON(status, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, c.GetString("userId"), app.TeamId.String(), *measure.ScopeAppRead); err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var releaseGroup errgroup.Group
+
+	// each go routine isolates log comment &
+	// clickhouse settings for safe concurrency
+
+	fetchHealth := func(health *release.Health, v release.Version, name string) func() error {
+		return func() (err error) {
+			lc := logcomment.New(2)
+			settings := clickhouse.Settings{
+				"log_comment":     lc.MustPut(logcomment.Root, logcomment.Releases).String(),
+				"use_query_cache": gin.Mode() == gin.ReleaseMode,
+				"query_cache_ttl": int(config.DefaultQueryCacheTTL.Seconds()),
+			}
+			ctx := chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, name))
+
+			if *health, _, err = release.GetHealth(ctx, deps.RchPool, app.TeamId, id, v, req.From, req.To); err != nil {
+				return fmt.Errorf("failed to fetch %s release health: %w", name, err)
+			}
+
+			if health.Size, err = release.GetBuildSize(ctx, deps.PgPool, id, v); err != nil {
+				return fmt.Errorf("failed to fetch %s release size: %w", name, err)
+			}
+
+			if app.Family() != opsys.Android {
+				health.ClearANR()
+			}
+
+			return nil
+		}
+	}
+
+	var baseHealth, targetHealth release.Health
+	releaseGroup.Go(fetchHealth(&baseHealth, base, "base"))
+	releaseGroup.Go(fetchHealth(&targetHealth, target, "target"))
+
+	var newErrorGroups []group.ErrorGroup
+	releaseGroup.Go(func() (err error) {
+		lc := logcomment.New(2)
+		settings := clickhouse.Settings{
+			"log_comment": lc.MustPut(logcomment.Root, logcomment.Releases).String(),
+		}
+		ctx := chquery.WithSettings(ctx, logcomment.Put(settings, lc, logcomment.Name, "new_error_groups"))
+
+		newErrorGroups, err = release.GetNewErrorGroups(ctx, deps.RchPool, app.TeamId, id, base, target, req.From, req.To, maxNewErrorGroups)
+		if err != nil {
+			err = fmt.Errorf("failed to fetch new error groups: %w", err)
+		}
+		return
+	})
+
+	if err := releaseGroup.Wait(); err != nil {
+		msg := `failed to compare releases`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if newErrorGroups == nil {
+		newErrorGroups = []group.ErrorGroup{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base":             baseHealth,
+		"target":           targetHealth,
+		"diff":             release.Compare(baseHealth, targetHealth),
+		"new_error_groups": newErrorGroups,
+	})
+}
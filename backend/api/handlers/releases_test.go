@@ -0,0 +1,108 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newReleaseComparisonContext(userID string, appID uuid.UUID, query string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(http.MethodGet, "/apps/"+appID.String()+"/releases/compare?"+query, nil)
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: appID.String()}}
+	return c, w
+}
+
+func TestGetReleaseComparison(t *testing.T) {
+	ctx := context.Background()
+	const query = "base_version=1.0.0&base_version_code=100&target_version=1.1.0&target_version_code=110"
+
+	t.Run("compares two releases", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newReleaseComparisonContext(userID, appID, query)
+		h.GetReleaseComparison(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var body struct {
+			Base struct {
+				Version struct {
+					Name string `json:"version"`
+					Code string `json:"version_code"`
+				} `json:"version"`
+				Sessions uint64 `json:"sessions"`
+			} `json:"base"`
+			Diff           map[string]any `json:"diff"`
+			NewErrorGroups []any          `json:"new_error_groups"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if body.Base.Version.Name != "1.0.0" || body.Base.Version.Code != "100" {
+			t.Errorf("base version = %+v, want 1.0.0 (100)", body.Base.Version)
+		}
+		if body.Base.Sessions != 0 {
+			t.Errorf("base sessions = %d, want 0 without data", body.Base.Sessions)
+		}
+		if body.Diff["crash_free_sessions"] != nil {
+			t.Errorf("crash free diff = %v, want null without data", body.Diff["crash_free_sessions"])
+		}
+		if body.NewErrorGroups == nil {
+			t.Error("new_error_groups = null, want an empty list")
+		}
+	})
+
+	t.Run("requires both versions", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newReleaseComparisonContext(userID, appID, "base_version=1.0.0&base_version_code=100")
+		h.GetReleaseComparison(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("rejects comparing a release with itself", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newReleaseComparisonContext(userID, appID, "base_version=1.0.0&base_version_code=100&target_version=1.0.0&target_version_code=100")
+		h.GetReleaseComparison(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("non member is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		_, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		outsiderID := uuid.New().String()
+		seedUser(ctx, t, outsiderID, "outsider@test.com")
+
+		c, w := newReleaseComparisonContext(outsiderID, appID, query)
+		h.GetReleaseComparison(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
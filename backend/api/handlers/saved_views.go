@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"backend/libs/filter"
+	"backend/libs/measure"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type savedViewRequest struct {
+	Name       *string                `json:"name"`
+	Page       *filter.ViewPage       `json:"page"`
+	FilterExpr *string                `json:"filter_expr"`
+	TimeRange  *filter.TimeRange      `json:"time_range"`
+	From       *time.Time             `json:"from"`
+	To         *time.Time             `json:"to"`
+	Visibility *filter.ViewVisibility `json:"visibility"`
+}
+
+// apply applies the fields present in the
+// request to the view.
+func (req savedViewRequest) apply(view *filter.SavedView) {
+	if req.Name != nil {
+		view.Name = *req.Name
+	}
+	if req.Page != nil {
+		view.Page = *req.Page
+	}
+	if req.FilterExpr != nil {
+		view.FilterExpr = *req.FilterExpr
+	}
+	if req.TimeRange != nil {
+		view.TimeRange = *req.TimeRange
+		view.From, view.To = nil, nil
+	}
+	if req.From != nil {
+		from := req.From.UTC()
+		view.From = &from
+	}
+	if req.To != nil {
+		to := req.To.UTC()
+		view.To = &to
+	}
+	if req.Visibility != nil {
+		view.Visibility = *req.Visibility
+	}
+}
+
+// canManageSavedView returns true if the user can edit
+// or delete the view. Private views are managed by
+// their creator only. Team views are also managed by
+// anyone who can manage the app.
+func canManageSavedView(pg *pgxpool.Pool, userID uuid.UUID, teamID uuid.UUID, view filter.SavedView) (bool, error) {
+	if view.IsCreatedBy(userID) {
+		return true, nil
+	}
+
+	if view.Visibility != filter.ViewVisibilityTeam {
+		return false, nil
+	}
+
+	return measure.PerformAuthz(pg, userID.String(), teamID.String(), *measure.ScopeAppAll)
+}
+
+// GetSavedViews lists the app's saved views the user
+// can see, optionally of one dashboard page only.
+func (h Handlers) GetSavedViews(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	views, err := filter.GetSavedViews(ctx, deps.PgPool, appID, userUUID, filter.ViewPage(c.Query("page")))
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved views: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": views})
+}
+
+// GetSavedView fetches a saved view the user can see.
+func (h Handlers) GetSavedView(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	viewID, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		msg := `saved view id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	view, err := filter.GetSavedView(ctx, deps.PgPool, appID, viewID, userUUID)
+	if errors.Is(err, filter.ErrSavedViewNotFound) {
+		msg := fmt.Sprintf("saved view [%s] not found", viewID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// CreateSavedView saves a named view for the app, private
+// to the user unless shared with the team.
+func (h Handlers) CreateSavedView(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var req savedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	now := time.Now().UTC()
+	view := filter.SavedView{
+		ID:         uuid.New(),
+		TeamID:     *team.ID,
+		AppID:      appID,
+		Visibility: filter.ViewVisibilityPrivate,
+		CreatedBy:  &userUUID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	req.apply(&view)
+
+	if err := view.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	count, err := filter.CountSavedViews(ctx, deps.PgPool, appID)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved views: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if count >= filter.MaxSavedViewsPerApp {
+		msg := fmt.Sprintf("an app can have at most %d saved views", filter.MaxSavedViewsPerApp)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := view.Insert(ctx, deps.PgPool); err != nil {
+		msg := fmt.Sprintf("error occurred while creating saved view: %s", appID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, view)
+}
+
+// UpdateSavedView updates the fields present in the
+// request. A view made private or moved to another
+// page stops being its page's default.
+func (h Handlers) UpdateSavedView(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	viewID, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		msg := `saved view id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	view, err := filter.GetSavedView(ctx, deps.PgPool, appID, viewID, userUUID)
+	if errors.Is(err, filter.ErrSavedViewNotFound) {
+		msg := fmt.Sprintf("saved view [%s] not found", viewID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := canManageSavedView(deps.PgPool, userUUID, *team.ID, view); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to change saved view [%s]`, viewID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var req savedViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	page := view.Page
+	req.apply(&view)
+	if view.Page != page || view.Visibility != filter.ViewVisibilityTeam {
+		view.IsDefault = false
+	}
+	view.UpdatedAt = time.Now().UTC()
+
+	if err := view.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := view.Update(ctx, deps.PgPool); err != nil {
+		msg := fmt.Sprintf("error occurred while updating saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+func (h Handlers) DeleteSavedView(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	viewID, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		msg := `saved view id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	view, err := filter.GetSavedView(ctx, deps.PgPool, appID, viewID, userUUID)
+	if errors.Is(err, filter.ErrSavedViewNotFound) {
+		msg := fmt.Sprintf("saved view [%s] not found", viewID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := canManageSavedView(deps.PgPool, userUUID, *team.ID, view); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to delete saved view [%s]`, viewID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	if err := filter.DeleteSavedView(ctx, deps.PgPool, appID, viewID); err != nil {
+		msg := fmt.Sprintf("error occurred while deleting saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// PinSavedView pins a team view as the default view of
+// its dashboard page, replacing the page's previous
+// default. Unpinning leaves the page without a default.
+// The default applies to everyone on the app, so only
+// those who can manage the app can change it.
+func (h Handlers) PinSavedView(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userID := c.GetString("userId")
+	pin := c.Request.Method != http.MethodDelete
+	appID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	viewID, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		msg := `saved view id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := measure.App{ID: &appID}
+	team, err := app.GetTeam(ctx, deps.PgPool)
+	if err != nil {
+		msg := `couldn't retrieve team for app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", appID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userID, team.ID.String(), *measure.ScopeAppAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for app [%s]`, appID)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	view, err := filter.GetSavedView(ctx, deps.PgPool, appID, viewID, userUUID)
+	if errors.Is(err, filter.ErrSavedViewNotFound) {
+		msg := fmt.Sprintf("saved view [%s] not found", viewID)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if pin && view.Visibility != filter.ViewVisibilityTeam {
+		msg := `only team views can be an app's default view`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if !pin && !view.IsDefault {
+		c.JSON(http.StatusOK, view)
+		return
+	}
+
+	var pinID *uuid.UUID
+	if pin {
+		pinID = &viewID
+	}
+
+	if err := filter.SetDefaultSavedView(ctx, deps.PgPool, appID, view.Page, pinID); err != nil {
+		msg := fmt.Sprintf("error occurred while pinning saved view: %s", viewID)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	view.IsDefault = pin
+	c.JSON(http.StatusOK, view)
+}
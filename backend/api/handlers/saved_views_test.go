@@ -0,0 +1,238 @@
+//go:build integration
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newSavedViewsContext(method, userID string, appID uuid.UUID, body string, params ...gin.Param) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/apps/"+appID.String()+"/views", strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = append(gin.Params{{Key: "id", Value: appID.String()}}, params...)
+	return c, w
+}
+
+func createSavedView(t *testing.T, userID string, appID uuid.UUID, body string) string {
+	t.Helper()
+
+	c, w := newSavedViewsContext(http.MethodPost, userID, appID, body)
+	h.CreateSavedView(c)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201, body: %s", w.Code, w.Body.String())
+	}
+
+	var view struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &view); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	return view.ID
+}
+
+func listSavedViews(t *testing.T, userID string, appID uuid.UUID) []map[string]any {
+	t.Helper()
+
+	c, w := newSavedViewsContext(http.MethodGet, userID, appID, "")
+	h.GetSavedViews(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+	}
+
+	var list struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	return list.Results
+}
+
+func TestSavedViews(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("private views are seen by their creator only", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		ownerID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		developerID := uuid.New().String()
+		seedUser(ctx, t, developerID, "developer-views@test.com")
+		seedTeamMembership(ctx, t, teamID, developerID, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		viewID := createSavedView(t, developerID, appID, `{"name":"Android 14","page":"crashes","filter_expr":"os_version:in:14","time_range":"last_7_days"}`)
+		createSavedView(t, developerID, appID, `{"name":"Slow sessions","page":"sessions","time_range":"last_24_hours","visibility":"team"}`)
+
+		if got := listSavedViews(t, developerID, appID); len(got) != 2 {
+			t.Fatalf("creator sees %d views, want 2", len(got))
+		}
+		if got := listSavedViews(t, ownerID, appID); len(got) != 1 || got[0]["name"] != "Slow sessions" {
+			t.Fatalf("team member sees %v, want the team view only", got)
+		}
+
+		c, w := newSavedViewsContext(http.MethodGet, ownerID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.GetSavedView(c)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+
+		c, w = newSavedViewsContext(http.MethodDelete, ownerID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.DeleteSavedView(c)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("updates and deletes a view", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		viewID := createSavedView(t, userID, appID, `{"name":"Recent","page":"traces","time_range":"last_hour"}`)
+
+		c, w := newSavedViewsContext(http.MethodPatch, userID, appID, `{"name":"Last month","time_range":"custom","from":"2026-09-01T00:00:00Z","to":"2026-10-01T00:00:00Z"}`, gin.Param{Key: "viewId", Value: viewID})
+		h.UpdateSavedView(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		views := listSavedViews(t, userID, appID)
+		if len(views) != 1 || views[0]["name"] != "Last month" || views[0]["time_range"] != "custom" {
+			t.Fatalf("views = %v, want the updated view", views)
+		}
+
+		c, w = newSavedViewsContext(http.MethodDelete, userID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.DeleteSavedView(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		if views := listSavedViews(t, userID, appID); len(views) != 0 {
+			t.Errorf("views = %v, want none", views)
+		}
+	})
+
+	t.Run("pins one default view per page", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "admin")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		firstID := createSavedView(t, userID, appID, `{"name":"First","page":"crashes","time_range":"last_7_days","visibility":"team"}`)
+		secondID := createSavedView(t, userID, appID, `{"name":"Second","page":"crashes","time_range":"last_7_days","visibility":"team"}`)
+		privateID := createSavedView(t, userID, appID, `{"name":"Mine","page":"crashes","time_range":"last_7_days"}`)
+
+		for _, id := range []string{firstID, secondID} {
+			c, w := newSavedViewsContext(http.MethodPut, userID, appID, "", gin.Param{Key: "viewId", Value: id})
+			h.PinSavedView(c)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+			}
+		}
+
+		views := listSavedViews(t, userID, appID)
+		if views[0]["id"] != secondID || views[0]["is_default"] != true || views[1]["is_default"] != false {
+			t.Fatalf("views = %v, want the second view as the only default", views)
+		}
+
+		c, w := newSavedViewsContext(http.MethodPut, userID, appID, "", gin.Param{Key: "viewId", Value: privateID})
+		h.PinSavedView(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+
+		c, w = newSavedViewsContext(http.MethodDelete, userID, appID, "", gin.Param{Key: "viewId", Value: secondID})
+		h.PinSavedView(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		for _, view := range listSavedViews(t, userID, appID) {
+			if view["is_default"] == true {
+				t.Errorf("view %v is still a default", view["id"])
+			}
+		}
+	})
+
+	t.Run("viewer cannot pin a default view", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		adminID, teamID := seedTeamAndMemberWithRole(t, ctx, "admin")
+		viewerID := uuid.New().String()
+		seedUser(ctx, t, viewerID, "viewer-views@test.com")
+		seedTeamMembership(ctx, t, teamID, viewerID, "viewer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		viewID := createSavedView(t, viewerID, appID, `{"name":"Mine","page":"sessions","time_range":"last_7_days","visibility":"team"}`)
+
+		c, w := newSavedViewsContext(http.MethodPut, viewerID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.PinSavedView(c)
+		if w.Code != http.StatusForbidden {
+			t.Fatalf("status = %d, want 403, body: %s", w.Code, w.Body.String())
+		}
+
+		c, w = newSavedViewsContext(http.MethodPut, adminID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.PinSavedView(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		c, w = newSavedViewsContext(http.MethodDelete, viewerID, appID, "", gin.Param{Key: "viewId", Value: viewID})
+		h.PinSavedView(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("team views are managed by their creator or app admins", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		adminID, teamID := seedTeamAndMemberWithRole(t, ctx, "admin")
+		creatorID := uuid.New().String()
+		otherID := uuid.New().String()
+		seedUser(ctx, t, creatorID, "creator-views@test.com")
+		seedUser(ctx, t, otherID, "other-views@test.com")
+		seedTeamMembership(ctx, t, teamID, creatorID, "developer")
+		seedTeamMembership(ctx, t, teamID, otherID, "developer")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		viewID := createSavedView(t, creatorID, appID, `{"name":"Shared","page":"bug_reports","time_range":"last_30_days","visibility":"team"}`)
+
+		c, w := newSavedViewsContext(http.MethodPatch, otherID, appID, `{"name":"Renamed"}`, gin.Param{Key: "viewId", Value: viewID})
+		h.UpdateSavedView(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+
+		c, w = newSavedViewsContext(http.MethodPatch, adminID, appID, `{"name":"Renamed"}`, gin.Param{Key: "viewId", Value: viewID})
+		h.UpdateSavedView(c)
+		if w.Code != http.StatusOK {
+			t.Errorf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rejects an invalid view", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+		appID := uuid.New()
+		seedApp(ctx, t, appID, teamID, 30)
+
+		c, w := newSavedViewsContext(http.MethodPost, userID, appID, `{"name":"Bad","page":"alerts","filter_expr":"os_version:in:14","time_range":"last_7_days"}`)
+		h.CreateSavedView(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400, body: %s", w.Code, w.Body.String())
+		}
+	})
+}
@@ -7,6 +7,7 @@ import (
 
 	"backend/libs/chquery"
 	"backend/libs/measure"
+	"backend/libs/ratelimit"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -25,6 +26,9 @@ type MonthlyAppUsage struct {
 	Events    uint64 `json:"events"`
 	Spans     uint64 `json:"spans"`
 	BytesIn   uint64 `json:"bytes_in"`
+	// RateLimited is what ingest dropped
+	// for exceeding the app's rate limits.
+	RateLimited ratelimit.Drops `json:"rate_limited"`
 }
 
 func (h Handlers) GetUsage(c *gin.Context) {
@@ -130,10 +134,10 @@ func (h Handlers) GetUsage(c *gin.Context) {
 	// (e.g. March 31 minus 1 month = Feb 31 → Go normalizes to March 3).
 	monthYearFormat := "Jan 2006"
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
-	monthNames := []string{
-		startOfMonth.AddDate(0, -2, 0).Format(monthYearFormat),
-		startOfMonth.AddDate(0, -1, 0).Format(monthYearFormat),
-		startOfMonth.Format(monthYearFormat),
+	months := []time.Time{
+		startOfMonth.AddDate(0, -2, 0),
+		startOfMonth.AddDate(0, -1, 0),
+		startOfMonth,
 	}
 
 	// Populate appUsageMap with metrics rows from DB
@@ -174,18 +178,28 @@ func (h Handlers) GetUsage(c *gin.Context) {
 		}
 
 		newMonthlyAppUsage := make([]MonthlyAppUsage, 0, 3)
-		for _, monthName := range monthNames {
-			if usage, exists := monthDataMap[monthName]; exists {
-				newMonthlyAppUsage = append(newMonthlyAppUsage, usage)
-			} else {
-				newMonthlyAppUsage = append(newMonthlyAppUsage, MonthlyAppUsage{
+		for _, month := range months {
+			monthName := month.Format(monthYearFormat)
+			usage, exists := monthDataMap[monthName]
+			if !exists {
+				usage = MonthlyAppUsage{
 					MonthName: monthName,
 					Sessions:  0,
 					Events:    0,
 					Spans:     0,
 					BytesIn:   0,
-				})
+				}
 			}
+
+			// rate limit drops are best-effort, usage
+			// is still served when they can't be read
+			drops, err := ratelimit.GetDrops(ctx, deps.VK, uuid.MustParse(appUsage.AppId), month.Year(), month.Month())
+			if err != nil {
+				fmt.Printf("failed to read rate limit drops for app %s: %v\n", appUsage.AppId, err)
+			}
+			usage.RateLimited = drops
+
+			newMonthlyAppUsage = append(newMonthlyAppUsage, usage)
 		}
 		appUsage.MonthlyAppUsage = newMonthlyAppUsage
 	}
@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/libs/measure"
+	"backend/libs/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// webhookClient posts test deliveries. A package var so tests can swap the
+// transport.
+var webhookClient = webhook.NewClient()
+
+// webhookDeliveriesDefaultLimit is the page size of the delivery log when
+// the request does not set one.
+const webhookDeliveriesDefaultLimit = 20
+
+// webhookDeliveriesMaxLimit is the largest page size of the delivery log.
+const webhookDeliveriesMaxLimit = 100
+
+type TeamWebhook struct {
+	URL       string    `json:"url"`
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Secret is only set in the response that creates the webhook or
+	// rotates its secret. It is never read back afterwards.
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	AppID          *uuid.UUID      `json:"app_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus *int            `json:"response_status"`
+	LastError      *string         `json:"last_error"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func (h Handlers) GetTeamWebhook(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		From("team_webhooks").
+		Select("url").
+		Select("is_active").
+		Select("created_at").
+		Select("updated_at").
+		Where("team_id = ?", teamId)
+
+	defer stmt.Close()
+
+	var result TeamWebhook
+	err = deps.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&result.URL, &result.IsActive, &result.CreatedAt, &result.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusOK, nil)
+			return
+		}
+		msg := fmt.Sprintf("error occurred while querying team webhook: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateTeamWebhook creates or updates the team's webhook url. A signing
+// secret is created with the webhook and returned once in the response;
+// changing the url of an existing webhook keeps its secret.
+func (h Handlers) UpdateTeamWebhook(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		URL string `json:"url" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := webhook.ValidateURL(payload.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		msg := "failed to create webhook secret"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		InsertInto("team_webhooks").
+		Set("team_id", teamId).
+		Set("url", payload.URL).
+		Set("secret", secret).
+		Set("is_active", true).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	defer stmt.Close()
+
+	// xmax is 0 only for a freshly inserted row, which tells a create
+	// apart from an update without a second query
+	query := stmt.String() + ` ON CONFLICT (team_id) DO UPDATE SET
+		url = EXCLUDED.url,
+		updated_at = NOW()
+		RETURNING is_active, created_at, updated_at, (xmax = 0)`
+
+	var result TeamWebhook
+	var created bool
+	if err := deps.PgPool.QueryRow(ctx, query, stmt.Args()...).Scan(&result.IsActive, &result.CreatedAt, &result.UpdatedAt, &created); err != nil {
+		msg := fmt.Sprintf("error occurred while saving team webhook: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	result.URL = payload.URL
+	if created {
+		result.Secret = secret
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RotateTeamWebhookSecret replaces the team's webhook signing secret and
+// returns the new one. Deliveries still pending are signed with the new
+// secret.
+func (h Handlers) RotateTeamWebhookSecret(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		msg := "failed to create webhook secret"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		Update("team_webhooks").
+		Set("secret", secret).
+		Set("updated_at", time.Now()).
+		Where("team_id = ?", teamId)
+
+	defer stmt.Close()
+
+	commandTag, err := deps.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while rotating team webhook secret: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no webhook found for the team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret})
+}
+
+func (h Handlers) UpdateTeamWebhookStatus(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		IsActive bool `json:"is_active"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `invalid request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		Update("team_webhooks").
+		Set("is_active", payload.IsActive).
+		Set("updated_at", time.Now()).
+		Where("team_id = ?", teamId)
+
+	defer stmt.Close()
+
+	commandTag, err := deps.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while updating team webhook: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no webhook found for the team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// DeleteTeamWebhook removes the team's webhook. Its delivery log, including
+// deliveries still pending, goes with it.
+func (h Handlers) DeleteTeamWebhook(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		DeleteFrom("team_webhooks").
+		Where("team_id = ?", teamId)
+
+	defer stmt.Close()
+
+	commandTag, err := deps.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while deleting team webhook: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no webhook found for the team"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// SendTestWebhookAlert posts a signed test event to the team's webhook
+// right away and records the attempt in the delivery log. Test sends are
+// not retried.
+func (h Handlers) SendTestWebhookAlert(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamAll); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var url, secret string
+	stmt := sqlf.PostgreSQL.
+		From("team_webhooks").
+		Select("url").
+		Select("secret").
+		Where("team_id = ?", teamId)
+
+	defer stmt.Close()
+
+	if err := deps.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&url, &secret); err != nil {
+		if err == pgx.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no webhook found for the team"})
+			return
+		}
+		msg := fmt.Sprintf("error occurred while querying team webhook: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	deliveryID := uuid.New()
+	event := webhook.Event{
+		ID:        deliveryID.String(),
+		Type:      webhook.EventTest,
+		CreatedAt: time.Now().UTC(),
+		Team:      webhook.Team{ID: teamId.String()},
+		Alert: &webhook.Alert{
+			Message: "This is a test alert from Measure. If you are receiving this, your webhook integration is working correctly!",
+			URL:     fmt.Sprintf("%s/%s/overview", deps.Config.SiteOrigin, teamId),
+		},
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		msg := "failed to marshal test webhook event"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	code, sendErr := webhook.Post(ctx, webhookClient, url, secret, deliveryID.String(), webhook.EventTest, body)
+
+	insertStmt := sqlf.PostgreSQL.
+		InsertInto("webhook_deliveries").
+		Set("id", deliveryID).
+		Set("team_id", teamId).
+		Set("event_type", webhook.EventTest).
+		SetExpr("payload", "?::jsonb", string(body)).
+		Set("attempts", 1).
+		Set("next_attempt_at", time.Now()).
+		Set("created_at", time.Now()).
+		Set("updated_at", time.Now())
+
+	if code != 0 {
+		insertStmt.Set("response_status", code)
+	}
+
+	if sendErr != nil {
+		insertStmt.Set("status", "failed").Set("last_error", sendErr.Error())
+	} else {
+		insertStmt.Set("status", "delivered").Set("delivered_at", time.Now())
+	}
+
+	defer insertStmt.Close()
+
+	if _, err := deps.PgPool.Exec(ctx, insertStmt.String(), insertStmt.Args()...); err != nil {
+		fmt.Println("failed to record test webhook delivery", err)
+	}
+
+	if sendErr != nil {
+		msg := fmt.Sprintf("Failed to send test webhook alert: %s", sendErr)
+		fmt.Println(msg)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+// GetTeamWebhookDeliveries lists the team's webhook deliveries newest first.
+// The page is set with the limit and offset query params.
+func (h Handlers) GetTeamWebhookDeliveries(c *gin.Context) {
+	deps := h.Deps
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	teamId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `team id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if ok, err := measure.PerformAuthz(deps.PgPool, userId, teamId.String(), *measure.ScopeTeamRead); err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	} else if !ok {
+		msg := fmt.Sprintf(`you don't have permissions for team [%s]`, teamId)
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	limit := webhookDeliveriesDefaultLimit
+	offset := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > webhookDeliveriesMaxLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", webhookDeliveriesMaxLimit)})
+			return
+		}
+		limit = n
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative number"})
+			return
+		}
+		offset = n
+	}
+
+	stmt := sqlf.PostgreSQL.
+		From("webhook_deliveries").
+		Select("id").
+		Select("app_id").
+		Select("event_type").
+		Select("payload").
+		Select("status").
+		Select("attempts").
+		Select("response_status").
+		Select("last_error").
+		Select("next_attempt_at").
+		Select("delivered_at").
+		Select("created_at").
+		Where("team_id = ?", teamId).
+		OrderBy("created_at DESC").
+		Limit(uint64(limit) + 1).
+		Offset(uint64(offset))
+
+	defer stmt.Close()
+
+	rows, err := deps.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying webhook deliveries: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.AppID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseStatus,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.DeliveredAt,
+			&d.CreatedAt,
+		); err != nil {
+			msg := fmt.Sprintf("error occurred while reading webhook deliveries: %s", teamId)
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		msg := fmt.Sprintf("error occurred while reading webhook deliveries: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	next := false
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		next = true
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": deliveries,
+		"meta": gin.H{
+			"next":     next,
+			"previous": offset > 0,
+		},
+	})
+}
@@ -0,0 +1,215 @@
+//go:build integration
+
+package handlers
+
+import (
+	"backend/libs/webhook"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newWebhookContext(method, userID string, teamID uuid.UUID, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	c, w := newTestGinContext(method, "/teams/"+teamID.String()+"/webhook", strings.NewReader(body))
+	c.Set("userId", userID)
+	c.Params = gin.Params{{Key: "id", Value: teamID.String()}}
+	return c, w
+}
+
+// seedTeamWebhook inserts an active webhook for the team and returns its
+// secret. Test receivers listen on loopback, which UpdateTeamWebhook
+// rejects, so this bypasses it.
+func seedTeamWebhook(ctx context.Context, t *testing.T, teamID uuid.UUID, url string) string {
+	t.Helper()
+	secret := "whsec_test"
+	if _, err := th.PgPool.Exec(ctx,
+		`INSERT INTO team_webhooks (team_id, url, secret, is_active, created_at, updated_at) VALUES ($1, $2, $3, true, now(), now())`,
+		teamID, url, secret); err != nil {
+		t.Fatalf("seed team webhook: %v", err)
+	}
+	return secret
+}
+
+// useWebhookClient posts test deliveries through client until the
+// test ends. The default client refuses loopback receivers.
+func useWebhookClient(t *testing.T, client *http.Client) {
+	t.Helper()
+	prev := webhookClient
+	webhookClient = client
+	t.Cleanup(func() { webhookClient = prev })
+}
+
+func TestUpdateTeamWebhook(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("create returns the secret once, update keeps it", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newWebhookContext(http.MethodPut, userID, teamID, `{"url":"https://hooks.example.com/a"}`)
+		h.UpdateTeamWebhook(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("create status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var created TeamWebhook
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if !strings.HasPrefix(created.Secret, "whsec_") {
+			t.Errorf("secret = %q, want a whsec_ secret on create", created.Secret)
+		}
+		if !created.IsActive {
+			t.Error("is_active = false, want a new webhook active")
+		}
+
+		c, w = newWebhookContext(http.MethodPut, userID, teamID, `{"url":"https://hooks.example.com/b"}`)
+		h.UpdateTeamWebhook(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("update status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		var updated TeamWebhook
+		if err := json.Unmarshal(w.Body.Bytes(), &updated); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if updated.Secret != "" {
+			t.Error("update returned the secret, want it only on create")
+		}
+
+		var url, secret string
+		if err := th.PgPool.QueryRow(ctx,
+			`SELECT url, secret FROM team_webhooks WHERE team_id = $1`, teamID).Scan(&url, &secret); err != nil {
+			t.Fatalf("read team_webhooks: %v", err)
+		}
+		if url != "https://hooks.example.com/b" {
+			t.Errorf("url = %q, want the updated url", url)
+		}
+		if secret != created.Secret {
+			t.Error("secret changed on update, want it kept")
+		}
+	})
+
+	t.Run("rejects a non-http url", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newWebhookContext(http.MethodPut, userID, teamID, `{"url":"ftp://hooks.example.com"}`)
+		h.UpdateTeamWebhook(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("rejects a private address", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newWebhookContext(http.MethodPut, userID, teamID, `{"url":"http://169.254.169.254/latest/meta-data"}`)
+		h.UpdateTeamWebhook(c)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", w.Code)
+		}
+	})
+
+	t.Run("viewer is forbidden", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "viewer")
+
+		c, w := newWebhookContext(http.MethodPut, userID, teamID, `{"url":"https://hooks.example.com/a"}`)
+		h.UpdateTeamWebhook(c)
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+	})
+}
+
+func TestSendTestWebhookAlert(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("posts a signed test event and logs the delivery", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		var gotSignature, gotEvent string
+		var gotBody []byte
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotSignature = r.Header.Get(webhook.HeaderSignature)
+			gotEvent = r.Header.Get(webhook.HeaderEvent)
+			gotBody, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer srv.Close()
+		useWebhookClient(t, srv.Client())
+		secret := seedTeamWebhook(ctx, t, teamID, srv.URL)
+
+		c, w := newWebhookContext(http.MethodPost, userID, teamID, "")
+		h.SendTestWebhookAlert(c)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+		}
+
+		if gotEvent != webhook.EventTest {
+			t.Errorf("event header = %q, want %q", gotEvent, webhook.EventTest)
+		}
+		if err := webhook.Verify(secret, gotSignature, gotBody, time.Now(), time.Minute); err != nil {
+			t.Errorf("signature does not verify: %v", err)
+		}
+
+		var status string
+		var responseStatus int
+		if err := th.PgPool.QueryRow(ctx,
+			`SELECT status, response_status FROM webhook_deliveries WHERE team_id = $1`, teamID).Scan(&status, &responseStatus); err != nil {
+			t.Fatalf("read webhook_deliveries: %v", err)
+		}
+		if status != "delivered" || responseStatus != http.StatusOK {
+			t.Errorf("delivery = %s/%d, want delivered/200", status, responseStatus)
+		}
+	})
+
+	t.Run("logs a failed delivery", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusGone)
+		}))
+		defer srv.Close()
+		useWebhookClient(t, srv.Client())
+		seedTeamWebhook(ctx, t, teamID, srv.URL)
+
+		c, w := newWebhookContext(http.MethodPost, userID, teamID, "")
+		h.SendTestWebhookAlert(c)
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("status = %d, want 500", w.Code)
+		}
+
+		var status string
+		if err := th.PgPool.QueryRow(ctx,
+			`SELECT status FROM webhook_deliveries WHERE team_id = $1`, teamID).Scan(&status); err != nil {
+			t.Fatalf("read webhook_deliveries: %v", err)
+		}
+		if status != "failed" {
+			t.Errorf("status = %q, want failed", status)
+		}
+	})
+
+	t.Run("404 without a webhook", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		userID, teamID := seedTeamAndMemberWithRole(t, ctx, "owner")
+
+		c, w := newWebhookContext(http.MethodPost, userID, teamID, "")
+		h.SendTestWebhookAlert(c)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+}
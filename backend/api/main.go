@@ -26,7 +26,7 @@ import (
 func main() {
 	config := server.NewConfig()
 	deps := server.Connect(config)
-	producer := server.NewAgentEventsProducer(config)
+	producer := server.NewAgentEventsProducer(config, deps.PgPool)
 
 	defer deps.PgPool.Close()
 	if deps.VK != nil {
@@ -89,6 +89,9 @@ func main() {
 	r.PUT("/builds", hdl.ValidateAPIKey(), hdl.PutBuilds)
 	r.GET("/config", hdl.ValidateAPIKey(), hdl.GetConfigForSdk)
 
+	// Metric export routes, authenticated by the team's scrape key
+	r.GET("/prometheus/teams/:id/metrics", hdl.GetTeamPrometheusMetrics)
+
 	// Proxy routes
 	r.GET("/proxy/attachments", hdl.ProxyAttachment)
 	r.PUT("/proxy/attachments", hdl.ProxyAttachment)
@@ -111,13 +114,26 @@ func main() {
 	apps := r.Group("/apps", hdl.ValidateAccessToken())
 	{
 		apps.GET(":id/journey", hdl.GetAppJourney)
+		apps.GET(":id/funnel", hdl.GetAppFunnel)
+		apps.GET(":id/retention/cohorts", hdl.GetAppRetentionCohorts)
+		apps.GET(":id/retention/cohorts/stickiness", hdl.GetAppRetentionStickiness)
 		apps.GET(":id/metrics", hdl.GetAppMetrics)
+		apps.GET(":id/releases/compare", hdl.GetReleaseComparison)
+		apps.GET(":id/profiles/flamegraph", hdl.GetProfileFlamegraph)
+		apps.GET(":id/profiles/flamegraph/diff", hdl.GetProfileFlamegraphDiff)
+		apps.GET(":id/heapDumps/leaks", hdl.GetHeapDumpLeaks)
+		apps.GET(":id/perfettoTraces/startup", hdl.GetPerfettoStartupBreakdowns)
+		apps.GET(":id/endUsers", hdl.GetEndUserProfile)
 		apps.GET(":id/health/plots/instances", hdl.GetHealthOverviewPlotInstances)
 		apps.GET(":id/filters", hdl.GetAppFilters)
 
 		// errors
 		apps.GET(":id/errorGroups", hdl.GetErrorOverview)
 		apps.GET(":id/errorGroups/plots/instances", hdl.GetErrorOverviewPlotInstances)
+		apps.GET(":id/errorGroups/:errorGroupId", hdl.GetErrorGroupState)
+		apps.PATCH(":id/errorGroups/:errorGroupId", hdl.UpdateErrorGroupState)
+		apps.POST(":id/errorGroups/merge", hdl.MergeErrorGroups)
+		apps.DELETE(":id/errorGroups/:errorGroupId/merge", hdl.SplitErrorGroup)
 		apps.GET(":id/errorGroups/:errorGroupId/errors", hdl.GetErrorDetailErrors)
 		apps.GET(":id/errorGroups/:errorGroupId/path", hdl.GetErrorGroupCommonPath)
 		apps.GET(":id/errorGroups/:errorGroupId/plots/instances", hdl.GetErrorDetailPlotInstances)
@@ -140,16 +156,30 @@ func main() {
 		apps.GET(":id/bugReports/:bugReportId", hdl.GetBugReport)
 		apps.PATCH(":id/bugReports/:bugReportId", hdl.UpdateBugReportStatus)
 
+		// custom events
+		apps.GET(":id/customEvents/plots/insights", hdl.GetCustomEventInsightsPlot)
+
 		// alerts
 		apps.GET(":id/alerts", hdl.GetAlertsOverview)
+		apps.GET(":id/alertRules", hdl.GetAlertRules)
+		apps.POST(":id/alertRules", hdl.CreateAlertRule)
+		apps.GET(":id/alertRules/:ruleId", hdl.GetAlertRule)
+		apps.PATCH(":id/alertRules/:ruleId", hdl.UpdateAlertRule)
+		apps.DELETE(":id/alertRules/:ruleId", hdl.DeleteAlertRule)
+		apps.GET(":id/fingerprintRules", hdl.GetFingerprintRules)
+		apps.POST(":id/fingerprintRules", hdl.CreateFingerprintRule)
+		apps.DELETE(":id/fingerprintRules/:ruleId", hdl.DeleteFingerprintRule)
 
 		// threshold preferences
 		apps.GET(":id/thresholdPrefs", hdl.GetAppThresholdPrefs)
 		apps.PATCH(":id/thresholdPrefs", hdl.UpdateAppThresholdPrefs)
+		apps.GET(":id/ingestLimits", hdl.GetAppIngestLimits)
+		apps.PUT(":id/ingestLimits", hdl.UpdateAppIngestLimits)
 
 		// builds
 		apps.GET(":id/builds", hdl.GetBuilds)
 		apps.GET(":id/builds/:buildFileId/download", hdl.DownloadBuildFile)
+		apps.GET(":id/symbols/coverage", hdl.GetSymbolCoverage)
 
 		// filters
 		apps.GET(":id/filters/keys", hdl.GetFilterKeys)
@@ -177,6 +207,13 @@ func main() {
 
 		// filters
 		apps.POST(":id/shortFilters", hdl.CreateShortFilters)
+		apps.GET(":id/views", hdl.GetSavedViews)
+		apps.POST(":id/views", hdl.CreateSavedView)
+		apps.GET(":id/views/:viewId", hdl.GetSavedView)
+		apps.PATCH(":id/views/:viewId", hdl.UpdateSavedView)
+		apps.DELETE(":id/views/:viewId", hdl.DeleteSavedView)
+		apps.PUT(":id/views/:viewId/default", hdl.PinSavedView)
+		apps.DELETE(":id/views/:viewId/default", hdl.PinSavedView)
 	}
 
 	teams := r.Group("/teams", hdl.ValidateAccessToken())
@@ -201,6 +238,18 @@ func main() {
 		teams.GET(":id/slack/connect-url", hdl.CreateTeamSlackConnectURL)
 		teams.PATCH(":id/slack/status", hdl.UpdateTeamSlackStatus)
 		teams.POST(":id/slack/test", hdl.SendTestSlackAlert)
+		teams.GET(":id/webhook", hdl.GetTeamWebhook)
+		teams.PUT(":id/webhook", hdl.UpdateTeamWebhook)
+		teams.DELETE(":id/webhook", hdl.DeleteTeamWebhook)
+		teams.PATCH(":id/webhook/status", hdl.UpdateTeamWebhookStatus)
+		teams.PATCH(":id/webhook/secret", hdl.RotateTeamWebhookSecret)
+		teams.POST(":id/webhook/test", hdl.SendTestWebhookAlert)
+		teams.GET(":id/webhook/deliveries", hdl.GetTeamWebhookDeliveries)
+		teams.GET(":id/metric-export", hdl.GetTeamMetricExport)
+		teams.PUT(":id/metric-export", hdl.UpdateTeamMetricExport)
+		teams.DELETE(":id/metric-export", hdl.DeleteTeamMetricExport)
+		teams.PATCH(":id/metric-export/scrape-key", hdl.RotateTeamScrapeKey)
+		teams.DELETE(":id/metric-export/scrape-key", hdl.DeleteTeamScrapeKey)
 		teams.GET(":id/billing/info", hdl.GetTeamBilling)
 		teams.PATCH(":id/billing/checkout", hdl.CreateCheckoutSession)
 		teams.PATCH(":id/billing/downgrade", hdl.CancelAndDowngradeToFreePlan)
@@ -78,6 +78,7 @@ type Config struct {
 	CH                         ClickhouseConfig
 	RD                         RedisConfig
 	IG                         IggyConfig
+	BusBackend                 string
 	ServiceAccountEmail        string
 	SymbolsBucket              string
 	SymbolsBucketRegion        string
@@ -323,19 +324,21 @@ func NewConfig() *Config {
 		log.Fatalf("Invalid REDIS_PORT value: %v", err)
 	}
 
+	busBackend := bus.SelfHostBackend()
+
 	// Iggy backs the message bus on self-host only; cloud uses Pub/Sub.
 	iggyAddr := os.Getenv("IGGY_ADDR")
-	if iggyAddr == "" && !cloudEnv {
+	if iggyAddr == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_ADDR env var is not set, the Slack query agent will not work")
 	}
 
 	iggyUsername := os.Getenv("IGGY_USERNAME")
-	if iggyUsername == "" && !cloudEnv {
+	if iggyUsername == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_USERNAME env var is not set, the Slack query agent will not work")
 	}
 
 	iggyPassword := os.Getenv("IGGY_PASSWORD")
-	if iggyPassword == "" && !cloudEnv {
+	if iggyPassword == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_PASSWORD env var is not set, the Slack query agent will not work")
 	}
 
@@ -464,6 +467,7 @@ func NewConfig() *Config {
 			Username: iggyUsername,
 			Password: iggyPassword,
 		},
+		BusBackend:                 busBackend,
 		ServiceAccountEmail:        serviceAccountEmail,
 		SymbolsBucket:              symbolsBucket,
 		SymbolsBucketRegion:        symbolsBucketRegion,
@@ -716,13 +720,13 @@ func InitTracing(c *Config) func(context.Context) error {
 // that publishes. A failed build is not fatal: the wrapper retries it on the
 // next publish. It returns nil when no bus is configured at all; the Slack
 // agent is then off by design.
-func NewAgentEventsProducer(config *Config) bus.Producer {
-	if !config.IsCloud() && config.IG.Addr == "" {
+func NewAgentEventsProducer(config *Config, pgPool *pgxpool.Pool) bus.Producer {
+	if !config.IsCloud() && config.BusBackend == bus.BackendIggy && config.IG.Addr == "" {
 		return nil
 	}
 
 	producer := &agentEventsProducer{build: func() (bus.Producer, error) {
-		return buildAgentEventsProducer(config)
+		return buildAgentEventsProducer(config, pgPool)
 	}}
 	if _, err := producer.swap(nil); err != nil {
 		log.Printf("failed to create agent events producer, will retry on publish: %v\n", err)
@@ -732,10 +736,13 @@ func NewAgentEventsProducer(config *Config) bus.Producer {
 
 // buildAgentEventsProducer creates the raw producer for the configured bus
 // backend.
-func buildAgentEventsProducer(config *Config) (bus.Producer, error) {
+func buildAgentEventsProducer(config *Config, pgPool *pgxpool.Pool) (bus.Producer, error) {
 	if config.IsCloud() {
 		return bus.NewPubSubProducer(context.Background(), slack.AgentEventsTopic)
 	}
+	if config.BusBackend == bus.BackendPostgres {
+		return bus.NewPostgresProducer(pgPool, slack.AgentEventsTopic)
+	}
 	return bus.NewIggyProducer(
 		config.IG.Addr,
 		config.IG.Username,
@@ -50,6 +50,9 @@ func DeleteStaleData(ctx context.Context) {
 	// delete stale pending alert messages
 	deleteStalePendingAlertMessages(ctx)
 
+	// delete stale webhook deliveries
+	deleteStaleWebhookDeliveries(ctx)
+
 	// fetch each app's retention thresholds
 	appRetentions, err := fetchAppRetentions(ctx)
 	if err != nil {
@@ -84,12 +87,27 @@ func DeleteStaleData(ctx context.Context) {
 	// delete sessions
 	deleteSessions(ctx, appRetentions)
 
+	// delete installation activity
+	deleteInstallationActivity(ctx, appRetentions)
+
 	// delete journeys
 	deleteJourneys(ctx, appRetentions)
 
 	// delete spans
 	deleteSpans(ctx, appRetentions)
 
+	// delete profile stacks
+	deleteProfileStacks(ctx, appRetentions)
+
+	// delete symbolication coverage
+	deleteSymbolicationCoverage(ctx, appRetentions)
+
+	// delete heap dump reports
+	deleteHeapDumps(ctx, appRetentions)
+
+	// delete perfetto trace summaries
+	deleteTraceSummaries(ctx, appRetentions)
+
 	// delete http events
 	deleteHttpEvents(ctx, appRetentions)
 
@@ -435,6 +453,32 @@ func deleteSessions(ctx context.Context, retentions []AppRetention) {
 	}
 }
 
+// deleteInstallationActivity deletes stale installation
+// activity for each app's retention threshold.
+func deleteInstallationActivity(ctx context.Context, retentions []AppRetention) {
+	errCount := 0
+	for _, retention := range retentions {
+		stmt := sqlf.
+			DeleteFrom("installation_activity").
+			Where("team_id = toUUID(?)", retention.TeamID).
+			Where("app_id = toUUID(?)", retention.AppID).
+			Where("day < toDate(?)", retention.Threshold)
+
+		if err := server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+			errCount += 1
+			fmt.Printf("Failed to delete stale installation activity for app id %q: %v\n", retention.AppID, err)
+			stmt.Close()
+			continue
+		}
+
+		stmt.Close()
+	}
+
+	if errCount < 1 {
+		fmt.Println("Successfully deleted stale installation activity")
+	}
+}
+
 // deleteJourneys deletes stale journeys for each
 // app's retention threshold.
 func deleteJourneys(ctx context.Context, retentions []AppRetention) {
@@ -487,6 +531,58 @@ func deleteSpans(ctx context.Context, retentions []AppRetention) {
 	}
 }
 
+// deleteProfileStacks deletes stale profile stacks
+// for each app's retention threshold.
+func deleteProfileStacks(ctx context.Context, retentions []AppRetention) {
+	errCount := 0
+	for _, retention := range retentions {
+		stmt := sqlf.
+			DeleteFrom("profile_stacks").
+			Where("team_id = toUUID(?)", retention.TeamID).
+			Where("app_id = toUUID(?)", retention.AppID).
+			Where("timestamp < ?", retention.Threshold)
+
+		if err := server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+			errCount += 1
+			fmt.Printf("Failed to delete stale profile stacks for app id %q: %v\n", retention.AppID, err)
+			stmt.Close()
+			continue
+		}
+
+		stmt.Close()
+	}
+
+	if errCount < 1 {
+		fmt.Println("Successfully deleted stale profile stacks")
+	}
+}
+
+// deleteSymbolicationCoverage deletes stale symbolication
+// coverage for each app's retention threshold.
+func deleteSymbolicationCoverage(ctx context.Context, retentions []AppRetention) {
+	errCount := 0
+	for _, retention := range retentions {
+		stmt := sqlf.
+			DeleteFrom("symbolication_coverage").
+			Where("team_id = toUUID(?)", retention.TeamID).
+			Where("app_id = toUUID(?)", retention.AppID).
+			Where("timestamp < ?", retention.Threshold)
+
+		if err := server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+			errCount += 1
+			fmt.Printf("Failed to delete stale symbolication coverage for app id %q: %v\n", retention.AppID, err)
+			stmt.Close()
+			continue
+		}
+
+		stmt.Close()
+	}
+
+	if errCount < 1 {
+		fmt.Println("Successfully deleted stale symbolication coverage")
+	}
+}
+
 // deleteHttpEvents deletes stale http events for each
 // app's retention threshold.
 func deleteHttpEvents(ctx context.Context, retentions []AppRetention) {
@@ -778,6 +874,25 @@ func deleteStalePendingAlertMessages(ctx context.Context) {
 	fmt.Printf("Succesfully deleted stale pending alert messages\n")
 }
 
+// deleteStaleWebhookDeliveries deletes delivered or
+// failed webhook deliveries older than 30 days
+func deleteStaleWebhookDeliveries(ctx context.Context) {
+	threshold := time.Now().Add(-30 * 24 * time.Hour) // 30 days expiry
+	stmt := sqlf.PostgreSQL.DeleteFrom("webhook_deliveries").
+		Where("status <> ?", "pending").
+		Where("created_at < ?", threshold)
+
+	defer stmt.Close()
+
+	_, err := server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		fmt.Printf("Failed to delete stale webhook deliveries: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Successfully deleted stale webhook deliveries\n")
+}
+
 // fetchAppRetentions fetches retention period
 // for each app.
 func fetchAppRetentions(ctx context.Context) (retentions []AppRetention, err error) {
@@ -812,3 +927,55 @@ func fetchAppRetentions(ctx context.Context) (retentions []AppRetention, err err
 
 	return
 }
+
+// deleteHeapDumps deletes stale heap dump
+// reports for each app's retention threshold.
+func deleteHeapDumps(ctx context.Context, retentions []AppRetention) {
+	errCount := 0
+	for _, retention := range retentions {
+		stmt := sqlf.
+			DeleteFrom("heap_dumps").
+			Where("team_id = toUUID(?)", retention.TeamID).
+			Where("app_id = toUUID(?)", retention.AppID).
+			Where("timestamp < ?", retention.Threshold)
+
+		if err := server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+			errCount += 1
+			fmt.Printf("Failed to delete stale heap dumps for app id %q: %v\n", retention.AppID, err)
+			stmt.Close()
+			continue
+		}
+
+		stmt.Close()
+	}
+
+	if errCount < 1 {
+		fmt.Println("Successfully deleted stale heap dumps")
+	}
+}
+
+// deleteTraceSummaries deletes stale perfetto trace
+// summaries for each app's retention threshold.
+func deleteTraceSummaries(ctx context.Context, retentions []AppRetention) {
+	errCount := 0
+	for _, retention := range retentions {
+		stmt := sqlf.
+			DeleteFrom("trace_summaries").
+			Where("team_id = toUUID(?)", retention.TeamID).
+			Where("app_id = toUUID(?)", retention.AppID).
+			Where("timestamp < ?", retention.Threshold)
+
+		if err := server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+			errCount += 1
+			fmt.Printf("Failed to delete stale trace summaries for app id %q: %v\n", retention.AppID, err)
+			stmt.Close()
+			continue
+		}
+
+		stmt.Close()
+	}
+
+	if errCount < 1 {
+		fmt.Println("Successfully deleted stale trace summaries")
+	}
+}
@@ -0,0 +1,312 @@
+// Deadletter inspects and replays ingest batches the ingest
+// worker gave up on.
+//
+// drain moves dead letters off the dead-letter topic into a
+// directory, one JSON file per batch carrying the failure reason
+// and the batch itself. Fix up a batch by editing its file, then
+// replay it onto the ingest topic for the worker to process again:
+//
+//	deadletter drain ./dead
+//	deadletter replay ./dead/<batch-id>.json
+//
+// The bus is picked like the worker picks it on self-host: Iggy,
+// configured with IGGY_ADDR, IGGY_USERNAME and IGGY_PASSWORD, unless
+// BUS_BACKEND is postgres, configured with POSTGRES_DSN. Pass
+// -pubsub-project to use Pub/Sub instead.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"backend/ingest-worker/measure"
+	"backend/libs/bus"
+	"backend/libs/ingest"
+	"backend/libs/secret"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// letter is a dead letter as written to disk. The batch is
+// kept as JSON so it can be edited in place; payloads that
+// aren't JSON at all are kept in Raw.
+type letter struct {
+	Topic    string          `json:"topic"`
+	Attempts int             `json:"attempts"`
+	Reason   string          `json:"reason"`
+	FailedAt time.Time       `json:"failed_at"`
+	Batch    json.RawMessage `json:"batch,omitempty"`
+	Raw      []byte          `json:"raw,omitempty"`
+}
+
+var (
+	pubsubProject      = flag.String("pubsub-project", "", "GCP project to use Pub/Sub in, the self-hosted bus is used if empty")
+	pubsubSubscription = flag.String("pubsub-subscription", ingest.IngestBatchDeadLetterTopic, "Pub/Sub subscription of the dead-letter topic to drain")
+	idle               = flag.Duration("idle", 10*time.Second, "stop draining once no dead letter arrived for this long")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage:\n  deadletter [flags] drain <dir>\n  deadletter [flags] replay <file>...\n\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch args := flag.Args(); {
+	case len(args) == 2 && args[0] == "drain":
+		err = drain(ctx, args[1])
+	case len(args) >= 2 && args[0] == "replay":
+		err = replay(ctx, args[1:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// drain writes every dead letter on the dead-letter topic to
+// dir and acknowledges it, until none arrived for a while.
+func drain(ctx context.Context, dir string) (err error) {
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return
+	}
+
+	consumer, err := newConsumer(ctx)
+	if err != nil {
+		return
+	}
+	defer consumer.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var drained atomic.Int64
+	var last atomic.Int64
+	last.Store(time.Now().UnixNano())
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, last.Load())) > *idle {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	err = consumer.Listen(ctx, func(_ context.Context, data []byte) error {
+		last.Store(time.Now().UnixNano())
+
+		path, l, err := write(dir, data)
+		if err != nil {
+			return err
+		}
+
+		drained.Add(1)
+		fmt.Printf("%s\t%d attempts\t%s\n", path, l.Attempts, l.Reason)
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		err = nil
+	}
+
+	fmt.Printf("drained %d dead letters\n", drained.Load())
+
+	return
+}
+
+// write writes the dead letter to a file in dir named
+// after its batch.
+func write(dir string, data []byte) (path string, l letter, err error) {
+	dl, decodeErr := bus.DecodeDeadLetter(data)
+	if decodeErr != nil {
+		l = letter{Reason: decodeErr.Error(), FailedAt: time.Now().UTC(), Raw: data}
+	} else {
+		l = letter{
+			Topic:    dl.Topic,
+			Attempts: dl.Attempts,
+			Reason:   dl.Reason,
+			FailedAt: dl.FailedAt,
+		}
+		if json.Valid(dl.Data) {
+			l.Batch = dl.Data
+		} else {
+			l.Raw = dl.Data
+		}
+	}
+
+	content, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return
+	}
+
+	name := fmt.Sprintf("dead-letter-%d", l.FailedAt.UnixNano())
+	var ids struct {
+		BatchID string `json:"batch_id"`
+	}
+	if json.Unmarshal(l.Batch, &ids) == nil && ids.BatchID != "" {
+		name = filepath.Base(ids.BatchID)
+	}
+
+	// a batch can be dead-lettered again after a replay,
+	// keep every copy
+	for i := 0; ; i++ {
+		path = filepath.Join(dir, name+".json")
+		if i > 0 {
+			path = filepath.Join(dir, fmt.Sprintf("%s.%d.json", name, i))
+		}
+
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		if _, err = f.Write(content); err != nil {
+			f.Close()
+			return
+		}
+		err = f.Close()
+		return
+	}
+}
+
+// replay publishes the batches in the files to the
+// ingest topic.
+func replay(ctx context.Context, paths []string) (err error) {
+	batches := make([][]byte, len(paths))
+	for i, path := range paths {
+		if batches[i], err = read(path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	producer, err := newProducer(ctx)
+	if err != nil {
+		return
+	}
+	defer producer.Close()
+
+	for i, path := range paths {
+		if err = producer.Publish(ctx, batches[i]); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		fmt.Printf("%s\treplayed\n", path)
+	}
+
+	return
+}
+
+// read reads the batch out of a dead letter file
+// and checks it's still a well formed batch.
+func read(path string) (batch []byte, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var l letter
+	if err = json.Unmarshal(content, &l); err != nil {
+		return
+	}
+	if len(l.Batch) == 0 {
+		return nil, errors.New("no batch to replay, move a fixed up batch from raw to batch")
+	}
+
+	var b measure.IngestBatch
+	if err = json.Unmarshal(l.Batch, &b); err != nil {
+		return nil, fmt.Errorf("invalid batch: %w", err)
+	}
+	for name, id := range map[string]string{"batch_id": b.BatchID, "app_id": b.AppID, "team_id": b.TeamID} {
+		if _, err = uuid.Parse(id); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", name, id, err)
+		}
+	}
+
+	return json.Marshal(l.Batch)
+}
+
+// connectPostgres connects to the Postgres
+// backing the bus.
+func connectPostgres(ctx context.Context) (*pgxpool.Pool, error) {
+	dsn, err := secret.FromEnvOrFile("POSTGRES_DSN")
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.New(ctx, dsn)
+}
+
+func newConsumer(ctx context.Context) (bus.Consumer, error) {
+	if *pubsubProject != "" {
+		return bus.NewPubSubConsumer(ctx, *pubsubSubscription, bus.WithPubSubProjectID(*pubsubProject))
+	}
+
+	if bus.SelfHostBackend() == bus.BackendPostgres {
+		pool, err := connectPostgres(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return bus.NewPostgresConsumer(pool, ingest.IngestBatchDeadLetterTopic)
+	}
+
+	return bus.NewIggyGroupConsumer(
+		os.Getenv("IGGY_ADDR"),
+		os.Getenv("IGGY_USERNAME"),
+		os.Getenv("IGGY_PASSWORD"),
+		ingest.IngestBatchDeadLetterTopic+"-consumer",
+		bus.DefaultStreamName,
+		ingest.IngestBatchDeadLetterTopic,
+	)
+}
+
+func newProducer(ctx context.Context) (bus.Producer, error) {
+	if *pubsubProject != "" {
+		return bus.NewPubSubProducer(ctx, ingest.IngestBatchTopic, bus.WithPubSubProjectID(*pubsubProject))
+	}
+
+	if bus.SelfHostBackend() == bus.BackendPostgres {
+		pool, err := connectPostgres(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return bus.NewPostgresProducer(pool, ingest.IngestBatchTopic)
+	}
+
+	return bus.NewIggyProducer(
+		os.Getenv("IGGY_ADDR"),
+		os.Getenv("IGGY_USERNAME"),
+		os.Getenv("IGGY_PASSWORD"),
+		"deadletter",
+		bus.DefaultStreamName,
+		ingest.IngestBatchTopic,
+	)
+}
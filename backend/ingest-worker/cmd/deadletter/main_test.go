@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"backend/libs/bus"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	dir := t.TempDir()
+	batch := `{"batch_id":"0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01","app_id":"6f1c2d3e-4a5b-4c6d-8e7f-901a2b3c4d5e","team_id":"9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d","events":[],"spans":[]}`
+
+	data, err := json.Marshal(bus.DeadLetter{
+		Topic:    "ingest-batch",
+		Attempts: 5,
+		Reason:   "failed to lookup app: not found",
+		FailedAt: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC),
+		Data:     []byte(batch),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01.json", "0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01.1.json"} {
+		path, l, err := write(dir, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(path) != want {
+			t.Errorf("path = %q, want %q", filepath.Base(path), want)
+		}
+		if l.Attempts != 5 || l.Reason != "failed to lookup app: not found" {
+			t.Errorf("letter = %+v, want the dead letter's attempts and reason", l)
+		}
+	}
+
+	got, err := read(filepath.Join(dir, "0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != batch {
+		t.Errorf("batch = %s, want %s", got, batch)
+	}
+}
+
+func TestReadRejectsBrokenBatches(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, content := range map[string]string{
+		"raw":        `{"reason":"bad payload","raw":"bm90IGpzb24="}`,
+		"bad app id": `{"batch":{"batch_id":"0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01","app_id":"nope","team_id":"9a8b7c6d-5e4f-4a3b-8c2d-1e0f9a8b7c6d"}}`,
+		"bad events": `{"batch":{"batch_id":"0b5e3c4e-1d2a-4b1c-9f3e-2a6d7c8e9f01","events":"nope"}}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := read(path); err == nil {
+			t.Errorf("%s: read succeeded, want an error", name)
+		}
+	}
+
+	if _, err := read(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file err = %v, want os.ErrNotExist", err)
+	}
+}
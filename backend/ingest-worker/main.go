@@ -84,6 +84,11 @@ func main() {
 		Handler: r,
 	}
 
+	// Close the dead-letter producer after the consumer
+	if server.Server.DeadLetter != nil {
+		defer server.Server.DeadLetter.Close()
+	}
+
 	// Start bus consumer if initialized
 	if server.Server.BusConsumer != nil {
 		defer server.Server.BusConsumer.Close()
@@ -96,6 +101,69 @@ func main() {
 		}()
 	}
 
+	// Close the profile, heap dump, perfetto trace &
+	// resymbolicate producers after the consumers
+	if server.Server.ProfileProducer != nil {
+		defer server.Server.ProfileProducer.Close()
+	}
+	if server.Server.HeapDumpProducer != nil {
+		defer server.Server.HeapDumpProducer.Close()
+	}
+	if server.Server.PerfettoTraceProducer != nil {
+		defer server.Server.PerfettoTraceProducer.Close()
+	}
+	if server.Server.ResymbolicateProducer != nil {
+		defer server.Server.ResymbolicateProducer.Close()
+	}
+
+	// Start profile consumer if initialized
+	if server.Server.ProfileConsumer != nil {
+		defer server.Server.ProfileConsumer.Close()
+
+		go func() {
+			fmt.Println("profile consumer listening")
+			if err := server.Server.ProfileConsumer.Listen(appCtx, measure.ConsumeProfileHandler); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("profile consumer stopped: %v\n", err)
+			}
+		}()
+	}
+
+	// Start heap dump consumer if initialized
+	if server.Server.HeapDumpConsumer != nil {
+		defer server.Server.HeapDumpConsumer.Close()
+
+		go func() {
+			fmt.Println("heap dump consumer listening")
+			if err := server.Server.HeapDumpConsumer.Listen(appCtx, measure.ConsumeHeapDumpHandler); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("heap dump consumer stopped: %v\n", err)
+			}
+		}()
+	}
+
+	// Start perfetto trace consumer if initialized
+	if server.Server.PerfettoTraceConsumer != nil {
+		defer server.Server.PerfettoTraceConsumer.Close()
+
+		go func() {
+			fmt.Println("perfetto trace consumer listening")
+			if err := server.Server.PerfettoTraceConsumer.Listen(appCtx, measure.ConsumePerfettoTraceHandler); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("perfetto trace consumer stopped: %v\n", err)
+			}
+		}()
+	}
+
+	// Start resymbolicate consumer if initialized
+	if server.Server.ResymbolicateConsumer != nil {
+		defer server.Server.ResymbolicateConsumer.Close()
+
+		go func() {
+			fmt.Println("resymbolicate consumer listening")
+			if err := server.Server.ResymbolicateConsumer.Listen(appCtx, measure.ConsumeResymbolicateHandler); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("resymbolicate consumer stopped: %v\n", err)
+			}
+		}()
+	}
+
 	// Run server in a goroutine
 	go func() {
 		fmt.Printf("Listening and serving HTTP on %s\n", srv.Addr)
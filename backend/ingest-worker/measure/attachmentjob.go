@@ -0,0 +1,145 @@
+package measure
+
+import (
+	"backend/ingest-worker/server"
+	"backend/libs/bus"
+	"backend/libs/event"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// attachmentUploadWait is how long a queued attachment job
+// waits for its attachment to finish uploading, before it's
+// given up on.
+const attachmentUploadWait = 24 * time.Hour
+
+// errAttachmentDropped is returned when an attachment job
+// can't ever be processed, like when its attachment was
+// never uploaded or is too large.
+var errAttachmentDropped = errors.New("attachment dropped")
+
+// attachmentJob is an event's attachment queued for
+// processing after the event's batch was ingested.
+// Profiles, heap dumps & perfetto traces embed it.
+type attachmentJob struct {
+	TeamID      uuid.UUID        `json:"team_id"`
+	AppID       uuid.UUID        `json:"app_id"`
+	EventID     uuid.UUID        `json:"event_id"`
+	EventType   string           `json:"event_type"`
+	SessionID   uuid.UUID        `json:"session_id"`
+	Timestamp   time.Time        `json:"timestamp"`
+	AppVersion  string           `json:"app_version"`
+	AppBuild    string           `json:"app_build"`
+	AppUniqueID string           `json:"app_unique_id"`
+	OSName      string           `json:"os_name"`
+	Attachment  event.Attachment `json:"attachment"`
+	QueuedAt    time.Time        `json:"queued_at"`
+}
+
+// newAttachmentJob creates the job for the
+// event's attachment queued at now.
+func (e eventreq) newAttachmentJob(ev event.EventField, a event.Attachment, now time.Time) attachmentJob {
+	return attachmentJob{
+		TeamID:      e.teamId,
+		AppID:       e.appId,
+		EventID:     ev.ID,
+		EventType:   ev.Type,
+		SessionID:   ev.SessionID,
+		Timestamp:   ev.Timestamp,
+		AppVersion:  ev.Attribute.AppVersion,
+		AppBuild:    ev.Attribute.AppBuild,
+		AppUniqueID: ev.Attribute.AppUniqueID,
+		OSName:      ev.Attribute.OSName,
+		Attachment:  a,
+		QueuedAt:    now,
+	}
+}
+
+// getAttachmentJobs gets a job for the first attachment
+// of each event that matches.
+func (e eventreq) getAttachmentJobs(matches func(a event.Attachment) bool) (jobs []attachmentJob) {
+	now := time.Now()
+	for _, ev := range e.events {
+		for _, a := range ev.Attachments {
+			if !matches(a) {
+				continue
+			}
+
+			jobs = append(jobs, e.newAttachmentJob(ev, a, now))
+			break
+		}
+	}
+
+	return
+}
+
+// queueAttachmentJobs publishes the jobs to the producer.
+// Does nothing when the producer isn't set up.
+func queueAttachmentJobs[T any](ctx context.Context, producer bus.Producer, jobs []T) error {
+	if producer == nil {
+		return nil
+	}
+
+	for _, job := range jobs {
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+
+		if err := producer.Publish(ctx, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// read downloads the job's attachment, of at most maxSize
+// bytes. Larger attachments are dropped, before downloading
+// when the event reports the attachment's size. Reading an
+// attachment that hasn't finished uploading fails, so the
+// consumer retries it later, until attachmentUploadWait has
+// passed since the job was queued.
+//
+// Returns an error wrapping errAttachmentDropped when the
+// job should be dropped.
+func (j attachmentJob) read(ctx context.Context, maxSize int64) (raw []byte, err error) {
+	if j.Attachment.Size > uint64(maxSize) {
+		return nil, fmt.Errorf("%w: its %d bytes exceed the limit of %d", errAttachmentDropped, j.Attachment.Size, maxSize)
+	}
+
+	config := server.Server.Config
+	rc, err := j.Attachment.Open(ctx, event.DownloadConfig{
+		IsCloud:                    config.IsCloud(),
+		AWSEndpoint:                config.AWSEndpoint,
+		AttachmentsBucket:          config.AttachmentsBucket,
+		AttachmentsBucketRegion:    config.AttachmentsBucketRegion,
+		AttachmentsAccessKey:       config.AttachmentsAccessKey,
+		AttachmentsSecretAccessKey: config.AttachmentsSecretAccessKey,
+	})
+	if errors.Is(err, event.ErrAttachmentNotFound) && time.Since(j.QueuedAt) > attachmentUploadWait {
+		return nil, fmt.Errorf("%w: attachment %q was never uploaded", errAttachmentDropped, j.Attachment.Key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open attachment %q: %w", j.Attachment.Key, err)
+	}
+	defer rc.Close()
+
+	// events don't always report the attachment's
+	// size, so reading stops past the limit too
+	raw, err = io.ReadAll(io.LimitReader(rc, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment %q: %w", j.Attachment.Key, err)
+	}
+	if int64(len(raw)) > maxSize {
+		return nil, fmt.Errorf("%w: it exceeds the limit of %d bytes", errAttachmentDropped, maxSize)
+	}
+
+	return
+}
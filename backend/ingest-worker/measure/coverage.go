@@ -0,0 +1,50 @@
+package measure
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/ingest-worker/server"
+	"backend/libs/chrono"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/leporo/sqlf"
+)
+
+// ingestCoverage writes the symbolication coverage of
+// the batch's errors, one row per app version. Rows are
+// keyed by the batch, so a retried batch replaces its
+// earlier rows.
+func (e eventreq) ingestCoverage(ctx context.Context) error {
+	if len(e.coverage) == 0 {
+		return nil
+	}
+
+	stmt := sqlf.InsertInto(`symbolication_coverage`)
+	defer stmt.Close()
+
+	now := time.Now().Format(chrono.MSTimeFormat)
+
+	for _, cov := range e.coverage {
+		appVersionTuple := fmt.Sprintf("('%s', '%s')", cov.VersionName, cov.VersionCode)
+
+		missing := cov.MissingDebugIDs
+		if missing == nil {
+			missing = []string{}
+		}
+
+		stmt.NewRow().
+			Set(`team_id`, e.teamId).
+			Set(`app_id`, e.appId).
+			Set(`batch_id`, e.id).
+			Set(`timestamp`, now).
+			Set(`app_version`, appVersionTuple).
+			Set(`frames`, cov.Frames).
+			Set(`symbolicated_frames`, cov.Symbolicated).
+			Set(`missing_debug_ids`, missing)
+	}
+
+	asyncCtx := clickhouse.Context(ctx, clickhouse.WithAsync(true))
+	return server.Server.ChPool.Exec(asyncCtx, stmt.String(), stmt.Args()...)
+}
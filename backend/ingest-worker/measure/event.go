@@ -4,6 +4,7 @@ import (
 	"backend/ingest-worker/server"
 	"backend/ingest-worker/symbolicator"
 	"backend/libs/ambient"
+	"backend/libs/bus"
 	"backend/libs/chrono"
 	"backend/libs/event"
 	"backend/libs/group"
@@ -83,6 +84,9 @@ type eventreq struct {
 	// size is the byte size of the original
 	// ingest request payload
 	size uint64
+	// coverage is the symbolication coverage
+	// of the batch's errors per app version
+	coverage []symbolicator.Coverage
 }
 
 // checkSeen checks & remembers if this request batch was
@@ -269,6 +273,7 @@ func (e eventreq) getANRs() (events []event.EventField) {
 // bucketExceptions groups exceptions based on similarity.
 func (e eventreq) bucketExceptions(ctx context.Context) (err error) {
 	events := e.getExceptions()
+	var sightings []group.Sighting
 
 	for i := range events {
 		if events[i].Exception.Fingerprint == "" {
@@ -296,14 +301,24 @@ func (e eventreq) bucketExceptions(ctx context.Context) (err error) {
 		if err = exceptionGroup.Insert(ctx, server.Server.ChPool); err != nil {
 			return
 		}
+
+		kind := group.KindNonfatal
+		if exceptionGroup.Fatal {
+			kind = group.KindFatal
+		}
+
+		sightings = append(sightings, e.newSighting(events[i], kind, exceptionGroup.ID))
 	}
 
+	e.trackReleases(ctx, sightings)
+
 	return
 }
 
 // bucketANRs groups ANRs based on similarity.
 func (e eventreq) bucketANRs(ctx context.Context) (err error) {
 	events := e.getANRs()
+	var sightings []group.Sighting
 
 	for i := range events {
 		if events[i].ANR.Fingerprint == "" {
@@ -328,11 +343,101 @@ func (e eventreq) bucketANRs(ctx context.Context) (err error) {
 		if err := anrGroup.Insert(ctx, server.Server.ChPool); err != nil {
 			return err
 		}
+
+		sightings = append(sightings, e.newSighting(events[i], group.KindANR, anrGroup.ID))
 	}
 
+	e.trackReleases(ctx, sightings)
+
 	return
 }
 
+// newSighting creates a sighting of an error
+// group from the event bucketed in it.
+func (e eventreq) newSighting(ev event.EventField, kind group.Kind, id string) group.Sighting {
+	return group.Sighting{
+		TeamID:      e.teamId,
+		AppID:       ev.AppID,
+		Kind:        kind,
+		ID:          id,
+		Version:     ev.Attribute.AppVersion,
+		VersionCode: ev.Attribute.AppBuild,
+		Timestamp:   ev.Timestamp,
+		Count:       1,
+	}
+}
+
+// trackReleases moves the release and triage state
+// of the error groups sighted in the batch along.
+// Tracking is best effort, failures are logged and
+// don't fail the batch.
+func (e eventreq) trackReleases(ctx context.Context, sightings []group.Sighting) {
+	if len(sightings) == 0 {
+		return
+	}
+
+	ids := make([]string, len(sightings))
+	for i := range sightings {
+		ids[i] = sightings[i].ID
+	}
+
+	// sightings of merged groups count
+	// towards their target groups
+	merges, err := group.GetMerges(ctx, server.Server.PgPool, e.appId, ids...)
+	if err != nil {
+		fmt.Printf("failed to get error group merges of app %q: %v\n", e.appId, err)
+		return
+	}
+
+	for i := range sightings {
+		if targetID, ok := merges[sightings[i].ID]; ok {
+			sightings[i].ID = targetID
+		}
+	}
+
+	sightings = group.DedupSightings(sightings)
+
+	entered, err := group.TrackReleases(ctx, server.Server.PgPool, server.Server.ChPool, sightings)
+	if err != nil {
+		fmt.Printf("failed to track releases of error groups of app %q: %v\n", e.appId, err)
+	}
+
+	for i, state := range entered {
+		if state != "" {
+			s := sightings[i]
+			fmt.Printf("%s group %q is %s in %s (%s)\n", s.Kind, s.ID, state, s.Version, s.VersionCode)
+		}
+	}
+
+	reopened, err := group.TrackStates(ctx, server.Server.PgPool, server.Server.ChPool, sightings)
+	if err != nil {
+		fmt.Printf("failed to track states of error groups of app %q: %v\n", e.appId, err)
+	}
+
+	for i, reason := range reopened {
+		if reason != "" {
+			s := sightings[i]
+			fmt.Printf("%s group %q reopened: %s\n", s.Kind, s.ID, reason)
+		}
+	}
+}
+
+// getFingerprintRules fetches and compiles the app's
+// fingerprint rules when the batch has errors to
+// fingerprint.
+func (e eventreq) getFingerprintRules(ctx context.Context) (rules *event.FingerprintRules, err error) {
+	if !e.hasExceptions() && !e.hasANRs() {
+		return
+	}
+
+	appRules, err := group.GetFingerprintRules(ctx, server.Server.PgPool, e.appId)
+	if err != nil {
+		return
+	}
+
+	return group.CompileFingerprintRules(appRules)
+}
+
 // needsSymbolication returns true if payload
 // contains events that should be symbolicated.
 func (e eventreq) needsSymbolication() bool {
@@ -412,6 +517,11 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 		return nil
 	}
 
+	rules, err := e.getFingerprintRules(ctx)
+	if err != nil {
+		return err
+	}
+
 	stmt := sqlf.InsertInto(`events`)
 	defer stmt.Close()
 
@@ -436,7 +546,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 				return err
 			}
 			anrThreads = string(marshalledThreads)
-			if err := e.events[i].ANR.ComputeFingerprint(); err != nil {
+			if err := e.events[i].ANR.ComputeFingerprintWithRules(rules); err != nil {
 				return err
 			}
 		}
@@ -452,7 +562,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 				return err
 			}
 			exceptionThreads = string(marshalledThreads)
-			if err := e.events[i].Exception.ComputeFingerprint(); err != nil {
+			if err := e.events[i].Exception.ComputeFingerprintWithRules(rules); err != nil {
 				return err
 			}
 
@@ -1165,6 +1275,72 @@ func mintSymboloaderToken(ctx context.Context, config *server.ServerConfig) (tok
 	return tok.AccessToken, nil
 }
 
+// newSymbolicator builds a symbolicator for the os
+// with the symbol sources the deployment provides.
+func newSymbolicator(ctx context.Context, config *server.ServerConfig, osName string) (s *symbolicator.Symbolicator) {
+	origin := config.SymbolicatorOrigin
+	sources := []symbolicator.Source{}
+	var sentrySources []symbolicator.SentrySource
+
+	// Mint the symboloader bearer token once per symbolicator. The same
+	// audience-scoped token authorizes both the symbol file (/symbols)
+	// and JS (/symbols/js) lookups, so it is computed here rather
+	// than per-OS. Stays empty on a cloud minting failure; the
+	// sources below skip rather than send an invalid token.
+	var symboloaderTok string
+	if config.SymboloaderOrigin != "" {
+		if tok, tokErr := mintSymboloaderToken(ctx, config); tokErr != nil {
+			fmt.Printf("failed to obtain symboloader token: %v\n", tokErr)
+		} else {
+			symboloaderTok = tok
+		}
+	}
+
+	switch opsys.ToFamily(osName) {
+	case opsys.Android:
+		if config.IsCloud() {
+			creds, err := getGCSCreds()
+			if err != nil {
+				fmt.Printf("failed to obtain credentials for GCS Android source: %v\n", err)
+			} else if tok, err := creds.Token(ctx); err != nil {
+				fmt.Printf("failed to generate token for GCS Android source: %v\n", err)
+			} else {
+				sources = append(sources, symbolicator.NewGCSSourceAndroid("msr-symbols", config.SymbolsBucket, tok.Value))
+			}
+		} else {
+			sources = append(sources, symbolicator.NewS3SourceAndroid("msr-symbols", config.SymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
+		}
+		if symboloaderTok != "" {
+			sentrySources = append(sentrySources, symbolicator.NewSentrySource("msr-symbols-sentry", config.SymboloaderOrigin+"/symbols", symboloaderTok))
+		}
+	case opsys.AppleFamily:
+		if config.IsCloud() {
+			creds, err := getGCSCreds()
+			if err != nil {
+				fmt.Printf("failed to obtain credentials for GCS Apple source: %v\n", err)
+			} else if tok, err := creds.Token(ctx); err != nil {
+				fmt.Printf("failed to generate token for GCS Apple source: %v\n", err)
+			} else {
+				sources = append(sources, symbolicator.NewGCSSourceApple("msr-symbols", config.SymbolsBucket, tok.Value))
+				if config.SystemSymbolsBucket != "" {
+					sources = append(sources, symbolicator.NewGCSSourceApple("msr-system-symbols", config.SystemSymbolsBucket, tok.Value))
+				}
+			}
+		} else {
+			sources = append(sources, symbolicator.NewS3SourceApple("msr-symbols", config.SymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
+			if config.SystemSymbolsBucket != "" {
+				sources = append(sources, symbolicator.NewS3SourceApple("msr-system-symbols", config.SystemSymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
+			}
+		}
+	}
+
+	s = symbolicator.New(origin, osName, sources, sentrySources)
+	s.SymboloaderOrigin = config.SymboloaderOrigin
+	s.SymboloaderToken = symboloaderTok
+
+	return
+}
+
 // PushHandler handles incoming Pub/Sub push messages containing
 // ingest batches published by the ingest service.
 func PushHandler(c *gin.Context) {
@@ -1197,11 +1373,13 @@ func PushHandler(c *gin.Context) {
 
 // ConsumeHandler is the bus.Consumer handler for message-based ingestion.
 // It processes the batch synchronously so that returning an error causes the
-// consumer to nack/skip-commit the message for redelivery.
+// consumer to nack/skip-commit the message for redelivery. Errors no
+// redelivery can fix are marked permanent so the batch is dead-lettered
+// right away.
 func ConsumeHandler(ctx context.Context, data []byte) error {
 	var batch IngestBatch
 	if err := json.Unmarshal(data, &batch); err != nil {
-		return fmt.Errorf("failed to unmarshal ingest batch: %w", err)
+		return bus.Permanent(fmt.Errorf("failed to unmarshal ingest batch: %w", err))
 	}
 	return processIngestBatchSync(ctx, batch)
 }
@@ -1238,15 +1416,15 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 	ingestBatchUnackCount.Add(ctx, 1)
 	batchID, err := uuid.Parse(batch.BatchID)
 	if err != nil {
-		return fmt.Errorf("failed to parse batch id: %w", err)
+		return bus.Permanent(fmt.Errorf("failed to parse batch id: %w", err))
 	}
 	appID, err := uuid.Parse(batch.AppID)
 	if err != nil {
-		return fmt.Errorf("failed to parse app id: %w", err)
+		return bus.Permanent(fmt.Errorf("failed to parse app id: %w", err))
 	}
 	teamID, err := uuid.Parse(batch.TeamID)
 	if err != nil {
-		return fmt.Errorf("failed to parse team id: %w", err)
+		return bus.Permanent(fmt.Errorf("failed to parse team id: %w", err))
 	}
 
 	app, err := SelectApp(ctx, appID)
@@ -1254,7 +1432,7 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 		return fmt.Errorf("failed to lookup app: %w", err)
 	}
 	if app == nil {
-		return fmt.Errorf("failed to lookup app: not found")
+		return bus.Permanent(fmt.Errorf("failed to lookup app: not found"))
 	}
 
 	eventReq := &eventreq{
@@ -1272,7 +1450,7 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 	// Rebuild exceptionIds, anrIds and symbolication lookups by scanning events.
 	for i := range eventReq.events {
 		eventReq.events[i].AppID = appID
-		if eventReq.events[i].NeedsSymbolication() {
+		if eventReq.events[i].NeedsSymbolication() || symbolicator.NeedsDeobfuscation(eventReq.events[i]) {
 			eventReq.symbolicateEvents[eventReq.events[i].ID] = i
 		}
 		if eventReq.events[i].IsException() {
@@ -1297,7 +1475,7 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 	// FIXME: This should be refactored, validate should not have such
 	// side effects.
 	if err := eventReq.validate(); err != nil {
-		return fmt.Errorf("failed to validate batch: %w", err)
+		return bus.Permanent(fmt.Errorf("failed to validate batch: %w", err))
 	}
 
 	// Check idempotency — both Pub/Sub and Iggy deliver at-least-once.
@@ -1331,72 +1509,14 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 			return nil
 		}
 
-		config := server.Server.Config
-		origin := config.SymbolicatorOrigin
-		osName := eventReq.osName
-		sources := []symbolicator.Source{}
-		var sentrySources []symbolicator.SentrySource
-
-		// Mint the symboloader bearer token once per batch. The same
-		// audience-scoped token authorizes both the ProGuard (/symbols)
-		// and JS (/symbols/js) lookups, so it is computed here rather
-		// than per-OS. Stays empty on a cloud minting failure; the
-		// sources below skip rather than send an invalid token.
-		var symboloaderTok string
-		if config.SymboloaderOrigin != "" {
-			if tok, tokErr := mintSymboloaderToken(ingestCtx, config); tokErr != nil {
-				fmt.Printf("failed to obtain symboloader token: %v\n", tokErr)
-			} else {
-				symboloaderTok = tok
-			}
-		}
-
-		switch opsys.ToFamily(osName) {
-		case opsys.Android:
-			if config.IsCloud() {
-				creds, err := getGCSCreds()
-				if err != nil {
-					fmt.Printf("failed to obtain credentials for GCS Android source: %v\n", err)
-				} else if tok, err := creds.Token(ingestCtx); err != nil {
-					fmt.Printf("failed to generate token for GCS Android source: %v\n", err)
-				} else {
-					sources = append(sources, symbolicator.NewGCSSourceAndroid("msr-symbols", config.SymbolsBucket, tok.Value))
-				}
-			} else {
-				sources = append(sources, symbolicator.NewS3SourceAndroid("msr-symbols", config.SymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
-			}
-			if symboloaderTok != "" {
-				sentrySources = append(sentrySources, symbolicator.NewSentrySource("msr-symbols-sentry", config.SymboloaderOrigin+"/symbols", symboloaderTok))
-			}
-		case opsys.AppleFamily:
-			if config.IsCloud() {
-				creds, err := getGCSCreds()
-				if err != nil {
-					fmt.Printf("failed to obtain credentials for GCS Apple source: %v\n", err)
-				} else if tok, err := creds.Token(ingestCtx); err != nil {
-					fmt.Printf("failed to generate token for GCS Apple source: %v\n", err)
-				} else {
-					sources = append(sources, symbolicator.NewGCSSourceApple("msr-symbols", config.SymbolsBucket, tok.Value))
-					if config.SystemSymbolsBucket != "" {
-						sources = append(sources, symbolicator.NewGCSSourceApple("msr-system-symbols", config.SystemSymbolsBucket, tok.Value))
-					}
-				}
-			} else {
-				sources = append(sources, symbolicator.NewS3SourceApple("msr-symbols", config.SymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
-				if config.SystemSymbolsBucket != "" {
-					sources = append(sources, symbolicator.NewS3SourceApple("msr-system-symbols", config.SystemSymbolsBucket, config.SymbolsBucketRegion, config.AWSEndpoint, config.SymbolsAccessKey, config.SymbolsSecretAccessKey))
-				}
-			}
-		}
-
-		symblctr := symbolicator.New(origin, osName, sources, sentrySources)
-		symblctr.SymboloaderOrigin = config.SymboloaderOrigin
-		symblctr.SymboloaderToken = symboloaderTok
+		symblctr := newSymbolicator(ingestCtx, server.Server.Config, eventReq.osName)
 
 		_, symbolicationSpan := ingestTracer.Start(ingestCtx, "symbolicate-events")
 		defer symbolicationSpan.End()
 
-		if err := symblctr.Symbolicate(ingestCtx, server.Server.PgPool, eventReq.appId, eventReq.events, eventReq.spans); err != nil {
+		err := symblctr.Symbolicate(ingestCtx, server.Server.PgPool, eventReq.appId, eventReq.events, eventReq.spans)
+		eventReq.coverage = symblctr.Coverage()
+		if err != nil {
 			fmt.Printf("failed to symbolicate batch %q containing %d events & %d spans: %v\n", eventReq.id, len(eventReq.events), len(eventReq.spans), err.Error())
 			return err
 		}
@@ -1430,6 +1550,17 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 		}
 		return nil
 	})
+	ingestGroup.Go(func() error {
+		_, ingestCoverageSpan := ingestTracer.Start(ingestCtx, "ingest-coverage")
+		defer ingestCoverageSpan.End()
+		// coverage only feeds diagnostics, so
+		// failing to write it doesn't fail the
+		// batch
+		if err := eventReq.ingestCoverage(ingestCtx); err != nil {
+			fmt.Println(`failed to ingest symbolication coverage`, err)
+		}
+		return nil
+	})
 	if err := ingestGroup.Wait(); err != nil {
 		return fmt.Errorf("failed to ingest: %w", err)
 	}
@@ -1490,6 +1621,27 @@ func processIngestBatchSync(ctx context.Context, batch IngestBatch) error {
 		return fmt.Errorf("failed to count ingestion metrics: %w", err)
 	}
 
+	_, queueProfilesSpan := ingestTracer.Start(ingestCtx, "queue-profiles")
+	err = eventReq.queueProfiles(ingestCtx)
+	queueProfilesSpan.End()
+	if err != nil {
+		return fmt.Errorf("failed to queue profiles: %w", err)
+	}
+
+	_, queueHeapDumpsSpan := ingestTracer.Start(ingestCtx, "queue-heap-dumps")
+	err = eventReq.queueHeapDumps(ingestCtx)
+	queueHeapDumpsSpan.End()
+	if err != nil {
+		return fmt.Errorf("failed to queue heap dumps: %w", err)
+	}
+
+	_, queuePerfettoTracesSpan := ingestTracer.Start(ingestCtx, "queue-perfetto-traces")
+	err = eventReq.queuePerfettoTraces(ingestCtx)
+	queuePerfettoTracesSpan.End()
+	if err != nil {
+		return fmt.Errorf("failed to queue perfetto traces: %w", err)
+	}
+
 	_, rememberIngestSpan := ingestTracer.Start(ingestCtx, "remember-ingest")
 	defer rememberIngestSpan.End()
 
@@ -0,0 +1,145 @@
+package measure
+
+import (
+	"backend/ingest-worker/server"
+	"backend/libs/bus"
+	"backend/libs/chrono"
+	"backend/libs/event"
+	"backend/libs/heapdump"
+	"backend/libs/opsys"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/leporo/sqlf"
+)
+
+// heapDumpJob is a heap dump
+// queued for analysis.
+type heapDumpJob struct {
+	attachmentJob
+}
+
+// getHeapDumps gets the heap dump jobs of
+// the events having a heap dump attachment.
+func (e eventreq) getHeapDumps() (jobs []heapDumpJob) {
+	for _, j := range e.getAttachmentJobs(event.Attachment.IsHeapDump) {
+		jobs = append(jobs, heapDumpJob{j})
+	}
+
+	return
+}
+
+// queueHeapDumps queues the batch's
+// heap dumps for analysis.
+func (e eventreq) queueHeapDumps(ctx context.Context) error {
+	return queueAttachmentJobs(ctx, server.Server.HeapDumpProducer, e.getHeapDumps())
+}
+
+// ConsumeHeapDumpHandler is the bus.Consumer handler for queued
+// heap dumps. It analyzes the heap dump's attachment for leak
+// suspects, de-obfuscates its class names & stores its report.
+//
+// A heap dump whose attachment hasn't finished uploading fails,
+// so the consumer retries it later. Heap dumps larger than the
+// configured maximum or that can't be parsed are dropped.
+func ConsumeHeapDumpHandler(ctx context.Context, data []byte) error {
+	var job heapDumpJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return bus.Permanent(fmt.Errorf("failed to unmarshal heap dump job: %w", err))
+	}
+
+	config := server.Server.Config
+	raw, err := job.read(ctx, config.HeapDumpMaxSize)
+	if errors.Is(err, errAttachmentDropped) {
+		fmt.Printf("dropping heap dump %q: %v\n", job.EventID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read heap dump %q: %w", job.EventID, err)
+	}
+
+	report, err := heapdump.Analyze(raw)
+	if err != nil {
+		fmt.Printf("dropping heap dump %q: %v\n", job.EventID, err)
+		return nil
+	}
+
+	if opsys.ToFamily(job.OSName) == opsys.Android {
+		symblctr := newSymbolicator(ctx, config, job.OSName)
+		rewrites, err := symblctr.SymbolicateClasses(ctx, server.Server.PgPool, job.AppID, job.AppVersion, job.AppBuild, report.ClassNames())
+		if err != nil {
+			fmt.Printf("failed to symbolicate heap dump %q: %v\n", job.EventID, err)
+		}
+		report.Rewrite(rewrites)
+	}
+
+	return job.ingest(ctx, report)
+}
+
+// ingest writes the heap dump's
+// report to database.
+func (j heapDumpJob) ingest(ctx context.Context, r heapdump.Report) (err error) {
+	classNames := make([]string, len(r.Classes))
+	classInstances := make([]uint64, len(r.Classes))
+	classShallowSizes := make([]uint64, len(r.Classes))
+	classRetainedSizes := make([]uint64, len(r.Classes))
+	for i, c := range r.Classes {
+		classNames[i] = c.ClassName
+		classInstances[i] = c.Instances
+		classShallowSizes[i] = c.ShallowSize
+		classRetainedSizes[i] = c.RetainedSize
+	}
+
+	leakKinds := make([]string, len(r.Leaks))
+	leakClassNames := make([]string, len(r.Leaks))
+	leakInstances := make([]uint64, len(r.Leaks))
+	leakRetainedSizes := make([]uint64, len(r.Leaks))
+	leakElements := make([]uint64, len(r.Leaks))
+	leakPathClasses := make([][]string, len(r.Leaks))
+	leakPathNames := make([][]string, len(r.Leaks))
+	for i, l := range r.Leaks {
+		leakKinds[i] = l.Kind
+		leakClassNames[i] = l.ClassName
+		leakInstances[i] = l.Instances
+		leakRetainedSizes[i] = l.RetainedSize
+		leakElements[i] = l.Elements
+		leakPathClasses[i] = make([]string, len(l.Path))
+		leakPathNames[i] = make([]string, len(l.Path))
+		for j, ref := range l.Path {
+			leakPathClasses[i][j] = ref.ClassName
+			leakPathNames[i][j] = ref.Name
+		}
+	}
+
+	appVersionTuple := fmt.Sprintf("('%s', '%s')", j.AppVersion, j.AppBuild)
+
+	stmt := sqlf.InsertInto(`heap_dumps`).
+		Set(`team_id`, j.TeamID).
+		Set(`app_id`, j.AppID).
+		Set(`event_id`, j.EventID).
+		Set(`event_type`, j.EventType).
+		Set(`session_id`, j.SessionID).
+		Set(`timestamp`, j.Timestamp.Format(chrono.MSTimeFormat)).
+		Set(`app_version`, appVersionTuple).
+		Set(`heap_size`, r.HeapSize).
+		Set(`objects`, r.Objects).
+		Set(`class_names`, classNames).
+		Set(`class_instances`, classInstances).
+		Set(`class_shallow_sizes`, classShallowSizes).
+		Set(`class_retained_sizes`, classRetainedSizes).
+		Set(`leak_kinds`, leakKinds).
+		Set(`leak_class_names`, leakClassNames).
+		Set(`leak_instances`, leakInstances).
+		Set(`leak_retained_sizes`, leakRetainedSizes).
+		Set(`leak_elements`, leakElements).
+		Set(`leak_path_classes`, leakPathClasses).
+		Set(`leak_path_names`, leakPathNames)
+
+	defer stmt.Close()
+
+	asyncCtx := clickhouse.Context(ctx, clickhouse.WithAsync(true))
+	return server.Server.ChPool.Exec(asyncCtx, stmt.String(), stmt.Args()...)
+}
@@ -0,0 +1,92 @@
+//go:build integration
+
+package measure
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"backend/ingest-worker/server"
+	"backend/libs/event"
+
+	"github.com/google/uuid"
+)
+
+func TestEventReqGetHeapDumps(t *testing.T) {
+	eventID := uuid.New()
+	sessionID := uuid.New()
+	eventReq := &eventreq{
+		events: []event.EventField{
+			{
+				Type: event.TypeString,
+			},
+			{
+				ID:   uuid.New(),
+				Type: event.TypeBugReport,
+				Attachments: []event.Attachment{
+					{Type: "screenshot", Key: "screenshot"},
+				},
+			},
+			{
+				ID:        eventID,
+				SessionID: sessionID,
+				Type:      event.TypeException,
+				Attribute: event.Attribute{
+					AppVersion: "1.0.0",
+					AppBuild:   "1",
+					OSName:     "android",
+				},
+				Attachments: []event.Attachment{
+					{Type: "screenshot", Key: "screenshot"},
+					{Type: "heap_dump", Key: "hprof"},
+				},
+			},
+		},
+	}
+
+	jobs := eventReq.getHeapDumps()
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 heap dump job, got %d", len(jobs))
+	}
+
+	job := jobs[0]
+	if job.EventID != eventID || job.SessionID != sessionID {
+		t.Errorf("Expected event id %v of session %v, got %v of %v", eventID, sessionID, job.EventID, job.SessionID)
+	}
+	if job.Attachment.Key != "hprof" {
+		t.Errorf("Expected heap dump attachment, got key %q", job.Attachment.Key)
+	}
+	if job.EventType != event.TypeException || job.AppVersion != "1.0.0" || job.AppBuild != "1" || job.OSName != "android" {
+		t.Errorf("Expected event attributes to be copied, got %+v", job)
+	}
+	if job.QueuedAt.IsZero() {
+		t.Errorf("Expected queued at to be set")
+	}
+}
+
+func TestConsumeHeapDumpHandlerDropsLargeHeapDumps(t *testing.T) {
+	prev := server.Server.Config.HeapDumpMaxSize
+	server.Server.Config.HeapDumpMaxSize = 1 << 20
+	t.Cleanup(func() { server.Server.Config.HeapDumpMaxSize = prev })
+
+	data, err := json.Marshal(heapDumpJob{attachmentJob{
+		EventID: uuid.New(),
+		Attachment: event.Attachment{
+			Type: "heap_dump",
+			Size: 2 << 20,
+			Key:  "hprof",
+		},
+		QueuedAt: time.Now(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the attachment doesn't exist, so a
+	// download would fail & be retried
+	if err := ConsumeHeapDumpHandler(context.Background(), data); err != nil {
+		t.Errorf("Expected the heap dump to be dropped, got %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package measure
+
+import (
+	"backend/ingest-worker/server"
+	"backend/libs/bus"
+	"backend/libs/chrono"
+	"backend/libs/event"
+	"backend/libs/flame"
+	"backend/libs/opsys"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/leporo/sqlf"
+)
+
+// profileMaxSize is the size in bytes of the
+// largest profile aggregated. Profiles are far
+// smaller than heap dumps or perfetto traces.
+const profileMaxSize = 64 << 20
+
+// profileJob is a profile queued for
+// aggregation.
+type profileJob struct {
+	attachmentJob
+	Reason string `json:"reason"`
+	Format string `json:"format"`
+}
+
+// getProfiles gets the profile jobs of the profile
+// events having an attachment of a supported format.
+func (e eventreq) getProfiles() (jobs []profileJob) {
+	now := time.Now()
+	for _, ev := range e.events {
+		if !ev.IsProfile() {
+			continue
+		}
+
+		for _, a := range ev.Attachments {
+			if !flame.Supported(a.Type) {
+				continue
+			}
+
+			jobs = append(jobs, profileJob{
+				attachmentJob: e.newAttachmentJob(ev, a, now),
+				Reason:        ev.Profile.Reason,
+				Format:        a.Type,
+			})
+			break
+		}
+	}
+
+	return
+}
+
+// queueProfiles queues the batch's profiles
+// for aggregation.
+func (e eventreq) queueProfiles(ctx context.Context) error {
+	return queueAttachmentJobs(ctx, server.Server.ProfileProducer, e.getProfiles())
+}
+
+// ConsumeProfileHandler is the bus.Consumer handler for queued
+// profiles. It parses the profile's attachment, de-obfuscates
+// its frames & stores its stacks for flamegraphs.
+//
+// A profile whose attachment hasn't finished uploading fails, so
+// the consumer retries it later. Profiles larger than
+// profileMaxSize or that can't be parsed are dropped.
+func ConsumeProfileHandler(ctx context.Context, data []byte) error {
+	var job profileJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return bus.Permanent(fmt.Errorf("failed to unmarshal profile job: %w", err))
+	}
+
+	raw, err := job.read(ctx, profileMaxSize)
+	if errors.Is(err, errAttachmentDropped) {
+		fmt.Printf("dropping profile %q: %v\n", job.EventID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read profile %q: %w", job.EventID, err)
+	}
+
+	profile, err := flame.Parse(job.Format, raw)
+	if err != nil {
+		fmt.Printf("dropping profile %q: %v\n", job.EventID, err)
+		return nil
+	}
+
+	if opsys.ToFamily(job.OSName) == opsys.Android {
+		symblctr := newSymbolicator(ctx, server.Server.Config, job.OSName)
+		rewrites, err := symblctr.SymbolicateFrames(ctx, server.Server.PgPool, job.AppID, job.AppVersion, job.AppBuild, profile.Frames())
+		if err != nil {
+			fmt.Printf("failed to symbolicate profile %q: %v\n", job.EventID, err)
+		}
+		profile.Rewrite(rewrites)
+	}
+
+	return job.ingest(ctx, profile)
+}
+
+// window gets the time range the
+// profile was taken over.
+func (j profileJob) window(p flame.Profile) (start, end time.Time) {
+	if p.Start.IsZero() {
+		end = j.Timestamp
+		start = end.Add(-p.Duration)
+		return
+	}
+
+	start = p.Start
+	end = start.Add(p.Duration)
+	return
+}
+
+// getScreen gets the last screen viewed
+// in the session by the time end.
+func (j profileJob) getScreen(ctx context.Context, end time.Time) (screen string, err error) {
+	stmt := sqlf.From("events final").
+		Select("toString(screen_view.name)").
+		Where("team_id = ?", j.TeamID).
+		Where("app_id = ?", j.AppID).
+		Where("session_id = ?", j.SessionID).
+		Where("type = ?", event.TypeScreenView).
+		Where("timestamp <= ?", end).
+		OrderBy("timestamp desc").
+		Limit(1)
+
+	defer stmt.Close()
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&screen); err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+	}
+
+	return
+}
+
+// getSpanNames gets the names of the session's
+// spans overlapping start & end.
+func (j profileJob) getSpanNames(ctx context.Context, start, end time.Time) (names []string, err error) {
+	stmt := sqlf.From("spans final").
+		Select("distinct toString(span_name)").
+		Where("team_id = ?", j.TeamID).
+		Where("app_id = ?", j.AppID).
+		Where("session_id = ?", j.SessionID).
+		Where("start_time <= ?", end).
+		Where("end_time >= ?", start)
+
+	defer stmt.Close()
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// ingest writes the profile's
+// stacks to database.
+func (j profileJob) ingest(ctx context.Context, p flame.Profile) (err error) {
+	if len(p.Samples) == 0 {
+		return
+	}
+
+	start, end := j.window(p)
+
+	screen, err := j.getScreen(ctx, end)
+	if err != nil {
+		return fmt.Errorf("failed to get screen of profile %q: %w", j.EventID, err)
+	}
+
+	spanNames, err := j.getSpanNames(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("failed to get spans of profile %q: %w", j.EventID, err)
+	}
+	if spanNames == nil {
+		spanNames = []string{}
+	}
+
+	appVersionTuple := fmt.Sprintf("('%s', '%s')", j.AppVersion, j.AppBuild)
+
+	stmt := sqlf.InsertInto(`profile_stacks`)
+	defer stmt.Close()
+
+	for _, s := range p.Samples {
+		stack := make([]string, len(s.Stack))
+		for i, f := range s.Stack {
+			stack[i] = f.String()
+		}
+
+		stmt.NewRow().
+			Set(`team_id`, j.TeamID).
+			Set(`app_id`, j.AppID).
+			Set(`event_id`, j.EventID).
+			Set(`session_id`, j.SessionID).
+			Set(`timestamp`, j.Timestamp.Format(chrono.MSTimeFormat)).
+			Set(`app_version`, appVersionTuple).
+			Set(`reason`, j.Reason).
+			Set(`format`, j.Format).
+			Set(`screen`, screen).
+			Set(`span_names`, spanNames).
+			Set(`thread_name`, s.ThreadName).
+			Set(`stack`, stack).
+			Set(`weight`, s.Weight).
+			Set(`samples`, s.Count)
+	}
+
+	asyncCtx := clickhouse.Context(ctx, clickhouse.WithAsync(true))
+	return server.Server.ChPool.Exec(asyncCtx, stmt.String(), stmt.Args()...)
+}
@@ -0,0 +1,91 @@
+//go:build integration
+
+package measure
+
+import (
+	"testing"
+	"time"
+
+	"backend/libs/event"
+	"backend/libs/flame"
+
+	"github.com/google/uuid"
+)
+
+func TestEventReqGetProfiles(t *testing.T) {
+	eventID := uuid.New()
+	eventReq := &eventreq{
+		events: []event.EventField{
+			{
+				Type: event.TypeString,
+			},
+			{
+				ID:      uuid.New(),
+				Type:    event.TypeProfile,
+				Profile: &event.Profile{Reason: "anr", Format: "perfetto"},
+				Attachments: []event.Attachment{
+					{Type: "perfetto_trace", Key: "perfetto"},
+				},
+			},
+			{
+				ID:      eventID,
+				Type:    event.TypeProfile,
+				Profile: &event.Profile{Reason: "slow_frame", Format: "pprof"},
+				Attribute: event.Attribute{
+					AppVersion: "1.0.0",
+					AppBuild:   "1",
+					OSName:     "android",
+				},
+				Attachments: []event.Attachment{
+					{Type: "screenshot", Key: "screenshot"},
+					{Type: flame.FormatPprof, Key: "pprof"},
+				},
+			},
+		},
+	}
+
+	jobs := eventReq.getProfiles()
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 profile job, got %d", len(jobs))
+	}
+
+	job := jobs[0]
+	if job.EventID != eventID {
+		t.Errorf("Expected event id %v, got %v", eventID, job.EventID)
+	}
+	if job.Format != flame.FormatPprof || job.Attachment.Key != "pprof" {
+		t.Errorf("Expected pprof attachment, got %q with key %q", job.Format, job.Attachment.Key)
+	}
+	if job.Reason != "slow_frame" || job.AppVersion != "1.0.0" || job.AppBuild != "1" || job.OSName != "android" {
+		t.Errorf("Expected profile attributes to be copied, got %+v", job)
+	}
+	if job.QueuedAt.IsZero() {
+		t.Errorf("Expected queued at to be set")
+	}
+}
+
+func TestProfileJobWindow(t *testing.T) {
+	timestamp := time.Date(2026, 10, 17, 9, 0, 10, 0, time.UTC)
+	job := profileJob{attachmentJob: attachmentJob{Timestamp: timestamp}}
+
+	// Ends at the event when the profile
+	// doesn't know its start
+	{
+		start, end := job.window(flame.Profile{Duration: 5 * time.Second})
+
+		if !end.Equal(timestamp) || !start.Equal(timestamp.Add(-5*time.Second)) {
+			t.Errorf("Expected window to end at event, got %v - %v", start, end)
+		}
+	}
+
+	// Starts at the profile's start
+	// when known
+	{
+		profileStart := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+		start, end := job.window(flame.Profile{Start: profileStart, Duration: 2 * time.Second})
+
+		if !start.Equal(profileStart) || !end.Equal(profileStart.Add(2*time.Second)) {
+			t.Errorf("Expected window to start at profile start, got %v - %v", start, end)
+		}
+	}
+}
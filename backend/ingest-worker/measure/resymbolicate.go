@@ -0,0 +1,635 @@
+package measure
+
+import (
+	"backend/ingest-worker/server"
+	"backend/libs/ambient"
+	"backend/libs/bus"
+	"backend/libs/chquery"
+	"backend/libs/chrono"
+	"backend/libs/event"
+	"backend/libs/ingest"
+	"backend/libs/symbol"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// resymbolicatePageSize is the number of errors
+// symbolicated & rewritten together.
+const resymbolicatePageSize = 200
+
+// resymbolicateMaxEvents caps the number of errors a
+// single job looks at, so a job finishes within its
+// lease. A job with more errors queues a continuation
+// for the rest.
+const resymbolicateMaxEvents = 20_000
+
+// resymbolicates reports whether mapping files of the
+// mapping type symbolicate the error event.
+func resymbolicates(ev event.EventField, mappingType symbol.MappingType) bool {
+	if ev.IsANR() {
+		return mappingType == symbol.TypeProguard
+	}
+
+	if !ev.IsException() {
+		return false
+	}
+
+	switch ev.Exception.GetFramework() {
+	case event.FrameworkJVM:
+		return mappingType == symbol.TypeProguard
+	case event.FrameworkApple:
+		return mappingType == symbol.TypeDsym
+	case event.FrameworkDart:
+		return mappingType == symbol.TypeDsym || mappingType == symbol.TypeElfDebug || mappingType == symbol.TypeDartObfuscation
+	case event.FrameworkJS:
+		return mappingType == symbol.TypeJsBundle || mappingType == symbol.TypeHermesSourcemap
+	}
+
+	return false
+}
+
+// errorUnits gets the marshalled exceptions, threads
+// & the fingerprint of an exception or ANR event.
+func errorUnits(ev event.EventField) (exceptions, threads, fingerprint string, err error) {
+	var e []byte
+	var t []byte
+
+	switch {
+	case ev.IsANR():
+		if e, err = json.Marshal(ev.ANR.Exceptions); err != nil {
+			return
+		}
+		if t, err = json.Marshal(ev.ANR.Threads); err != nil {
+			return
+		}
+		fingerprint = ev.ANR.Fingerprint
+	case ev.IsException():
+		if e, err = json.Marshal(ev.Exception.Exceptions); err != nil {
+			return
+		}
+		if t, err = json.Marshal(ev.Exception.Threads); err != nil {
+			return
+		}
+		fingerprint = ev.Exception.Fingerprint
+	}
+
+	return string(e), string(t), fingerprint, nil
+}
+
+// getResymbolicateEvents gets the next page of errors of the
+// job's app version, ingested before the job's mapping file
+// landed. Pages are ordered by timestamp & id, starting after
+// the cursor when not nil.
+func getResymbolicateEvents(ctx context.Context, teamID uuid.UUID, job ingest.ResymbolicateJob, cursor *ingest.ResymbolicateCursor) (events []event.EventField, err error) {
+	stmt := sqlf.From("events final").
+		Select("id").
+		Select("session_id").
+		Select("timestamp").
+		Select("type").
+		Select("inet.country_code").
+		Select("attribute.app_version").
+		Select("attribute.app_build").
+		Select("attribute.patch_id").
+		Select("attribute.os_name").
+		Select("attribute.os_version").
+		Select("attribute.device_cpu_arch").
+		Select("attribute.device_name").
+		Select("attribute.device_model").
+		Select("attribute.device_manufacturer").
+		Select("attribute.device_locale").
+		Select("attribute.network_type").
+		Select("attribute.network_provider").
+		Select("attribute.network_generation").
+		Select("exception.handled").
+		Select("exception.fingerprint").
+		Select("exception.exceptions").
+		Select("exception.threads").
+		Select("exception.foreground").
+		Select("exception.binary_images").
+		Select("exception.framework").
+		Select("exception.severity").
+		Select("exception.is_custom").
+		Select("anr.handled").
+		Select("anr.fingerprint").
+		Select("anr.exceptions").
+		Select("anr.threads").
+		Select("anr.foreground").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", job.AppID).
+		Where("type in ?", []string{event.TypeException, event.TypeANR}).
+		Where("attribute.app_version = ?", job.VersionName).
+		Where("attribute.app_build = ?", job.VersionCode).
+		Where("inserted_at < toDateTime64(?, 3, 'UTC')", job.UploadedAt.Format(chrono.MSTimeFormat)).
+		OrderBy("timestamp", "id").
+		Limit(resymbolicatePageSize)
+
+	defer stmt.Close()
+
+	// events ingested before OTA patches were
+	// tracked carry no patch id at all
+	if job.PatchID == uuid.Nil {
+		stmt.Where("attribute.patch_id in ?", []string{"", uuid.Nil.String()})
+	} else {
+		stmt.Where("attribute.patch_id = ?", job.PatchID.String())
+	}
+
+	if cursor != nil {
+		stmt.Where("(timestamp, id) > (toDateTime64(?, 3, 'UTC'), toUUID(?))", cursor.Timestamp.Format(chrono.MSTimeFormat), cursor.ID)
+	}
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var ev event.EventField
+		var patchID string
+		var exception event.Exception
+		var anr event.ANR
+		var exceptionExceptions, exceptionThreads, binaryImages, severity string
+		var anrExceptions, anrThreads string
+
+		if err = rows.Scan(
+			&ev.ID,
+			&ev.SessionID,
+			&ev.Timestamp,
+			&ev.Type,
+			&ev.CountryCode,
+			&ev.Attribute.AppVersion,
+			&ev.Attribute.AppBuild,
+			&patchID,
+			&ev.Attribute.OSName,
+			&ev.Attribute.OSVersion,
+			&ev.Attribute.DeviceCPUArch,
+			&ev.Attribute.DeviceName,
+			&ev.Attribute.DeviceModel,
+			&ev.Attribute.DeviceManufacturer,
+			&ev.Attribute.DeviceLocale,
+			&ev.Attribute.NetworkType,
+			&ev.Attribute.NetworkProvider,
+			&ev.Attribute.NetworkGeneration,
+			&exception.Handled,
+			&exception.Fingerprint,
+			&exceptionExceptions,
+			&exceptionThreads,
+			&exception.Foreground,
+			&binaryImages,
+			&exception.Framework,
+			&severity,
+			&exception.IsCustom,
+			&anr.Handled,
+			&anr.Fingerprint,
+			&anrExceptions,
+			&anrThreads,
+			&anr.Foreground,
+		); err != nil {
+			return
+		}
+
+		ev.AppID = job.AppID
+		ev.Attribute.DeviceCPUArch = strings.TrimRight(ev.Attribute.DeviceCPUArch, "\x00")
+		if parsed, errParse := uuid.Parse(patchID); errParse == nil {
+			ev.Attribute.PatchID = parsed
+		}
+
+		switch ev.Type {
+		case event.TypeException:
+			exception.Fingerprint = strings.TrimRight(exception.Fingerprint, "\x00")
+			exception.Framework = strings.TrimRight(exception.Framework, "\x00")
+			exception.Severity = event.Severity(severity)
+			if err = json.Unmarshal([]byte(exceptionExceptions), &exception.Exceptions); err != nil {
+				return
+			}
+			if err = json.Unmarshal([]byte(exceptionThreads), &exception.Threads); err != nil {
+				return
+			}
+			if binaryImages != "" {
+				if err = json.Unmarshal([]byte(binaryImages), &exception.BinaryImages); err != nil {
+					return
+				}
+			}
+			ev.Exception = &exception
+		case event.TypeANR:
+			anr.Fingerprint = strings.TrimRight(anr.Fingerprint, "\x00")
+			if err = json.Unmarshal([]byte(anrExceptions), &anr.Exceptions); err != nil {
+				return
+			}
+			if err = json.Unmarshal([]byte(anrThreads), &anr.Threads); err != nil {
+				return
+			}
+			ev.ANR = &anr
+		}
+
+		events = append(events, ev)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// rewriteEvents rewrites the exceptions, threads & fingerprints
+// of the error events by inserting the rewritten rows again.
+// events is a ReplacingMergeTree, so the rewritten rows replace
+// the old ones as parts merge. Reads with final see them right
+// away.
+//
+// The inserts fire the materialized views on events again. Their
+// tables dedup on the event or aggregate uniquely, except for
+// sessions, which rederiveSessions rebuilds.
+func rewriteEvents(ctx context.Context, teamID, appID uuid.UUID, prefix string, events []event.EventField) (err error) {
+	if len(events) == 0 {
+		return
+	}
+
+	var ids, exceptions, threads, fingerprints []string
+	from, to := events[0].Timestamp, events[0].Timestamp
+
+	for _, ev := range events {
+		e, t, f, errUnits := errorUnits(ev)
+		if errUnits != nil {
+			return errUnits
+		}
+
+		ids = append(ids, ev.ID.String())
+		exceptions = append(exceptions, e)
+		threads = append(threads, t)
+		fingerprints = append(fingerprints, f)
+
+		if ev.Timestamp.Before(from) {
+			from = ev.Timestamp
+		}
+		if ev.Timestamp.After(to) {
+			to = ev.Timestamp
+		}
+	}
+
+	exceptionsCol := "`" + prefix + ".exceptions`"
+	threadsCol := "`" + prefix + ".threads`"
+	fingerprintCol := "`" + prefix + ".fingerprint`"
+
+	stmt := sqlf.New("insert into events select * replace ("+
+		"transform(toString(id), ?, ?, "+exceptionsCol+") as "+exceptionsCol+", "+
+		"transform(toString(id), ?, ?, "+threadsCol+") as "+threadsCol+", "+
+		"toFixedString(transform(toString(id), ?, ?, toString("+fingerprintCol+")), 32) as "+fingerprintCol+", "+
+		"now64(3, 'UTC') as inserted_at) "+
+		"from events final "+
+		"where team_id = toUUID(?) "+
+		"and app_id = toUUID(?) "+
+		"and timestamp >= toDateTime64(?, 3, 'UTC') "+
+		"and timestamp <= toDateTime64(?, 3, 'UTC') "+
+		"and id in ?",
+		ids, exceptions, ids, threads, ids, fingerprints,
+		teamID, appID, from.Format(chrono.MSTimeFormat), to.Format(chrono.MSTimeFormat), ids)
+
+	defer stmt.Close()
+
+	return server.Server.ChPool.Exec(ctx, stmt.String(), stmt.Args()...)
+}
+
+// getRewrittenSessions gets the sessions of the errors the job
+// rewrote, after the job's cursor & up to upTo, when not nil.
+// Errors are picked by their insertion time, so a retried job
+// gets the sessions of errors an earlier attempt rewrote too.
+func getRewrittenSessions(ctx context.Context, teamID uuid.UUID, job ingest.ResymbolicateJob, upTo *ingest.ResymbolicateCursor) (sessionIDs []uuid.UUID, err error) {
+	stmt := sqlf.From("events").
+		Select("distinct session_id").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", job.AppID).
+		Where("type in ?", []string{event.TypeException, event.TypeANR}).
+		Where("attribute.app_version = ?", job.VersionName).
+		Where("attribute.app_build = ?", job.VersionCode).
+		Where("inserted_at >= toDateTime64(?, 3, 'UTC')", job.UploadedAt.Format(chrono.MSTimeFormat))
+
+	defer stmt.Close()
+
+	if job.After != nil {
+		stmt.Where("(timestamp, id) > (toDateTime64(?, 3, 'UTC'), toUUID(?))", job.After.Timestamp.Format(chrono.MSTimeFormat), job.After.ID)
+	}
+	if upTo != nil {
+		stmt.Where("(timestamp, id) <= (toDateTime64(?, 3, 'UTC'), toUUID(?))", upTo.Timestamp.Format(chrono.MSTimeFormat), upTo.ID)
+	}
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var sessionID uuid.UUID
+		if err = rows.Scan(&sessionID); err != nil {
+			return
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// getSessionsMVQuery gets the query of the sessions_mv
+// materialized view & the columns it outputs.
+func getSessionsMVQuery(ctx context.Context) (query string, cols []string, err error) {
+	if err = server.Server.ChPool.QueryRow(ctx, "select as_select from system.tables where database = currentDatabase() and name = 'sessions_mv'").Scan(&query); err != nil {
+		return
+	}
+
+	rows, err := server.Server.ChPool.Query(ctx, "select name from system.columns where database = currentDatabase() and table = 'sessions_mv' order by position")
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			return
+		}
+		cols = append(cols, "`"+col+"`")
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// rederiveSessions rebuilds the sessions rows of the sessions
+// whose errors the job rewrote, after the job's cursor & up to
+// upTo, when not nil. Rewritten errors were counted again as
+// they were inserted, so the sessions' rows are deleted &
+// aggregated again from events, with sessions_mv's own query.
+func rederiveSessions(ctx context.Context, teamID uuid.UUID, job ingest.ResymbolicateJob, upTo *ingest.ResymbolicateCursor) (err error) {
+	sessionIDs, err := getRewrittenSessions(ctx, teamID, job, upTo)
+	if err != nil {
+		return fmt.Errorf("failed to get rewritten sessions: %w", err)
+	}
+
+	if len(sessionIDs) == 0 {
+		return
+	}
+
+	query, cols, err := getSessionsMVQuery(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get sessions_mv query: %w", err)
+	}
+
+	del := sqlf.New("delete from sessions where team_id = toUUID(?) and app_id = toUUID(?) and session_id in ?", teamID, job.AppID, sessionIDs)
+
+	defer del.Close()
+
+	if err = server.Server.ChPool.Exec(ctx, del.String(), del.Args()...); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+
+	// sessions_mv reads events as they're inserted,
+	// here it reads every row of the sessions, so
+	// final leaves out the rows rewrites replaced
+	list := strings.Join(cols, ", ")
+	insert := sqlf.New("insert into sessions ("+list+") select "+list+" from ("+query+") where team_id = toUUID(?) and app_id = toUUID(?) and session_id in ?", teamID, job.AppID, sessionIDs)
+
+	defer insert.Close()
+
+	ctx = chquery.WithSettings(ctx, clickhouse.Settings{"final": 1})
+
+	if err = server.Server.ChPool.Exec(ctx, insert.String(), insert.Args()...); err != nil {
+		return fmt.Errorf("failed to insert sessions: %w", err)
+	}
+
+	return
+}
+
+// resymbolicate symbolicates a page of errors again, rewrites the
+// errors whose stacktraces or fingerprints changed & buckets them
+// into their error groups. Returns the number of rewritten errors.
+func resymbolicate(ctx context.Context, eventReq *eventreq, mappingType symbol.MappingType) (rewritten int, err error) {
+	rules, err := eventReq.getFingerprintRules(ctx)
+	if err != nil {
+		return
+	}
+
+	var events []event.EventField
+	var before []string
+
+	for _, ev := range eventReq.events {
+		if !resymbolicates(ev, mappingType) {
+			continue
+		}
+
+		e, t, f, errUnits := errorUnits(ev)
+		if errUnits != nil {
+			return 0, errUnits
+		}
+
+		events = append(events, ev)
+		before = append(before, e+t+f)
+	}
+
+	if len(events) == 0 {
+		return
+	}
+
+	symblctr := newSymbolicator(ctx, server.Server.Config, events[0].Attribute.OSName)
+	if err = symblctr.Symbolicate(ctx, server.Server.PgPool, eventReq.appId, events, nil); err != nil {
+		return
+	}
+
+	var exceptions, anrs []event.EventField
+	changed := &eventreq{
+		id:     eventReq.id,
+		appId:  eventReq.appId,
+		teamId: eventReq.teamId,
+	}
+
+	for i := range events {
+		if events[i].IsANR() {
+			err = events[i].ANR.ComputeFingerprintWithRules(rules)
+		} else {
+			err = events[i].Exception.ComputeFingerprintWithRules(rules)
+		}
+		if err != nil {
+			return
+		}
+
+		e, t, f, errUnits := errorUnits(events[i])
+		if errUnits != nil {
+			return 0, errUnits
+		}
+
+		// mapping files that don't cover the
+		// error leave it as it was
+		if e+t+f == before[i] {
+			continue
+		}
+
+		if events[i].IsANR() {
+			changed.anrIds = append(changed.anrIds, len(changed.events))
+			anrs = append(anrs, events[i])
+		} else {
+			changed.exceptionIds = append(changed.exceptionIds, len(changed.events))
+			exceptions = append(exceptions, events[i])
+		}
+		changed.events = append(changed.events, events[i])
+	}
+
+	if err = rewriteEvents(ctx, eventReq.teamId, eventReq.appId, "exception", exceptions); err != nil {
+		return
+	}
+
+	if err = rewriteEvents(ctx, eventReq.teamId, eventReq.appId, "anr", anrs); err != nil {
+		return
+	}
+
+	// errors moved to groups of their new
+	// fingerprints, groups left without
+	// errors drop out of listings
+	if err = changed.bucketExceptions(ctx); err != nil {
+		return
+	}
+
+	if err = changed.bucketANRs(ctx); err != nil {
+		return
+	}
+
+	return len(changed.events), nil
+}
+
+// ConsumeResymbolicateHandler is the bus.Consumer handler for
+// re-symbolication jobs. Symboloader queues a job when a mapping
+// file lands for an app version whose errors were already
+// ingested. The handler symbolicates those errors again, rewrites
+// their rows & recomputes their fingerprints & error groups.
+//
+// A job looks at up to resymbolicateMaxEvents errors, then queues
+// a continuation that picks up after the last error it looked at.
+// Rewritten rows carry a fresh insertion time, so a retried job
+// skips the errors it already rewrote. The sessions of the errors
+// are rebuilt once, at the end of the job.
+func ConsumeResymbolicateHandler(ctx context.Context, data []byte) error {
+	var job ingest.ResymbolicateJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return bus.Permanent(fmt.Errorf("failed to unmarshal resymbolicate job: %w", err))
+	}
+
+	mappingType := symbol.ParseMappingType(job.MappingType)
+	if mappingType == symbol.TypeUnknown {
+		return bus.Permanent(fmt.Errorf("failed to resymbolicate: unknown mapping type %q", job.MappingType))
+	}
+
+	app, err := SelectApp(ctx, job.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to lookup app: %w", err)
+	}
+	if app == nil {
+		fmt.Printf("dropping resymbolicate job, app %q not found\n", job.AppID)
+		return nil
+	}
+
+	ctx = ambient.WithTeamId(ctx, app.TeamId)
+	start := time.Now()
+
+	cursor := job.After
+	seen, rewritten := 0, 0
+	more := false
+
+	for {
+		if seen >= resymbolicateMaxEvents {
+			more = true
+			break
+		}
+
+		events, err := getResymbolicateEvents(ctx, app.TeamId, job, cursor)
+		if err != nil {
+			return fmt.Errorf("failed to get events to resymbolicate: %w", err)
+		}
+
+		if len(events) == 0 {
+			break
+		}
+
+		seen += len(events)
+		last := events[len(events)-1]
+		cursor = &ingest.ResymbolicateCursor{
+			Timestamp: last.Timestamp,
+			ID:        last.ID,
+		}
+
+		eventReq := &eventreq{
+			id:     uuid.New(),
+			appId:  job.AppID,
+			teamId: app.TeamId,
+			events: events,
+		}
+
+		for i := range events {
+			if events[i].IsException() {
+				eventReq.exceptionIds = append(eventReq.exceptionIds, i)
+			}
+			if events[i].IsANR() {
+				eventReq.anrIds = append(eventReq.anrIds, i)
+			}
+		}
+
+		n, err := resymbolicate(ctx, eventReq, mappingType)
+		if err != nil {
+			return fmt.Errorf("failed to resymbolicate events of app %q version %s (%s): %w", job.AppID, job.VersionName, job.VersionCode, err)
+		}
+
+		rewritten += n
+
+		if len(events) < resymbolicatePageSize {
+			break
+		}
+	}
+
+	// a job with more errors rebuilds the
+	// sessions up to where it stopped, its
+	// continuation the rest
+	var upTo *ingest.ResymbolicateCursor
+	if more {
+		upTo = cursor
+	}
+
+	if err := rederiveSessions(ctx, app.TeamId, job, upTo); err != nil {
+		return fmt.Errorf("failed to rederive sessions of app %q version %s (%s): %w", job.AppID, job.VersionName, job.VersionCode, err)
+	}
+
+	fmt.Printf("resymbolicated %d of %d errors of app %q version %s (%s) for %s mapping in %s\n", rewritten, seen, job.AppID, job.VersionName, job.VersionCode, job.MappingType, time.Since(start))
+
+	if more {
+		next := job
+		next.After = cursor
+		if err := queueResymbolicateJob(ctx, next); err != nil {
+			return fmt.Errorf("failed to queue continuation of resymbolicate job: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// queueResymbolicateJob queues a re-symbolication job.
+func queueResymbolicateJob(ctx context.Context, job ingest.ResymbolicateJob) error {
+	if server.Server.ResymbolicateProducer == nil {
+		return fmt.Errorf("no resymbolicate producer")
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	return server.Server.ResymbolicateProducer.Publish(ctx, data)
+}
@@ -0,0 +1,203 @@
+//go:build integration
+
+package measure
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/ingest-worker/server"
+	"backend/libs/event"
+	"backend/libs/ingest"
+	"backend/libs/symbol"
+
+	"github.com/google/uuid"
+)
+
+func TestResymbolicates(t *testing.T) {
+	jvm := event.EventField{
+		Type:      event.TypeException,
+		Exception: &event.Exception{Framework: event.FrameworkJVM},
+	}
+	dart := event.EventField{
+		Type:      event.TypeException,
+		Exception: &event.Exception{Framework: event.FrameworkDart},
+	}
+	js := event.EventField{
+		Type:      event.TypeException,
+		Exception: &event.Exception{Framework: event.FrameworkJS},
+	}
+	anr := event.EventField{
+		Type: event.TypeANR,
+		ANR:  &event.ANR{},
+	}
+	log := event.EventField{
+		Type: event.TypeLog,
+	}
+
+	cases := []struct {
+		name        string
+		ev          event.EventField
+		mappingType symbol.MappingType
+		want        bool
+	}{
+		{"jvm exception with proguard", jvm, symbol.TypeProguard, true},
+		{"jvm exception with dsym", jvm, symbol.TypeDsym, false},
+		{"anr with proguard", anr, symbol.TypeProguard, true},
+		{"anr with elf debug", anr, symbol.TypeElfDebug, false},
+		{"dart exception with elf debug", dart, symbol.TypeElfDebug, true},
+		{"dart exception with obfuscation map", dart, symbol.TypeDartObfuscation, true},
+		{"dart exception with proguard", dart, symbol.TypeProguard, false},
+		{"js exception with hermes source map", js, symbol.TypeHermesSourcemap, true},
+		{"js exception with js bundle", js, symbol.TypeJsBundle, true},
+		{"log with proguard", log, symbol.TypeProguard, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := resymbolicates(c.ev, c.mappingType); got != c.want {
+				t.Errorf("resymbolicates() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestResymbolicateRewriteEvents(t *testing.T) {
+	ctx := context.Background()
+	defer cleanupAll(ctx, t)
+
+	teamID := uuid.New()
+	appID := uuid.New()
+	seedTeam(ctx, t, teamID, "test-team")
+	seedApp(ctx, t, appID, teamID, 30)
+
+	sessionStart := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	exception := event.EventField{
+		ID:        uuid.New(),
+		AppID:     appID,
+		SessionID: uuid.New(),
+		Timestamp: sessionStart.Add(time.Minute),
+		Type:      event.TypeException,
+		Attribute: event.Attribute{
+			AppVersion:       "1.0.0",
+			AppBuild:         "100",
+			OSName:           "android",
+			OSVersion:        "34",
+			SessionStartTime: sessionStart,
+		},
+		Exception: &event.Exception{
+			Framework: event.FrameworkJVM,
+			Exceptions: event.ExceptionUnits{
+				{
+					Type: "java.lang.IllegalStateException",
+					Frames: event.Frames{
+						{ClassName: "a.b.c", MethodName: "a", FileName: "SourceFile", LineNum: 1},
+					},
+				},
+			},
+			Threads: event.Threads{},
+		},
+	}
+
+	eventReq := &eventreq{
+		id:           uuid.New(),
+		appId:        appID,
+		teamId:       teamID,
+		events:       []event.EventField{exception},
+		exceptionIds: []int{0},
+	}
+
+	if err := eventReq.ingestEvents(ctx); err != nil {
+		t.Fatalf("ingestEvents: %v", err)
+	}
+
+	// the mapping lands after the
+	// exception was ingested
+	time.Sleep(10 * time.Millisecond)
+	job := ingest.ResymbolicateJob{
+		AppID:       appID,
+		VersionName: "1.0.0",
+		VersionCode: "100",
+		MappingType: symbol.TypeProguard.String(),
+		UploadedAt:  time.Now(),
+	}
+
+	events, err := getResymbolicateEvents(ctx, teamID, job, nil)
+	if err != nil {
+		t.Fatalf("getResymbolicateEvents: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("events = %d, want 1", len(events))
+	}
+
+	got := events[0]
+	if got.ID != exception.ID || got.Exception.GetFramework() != event.FrameworkJVM {
+		t.Fatalf("event = %q (%s), want %q (jvm)", got.ID, got.Exception.GetFramework(), exception.ID)
+	}
+
+	if got.Exception.Fingerprint != exception.Exception.Fingerprint {
+		t.Fatalf("fingerprint = %q, want %q", got.Exception.Fingerprint, exception.Exception.Fingerprint)
+	}
+
+	got.Exception.Exceptions[0].Frames[0].ClassName = "sh.measure.sample.ExceptionDemoActivity"
+	if err := got.Exception.ComputeFingerprintWithRules(nil); err != nil {
+		t.Fatalf("ComputeFingerprintWithRules: %v", err)
+	}
+
+	// events the sessions table counted,
+	// without merging its rows
+	sessionEvents := func() (count uint64) {
+		t.Helper()
+		if err := server.Server.ChPool.QueryRow(ctx, "select sum(event_count) from sessions where app_id = ?", appID).Scan(&count); err != nil {
+			t.Fatalf("count session events: %v", err)
+		}
+		return
+	}
+
+	sessionEventsBefore := sessionEvents()
+
+	if err := rewriteEvents(ctx, teamID, appID, "exception", []event.EventField{got}); err != nil {
+		t.Fatalf("rewriteEvents: %v", err)
+	}
+
+	if err := rederiveSessions(ctx, teamID, job, nil); err != nil {
+		t.Fatalf("rederiveSessions: %v", err)
+	}
+
+	// the rewrite fired the materialized views
+	// again, but the session counts it once
+	if sessionEventsAfter := sessionEvents(); sessionEventsAfter != sessionEventsBefore {
+		t.Errorf("session events = %d after rewrite, want %d", sessionEventsAfter, sessionEventsBefore)
+	}
+
+	var exceptions, fingerprint string
+	var count uint64
+	if err := server.Server.ChPool.QueryRow(ctx, "select any(`exception.exceptions`), any(toString(`exception.fingerprint`)), count() from events final where app_id = ? and id = ?", appID, exception.ID).Scan(&exceptions, &fingerprint, &count); err != nil {
+		t.Fatalf("query rewritten event: %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("rows = %d, want 1", count)
+	}
+
+	if fingerprint != got.Exception.Fingerprint {
+		t.Errorf("fingerprint = %q, want %q", fingerprint, got.Exception.Fingerprint)
+	}
+
+	wantExceptions, _, _, _ := errorUnits(got)
+	if exceptions != wantExceptions {
+		t.Errorf("exceptions = %s, want %s", exceptions, wantExceptions)
+	}
+
+	// rewritten errors aren't picked up again
+	events, err = getResymbolicateEvents(ctx, teamID, job, nil)
+	if err != nil {
+		t.Fatalf("getResymbolicateEvents: %v", err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("events = %d after rewrite, want 0", len(events))
+	}
+}
@@ -0,0 +1,127 @@
+package measure
+
+import (
+	"backend/ingest-worker/server"
+	"backend/libs/bus"
+	"backend/libs/chrono"
+	"backend/libs/event"
+	"backend/libs/perfetto"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/leporo/sqlf"
+)
+
+// perfettoTraceJob is a perfetto trace
+// queued for summarization.
+type perfettoTraceJob struct {
+	attachmentJob
+}
+
+// getPerfettoTraces gets the perfetto trace jobs
+// of the events having a perfetto trace
+// attachment.
+func (e eventreq) getPerfettoTraces() (jobs []perfettoTraceJob) {
+	for _, j := range e.getAttachmentJobs(event.Attachment.IsPerfettoTrace) {
+		jobs = append(jobs, perfettoTraceJob{j})
+	}
+
+	return
+}
+
+// queuePerfettoTraces queues the batch's
+// perfetto traces for summarization.
+func (e eventreq) queuePerfettoTraces(ctx context.Context) error {
+	return queueAttachmentJobs(ctx, server.Server.PerfettoTraceProducer, e.getPerfettoTraces())
+}
+
+// ConsumePerfettoTraceHandler is the bus.Consumer handler for
+// queued perfetto traces. It summarizes the jank, startup,
+// binder transactions & garbage collection of the app's
+// process in the trace's attachment & stores the summary.
+//
+// A trace whose attachment hasn't finished uploading fails,
+// so the consumer retries it later. Traces larger than the
+// configured maximum or that can't be parsed are dropped.
+func ConsumePerfettoTraceHandler(ctx context.Context, data []byte) error {
+	var job perfettoTraceJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return bus.Permanent(fmt.Errorf("failed to unmarshal perfetto trace job: %w", err))
+	}
+
+	raw, err := job.read(ctx, server.Server.Config.PerfettoTraceMaxSize)
+	if errors.Is(err, errAttachmentDropped) {
+		fmt.Printf("dropping perfetto trace %q: %v\n", job.EventID, err)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read perfetto trace %q: %w", job.EventID, err)
+	}
+
+	summary, err := perfetto.Summarize(raw, job.AppUniqueID)
+	if err != nil {
+		fmt.Printf("dropping perfetto trace %q: %v\n", job.EventID, err)
+		return nil
+	}
+
+	return job.ingest(ctx, summary)
+}
+
+// ingest writes the perfetto trace's
+// summary to database.
+func (j perfettoTraceJob) ingest(ctx context.Context, s perfetto.Summary) (err error) {
+	summary, err := json.Marshal(s)
+	if err != nil {
+		return
+	}
+
+	var startupDuration uint64
+	phaseNames, phaseDurations := []string{}, []uint64{}
+	workKinds, workDurations := []string{}, []uint64{}
+	if s.Startup != nil {
+		startupDuration = uint64(s.Startup.Duration)
+		for _, p := range s.Startup.Phases {
+			phaseNames = append(phaseNames, p.Name)
+			phaseDurations = append(phaseDurations, uint64(p.Duration))
+		}
+		for _, w := range s.Startup.Work {
+			workKinds = append(workKinds, w.Kind)
+			workDurations = append(workDurations, uint64(w.Duration))
+		}
+	}
+
+	appVersionTuple := fmt.Sprintf("('%s', '%s')", j.AppVersion, j.AppBuild)
+
+	stmt := sqlf.InsertInto(`trace_summaries`).
+		Set(`team_id`, j.TeamID).
+		Set(`app_id`, j.AppID).
+		Set(`event_id`, j.EventID).
+		Set(`event_type`, j.EventType).
+		Set(`session_id`, j.SessionID).
+		Set(`timestamp`, j.Timestamp.Format(chrono.MSTimeFormat)).
+		Set(`app_version`, appVersionTuple).
+		Set(`process_name`, s.ProcessName).
+		Set(`duration`, uint64(s.Duration)).
+		Set(`startup_duration`, startupDuration).
+		Set(`startup_phase_names`, phaseNames).
+		Set(`startup_phase_durations`, phaseDurations).
+		Set(`startup_work_kinds`, workKinds).
+		Set(`startup_work_durations`, workDurations).
+		Set(`jank_slices`, s.Jank.Slices).
+		Set(`jank_duration`, uint64(s.Jank.Duration)).
+		Set(`binder_transactions`, s.Binder.Transactions).
+		Set(`binder_duration`, uint64(s.Binder.Duration)).
+		Set(`binder_main_thread_duration`, uint64(s.Binder.MainThreadDuration)).
+		Set(`gc_collections`, s.GC.Collections).
+		Set(`gc_duration`, uint64(s.GC.Duration)).
+		Set(`gc_main_thread_duration`, uint64(s.GC.MainThreadDuration)).
+		Set(`summary`, string(summary))
+
+	defer stmt.Close()
+
+	asyncCtx := clickhouse.Context(ctx, clickhouse.WithAsync(true))
+	return server.Server.ChPool.Exec(asyncCtx, stmt.String(), stmt.Args()...)
+}
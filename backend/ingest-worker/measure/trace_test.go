@@ -0,0 +1,92 @@
+//go:build integration
+
+package measure
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"backend/ingest-worker/server"
+	"backend/libs/event"
+
+	"github.com/google/uuid"
+)
+
+func TestEventReqGetPerfettoTraces(t *testing.T) {
+	eventID := uuid.New()
+	sessionID := uuid.New()
+	eventReq := &eventreq{
+		events: []event.EventField{
+			{
+				Type: event.TypeString,
+			},
+			{
+				ID:   uuid.New(),
+				Type: event.TypeException,
+				Attachments: []event.Attachment{
+					{Type: "heap_dump", Key: "hprof"},
+				},
+			},
+			{
+				ID:        eventID,
+				SessionID: sessionID,
+				Type:      event.TypeColdLaunch,
+				Attribute: event.Attribute{
+					AppVersion:  "1.0.0",
+					AppBuild:    "1",
+					AppUniqueID: "sh.measure.test",
+				},
+				Attachments: []event.Attachment{
+					{Type: "screenshot", Key: "screenshot"},
+					{Type: "perfetto_trace", Key: "perfetto"},
+				},
+			},
+		},
+	}
+
+	jobs := eventReq.getPerfettoTraces()
+	if len(jobs) != 1 {
+		t.Fatalf("Expected 1 perfetto trace job, got %d", len(jobs))
+	}
+
+	job := jobs[0]
+	if job.EventID != eventID || job.SessionID != sessionID {
+		t.Errorf("Expected event id %v of session %v, got %v of %v", eventID, sessionID, job.EventID, job.SessionID)
+	}
+	if job.Attachment.Key != "perfetto" {
+		t.Errorf("Expected perfetto trace attachment, got key %q", job.Attachment.Key)
+	}
+	if job.EventType != event.TypeColdLaunch || job.AppVersion != "1.0.0" || job.AppBuild != "1" || job.AppUniqueID != "sh.measure.test" {
+		t.Errorf("Expected event attributes to be copied, got %+v", job)
+	}
+	if job.QueuedAt.IsZero() {
+		t.Errorf("Expected queued at to be set")
+	}
+}
+
+func TestConsumePerfettoTraceHandlerDropsLargeTraces(t *testing.T) {
+	prev := server.Server.Config.PerfettoTraceMaxSize
+	server.Server.Config.PerfettoTraceMaxSize = 1 << 20
+	t.Cleanup(func() { server.Server.Config.PerfettoTraceMaxSize = prev })
+
+	data, err := json.Marshal(perfettoTraceJob{attachmentJob{
+		EventID: uuid.New(),
+		Attachment: event.Attachment{
+			Type: "perfetto_trace",
+			Size: 2 << 20,
+			Key:  "perfetto",
+		},
+		QueuedAt: time.Now(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the attachment doesn't exist, so a
+	// download would fail & be retried
+	if err := ConsumePerfettoTraceHandler(context.Background(), data); err != nil {
+		t.Errorf("Expected the perfetto trace to be dropped, got %v", err)
+	}
+}
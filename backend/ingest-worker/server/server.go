@@ -38,10 +38,69 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
+// defaultDeadLetterMaxAttempts is the number of failed attempts
+// after which an ingest batch is dead-lettered.
+const defaultDeadLetterMaxAttempts = 5
+
 // ackLeaseDuration is the Pub/Sub ack lease held per message. Matches the
 // ingest-batch subscription's ackDeadlineSeconds.
 const ackLeaseDuration = 120 * time.Second
 
+// profileRetryDelay is the delay before a profile is looked
+// at again, like when its attachment is still uploading.
+const profileRetryDelay = 2 * time.Minute
+
+// profilePollInterval is the delay between polls
+// for queued profiles.
+const profilePollInterval = 5 * time.Second
+
+// heapDumpLease is how long a claimed heap dump stays
+// hidden from other workers. Large dumps take a while
+// to download & analyze.
+const heapDumpLease = 15 * time.Minute
+
+// heapDumpRetryDelay is the delay before a heap dump is
+// looked at again, like when its attachment is still
+// uploading.
+const heapDumpRetryDelay = 5 * time.Minute
+
+// defaultHeapDumpMaxSize is the size in bytes of the
+// largest heap dump analyzed, unless configured. A heap
+// dump is analyzed in memory, with its object graph &
+// dominator tree, in a few times its size.
+const defaultHeapDumpMaxSize = 64 << 20
+
+// heapDumpPollInterval is the delay between polls
+// for queued heap dumps.
+const heapDumpPollInterval = 10 * time.Second
+
+// perfettoTraceRetryDelay is the delay before a perfetto
+// trace is looked at again, like when its attachment is
+// still uploading.
+const perfettoTraceRetryDelay = 2 * time.Minute
+
+// defaultPerfettoTraceMaxSize is the size in bytes of
+// the largest perfetto trace summarized, unless
+// configured.
+const defaultPerfettoTraceMaxSize = 256 << 20
+
+// perfettoTracePollInterval is the delay between polls
+// for queued perfetto traces.
+const perfettoTracePollInterval = 5 * time.Second
+
+// resymbolicateLease is how long a claimed re-symbolication
+// job stays hidden from other workers. Jobs rewrite every
+// error of an app version, so they get a longer lease.
+const resymbolicateLease = 30 * time.Minute
+
+// resymbolicateRetryDelay is the delay before a failed
+// re-symbolication job is looked at again.
+const resymbolicateRetryDelay = 5 * time.Minute
+
+// resymbolicatePollInterval is the delay between polls
+// for queued re-symbolication jobs.
+const resymbolicatePollInterval = 30 * time.Second
+
 var Server *server
 
 type server struct {
@@ -50,6 +109,36 @@ type server struct {
 	Config      *ServerConfig
 	VK          redis.Client
 	BusConsumer bus.Consumer
+	// DeadLetter publishes batches the consumer gave up on.
+	// Nil when dead-lettering is off.
+	DeadLetter bus.Producer
+	// ProfileProducer queues profiles for aggregation.
+	// Profiles always queue in Postgres, whatever the
+	// bus backend of ingest batches, as a profile can
+	// wait hours on its attachment between retries.
+	// See "Attachment & re-symbolication queues" in
+	// the README.
+	ProfileProducer bus.Producer
+	// ProfileConsumer consumes queued profiles.
+	ProfileConsumer bus.Consumer
+	// HeapDumpProducer queues heap dumps for analysis
+	// in Postgres, like profiles.
+	HeapDumpProducer bus.Producer
+	// HeapDumpConsumer consumes queued heap dumps.
+	HeapDumpConsumer bus.Consumer
+	// PerfettoTraceProducer queues perfetto traces for
+	// summarization in Postgres, like profiles.
+	PerfettoTraceProducer bus.Producer
+	// PerfettoTraceConsumer consumes queued perfetto
+	// traces.
+	PerfettoTraceConsumer bus.Consumer
+	// ResymbolicateProducer queues the continuation of
+	// re-symbolication jobs that hit their error cap.
+	ResymbolicateProducer bus.Producer
+	// ResymbolicateConsumer consumes re-symbolication
+	// jobs symboloader queues when mapping files land
+	// after their errors were ingested.
+	ResymbolicateConsumer bus.Consumer
 }
 
 type PostgresConfig struct {
@@ -78,6 +167,7 @@ type ServerConfig struct {
 	CH                         ClickhouseConfig
 	RD                         RedisConfig
 	IG                         IggyConfig
+	BusBackend                 string
 	ServiceAccountEmail        string
 	SymbolsBucket              string
 	SymbolsBucketRegion        string
@@ -98,6 +188,15 @@ type ServerConfig struct {
 	CloudEnv                   bool
 	IngestEnforceTimeWindow    bool
 	BillingEnabled             bool
+	// DeadLetterMaxAttempts is the number of failed attempts after
+	// which a batch is dead-lettered. 0 retries batches forever.
+	DeadLetterMaxAttempts int
+	// HeapDumpMaxSize is the size in bytes of the largest
+	// heap dump analyzed. Larger heap dumps are dropped.
+	HeapDumpMaxSize int64
+	// PerfettoTraceMaxSize is the size in bytes of the largest
+	// perfetto trace summarized. Larger traces are dropped.
+	PerfettoTraceMaxSize int64
 }
 
 // IsCloud is true if the service is
@@ -260,18 +359,50 @@ func NewConfig() *ServerConfig {
 
 	posthog.Init(posthogAPIKey, posthogHost)
 
+	deadLetterMaxAttempts := defaultDeadLetterMaxAttempts
+	if v := os.Getenv("INGEST_DEAD_LETTER_MAX_ATTEMPTS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			log.Printf("invalid INGEST_DEAD_LETTER_MAX_ATTEMPTS %q, using %d\n", v, defaultDeadLetterMaxAttempts)
+		} else {
+			deadLetterMaxAttempts = n
+		}
+	}
+
+	heapDumpMaxSize := int64(defaultHeapDumpMaxSize)
+	if v := os.Getenv("HEAP_DUMP_MAX_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			log.Printf("invalid HEAP_DUMP_MAX_SIZE %q, using %d\n", v, heapDumpMaxSize)
+		} else {
+			heapDumpMaxSize = n
+		}
+	}
+
+	perfettoTraceMaxSize := int64(defaultPerfettoTraceMaxSize)
+	if v := os.Getenv("PERFETTO_TRACE_MAX_SIZE"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 {
+			log.Printf("invalid PERFETTO_TRACE_MAX_SIZE %q, using %d\n", v, perfettoTraceMaxSize)
+		} else {
+			perfettoTraceMaxSize = n
+		}
+	}
+
+	busBackend := bus.SelfHostBackend()
+
 	iggyAddr := os.Getenv("IGGY_ADDR")
-	if iggyAddr == "" && !cloudEnv {
+	if iggyAddr == "" && !cloudEnv && busBackend == bus.BackendIggy {
 		log.Println("IGGY_ADDR env var is not set, Iggy message streaming will not work")
 	}
 
 	iggyUsername := os.Getenv("IGGY_USERNAME")
-	if iggyUsername == "" {
+	if iggyUsername == "" && busBackend == bus.BackendIggy {
 		log.Println("IGGY_USERNAME env var is not set, Iggy message streaming will not work")
 	}
 
 	iggyPassword := os.Getenv("IGGY_PASSWORD")
-	if iggyPassword == "" {
+	if iggyPassword == "" && busBackend == bus.BackendIggy {
 		log.Println("IGGY_PASSWORD env var is not set, Iggy message streaming will not work")
 	}
 
@@ -291,6 +422,7 @@ func NewConfig() *ServerConfig {
 			Username: iggyUsername,
 			Password: iggyPassword,
 		},
+		BusBackend:                 busBackend,
 		ServiceAccountEmail:        serviceAccountEmail,
 		SymbolsBucket:              symbolsBucket,
 		SymbolsBucketRegion:        symbolsBucketRegion,
@@ -311,6 +443,9 @@ func NewConfig() *ServerConfig {
 		CloudEnv:                   cloudEnv,
 		IngestEnforceTimeWindow:    enforceIngestTimeWindow,
 		BillingEnabled:             billingEnabled,
+		DeadLetterMaxAttempts:      deadLetterMaxAttempts,
+		HeapDumpMaxSize:            heapDumpMaxSize,
+		PerfettoTraceMaxSize:       perfettoTraceMaxSize,
 	}
 }
 
@@ -401,6 +536,86 @@ func Init(config *ServerConfig) {
 		VK:     vkClient,
 	}
 
+	profileProducer, err := bus.NewPostgresProducer(pgPool, ingest.ProfileTopic)
+	if err != nil {
+		log.Printf("failed to create profile producer: %v\n", err)
+	} else {
+		Server.ProfileProducer = profileProducer
+	}
+
+	// profiles are parsed & symbolicated one at a
+	// time, so a claimed batch finishes within
+	// its lease
+	profileConsumer, err := bus.NewPostgresConsumer(pgPool, ingest.ProfileTopic,
+		bus.WithPostgresBatchSize(1),
+		bus.WithPostgresPollInterval(profilePollInterval),
+		bus.WithPostgresRetryDelay(profileRetryDelay),
+	)
+	if err != nil {
+		log.Printf("failed to create profile consumer: %v\n", err)
+	} else {
+		Server.ProfileConsumer = profileConsumer
+	}
+
+	heapDumpProducer, err := bus.NewPostgresProducer(pgPool, ingest.HeapDumpTopic)
+	if err != nil {
+		log.Printf("failed to create heap dump producer: %v\n", err)
+	} else {
+		Server.HeapDumpProducer = heapDumpProducer
+	}
+
+	// a heap dump is held in memory while it's
+	// analyzed, so dumps are analyzed one at
+	// a time
+	heapDumpConsumer, err := bus.NewPostgresConsumer(pgPool, ingest.HeapDumpTopic,
+		bus.WithPostgresBatchSize(1),
+		bus.WithPostgresPollInterval(heapDumpPollInterval),
+		bus.WithPostgresLease(heapDumpLease),
+		bus.WithPostgresRetryDelay(heapDumpRetryDelay),
+	)
+	if err != nil {
+		log.Printf("failed to create heap dump consumer: %v\n", err)
+	} else {
+		Server.HeapDumpConsumer = heapDumpConsumer
+	}
+
+	perfettoTraceProducer, err := bus.NewPostgresProducer(pgPool, ingest.PerfettoTraceTopic)
+	if err != nil {
+		log.Printf("failed to create perfetto trace producer: %v\n", err)
+	} else {
+		Server.PerfettoTraceProducer = perfettoTraceProducer
+	}
+
+	perfettoTraceConsumer, err := bus.NewPostgresConsumer(pgPool, ingest.PerfettoTraceTopic,
+		bus.WithPostgresBatchSize(1),
+		bus.WithPostgresPollInterval(perfettoTracePollInterval),
+		bus.WithPostgresRetryDelay(perfettoTraceRetryDelay),
+	)
+	if err != nil {
+		log.Printf("failed to create perfetto trace consumer: %v\n", err)
+	} else {
+		Server.PerfettoTraceConsumer = perfettoTraceConsumer
+	}
+
+	resymbolicateProducer, err := bus.NewPostgresProducer(pgPool, ingest.ResymbolicateTopic)
+	if err != nil {
+		log.Printf("failed to create resymbolicate producer: %v\n", err)
+	} else {
+		Server.ResymbolicateProducer = resymbolicateProducer
+	}
+
+	resymbolicateConsumer, err := bus.NewPostgresConsumer(pgPool, ingest.ResymbolicateTopic,
+		bus.WithPostgresBatchSize(1),
+		bus.WithPostgresPollInterval(resymbolicatePollInterval),
+		bus.WithPostgresLease(resymbolicateLease),
+		bus.WithPostgresRetryDelay(resymbolicateRetryDelay),
+	)
+	if err != nil {
+		log.Printf("failed to create resymbolicate consumer: %v\n", err)
+	} else {
+		Server.ResymbolicateConsumer = resymbolicateConsumer
+	}
+
 	if config.CloudEnv {
 		var batchSize = 20
 		ingestBatchSize := os.Getenv("INGEST_BATCH_SIZE")
@@ -419,6 +634,19 @@ func Init(config *ServerConfig) {
 		log.Println("pub/sub pull enabled:", pullEnabled)
 
 		if subscription != "" && pullEnabled {
+			opts := []bus.PubSubOption{}
+			deadLetterTopic := os.Getenv("INGEST_PUBSUB_DEAD_LETTER_TOPIC")
+			if deadLetterTopic != "" && config.DeadLetterMaxAttempts > 0 {
+				p, err := bus.NewPubSubProducer(context.Background(), deadLetterTopic)
+				if err != nil {
+					log.Printf("failed to create Pub/Sub dead-letter producer: %v\n", err)
+				} else {
+					log.Println("pub/sub dead-letter topic:", deadLetterTopic)
+					Server.DeadLetter = p
+					opts = append(opts, bus.WithPubSubDeadLetter(p, config.DeadLetterMaxAttempts))
+				}
+			}
+
 			// Pin the ack lease to the subscription's ack deadline. Left unset,
 			// the client derives it from the p99 processing time & floors it at
 			// 10s, so fast batches get the shortest lease & every message living
@@ -427,11 +655,11 @@ func Init(config *ServerConfig) {
 			consumer, err := bus.NewPubSubConsumer(
 				context.Background(),
 				subscription,
-				bus.WithPubSubReceiveSettings(pubsub.ReceiveSettings{
+				append(opts, bus.WithPubSubReceiveSettings(pubsub.ReceiveSettings{
 					MaxOutstandingMessages:     batchSize,
 					MinDurationPerAckExtension: ackLeaseDuration,
 					MaxDurationPerAckExtension: ackLeaseDuration,
-				}),
+				}))...,
 			)
 			if err != nil {
 				log.Printf("failed to create Pub/Sub consumer: %v\n", err)
@@ -439,6 +667,42 @@ func Init(config *ServerConfig) {
 				Server.BusConsumer = consumer
 			}
 		}
+	} else if config.BusBackend == bus.BackendPostgres {
+		var opts []bus.PostgresOption
+		if ingestBatchSize := os.Getenv("INGEST_BATCH_SIZE"); ingestBatchSize != "" {
+			batchSize, err := strconv.Atoi(ingestBatchSize)
+			if err != nil {
+				log.Printf("failed to parse INGEST_BATCH_SIZE: %v\n", err)
+			} else {
+				opts = append(opts, bus.WithPostgresBatchSize(batchSize))
+			}
+		}
+
+		if ingestPollInterval := os.Getenv("INGEST_POLL_INTERVAL"); ingestPollInterval != "" {
+			pollInterval, err := time.ParseDuration(ingestPollInterval)
+			if err != nil {
+				log.Printf("failed to parse INGEST_POLL_INTERVAL: %v\n", err)
+			} else {
+				opts = append(opts, bus.WithPostgresPollInterval(pollInterval))
+			}
+		}
+
+		if config.DeadLetterMaxAttempts > 0 {
+			p, err := bus.NewPostgresProducer(pgPool, ingest.IngestBatchDeadLetterTopic)
+			if err != nil {
+				log.Printf("failed to create Postgres dead-letter producer: %v\n", err)
+			} else {
+				Server.DeadLetter = p
+				opts = append(opts, bus.WithPostgresDeadLetter(p, config.DeadLetterMaxAttempts))
+			}
+		}
+
+		consumer, err := bus.NewPostgresConsumer(pgPool, ingest.IngestBatchTopic, opts...)
+		if err != nil {
+			log.Printf("failed to create Postgres consumer: %v\n", err)
+		} else {
+			Server.BusConsumer = consumer
+		}
 	} else {
 		var batchSize = 500
 		ingestBatchSize := os.Getenv("INGEST_BATCH_SIZE")
@@ -460,6 +724,27 @@ func Init(config *ServerConfig) {
 		}
 		log.Printf("iggy poll interval: %v\n", pollInterval)
 
+		opts := []bus.IggyOption{
+			bus.WithIggyBatchSize(batchSize),
+			bus.WithIggyPollInterval(pollInterval),
+		}
+		if config.DeadLetterMaxAttempts > 0 {
+			p, err := bus.NewIggyProducer(
+				config.IG.Addr,
+				config.IG.Username,
+				config.IG.Password,
+				"ingest-worker",
+				bus.DefaultStreamName,
+				ingest.IngestBatchDeadLetterTopic,
+			)
+			if err != nil {
+				log.Printf("failed to create Iggy dead-letter producer: %v\n", err)
+			} else {
+				Server.DeadLetter = p
+				opts = append(opts, bus.WithIggyDeadLetter(p, config.DeadLetterMaxAttempts))
+			}
+		}
+
 		consumer, err := bus.NewIggyGroupConsumer(
 			config.IG.Addr,
 			config.IG.Username,
@@ -467,8 +752,7 @@ func Init(config *ServerConfig) {
 			"ingest-batch-consumer",
 			bus.DefaultStreamName,
 			ingest.IngestBatchTopic,
-			bus.WithIggyBatchSize(batchSize),
-			bus.WithIggyPollInterval(pollInterval),
+			opts...,
 		)
 		if err != nil {
 			log.Printf("failed to create Iggy consumer: %v\n", err)
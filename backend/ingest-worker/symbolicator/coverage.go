@@ -0,0 +1,324 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/symbol"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Coverage tallies how much of the error stacktraces of an
+// app version symbolication resolved, along with the debug
+// ids of the binaries the symbolicator found no symbols for.
+type Coverage struct {
+	// VersionName is the app version the
+	// errors were thrown in.
+	VersionName string
+	// VersionCode is the app build the
+	// errors were thrown in.
+	VersionCode string
+	// Frames is the count of error frames
+	// needing symbolication.
+	Frames uint64
+	// Symbolicated is the count of error
+	// frames symbolication resolved.
+	Symbolicated uint64
+	// MissingDebugIDs are the debug ids or
+	// UUIDs of the binaries crashes referenced
+	// without symbols.
+	MissingDebugIDs []string
+}
+
+// coverage maps app versions to their
+// symbolication coverage.
+type coverage map[[2]string]*Coverage
+
+// add tallies the frames of an error
+// event.
+func (c coverage) add(ev event.EventField, frames, symbolicated int, missing ...string) {
+	if frames < 1 && len(missing) < 1 {
+		return
+	}
+
+	key := [2]string{ev.Attribute.AppVersion, ev.Attribute.AppBuild}
+	cov, ok := c[key]
+	if !ok {
+		cov = &Coverage{
+			VersionName: key[0],
+			VersionCode: key[1],
+		}
+		c[key] = cov
+	}
+
+	cov.Frames += uint64(frames)
+	cov.Symbolicated += uint64(min(symbolicated, frames))
+
+	for _, id := range missing {
+		if id != "" && !slices.Contains(cov.MissingDebugIDs, id) {
+			cov.MissingDebugIDs = append(cov.MissingDebugIDs, id)
+		}
+	}
+}
+
+// errorFrames counts the exception frames
+// of an exception or ANR event.
+func errorFrames(ev event.EventField) (count int) {
+	var exceptions event.ExceptionUnits
+	switch {
+	case ev.IsException():
+		exceptions = ev.Exception.Exceptions
+	case ev.IsANR():
+		exceptions = ev.ANR.Exceptions
+	}
+
+	for _, excep := range exceptions {
+		count += len(excep.Frames)
+	}
+
+	return
+}
+
+// isError reports whether the event is an
+// exception or ANR.
+func isError(ev event.EventField) bool {
+	return ev.IsException() || ev.IsANR()
+}
+
+// normalizeDebugID lowercases a debug id &
+// strips its dashes, so the UUIDs of binary
+// images match symbolicator's debug ids.
+func normalizeDebugID(id string) string {
+	return strings.ToLower(strings.ReplaceAll(id, "-", ""))
+}
+
+// unsymbolicatedImages picks the debug ids of the app's
+// binary images, skipping system binaries. Used when no
+// mapping was uploaded for the crash's build.
+func unsymbolicatedImages(ev event.EventField) (ids []string) {
+	if !ev.IsException() {
+		return
+	}
+
+	for _, image := range ev.Exception.BinaryImages {
+		if image.System || image.Uuid == "" {
+			continue
+		}
+		ids = append(ids, image.Uuid)
+	}
+
+	return
+}
+
+// hasMappingType reports whether the mappings
+// contain a mapping of type t.
+func hasMappingType(mappings map[string]symbol.MappingType, t symbol.MappingType) bool {
+	for _, mType := range mappings {
+		if mType == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stacktraceEvents maps the index of each request
+// stacktrace to the index of its event.
+func stacktraceEvents(lut []stacktraceEntry) map[int]int {
+	events := make(map[int]int)
+	for _, entry := range lut {
+		if entry[1] == -1 || entry[2] == -1 {
+			continue
+		}
+		events[entry[5]] = entry[0]
+	}
+
+	return events
+}
+
+// coverJVM queues the JVM exception or ANR event at
+// index for tallying once symbolicated, if the build
+// has a proguard mapping. Otherwise, its frames are
+// tallied as unsymbolicated right away.
+func (s *Symbolicator) coverJVM(ev event.EventField, index int, mappings map[string]symbol.MappingType, queued []int) []int {
+	if hasMappingType(mappings, symbol.TypeProguard) {
+		return append(queued, index)
+	}
+
+	s.coverage.add(ev, errorFrames(ev), 0)
+	return queued
+}
+
+// coverage tallies the frames of the queued JVM errors.
+// Symbolicator doesn't report which JVM frames it
+// resolved, so all frames of an error count as
+// symbolicated when the proguard mapping was applied.
+// When symbolicator couldn't find the mapping, its UUID
+// is reported missing.
+func (js *jvmSymbolicator) coverage(evs []event.EventField, indexes []int, err error, cov coverage) {
+	var missing []string
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrJVMSymbolicationFailure):
+		for _, module := range js.response.Errors {
+			missing = append(missing, module.UUID)
+		}
+	default:
+		return
+	}
+
+	for _, i := range indexes {
+		frames := errorFrames(evs[i])
+		if len(missing) > 0 {
+			cov.add(evs[i], frames, 0, missing...)
+			continue
+		}
+		cov.add(evs[i], frames, frames)
+	}
+}
+
+// coverage tallies the frames of the Dart exceptions
+// symbolicated. The binary image of an exception with
+// no frames symbolicated is reported missing.
+func (ns *nativeSymbolicator) coverage(evs []event.EventField, cov coverage) {
+	if ns.request == nil || ns.response == nil {
+		return
+	}
+
+	frames := make(map[int]int)
+	resolved := make(map[int]int)
+	for n, i := range stacktraceEvents(ns.stacktraceLUT) {
+		frames[i] += len(ns.request.Stacktraces[n].Frames)
+		if n >= len(ns.response.Stacktraces) {
+			continue
+		}
+
+		// inlined frames share the index
+		// of their original frame
+		seen := make(map[int]bool)
+		for _, f := range ns.response.Stacktraces[n].Frames {
+			if f.Status == "symbolicated" && !seen[f.OriginalIndex] {
+				seen[f.OriginalIndex] = true
+				resolved[i]++
+			}
+		}
+	}
+
+	for _, i := range slices.Sorted(maps.Keys(frames)) {
+		var missing []string
+		if resolved[i] < 1 {
+			missing = unsymbolicatedImages(evs[i])
+		}
+		cov.add(evs[i], frames[i], resolved[i], missing...)
+	}
+}
+
+// coverage counts the frames of the app's binaries in the
+// crash report & those symbolicated, along with the UUIDs
+// of the app's binaries symbolicator found no dSYM for.
+// Frames of system binaries are left out.
+func (as appleSymbolicator) coverage(ev event.EventField) (frames, symbolicated int, missing []string) {
+	system := make(map[string]bool)
+	images := make(map[string]string)
+	for _, image := range ev.Exception.BinaryImages {
+		if image.System {
+			system[image.Name] = true
+			continue
+		}
+		images[normalizeDebugID(image.Uuid)] = image.Uuid
+	}
+
+	inApp := func(f event.Frame) bool {
+		return f.FrameiOS == nil || !system[f.BinaryName]
+	}
+
+	for i, excep := range ev.Exception.Exceptions {
+		for _, f := range excep.Frames {
+			if inApp(f) {
+				frames++
+			}
+		}
+
+		if as.response == nil || i >= len(as.response.Stacktraces) {
+			continue
+		}
+
+		seen := make(map[int]bool)
+		for _, f := range as.response.Stacktraces[i].Frames {
+			if f.Status != "symbolicated" || f.OriginalIndex >= len(excep.Frames) || seen[f.OriginalIndex] {
+				continue
+			}
+			seen[f.OriginalIndex] = true
+			if inApp(excep.Frames[f.OriginalIndex]) {
+				symbolicated++
+			}
+		}
+	}
+
+	if as.response != nil {
+		for _, module := range as.response.Modules {
+			if module.DebugStatus != "missing" {
+				continue
+			}
+			if uuid, ok := images[normalizeDebugID(module.DebugId)]; ok {
+				missing = append(missing, uuid)
+			}
+		}
+	}
+
+	return
+}
+
+// coverage tallies the frames of the JS exceptions
+// symbolicator resolved.
+func (js *jsSymbolicator) coverage(evs []event.EventField, cov coverage) {
+	if js.request == nil || js.response == nil {
+		return
+	}
+
+	frames := make(map[int]int)
+	resolved := make(map[int]int)
+	for n, i := range stacktraceEvents(js.stacktraceLUT) {
+		frames[i] += len(js.request.Stacktraces[n].Frames)
+		if n >= len(js.response.Stacktraces) {
+			continue
+		}
+
+		for _, f := range js.response.Stacktraces[n].Frames {
+			if f.Data != nil && f.Data.Symbolicated {
+				resolved[i]++
+			}
+		}
+	}
+
+	for _, i := range slices.Sorted(maps.Keys(frames)) {
+		cov.add(evs[i], frames[i], resolved[i])
+	}
+}
+
+// coverage tallies the frames of the Hermes
+// exceptions resolved by source maps.
+func (hs *hermesSymbolicator) coverage(evs []event.EventField, cov coverage) {
+	for _, i := range slices.Sorted(maps.Keys(hs.events)) {
+		cov.add(evs[i], errorFrames(evs[i]), hs.resolved[i])
+	}
+}
+
+// Coverage returns the symbolication coverage of the
+// errors of the last Symbolicate call, one entry per
+// app version.
+func (s *Symbolicator) Coverage() (coverages []Coverage) {
+	for _, cov := range s.coverage {
+		coverages = append(coverages, *cov)
+	}
+
+	slices.SortFunc(coverages, func(a, b Coverage) int {
+		if n := strings.Compare(a.VersionName, b.VersionName); n != 0 {
+			return n
+		}
+		return strings.Compare(a.VersionCode, b.VersionCode)
+	})
+
+	return
+}
@@ -0,0 +1,201 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// makeCoverageEvent builds an exception event of
+// the framework with frameCount frames.
+func makeCoverageEvent(framework, version, build string, frameCount int) event.EventField {
+	frames := make(event.Frames, frameCount)
+	return event.EventField{
+		Type:      event.TypeException,
+		Attribute: event.Attribute{AppVersion: version, AppBuild: build},
+		Exception: &event.Exception{
+			Framework:  framework,
+			Exceptions: event.ExceptionUnits{{Frames: frames}},
+		},
+	}
+}
+
+func TestCoverageAdd(t *testing.T) {
+	cov := make(coverage)
+	ev := makeCoverageEvent(event.FrameworkJVM, "1.0.0", "100", 4)
+
+	cov.add(ev, 4, 4)
+	cov.add(ev, 4, 1, "a", "b")
+	cov.add(ev, 2, 5, "a", "")
+	cov.add(makeCoverageEvent(event.FrameworkJVM, "0.9.0", "90", 0), 0, 0)
+
+	s := &Symbolicator{coverage: cov}
+	got := s.Coverage()
+	if len(got) != 1 {
+		t.Fatalf("coverages = %d, want 1", len(got))
+	}
+
+	want := Coverage{
+		VersionName:     "1.0.0",
+		VersionCode:     "100",
+		Frames:          10,
+		Symbolicated:    7,
+		MissingDebugIDs: []string{"a", "b"},
+	}
+	if got[0].VersionName != want.VersionName || got[0].VersionCode != want.VersionCode || got[0].Frames != want.Frames || got[0].Symbolicated != want.Symbolicated || !slices.Equal(got[0].MissingDebugIDs, want.MissingDebugIDs) {
+		t.Errorf("coverage = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestCoverageSorted(t *testing.T) {
+	cov := make(coverage)
+	cov.add(makeCoverageEvent(event.FrameworkJVM, "2.0.0", "200", 1), 1, 1)
+	cov.add(makeCoverageEvent(event.FrameworkJVM, "1.0.0", "101", 1), 1, 1)
+	cov.add(makeCoverageEvent(event.FrameworkJVM, "1.0.0", "100", 1), 1, 1)
+
+	s := &Symbolicator{coverage: cov}
+	var got []string
+	for _, c := range s.Coverage() {
+		got = append(got, fmt.Sprintf("%s (%s)", c.VersionName, c.VersionCode))
+	}
+
+	want := []string{"1.0.0 (100)", "1.0.0 (101)", "2.0.0 (200)"}
+	if !slices.Equal(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+}
+
+func TestJVMCoverage(t *testing.T) {
+	evs := []event.EventField{
+		makeCoverageEvent(event.FrameworkJVM, "1.0.0", "100", 3),
+		makeCoverageEvent(event.FrameworkJVM, "1.0.0", "100", 2),
+	}
+
+	js := &jvmSymbolicator{}
+	cov := make(coverage)
+	js.coverage(evs, []int{0, 1}, nil, cov)
+
+	got := cov[[2]string{"1.0.0", "100"}]
+	if got.Frames != 5 || got.Symbolicated != 5 || len(got.MissingDebugIDs) != 0 {
+		t.Errorf("coverage = %+v, want 5 of 5 frames symbolicated", got)
+	}
+
+	// symbolicator couldn't find
+	// the proguard mapping
+	js.response = &responseJVM{Errors: []moduleJVM{{UUID: "b8a3c1f0-0000-4000-8000-000000000001", Type: "proguard"}}}
+	cov = make(coverage)
+	js.coverage(evs, []int{0}, fmt.Errorf("jvm: %w", ErrJVMSymbolicationFailure), cov)
+
+	got = cov[[2]string{"1.0.0", "100"}]
+	if got.Frames != 3 || got.Symbolicated != 0 || !slices.Equal(got.MissingDebugIDs, []string{"b8a3c1f0-0000-4000-8000-000000000001"}) {
+		t.Errorf("coverage = %+v, want 0 of 3 frames symbolicated & missing mapping", got)
+	}
+
+	// other failures aren't
+	// tallied
+	cov = make(coverage)
+	js.coverage(evs, []int{0}, fmt.Errorf("connection refused"), cov)
+	if len(cov) != 0 {
+		t.Errorf("coverages = %d, want 0", len(cov))
+	}
+}
+
+func TestNativeCoverage(t *testing.T) {
+	symbolicated := makeCoverageEvent(event.FrameworkDart, "1.0.0", "100", 2)
+	symbolicated.Exception.BinaryImages = []event.BinaryImage{{Uuid: "aaaa"}}
+	unsymbolicated := makeCoverageEvent(event.FrameworkDart, "1.0.0", "100", 2)
+	unsymbolicated.Exception.BinaryImages = []event.BinaryImage{{Uuid: "bbbb"}}
+	evs := []event.EventField{symbolicated, unsymbolicated}
+
+	ns := &nativeSymbolicator{}
+	ns.ensureRequestInitialized()
+	ns.parseExceptions(evs[0].Exception.Exceptions, 0)
+	ns.parseExceptions(evs[1].Exception.Exceptions, 1)
+
+	ns.response = &responseNative{
+		Stacktraces: []stacktraceNative{
+			{Frames: []frameNative{
+				// first frame was unfurled
+				// into an inlined frame
+				{Status: "symbolicated", OriginalIndex: 0},
+				{Status: "symbolicated", OriginalIndex: 0},
+				{Status: "symbolicated", OriginalIndex: 1},
+			}},
+			{Frames: []frameNative{
+				{Status: "missing", OriginalIndex: 0},
+				{Status: "missing", OriginalIndex: 1},
+			}},
+		},
+	}
+
+	cov := make(coverage)
+	ns.coverage(evs, cov)
+
+	got := cov[[2]string{"1.0.0", "100"}]
+	if got.Frames != 4 || got.Symbolicated != 2 || !slices.Equal(got.MissingDebugIDs, []string{"bbbb"}) {
+		t.Errorf("coverage = %+v, want 2 of 4 frames symbolicated & bbbb missing", got)
+	}
+}
+
+func TestAppleCoverage(t *testing.T) {
+	ev := makeAppleExceptionEvent(event.ExceptionUnits{
+		{
+			Frames: event.Frames{
+				{FrameiOS: &event.FrameiOS{BinaryName: "DemoApp"}},
+				{FrameiOS: &event.FrameiOS{BinaryName: "DemoApp"}},
+				{FrameiOS: &event.FrameiOS{BinaryName: "UIKitCore"}},
+			},
+		},
+	})
+	ev.Exception.BinaryImages = []event.BinaryImage{
+		{Name: "DemoApp", Uuid: "C3B5A2D1-0000-4000-8000-00000000000A"},
+		{Name: "UIKitCore", Uuid: "D4C6B3E2-0000-4000-8000-00000000000B", System: true},
+	}
+
+	as := appleSymbolicator{
+		response: &responseApple{
+			Stacktraces: []stacktraceApple{
+				{Frames: []frameApple{
+					{Status: "symbolicated", OriginalIndex: 0},
+					{Status: "missing", OriginalIndex: 1},
+					{Status: "missing", OriginalIndex: 2},
+				}},
+			},
+			Modules: []moduleApple{
+				{DebugStatus: "missing", DebugId: "c3b5a2d1-0000-4000-8000-00000000000a"},
+				{DebugStatus: "missing", DebugId: "d4c6b3e2-0000-4000-8000-00000000000b"},
+			},
+		},
+	}
+
+	frames, symbolicated, missing := as.coverage(ev)
+	if frames != 2 || symbolicated != 1 {
+		t.Errorf("frames = %d, symbolicated = %d, want 1 of 2 app frames symbolicated", frames, symbolicated)
+	}
+
+	// system binaries aren't
+	// reported missing
+	if !slices.Equal(missing, []string{"C3B5A2D1-0000-4000-8000-00000000000A"}) {
+		t.Errorf("missing = %v, want the app binary", missing)
+	}
+}
+
+func TestHermesCoverage(t *testing.T) {
+	evs := []event.EventField{
+		makeCoverageEvent(event.FrameworkJS, "1.0.0", "100", 3),
+	}
+
+	hs := &hermesSymbolicator{
+		events:   map[int][]string{0: {"index.android.bundle.map"}},
+		resolved: map[int]int{0: 2},
+	}
+
+	cov := make(coverage)
+	hs.coverage(evs, cov)
+
+	got := cov[[2]string{"1.0.0", "100"}]
+	if got.Frames != 3 || got.Symbolicated != 2 {
+		t.Errorf("coverage = %+v, want 2 of 3 frames symbolicated", got)
+	}
+}
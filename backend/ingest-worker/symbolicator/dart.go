@@ -0,0 +1,99 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/symbol"
+	"context"
+	"regexp"
+	"strings"
+)
+
+// dartIdentRE matches the identifiers
+// of Dart symbol names.
+var dartIdentRE = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// dartDeobfuscator de-obfuscates the Dart identifiers of
+// Flutter exceptions built with `--obfuscate`, using the
+// build's obfuscation maps. Runs after native symbolication
+// has resolved instruction addresses to obfuscated names.
+type dartDeobfuscator struct {
+	// events maps the index of each exception
+	// event to its obfuscation map keys.
+	events map[int][]string
+}
+
+// add queues the exception event at index for
+// de-obfuscation, if the build has obfuscation
+// maps.
+func (dd *dartDeobfuscator) add(mappings map[string]symbol.MappingType, index int) {
+	keys := mappingKeys(mappings, symbol.TypeDartObfuscation)
+	if len(keys) == 0 {
+		return
+	}
+
+	if dd.events == nil {
+		dd.events = make(map[int][]string)
+	}
+
+	dd.events[index] = keys
+}
+
+// deobfuscate rewrites the exception types & frame
+// names of the queued events.
+func (dd *dartDeobfuscator) deobfuscate(ctx context.Context, evs []event.EventField, files *symbolFiles) (err error) {
+	lookups := make(map[string]map[string]string)
+
+	for i, keys := range dd.events {
+		// events of the same build share
+		// the same set of keys
+		cacheKey := strings.Join(keys, ",")
+		names, ok := lookups[cacheKey]
+		if !ok {
+			names = make(map[string]string)
+			for _, key := range keys {
+				data, errGet := files.get(ctx, key)
+				if errGet != nil {
+					return errGet
+				}
+
+				m, errParse := symbol.ParseDartObfuscationMap(data)
+				if errParse != nil {
+					return errParse
+				}
+
+				for obfuscated, original := range m {
+					names[obfuscated] = original
+				}
+			}
+			lookups[cacheKey] = names
+		}
+
+		exceptions := evs[i].Exception.Exceptions
+		for j := range exceptions {
+			exceptions[j].Type = rewriteDartName(exceptions[j].Type, names)
+			for k := range exceptions[j].Frames {
+				frame := &exceptions[j].Frames[k]
+				frame.MethodName = rewriteDartName(frame.MethodName, names)
+				frame.ClassName = rewriteDartName(frame.ClassName, names)
+			}
+		}
+	}
+
+	return
+}
+
+// rewriteDartName replaces each obfuscated
+// identifier of a Dart symbol name with
+// its original.
+func rewriteDartName(name string, names map[string]string) string {
+	if name == "" {
+		return name
+	}
+
+	return dartIdentRE.ReplaceAllStringFunc(name, func(ident string) string {
+		if original, ok := names[ident]; ok {
+			return original
+		}
+		return ident
+	})
+}
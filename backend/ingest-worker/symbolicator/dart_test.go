@@ -0,0 +1,104 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/symbol"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestSymbolFiles serves symbol files by
+// key, like symboloader's /symbols endpoint.
+func newTestSymbolFiles(t *testing.T, files map[string]string) *symbolFiles {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/symbols" || r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		data, ok := files[r.URL.Query().Get("id")]
+		if !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	t.Cleanup(server.Close)
+
+	return newSymbolFiles(server.URL, "test-token")
+}
+
+func TestRewriteDartName(t *testing.T) {
+	names := map[string]string{
+		"ex": "HomePage",
+		"ey": "_HomePageState",
+		"ez": "onPressed",
+	}
+
+	cases := map[string]string{
+		"ey.ez":                  "_HomePageState.onPressed",
+		"ex.<anonymous closure>": "HomePage.<anonymous closure>",
+		"StateError":             "StateError",
+		"":                       "",
+	}
+
+	for in, want := range cases {
+		if got := rewriteDartName(in, names); got != want {
+			t.Errorf("rewriteDartName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDartDeobfuscate(t *testing.T) {
+	files := newTestSymbolFiles(t, map[string]string{
+		"aa/bb/dart_obfuscation": `["HomePage", "ex", "_HomePageState", "ey", "onPressed", "ez", "CheckoutError", "fa"]`,
+	})
+
+	evs := []event.EventField{
+		{
+			Type: event.TypeException,
+			Exception: &event.Exception{
+				Exceptions: event.ExceptionUnits{
+					{
+						Type: "fa",
+						Frames: event.Frames{
+							{MethodName: "ey.ez", InstructionAddr: "0x1"},
+							{MethodName: "runApp", InstructionAddr: "0x2"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var dd dartDeobfuscator
+	dd.add(map[string]symbol.MappingType{
+		"cc/dd/debuginfo":        symbol.TypeElfDebug,
+		"aa/bb/dart_obfuscation": symbol.TypeDartObfuscation,
+	}, 0)
+
+	if err := dd.deobfuscate(context.Background(), evs, files); err != nil {
+		t.Fatalf("deobfuscate failed: %v", err)
+	}
+
+	unit := evs[0].Exception.Exceptions[0]
+	if unit.Type != "CheckoutError" {
+		t.Errorf("type = %q, want CheckoutError", unit.Type)
+	}
+	if unit.Frames[0].MethodName != "_HomePageState.onPressed" {
+		t.Errorf("frame 0 = %q, want _HomePageState.onPressed", unit.Frames[0].MethodName)
+	}
+	if unit.Frames[1].MethodName != "runApp" {
+		t.Errorf("frame 1 = %q, want runApp", unit.Frames[1].MethodName)
+	}
+
+	// builds without obfuscation
+	// maps are left alone
+	var none dartDeobfuscator
+	none.add(map[string]symbol.MappingType{"cc/dd/debuginfo": symbol.TypeElfDebug}, 0)
+	if len(none.events) != 0 {
+		t.Errorf("expected no queued events, got %v", none.events)
+	}
+}
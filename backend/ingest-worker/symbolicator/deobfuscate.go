@@ -0,0 +1,242 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/opsys"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Payload is a kind of event payload whose obfuscated
+// JVM class names are de-obfuscated along with crashes.
+type Payload string
+
+const (
+	// PayloadLog is the body of log
+	// & string events.
+	PayloadLog Payload = "log"
+
+	// PayloadCustom is the name of
+	// custom events.
+	PayloadCustom Payload = "custom"
+
+	// PayloadGesture is the target of click,
+	// long click & scroll events.
+	PayloadGesture Payload = "gesture"
+)
+
+// payloads is a list of all payloads.
+var payloads = []Payload{
+	PayloadLog,
+	PayloadCustom,
+	PayloadGesture,
+}
+
+// maxTextClasses is the number of class names
+// looked up per log body or custom event name.
+const maxTextClasses = 32
+
+// classNameRE matches dotted JVM names in free
+// text, like `a.b.c` or `a.b.c$d.e`. Names without
+// a package aren't matched, they are
+// indistinguishable from plain words.
+var classNameRE = regexp.MustCompile(`[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)+`)
+
+// obfuscatedNameRE matches JVM names made of short
+// lower-case segments only, like `a.b.c` or `ab.c$d`,
+// as ProGuard & R8 name obfuscated classes.
+var obfuscatedNameRE = regexp.MustCompile(`^[a-z]{1,3}(?:[.$][a-z]{1,3})+$`)
+
+// minTextSegments is the least number of dotted
+// segments of an obfuscated name in free text, so
+// text like `e.g.` isn't taken for a class name.
+const minTextSegments = 3
+
+// obfuscated is true if the name looks like an
+// obfuscated class name, with at least
+// minSegments dotted segments.
+func obfuscated(name string, minSegments int) bool {
+	return strings.Count(name, ".")+1 >= minSegments && obfuscatedNameRE.MatchString(name)
+}
+
+// obfuscatedNames finds up to maxTextClasses
+// obfuscated class names in free text.
+func obfuscatedNames(text string) (names []string) {
+	for _, name := range classNameRE.FindAllString(text, -1) {
+		if !obfuscated(name, minTextSegments) {
+			continue
+		}
+
+		names = append(names, name)
+		if len(names) == maxTextClasses {
+			break
+		}
+	}
+
+	return
+}
+
+// DefaultPayloads are the payloads de-obfuscated, as
+// configured by the comma separated
+// `SYMBOLICATE_JVM_PAYLOADS` env var. No payloads are
+// de-obfuscated when unset or empty.
+var DefaultPayloads = payloadsFromEnv()
+
+// payloadsFromEnv reads the payloads to
+// de-obfuscate from env.
+func payloadsFromEnv() []Payload {
+	value, ok := os.LookupEnv("SYMBOLICATE_JVM_PAYLOADS")
+	if !ok {
+		return nil
+	}
+
+	result, err := ParsePayloads(value)
+	if err != nil {
+		fmt.Printf("invalid SYMBOLICATE_JVM_PAYLOADS, de-obfuscating no payloads: %v\n", err)
+		return nil
+	}
+
+	return result
+}
+
+// ParsePayloads parses a comma separated
+// list of payloads.
+func ParsePayloads(value string) (result []Payload, err error) {
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		p := Payload(name)
+		if !slices.Contains(payloads, p) {
+			err = fmt.Errorf("unknown payload %q", name)
+			return
+		}
+
+		if !slices.Contains(result, p) {
+			result = append(result, p)
+		}
+	}
+
+	return
+}
+
+// NeedsDeobfuscation is true if the event carries a
+// payload whose class names are de-obfuscated & that
+// has obfuscated class names.
+func NeedsDeobfuscation(ev event.EventField) bool {
+	return needsDeobfuscation(ev, DefaultPayloads)
+}
+
+// needsDeobfuscation is true if the event carries one
+// of the payloads to de-obfuscate, with obfuscated
+// class names.
+func needsDeobfuscation(ev event.EventField, payloads []Payload) bool {
+	if opsys.ToFamily(ev.Attribute.OSName) != opsys.Android {
+		return false
+	}
+
+	switch {
+	case ev.IsLog() && ev.Log != nil:
+		return slices.Contains(payloads, PayloadLog) && len(obfuscatedNames(ev.Log.Body)) > 0
+	case ev.IsString() && ev.LogString != nil:
+		return slices.Contains(payloads, PayloadLog) && len(obfuscatedNames(ev.LogString.String)) > 0
+	case ev.IsCustom() && ev.Custom != nil:
+		return slices.Contains(payloads, PayloadCustom) && len(obfuscatedNames(ev.Custom.Name)) > 0
+	case ev.IsGestureClick() && ev.GestureClick != nil:
+		return slices.Contains(payloads, PayloadGesture) && obfuscated(ev.GestureClick.Target, 2)
+	case ev.IsGestureLongClick() && ev.GestureLongClick != nil:
+		return slices.Contains(payloads, PayloadGesture) && obfuscated(ev.GestureLongClick.Target, 2)
+	case ev.IsGestureScroll() && ev.GestureScroll != nil:
+		return slices.Contains(payloads, PayloadGesture) && obfuscated(ev.GestureScroll.Target, 2)
+	}
+
+	return false
+}
+
+// addPayload adds the class names of the
+// payload of the event at index to the
+// request.
+func (js *jvmSymbolicator) addPayload(ev event.EventField, index int) {
+	js.payloadEvents = append(js.payloadEvents, index)
+
+	switch ev.Type {
+	case event.TypeLog:
+		js.addText(ev.Log.Body)
+	case event.TypeString:
+		js.addText(ev.LogString.String)
+	case event.TypeCustom:
+		js.addText(ev.Custom.Name)
+	case event.TypeGestureClick:
+		js.request.AddClass(ev.GestureClick.Target)
+	case event.TypeGestureLongClick:
+		js.request.AddClass(ev.GestureLongClick.Target)
+	case event.TypeGestureScroll:
+		js.request.AddClass(ev.GestureScroll.Target)
+	}
+}
+
+// addText adds the obfuscated class names found
+// in free text to the request. Names referring to
+// a member, like `a.b.c.d` for method `d` of
+// class `a.b.c`, add their class too.
+func (js *jvmSymbolicator) addText(text string) {
+	for _, name := range obfuscatedNames(text) {
+		js.request.AddClass(name)
+		if strings.Count(name, ".") > 1 {
+			js.request.AddClass(name[:strings.LastIndex(name, ".")])
+		}
+	}
+}
+
+// rewritePayload rewrites the class names
+// of the event's payload.
+func (r responseJVM) rewritePayload(ev *event.EventField) {
+	switch ev.Type {
+	case event.TypeLog:
+		ev.Log.Body = r.rewriteText(ev.Log.Body)
+	case event.TypeString:
+		ev.LogString.String = r.rewriteText(ev.LogString.String)
+	case event.TypeCustom:
+		ev.Custom.Name = r.rewriteText(ev.Custom.Name)
+	case event.TypeGestureClick:
+		ev.GestureClick.Target = r.rewriteClass(ev.GestureClick.Target, ev.GestureClick.Target)
+	case event.TypeGestureLongClick:
+		ev.GestureLongClick.Target = r.rewriteClass(ev.GestureLongClick.Target, ev.GestureLongClick.Target)
+	case event.TypeGestureScroll:
+		ev.GestureScroll.Target = r.rewriteClass(ev.GestureScroll.Target, ev.GestureScroll.Target)
+	}
+}
+
+// rewriteText rewrites the obfuscated class names
+// found in free text, leaving the rest of the text
+// as is.
+func (r responseJVM) rewriteText(text string) string {
+	seen := 0
+	return classNameRE.ReplaceAllStringFunc(text, func(name string) string {
+		// only names sent for lookup
+		// are rewritten
+		if !obfuscated(name, minTextSegments) {
+			return name
+		}
+		seen++
+		if seen > maxTextClasses {
+			return name
+		}
+
+		if class, ok := r.Classes[name]; ok {
+			return class
+		}
+
+		i := strings.LastIndex(name, ".")
+		if class, ok := r.Classes[name[:i]]; ok {
+			return class + name[i:]
+		}
+
+		return name
+	})
+}
@@ -0,0 +1,145 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestParsePayloads(t *testing.T) {
+	got, err := ParsePayloads(" log, gesture,log,,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []Payload{PayloadLog, PayloadGesture}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got, err = ParsePayloads("")
+	if err != nil || len(got) != 0 {
+		t.Errorf("got %v, %v, want no payloads", got, err)
+	}
+
+	if _, err := ParsePayloads("log,crash"); err == nil {
+		t.Errorf("expected error for unknown payload")
+	}
+}
+
+func TestPayloadsFromEnv(t *testing.T) {
+	t.Setenv("SYMBOLICATE_JVM_PAYLOADS", "")
+	os.Unsetenv("SYMBOLICATE_JVM_PAYLOADS")
+	if got := payloadsFromEnv(); len(got) != 0 {
+		t.Errorf("got %v, want no payloads when unset", got)
+	}
+
+	t.Setenv("SYMBOLICATE_JVM_PAYLOADS", "log,custom")
+	if got, want := payloadsFromEnv(), []Payload{PayloadLog, PayloadCustom}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNeedsDeobfuscation(t *testing.T) {
+	android := event.Attribute{OSName: "android"}
+
+	cases := []struct {
+		name     string
+		ev       event.EventField
+		payloads []Payload
+		want     bool
+	}{
+		{
+			name:     "log with class name",
+			ev:       event.EventField{Type: event.TypeLog, Attribute: android, Log: &event.Log{Body: "failed in a.b.c"}},
+			payloads: payloads,
+			want:     true,
+		},
+		{
+			name:     "log without class name",
+			ev:       event.EventField{Type: event.TypeLog, Attribute: android, Log: &event.Log{Body: "failed to load"}},
+			payloads: payloads,
+			want:     false,
+		},
+		{
+			name:     "string when log payload is off",
+			ev:       event.EventField{Type: event.TypeString, Attribute: android, LogString: &event.LogString{String: "a.b.c"}},
+			payloads: []Payload{PayloadGesture},
+			want:     false,
+		},
+		{
+			name:     "custom with class name",
+			ev:       event.EventField{Type: event.TypeCustom, Attribute: android, Custom: &event.Custom{Name: "open a.b.c"}},
+			payloads: []Payload{PayloadCustom},
+			want:     true,
+		},
+		{
+			name:     "gesture click with target",
+			ev:       event.EventField{Type: event.TypeGestureClick, Attribute: android, GestureClick: &event.GestureClick{Target: "f.g"}},
+			payloads: []Payload{PayloadGesture},
+			want:     true,
+		},
+		{
+			name:     "log with url & abbreviation",
+			ev:       event.EventField{Type: event.TypeLog, Attribute: android, Log: &event.Log{Body: "e.g. fetching https://api.example.com for user@example.com"}},
+			payloads: payloads,
+			want:     false,
+		},
+		{
+			name:     "gesture click with unobfuscated target",
+			ev:       event.EventField{Type: event.TypeGestureClick, Attribute: android, GestureClick: &event.GestureClick{Target: "android.widget.Button"}},
+			payloads: payloads,
+			want:     false,
+		},
+		{
+			name:     "gesture click on ios",
+			ev:       event.EventField{Type: event.TypeGestureClick, Attribute: event.Attribute{OSName: "ios"}, GestureClick: &event.GestureClick{Target: "f.g"}},
+			payloads: payloads,
+			want:     false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := needsDeobfuscation(c.ev, c.payloads); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestAddText(t *testing.T) {
+	js := jvmSymbolicator{request: &requestJVM{}}
+	js.addText("crash in a.b.c.d, e.g. retrying f.g via sh.measure.sample")
+
+	want := []string{"a.b.c.d", "a.b.c"}
+	if !slices.Equal(js.request.Classes, want) {
+		t.Errorf("got %v, want %v", js.request.Classes, want)
+	}
+}
+
+func TestRewritePayload(t *testing.T) {
+	response := responseJVM{
+		Classes: map[string]string{
+			"a.b.c": "sh.measure.sample.ExceptionDemoActivity",
+			"f.g":   "sh.measure.sample.HomeActivity",
+		},
+	}
+
+	log := event.EventField{Type: event.TypeLog, Log: &event.Log{Body: "crash in a.b.c.d, retrying f.g from x.y.z"}}
+	response.rewritePayload(&log)
+	if want := "crash in sh.measure.sample.ExceptionDemoActivity.d, retrying f.g from x.y.z"; log.Log.Body != want {
+		t.Errorf("got %q, want %q", log.Log.Body, want)
+	}
+
+	click := event.EventField{Type: event.TypeGestureClick, GestureClick: &event.GestureClick{Target: "f.g"}}
+	response.rewritePayload(&click)
+	if want := "sh.measure.sample.HomeActivity"; click.GestureClick.Target != want {
+		t.Errorf("got %q, want %q", click.GestureClick.Target, want)
+	}
+
+	scroll := event.EventField{Type: event.TypeGestureScroll, GestureScroll: &event.GestureScroll{Target: "android.widget.ScrollView"}}
+	response.rewritePayload(&scroll)
+	if want := "android.widget.ScrollView"; scroll.GestureScroll.Target != want {
+		t.Errorf("got %q, want %q", scroll.GestureScroll.Target, want)
+	}
+}
@@ -4,6 +4,11 @@
 // over HTTP.
 //
 // It resolves obfuscated class names and method names (JVM/ProGuard),
-// binary instruction addresses (Apple/dSYM), and native instruction
-// addresses (Dart/ELF) into human-readable stack traces.
+// binary instruction addresses (Apple/dSYM), native instruction
+// addresses (Dart/ELF) and Hermes bytecode offsets (React Native)
+// into human-readable stack traces. Obfuscated Dart names are
+// restored from Flutter's obfuscation maps. JVM frames of CPU
+// profiles are de-obfuscated the same way, as are JVM class names
+// found in log bodies, custom event names, gesture targets & heap
+// dump reports.
 package symbolicator
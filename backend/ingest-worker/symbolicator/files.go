@@ -0,0 +1,91 @@
+package symbolicator
+
+import (
+	"backend/libs/symbol"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// symbolFileTimeout is the time allowed to
+// download a single symbol file.
+const symbolFileTimeout = 30 * time.Second
+
+// symbolFiles downloads symbol files that are applied by the
+// ingest worker itself, instead of by Sentry's Symbolicator.
+// Files are downloaded from symboloader's /symbols endpoint &
+// cached for the duration of a batch.
+type symbolFiles struct {
+	origin string
+	token  string
+	cache  map[string][]byte
+}
+
+// newSymbolFiles creates a symbol file downloader
+// for the symboloader origin.
+func newSymbolFiles(origin, token string) *symbolFiles {
+	return &symbolFiles{
+		origin: origin,
+		token:  token,
+		cache:  make(map[string][]byte),
+	}
+}
+
+// get downloads the symbol file
+// stored at the key.
+func (sf *symbolFiles) get(ctx context.Context, key string) (data []byte, err error) {
+	if data, ok := sf.cache[key]; ok {
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, symbolFileTimeout)
+	defer cancel()
+
+	q := url.Values{}
+	q.Set("id", key)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sf.origin+"/symbols?"+q.Encode(), nil)
+	if err != nil {
+		return
+	}
+
+	if sf.token != "" {
+		req.Header.Set("Authorization", "Bearer "+sf.token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("failed to download symbol file %q: status %d", key, resp.StatusCode)
+		return
+	}
+
+	if data, err = io.ReadAll(resp.Body); err != nil {
+		return
+	}
+
+	sf.cache[key] = data
+
+	return
+}
+
+// mappingKeys gets the sorted keys of
+// the mappings of the mapping type.
+func mappingKeys(mappings map[string]symbol.MappingType, mType symbol.MappingType) (keys []string) {
+	for key, t := range mappings {
+		if t == mType {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+	return
+}
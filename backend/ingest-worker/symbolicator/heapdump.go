@@ -0,0 +1,66 @@
+package symbolicator
+
+import (
+	"backend/libs/symbol"
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SymbolicateClasses de-obfuscates the JVM class names of a
+// heap dump taken on the app version using the version's
+// proguard mapping. It provides each class name that changed,
+// mapped to its original name.
+//
+// Heap dumps of apps other than Android ones are left alone.
+func (s *Symbolicator) SymbolicateClasses(ctx context.Context, conn *pgxpool.Pool, appId uuid.UUID, versionName, versionCode string, classes []string) (rewrites map[string]string, err error) {
+	if s.jvmSymbolicator == nil || len(classes) == 0 {
+		return
+	}
+
+	mappings, err := symbol.GetMappings(ctx, conn, appId, versionName, versionCode)
+	if err != nil {
+		return
+	}
+
+	js := &jvmSymbolicator{}
+	js.ensureRequestInitialized()
+	for _, class := range classes {
+		js.request.AddClass(class)
+	}
+
+	js.configureModule(mappings)
+	if len(js.request.Modules) == 0 {
+		return
+	}
+
+	sr := &SymbolicatorRequest{}
+	if err = sr.prepareJvmRequest(js, s.Origin, s.SentrySources); err != nil {
+		return
+	}
+
+	respBody, err := sr.makeRequest()
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(respBody, &js.response); err != nil {
+		return
+	}
+
+	if len(js.response.Errors) > 0 {
+		err = ErrJVMSymbolicationFailure
+		return
+	}
+
+	rewrites = map[string]string{}
+	for _, class := range classes {
+		if original := js.response.rewriteClass(class, class); original != class {
+			rewrites[class] = original
+		}
+	}
+
+	return
+}
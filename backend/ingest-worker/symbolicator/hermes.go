@@ -0,0 +1,124 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/symbol"
+	"context"
+	"path"
+	"strings"
+)
+
+// hermesFramePrefix prefixes the file name of
+// frames Hermes reports by bytecode offset.
+const hermesFramePrefix = "address at "
+
+// hermesSymbolicator symbolicates the frames of React Native
+// exceptions thrown from Hermes bytecode, using the build's
+// Hermes source maps. Hermes frames carry the bytecode offset
+// in place of the column, which the source maps are keyed by.
+type hermesSymbolicator struct {
+	// events maps the index of each exception
+	// event to its source map keys.
+	events map[int][]string
+	// resolved maps the index of each exception
+	// event to the count of its frames resolved.
+	resolved map[int]int
+}
+
+// hermesSourcemap is a parsed Hermes source map
+// along with the bundle it belongs to.
+type hermesSourcemap struct {
+	bundle    string
+	sourcemap *symbol.SourceMap
+}
+
+// add queues the exception event at index for
+// symbolication, if the build has Hermes source
+// maps.
+func (hs *hermesSymbolicator) add(mappings map[string]symbol.MappingType, index int) bool {
+	keys := mappingKeys(mappings, symbol.TypeHermesSourcemap)
+	if len(keys) == 0 {
+		return false
+	}
+
+	if hs.events == nil {
+		hs.events = make(map[int][]string)
+	}
+
+	hs.events[index] = keys
+	return true
+}
+
+// symbolicate rewrites the frames of the
+// queued events.
+func (hs *hermesSymbolicator) symbolicate(ctx context.Context, evs []event.EventField, files *symbolFiles) (err error) {
+	parsed := make(map[string]*symbol.SourceMap)
+	hs.resolved = make(map[int]int)
+
+	for i, keys := range hs.events {
+		var sourcemaps []hermesSourcemap
+		for _, key := range keys {
+			sm, ok := parsed[key]
+			if !ok {
+				data, errGet := files.get(ctx, key)
+				if errGet != nil {
+					return errGet
+				}
+
+				if sm, err = symbol.ParseSourceMap(data); err != nil {
+					return
+				}
+				parsed[key] = sm
+			}
+
+			sourcemaps = append(sourcemaps, hermesSourcemap{
+				bundle:    strings.TrimSuffix(path.Base(key), ".map"),
+				sourcemap: sm,
+			})
+		}
+
+		exceptions := evs[i].Exception.Exceptions
+		for j := range exceptions {
+			for k := range exceptions[j].Frames {
+				frame := &exceptions[j].Frames[k]
+				sm := pickHermesSourcemap(sourcemaps, frame.FileName)
+				if sm == nil {
+					continue
+				}
+
+				token, ok := sm.Lookup(frame.LineNum, frame.ColNum)
+				if !ok {
+					continue
+				}
+
+				hs.resolved[i]++
+				frame.FileName = token.Source
+				frame.LineNum = token.Line
+				frame.ColNum = token.Col
+				if token.Name != "" {
+					frame.MethodName = token.Name
+				}
+			}
+		}
+	}
+
+	return
+}
+
+// pickHermesSourcemap picks the source map of the bundle
+// the frame belongs to. A build with a single source map
+// uses it for all frames.
+func pickHermesSourcemap(sourcemaps []hermesSourcemap, fileName string) *symbol.SourceMap {
+	if len(sourcemaps) == 1 {
+		return sourcemaps[0].sourcemap
+	}
+
+	bundle := path.Base(strings.TrimPrefix(fileName, hermesFramePrefix))
+	for _, sm := range sourcemaps {
+		if sm.bundle == bundle {
+			return sm.sourcemap
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package symbolicator
+
+import (
+	"backend/libs/event"
+	"backend/libs/symbol"
+	"context"
+	"testing"
+)
+
+// testHermesSourcemap maps bytecode offsets 0 & 5
+// of line 1 to lines 1 & 2 of App.tsx.
+const testHermesSourcemap = `{"version": 3, "sources": ["App.tsx"], "names": ["onPress"], "mappings": "AAAAA,KACE"}`
+
+func TestHermesSymbolicate(t *testing.T) {
+	files := newTestSymbolFiles(t, map[string]string{
+		"aa/bb/index.android.bundle.map": testHermesSourcemap,
+	})
+
+	evs := []event.EventField{
+		{
+			Type: event.TypeException,
+			Exception: &event.Exception{
+				Exceptions: event.ExceptionUnits{
+					{
+						Frames: event.Frames{
+							{MethodName: "anonymous", FileName: "address at index.android.bundle", LineNum: 1, ColNum: 2},
+							{MethodName: "render", FileName: "address at index.android.bundle", LineNum: 1, ColNum: 9},
+							{MethodName: "native", FileName: "native", LineNum: 4, ColNum: 0},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var hs hermesSymbolicator
+	if !hs.add(map[string]symbol.MappingType{"aa/bb/index.android.bundle.map": symbol.TypeHermesSourcemap}, 0) {
+		t.Fatal("expected event to be queued")
+	}
+
+	if err := hs.symbolicate(context.Background(), evs, files); err != nil {
+		t.Fatalf("symbolicate failed: %v", err)
+	}
+
+	frames := evs[0].Exception.Exceptions[0].Frames
+	if got := frames[0]; got.MethodName != "onPress" || got.FileName != "App.tsx" || got.LineNum != 1 || got.ColNum != 1 {
+		t.Errorf("frame 0 = %+v, want onPress at App.tsx:1:1", got)
+	}
+	if got := frames[1]; got.MethodName != "render" || got.FileName != "App.tsx" || got.LineNum != 2 || got.ColNum != 3 {
+		t.Errorf("frame 1 = %+v, want render at App.tsx:2:3", got)
+	}
+	if got := frames[2]; got.FileName != "native" || got.LineNum != 4 {
+		t.Errorf("frame 2 = %+v, want unmapped frame untouched", got)
+	}
+
+	// builds without hermes source
+	// maps aren't queued
+	var none hermesSymbolicator
+	if none.add(map[string]symbol.MappingType{"aa/bb/main.jsbundle": symbol.TypeJsBundle}, 0) {
+		t.Error("expected event not to be queued")
+	}
+}
+
+func TestPickHermesSourcemap(t *testing.T) {
+	android := &symbol.SourceMap{}
+	ios := &symbol.SourceMap{}
+	sourcemaps := []hermesSourcemap{
+		{bundle: "index.android.bundle", sourcemap: android},
+		{bundle: "main.jsbundle", sourcemap: ios},
+	}
+
+	if got := pickHermesSourcemap(sourcemaps, "address at index.android.bundle"); got != android {
+		t.Errorf("expected android source map")
+	}
+	if got := pickHermesSourcemap(sourcemaps, "app:///main.jsbundle"); got != ios {
+		t.Errorf("expected ios source map")
+	}
+	if got := pickHermesSourcemap(sourcemaps, "other.bundle"); got != nil {
+		t.Errorf("expected no source map")
+	}
+	if got := pickHermesSourcemap(sourcemaps[:1], "other.bundle"); got != android {
+		t.Errorf("expected the only source map")
+	}
+}
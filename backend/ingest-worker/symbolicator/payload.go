@@ -294,11 +294,19 @@ func NewRequestNative() *requestNative {
 // sourcemap token. Hence the `omitempty` tag — we do not
 // send it on input, but we read it on output.
 type frameJS struct {
-	Function string `json:"function"`
-	Filename string `json:"filename,omitempty"`
-	AbsPath  string `json:"abs_path"`
-	LineNo   int    `json:"lineno"`
-	ColumnNo int    `json:"colno"`
+	Function string       `json:"function"`
+	Filename string       `json:"filename,omitempty"`
+	AbsPath  string       `json:"abs_path"`
+	LineNo   int          `json:"lineno"`
+	ColumnNo int          `json:"colno"`
+	Data     *frameJSData `json:"data,omitempty"`
+}
+
+// frameJSData carries symbolicator's resolution
+// details of a JavaScript frame. Only present on
+// output.
+type frameJSData struct {
+	Symbolicated bool `json:"symbolicated"`
 }
 
 // stacktraceJS represents a stacktrace
@@ -0,0 +1,115 @@
+package symbolicator
+
+import (
+	"backend/libs/flame"
+	"backend/libs/symbol"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SymbolicateFrames de-obfuscates the JVM frames of a profile
+// taken on the app version using the version's proguard
+// mapping. It provides each frame that changed, mapped to the
+// frames it resolved to, outermost first. Inlined frames
+// resolve to more than one frame.
+//
+// Frames without a class are left alone, as are profiles of
+// apps other than Android ones.
+func (s *Symbolicator) SymbolicateFrames(ctx context.Context, conn *pgxpool.Pool, appId uuid.UUID, versionName, versionCode string, frames []flame.Frame) (rewrites map[flame.Frame][]flame.Frame, err error) {
+	if s.jvmSymbolicator == nil {
+		return
+	}
+
+	mappings, err := symbol.GetMappings(ctx, conn, appId, versionName, versionCode)
+	if err != nil {
+		return
+	}
+
+	js := &jvmSymbolicator{}
+	js.ensureRequestInitialized()
+
+	// each frame goes as a stacktrace of its
+	// own, so inlined frames unfurl in place
+	var jvmFrames []flame.Frame
+	for _, f := range frames {
+		if f.ClassName == "" {
+			continue
+		}
+		js.request.AddClass(f.ClassName)
+		js.request.Stacktraces = append(js.request.Stacktraces, stacktraceJVM{
+			Frames: []frameJVM{{
+				Function: f.MethodName,
+				Filename: f.FileName,
+				Module:   f.ClassName,
+			}},
+		})
+		jvmFrames = append(jvmFrames, f)
+	}
+
+	js.configureModule(mappings)
+	if len(jvmFrames) == 0 || len(js.request.Modules) == 0 {
+		return
+	}
+
+	sr := &SymbolicatorRequest{}
+	if err = sr.prepareJvmRequest(js, s.Origin, s.SentrySources); err != nil {
+		return
+	}
+
+	respBody, err := sr.makeRequest()
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(respBody, &js.response); err != nil {
+		return
+	}
+
+	if len(js.response.Errors) > 0 {
+		err = ErrJVMSymbolicationFailure
+		return
+	}
+
+	if len(js.response.Stacktraces) != len(jvmFrames) {
+		err = fmt.Errorf("symbolicator returned %d stacktraces for %d frames", len(js.response.Stacktraces), len(jvmFrames))
+		return
+	}
+
+	lambdaSubstr := "SyntheticLambda"
+	rewrites = map[flame.Frame][]flame.Frame{}
+
+	for i, f := range jvmFrames {
+		out := js.response.Stacktraces[i].Frames
+		if len(out) == 0 {
+			continue
+		}
+
+		// unfurled frames come innermost first, like
+		// the frames of an exception
+		resolved := make([]flame.Frame, len(out))
+		for j, o := range out {
+			className := o.Module
+			if s.jvmLambdaWorkaround && strings.Contains(className, lambdaSubstr) {
+				className = js.response.rewriteClass(f.ClassName, className)
+			}
+			resolved[len(out)-1-j] = flame.Frame{
+				ClassName:  className,
+				MethodName: o.Function,
+				FileName:   o.Filename,
+			}
+		}
+
+		if len(resolved) == 1 && resolved[0] == f {
+			continue
+		}
+
+		rewrites[f] = resolved
+	}
+
+	return
+}
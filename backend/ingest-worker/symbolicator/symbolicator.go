@@ -95,6 +95,9 @@ type jvmSymbolicator struct {
 	// ttidSpans stores the index of the TTID span
 	// that needs symbolication.
 	ttidSpans []int
+	// payloadEvents stores the index of the events
+	// whose payloads need de-obfuscation.
+	payloadEvents []int
 }
 
 // nativeSymbolicator represents a native symbolicator request.
@@ -148,10 +151,18 @@ type Symbolicator struct {
 	// jsSymbolicator maintains state for
 	// a JavaScript/React Native symbolication request.
 	jsSymbolicator *jsSymbolicator
+	// dartDeobfuscator maintains state for
+	// de-obfuscating Flutter exceptions.
+	dartDeobfuscator *dartDeobfuscator
+	// hermesSymbolicator maintains state for
+	// symbolicating React Native Hermes exceptions.
+	hermesSymbolicator *hermesSymbolicator
 	// SymboloaderOrigin is the origin of the symboloader
 	// service. Symbolicator's /symbolicate-js endpoint
 	// will be pointed at the /symbols/js endpoint hosted
-	// on symboloader. If empty, JS symbolication is skipped.
+	// on symboloader. Dart obfuscation maps & Hermes source
+	// maps are downloaded from its /symbols endpoint. If
+	// empty, JS, Hermes & Dart de-obfuscation are skipped.
 	SymboloaderOrigin string
 	// SymboloaderToken is the bearer credential sent to the
 	// symboloader /symbols/js endpoint. On cloud it is a Google
@@ -171,13 +182,21 @@ type Symbolicator struct {
 	// like `J3.ExceptionDemoActivity...` - where the
 	// J3 is totally unwarranted.
 	jvmLambdaWorkaround bool
+	// jvmPayloads are the payloads, like log bodies
+	// or gesture targets, whose JVM class names are
+	// de-obfuscated.
+	jvmPayloads []Payload
+	// coverage tallies the error frames symbolicated
+	// per app version by the last Symbolicate call.
+	coverage coverage
 }
 
 // New creates a new Symbolicator instance.
 func New(origin, operatingSys string, sources []Source, sentrySources []SentrySource) (symbolicator *Symbolicator) {
 	symbolicator = &Symbolicator{
-		Origin: origin,
-		OSName: operatingSys,
+		Origin:      origin,
+		OSName:      operatingSys,
+		jvmPayloads: DefaultPayloads,
 	}
 
 	if len(sources) > 0 {
@@ -215,6 +234,11 @@ func New(origin, operatingSys string, sources []Source, sentrySources []SentrySo
 	// apps can run on both Android and iOS.
 	symbolicator.jsSymbolicator = &jsSymbolicator{}
 
+	// Flutter apps run on both Android and iOS
+	// too, as do React Native apps on Hermes.
+	symbolicator.dartDeobfuscator = &dartDeobfuscator{}
+	symbolicator.hermesSymbolicator = &hermesSymbolicator{}
+
 	return
 }
 
@@ -257,8 +281,19 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 		}
 	}
 
+	s.coverage = make(coverage)
+
+	// versionMappings caches the mapping sets of the
+	// app versions looked up, by version name & code
+	versionMappings := make(map[[2]string]map[string]symbol.MappingType)
+
+	// jvmErrors stores the index of the exception & ANR
+	// events whose JVM frames the proguard mapping covers.
+	var jvmErrors []int
+
 	for i, ev := range events {
-		if !ev.NeedsSymbolication() {
+		deobfuscate := s.jvmSymbolicator != nil && needsDeobfuscation(ev, s.jvmPayloads)
+		if !ev.NeedsSymbolication() && !deobfuscate {
 			continue
 		}
 
@@ -270,7 +305,10 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 				fmt.Printf("skipping apple symbolication for event %s: symbolicator initialized for os %q\n", ev.ID, s.OSName)
 				continue
 			}
-			s.appleSymbolicator.symbolicate(ev, s.Origin, s.Sources)
+			if err := s.appleSymbolicator.symbolicate(ev, s.Origin, s.Sources); err == nil {
+				frames, symbolicated, missing := s.appleSymbolicator.coverage(ev)
+				s.coverage.add(ev, frames, symbolicated, missing...)
+			}
 			continue
 		}
 
@@ -283,10 +321,15 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 		} else {
 			name := ev.Attribute.AppVersion
 			code := ev.Attribute.AppBuild
-			keyMap, keyErr := symbol.GetMappings(ctx, conn, appId, name, code)
-			if keyErr != nil {
-				fmt.Printf("Error fetching mapping keys for appId %s, version %s, build %s: %v\n", appId, name, code, keyErr)
-				continue
+			keyMap, ok := versionMappings[[2]string{name, code}]
+			if !ok {
+				var keyErr error
+				keyMap, keyErr = symbol.GetMappings(ctx, conn, appId, name, code)
+				if keyErr != nil {
+					fmt.Printf("Error fetching mapping keys for appId %s, version %s, build %s: %v\n", appId, name, code, keyErr)
+					continue
+				}
+				versionMappings[[2]string{name, code}] = keyMap
 			}
 			mappings = keyMap
 		}
@@ -295,6 +338,15 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 			// Mapping keys can be absent if the app has
 			// not uploaded the mapping files or does not
 			// have a need to symbolicate.
+			if isError(ev) {
+				s.coverage.add(ev, errorFrames(ev), 0, unsymbolicatedImages(ev)...)
+			}
+			continue
+		}
+
+		// payloads are only de-obfuscated
+		// with a proguard mapping
+		if deobfuscate && !ev.NeedsSymbolication() && !hasMappingType(mappings, symbol.TypeProguard) {
 			continue
 		}
 
@@ -313,6 +365,7 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 				exceptions := ev.Exception.Exceptions
 				threads := ev.Exception.Threads
 				s.jvmSymbolicator.parseExceptions(exceptions, threads, i)
+				jvmErrors = s.coverJVM(ev, i, mappings, jvmErrors)
 
 			case event.FrameworkDart:
 				// initialize native symbolicator request
@@ -329,10 +382,24 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 				arch := ev.Exception.BinaryImages[0].Arch
 				s.nativeSymbolicator.configureModule(mappings, baseAddr, uuid, arch)
 
+				// obfuscated builds need their symbolicated
+				// names de-obfuscated too
+				if s.SymboloaderOrigin != "" {
+					s.dartDeobfuscator.add(mappings, i)
+				}
+
 			case event.FrameworkJS:
 				if s.SymboloaderOrigin == "" {
 					continue
 				}
+
+				// Hermes builds ship a source map of the
+				// bytecode bundle, which is applied here
+				// instead of by symbolicator
+				if s.hermesSymbolicator.add(mappings, i) {
+					continue
+				}
+
 				s.jsSymbolicator.ensureRequestInitialized()
 				s.jsSymbolicator.parseExceptions(ev.Exception.Exceptions, i)
 				s.jsSymbolicator.configureSource(s.SymboloaderOrigin, s.SymboloaderToken, appId, ev.Attribute.AppVersion, ev.Attribute.AppBuild)
@@ -347,6 +414,7 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 			exceptions := ev.ANR.Exceptions
 			threads := ev.ANR.Threads
 			s.jvmSymbolicator.parseExceptions(exceptions, threads, i)
+			jvmErrors = s.coverJVM(ev, i, mappings, jvmErrors)
 
 		case event.TypeLifecycleActivity:
 			s.jvmSymbolicator.ensureRequestInitialized()
@@ -379,6 +447,11 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 			s.jvmSymbolicator.ensureRequestInitialized()
 
 			s.jvmSymbolicator.request.AddClass(ev.AppExit.Trace)
+
+		case event.TypeLog, event.TypeString, event.TypeCustom, event.TypeGestureClick, event.TypeGestureLongClick, event.TypeGestureScroll:
+			s.jvmSymbolicator.ensureRequestInitialized()
+
+			s.jvmSymbolicator.addPayload(ev, i)
 		}
 
 		// configure module for jvm symbolication
@@ -388,7 +461,9 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 	}
 
 	if s.jvmSymbolicator != nil {
-		if err := s.jvmSymbolicator.symbolicate(events, spans, s.Origin, s.SentrySources, s.jvmLambdaWorkaround); err != nil {
+		err := s.jvmSymbolicator.symbolicate(events, spans, s.Origin, s.SentrySources, s.jvmLambdaWorkaround)
+		s.jvmSymbolicator.coverage(events, jvmErrors, err, s.coverage)
+		if err != nil {
 			return fmt.Errorf("jvm symbolication failed: %w", err)
 		}
 	}
@@ -397,12 +472,29 @@ func (s *Symbolicator) Symbolicate(ctx context.Context, conn *pgxpool.Pool, appI
 		if err := s.nativeSymbolicator.symbolicate(events, s.Origin, s.Sources); err != nil {
 			return fmt.Errorf("native symbolication failed: %w", err)
 		}
+		s.nativeSymbolicator.coverage(events, s.coverage)
 	}
 
 	if s.jsSymbolicator != nil {
 		if err := s.jsSymbolicator.symbolicate(events, s.Origin); err != nil {
 			return fmt.Errorf("js symbolication failed: %w", err)
 		}
+		s.jsSymbolicator.coverage(events, s.coverage)
+	}
+
+	files := newSymbolFiles(s.SymboloaderOrigin, s.SymboloaderToken)
+
+	if s.dartDeobfuscator != nil {
+		if err := s.dartDeobfuscator.deobfuscate(ctx, events, files); err != nil {
+			return fmt.Errorf("dart de-obfuscation failed: %w", err)
+		}
+	}
+
+	if s.hermesSymbolicator != nil {
+		if err := s.hermesSymbolicator.symbolicate(ctx, events, files); err != nil {
+			return fmt.Errorf("hermes symbolication failed: %w", err)
+		}
+		s.hermesSymbolicator.coverage(events, s.coverage)
 	}
 
 	return
@@ -733,6 +825,10 @@ func (js jvmSymbolicator) rewriteException(evs []event.EventField, sps []span.Sp
 		}
 	}
 
+	for _, i := range js.payloadEvents {
+		js.response.rewritePayload(&evs[i])
+	}
+
 	// rewrite TTID spans whose names are like
 	//
 	// Activity TTID {class_name}
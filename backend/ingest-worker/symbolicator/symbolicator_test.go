@@ -4,6 +4,7 @@ package symbolicator
 
 import (
 	"backend/libs/event"
+	"backend/libs/flame"
 	"backend/libs/symbol"
 	"backend/testinfra"
 	"bytes"
@@ -55,6 +56,7 @@ var (
 	minioContainer           testcontainers.Container
 	sentrySourceURL          string // URL for the Sentry source handler, reachable from symbolicator container
 	symboloaderOriginURL     string // origin (no path) of the same test HTTP server; used as the /symbols/js host
+	symboloaderHostURL       string // origin of the same test HTTP server, reachable from the test host
 	sentryListener           net.Listener
 	update                   = flag.Bool("update", false, "update golden files")
 	testdataDir              string
@@ -484,6 +486,7 @@ func TestMain(m *testing.M) {
 	// (macOS/Windows). The HostConfigModifier below adds the mapping for Linux.
 	sentrySourceURL = fmt.Sprintf("http://host.docker.internal:%d/symbols", sentryPort)
 	symboloaderOriginURL = fmt.Sprintf("http://host.docker.internal:%d", sentryPort)
+	symboloaderHostURL = fmt.Sprintf("http://127.0.0.1:%d", sentryPort)
 
 	// 5. Start Symbolicator
 	configPath := filepath.Join(testdataDir, "symbolicator.yml")
@@ -1026,6 +1029,44 @@ func TestSymbolicationNoMapping(t *testing.T) {
 	}
 }
 
+func TestProfileFrameSymbolication(t *testing.T) {
+	ctx := context.Background()
+	appID := uuid.New()
+	versionName := "1.0.0"
+	versionCode := "4"
+
+	seedApp(ctx, t, appID)
+	seedBuildMappingRow(ctx, t, appID, versionName, versionCode, "proguard", basicProguardMappingKey)
+
+	obfuscated := flame.Frame{ClassName: "a.b.c", MethodName: "e", FileName: "SourceFile"}
+	framework := flame.Frame{ClassName: "android.view.View", MethodName: "performClick", FileName: "View.java"}
+	native := flame.Frame{MethodName: "art_quick_invoke_stub"}
+
+	symb := New(symbolicatorOrigin, "android", []Source{newS3Source()}, []SentrySource{newSentrySource()})
+	rewrites, err := symb.SymbolicateFrames(ctx, pgPool, appID, versionName, versionCode, []flame.Frame{obfuscated, framework, native})
+	if err != nil {
+		t.Fatalf("SymbolicateFrames failed: %v", err)
+	}
+
+	resolved, ok := rewrites[obfuscated]
+	if !ok || len(resolved) != 1 {
+		t.Fatalf("expected %+v to resolve to 1 frame, got %+v", obfuscated, resolved)
+	}
+	if resolved[0].ClassName != "sh.measure.sample.ExceptionDemoActivity" {
+		t.Errorf("expected class name %q, got %q", "sh.measure.sample.ExceptionDemoActivity", resolved[0].ClassName)
+	}
+	if resolved[0].MethodName != "onResume" {
+		t.Errorf("expected method name %q, got %q", "onResume", resolved[0].MethodName)
+	}
+
+	if _, ok := rewrites[framework]; ok {
+		t.Errorf("expected %+v to remain unchanged", framework)
+	}
+	if _, ok := rewrites[native]; ok {
+		t.Errorf("expected %+v to remain unchanged", native)
+	}
+}
+
 func TestSymbolicationNonSymbolicatableEvents(t *testing.T) {
 	ctx := context.Background()
 	appID := uuid.New()
@@ -1036,6 +1077,7 @@ func TestSymbolicationNonSymbolicatableEvents(t *testing.T) {
 	seedBuildMappingRow(ctx, t, appID, versionName, versionCode, "proguard", basicProguardMappingKey)
 
 	// Create a gesture_click event - should not be symbolicated
+	// when payload de-obfuscation is off
 	events := []event.EventField{
 		{
 			ID:        uuid.New(),
@@ -1058,6 +1100,7 @@ func TestSymbolicationNonSymbolicatableEvents(t *testing.T) {
 
 	sources := []Source{newS3Source()}
 	symb := New(symbolicatorOrigin, "android", sources, []SentrySource{newSentrySource()})
+	symb.jvmPayloads = nil
 	err := symb.Symbolicate(ctx, pgPool, appID, events, nil)
 	if err != nil {
 		t.Fatalf("Symbolicate failed: %v", err)
@@ -1069,6 +1112,55 @@ func TestSymbolicationNonSymbolicatableEvents(t *testing.T) {
 	}
 }
 
+func TestJVMPayloadDeobfuscationBasic(t *testing.T) {
+	ctx := context.Background()
+	appID := uuid.New()
+	versionName := "1.0.0"
+	versionCode := "4"
+
+	seedApp(ctx, t, appID)
+	seedBuildMappingRow(ctx, t, appID, versionName, versionCode, "proguard", basicProguardMappingKey)
+
+	attribute := event.Attribute{
+		AppVersion: versionName,
+		AppBuild:   versionCode,
+		OSName:     "android",
+	}
+
+	events := []event.EventField{
+		{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			Timestamp: time.Now(),
+			Type:      event.TypeLog,
+			Attribute: attribute,
+			Log:       &event.Log{Body: "crash in a.b.c.d"},
+		},
+		{
+			ID:           uuid.New(),
+			SessionID:    uuid.New(),
+			Timestamp:    time.Now(),
+			Type:         event.TypeGestureClick,
+			Attribute:    attribute,
+			GestureClick: &event.GestureClick{Target: "f.g", TargetID: "button1"},
+		},
+	}
+
+	sources := []Source{newS3Source()}
+	symb := New(symbolicatorOrigin, "android", sources, []SentrySource{newSentrySource()})
+	symb.jvmPayloads = payloads
+	if err := symb.Symbolicate(ctx, pgPool, appID, events, nil); err != nil {
+		t.Fatalf("Symbolicate failed: %v", err)
+	}
+
+	if want := "crash in sh.measure.sample.ExceptionDemoActivity.d"; events[0].Log.Body != want {
+		t.Errorf("expected log body %q, got %q", want, events[0].Log.Body)
+	}
+	if want := "sh.measure.sample.HomeActivity"; events[1].GestureClick.Target != want {
+		t.Errorf("expected target %q, got %q", want, events[1].GestureClick.Target)
+	}
+}
+
 // newS3SourceApple creates an S3 source for Apple that points to the MinIO
 // instance via the Docker network alias.
 func newS3SourceApple() Source {
@@ -1423,3 +1515,66 @@ func TestJSExceptionSymbolicationNonOTA(t *testing.T) {
 	results := extractResult(events)
 	assertMatchesGolden(t, "rn_ios_exception_golden.json", results)
 }
+
+func TestHermesSourcemapSymbolication(t *testing.T) {
+	ctx := context.Background()
+	appID := uuid.New()
+	versionName := "1.0.0"
+	versionCode := "1"
+
+	seedApp(ctx, t, appID)
+
+	sourcemap := []byte(`{"version": 3, "sources": ["App.tsx"], "names": ["onPress"], "mappings": "AAAAA,KACE"}`)
+	dif, err := symbol.ExtractHermesSourcemap("index.android.bundle.map", sourcemap)
+	if err != nil {
+		t.Fatalf("extract hermes source map: %v", err)
+	}
+
+	if _, err := s3Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(symbolsBucket),
+		Key:    aws.String(dif.Key),
+		Body:   bytes.NewReader(dif.Data),
+	}); err != nil {
+		t.Fatalf("upload hermes source map: %v", err)
+	}
+
+	seedBuildMappingRow(ctx, t, appID, versionName, versionCode, symbol.TypeHermesSourcemap.String(), dif.Key)
+
+	events := []event.EventField{
+		{
+			ID:        uuid.New(),
+			SessionID: uuid.New(),
+			Timestamp: time.Now(),
+			Type:      event.TypeException,
+			Attribute: event.Attribute{
+				AppVersion: versionName,
+				AppBuild:   versionCode,
+				OSName:     "android",
+			},
+			Exception: &event.Exception{
+				Framework: event.FrameworkJS,
+				Exceptions: event.ExceptionUnits{
+					{
+						Type:    "Error",
+						Message: "boom",
+						Frames: event.Frames{
+							{MethodName: "anonymous", FileName: "address at index.android.bundle", LineNum: 1, ColNum: 7},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	symb := New(symbolicatorOrigin, "android", nil, nil)
+	symb.SymboloaderOrigin = symboloaderHostURL
+	symb.SymboloaderToken = "test-token"
+	if err := symb.Symbolicate(ctx, pgPool, appID, events, nil); err != nil {
+		t.Fatalf("Symbolicate failed: %v", err)
+	}
+
+	frame := events[0].Exception.Exceptions[0].Frames[0]
+	if frame.FileName != "App.tsx" || frame.LineNum != 2 || frame.ColNum != 3 {
+		t.Errorf("expected frame at App.tsx:2:3, got %s:%d:%d", frame.FileName, frame.LineNum, frame.ColNum)
+	}
+}
@@ -69,6 +69,10 @@ func main() {
 	r.PUT("/builds/ota", measure.ValidateAPIKey(), measure.PutOTABuilds)
 	r.GET("/config", measure.ValidateAPIKey(), measure.GetConfigForSdk)
 
+	// OTLP/HTTP routes
+	r.POST("/v1/traces", measure.ValidateAPIKey(), measure.PostOTLPTraces)
+	r.POST("/v1/logs", measure.ValidateAPIKey(), measure.PostOTLPLogs)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8085"
@@ -926,6 +926,10 @@ func PutEvents(c *gin.Context) {
 		return
 	}
 
+	if !eventReq.checkRateLimit(c, eventReq.billableSize()) {
+		return
+	}
+
 	_, validateReqSpan := ingestReqTracer.Start(ingestReqCtx, "validate")
 	defer validateReqSpan.End()
 
@@ -1025,15 +1029,21 @@ func PutEvents(c *gin.Context) {
 
 	ingestReqSpan.End()
 
+	eventReq.publish(c)
+}
+
+// publish publishes the request batch to the
+// bus for the ingest worker to process.
+func (e *eventreq) publish(c *gin.Context) {
 	batch := IngestBatch{
-		BatchID:  eventReq.id.String(),
-		AppID:    eventReq.appId.String(),
-		TeamID:   eventReq.teamId.String(),
-		OsName:   eventReq.osName,
+		BatchID:  e.id.String(),
+		AppID:    e.appId.String(),
+		TeamID:   e.teamId.String(),
+		OsName:   e.osName,
 		ClientIP: c.ClientIP(),
-		Size:     eventReq.billableSize(),
-		Events:   eventReq.events,
-		Spans:    eventReq.spans,
+		Size:     e.billableSize(),
+		Events:   e.events,
+		Spans:    e.spans,
 	}
 
 	payload, err := json.Marshal(batch)
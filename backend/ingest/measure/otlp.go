@@ -0,0 +1,986 @@
+package measure
+
+import (
+	"backend/libs/event"
+	"backend/libs/opsys"
+	"backend/libs/span"
+	"backend/libs/udattr"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// otlpProtobuf is the content type of
+	// OTLP/HTTP protobuf payloads.
+	otlpProtobuf = "application/x-protobuf"
+
+	// otlpJSON is the content type of
+	// OTLP/HTTP JSON payloads.
+	otlpJSON = "application/json"
+)
+
+// otlpSDKVersion is the `measure_sdk_version` of
+// events and spans ingested over OTLP.
+const otlpSDKVersion = "otlp"
+
+// OTLP attributes beyond the limits of user defined
+// attributes are dropped or cut short, instead of
+// failing the whole record.
+const (
+	otlpMaxAttrs          = 100
+	otlpMaxAttrKeyChars   = 256
+	otlpMaxAttrValueChars = 256
+	otlpMaxCheckpoints    = 100
+)
+
+// errOTLPTooLarge is returned when the OTLP payload
+// exceeds the maximum allowed size.
+var errOTLPTooLarge = fmt.Errorf(`payload cannot exceed maximum allowed size of %d`, maxBatchSize)
+
+// otlpAttrKeyRE matches the characters not allowed
+// in user defined attribute keys.
+var otlpAttrKeyRE = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// otlpIDKeys are the JSON keys of trace
+// and span ids in OTLP/JSON.
+var otlpIDKeys = map[string]bool{
+	"traceId":        true,
+	"spanId":         true,
+	"parentSpanId":   true,
+	"trace_id":       true,
+	"span_id":        true,
+	"parent_span_id": true,
+}
+
+// otlpMappedKeys are the OTLP attributes mapped onto
+// Measure's own attributes. They are not repeated as
+// user defined attributes.
+var otlpMappedKeys = map[string]bool{
+	"session.id":                 true,
+	"user.id":                    true,
+	"enduser.id":                 true,
+	"thread.name":                true,
+	"network.connection.type":    true,
+	"network.connection.subtype": true,
+	"network.carrier.name":       true,
+}
+
+// otlpAttrs is a list of OTLP attributes.
+type otlpAttrs []*commonpb.KeyValue
+
+// str returns the first non-empty string
+// value among the keys.
+func (a otlpAttrs) str(keys ...string) string {
+	for _, key := range keys {
+		for _, kv := range a {
+			if kv.GetKey() != key {
+				continue
+			}
+			if value := otlpString(kv.GetValue()); value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// udAttribute converts the attributes not mapped onto
+// Measure's attributes to user defined attributes. Keys
+// have their dots and other disallowed characters
+// replaced with underscores.
+func (a otlpAttrs) udAttribute() (ud udattr.UDAttribute, err error) {
+	raw := map[string]any{}
+
+	for _, kv := range a {
+		if len(raw) == otlpMaxAttrs {
+			break
+		}
+
+		if otlpMappedKeys[kv.GetKey()] {
+			continue
+		}
+
+		key := truncate(otlpAttrKeyRE.ReplaceAllString(kv.GetKey(), "_"), otlpMaxAttrKeyChars)
+		if key == "" {
+			continue
+		}
+
+		switch value := kv.GetValue().GetValue().(type) {
+		case *commonpb.AnyValue_StringValue:
+			raw[key] = truncate(value.StringValue, otlpMaxAttrValueChars)
+		case *commonpb.AnyValue_BoolValue:
+			raw[key] = value.BoolValue
+		case *commonpb.AnyValue_IntValue:
+			raw[key] = value.IntValue
+		case *commonpb.AnyValue_DoubleValue:
+			if !math.IsNaN(value.DoubleValue) && !math.IsInf(value.DoubleValue, 0) {
+				raw[key] = value.DoubleValue
+			}
+		}
+	}
+
+	if len(raw) == 0 {
+		return
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &ud)
+	return
+}
+
+// otlpResource maps an OTLP resource onto the
+// attributes of Measure's events and spans.
+type otlpResource struct {
+	// appID is the id of the app
+	appID uuid.UUID
+	// attribute is the resource's attributes
+	attribute event.Attribute
+	// sessionID is the resource's `session.id`,
+	// if any
+	sessionID string
+}
+
+// newOTLPResource maps the resource's attributes. When the
+// resource doesn't name a supported OS, the app's own OS
+// is assumed.
+func newOTLPResource(appID uuid.UUID, osNames []string, res *resourcepb.Resource) (r otlpResource, err error) {
+	attrs := otlpAttrs(res.GetAttributes())
+
+	r.appID = appID
+	r.sessionID = attrs.str("session.id")
+	r.attribute = event.Attribute{
+		AppUniqueID:       attrs.str("service.name"),
+		MeasureSDKVersion: otlpSDKVersion,
+		NetworkType:       event.NetworkTypeUnknown,
+		NetworkGeneration: event.NetworkGenerationUnknown,
+	}
+
+	installationID := attrs.str("app.installation.id", "service.instance.id", "device.id")
+	if installationID == "" {
+		err = fmt.Errorf(`resource must set one of %q, %q or %q`, `app.installation.id`, `service.instance.id`, `device.id`)
+		return
+	}
+	r.attribute.InstallationID = otlpUUID(appID, installationID)
+
+	setOTLPAttribute(&r.attribute, attrs)
+
+	if opsys.ToFamily(r.attribute.OSName) == opsys.Unknown {
+		r.attribute.OSName = ""
+		for _, osName := range osNames {
+			if opsys.ToFamily(osName) != opsys.Unknown {
+				r.attribute.OSName = strings.ToLower(osName)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// session returns the session of a record. Without a
+// `session.id`, each installation's records of a UTC
+// day fall into one session.
+func (r otlpResource) session(attrs otlpAttrs, ts time.Time) uuid.UUID {
+	sessionID := attrs.str("session.id")
+	if sessionID == "" {
+		sessionID = r.sessionID
+	}
+
+	if sessionID != "" {
+		return otlpUUID(r.appID, sessionID)
+	}
+
+	return uuid.NewSHA1(r.attribute.InstallationID, []byte(ts.UTC().Format(time.DateOnly)))
+}
+
+// span converts an OTLP span to a Measure span.
+func (r otlpResource) span(s *tracepb.Span) (sp span.SpanField, err error) {
+	attrs := otlpAttrs(s.GetAttributes())
+	attribute := r.attribute
+	setOTLPAttribute(&attribute, attrs)
+
+	sp = span.SpanField{
+		AppID:      r.appID,
+		SpanName:   s.GetName(),
+		SpanID:     hex.EncodeToString(s.GetSpanId()),
+		ParentID:   hex.EncodeToString(s.GetParentSpanId()),
+		TraceID:    hex.EncodeToString(s.GetTraceId()),
+		Status:     uint8(s.GetStatus().GetCode()),
+		StartTime:  otlpTime(s.GetStartTimeUnixNano()),
+		EndTime:    otlpTime(s.GetEndTimeUnixNano()),
+		Attributes: spanAttributes(attribute),
+	}
+	sp.SessionID = r.session(attrs, sp.StartTime)
+
+	for _, ev := range s.GetEvents() {
+		if len(sp.CheckPoints) == otlpMaxCheckpoints {
+			break
+		}
+		sp.CheckPoints = append(sp.CheckPoints, span.CheckPointField{
+			Name:      ev.GetName(),
+			Timestamp: otlpTime(ev.GetTimeUnixNano()),
+		})
+	}
+
+	if sp.UserDefinedAttribute, err = attrs.udAttribute(); err != nil {
+		return
+	}
+
+	if err = sp.Validate(); err != nil {
+		return
+	}
+
+	if !sp.UserDefinedAttribute.Empty() {
+		err = sp.UserDefinedAttribute.Validate()
+	}
+
+	return
+}
+
+// log converts an OTLP log record to a Measure log event.
+// The event's id derives from the record, so that an
+// export retried by the client yields the same events.
+func (r otlpResource) log(l *logspb.LogRecord) (ev event.EventField, err error) {
+	attrs := otlpAttrs(l.GetAttributes())
+	attribute := r.attribute
+	setOTLPAttribute(&attribute, attrs)
+
+	ts := otlpTime(l.GetTimeUnixNano())
+	if ts.IsZero() {
+		ts = otlpTime(l.GetObservedTimeUnixNano())
+	}
+
+	// keep the record's trace context around
+	// to find the log from its trace
+	if traceID := l.GetTraceId(); len(traceID) > 0 {
+		attrs = append(attrs[:len(attrs):len(attrs)], otlpKeyValue("trace_id", hex.EncodeToString(traceID)))
+	}
+	if spanID := l.GetSpanId(); len(spanID) > 0 {
+		attrs = append(attrs[:len(attrs):len(attrs)], otlpKeyValue("span_id", hex.EncodeToString(spanID)))
+	}
+
+	record, err := proto.MarshalOptions{Deterministic: true}.Marshal(l)
+	if err != nil {
+		return
+	}
+
+	body := otlpString(l.GetBody())
+	if body == "" {
+		body = l.GetEventName()
+	}
+
+	severityText, severityNumber := otlpSeverity(l.GetSeverityNumber(), l.GetSeverityText())
+	sessionID := r.session(attrs, ts)
+
+	ev = event.EventField{
+		ID:        uuid.NewSHA1(sessionID, record),
+		AppID:     r.appID,
+		SessionID: sessionID,
+		Timestamp: ts,
+		Type:      event.TypeLog,
+		Attribute: attribute,
+		Log: &event.Log{
+			SeverityText:   severityText,
+			SeverityNumber: severityNumber,
+			Body:           body,
+		},
+	}
+
+	if ev.UserDefinedAttribute, err = attrs.udAttribute(); err != nil {
+		return
+	}
+
+	if err = ev.Validate(); err != nil {
+		return
+	}
+
+	if err = ev.Attribute.Validate(); err != nil {
+		return
+	}
+
+	if !ev.UserDefinedAttribute.Empty() {
+		err = ev.UserDefinedAttribute.Validate()
+	}
+
+	return
+}
+
+// setOTLPAttribute sets the attributes found in
+// the OTLP attributes, leaving the rest as is.
+func setOTLPAttribute(a *event.Attribute, attrs otlpAttrs) {
+	set := func(field *string, keys ...string) {
+		if value := attrs.str(keys...); value != "" {
+			*field = value
+		}
+	}
+
+	set(&a.AppUniqueID, "service.name")
+	set(&a.AppVersion, "service.version")
+	set(&a.AppBuild, "app.build_id", "service.version")
+	set(&a.OSVersion, "os.version")
+	set(&a.DeviceManufacturer, "device.manufacturer")
+	set(&a.DeviceModel, "device.model.identifier", "device.model.name")
+	set(&a.UserID, "user.id", "enduser.id")
+	set(&a.ThreadName, "thread.name")
+	set(&a.NetworkProvider, "network.carrier.name")
+
+	if osName := attrs.str("os.name"); osName != "" {
+		a.OSName = strings.ToLower(osName)
+	}
+
+	if networkType := attrs.str("network.connection.type"); networkType != "" {
+		a.NetworkType = otlpNetworkType(networkType)
+	}
+
+	if subtype := attrs.str("network.connection.subtype"); subtype != "" {
+		a.NetworkGeneration = otlpNetworkGeneration(subtype)
+	}
+}
+
+// spanAttributes converts event attributes
+// to span attributes.
+func spanAttributes(a event.Attribute) span.SpanAttributes {
+	return span.SpanAttributes{
+		AppUniqueID:        a.AppUniqueID,
+		InstallationID:     a.InstallationID,
+		UserID:             a.UserID,
+		MeasureSDKVersion:  a.MeasureSDKVersion,
+		AppVersion:         a.AppVersion,
+		AppBuild:           a.AppBuild,
+		OSName:             a.OSName,
+		OSVersion:          a.OSVersion,
+		ThreadName:         a.ThreadName,
+		NetworkType:        a.NetworkType,
+		NetworkProvider:    a.NetworkProvider,
+		NetworkGeneration:  a.NetworkGeneration,
+		DeviceName:         a.DeviceName,
+		DeviceModel:        a.DeviceModel,
+		DeviceManufacturer: a.DeviceManufacturer,
+	}
+}
+
+// otlpNetworkType maps the OTel `network.connection.type`
+// onto Measure's network types.
+func otlpNetworkType(connectionType string) string {
+	switch connectionType {
+	case "wifi":
+		return event.NetworkTypeWifi
+	case "cell":
+		return event.NetworkTypeCellular
+	case "unavailable":
+		return event.NetworkTypeNoNetwork
+	default:
+		return event.NetworkTypeUnknown
+	}
+}
+
+// otlpNetworkGeneration maps the OTel `network.connection.subtype`
+// onto Measure's network generations.
+func otlpNetworkGeneration(subtype string) string {
+	switch subtype {
+	case "gprs", "edge", "cdma", "cdma2000_1xrtt", "iden", "gsm":
+		return event.NetworkGeneration2G
+	case "umts", "evdo_0", "evdo_a", "evdo_b", "hsdpa", "hsupa", "hspa", "hspap", "ehrpd", "td_scdma":
+		return event.NetworkGeneration3G
+	case "lte", "lte_ca", "iwlan":
+		return event.NetworkGeneration4G
+	case "nr", "nrnsa":
+		return event.NetworkGeneration5G
+	default:
+		return event.NetworkGenerationUnknown
+	}
+}
+
+// otlpSeverity maps an OTLP log severity onto one of
+// Measure's log severities and its severity number.
+// Records without a severity number are mapped by
+// their severity text.
+func otlpSeverity(number logspb.SeverityNumber, text string) (string, int32) {
+	if number == logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED {
+		switch strings.ToLower(text) {
+		case "trace", "debug":
+			number = logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+		case "warn", "warning":
+			number = logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+		case "error":
+			number = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+		case "fatal", "critical":
+			number = logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+		default:
+			number = logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+		}
+	}
+
+	switch {
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG4:
+		return "debug", 8
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_INFO4:
+		return "info", 12
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_WARN4:
+		return "warning", 16
+	case number <= logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4:
+		return "error", 20
+	default:
+		return "fatal", 24
+	}
+}
+
+// otlpUUID returns the value as a UUID when it is one,
+// or else a UUID derived from the value.
+func otlpUUID(namespace uuid.UUID, value string) uuid.UUID {
+	if id, err := uuid.Parse(value); err == nil && id != uuid.Nil {
+		return id
+	}
+
+	return uuid.NewSHA1(namespace, []byte(value))
+}
+
+// otlpTime converts unix nanoseconds to time. Zero
+// nanoseconds, an unset time in OTLP, stay zero.
+func otlpTime(nanos uint64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, int64(nanos)).UTC()
+}
+
+// otlpString renders an OTLP value as a string. Arrays
+// and maps are rendered as JSON.
+func otlpString(v *commonpb.AnyValue) string {
+	switch value := v.GetValue().(type) {
+	case nil:
+		return ""
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(value.BytesValue)
+	default:
+		data, err := json.Marshal(otlpValue(v))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+}
+
+// otlpValue converts an OTLP value to
+// its plain Go value.
+func otlpValue(v *commonpb.AnyValue) any {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return value.DoubleValue
+	case *commonpb.AnyValue_BytesValue:
+		return value.BytesValue
+	case *commonpb.AnyValue_ArrayValue:
+		values := []any{}
+		for _, item := range value.ArrayValue.GetValues() {
+			values = append(values, otlpValue(item))
+		}
+		return values
+	case *commonpb.AnyValue_KvlistValue:
+		values := map[string]any{}
+		for _, kv := range value.KvlistValue.GetValues() {
+			values[kv.GetKey()] = otlpValue(kv.GetValue())
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// otlpKeyValue builds a string
+// OTLP attribute.
+func otlpKeyValue(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key: key,
+		Value: &commonpb.AnyValue{
+			Value: &commonpb.AnyValue_StringValue{StringValue: value},
+		},
+	}
+}
+
+// truncate cuts the string short to at most n
+// bytes, without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n]
+}
+
+// otlpRejects counts the records dropped from an
+// OTLP export, with the first reason to drop one.
+type otlpRejects struct {
+	count int64
+	first error
+}
+
+// add counts n dropped records.
+func (r *otlpRejects) add(n int, err error) {
+	r.count += int64(n)
+	if r.first == nil {
+		r.first = err
+	}
+}
+
+// message describes the dropped records
+// for a partial success response.
+func (r otlpRejects) message() string {
+	if r.count == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("dropped %d invalid records, first: %v", r.count, r.first)
+}
+
+// readOTLPTraces reads the spans of an OTLP trace export.
+// Spans failing validation are dropped, not the batch.
+func (e *eventreq) readOTLPTraces(req *coltracepb.ExportTraceServiceRequest, osNames []string) (rejects otlpRejects) {
+	dupSpan := make(map[string]struct{})
+
+	for _, rs := range req.GetResourceSpans() {
+		res, err := newOTLPResource(e.appId, osNames, rs.GetResource())
+		if err != nil {
+			for _, ss := range rs.GetScopeSpans() {
+				rejects.add(len(ss.GetSpans()), err)
+			}
+			continue
+		}
+
+		for _, ss := range rs.GetScopeSpans() {
+			for _, s := range ss.GetSpans() {
+				sp, err := res.span(s)
+				if err != nil {
+					rejects.add(1, fmt.Errorf("span %x: %w", s.GetSpanId(), err))
+					continue
+				}
+
+				// a span repeated within an export
+				// is the same span, keep one
+				if _, ok := dupSpan[sp.SpanID]; ok {
+					continue
+				}
+				dupSpan[sp.SpanID] = struct{}{}
+
+				bytes, err := json.Marshal(sp)
+				if err != nil {
+					rejects.add(1, fmt.Errorf("span %x: %w", s.GetSpanId(), err))
+					continue
+				}
+
+				e.bumpPayloadSize(uint64(len(bytes)))
+				e.spans = append(e.spans, sp)
+			}
+		}
+	}
+
+	return
+}
+
+// readOTLPLogs reads the log records of an OTLP logs export
+// as log events. Records failing validation are dropped,
+// not the batch.
+func (e *eventreq) readOTLPLogs(req *collogspb.ExportLogsServiceRequest, osNames []string) (rejects otlpRejects) {
+	dupEvent := make(map[uuid.UUID]struct{})
+
+	for _, rl := range req.GetResourceLogs() {
+		res, err := newOTLPResource(e.appId, osNames, rl.GetResource())
+		if err != nil {
+			for _, sl := range rl.GetScopeLogs() {
+				rejects.add(len(sl.GetLogRecords()), err)
+			}
+			continue
+		}
+
+		for _, sl := range rl.GetScopeLogs() {
+			for i, l := range sl.GetLogRecords() {
+				ev, err := res.log(l)
+				if err != nil {
+					rejects.add(1, fmt.Errorf("log record %d: %w", i, err))
+					continue
+				}
+
+				// identical records derive the
+				// same id, keep one
+				if _, ok := dupEvent[ev.ID]; ok {
+					continue
+				}
+				dupEvent[ev.ID] = struct{}{}
+
+				bytes, err := json.Marshal(ev)
+				if err != nil {
+					rejects.add(1, fmt.Errorf("log record %d: %w", i, err))
+					continue
+				}
+
+				e.bumpPayloadSize(uint64(len(bytes)))
+				e.events = append(e.events, ev)
+			}
+		}
+	}
+
+	return
+}
+
+// otlpSignal is an OTLP export of
+// one signal, like traces or logs.
+type otlpSignal interface {
+	// request is the export request
+	// to decode the payload into.
+	request() proto.Message
+
+	// read reads the export's records into
+	// the batch and counts the dropped ones.
+	read(e *eventreq, osNames []string) otlpRejects
+
+	// response is the export's response.
+	response(rejects otlpRejects) proto.Message
+}
+
+// otlpTraces is an OTLP trace export.
+type otlpTraces struct {
+	req *coltracepb.ExportTraceServiceRequest
+}
+
+func (t otlpTraces) request() proto.Message {
+	return t.req
+}
+
+func (t otlpTraces) read(e *eventreq, osNames []string) otlpRejects {
+	return e.readOTLPTraces(t.req, osNames)
+}
+
+func (t otlpTraces) response(rejects otlpRejects) proto.Message {
+	res := &coltracepb.ExportTraceServiceResponse{}
+	if rejects.count > 0 {
+		res.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+			RejectedSpans: rejects.count,
+			ErrorMessage:  rejects.message(),
+		}
+	}
+	return res
+}
+
+// otlpLogs is an OTLP logs export.
+type otlpLogs struct {
+	req *collogspb.ExportLogsServiceRequest
+}
+
+func (l otlpLogs) request() proto.Message {
+	return l.req
+}
+
+func (l otlpLogs) read(e *eventreq, osNames []string) otlpRejects {
+	return e.readOTLPLogs(l.req, osNames)
+}
+
+func (l otlpLogs) response(rejects otlpRejects) proto.Message {
+	res := &collogspb.ExportLogsServiceResponse{}
+	if rejects.count > 0 {
+		res.PartialSuccess = &collogspb.ExportLogsPartialSuccess{
+			RejectedLogRecords: rejects.count,
+			ErrorMessage:       rejects.message(),
+		}
+	}
+	return res
+}
+
+// readOTLPBody reads the request body,
+// decompressing gzip encoded bodies.
+func readOTLPBody(c *gin.Context) (body []byte, err error) {
+	reader := io.Reader(c.Request.Body)
+
+	switch encoding := c.GetHeader("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	body, err = io.ReadAll(io.LimitReader(reader, int64(maxBatchSize)+1))
+	if err != nil {
+		return
+	}
+
+	if len(body) > maxBatchSize {
+		return nil, errOTLPTooLarge
+	}
+
+	return
+}
+
+// decodeOTLP decodes an OTLP/HTTP payload
+// of the content type into the message.
+func decodeOTLP(body []byte, contentType string, msg proto.Message) error {
+	if contentType == otlpProtobuf {
+		return proto.Unmarshal(body, msg)
+	}
+
+	body, err := otlpHexIDs(body)
+	if err != nil {
+		return err
+	}
+
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, msg)
+}
+
+// otlpHexIDs rewrites the hex encoded trace and span ids
+// of an OTLP/JSON payload to base64, the encoding protojson
+// expects of bytes. OTLP/JSON deviates from the protobuf
+// JSON mapping only for these ids.
+func otlpHexIDs(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// keep large integers, like
+	// timestamps, intact
+	decoder.UseNumber()
+
+	var payload any
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for key, value := range v {
+				if id, ok := value.(string); ok && otlpIDKeys[key] {
+					if b, err := hex.DecodeString(id); err == nil {
+						v[key] = base64.StdEncoding.EncodeToString(b)
+					}
+					continue
+				}
+				walk(value)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+
+	walk(payload)
+
+	return json.Marshal(payload)
+}
+
+// writeOTLP writes an OTLP response in
+// the request's content type.
+func writeOTLP(c *gin.Context, contentType string, msg proto.Message) {
+	var body []byte
+	var err error
+
+	if contentType == otlpJSON {
+		body, err = protojson.Marshal(msg)
+	} else {
+		body, err = proto.Marshal(msg)
+	}
+
+	if err != nil {
+		msg := `failed to encode OTLP response`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// PostOTLPTraces ingests an OTLP/HTTP trace export.
+func PostOTLPTraces(c *gin.Context) {
+	ingestOTLP(c, otlpTraces{req: &coltracepb.ExportTraceServiceRequest{}})
+}
+
+// PostOTLPLogs ingests an OTLP/HTTP logs export.
+func PostOTLPLogs(c *gin.Context) {
+	ingestOTLP(c, otlpLogs{req: &collogspb.ExportLogsServiceRequest{}})
+}
+
+// ingestOTLP ingests an OTLP/HTTP export. The export's
+// records become spans and events of one batch, which takes
+// the same path to the bus as batches from Measure's SDKs.
+//
+// An export carries no batch id, so the batch id derives
+// from the payload. Exports retried by the client are then
+// recognized as previously ingested.
+func ingestOTLP(c *gin.Context, signal otlpSignal) {
+	ingestReqTracer := otel.Tracer("ingest-req-tracer")
+	ingestReqCtx, ingestReqSpan := ingestReqTracer.Start(context.Background(), "otlp-ingest-request")
+	defer ingestReqSpan.End()
+
+	appId, err := uuid.Parse(c.GetString("appId"))
+	if err != nil {
+		msg := `error parsing app's uuid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if err := CheckIngestAllowedForApp(c, appId); err != nil {
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != otlpProtobuf && contentType != otlpJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": fmt.Sprintf("content type must be one of %q or %q", otlpProtobuf, otlpJSON),
+		})
+		return
+	}
+
+	msg := `failed to parse OTLP request payload`
+
+	body, err := readOTLPBody(c)
+	if err != nil {
+		fmt.Println(msg, err)
+		status := http.StatusBadRequest
+		if errors.Is(err, errOTLPTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := decodeOTLP(body, contentType, signal.request()); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	app, err := SelectApp(ctx, appId)
+	if app == nil || err != nil {
+		msg := `failed to lookup app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	eventReq := eventreq{
+		id:     uuid.NewSHA1(appId, body),
+		appId:  appId,
+		teamId: app.TeamId,
+		json:   contentType == otlpJSON,
+	}
+
+	rejects := signal.read(&eventReq, app.OSNames)
+
+	if len(eventReq.events) < 1 && len(eventReq.spans) < 1 {
+		// an export without any records
+		// has nothing to ingest
+		if rejects.count == 0 {
+			writeOTLP(c, contentType, signal.response(rejects))
+			return
+		}
+
+		msg := `failed to validate OTLP request payload`
+		fmt.Println(msg, rejects.first)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": rejects.message(),
+		})
+		return
+	}
+
+	eventReq.osName = eventReq.getOSName()
+
+	if err := eventReq.checkSeen(ingestReqCtx); err != nil {
+		msg := "failed to check for duplicate event request batch"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if eventReq.seen {
+		writeOTLP(c, contentType, signal.response(rejects))
+		return
+	}
+
+	if !eventReq.checkRateLimit(c, eventReq.billableSize()) {
+		return
+	}
+
+	if err := eventReq.validate(); err != nil {
+		msg := `failed to validate OTLP request payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	writeOTLP(c, contentType, signal.response(rejects))
+
+	ingestReqSpan.End()
+
+	eventReq.publish(c)
+}
@@ -0,0 +1,293 @@
+package measure
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/libs/event"
+
+	"github.com/google/uuid"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+func newTestOTLPResource(kvs ...*commonpb.KeyValue) *resourcepb.Resource {
+	base := []*commonpb.KeyValue{
+		otlpKeyValue("service.name", "sh.measure.sample"),
+		otlpKeyValue("service.version", "1.2.0"),
+		otlpKeyValue("app.installation.id", "install-1"),
+		otlpKeyValue("os.name", "Android"),
+		otlpKeyValue("os.version", "14"),
+	}
+	return &resourcepb.Resource{Attributes: append(base, kvs...)}
+}
+
+func newTestOTLPSpan(name string, spanID byte) *tracepb.Span {
+	start := time.Now().Add(-time.Minute)
+	return &tracepb.Span{
+		TraceId:           []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanId:            []byte{0, 0, 0, 0, 0, 0, 0, spanID},
+		Name:              name,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.Add(time.Second).UnixNano()),
+		Status:            &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK},
+		Attributes: []*commonpb.KeyValue{
+			otlpKeyValue("http.route", "/checkout"),
+			otlpKeyValue("thread.name", "main"),
+		},
+	}
+}
+
+func TestNewOTLPResource(t *testing.T) {
+	appID := uuid.New()
+
+	t.Run("maps resource attributes", func(t *testing.T) {
+		r, err := newOTLPResource(appID, nil, newTestOTLPResource(
+			otlpKeyValue("device.manufacturer", "Google"),
+			otlpKeyValue("device.model.identifier", "Pixel 8"),
+			otlpKeyValue("user.id", "u-1"),
+		))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		a := r.attribute
+		if a.AppUniqueID != "sh.measure.sample" || a.AppVersion != "1.2.0" || a.AppBuild != "1.2.0" {
+			t.Errorf("app = %q %q %q", a.AppUniqueID, a.AppVersion, a.AppBuild)
+		}
+		if a.OSName != "android" || a.OSVersion != "14" {
+			t.Errorf("os = %q %q", a.OSName, a.OSVersion)
+		}
+		if a.DeviceManufacturer != "Google" || a.DeviceModel != "Pixel 8" {
+			t.Errorf("device = %q %q", a.DeviceManufacturer, a.DeviceModel)
+		}
+		if a.UserID != "u-1" {
+			t.Errorf("user id = %q, want u-1", a.UserID)
+		}
+		if a.InstallationID != uuid.NewSHA1(appID, []byte("install-1")) {
+			t.Errorf("installation id = %s, want one derived from the app", a.InstallationID)
+		}
+		if err := a.Validate(); err != nil {
+			t.Errorf("attribute invalid: %v", err)
+		}
+	})
+
+	t.Run("falls back to the app's os", func(t *testing.T) {
+		res := &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			otlpKeyValue("service.instance.id", uuid.NewString()),
+			otlpKeyValue("os.name", "Linux"),
+		}}
+		r, err := newOTLPResource(appID, []string{"ios"}, res)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r.attribute.OSName != "ios" {
+			t.Errorf("os name = %q, want ios", r.attribute.OSName)
+		}
+	})
+
+	t.Run("keeps installation ids that are uuids", func(t *testing.T) {
+		id := uuid.New()
+		r, err := newOTLPResource(appID, nil, &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+			otlpKeyValue("device.id", id.String()),
+		}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if r.attribute.InstallationID != id {
+			t.Errorf("installation id = %s, want %s", r.attribute.InstallationID, id)
+		}
+	})
+
+	t.Run("needs an installation", func(t *testing.T) {
+		if _, err := newOTLPResource(appID, nil, &resourcepb.Resource{}); err == nil {
+			t.Error("expected error for resource without an installation")
+		}
+	})
+}
+
+func TestOTLPSpan(t *testing.T) {
+	appID := uuid.New()
+	r, err := newOTLPResource(appID, nil, newTestOTLPResource(otlpKeyValue("session.id", "s-1")))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s := newTestOTLPSpan("checkout", 1)
+	s.ParentSpanId = []byte{0, 0, 0, 0, 0, 0, 0, 9}
+	s.Events = []*tracepb.Span_Event{{Name: "paid", TimeUnixNano: s.StartTimeUnixNano + 10}}
+
+	sp, err := r.span(s)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sp.SpanID != "0000000000000001" || sp.ParentID != "0000000000000009" {
+		t.Errorf("ids = %q %q", sp.SpanID, sp.ParentID)
+	}
+	if sp.TraceID != "0102030405060708090a0b0c0d0e0f10" {
+		t.Errorf("trace id = %q", sp.TraceID)
+	}
+	if sp.SessionID != uuid.NewSHA1(appID, []byte("s-1")) {
+		t.Errorf("session id = %s, want one derived from the session.id", sp.SessionID)
+	}
+	if sp.Status != 1 {
+		t.Errorf("status = %d, want 1", sp.Status)
+	}
+	if sp.Attributes.ThreadName != "main" {
+		t.Errorf("thread name = %q, want main", sp.Attributes.ThreadName)
+	}
+	if len(sp.CheckPoints) != 1 || sp.CheckPoints[0].Name != "paid" {
+		t.Errorf("checkpoints = %+v", sp.CheckPoints)
+	}
+
+	data, err := json.Marshal(sp.UserDefinedAttribute)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `{"http_route":"/checkout"}` {
+		t.Errorf("user defined attributes = %s", data)
+	}
+}
+
+func TestOTLPLog(t *testing.T) {
+	appID := uuid.New()
+	r, err := newOTLPResource(appID, nil, newTestOTLPResource())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ts := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	l := &logspb.LogRecord{
+		TimeUnixNano:   uint64(ts.UnixNano()),
+		SeverityNumber: logspb.SeverityNumber_SEVERITY_NUMBER_WARN2,
+		Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "cart expired"}},
+		TraceId:        []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+	}
+
+	ev, err := r.log(l)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ev.Type != event.TypeLog || ev.Log.Body != "cart expired" {
+		t.Errorf("event = %q %+v", ev.Type, ev.Log)
+	}
+	if ev.Log.SeverityText != "warning" || ev.Log.SeverityNumber != 16 {
+		t.Errorf("severity = %q %d, want warning 16", ev.Log.SeverityText, ev.Log.SeverityNumber)
+	}
+	if want := uuid.NewSHA1(r.attribute.InstallationID, []byte("2026-10-01")); ev.SessionID != want {
+		t.Errorf("session id = %s, want the installation's session of the day %s", ev.SessionID, want)
+	}
+
+	again, err := r.log(l)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again.ID != ev.ID {
+		t.Errorf("event ids differ for the same record: %s, %s", ev.ID, again.ID)
+	}
+
+	data, err := json.Marshal(ev.UserDefinedAttribute)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `{"trace_id":"0102030405060708090a0b0c0d0e0f10"}` {
+		t.Errorf("user defined attributes = %s", data)
+	}
+
+	l.Body = nil
+	if _, err := r.log(l); err == nil {
+		t.Error("expected error for log without a body")
+	}
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	tests := []struct {
+		number     logspb.SeverityNumber
+		text       string
+		wantText   string
+		wantNumber int32
+	}{
+		{logspb.SeverityNumber_SEVERITY_NUMBER_TRACE, "", "debug", 8},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_INFO3, "", "info", 12},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "", "warning", 16},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_ERROR4, "", "error", 20},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_FATAL2, "", "fatal", 24},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "WARN", "warning", 16},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "critical", "fatal", 24},
+		{logspb.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED, "", "info", 12},
+	}
+
+	for _, tt := range tests {
+		text, number := otlpSeverity(tt.number, tt.text)
+		if text != tt.wantText || number != tt.wantNumber {
+			t.Errorf("otlpSeverity(%v, %q) = %q %d, want %q %d", tt.number, tt.text, text, number, tt.wantText, tt.wantNumber)
+		}
+	}
+}
+
+func TestReadOTLPTraces(t *testing.T) {
+	appID := uuid.New()
+	e := eventreq{appId: appID}
+
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{
+			{
+				Resource: newTestOTLPResource(),
+				ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{
+					newTestOTLPSpan("checkout", 1),
+					newTestOTLPSpan("checkout", 1),
+					newTestOTLPSpan(strings.Repeat("x", 100), 2),
+				}}},
+			},
+			{
+				Resource:   &resourcepb.Resource{},
+				ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{newTestOTLPSpan("checkout", 3)}}},
+			},
+		},
+	}
+
+	rejects := e.readOTLPTraces(req, nil)
+
+	if len(e.spans) != 1 {
+		t.Fatalf("spans = %d, want 1", len(e.spans))
+	}
+	if e.spans[0].AppID != appID {
+		t.Errorf("app id = %s, want %s", e.spans[0].AppID, appID)
+	}
+	if rejects.count != 2 {
+		t.Errorf("rejected = %d, want 2", rejects.count)
+	}
+	if e.payloadSize == 0 {
+		t.Error("payload size not counted")
+	}
+	if err := e.validate(); err != nil {
+		t.Errorf("batch invalid: %v", err)
+	}
+}
+
+func TestOTLPHexIDs(t *testing.T) {
+	body := `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"0102030405060708090a0b0c0d0e0f10","spanId":"0000000000000001","startTimeUnixNano":1760000000123456789,"name":"a"}]}]}]}`
+
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if err := decodeOTLP([]byte(body), otlpJSON, req); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	s := req.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+	if got := s.GetTraceId(); len(got) != 16 || got[15] != 16 {
+		t.Errorf("trace id = %x", got)
+	}
+	if got := s.GetSpanId(); len(got) != 8 || got[7] != 1 {
+		t.Errorf("span id = %x", got)
+	}
+	if s.GetStartTimeUnixNano() != 1760000000123456789 {
+		t.Errorf("start time = %d, want it intact", s.GetStartTimeUnixNano())
+	}
+}
@@ -0,0 +1,137 @@
+package measure
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/ingest/server"
+	"backend/libs/cache"
+	"backend/libs/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// overridesCacheTTL is how long an app's rate limit
+// overrides are trusted before they are read again.
+const overridesCacheTTL = time.Minute
+
+// overridesCache caches each app's
+// rate limit overrides.
+var overridesCache = cache.NewLRUCache(10000)
+
+// overridesCacheEntry is an app's rate limit
+// overrides and when they were read.
+type overridesCacheEntry struct {
+	overrides ratelimit.Overrides
+	at        time.Time
+}
+
+var ingestBatchRateLimitedCount metric.Int64Counter
+
+func init() {
+	meter := otel.Meter("measure/ingest")
+	counter, err := meter.Int64Counter(
+		"ingest_batch_rate_limited_count",
+		metric.WithDescription("Number of ingest batches dropped by rate limits"),
+	)
+	if err != nil {
+		panic(err)
+	}
+	ingestBatchRateLimitedCount = counter
+}
+
+// getAppLimits resolves the app's rate limits from the service's
+// defaults and the app's overrides. Fails open to the defaults
+// when the overrides can't be read.
+func getAppLimits(ctx context.Context, appId uuid.UUID) ratelimit.Limits {
+	defaults := server.Server.Config.IngestLimits
+
+	if v, ok := overridesCache.Get(appId.String()); ok {
+		if entry := v.(overridesCacheEntry); time.Since(entry.at) < overridesCacheTTL {
+			return defaults.Apply(entry.overrides)
+		}
+	}
+
+	overrides, err := ratelimit.GetOverrides(ctx, server.Server.PgPool, appId)
+	if err != nil {
+		fmt.Printf("rate limit: failed to read overrides for app %s, using defaults: %v\n", appId, err)
+		return defaults
+	}
+
+	overridesCache.Put(appId.String(), overridesCacheEntry{overrides: overrides, at: time.Now()})
+
+	return defaults.Apply(overrides)
+}
+
+// rateLimitBatch is what the request batch counts
+// against the rate limits, given its size in bytes.
+func (e *eventreq) rateLimitBatch(size uint64) (batch ratelimit.Batch) {
+	batch.Events = int64(len(e.events) + len(e.spans))
+	batch.Bytes = int64(min(size, math.MaxInt64))
+	batch.Installations = make(map[uuid.UUID]int64)
+
+	for i := range e.events {
+		batch.Installations[e.events[i].Attribute.InstallationID]++
+	}
+
+	for i := range e.spans {
+		batch.Installations[e.spans[i].Attributes.InstallationID]++
+	}
+
+	return
+}
+
+// checkRateLimit checks the request batch against the app's rate
+// limits. A throttled batch is counted as dropped and answered
+// with 429 and a Retry-After header, so that clients back off.
+// Reports whether the batch may be ingested. Fails open when
+// the limits can't be checked.
+func (e *eventreq) checkRateLimit(c *gin.Context, size uint64) bool {
+	ctx := c.Request.Context()
+
+	limits := getAppLimits(ctx, e.appId)
+	if limits.Unlimited() {
+		return true
+	}
+
+	now := time.Now()
+	batch := e.rateLimitBatch(size)
+
+	decision, err := ratelimit.Check(ctx, server.Server.VK, e.appId, limits, batch, now)
+	if err != nil {
+		fmt.Printf("rate limit: check failed for app %s, allowing batch: %v\n", e.appId, err)
+		return true
+	}
+
+	if decision.Allowed {
+		return true
+	}
+
+	if err := ratelimit.RecordDrop(ctx, server.Server.VK, e.appId, batch, now); err != nil {
+		fmt.Printf("rate limit: failed to record drop for app %s: %v\n", e.appId, err)
+	}
+
+	// apps are logged rather than counted, an attribute
+	// per app would grow the metric without bound
+	fmt.Printf("rate limit: dropped batch of app %s over its %s limit\n", e.appId, decision.Limit)
+
+	ingestBatchRateLimitedCount.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("limit", string(decision.Limit)),
+	))
+
+	c.Header("Retry-After", strconv.Itoa(int(decision.RetryAfter/time.Second)))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":   "rate limit exceeded",
+		"details": fmt.Sprintf("app exceeded its %s limit, retry after %s", decision.Limit, decision.RetryAfter),
+	})
+
+	return false
+}
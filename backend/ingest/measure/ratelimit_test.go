@@ -0,0 +1,105 @@
+//go:build integration
+
+package measure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"backend/ingest/server"
+	"backend/libs/event"
+	"backend/libs/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func newRateLimitContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/events", nil)
+	return c, w
+}
+
+func newRateLimitEventReq(appID, installationID uuid.UUID, n int) *eventreq {
+	e := &eventreq{appId: appID}
+	for range n {
+		var ev event.EventField
+		ev.Attribute.InstallationID = installationID
+		e.events = append(e.events, ev)
+	}
+	return e
+}
+
+func TestCheckRateLimit(t *testing.T) {
+	ctx := context.Background()
+
+	orig := server.Server.Config.IngestLimits
+	t.Cleanup(func() { server.Server.Config.IngestLimits = orig })
+
+	t.Run("unlimited apps are never throttled", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		server.Server.Config.IngestLimits = ratelimit.Limits{}
+
+		c, _ := newRateLimitContext()
+		if !newRateLimitEventReq(uuid.New(), uuid.New(), 1000).checkRateLimit(c, 1<<20) {
+			t.Error("checkRateLimit = false without limits, want true")
+		}
+	})
+
+	t.Run("throttled batches get 429 with Retry-After and count as dropped", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		server.Server.Config.IngestLimits = ratelimit.Limits{InstallationEventsPerSecond: 1}
+
+		appID := uuid.New()
+		installationID := uuid.New()
+
+		c, _ := newRateLimitContext()
+		if !newRateLimitEventReq(appID, installationID, 60).checkRateLimit(c, 100) {
+			t.Fatal("first batch throttled, want allowed")
+		}
+
+		c, w := newRateLimitContext()
+		if newRateLimitEventReq(appID, installationID, 5).checkRateLimit(c, 100) {
+			t.Fatal("batch over the installation limit allowed, want throttled")
+		}
+		if w.Code != http.StatusTooManyRequests {
+			t.Errorf("status = %d, want 429", w.Code)
+		}
+		if w.Header().Get("Retry-After") == "" {
+			t.Error("Retry-After header missing")
+		}
+
+		now := time.Now().UTC()
+		drops, err := ratelimit.GetDrops(ctx, server.Server.VK, appID, now.Year(), now.Month())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (ratelimit.Drops{Batches: 1, Events: 5, Bytes: 100}); drops != want {
+			t.Errorf("drops = %+v, want %+v", drops, want)
+		}
+	})
+
+	t.Run("app overrides replace the defaults", func(t *testing.T) {
+		defer cleanupAll(ctx, t)
+		server.Server.Config.IngestLimits = ratelimit.Limits{EventsPerSecond: 1}
+
+		teamID := uuid.New()
+		appID := uuid.New()
+		seedTeam(ctx, t, teamID, "team")
+		seedApp(ctx, t, appID, teamID, 30)
+
+		unlimited := int64(0)
+		if err := ratelimit.SaveOverrides(ctx, server.Server.PgPool, appID, ratelimit.Overrides{EventsPerSecond: &unlimited}); err != nil {
+			t.Fatal(err)
+		}
+
+		if limits := getAppLimits(ctx, appID); !limits.Unlimited() {
+			t.Errorf("limits = %+v, want the override to lift the default", limits)
+		}
+	})
+}
@@ -13,6 +13,7 @@ import (
 	"backend/libs/autumn"
 	"backend/libs/boot"
 	"backend/libs/bus"
+	"backend/libs/ratelimit"
 	"backend/libs/secret"
 
 	"cloud.google.com/go/cloudsqlconn"
@@ -70,6 +71,7 @@ type ServerConfig struct {
 	CH                         ClickhouseConfig
 	RD                         RedisConfig
 	IG                         IggyConfig
+	BusBackend                 string
 	ServiceAccountEmail        string
 	SymbolsBucket              string
 	SymbolsBucketRegion        string
@@ -86,6 +88,9 @@ type ServerConfig struct {
 	CloudEnv                   bool
 	IngestEnforceTimeWindow    bool
 	BillingEnabled             bool
+	// IngestLimits are the default rate limits of every
+	// app, unless the app overrides them.
+	IngestLimits ratelimit.Limits
 }
 
 // IsCloud is true if the service is
@@ -216,18 +221,20 @@ func NewConfig() *ServerConfig {
 		log.Fatal("REDIS_PORT env var is not set, cannot start valkey client")
 	}
 
+	busBackend := bus.SelfHostBackend()
+
 	iggyAddr := os.Getenv("IGGY_ADDR")
-	if iggyAddr == "" {
+	if iggyAddr == "" && busBackend == bus.BackendIggy {
 		log.Println("IGGY_ADDR env var is not set, ingestion will not work")
 	}
 
 	iggyUsername := os.Getenv("IGGY_USERNAME")
-	if iggyUsername == "" {
+	if iggyUsername == "" && busBackend == bus.BackendIggy {
 		log.Println("IGGY_USERNAME env var is not set, ingestion will not work")
 	}
 
 	iggyPassword := os.Getenv("IGGY_PASSWORD")
-	if iggyPassword == "" {
+	if iggyPassword == "" && busBackend == bus.BackendIggy {
 		log.Println("IGGY_PASSWORD env var is not set, ingestion will not work")
 	}
 
@@ -244,6 +251,12 @@ func NewConfig() *ServerConfig {
 	endpoint := os.Getenv("AWS_ENDPOINT_URL")
 	enforceIngestTimeWindow := os.Getenv("INGEST_ENFORCE_TIME_WINDOW") != ""
 
+	ingestLimits := ratelimit.Limits{
+		EventsPerSecond:             envLimit("INGEST_APP_EVENTS_PER_SECOND"),
+		BytesPerMinute:              envLimit("INGEST_APP_BYTES_PER_MINUTE"),
+		InstallationEventsPerSecond: envLimit("INGEST_INSTALLATION_EVENTS_PER_SECOND"),
+	}
+
 	return &ServerConfig{
 		PG: PostgresConfig{
 			DSN: postgresDSN,
@@ -260,6 +273,7 @@ func NewConfig() *ServerConfig {
 			Username: iggyUsername,
 			Password: iggyPassword,
 		},
+		BusBackend:                 busBackend,
 		ServiceAccountEmail:        serviceAccountEmail,
 		SymbolsBucket:              symbolsBucket,
 		SymbolsBucketRegion:        symbolsBucketRegion,
@@ -276,7 +290,25 @@ func NewConfig() *ServerConfig {
 		CloudEnv:                   cloudEnv,
 		IngestEnforceTimeWindow:    enforceIngestTimeWindow,
 		BillingEnabled:             billingEnabled,
+		IngestLimits:               ingestLimits,
+	}
+}
+
+// envLimit reads a rate limit from the named env var.
+// Unset or invalid values are no limit.
+func envLimit(name string) int64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
 	}
+
+	limit, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || limit < 0 {
+		log.Printf("Invalid %s value %q, not rate limiting\n", name, v)
+		return 0
+	}
+
+	return limit
 }
 
 func Init(config *ServerConfig) {
@@ -362,6 +394,12 @@ func Init(config *ServerConfig) {
 			log.Fatalf("failed to create Pub/Sub producer: %v", err)
 		}
 		busProducer = p
+	} else if config.BusBackend == bus.BackendPostgres {
+		p, err := bus.NewPostgresProducer(pgPool, "ingest-batch")
+		if err != nil {
+			log.Fatalf("failed to create Postgres producer: %v", err)
+		}
+		busProducer = p
 	} else {
 		p, err := bus.NewIggyProducer(
 			config.IG.Addr,
@@ -1,12 +1,16 @@
 // Package alertmsg builds the plain text messages and dashboard URLs
-// for crash spike, ANR spike, and bug report alerts. The alerts service
-// stores each message in the alerts table and hands the same string to
-// the email and Slack channels, so the builders emit no markup: each
-// channel applies its own formatting, email by escaping the text into
-// HTML and Slack by escaping mrkdwn control characters.
+// for crash spike, ANR spike, new fatal error group, bug report, network
+// regression and release regression alerts. The alerts service stores
+// each message in the alerts table and hands the same string to the
+// email and Slack channels, so the builders emit no markup: each channel
+// applies its own formatting, email by escaping the text into HTML and
+// Slack by escaping mrkdwn control characters.
 package alertmsg
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+)
 
 // CrashSpikeMessage builds the plain text message for a crash spike alert.
 func CrashSpikeMessage(file, method, message string) string {
@@ -54,3 +58,73 @@ func AnrSpikeURL(siteOrigin, teamId, appId, fingerprint, anrType, fileName strin
 func BugReportURL(siteOrigin, teamId, appId, bugReportId string) string {
 	return fmt.Sprintf("%s/%s/bug_reports/%s/%s", siteOrigin, teamId, appId, bugReportId)
 }
+
+// NetworkLatencyMessage builds the plain text message for a network
+// endpoint p95 latency regression alert.
+func NetworkLatencyMessage(domain, path string, currentMs, baselineMs float64) string {
+	return fmt.Sprintf("p95 latency is regressing at:\n\n%s%s - %.0f ms, up from %.0f ms (%s) over the last hour", domain, path, currentMs, baselineMs, percentChange(currentMs, baselineMs))
+}
+
+// NetworkErrorRateMessage builds the plain text message for a network
+// endpoint 4xx or 5xx rate regression alert. statusClass is the status
+// code bucket, like "5xx".
+func NetworkErrorRateMessage(domain, path, statusClass string, currentRate, baselineRate float64) string {
+	return fmt.Sprintf("%s responses are spiking at:\n\n%s%s - %.2f%% of requests, up from %.2f%% over the last hour", statusClass, domain, path, currentRate, baselineRate)
+}
+
+// NetworkTrafficDropMessage builds the plain text message for a network
+// endpoint traffic drop alert.
+func NetworkTrafficDropMessage(domain, path string, currentCount, baselineCount float64) string {
+	return fmt.Sprintf("Requests are dropping at:\n\n%s%s - %.0f requests, down from %.0f (%s) over the last hour", domain, path, currentCount, baselineCount, percentChange(currentCount, baselineCount))
+}
+
+// NetworkURL builds the dashboard URL of a network endpoint's details.
+func NetworkURL(siteOrigin, teamId, domain, path string) string {
+	query := url.Values{}
+	query.Set("domain", domain)
+	query.Set("path", path)
+	return fmt.Sprintf("%s/%s/network/details?%s", siteOrigin, teamId, query.Encode())
+}
+
+// ReleaseRegressionMessage builds the plain text message for a release
+// regression alert. The regression describes the metric that regressed
+// against the app's other releases.
+func ReleaseRegressionMessage(version, regression string) string {
+	return fmt.Sprintf("Release %s is regressing compared to other releases:\n\n%s over the last day", version, regression)
+}
+
+// ReleaseURL builds the dashboard URL for a release regression alert.
+func ReleaseURL(siteOrigin, teamId string) string {
+	return fmt.Sprintf("%s/%s/overview", siteOrigin, teamId)
+}
+
+// NewFatalGroupMessage builds the plain text message for a new fatal
+// error group alert. kind names the error, like "crash" or "ANR".
+func NewFatalGroupMessage(kind, version, file, method, message string) string {
+	return fmt.Sprintf("A new %s appeared in release %s:\n\n%s: %s() - %s", kind, version, file, method, message)
+}
+
+// MissingSymbolsMessage builds the plain text message for a missing
+// symbols alert. percentage is the percent of error frames symbolicated
+// & missing the count of debug ids errors referenced without symbols.
+func MissingSymbolsMessage(version string, percentage float64, missing int) string {
+	msg := fmt.Sprintf("Release %s is missing symbols:\n\n%.2f%% of error frames were symbolicated", version, percentage)
+	if missing > 0 {
+		msg += fmt.Sprintf(", %d debug ids have no symbols", missing)
+	}
+	return msg
+}
+
+// BuildsURL builds the dashboard URL for a missing symbols alert.
+func BuildsURL(siteOrigin, teamId string) string {
+	return fmt.Sprintf("%s/%s/builds", siteOrigin, teamId)
+}
+
+// percentChange formats the signed percent change
+// of current over baseline.
+func percentChange(current, baseline float64) string {
+	if baseline == 0 {
+		return "new"
+	}
+	return fmt.Sprintf("%+.0f%%", (current-baseline)/baseline*100)
+}
@@ -79,3 +79,65 @@ func TestBugReportURL(t *testing.T) {
 		t.Errorf("BugReportURL = %q, want %q", got, want)
 	}
 }
+
+func TestNetworkLatencyMessage(t *testing.T) {
+	got := NetworkLatencyMessage("api.example.com", "/users/*", 620, 300)
+	want := "p95 latency is regressing at:\n\napi.example.com/users/* - 620 ms, up from 300 ms (+107%) over the last hour"
+	if got != want {
+		t.Errorf("NetworkLatencyMessage = %q, want %q", got, want)
+	}
+}
+
+func TestNetworkErrorRateMessage(t *testing.T) {
+	got := NetworkErrorRateMessage("api.example.com", "/orders", "5xx", 8, 1)
+	want := "5xx responses are spiking at:\n\napi.example.com/orders - 8.00% of requests, up from 1.00% over the last hour"
+	if got != want {
+		t.Errorf("NetworkErrorRateMessage = %q, want %q", got, want)
+	}
+}
+
+func TestNetworkTrafficDropMessage(t *testing.T) {
+	got := NetworkTrafficDropMessage("api.example.com", "/feed", 50, 1000)
+	want := "Requests are dropping at:\n\napi.example.com/feed - 50 requests, down from 1000 (-95%) over the last hour"
+	if got != want {
+		t.Errorf("NetworkTrafficDropMessage = %q, want %q", got, want)
+	}
+}
+
+func TestNetworkURL(t *testing.T) {
+	got := NetworkURL("https://measure.sh", "team-1", "api.example.com", "/users/*")
+	want := "https://measure.sh/team-1/network/details?domain=api.example.com&path=%2Fusers%2F%2A"
+	if got != want {
+		t.Errorf("NetworkURL = %q, want %q", got, want)
+	}
+}
+
+func TestReleaseRegressionMessage(t *testing.T) {
+	got := ReleaseRegressionMessage("1.2.0 (120)", "crash free sessions dropped from 99.50% to 97.20%")
+	want := "Release 1.2.0 (120) is regressing compared to other releases:\n\ncrash free sessions dropped from 99.50% to 97.20% over the last day"
+	if got != want {
+		t.Errorf("ReleaseRegressionMessage = %q, want %q", got, want)
+	}
+}
+
+func TestNewFatalGroupMessage(t *testing.T) {
+	got := NewFatalGroupMessage("crash", "4.12.0 (412)", "MainActivity.kt", "onCreate", "boom")
+	want := "A new crash appeared in release 4.12.0 (412):\n\nMainActivity.kt: onCreate() - boom"
+	if got != want {
+		t.Errorf("NewFatalGroupMessage = %q, want %q", got, want)
+	}
+}
+
+func TestMissingSymbolsMessage(t *testing.T) {
+	got := MissingSymbolsMessage("1.2.0 (120)", 12.5, 2)
+	want := "Release 1.2.0 (120) is missing symbols:\n\n12.50% of error frames were symbolicated, 2 debug ids have no symbols"
+	if got != want {
+		t.Errorf("MissingSymbolsMessage = %q, want %q", got, want)
+	}
+
+	got = MissingSymbolsMessage("1.2.0 (120)", 0, 0)
+	want = "Release 1.2.0 (120) is missing symbols:\n\n0.00% of error frames were symbolicated"
+	if got != want {
+		t.Errorf("MissingSymbolsMessage = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,489 @@
+// Package alertrule defines user defined alert rules. A rule computes one
+// metric of an app over a window, like p95 cold launch duration or the
+// share of HTTP requests answered with a 5xx, and raises an alert when the
+// value, or its percent change against an earlier baseline window, crosses
+// a threshold. The api service validates and stores rules, the alerts
+// service evaluates them.
+package alertrule
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertType is the alert type of alerts raised by rules. The rule id is
+// the alert's entity id.
+const AlertType = "alert_rule"
+
+// maxNameLength is the maximum length of a rule name.
+const maxNameLength = 128
+
+// maxTargetLength is the maximum length of a rule target.
+const maxTargetLength = 256
+
+// maxAppVersions is the maximum number of app versions a rule can be
+// scoped to.
+const maxAppVersions = 20
+
+const (
+	minWindow   = 5 * time.Minute
+	maxWindow   = 7 * 24 * time.Hour
+	minInterval = time.Minute
+	maxInterval = 24 * time.Hour
+	maxCooldown = 30 * 24 * time.Hour
+	maxBaseline = 90 * 24 * time.Hour
+)
+
+type Metric string
+
+const (
+	// MetricColdLaunch is the cold launch duration in milliseconds.
+	MetricColdLaunch Metric = "cold_launch"
+	// MetricWarmLaunch is the warm launch duration in milliseconds.
+	MetricWarmLaunch Metric = "warm_launch"
+	// MetricHotLaunch is the hot launch duration in milliseconds.
+	MetricHotLaunch Metric = "hot_launch"
+	// MetricCrashRate is the percent of sessions with a crash.
+	MetricCrashRate Metric = "crash_rate"
+	// MetricAnrRate is the percent of sessions with an ANR.
+	MetricAnrRate Metric = "anr_rate"
+	// MetricHttp5xxRate is the percent of HTTP requests answered with a
+	// 5xx status code.
+	MetricHttp5xxRate Metric = "http_5xx_rate"
+	// MetricHttp4xxRate is the percent of HTTP requests answered with a
+	// 4xx status code.
+	MetricHttp4xxRate Metric = "http_4xx_rate"
+	// MetricHttpLatency is the HTTP request latency in milliseconds.
+	MetricHttpLatency Metric = "http_latency"
+	// MetricSpanDuration is the duration of a span in milliseconds.
+	MetricSpanDuration Metric = "span_duration"
+	// MetricEventCount is the number of custom events with a name.
+	MetricEventCount Metric = "event_count"
+)
+
+// Metrics lists every metric a rule can evaluate.
+var Metrics = []Metric{
+	MetricColdLaunch,
+	MetricWarmLaunch,
+	MetricHotLaunch,
+	MetricCrashRate,
+	MetricAnrRate,
+	MetricHttp5xxRate,
+	MetricHttp4xxRate,
+	MetricHttpLatency,
+	MetricSpanDuration,
+	MetricEventCount,
+}
+
+// IsDuration reports whether the metric is a duration, which needs an
+// aggregation.
+func (m Metric) IsDuration() bool {
+	switch m {
+	case MetricColdLaunch, MetricWarmLaunch, MetricHotLaunch, MetricHttpLatency, MetricSpanDuration:
+		return true
+	}
+	return false
+}
+
+// IsRate reports whether the metric is a percentage.
+func (m Metric) IsRate() bool {
+	switch m {
+	case MetricCrashRate, MetricAnrRate, MetricHttp5xxRate, MetricHttp4xxRate:
+		return true
+	}
+	return false
+}
+
+// IsHttp reports whether the metric is computed from HTTP requests. The
+// target of an HTTP metric is an optional url pattern.
+func (m Metric) IsHttp() bool {
+	switch m {
+	case MetricHttp5xxRate, MetricHttp4xxRate, MetricHttpLatency:
+		return true
+	}
+	return false
+}
+
+// NeedsTarget reports whether a rule on the metric must name a target.
+func (m Metric) NeedsTarget() bool {
+	return m == MetricSpanDuration || m == MetricEventCount
+}
+
+type Aggregation string
+
+const (
+	AggregationP50 Aggregation = "p50"
+	AggregationP90 Aggregation = "p90"
+	AggregationP95 Aggregation = "p95"
+	AggregationP99 Aggregation = "p99"
+	AggregationAvg Aggregation = "avg"
+)
+
+// Quantile returns the quantile level of a percentile aggregation and
+// false for avg.
+func (a Aggregation) Quantile() (float64, bool) {
+	switch a {
+	case AggregationP50:
+		return 0.50, true
+	case AggregationP90:
+		return 0.90, true
+	case AggregationP95:
+		return 0.95, true
+	case AggregationP99:
+		return 0.99, true
+	}
+	return 0, false
+}
+
+type Operator string
+
+const (
+	OperatorGreaterThan Operator = "gt"
+	OperatorLessThan    Operator = "lt"
+)
+
+type Comparison string
+
+const (
+	// ComparisonAbsolute compares the metric value to the threshold.
+	ComparisonAbsolute Comparison = "absolute"
+	// ComparisonRelative compares the percent change of the metric value
+	// against the baseline window to the threshold.
+	ComparisonRelative Comparison = "relative"
+)
+
+const (
+	ChannelEmail   = "email"
+	ChannelSlack   = "slack"
+	ChannelWebhook = "webhook"
+)
+
+// Channels lists every channel a rule can alert on.
+var Channels = []string{ChannelEmail, ChannelSlack, ChannelWebhook}
+
+// Rule is a user defined alert rule of an app.
+type Rule struct {
+	ID                    uuid.UUID   `json:"id"`
+	TeamID                uuid.UUID   `json:"team_id"`
+	AppID                 uuid.UUID   `json:"app_id"`
+	Name                  string      `json:"name"`
+	Metric                Metric      `json:"metric"`
+	Aggregation           Aggregation `json:"aggregation"`
+	Target                string      `json:"target"`
+	AppVersions           []string    `json:"app_versions"`
+	Operator              Operator    `json:"operator"`
+	Threshold             float64     `json:"threshold"`
+	Comparison            Comparison  `json:"comparison"`
+	BaselineOffsetMinutes int         `json:"baseline_offset_minutes"`
+	MinSampleCount        int         `json:"min_sample_count"`
+	WindowMinutes         int         `json:"window_minutes"`
+	IntervalMinutes       int         `json:"interval_minutes"`
+	CooldownMinutes       int         `json:"cooldown_minutes"`
+	Channels              []string    `json:"channels"`
+	IsActive              bool        `json:"is_active"`
+	LastEvaluatedAt       *time.Time  `json:"last_evaluated_at"`
+	CreatedAt             time.Time   `json:"created_at"`
+	UpdatedAt             time.Time   `json:"updated_at"`
+}
+
+// Window is the length of the window the metric is computed over.
+func (r Rule) Window() time.Duration {
+	return time.Duration(r.WindowMinutes) * time.Minute
+}
+
+// Interval is how often the rule is evaluated.
+func (r Rule) Interval() time.Duration {
+	return time.Duration(r.IntervalMinutes) * time.Minute
+}
+
+// Cooldown is the minimum time between two alerts of the rule.
+func (r Rule) Cooldown() time.Duration {
+	return time.Duration(r.CooldownMinutes) * time.Minute
+}
+
+// BaselineOffset is how far back the baseline window starts from the
+// current window.
+func (r Rule) BaselineOffset() time.Duration {
+	return time.Duration(r.BaselineOffsetMinutes) * time.Minute
+}
+
+// Due reports whether the rule should be evaluated at now.
+func (r Rule) Due(now time.Time) bool {
+	if !r.IsActive {
+		return false
+	}
+	if r.LastEvaluatedAt == nil {
+		return true
+	}
+	return !now.Before(r.LastEvaluatedAt.Add(r.Interval()))
+}
+
+// Normalize fills defaults and tidies user input before validation. Duration
+// metrics default to p95, other metrics drop any aggregation. Channels and
+// app versions are deduplicated.
+func (r *Rule) Normalize() {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Target = strings.TrimSpace(r.Target)
+
+	if r.Metric.IsDuration() {
+		if r.Aggregation == "" {
+			r.Aggregation = AggregationP95
+		}
+	} else {
+		r.Aggregation = ""
+	}
+
+	if r.Comparison == "" {
+		r.Comparison = ComparisonAbsolute
+	}
+	if r.Comparison == ComparisonAbsolute {
+		r.BaselineOffsetMinutes = 0
+	}
+
+	r.Channels = dedupe(r.Channels)
+	r.AppVersions = dedupe(r.AppVersions)
+}
+
+// Validate checks the rule for errors a user can fix. The returned error
+// is meant to be shown to the user.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(r.Name) > maxNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxNameLength)
+	}
+
+	if !slices.Contains(Metrics, r.Metric) {
+		return fmt.Errorf("metric must be one of %s", joinMetrics(Metrics))
+	}
+
+	if r.Metric.IsDuration() {
+		if _, ok := r.Aggregation.Quantile(); !ok && r.Aggregation != AggregationAvg {
+			return errors.New("aggregation must be one of p50, p90, p95, p99 or avg")
+		}
+	}
+
+	if r.Metric.NeedsTarget() && r.Target == "" {
+		return fmt.Errorf("target is required for metric %s", r.Metric)
+	}
+	if !r.Metric.NeedsTarget() && !r.Metric.IsHttp() && r.Target != "" {
+		return fmt.Errorf("target is not supported for metric %s", r.Metric)
+	}
+	if len(r.Target) > maxTargetLength {
+		return fmt.Errorf("target must be at most %d characters", maxTargetLength)
+	}
+
+	if len(r.AppVersions) > maxAppVersions {
+		return fmt.Errorf("app_versions must have at most %d versions", maxAppVersions)
+	}
+	for _, v := range r.AppVersions {
+		if strings.TrimSpace(v) == "" {
+			return errors.New("app_versions must not contain empty versions")
+		}
+	}
+
+	if r.Operator != OperatorGreaterThan && r.Operator != OperatorLessThan {
+		return errors.New("operator must be gt or lt")
+	}
+
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		return errors.New("threshold must be a number")
+	}
+
+	switch r.Comparison {
+	case ComparisonAbsolute:
+		if r.Threshold < 0 {
+			return errors.New("threshold must not be negative")
+		}
+		if r.Metric.IsRate() && r.Threshold > 100 {
+			return errors.New("threshold of a rate metric must be between 0 and 100")
+		}
+	case ComparisonRelative:
+		if r.Threshold <= 0 {
+			return errors.New("threshold of a relative rule is a percent change and must be greater than 0")
+		}
+		if r.BaselineOffset() < r.Window() {
+			return errors.New("baseline_offset_minutes must be at least window_minutes so the windows do not overlap")
+		}
+		if r.BaselineOffset() > maxBaseline {
+			return fmt.Errorf("baseline_offset_minutes must be at most %d", int(maxBaseline.Minutes()))
+		}
+	default:
+		return errors.New("comparison must be absolute or relative")
+	}
+
+	if r.MinSampleCount < 0 {
+		return errors.New("min_sample_count must not be negative")
+	}
+
+	if r.Window() < minWindow || r.Window() > maxWindow {
+		return fmt.Errorf("window_minutes must be between %d and %d", int(minWindow.Minutes()), int(maxWindow.Minutes()))
+	}
+	if r.Interval() < minInterval || r.Interval() > maxInterval {
+		return fmt.Errorf("interval_minutes must be between %d and %d", int(minInterval.Minutes()), int(maxInterval.Minutes()))
+	}
+	if r.Cooldown() < 0 || r.Cooldown() > maxCooldown {
+		return fmt.Errorf("cooldown_minutes must be between 0 and %d", int(maxCooldown.Minutes()))
+	}
+
+	if len(r.Channels) == 0 {
+		return errors.New("channels must have at least one channel")
+	}
+	for _, c := range r.Channels {
+		if !slices.Contains(Channels, c) {
+			return fmt.Errorf("channels must be any of %s", strings.Join(Channels, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Evaluate reports whether the value breaches the rule. For relative
+// rules it also returns the percent change of value against baseline. A
+// relative rule never breaches against a zero baseline, there is no
+// meaningful change to compare.
+func (r Rule) Evaluate(value, baseline float64) (breached bool, change float64) {
+	compared := value
+	if r.Comparison == ComparisonRelative {
+		if baseline == 0 {
+			return false, 0
+		}
+		change = (value - baseline) / baseline * 100
+		compared = change
+		// a relative lt rule is about drops, so compare the size of the
+		// drop against the threshold
+		if r.Operator == OperatorLessThan {
+			return -change > r.Threshold, change
+		}
+	}
+
+	switch r.Operator {
+	case OperatorGreaterThan:
+		return compared > r.Threshold, change
+	case OperatorLessThan:
+		return compared < r.Threshold, change
+	}
+	return false, change
+}
+
+// Describe describes what the rule measures, like "p95 cold launch for
+// 1.2.0" or "5xx rate of api.example.com/*".
+func (r Rule) Describe() string {
+	var b strings.Builder
+	if r.Aggregation != "" {
+		b.WriteString(string(r.Aggregation))
+		b.WriteString(" ")
+	}
+
+	switch r.Metric {
+	case MetricColdLaunch:
+		b.WriteString("cold launch")
+	case MetricWarmLaunch:
+		b.WriteString("warm launch")
+	case MetricHotLaunch:
+		b.WriteString("hot launch")
+	case MetricCrashRate:
+		b.WriteString("crash rate")
+	case MetricAnrRate:
+		b.WriteString("ANR rate")
+	case MetricHttp5xxRate:
+		b.WriteString("HTTP 5xx rate")
+	case MetricHttp4xxRate:
+		b.WriteString("HTTP 4xx rate")
+	case MetricHttpLatency:
+		b.WriteString("HTTP latency")
+	case MetricSpanDuration:
+		b.WriteString("span duration")
+	case MetricEventCount:
+		b.WriteString("event count")
+	default:
+		b.WriteString(string(r.Metric))
+	}
+
+	if r.Target != "" {
+		b.WriteString(" of ")
+		b.WriteString(r.Target)
+	}
+
+	if len(r.AppVersions) > 0 {
+		b.WriteString(" for ")
+		b.WriteString(strings.Join(r.AppVersions, ", "))
+	}
+
+	return b.String()
+}
+
+// FormatValue formats a metric value with the metric's unit.
+func (r Rule) FormatValue(value float64) string {
+	switch {
+	case r.Metric.IsDuration():
+		return strconv.FormatFloat(value, 'f', 0, 64) + "ms"
+	case r.Metric.IsRate():
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+	}
+	return strconv.FormatFloat(value, 'f', 0, 64)
+}
+
+// Message builds the plain text message for an alert raised by the rule.
+func (r Rule) Message(value, baseline, change float64) string {
+	op := "above"
+	if r.Operator == OperatorLessThan {
+		op = "below"
+	}
+
+	if r.Comparison == ComparisonRelative {
+		direction := "up"
+		if change < 0 {
+			direction = "down"
+		}
+		return fmt.Sprintf("%s: %s is %s, %s %.1f%% from %s, %s the %.1f%% change threshold.",
+			r.Name, r.Describe(), r.FormatValue(value), direction, math.Abs(change), r.FormatValue(baseline), op, r.Threshold)
+	}
+
+	return fmt.Sprintf("%s: %s is %s, %s the %s threshold.",
+		r.Name, r.Describe(), r.FormatValue(value), op, r.FormatValue(r.Threshold))
+}
+
+// SplitURLPattern splits an HTTP target like "api.example.com/v1/*" into
+// its domain and a path LIKE pattern. A "*" matches any run of characters
+// and LIKE wildcards in the target are escaped. An empty path matches
+// every path of the domain.
+func SplitURLPattern(target string) (domain, pathLike string) {
+	target = strings.TrimPrefix(target, "https://")
+	target = strings.TrimPrefix(target, "http://")
+
+	domain, path, found := strings.Cut(target, "/")
+	if !found || path == "" {
+		return domain, "%"
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return domain, replacer.Replace("/" + path)
+}
+
+func dedupe(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinMetrics(metrics []Metric) string {
+	names := make([]string, len(metrics))
+	for i, m := range metrics {
+		names[i] = string(m)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,221 @@
+package alertrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func validRule() Rule {
+	return Rule{
+		Name:            "Slow cold launch",
+		Metric:          MetricColdLaunch,
+		Operator:        OperatorGreaterThan,
+		Threshold:       3000,
+		WindowMinutes:   60,
+		IntervalMinutes: 15,
+		CooldownMinutes: 240,
+		Channels:        []string{ChannelEmail},
+		IsActive:        true,
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	r := validRule()
+	r.Name = "  Slow cold launch "
+	r.Channels = []string{"email", " slack", "email"}
+	r.Normalize()
+
+	if r.Name != "Slow cold launch" {
+		t.Errorf("name = %q, want it trimmed", r.Name)
+	}
+	if r.Aggregation != AggregationP95 {
+		t.Errorf("aggregation = %q, want p95 by default for a duration metric", r.Aggregation)
+	}
+	if r.Comparison != ComparisonAbsolute {
+		t.Errorf("comparison = %q, want absolute by default", r.Comparison)
+	}
+	if strings.Join(r.Channels, ",") != "email,slack" {
+		t.Errorf("channels = %v, want them trimmed and deduplicated", r.Channels)
+	}
+
+	r = validRule()
+	r.Metric = MetricCrashRate
+	r.Aggregation = AggregationP99
+	r.Normalize()
+	if r.Aggregation != "" {
+		t.Errorf("aggregation = %q, want it dropped for a rate metric", r.Aggregation)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(r *Rule)
+		wantErr string
+	}{
+		{"valid", func(r *Rule) {}, ""},
+		{"missing name", func(r *Rule) { r.Name = "" }, "name is required"},
+		{"unknown metric", func(r *Rule) { r.Metric = "battery" }, "metric must be one of"},
+		{"bad aggregation", func(r *Rule) { r.Aggregation = "p42" }, "aggregation must be"},
+		{"span without target", func(r *Rule) { r.Metric = MetricSpanDuration }, "target is required"},
+		{"target on launch", func(r *Rule) { r.Target = "checkout" }, "target is not supported"},
+		{"http pattern", func(r *Rule) {
+			r.Metric = MetricHttp5xxRate
+			r.Aggregation = ""
+			r.Threshold = 2
+			r.Target = "api.example.com/*"
+		}, ""},
+		{"rate above 100", func(r *Rule) {
+			r.Metric = MetricCrashRate
+			r.Aggregation = ""
+			r.Threshold = 120
+		}, "between 0 and 100"},
+		{"bad operator", func(r *Rule) { r.Operator = "eq" }, "operator must be"},
+		{"negative threshold", func(r *Rule) { r.Threshold = -1 }, "must not be negative"},
+		{"relative overlapping baseline", func(r *Rule) {
+			r.Comparison = ComparisonRelative
+			r.Threshold = 30
+			r.BaselineOffsetMinutes = 30
+		}, "baseline_offset_minutes must be at least"},
+		{"relative week over week", func(r *Rule) {
+			r.Comparison = ComparisonRelative
+			r.Threshold = 30
+			r.BaselineOffsetMinutes = 7 * 24 * 60
+		}, ""},
+		{"short window", func(r *Rule) { r.WindowMinutes = 1 }, "window_minutes must be"},
+		{"zero interval", func(r *Rule) { r.IntervalMinutes = 0 }, "interval_minutes must be"},
+		{"long cooldown", func(r *Rule) { r.CooldownMinutes = 60 * 24 * 60 }, "cooldown_minutes must be"},
+		{"no channels", func(r *Rule) { r.Channels = nil }, "at least one channel"},
+		{"unknown channel", func(r *Rule) { r.Channels = []string{"pager"} }, "channels must be any of"},
+		{"empty version", func(r *Rule) { r.AppVersions = []string{" "} }, "empty versions"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := validRule()
+			r.Aggregation = AggregationP95
+			r.Comparison = ComparisonAbsolute
+			c.mutate(&r)
+
+			err := r.Validate()
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("Validate = %v, want an error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []struct {
+		name       string
+		comparison Comparison
+		operator   Operator
+		threshold  float64
+		value      float64
+		baseline   float64
+		want       bool
+		wantChange float64
+	}{
+		{"absolute above", ComparisonAbsolute, OperatorGreaterThan, 3000, 3200, 0, true, 0},
+		{"absolute at threshold", ComparisonAbsolute, OperatorGreaterThan, 3000, 3000, 0, false, 0},
+		{"absolute below", ComparisonAbsolute, OperatorLessThan, 10, 5, 0, true, 0},
+		{"relative regression", ComparisonRelative, OperatorGreaterThan, 30, 1400, 1000, true, 40},
+		{"relative within threshold", ComparisonRelative, OperatorGreaterThan, 30, 1200, 1000, false, 20},
+		{"relative improvement on gt rule", ComparisonRelative, OperatorGreaterThan, 30, 500, 1000, false, -50},
+		{"relative drop", ComparisonRelative, OperatorLessThan, 30, 500, 1000, true, -50},
+		{"relative zero baseline", ComparisonRelative, OperatorGreaterThan, 30, 500, 0, false, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := Rule{Comparison: c.comparison, Operator: c.operator, Threshold: c.threshold}
+			got, change := r.Evaluate(c.value, c.baseline)
+			if got != c.want {
+				t.Errorf("breached = %v, want %v", got, c.want)
+			}
+			if change != c.wantChange {
+				t.Errorf("change = %v, want %v", change, c.wantChange)
+			}
+		})
+	}
+}
+
+func TestDue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	r := validRule()
+
+	if !r.Due(now) {
+		t.Error("never evaluated rule is not due, want due")
+	}
+
+	recent := now.Add(-10 * time.Minute)
+	r.LastEvaluatedAt = &recent
+	if r.Due(now) {
+		t.Error("rule evaluated 10m ago with a 15m interval is due, want not due")
+	}
+
+	old := now.Add(-15 * time.Minute)
+	r.LastEvaluatedAt = &old
+	if !r.Due(now) {
+		t.Error("rule evaluated an interval ago is not due, want due")
+	}
+
+	r.IsActive = false
+	if r.Due(now) {
+		t.Error("inactive rule is due, want not due")
+	}
+}
+
+func TestMessage(t *testing.T) {
+	r := validRule()
+	r.Aggregation = AggregationP95
+	r.AppVersions = []string{"1.2.0"}
+
+	want := "Slow cold launch: p95 cold launch for 1.2.0 is 3450ms, above the 3000ms threshold."
+	if got := r.Message(3450, 0, 0); got != want {
+		t.Errorf("Message = %q, want %q", got, want)
+	}
+
+	r = Rule{
+		Name:        "Checkout regression",
+		Metric:      MetricSpanDuration,
+		Aggregation: AggregationP90,
+		Target:      "checkout",
+		Operator:    OperatorGreaterThan,
+		Threshold:   30,
+		Comparison:  ComparisonRelative,
+	}
+	want = "Checkout regression: p90 span duration of checkout is 1400ms, up 40.0% from 1000ms, above the 30.0% change threshold."
+	if got := r.Message(1400, 1000, 40); got != want {
+		t.Errorf("Message = %q, want %q", got, want)
+	}
+}
+
+func TestSplitURLPattern(t *testing.T) {
+	cases := []struct {
+		target     string
+		wantDomain string
+		wantPath   string
+	}{
+		{"api.example.com", "api.example.com", "%"},
+		{"api.example.com/", "api.example.com", "%"},
+		{"api.example.com/*", "api.example.com", "/%"},
+		{"https://api.example.com/v1/users/*/orders", "api.example.com", "/v1/users/%/orders"},
+		{"api.example.com/v1/user_info", "api.example.com", `/v1/user\_info`},
+		{"api.example.com/100%", "api.example.com", `/100\%`},
+	}
+
+	for _, c := range cases {
+		domain, path := SplitURLPattern(c.target)
+		if domain != c.wantDomain || path != c.wantPath {
+			t.Errorf("SplitURLPattern(%q) = %q, %q, want %q, %q", c.target, domain, path, c.wantDomain, c.wantPath)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package bus
 
 import (
 	"context"
+	"log"
+	"os"
 	"time"
 
 	"cloud.google.com/go/pubsub/v2"
@@ -11,6 +13,29 @@ import (
 // DefaultStreamName is the global stream for all message streaming operations.
 const DefaultStreamName = "measure"
 
+// Self-hosted installs pick their backend with the BUS_BACKEND env var.
+// Cloud always uses Pub/Sub.
+const (
+	// BackendIggy is the Apache Iggy backend, the default.
+	BackendIggy = "iggy"
+	// BackendPostgres is the Postgres backend, needing no broker.
+	BackendPostgres = "postgres"
+)
+
+// SelfHostBackend returns the backend named by the BUS_BACKEND env var,
+// BackendIggy if unset or unknown.
+func SelfHostBackend() string {
+	switch backend := os.Getenv("BUS_BACKEND"); backend {
+	case "", BackendIggy:
+		return BackendIggy
+	case BackendPostgres:
+		return BackendPostgres
+	default:
+		log.Printf("bus: unknown BUS_BACKEND %q, using %s", backend, BackendIggy)
+		return BackendIggy
+	}
+}
+
 // Producer publishes messages to a topic/stream.
 type Producer interface {
 	// Publish sends data to the configured topic/stream. Blocks until the
@@ -49,6 +74,9 @@ type pubSubConfig struct {
 	// receiveSettings configures receive behaviour (consumer-only).
 	// If nil, the subscriber's default settings are used.
 	receiveSettings *pubsub.ReceiveSettings
+	// deadLetter configures dead-lettering (consumer-only).
+	// If nil, failing messages are redelivered indefinitely.
+	deadLetter *deadLetterConfig
 }
 
 // WithPubSubProjectID overrides the GCP project ID.
@@ -68,6 +96,17 @@ func WithPubSubReceiveSettings(s pubsub.ReceiveSettings) PubSubOption {
 	}
 }
 
+// WithPubSubDeadLetter publishes messages the handler failed on
+// maxAttempts times to p as a [DeadLetter] and acknowledges them.
+// Attempts are taken from the subscription's delivery attempt
+// counter when it has a dead-letter policy of its own, and
+// counted by the consumer otherwise.
+func WithPubSubDeadLetter(p Producer, maxAttempts int) PubSubOption {
+	return func(c *pubSubConfig) {
+		c.deadLetter = &deadLetterConfig{producer: p, maxAttempts: maxAttempts}
+	}
+}
+
 // WithPublishSettings sets the PublishSettings for the Pub/Sub producer.
 // If not provided, pubsub.DefaultPublishSettings is used.
 func WithPubSubPublishSettings(s pubsub.PublishSettings) PubSubOption {
@@ -94,6 +133,9 @@ type iggyConfig struct {
 	pollInterval time.Duration
 	// pollingStrategy selects how the server determines the next batch of messages (consumer-only).
 	pollingStrategy *iggcon.PollingStrategy
+	// deadLetter configures dead-lettering (consumer-only).
+	// If nil, failing messages are retried indefinitely.
+	deadLetter *deadLetterConfig
 }
 
 // WithIggyPartitionID routes all produced messages to the given partition ID.
@@ -136,3 +178,74 @@ func WithIggyPollingStrategy(s iggcon.PollingStrategy) IggyOption {
 		c.pollingStrategy = &s
 	}
 }
+
+// WithIggyDeadLetter publishes messages the handler failed on
+// maxAttempts times to p as a [DeadLetter] and commits their
+// offsets, so a poison message no longer blocks its partition.
+func WithIggyDeadLetter(p Producer, maxAttempts int) IggyOption {
+	return func(c *iggyConfig) {
+		c.deadLetter = &deadLetterConfig{producer: p, maxAttempts: maxAttempts}
+	}
+}
+
+// PostgresOption configures a Postgres consumer.
+type PostgresOption func(*postgresConfig)
+
+type postgresConfig struct {
+	// batchSize is the number of messages claimed per poll.
+	batchSize int
+	// pollInterval is the delay between polls when no messages are available.
+	pollInterval time.Duration
+	// lease is how long a claimed message stays hidden from other consumers.
+	lease time.Duration
+	// retryDelay is the delay before a failed message is retried.
+	retryDelay time.Duration
+	// deadLetter configures dead-lettering.
+	// If nil, failing messages are retried indefinitely.
+	deadLetter *deadLetterConfig
+}
+
+// WithPostgresBatchSize sets the number of messages claimed per poll
+// (default: 10). Every claimed message's lease runs while the batch is
+// handled, so keep batches small enough to finish within the lease.
+func WithPostgresBatchSize(n int) PostgresOption {
+	return func(c *postgresConfig) {
+		c.batchSize = n
+	}
+}
+
+// WithPostgresPollInterval sets the delay between polls when no messages are
+// available, which is also the delay before a failed message is retried
+// unless set with [WithPostgresRetryDelay] (default: 500ms).
+func WithPostgresPollInterval(d time.Duration) PostgresOption {
+	return func(c *postgresConfig) {
+		c.pollInterval = d
+	}
+}
+
+// WithPostgresLease sets how long a claimed message stays hidden from other
+// consumers (default: 5m). A message still being handled when its lease runs
+// out is handed out again.
+func WithPostgresLease(d time.Duration) PostgresOption {
+	return func(c *postgresConfig) {
+		c.lease = d
+	}
+}
+
+// WithPostgresRetryDelay sets the delay before a message the handler failed
+// on is handed out again (default: the poll interval). Use it for work that
+// waits on something outside the bus, so retries don't spin.
+func WithPostgresRetryDelay(d time.Duration) PostgresOption {
+	return func(c *postgresConfig) {
+		c.retryDelay = d
+	}
+}
+
+// WithPostgresDeadLetter publishes messages the handler failed on maxAttempts
+// times to p as a [DeadLetter] and deletes them. Attempts are counted in the
+// message's row, so they survive consumer restarts.
+func WithPostgresDeadLetter(p Producer, maxAttempts int) PostgresOption {
+	return func(c *postgresConfig) {
+		c.deadLetter = &deadLetterConfig{producer: p, maxAttempts: maxAttempts}
+	}
+}
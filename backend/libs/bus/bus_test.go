@@ -222,6 +222,32 @@ func TestDefaultStreamName(t *testing.T) {
 	}
 }
 
+func TestSelfHostBackend(t *testing.T) {
+	for env, want := range map[string]string{
+		"":         BackendIggy,
+		"iggy":     BackendIggy,
+		"postgres": BackendPostgres,
+		"kafka":    BackendIggy,
+	} {
+		t.Setenv("BUS_BACKEND", env)
+		if got := SelfHostBackend(); got != want {
+			t.Errorf("SelfHostBackend() with BUS_BACKEND=%q = %q, want %q", env, got, want)
+		}
+	}
+}
+
+func TestWithPostgresOptions(t *testing.T) {
+	cfg := &postgresConfig{}
+	WithPostgresBatchSize(25)(cfg)
+	WithPostgresPollInterval(2 * time.Second)(cfg)
+	WithPostgresLease(time.Minute)(cfg)
+	WithPostgresRetryDelay(time.Hour)(cfg)
+
+	if cfg.batchSize != 25 || cfg.pollInterval != 2*time.Second || cfg.lease != time.Minute || cfg.retryDelay != time.Hour {
+		t.Errorf("config = %+v, want batch size 25, poll interval 2s, lease 1m and retry delay 1h", cfg)
+	}
+}
+
 func TestWithIggyPartitionID(t *testing.T) {
 	cfg := &iggyConfig{}
 	WithIggyPartitionID(42)(cfg)
@@ -0,0 +1,150 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"backend/libs/cache"
+)
+
+// maxTrackedAttempts caps the number of failing messages
+// whose attempts a consumer keeps count of.
+const maxTrackedAttempts = 10_000
+
+// DeadLetter is a message a consumer gave up on after its
+// handler failed repeatedly. It's published to the dead-letter
+// topic as JSON, carrying the original payload untouched.
+type DeadLetter struct {
+	// Topic is the topic or subscription the
+	// message was consumed from.
+	Topic string `json:"topic"`
+	// Attempts is the number of times the
+	// handler failed on the message.
+	Attempts int `json:"attempts"`
+	// Reason is the handler's last error.
+	Reason string `json:"reason"`
+	// FailedAt is when the message was
+	// dead-lettered.
+	FailedAt time.Time `json:"failed_at"`
+	// Data is the original payload.
+	Data []byte `json:"data"`
+}
+
+// permanentError marks a handler error
+// retrying won't fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks a handler error as one retrying won't fix,
+// like a malformed payload. A consumer with dead-lettering
+// dead-letters the message on its first failure; without, the
+// message is retried like on any other error.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// DecodeDeadLetter decodes a message consumed
+// from a dead-letter topic.
+func DecodeDeadLetter(data []byte) (dl DeadLetter, err error) {
+	if err = json.Unmarshal(data, &dl); err != nil {
+		err = fmt.Errorf("bus: invalid dead letter: %w", err)
+	}
+	return
+}
+
+// deadLetterConfig configures dead-lettering
+// for a consumer.
+type deadLetterConfig struct {
+	// producer publishes to the dead-letter topic.
+	producer Producer
+	// maxAttempts is the number of failed attempts
+	// after which a message is dead-lettered.
+	maxAttempts int
+}
+
+// deadLetterer dead-letters a consumer's messages
+// once its handler failed on them enough times.
+type deadLetterer struct {
+	deadLetterConfig
+	// topic is the source recorded on dead letters.
+	topic string
+	// attempts counts failed attempts per message key.
+	attempts *cache.LRUCache
+	// now returns the current time.
+	now func() time.Time
+}
+
+// newDeadLetterer returns nil when dead-lettering
+// isn't configured.
+func newDeadLetterer(cfg *deadLetterConfig, topic string) *deadLetterer {
+	if cfg == nil || cfg.producer == nil || cfg.maxAttempts < 1 {
+		return nil
+	}
+
+	return &deadLetterer{
+		deadLetterConfig: *cfg,
+		topic:            topic,
+		attempts:         cache.NewLRUCache(maxTrackedAttempts),
+		now:              time.Now,
+	}
+}
+
+// fail records a failed attempt on the message identified by
+// key and dead-letters it once attempts reach the maximum.
+// delivered is the backend's own count of deliveries, 0 when
+// unknown. [Permanent] errors are dead-lettered right away. It
+// reports whether the message was dead-lettered, in which case
+// the consumer should acknowledge it.
+//
+// Counts are kept in memory, so a message redelivered to
+// another consumer instance starts counting afresh.
+func (d *deadLetterer) fail(ctx context.Context, key string, delivered int, data []byte, cause error) bool {
+	attempts := 1
+	if v, ok := d.attempts.Get(key); ok {
+		attempts += v.(int)
+	}
+	attempts = max(attempts, delivered)
+	d.attempts.Put(key, attempts)
+
+	if attempts < d.maxAttempts && !errors.As(cause, &permanentError{}) {
+		return false
+	}
+
+	payload, err := json.Marshal(DeadLetter{
+		Topic:    d.topic,
+		Attempts: attempts,
+		Reason:   cause.Error(),
+		FailedAt: d.now().UTC(),
+		Data:     data,
+	})
+	if err != nil {
+		log.Printf("bus: failed to encode dead letter for %s: %v", key, err)
+		return false
+	}
+
+	if err := d.producer.Publish(ctx, payload); err != nil {
+		log.Printf("bus: failed to dead-letter %s, will retry: %v", key, err)
+		return false
+	}
+
+	d.attempts.Put(key, 0)
+	log.Printf("bus: dead-lettered %s from %s after %d attempts: %v", key, d.topic, attempts, cause)
+
+	return true
+}
@@ -0,0 +1,171 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	iggcon "github.com/apache/iggy/foreign/go/contracts"
+)
+
+func TestNewDeadLetterer(t *testing.T) {
+	if d := newDeadLetterer(nil, "test-topic"); d != nil {
+		t.Error("newDeadLetterer(nil) != nil, want nil")
+	}
+	if d := newDeadLetterer(&deadLetterConfig{producer: newTestProducer(&mockIggyClient{}), maxAttempts: 0}, "test-topic"); d != nil {
+		t.Error("newDeadLetterer with 0 attempts != nil, want nil")
+	}
+}
+
+func TestDeadLettererFail(t *testing.T) {
+	ctx := context.Background()
+	failedAt := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	cause := errors.New("boom")
+
+	newTest := func(mock *mockIggyClient) *deadLetterer {
+		d := newDeadLetterer(&deadLetterConfig{producer: newTestProducer(mock), maxAttempts: 3}, "test-topic")
+		d.now = func() time.Time { return failedAt }
+		return d
+	}
+
+	t.Run("dead_letters_after_max_attempts", func(t *testing.T) {
+		mock := &mockIggyClient{}
+		d := newTest(mock)
+
+		for i := range 2 {
+			if d.fail(ctx, "msg", 0, []byte("payload"), cause) {
+				t.Fatalf("attempt %d dead-lettered, want retried", i+1)
+			}
+		}
+		if !d.fail(ctx, "msg", 0, []byte("payload"), cause) {
+			t.Fatal("attempt 3 retried, want dead-lettered")
+		}
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		if len(mock.sentMessages) != 1 {
+			t.Fatalf("sentMessages = %d, want 1", len(mock.sentMessages))
+		}
+
+		dl, err := DecodeDeadLetter(mock.sentMessages[0].Messages[0].Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dl.Topic != "test-topic" || dl.Attempts != 3 || dl.Reason != "boom" || !dl.FailedAt.Equal(failedAt) || string(dl.Data) != "payload" {
+			t.Errorf("dead letter = %+v, want test-topic, 3 attempts, boom, %s, payload", dl, failedAt)
+		}
+	})
+
+	t.Run("backend_delivery_count_wins", func(t *testing.T) {
+		d := newTest(&mockIggyClient{})
+
+		if !d.fail(ctx, "msg", 5, []byte("payload"), cause) {
+			t.Error("message delivered 5 times retried, want dead-lettered")
+		}
+	})
+
+	t.Run("permanent_errors_skip_retries", func(t *testing.T) {
+		mock := &mockIggyClient{}
+		d := newTest(mock)
+
+		if !d.fail(ctx, "msg", 0, []byte("payload"), fmt.Errorf("decode: %w", Permanent(cause))) {
+			t.Fatal("permanent error retried, want dead-lettered")
+		}
+
+		mock.mu.Lock()
+		defer mock.mu.Unlock()
+		dl, err := DecodeDeadLetter(mock.sentMessages[0].Messages[0].Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dl.Attempts != 1 || dl.Reason != "decode: boom" {
+			t.Errorf("dead letter = %+v, want 1 attempt and the wrapped reason", dl)
+		}
+	})
+
+	t.Run("messages_are_counted_apart", func(t *testing.T) {
+		d := newTest(&mockIggyClient{})
+
+		for _, key := range []string{"a", "b", "a", "b"} {
+			if d.fail(ctx, key, 0, nil, cause) {
+				t.Fatalf("%s dead-lettered after 2 attempts, want retried", key)
+			}
+		}
+	})
+
+	t.Run("publish_failure_retries", func(t *testing.T) {
+		mock := &mockIggyClient{sendErr: errors.New("network failure")}
+		d := newTest(mock)
+
+		if d.fail(ctx, "msg", 3, []byte("payload"), cause) {
+			t.Fatal("dead-lettered despite publish failure, want retried")
+		}
+
+		mock.sendErr = nil
+		if !d.fail(ctx, "msg", 0, []byte("payload"), cause) {
+			t.Error("retried after publish recovered, want dead-lettered")
+		}
+	})
+}
+
+func TestIggyConsumerListenDeadLetter(t *testing.T) {
+	var polls atomic.Int32
+	cancel2 := context.CancelFunc(nil)
+
+	mock := &mockIggyClient{}
+	mock.pollFn = func(_ iggcon.Identifier, _ iggcon.Identifier, _ iggcon.Consumer, _ iggcon.PollingStrategy, _ uint32, _ bool, _ *uint32) (*iggcon.PolledMessage, error) {
+		// redeliver the poison message until it's committed
+		if int(polls.Add(1)) <= 3 {
+			return &iggcon.PolledMessage{
+				PartitionId: 2,
+				Messages: []iggcon.IggyMessage{
+					{Header: iggcon.MessageHeader{Offset: 10}, Payload: []byte("poison")},
+				},
+			}, nil
+		}
+		cancel2()
+		return nil, nil
+	}
+
+	dlq := &mockIggyClient{}
+	c := newTestConsumer(mock, iggcon.ConsumerKindSingle)
+	c.deadLetter = newDeadLetterer(&deadLetterConfig{producer: newTestProducer(dlq), maxAttempts: 3}, "test-topic")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel2 = cancel
+
+	var handlerCalls int
+	err := c.Listen(ctx, func(_ context.Context, _ []byte) error {
+		handlerCalls++
+		return errors.New("processing failed")
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if handlerCalls != 3 {
+		t.Errorf("handler called %d times, want 3", handlerCalls)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.offsets) != 1 || mock.offsets[0].Offset != 10 {
+		t.Fatalf("offsets committed = %+v, want offset 10 once", mock.offsets)
+	}
+
+	dlq.mu.Lock()
+	defer dlq.mu.Unlock()
+	if len(dlq.sentMessages) != 1 {
+		t.Fatalf("dead letters = %d, want 1", len(dlq.sentMessages))
+	}
+	dl, err := DecodeDeadLetter(dlq.sentMessages[0].Messages[0].Payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(dl.Data) != "poison" || dl.Reason != "processing failed" {
+		t.Errorf("dead letter = %+v, want poison payload and handler error", dl)
+	}
+}
@@ -1,10 +1,11 @@
 // Package bus provides a backend-agnostic message bus for producing and
-// consuming messages. Two implementations are available:
+// consuming messages. Three implementations are available:
 //
 //   - Google Cloud Pub/Sub (cloud environments)
 //   - Apache Iggy (self-hosted environments)
+//   - Postgres (single-node self-hosted installs and tests)
 //
-// Both backends expose the same [Producer] and [Consumer] interfaces, so
+// All backends expose the same [Producer] and [Consumer] interfaces, so
 // callers can switch between them without changing application logic.
 //
 // # Producing messages
@@ -60,12 +61,55 @@
 //   - Pub/Sub: the message is nacked and redelivered by the server.
 //   - Iggy: the message offset is not committed; the failed message and any
 //     remaining messages in the batch are retried on the next poll.
+//   - Postgres: the message is retried after the retry delay, the poll
+//     interval unless set; the rest of the batch is handled meanwhile.
 //
 // Because Iggy commits offsets only after successful handling, the handler
 // must complete all processing before returning. Dispatching work to a
 // background goroutine and returning nil will cause premature offset commits
 // and potential message loss.
 //
+// # Postgres backend
+//
+// [NewPostgresProducer] and [NewPostgresConsumer] keep messages as rows of
+// the measure.bus_messages table, for installs that would rather not run a
+// broker. Consumers claim messages with SELECT ... FOR UPDATE SKIP LOCKED,
+// hiding them from other consumers for a lease, and delete them once
+// handled. Delivery is at-least-once: a failed message is retried after the
+// retry delay, and one whose consumer died is handed out again once its
+// lease runs out. Messages published with [Producer.PublishOrdered] are
+// delivered one at a time per ordering key.
+//
+// Each topic is a single queue. Its consumers share its messages like the
+// members of one consumer group, and a handled message is gone for all of
+// them. There are no consumer offsets, so a topic can't be consumed by more
+// than one group, each getting every message. Topics that need that need
+// Iggy or Pub/Sub.
+//
+//	p, err := bus.NewPostgresProducer(pool, "ingest-batch")
+//	c, err := bus.NewPostgresConsumer(pool, "ingest-batch",
+//	    bus.WithPostgresBatchSize(10),
+//	    bus.WithPostgresPollInterval(time.Second),
+//	)
+//
+// # Dead-lettering
+//
+// Without further configuration a message the handler keeps failing on is
+// retried forever, and on Iggy it blocks the rest of its partition. Pass
+// [WithPubSubDeadLetter] or [WithIggyDeadLetter] with a producer for a
+// dead-letter topic to give up after a number of attempts:
+//
+//	dlq, err := bus.NewIggyProducer(addr, user, pass, name, bus.DefaultStreamName, "ingest-batch-dead-letter")
+//	c, err := bus.NewIggyGroupConsumer(addr, user, pass, "my-group",
+//	    bus.DefaultStreamName, "ingest-batch",
+//	    bus.WithIggyDeadLetter(dlq, 5),
+//	)
+//
+// A dead-lettered message is published as a JSON [DeadLetter] carrying the
+// original payload and the handler's last error, then acknowledged. Handlers
+// wrap errors retrying can't fix in [Permanent] to dead-letter on the first
+// failure. Read dead letters back with [DecodeDeadLetter].
+//
 // # Error handling and resilience
 //
 // Both backends handle transient errors internally:
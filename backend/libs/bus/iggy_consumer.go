@@ -29,6 +29,8 @@ type iggyConsumer struct {
 	pollInterval time.Duration
 	// pollingStrategy selects how the server determines the next batch of messages.
 	pollingStrategy iggcon.PollingStrategy
+	// deadLetter dead-letters repeatedly failing messages. If nil, they are retried indefinitely.
+	deadLetter *deadLetterer
 }
 
 // newIggyConsumer is the shared builder for both NewIggyConsumer and
@@ -122,6 +124,7 @@ func newIggyConsumer(address, username, password, consumerName, streamName, topi
 		batchSize:       batchSize,
 		pollInterval:    pollInterval,
 		pollingStrategy: pollingStrategy,
+		deadLetter:      newDeadLetterer(cfg.deadLetter, topicName),
 	}, nil
 }
 
@@ -192,8 +195,11 @@ func (c *iggyConsumer) Listen(ctx context.Context, handler func(ctx context.Cont
 		partitionID := polled.PartitionId
 		for _, msg := range polled.Messages {
 			if err := handler(ctx, msg.Payload); err != nil {
-				log.Printf("bus: iggy handler error (offset %d will be retried): %v", msg.Header.Offset, err)
-				break
+				key := fmt.Sprintf("partition %d offset %d", partitionID, msg.Header.Offset)
+				if c.deadLetter == nil || !c.deadLetter.fail(ctx, key, 0, msg.Payload, err) {
+					log.Printf("bus: iggy handler error (offset %d will be retried): %v", msg.Header.Offset, err)
+					break
+				}
 			}
 
 			if err := c.client.StoreConsumerOffset(c.consumer, c.streamID, c.topicID, msg.Header.Offset, &partitionID); err != nil {
@@ -0,0 +1,221 @@
+package bus
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// claimQuery claims up to $2 available messages of topic $1 by
+// hiding them from other consumers for $3 seconds. A message with
+// an ordering key is only available once every earlier message
+// with the same key is consumed.
+const claimQuery = `
+update measure.bus_messages
+set available_at = now() + make_interval(secs => $3), attempts = attempts + 1
+where id in (
+	select m.id
+	from measure.bus_messages m
+	where m.topic = $1
+		and m.available_at <= now()
+		and (m.ordering_key is null or not exists (
+			select 1
+			from measure.bus_messages o
+			where o.topic = m.topic
+				and o.ordering_key = m.ordering_key
+				and o.id < m.id
+		))
+	order by m.id
+	limit $2
+	for update skip locked
+)
+returning id, data, attempts`
+
+type postgresMessage struct {
+	id       int64
+	data     []byte
+	attempts int
+}
+
+type postgresConsumer struct {
+	// pool is the Postgres pool messages are claimed through.
+	pool *pgxpool.Pool
+	// topic is the topic messages are consumed from.
+	topic string
+	// batchSize is the number of messages claimed per poll.
+	batchSize int
+	// pollInterval is the delay between polls when no messages are available
+	// and the initial backoff for poll error retries.
+	pollInterval time.Duration
+	// retryDelay is the delay before a failed message is retried.
+	retryDelay time.Duration
+	// lease is how long a claimed message stays hidden from other consumers.
+	lease time.Duration
+	// deadLetter dead-letters repeatedly failing messages. If nil, they are retried indefinitely.
+	deadLetter *deadLetterer
+}
+
+// NewPostgresConsumer creates a Postgres-backed Consumer for topic. Consumers
+// of a topic share its messages, each message going to one of them, so
+// running more instances spreads the load like an Iggy consumer group. A
+// topic is a single-consumer queue though: a message is deleted once handled,
+// and no other group can consume it after. One not handled within the lease,
+// say because its consumer died, is handed out again. The pool stays owned by
+// the caller; Close does not close it.
+func NewPostgresConsumer(pool *pgxpool.Pool, topic string, opts ...PostgresOption) (Consumer, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("bus: Postgres pool is nil")
+	}
+
+	cfg := &postgresConfig{}
+	for _, o := range opts {
+		o(cfg)
+	}
+
+	batchSize := 10
+	if cfg.batchSize > 0 {
+		batchSize = cfg.batchSize
+	}
+
+	pollInterval := 500 * time.Millisecond
+	if cfg.pollInterval > 0 {
+		pollInterval = cfg.pollInterval
+	}
+
+	lease := 5 * time.Minute
+	if cfg.lease > 0 {
+		lease = cfg.lease
+	}
+
+	retryDelay := pollInterval
+	if cfg.retryDelay > 0 {
+		retryDelay = cfg.retryDelay
+	}
+
+	return &postgresConsumer{
+		pool:         pool,
+		topic:        topic,
+		batchSize:    batchSize,
+		pollInterval: pollInterval,
+		lease:        lease,
+		retryDelay:   retryDelay,
+		deadLetter:   newDeadLetterer(cfg.deadLetter, topic),
+	}, nil
+}
+
+func (c *postgresConsumer) Listen(ctx context.Context, handler func(ctx context.Context, data []byte) error) error {
+	const maxPollRetries = 5
+
+	var pollFailures int
+	backoff := c.pollInterval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		msgs, err := c.claim(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			pollFailures++
+			if pollFailures >= maxPollRetries {
+				return fmt.Errorf("bus: Postgres poll failed after %d consecutive attempts: %w", pollFailures, err)
+			}
+
+			log.Printf("bus: postgres poll error (%d/%d), retrying in %s: %v", pollFailures, maxPollRetries, backoff, err)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+
+			// Exponential backoff, capped at 30s.
+			backoff *= 2
+			if backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+
+		// Reset on successful poll.
+		pollFailures = 0
+		backoff = c.pollInterval
+
+		if len(msgs) == 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.pollInterval):
+			}
+			continue
+		}
+
+		for _, msg := range msgs {
+			if err := handler(ctx, msg.data); err != nil {
+				key := fmt.Sprintf("message %d", msg.id)
+				if c.deadLetter == nil || !c.deadLetter.fail(ctx, key, msg.attempts, msg.data, err) {
+					log.Printf("bus: postgres handler error (message %d will be retried): %v", msg.id, err)
+					c.release(ctx, msg.id)
+					continue
+				}
+			}
+
+			c.ack(ctx, msg.id)
+		}
+	}
+}
+
+// claim claims the next batch of messages, oldest first.
+func (c *postgresConsumer) claim(ctx context.Context) (msgs []postgresMessage, err error) {
+	rows, err := c.pool.Query(ctx, claimQuery, c.topic, c.batchSize, c.lease.Seconds())
+	if err != nil {
+		return
+	}
+
+	msgs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (msg postgresMessage, err error) {
+		err = row.Scan(&msg.id, &msg.data, &msg.attempts)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	slices.SortFunc(msgs, func(a, b postgresMessage) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	return
+}
+
+// ack deletes a handled message. A failed delete only
+// logs; the message is handed out again once its lease
+// runs out.
+func (c *postgresConsumer) ack(ctx context.Context, id int64) {
+	if _, err := c.pool.Exec(ctx, `delete from measure.bus_messages where id = $1`, id); err != nil {
+		log.Printf("bus: postgres ack failed (message %d): %v", id, err)
+	}
+}
+
+// release makes a failed message available again
+// after the retry delay.
+func (c *postgresConsumer) release(ctx context.Context, id int64) {
+	if _, err := c.pool.Exec(ctx, `update measure.bus_messages set available_at = now() + make_interval(secs => $2) where id = $1`, id, c.retryDelay.Seconds()); err != nil {
+		log.Printf("bus: postgres release failed (message %d): %v", id, err)
+	}
+}
+
+func (c *postgresConsumer) Close() error {
+	return nil
+}
@@ -0,0 +1,224 @@
+//go:build integration
+
+package bus
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"backend/testinfra"
+)
+
+// TestPostgresIntegration exercises the Postgres backend
+// against a real, migrated Postgres container.
+func TestPostgresIntegration(t *testing.T) {
+	ctx := context.Background()
+	pool, cleanup := testinfra.SetupPostgres(ctx)
+	defer cleanup()
+
+	opts := []PostgresOption{WithPostgresPollInterval(10 * time.Millisecond)}
+
+	// listen runs the consumer until want messages were handled
+	// successfully, returning the payloads in handling order.
+	listen := func(t *testing.T, c Consumer, want int, handler func(data []byte) error) []string {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+
+		var mu sync.Mutex
+		var handled []string
+		err := c.Listen(ctx, func(_ context.Context, data []byte) error {
+			if err := handler(data); err != nil {
+				return err
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, string(data))
+			if len(handled) == want {
+				cancel()
+			}
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Listen() = %v, want context.Canceled", err)
+		}
+		return handled
+	}
+
+	pending := func(t *testing.T, topic string) (n int) {
+		t.Helper()
+		if err := pool.QueryRow(ctx, `select count(*) from measure.bus_messages where topic = $1`, topic).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	t.Run("messages are delivered in order and deleted", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "orders")
+		for _, m := range []string{"a", "b", "c"} {
+			if err := p.Publish(ctx, []byte(m)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		c, _ := NewPostgresConsumer(pool, "orders", opts...)
+		got := listen(t, c, 3, func([]byte) error { return nil })
+
+		if !slices.Equal(got, []string{"a", "b", "c"}) {
+			t.Errorf("handled = %v, want [a b c]", got)
+		}
+		if n := pending(t, "orders"); n != 0 {
+			t.Errorf("pending = %d, want 0", n)
+		}
+	})
+
+	t.Run("topics are kept apart", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "other")
+		if err := p.Publish(ctx, []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+		q, _ := NewPostgresProducer(pool, "mine")
+		if err := q.Publish(ctx, []byte("y")); err != nil {
+			t.Fatal(err)
+		}
+
+		c, _ := NewPostgresConsumer(pool, "mine", opts...)
+		if got := listen(t, c, 1, func([]byte) error { return nil }); !slices.Equal(got, []string{"y"}) {
+			t.Errorf("handled = %v, want [y]", got)
+		}
+		if n := pending(t, "other"); n != 1 {
+			t.Errorf("other topic pending = %d, want 1", n)
+		}
+	})
+
+	t.Run("failed messages are retried", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "retries")
+		if err := p.Publish(ctx, []byte("flaky")); err != nil {
+			t.Fatal(err)
+		}
+
+		var attempts int
+		c, _ := NewPostgresConsumer(pool, "retries", opts...)
+		listen(t, c, 1, func([]byte) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("not yet")
+			}
+			return nil
+		})
+
+		if attempts != 3 {
+			t.Errorf("attempts = %d, want 3", attempts)
+		}
+	})
+
+	t.Run("failed messages wait out the retry delay", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "delayed")
+		if err := p.Publish(ctx, []byte("waiting")); err != nil {
+			t.Fatal(err)
+		}
+
+		var attempts int
+		c, _ := NewPostgresConsumer(pool, "delayed", append(opts, WithPostgresRetryDelay(time.Hour))...)
+		listenCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		c.Listen(listenCtx, func(context.Context, []byte) error {
+			attempts++
+			return errors.New("not yet")
+		})
+
+		// polls run every 10ms, only the retry delay
+		// keeps the message from being handed out again
+		if attempts != 1 {
+			t.Errorf("attempts = %d, want 1", attempts)
+		}
+
+		var hidden bool
+		if err := pool.QueryRow(ctx, `select available_at > now() + interval '50 minutes' from measure.bus_messages where topic = 'delayed'`).Scan(&hidden); err != nil {
+			t.Fatal(err)
+		}
+		if !hidden {
+			t.Error("message is available again, want it hidden for the retry delay")
+		}
+	})
+
+	t.Run("ordered messages wait for earlier ones", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "ordered")
+		for _, m := range []string{"k1", "k2"} {
+			if err := p.PublishOrdered(ctx, "key", []byte(m)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := p.Publish(ctx, []byte("free")); err != nil {
+			t.Fatal(err)
+		}
+
+		var failedOnce bool
+		c, _ := NewPostgresConsumer(pool, "ordered", opts...)
+		got := listen(t, c, 3, func(data []byte) error {
+			if string(data) == "k1" && !failedOnce {
+				failedOnce = true
+				return errors.New("not yet")
+			}
+			return nil
+		})
+
+		// k2 must not overtake the retried k1, the
+		// unordered message may
+		if i, j := slices.Index(got, "k1"), slices.Index(got, "k2"); i < 0 || j < i {
+			t.Errorf("handled = %v, want k1 before k2", got)
+		}
+	})
+
+	t.Run("expired leases are handed out again", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "leases")
+		if err := p.Publish(ctx, []byte("orphan")); err != nil {
+			t.Fatal(err)
+		}
+
+		// claim the message as a consumer dying right after would
+		dead := &postgresConsumer{pool: pool, topic: "leases", batchSize: 10, lease: 50 * time.Millisecond}
+		if msgs, err := dead.claim(ctx); err != nil || len(msgs) != 1 {
+			t.Fatalf("claim() = %v, %v, want one message", msgs, err)
+		}
+
+		c, _ := NewPostgresConsumer(pool, "leases", opts...)
+		if got := listen(t, c, 1, func([]byte) error { return nil }); !slices.Equal(got, []string{"orphan"}) {
+			t.Errorf("handled = %v, want [orphan]", got)
+		}
+	})
+
+	t.Run("dead letters after max attempts", func(t *testing.T) {
+		p, _ := NewPostgresProducer(pool, "poison")
+		if err := p.Publish(ctx, []byte("poison")); err != nil {
+			t.Fatal(err)
+		}
+		dlq, _ := NewPostgresProducer(pool, "poison-dead-letter")
+
+		c, _ := NewPostgresConsumer(pool, "poison", append(opts, WithPostgresDeadLetter(dlq, 2))...)
+		d, _ := NewPostgresConsumer(pool, "poison-dead-letter", opts...)
+
+		lctx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.Listen(lctx, func(context.Context, []byte) error { return errors.New("boom") })
+		}()
+
+		var dl DeadLetter
+		listen(t, d, 1, func(data []byte) (err error) {
+			dl, err = DecodeDeadLetter(data)
+			return
+		})
+		cancel()
+		<-done
+
+		if string(dl.Data) != "poison" || dl.Attempts != 2 || dl.Reason != "boom" {
+			t.Errorf("dead letter = %+v, want poison after 2 attempts with boom", dl)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type postgresProducer struct {
+	// pool is the Postgres pool messages are inserted through.
+	pool *pgxpool.Pool
+	// topic is the topic messages are published to.
+	topic string
+}
+
+// NewPostgresProducer creates a Postgres-backed Producer publishing to topic.
+// Messages are rows in the measure.bus_messages table, so publishing needs no
+// broker beyond the database the services already use. The pool stays owned
+// by the caller; Close does not close it.
+func NewPostgresProducer(pool *pgxpool.Pool, topic string) (Producer, error) {
+	if pool == nil {
+		return nil, fmt.Errorf("bus: Postgres pool is nil")
+	}
+	return &postgresProducer{pool: pool, topic: topic}, nil
+}
+
+func (p *postgresProducer) Publish(ctx context.Context, data []byte) error {
+	if _, err := p.pool.Exec(ctx, `insert into measure.bus_messages (topic, data) values ($1, $2)`, p.topic, data); err != nil {
+		return fmt.Errorf("bus: postgres publish failed: %w", err)
+	}
+	return nil
+}
+
+// PublishOrdered stores the ordering key with the message; consumers skip a
+// message while an earlier one with the same key is still pending.
+func (p *postgresProducer) PublishOrdered(ctx context.Context, orderingKey string, data []byte) error {
+	if _, err := p.pool.Exec(ctx, `insert into measure.bus_messages (topic, ordering_key, data) values ($1, $2, $3)`, p.topic, orderingKey, data); err != nil {
+		return fmt.Errorf("bus: postgres ordered publish failed: %w", err)
+	}
+	return nil
+}
+
+func (p *postgresProducer) Close() error {
+	return nil
+}
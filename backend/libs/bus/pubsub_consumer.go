@@ -17,6 +17,8 @@ type pubSubConsumer struct {
 	subID string
 	// receiveSettings configures receive behaviour. If nil, the subscriber's defaults are used.
 	receiveSettings *pubsub.ReceiveSettings
+	// deadLetter dead-letters repeatedly failing messages. If nil, they are nacked indefinitely.
+	deadLetter *deadLetterer
 }
 
 // NewPubSubConsumer creates a Pub/Sub-backed Consumer using a pull subscription.
@@ -47,6 +49,7 @@ func NewPubSubConsumer(ctx context.Context, subscription string, opts ...PubSubO
 		client:          client,
 		subID:           subscription,
 		receiveSettings: cfg.receiveSettings,
+		deadLetter:      newDeadLetterer(cfg.deadLetter, subscription),
 	}, nil
 }
 
@@ -58,6 +61,10 @@ func (c *pubSubConsumer) Listen(ctx context.Context, handler func(ctx context.Co
 		}
 		err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
 			if err := handler(ctx, msg.Data); err != nil {
+				if c.deadLetter != nil && c.deadLetter.fail(ctx, msg.ID, deliveryAttempt(msg), msg.Data, err) {
+					msg.Ack()
+					return
+				}
 				msg.Nack()
 			} else {
 				msg.Ack()
@@ -79,6 +86,15 @@ func (c *pubSubConsumer) Listen(ctx context.Context, handler func(ctx context.Co
 	}
 }
 
+// deliveryAttempt is the server's count of the message's
+// deliveries, 0 if the subscription doesn't track it.
+func deliveryAttempt(msg *pubsub.Message) int {
+	if msg.DeliveryAttempt == nil {
+		return 0
+	}
+	return *msg.DeliveryAttempt
+}
+
 func (c *pubSubConsumer) Close() error {
 	return c.client.Close()
 }
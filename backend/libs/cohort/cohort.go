@@ -0,0 +1,207 @@
+// Package cohort groups app installations into cohorts by the
+// day, week or app version they were first seen on & reports
+// how often they return, how sticky the app is & how crashing
+// in the first session relates to churn.
+package cohort
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// Ways to group installations
+// into cohorts.
+const (
+	// GroupDay groups installations by
+	// the utc day first seen on.
+	GroupDay = "day"
+	// GroupWeek groups installations by
+	// the utc week, starting monday, first
+	// seen in.
+	GroupWeek = "week"
+	// GroupVersion groups installations by
+	// the app version first seen on.
+	GroupVersion = "version"
+)
+
+// groups are the valid ways to group
+// installations into cohorts.
+var groups = []string{GroupDay, GroupWeek, GroupVersion}
+
+// ReturnDays are the days after first seen
+// return rates are reported for.
+var ReturnDays = []int{1, 7, 30}
+
+// ChurnDays is the count of days after first
+// seen an installation must return within to
+// not have churned.
+const ChurnDays = 7
+
+// StickinessDays is the count of trailing days
+// monthly active installations are counted
+// over.
+const StickinessDays = 30
+
+// maxRange is the longest time range
+// cohorts can be queried for.
+const maxRange = 366 * 24 * time.Hour
+
+// Query selects the installations first
+// seen in a time range.
+type Query struct {
+	From time.Time
+	To   time.Time
+	// GroupBy is how installations
+	// are grouped into cohorts.
+	GroupBy string
+}
+
+// Validate validates the query.
+func (q Query) Validate() error {
+	if !slices.Contains(groups, q.GroupBy) {
+		return fmt.Errorf("group by %q is not one of %v", q.GroupBy, groups)
+	}
+	if !q.From.Before(q.To) {
+		return errors.New("`from` must be earlier than `to`")
+	}
+	if q.To.Sub(q.From) > maxRange {
+		return fmt.Errorf("time range cannot exceed %d days", int(maxRange.Hours()/24))
+	}
+	return nil
+}
+
+// Cohort is a group of installations
+// & how they returned.
+type Cohort struct {
+	// Start is the first day of the cohort,
+	// for day & week cohorts.
+	Start string `json:"start,omitempty"`
+	// Version & VersionCode are the app version
+	// of the cohort, for version cohorts.
+	Version     string `json:"version,omitempty"`
+	VersionCode string `json:"version_code,omitempty"`
+	// Installations is the count of
+	// installations in the cohort.
+	Installations uint64 `json:"installations"`
+	// Returns are the return rates
+	// after each of the ReturnDays.
+	Returns []Return `json:"returns"`
+	// Crashed & Clean are how installations
+	// whose first session crashed, or didn't,
+	// churned.
+	Crashed Churn `json:"crashed"`
+	Clean   Churn `json:"clean"`
+}
+
+// Return is how many installations of a
+// cohort were active a day after first
+// seen.
+type Return struct {
+	// Day is the count of days
+	// after first seen.
+	Day int `json:"day"`
+	// Eligible is the count of installations
+	// first seen at least Day days ago.
+	Eligible uint64 `json:"eligible"`
+	// Returned is the count of eligible
+	// installations active on the day.
+	Returned uint64 `json:"returned"`
+	// Rate is the percentage of eligible
+	// installations that returned.
+	Rate float64 `json:"rate"`
+}
+
+// Churn is how many installations of a
+// cohort never returned within ChurnDays
+// of first seen.
+type Churn struct {
+	// Installations is the count
+	// of installations.
+	Installations uint64 `json:"installations"`
+	// Eligible is the count of installations
+	// first seen at least ChurnDays ago.
+	Eligible uint64 `json:"eligible"`
+	// Churned is the count of eligible
+	// installations that never returned.
+	Churned uint64 `json:"churned"`
+	// Rate is the percentage of eligible
+	// installations that churned.
+	Rate float64 `json:"rate"`
+}
+
+// Stickiness is how many of the installations
+// active over StickinessDays were active on a
+// day.
+type Stickiness struct {
+	// Day is the utc day.
+	Day string `json:"day"`
+	// DAU is the count of installations
+	// active on the day.
+	DAU uint64 `json:"dau"`
+	// MAU is the count of installations active
+	// over StickinessDays up to the day.
+	MAU uint64 `json:"mau"`
+	// Stickiness is DAU as a
+	// percentage of MAU.
+	Stickiness float64 `json:"stickiness"`
+}
+
+// counts are the counts of installations
+// of a cohort, as queried.
+type counts struct {
+	start, version, versionCode string
+	installations               uint64
+	eligible, returned          []uint64
+
+	crashed, crashedEligible, crashedChurned uint64
+	cleanEligible, cleanChurned              uint64
+}
+
+// cohort computes the cohort's rates
+// from its counts.
+func (c counts) cohort() Cohort {
+	cohort := Cohort{
+		Start:         c.start,
+		Version:       c.version,
+		VersionCode:   c.versionCode,
+		Installations: c.installations,
+		Returns:       make([]Return, len(ReturnDays)),
+		Crashed: Churn{
+			Installations: c.crashed,
+			Eligible:      c.crashedEligible,
+			Churned:       c.crashedChurned,
+			Rate:          percent(c.crashedChurned, c.crashedEligible),
+		},
+		Clean: Churn{
+			Installations: c.installations - c.crashed,
+			Eligible:      c.cleanEligible,
+			Churned:       c.cleanChurned,
+			Rate:          percent(c.cleanChurned, c.cleanEligible),
+		},
+	}
+
+	for i, day := range ReturnDays {
+		r := Return{Day: day}
+		if i < len(c.eligible) && i < len(c.returned) {
+			r.Eligible = c.eligible[i]
+			r.Returned = c.returned[i]
+			r.Rate = percent(r.Returned, r.Eligible)
+		}
+		cohort.Returns[i] = r
+	}
+
+	return cohort
+}
+
+// percent computes the percentage of part in
+// whole, rounded to 2 decimals, zero for an
+// empty whole.
+func percent(part, whole uint64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(whole)*100*100) / 100
+}
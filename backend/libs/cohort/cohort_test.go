@@ -0,0 +1,75 @@
+package cohort
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	to := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -30)
+
+	for _, groupBy := range groups {
+		if err := (Query{From: from, To: to, GroupBy: groupBy}).Validate(); err != nil {
+			t.Errorf("Expected valid query for %q, got %v", groupBy, err)
+		}
+	}
+
+	invalid := map[string]Query{
+		"unknown group":  {From: from, To: to, GroupBy: "month"},
+		"reversed range": {From: to, To: from, GroupBy: GroupDay},
+		"empty range":    {From: to, To: to, GroupBy: GroupDay},
+		"long range":     {From: to.AddDate(-2, 0, 0), To: to, GroupBy: GroupWeek},
+	}
+
+	for name, q := range invalid {
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestCountsCohort(t *testing.T) {
+	c := counts{
+		start:           "2026-09-01",
+		installations:   200,
+		eligible:        []uint64{200, 150, 0},
+		returned:        []uint64{80, 30, 0},
+		crashed:         20,
+		crashedEligible: 15,
+		crashedChurned:  12,
+		cleanEligible:   135,
+		cleanChurned:    45,
+	}
+
+	expected := Cohort{
+		Start:         "2026-09-01",
+		Installations: 200,
+		Returns: []Return{
+			{Day: 1, Eligible: 200, Returned: 80, Rate: 40},
+			{Day: 7, Eligible: 150, Returned: 30, Rate: 20},
+			{Day: 30},
+		},
+		Crashed: Churn{Installations: 20, Eligible: 15, Churned: 12, Rate: 80},
+		Clean:   Churn{Installations: 180, Eligible: 135, Churned: 45, Rate: 33.33},
+	}
+
+	if cohort := c.cohort(); !reflect.DeepEqual(expected, cohort) {
+		t.Errorf("Expected %+v, got %+v", expected, cohort)
+	}
+}
+
+func TestCohortExpr(t *testing.T) {
+	expected := map[string]string{
+		GroupDay:     "toString(first_day) as start, '' as version, '' as version_code",
+		GroupWeek:    "toString(toMonday(first_day)) as start, '' as version, '' as version_code",
+		GroupVersion: "'' as start, toString(first_app_version.1) as version, toString(first_app_version.2) as version_code",
+	}
+
+	for groupBy, expr := range expected {
+		if got := cohortExpr(groupBy); got != expr {
+			t.Errorf("%s: expected %q, got %q", groupBy, expr, got)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package cohort
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"backend/libs/chquery"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// dateFormat is the format of
+// cohort & stickiness days.
+const dateFormat = "2006-01-02"
+
+// GetCohorts groups the installations first seen in the
+// query's time range into cohorts, earliest first, or
+// largest first for version cohorts.
+func GetCohorts(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, q Query) (cohorts []Cohort, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+	today := time.Now().UTC()
+
+	// sessions that crashed, of those that could
+	// be the first session of an installation
+	crashedSessions := sqlf.From("sessions").
+		Select("session_id").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("first_event_timestamp >= ?", q.From).
+		GroupBy("session_id").
+		Having("sum(fatal_exception_count) > 0")
+
+	defer crashedSessions.Close()
+
+	// days past the last day installations could be first
+	// seen on that return & churn rates still look at
+	lastDay := ChurnDays
+	for _, day := range ReturnDays {
+		lastDay = max(lastDay, day)
+	}
+
+	// installations active before the time range, so
+	// aren't first seen in it
+	seenBefore := sqlf.From("installation_activity").
+		Select("distinct installation_id").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("day < toDate(?)", q.From)
+
+	installs := sqlf.From("installation_activity").
+		Select("installation_id").
+		Select("min(first_event_timestamp) as first_seen").
+		Select("toDate(first_seen) as first_day").
+		Select("argMinMerge(first_app_version) as first_app_version").
+		Select("groupArray(day) as days").
+		Select(fmt.Sprintf("argMinMerge(first_session_id) in (%s) as crashed", crashedSessions.String()), crashedSessions.Args()...).
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where(fmt.Sprintf("day >= toDate(?) and day <= toDate(?) + %d", lastDay), q.From, q.To).
+		SubQuery("installation_id not in (", ")", seenBefore).
+		GroupBy("installation_id").
+		Having("first_seen >= ? and first_seen <= ?", q.From, q.To)
+
+	defer installs.Close()
+
+	var eligible, returned []string
+	var eligibleArgs, returnedArgs []any
+	for _, day := range ReturnDays {
+		eligible = append(eligible, fmt.Sprintf("countIf(first_day + %d <= toDate(?))", day))
+		eligibleArgs = append(eligibleArgs, today)
+		returned = append(returned, fmt.Sprintf("countIf(first_day + %[1]d <= toDate(?) and has(days, first_day + %[1]d))", day))
+		returnedArgs = append(returnedArgs, today)
+	}
+
+	churnEligible := fmt.Sprintf("first_day + %d <= toDate(?)", ChurnDays)
+	churned := fmt.Sprintf("not arrayExists(d -> d > first_day and d <= first_day + %d, days)", ChurnDays)
+
+	stmt := sqlf.New(fmt.Sprintf("with installs as (%s) select", installs.String()), installs.Args()...).
+		Select(cohortExpr(q.GroupBy)).
+		Select("count() as installations").
+		Select("["+strings.Join(eligible, ", ")+"]", eligibleArgs...).
+		Select("["+strings.Join(returned, ", ")+"]", returnedArgs...).
+		Select("countIf(crashed)").
+		Select("countIf(crashed and "+churnEligible+")", today).
+		Select("countIf(crashed and "+churnEligible+" and "+churned+")", today).
+		Select("countIf(not crashed and "+churnEligible+")", today).
+		Select("countIf(not crashed and "+churnEligible+" and "+churned+")", today).
+		From("installs").
+		GroupBy("start, version, version_code").
+		OrderBy("start, installations desc")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c counts
+		if err = rows.Scan(
+			&c.start,
+			&c.version,
+			&c.versionCode,
+			&c.installations,
+			&c.eligible,
+			&c.returned,
+			&c.crashed,
+			&c.crashedEligible,
+			&c.crashedChurned,
+			&c.cleanEligible,
+			&c.cleanChurned,
+		); err != nil {
+			return
+		}
+
+		cohorts = append(cohorts, c.cohort())
+	}
+
+	err = rows.Err()
+	return
+}
+
+// GetStickiness computes the daily & monthly active
+// installations of each day in the time range.
+func GetStickiness(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, from, to time.Time) (stickiness []Stickiness, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	// each active day counts towards
+	// the trailing window of the days
+	// after it
+	stmt := sqlf.From("installation_activity").
+		Select(fmt.Sprintf("day + arrayJoin(range(%d)) as window_day", StickinessDays)).
+		Select("uniqExactIf(installation_id, window_day = day)").
+		Select("uniqExact(installation_id)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("day >= toDate(?) and day <= toDate(?)", from.AddDate(0, 0, -(StickinessDays-1)), to).
+		GroupBy("window_day").
+		Having("window_day >= toDate(?) and window_day <= toDate(?)", from, to).
+		OrderBy("window_day")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var day time.Time
+		var s Stickiness
+		if err = rows.Scan(&day, &s.DAU, &s.MAU); err != nil {
+			return
+		}
+
+		s.Day = day.Format(dateFormat)
+		s.Stickiness = percent(s.DAU, s.MAU)
+		stickiness = append(stickiness, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// cohortExpr builds the SQL expressions of the
+// start & version of an installation's cohort.
+func cohortExpr(groupBy string) string {
+	switch groupBy {
+	case GroupWeek:
+		return "toString(toMonday(first_day)) as start, '' as version, '' as version_code"
+	case GroupVersion:
+		return "'' as start, toString(first_app_version.1) as version, toString(first_app_version.2) as version_code"
+	}
+	return "toString(first_day) as start, '' as version, '' as version_code"
+}
@@ -0,0 +1,188 @@
+// Package enduser looks up what happened to one end user of an
+// app: the devices and app versions they used, their sessions,
+// crashes, ANRs and bug reports. An end user is identified by the
+// user id the app sets on the SDK, or by the installation id the
+// SDK generates when the app sets none.
+package enduser
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/libs/event"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// DefaultLookback is how far back an end user
+// is looked up when no time range is asked for.
+const DefaultLookback = 30 * 24 * time.Hour
+
+// MaxLookback is the longest time range
+// an end user is looked up over.
+const MaxLookback = 3 * DefaultLookback
+
+// MaxSessions is the maximum number of sessions
+// listed for an end user.
+const MaxSessions = 100
+
+// MaxBugReports is the maximum number of bug
+// reports listed for an end user.
+const MaxBugReports = 50
+
+// MaxErrorGroups is the maximum number of error
+// groups listed for an end user.
+const MaxErrorGroups = 50
+
+// Lookup identifies the end user to look up.
+// Exactly one of the ids is set.
+type Lookup struct {
+	UserID         string
+	InstallationID uuid.UUID
+}
+
+// Validate validates the lookup.
+func (l Lookup) Validate() error {
+	hasUser := l.UserID != ""
+	hasInstallation := l.InstallationID != uuid.Nil
+
+	if hasUser == hasInstallation {
+		return errors.New("exactly one of `user_id` or `installation_id` is required")
+	}
+
+	return nil
+}
+
+// ValidateRange validates the time range
+// an end user is looked up over.
+func ValidateRange(from, to time.Time) error {
+	if !from.Before(to) {
+		return errors.New("`from` must be earlier than `to`")
+	}
+
+	if to.Sub(from) > MaxLookback {
+		return fmt.Errorf("`from` and `to` must be at most %d days apart", MaxLookback/(24*time.Hour))
+	}
+
+	return nil
+}
+
+// String describes the lookup, like
+// "user_id u-123".
+func (l Lookup) String() string {
+	if l.UserID != "" {
+		return fmt.Sprintf("user_id %s", l.UserID)
+	}
+	return fmt.Sprintf("installation_id %s", l.InstallationID)
+}
+
+// match adds the lookup's condition to a
+// statement on the events table.
+func (l Lookup) match(stmt *sqlf.Stmt) {
+	if l.UserID != "" {
+		stmt.Where("attribute.user_id = ?", l.UserID)
+		return
+	}
+	stmt.Where("attribute.installation_id = toUUID(?)", l.InstallationID)
+}
+
+// Profile is an end user's activity in an app over a time
+// range. Seen times and counts are nil or zero when the end
+// user has no events in the range.
+type Profile struct {
+	// UserIDs are the user ids the end user's events
+	// carry. An installation lookup may find several
+	// when users sign in and out of one installation.
+	UserIDs []string `json:"user_ids"`
+
+	// InstallationIDs are the installations the end
+	// user's events come from.
+	InstallationIDs []uuid.UUID `json:"installation_ids"`
+
+	FirstSeen      *time.Time `json:"first_seen"`
+	LastSeen       *time.Time `json:"last_seen"`
+	SessionCount   uint64     `json:"session_count"`
+	CrashCount     uint64     `json:"crash_count"`
+	ANRCount       uint64     `json:"anr_count"`
+	BugReportCount uint64     `json:"bug_report_count"`
+
+	Devices    []Device     `json:"devices"`
+	Versions   []AppVersion `json:"app_versions"`
+	Sessions   []Session    `json:"sessions"`
+	Errors     []ErrorGroup `json:"errors"`
+	BugReports []BugReport  `json:"bug_reports"`
+}
+
+// Found returns true if the end user
+// has any events in the time range.
+func (p Profile) Found() bool {
+	return p.FirstSeen != nil
+}
+
+// Device is an installation of the app on
+// one of the end user's devices.
+type Device struct {
+	InstallationID uuid.UUID `json:"installation_id"`
+	Manufacturer   string    `json:"manufacturer"`
+	Model          string    `json:"model"`
+	Name           string    `json:"name"`
+	OSName         string    `json:"os_name"`
+	OSVersion      string    `json:"os_version"`
+	FirstSeen      time.Time `json:"first_seen"`
+	LastSeen       time.Time `json:"last_seen"`
+}
+
+// AppVersion is an app version the end
+// user ran.
+type AppVersion struct {
+	Version      string    `json:"version"`
+	VersionCode  string    `json:"version_code"`
+	SessionCount uint64    `json:"session_count"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Session is one of the end user's sessions,
+// most recent first.
+type Session struct {
+	SessionID      uuid.UUID `json:"session_id"`
+	InstallationID uuid.UUID `json:"installation_id"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	Version        string    `json:"version"`
+	VersionCode    string    `json:"version_code"`
+	OSName         string    `json:"os_name"`
+	OSVersion      string    `json:"os_version"`
+	DeviceModel    string    `json:"device_model"`
+	EventCount     uint64    `json:"event_count"`
+	CrashCount     uint64    `json:"crash_count"`
+	ANRCount       uint64    `json:"anr_count"`
+}
+
+// ErrorGroup is an error group the end user ran into,
+// with the occurrences of the end user only.
+type ErrorGroup struct {
+	ID           string         `json:"id"`
+	Type         string         `json:"type"`
+	ErrorType    string         `json:"error_type"`
+	Severity     event.Severity `json:"severity"`
+	Message      string         `json:"message"`
+	Count        uint64         `json:"count"`
+	SessionCount uint64         `json:"session_count"`
+	FirstSeen    time.Time      `json:"first_seen"`
+	LastSeen     time.Time      `json:"last_seen"`
+}
+
+// BugReport is a bug report the end user
+// filed, most recent first.
+type BugReport struct {
+	EventID     uuid.UUID `json:"event_id"`
+	SessionID   uuid.UUID `json:"session_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	Status      uint8     `json:"status"`
+	Description string    `json:"description"`
+	Version     string    `json:"version"`
+	VersionCode string    `json:"version_code"`
+}
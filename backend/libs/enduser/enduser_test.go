@@ -0,0 +1,99 @@
+package enduser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+func TestLookupValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		lookup  Lookup
+		wantErr bool
+	}{
+		{name: "a user id", lookup: Lookup{UserID: "u-1"}},
+		{name: "an installation id", lookup: Lookup{InstallationID: uuid.New()}},
+		{name: "no id", lookup: Lookup{}, wantErr: true},
+		{name: "both ids", lookup: Lookup{UserID: "u-1", InstallationID: uuid.New()}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.lookup.Validate()
+			if test.wantErr && err == nil {
+				t.Error("want an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("want no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateRange(t *testing.T) {
+	to := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		from    time.Time
+		wantErr bool
+	}{
+		{name: "default lookback", from: to.Add(-DefaultLookback)},
+		{name: "max lookback", from: to.Add(-MaxLookback)},
+		{name: "over max lookback", from: to.Add(-MaxLookback - time.Millisecond), wantErr: true},
+		{name: "from after to", from: to.Add(time.Hour), wantErr: true},
+		{name: "empty range", from: to, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateRange(test.from, to)
+			if test.wantErr && err == nil {
+				t.Error("want an error, got nil")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("want no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestLookupMatch(t *testing.T) {
+	installationID := uuid.New()
+
+	tests := []struct {
+		lookup   Lookup
+		want     string
+		wantArgs []any
+	}{
+		{
+			lookup:   Lookup{UserID: "u-1"},
+			want:     "SELECT id FROM events WHERE attribute.user_id = ?",
+			wantArgs: []any{"u-1"},
+		},
+		{
+			lookup:   Lookup{InstallationID: installationID},
+			want:     "SELECT id FROM events WHERE attribute.installation_id = toUUID(?)",
+			wantArgs: []any{installationID},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.lookup.String(), func(t *testing.T) {
+			stmt := sqlf.From("events").Select("id")
+			defer stmt.Close()
+
+			test.lookup.match(stmt)
+
+			if got := stmt.String(); got != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+			if args := stmt.Args(); len(args) != 1 || args[0] != test.wantArgs[0] {
+				t.Errorf("want args %v, got %v", test.wantArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package enduser
+
+import (
+	"context"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+	"backend/libs/event"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// crashExpr matches crash events.
+const crashExpr = "type = 'exception' and " + config.FatalExceptionExpr
+
+// severityExpr is the severity of an error event, with legacy
+// exceptions without a severity mapped from their handled flag.
+const severityExpr = "if(type = 'anr', 'fatal', if(`exception.severity` != '', `exception.severity`, if(`exception.handled`, 'handled', 'fatal')))"
+
+// GetProfile looks up the end user's activity in
+// the app over [from, to].
+func GetProfile(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, lookup Lookup, from, to time.Time) (profile Profile, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	q := query{teamID: teamID, appID: appID, lookup: lookup, from: from, to: to}
+
+	if err = q.summary(ctx, rch, &profile); err != nil {
+		return
+	}
+
+	profile.Devices = []Device{}
+	profile.Versions = []AppVersion{}
+	profile.Sessions = []Session{}
+	profile.Errors = []ErrorGroup{}
+	profile.BugReports = []BugReport{}
+
+	if !profile.Found() {
+		return
+	}
+
+	if profile.Devices, err = q.devices(ctx, rch); err != nil {
+		return
+	}
+
+	if profile.Versions, err = q.versions(ctx, rch); err != nil {
+		return
+	}
+
+	if profile.Sessions, err = q.sessions(ctx, rch); err != nil {
+		return
+	}
+
+	if profile.Errors, err = q.errorGroups(ctx, rch); err != nil {
+		return
+	}
+
+	profile.BugReports, err = q.bugReports(ctx, rch)
+
+	return
+}
+
+// query scopes the queries of a
+// profile lookup.
+type query struct {
+	teamID uuid.UUID
+	appID  uuid.UUID
+	lookup Lookup
+	from   time.Time
+	to     time.Time
+}
+
+// events starts a statement on the end
+// user's events in the time range.
+func (q query) events() *sqlf.Stmt {
+	stmt := sqlf.
+		From(config.EventsTable).
+		Where("team_id = toUUID(?)", q.teamID).
+		Where("app_id = toUUID(?)", q.appID).
+		Where("timestamp >= toDateTime64(?, 3, 'UTC')", q.from).
+		Where("timestamp <= toDateTime64(?, 3, 'UTC')", q.to)
+
+	q.lookup.match(stmt)
+
+	return stmt
+}
+
+func (q query) summary(ctx context.Context, rch driver.Conn, profile *Profile) (err error) {
+	stmt := q.events().
+		Select("count()").
+		Select("groupUniqArrayIf(attribute.user_id, attribute.user_id != '')").
+		Select("groupUniqArray(attribute.installation_id)").
+		Select("min(timestamp)").
+		Select("max(timestamp)").
+		Select("uniqExact(session_id)").
+		Select("countIf("+crashExpr+")").
+		Select("countIf(type = ?)", event.TypeANR).
+		Select("countIf(type = ?)", event.TypeBugReport)
+
+	defer stmt.Close()
+
+	var count uint64
+	var firstSeen, lastSeen time.Time
+
+	if err = rch.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&count,
+		&profile.UserIDs,
+		&profile.InstallationIDs,
+		&firstSeen,
+		&lastSeen,
+		&profile.SessionCount,
+		&profile.CrashCount,
+		&profile.ANRCount,
+		&profile.BugReportCount,
+	); err != nil {
+		return
+	}
+
+	if count > 0 {
+		profile.FirstSeen = &firstSeen
+		profile.LastSeen = &lastSeen
+	}
+
+	if profile.UserIDs == nil {
+		profile.UserIDs = []string{}
+	}
+
+	if profile.InstallationIDs == nil {
+		profile.InstallationIDs = []uuid.UUID{}
+	}
+
+	return
+}
+
+func (q query) devices(ctx context.Context, rch driver.Conn) (devices []Device, err error) {
+	stmt := q.events().
+		Select("attribute.installation_id").
+		Select("argMax(attribute.device_manufacturer, timestamp)").
+		Select("argMax(attribute.device_model, timestamp)").
+		Select("argMax(attribute.device_name, timestamp)").
+		Select("argMax(attribute.os_name, timestamp)").
+		Select("argMax(attribute.os_version, timestamp)").
+		Select("min(timestamp)").
+		Select("max(timestamp) as last_seen").
+		GroupBy("attribute.installation_id").
+		OrderBy("last_seen desc")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	devices = []Device{}
+
+	for rows.Next() {
+		var d Device
+		if err = rows.Scan(&d.InstallationID, &d.Manufacturer, &d.Model, &d.Name, &d.OSName, &d.OSVersion, &d.FirstSeen, &d.LastSeen); err != nil {
+			return
+		}
+		devices = append(devices, d)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (q query) versions(ctx context.Context, rch driver.Conn) (versions []AppVersion, err error) {
+	stmt := q.events().
+		Select("attribute.app_version").
+		Select("attribute.app_build").
+		Select("uniqExact(session_id)").
+		Select("min(timestamp)").
+		Select("max(timestamp) as last_seen").
+		GroupBy("attribute.app_version").
+		GroupBy("attribute.app_build").
+		OrderBy("last_seen desc")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	versions = []AppVersion{}
+
+	for rows.Next() {
+		var v AppVersion
+		if err = rows.Scan(&v.Version, &v.VersionCode, &v.SessionCount, &v.FirstSeen, &v.LastSeen); err != nil {
+			return
+		}
+		versions = append(versions, v)
+	}
+
+	err = rows.Err()
+	return
+}
+
+func (q query) sessions(ctx context.Context, rch driver.Conn) (sessions []Session, err error) {
+	stmt := q.events().
+		Select("session_id").
+		Select("any(attribute.installation_id)").
+		Select("min(timestamp) as start_time").
+		Select("max(timestamp)").
+		Select("argMax(attribute.app_version, timestamp)").
+		Select("argMax(attribute.app_build, timestamp)").
+		Select("argMax(attribute.os_name, timestamp)").
+		Select("argMax(attribute.os_version, timestamp)").
+		Select("argMax(attribute.device_model, timestamp)").
+		Select("count()").
+		Select("countIf("+crashExpr+")").
+		Select("countIf(type = ?)", event.TypeANR).
+		GroupBy("session_id").
+		OrderBy("start_time desc").
+		Limit(MaxSessions)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	sessions = []Session{}
+
+	for rows.Next() {
+		var s Session
+		if err = rows.Scan(&s.SessionID, &s.InstallationID, &s.StartTime, &s.EndTime, &s.Version, &s.VersionCode, &s.OSName, &s.OSVersion, &s.DeviceModel, &s.EventCount, &s.CrashCount, &s.ANRCount); err != nil {
+			return
+		}
+		sessions = append(sessions, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// errorGroups fetches the error groups the end user ran
+// into, most recent first, with each group's latest type
+// and message read from its error group table.
+func (q query) errorGroups(ctx context.Context, rch driver.Conn) (groups []ErrorGroup, err error) {
+	stmt := q.events().
+		Select("if(type = 'anr', `anr.fingerprint`, `exception.fingerprint`) as fingerprint").
+		Select("type").
+		Select("argMax("+severityExpr+", timestamp)").
+		Select("count()").
+		Select("uniqExact(session_id)").
+		Select("min(timestamp)").
+		Select("max(timestamp) as last_seen").
+		Where("type in (?, ?)", event.TypeException, event.TypeANR).
+		Where("fingerprint != ''").
+		GroupBy("fingerprint").
+		GroupBy("type").
+		OrderBy("last_seen desc").
+		Limit(MaxErrorGroups)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	groups = []ErrorGroup{}
+	ids := []string{}
+
+	for rows.Next() {
+		var g ErrorGroup
+		var severity string
+		if err = rows.Scan(&g.ID, &g.ErrorType, &severity, &g.Count, &g.SessionCount, &g.FirstSeen, &g.LastSeen); err != nil {
+			return
+		}
+		g.Severity = event.Severity(severity)
+		groups = append(groups, g)
+		ids = append(ids, g.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+
+	details, err := q.errorGroupDetails(ctx, rch, ids)
+	if err != nil {
+		return
+	}
+
+	for i := range groups {
+		if d, ok := details[groups[i].ID]; ok {
+			groups[i].Type = d[0]
+			groups[i].Message = d[1]
+		}
+	}
+
+	return
+}
+
+// errorGroupDetails maps error group ids to the latest
+// exception type and message of the group.
+func (q query) errorGroupDetails(ctx context.Context, rch driver.Conn, ids []string) (details map[string][2]string, err error) {
+	branch := func(table string) *sqlf.Stmt {
+		return sqlf.
+			From(table+" final").
+			Select("id").
+			Select("argMax(type, timestamp)").
+			Select("argMax(message, timestamp)").
+			Where("team_id = toUUID(?)", q.teamID).
+			Where("app_id = toUUID(?)", q.appID).
+			Where("id in ?", ids).
+			GroupBy("id")
+	}
+
+	// the unioned statements are
+	// closed by the union
+	stmt := branch("fatal_exception_groups").
+		Union(true, branch("nonfatal_exception_groups")).
+		Union(true, branch("anr_groups"))
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	details = map[string][2]string{}
+
+	for rows.Next() {
+		var id, errorType, message string
+		if err = rows.Scan(&id, &errorType, &message); err != nil {
+			return
+		}
+		if _, ok := details[id]; !ok {
+			details[id] = [2]string{errorType, message}
+		}
+	}
+
+	err = rows.Err()
+	return
+}
+
+// bugReports fetches the bug reports the end user filed.
+// Bug reports are matched through their events, so that
+// installation lookups find them too.
+func (q query) bugReports(ctx context.Context, rch driver.Conn) (bugReports []BugReport, err error) {
+	// the subquery is closed by
+	// the statement it's added to
+	eventIDs := q.events().
+		Select("id").
+		Where("type = ?", event.TypeBugReport)
+
+	stmt := sqlf.
+		From("bug_reports final").
+		Select("event_id").
+		Select("session_id").
+		Select("timestamp").
+		Select("status").
+		Select("description").
+		Select("tupleElement(app_version, 1)").
+		Select("tupleElement(app_version, 2)").
+		Where("team_id = toUUID(?)", q.teamID).
+		Where("app_id = toUUID(?)", q.appID).
+		Where("timestamp >= ? and timestamp <= ?", q.from, q.to).
+		SubQuery("event_id in (", ")", eventIDs).
+		OrderBy("timestamp desc").
+		Limit(MaxBugReports)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	bugReports = []BugReport{}
+
+	for rows.Next() {
+		var b BugReport
+		if err = rows.Scan(&b.EventID, &b.SessionID, &b.Timestamp, &b.Status, &b.Description, &b.Version, &b.VersionCode); err != nil {
+			return
+		}
+		bugReports = append(bugReports, b)
+	}
+
+	err = rows.Err()
+	return
+}
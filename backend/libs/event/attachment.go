@@ -2,6 +2,7 @@ package event
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
@@ -18,6 +19,7 @@ import (
 	"cloud.google.com/go/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/google/uuid"
 	"google.golang.org/api/googleapi"
 )
@@ -38,6 +40,21 @@ type UploadConfig struct {
 	AttachmentsSecretAccessKey string
 }
 
+// DownloadConfig is the storage configuration Open needs to read an
+// attachment's object.
+type DownloadConfig struct {
+	IsCloud                    bool
+	AWSEndpoint                string
+	AttachmentsBucket          string
+	AttachmentsBucketRegion    string
+	AttachmentsAccessKey       string
+	AttachmentsSecretAccessKey string
+}
+
+// ErrAttachmentNotFound is returned by Open when the attachment's object
+// doesn't exist, like when the SDK hasn't finished uploading it yet.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
 // PreSignConfig is the storage configuration PreSignURL needs to build a
 // presigned (or proxied) attachment URL.
 type PreSignConfig struct {
@@ -82,6 +99,10 @@ const (
 	// attachmentTypeHeapProfile is a sample of native & Java allocations,
 	// carried in a protobuf trace.
 	attachmentTypeHeapProfile = "heap_profile"
+
+	// attachmentTypePprof is a sample of CPU stacks in pprof's gzipped
+	// protobuf format.
+	attachmentTypePprof = "pprof"
 )
 
 // contentTypeBinary is the fallback for opaque or unrecognized bytes.
@@ -96,6 +117,7 @@ var attachmentTypes = []string{
 	attachmentTypePerfettoTrace,
 	attachmentTypeHeapDump,
 	attachmentTypeHeapProfile,
+	attachmentTypePprof,
 }
 
 // isNotFound checks if error is a googleapi
@@ -149,6 +171,18 @@ func (a Attachment) Validate() error {
 	return nil
 }
 
+// IsHeapDump is true if the attachment
+// is an HPROF heap dump.
+func (a Attachment) IsHeapDump() bool {
+	return a.Type == attachmentTypeHeapDump
+}
+
+// IsPerfettoTrace is true if the attachment
+// is a perfetto trace.
+func (a Attachment) IsPerfettoTrace() bool {
+	return a.Type == attachmentTypePerfettoTrace
+}
+
 // gzipMagic prefixes every gzip stream.
 var gzipMagic = []byte{0x1f, 0x8b}
 
@@ -197,6 +231,7 @@ var fixedContentTypes = map[string]string{
 	attachmentTypeHeapDump:           contentTypeBinary,
 	attachmentTypeHeapProfile:        contentTypeBinary,
 	attachmentTypeAndroidMethodTrace: contentTypeBinary,
+	attachmentTypePprof:              contentTypeBinary,
 }
 
 // contentTypeFor resolves the mime type from the attachment type, falling back
@@ -400,3 +435,71 @@ func (a *Attachment) PreSignURL(ctx context.Context, config PreSignConfig) (err
 
 	return
 }
+
+// Open opens the attachment's object for reading. Objects stored gzip
+// encoded are read decoded. The caller must close the returned reader.
+func (a Attachment) Open(ctx context.Context, config DownloadConfig) (rc io.ReadCloser, err error) {
+	if config.IsCloud {
+		client, errStorage := storage.NewClient(ctx)
+		if errStorage != nil {
+			err = errStorage
+			return
+		}
+
+		// gcs transcodes gzip encoded objects
+		// on read, no need to decode them here
+		reader, errReader := client.Bucket(config.AttachmentsBucket).Object(a.Key).NewReader(ctx)
+		if errReader != nil {
+			client.Close()
+			if errors.Is(errReader, storage.ErrObjectNotExist) {
+				err = ErrAttachmentNotFound
+				return
+			}
+			err = errReader
+			return
+		}
+
+		rc = closers{reader, reader, client}
+		return
+	}
+
+	client := objstore.CreateS3Client(ctx, config.AttachmentsAccessKey, config.AttachmentsSecretAccessKey, config.AttachmentsBucketRegion, config.AWSEndpoint)
+
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(config.AttachmentsBucket),
+		Key:    aws.String(a.Key),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			err = ErrAttachmentNotFound
+		}
+		return
+	}
+
+	if aws.ToString(out.ContentEncoding) != "gzip" {
+		rc = out.Body
+		return
+	}
+
+	gzipReader, err := gzip.NewReader(out.Body)
+	if err != nil {
+		out.Body.Close()
+		return
+	}
+
+	rc = closers{gzipReader, gzipReader, out.Body}
+	return
+}
+
+// closers reads from a reader and closes each
+// of the closers in order.
+type closers struct {
+	io.Reader
+	first  io.Closer
+	second io.Closer
+}
+
+func (c closers) Close() error {
+	return errors.Join(c.first.Close(), c.second.Close())
+}
@@ -276,6 +276,13 @@ func TestContentTypeFor(t *testing.T) {
 			head:           []byte{0x0a, 0x00},
 			expected:       "application/octet-stream",
 		},
+		{
+			name:           "pprof",
+			attachmentType: "pprof",
+			filename:       "cpu.pb.gz",
+			head:           []byte{0x1f, 0x8b},
+			expected:       "application/octet-stream",
+		},
 
 		// degraded inputs
 		{
@@ -1956,6 +1956,13 @@ func (e Exception) Stacktrace() string {
 // ComputeFingerprint computes a fingerprint
 // for the exception.
 func (e *Exception) ComputeFingerprint() (err error) {
+	return e.ComputeFingerprintWithRules(nil)
+}
+
+// ComputeFingerprintWithRules computes a fingerprint
+// for the exception, customized by the app's
+// fingerprint rules.
+func (e *Exception) ComputeFingerprintWithRules(rules *FingerprintRules) (err error) {
 	framework := e.GetFramework()
 
 	// don't compute fingerprint for exceptions that contain error
@@ -1977,64 +1984,52 @@ func (e *Exception) ComputeFingerprint() (err error) {
 	// parts of the input
 	sep := ":"
 
+	// exType and exMessage are the type and
+	// message the fingerprint rules match
+	var exType, exMessage string
+
 	switch framework {
 	case FrameworkJVM:
 		// get the innermost exception
 		innermostException := e.Exceptions[len(e.Exceptions)-1]
+		exType, exMessage = innermostException.Type, innermostException.Message
 
 		// initialize fingerprint data with the exception type
 		input = innermostException.Type
 
 		// get the method name and file name from the first frame of the innermost exception
-		if len(innermostException.Frames) > 0 {
-			methodName := innermostException.Frames[0].MethodName
-			fileName := innermostException.Frames[0].FileName
-
-			// Include any non-empty information
-			if methodName != "" {
-				input += sep + methodName
-			}
-			if fileName != "" {
-				input += sep + fileName
-			}
+		if frame, ok := rules.firstFrame(innermostException.Frames); ok {
+			input += frameInput(frame, sep)
 		}
 	case FrameworkApple:
 		// initialize with the exception type
 		input = e.GetType()
+		exType, exMessage = input, e.GetMessage()
 
 		// find the relevant frame - which is
 		// either the first in app frame or the
 		// first frame.
 		frame := e.GetRelevantFrame()
-
-		if frame.MethodName != "" {
-			input += sep + frame.MethodName
-		}
-		if frame.FileName != "" {
-			input += sep + frame.FileName
+		if rules.ignores(frame) {
+			frame = e.getRelevantFrameWithRules(rules)
 		}
+
+		input += frameInput(frame, sep)
 	case FrameworkDart:
 		// get the outermost exception
 		outermostException := e.Exceptions[0]
+		exType, exMessage = outermostException.Type, outermostException.Message
 
 		// initialize fingerprint data with the exception type
 		input = outermostException.Type
 
-		if len(outermostException.Frames) > 0 {
-			methodName := outermostException.Frames[0].MethodName
-			fileName := outermostException.Frames[0].FileName
-
-			// Include any non-empty information
-			if methodName != "" {
-				input += sep + methodName
-			}
-			if fileName != "" {
-				input += sep + fileName
-			}
+		if frame, ok := rules.firstFrame(outermostException.Frames); ok {
+			input += frameInput(frame, sep)
 		}
 	case FrameworkJS:
 		// get the outermost exception
 		outermostException := e.Exceptions[0]
+		exType, exMessage = outermostException.Type, outermostException.Message
 
 		// initialize fingerprint data with the exception type
 		input = outermostException.Type
@@ -2043,22 +2038,17 @@ func (e *Exception) ComputeFingerprint() (err error) {
 			input += sep + outermostException.Message
 		}
 
-		if len(outermostException.Frames) > 0 {
-			methodName := outermostException.Frames[0].MethodName
-			fileName := outermostException.Frames[0].FileName
-
-			// Include any non-empty information
-			if methodName != "" {
-				input += sep + methodName
-			}
-			if fileName != "" {
-				input += sep + fileName
-			}
+		if frame, ok := rules.firstFrame(outermostException.Frames); ok {
+			input += frameInput(frame, sep)
 		}
 	default:
 		return errors.New("failed to compute fingerprint for unknown framework")
 	}
 
+	if override, ok := rules.override(exType, exMessage); ok {
+		input = override
+	}
+
 	// Compute the fingerprint
 	hash := md5.Sum([]byte(input))
 	e.Fingerprint = hex.EncodeToString(hash[:])
@@ -2066,6 +2056,33 @@ func (e *Exception) ComputeFingerprint() (err error) {
 	return
 }
 
+// getRelevantFrameWithRules finds the first in app frame not
+// ignored by the fingerprint rules, falling back to the first
+// frame not ignored.
+func (e Exception) getRelevantFrameWithRules(rules *FingerprintRules) (frame Frame) {
+	var fallback *Frame
+
+	for _, unit := range e.Exceptions {
+		for _, f := range unit.Frames {
+			if rules.ignores(f) {
+				continue
+			}
+			if f.InApp {
+				return f
+			}
+			if fallback == nil {
+				fallback = &f
+			}
+		}
+	}
+
+	if fallback != nil {
+		return *fallback
+	}
+
+	return
+}
+
 // IsNested returns true in case of
 // multiple nested ANRs.
 func (a ANR) IsNested() bool {
@@ -2177,6 +2194,13 @@ func (a ANR) Stacktrace() string {
 // ComputeFingerprint computes a fingerprint
 // from the ANR data.
 func (a *ANR) ComputeFingerprint() (err error) {
+	return a.ComputeFingerprintWithRules(nil)
+}
+
+// ComputeFingerprintWithRules computes a fingerprint
+// from the ANR data, customized by the app's
+// fingerprint rules.
+func (a *ANR) ComputeFingerprintWithRules(rules *FingerprintRules) (err error) {
 	if len(a.Exceptions) == 0 {
 		return fmt.Errorf("error computing ANR fingerprint: no exceptions found")
 	}
@@ -2191,17 +2215,12 @@ func (a *ANR) ComputeFingerprint() (err error) {
 	fingerprintData := exceptionType
 
 	// Get the method name and file name from the first frame of the innermost exception
-	if len(innermostException.Frames) > 0 {
-		methodName := innermostException.Frames[0].MethodName
-		fileName := innermostException.Frames[0].FileName
+	if frame, ok := rules.firstFrame(innermostException.Frames); ok {
+		fingerprintData += frameInput(frame, ":")
+	}
 
-		// Include any non-empty information
-		if methodName != "" {
-			fingerprintData += ":" + methodName
-		}
-		if fileName != "" {
-			fingerprintData += ":" + fileName
-		}
+	if override, ok := rules.override(exceptionType, innermostException.Message); ok {
+		fingerprintData = override
 	}
 
 	// Compute the fingerprint
@@ -0,0 +1,103 @@
+package event
+
+import (
+	"regexp"
+	"strings"
+)
+
+// FingerprintRules customize how fingerprints of
+// exceptions and ANRs are computed.
+//
+// Fold type rules are checked first, then group by
+// message rules. When neither matches, the default
+// recipe applies, skipping frames matched by ignore
+// frame rules.
+type FingerprintRules struct {
+	// IgnoreFrames skips frames whose class and
+	// method or file name match any pattern when
+	// picking the frame to fingerprint by.
+	IgnoreFrames []*regexp.Regexp
+
+	// GroupByMessage groups errors whose message
+	// matches a pattern by their type, the pattern
+	// and its captured submatches, regardless of
+	// frames.
+	GroupByMessage []*regexp.Regexp
+
+	// FoldTypes groups errors whose type matches
+	// a pattern by their type alone.
+	FoldTypes []*regexp.Regexp
+}
+
+// IsEmpty returns true if there are no rules.
+func (r *FingerprintRules) IsEmpty() bool {
+	return r == nil || len(r.IgnoreFrames) == 0 && len(r.GroupByMessage) == 0 && len(r.FoldTypes) == 0
+}
+
+// override provides the fingerprint input replacing the
+// default recipe for an error of the type and message,
+// if a fold type or group by message rule matches.
+func (r *FingerprintRules) override(exType, message string) (input string, ok bool) {
+	if r == nil {
+		return
+	}
+
+	for _, re := range r.FoldTypes {
+		if re.MatchString(exType) {
+			return "fold:" + exType, true
+		}
+	}
+
+	for _, re := range r.GroupByMessage {
+		match := re.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		parts := append([]string{"message", exType, re.String()}, match[1:]...)
+		return strings.Join(parts, ":"), true
+	}
+
+	return
+}
+
+// ignores returns true if the frame
+// matches an ignore frame rule.
+func (r *FingerprintRules) ignores(f Frame) bool {
+	if r == nil {
+		return false
+	}
+
+	for _, re := range r.IgnoreFrames {
+		if codeInfo := f.CodeInfo(); codeInfo != "" && re.MatchString(codeInfo) {
+			return true
+		}
+		if f.FileName != "" && re.MatchString(f.FileName) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// firstFrame provides the first of the
+// frames not ignored by the rules.
+func (r *FingerprintRules) firstFrame(frames Frames) (frame Frame, ok bool) {
+	for _, f := range frames {
+		if !r.ignores(f) {
+			return f, true
+		}
+	}
+	return
+}
+
+// frameInput provides the fingerprint input
+// contributed by the frame's method and file.
+func frameInput(frame Frame, sep string) (input string) {
+	if frame.MethodName != "" {
+		input += sep + frame.MethodName
+	}
+	if frame.FileName != "" {
+		input += sep + frame.FileName
+	}
+	return
+}
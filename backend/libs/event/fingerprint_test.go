@@ -0,0 +1,90 @@
+package event
+
+import (
+	"regexp"
+	"testing"
+)
+
+func newJVMException(exType, message string, frames ...Frame) Exception {
+	return Exception{
+		Framework: FrameworkJVM,
+		Exceptions: ExceptionUnits{
+			{
+				Type:    exType,
+				Message: message,
+				Frames:  frames,
+			},
+		},
+	}
+}
+
+func fingerprintWithRules(t *testing.T, e Exception, rules *FingerprintRules) string {
+	t.Helper()
+	if err := e.ComputeFingerprintWithRules(rules); err != nil {
+		t.Fatalf("Unexpected error computing fingerprint: %v", err)
+	}
+	return e.Fingerprint
+}
+
+func TestComputeFingerprintWithRules(t *testing.T) {
+	wrapper := Frame{ClassName: "kotlinx.coroutines.DispatchedTask", MethodName: "run", FileName: "DispatchedTask.kt"}
+	checkout := Frame{ClassName: "com.example.Checkout", MethodName: "pay", FileName: "Checkout.kt"}
+	cart := Frame{ClassName: "com.example.Cart", MethodName: "add", FileName: "Cart.kt"}
+
+	t.Run("no rules keep the default fingerprint", func(t *testing.T) {
+		e := newJVMException("java.lang.IllegalStateException", "boom", checkout)
+		withRules := fingerprintWithRules(t, e, &FingerprintRules{})
+		if err := e.ComputeFingerprint(); err != nil {
+			t.Fatalf("Unexpected error computing fingerprint: %v", err)
+		}
+		if withRules != e.Fingerprint {
+			t.Errorf("Expected fingerprint %q, but got %q", e.Fingerprint, withRules)
+		}
+	})
+
+	t.Run("ignored frames are skipped", func(t *testing.T) {
+		rules := &FingerprintRules{IgnoreFrames: []*regexp.Regexp{regexp.MustCompile(`^kotlinx\.coroutines\.`)}}
+		wrapped := fingerprintWithRules(t, newJVMException("java.lang.IllegalStateException", "boom", wrapper, checkout), rules)
+		direct := fingerprintWithRules(t, newJVMException("java.lang.IllegalStateException", "boom", checkout), nil)
+		if wrapped != direct {
+			t.Errorf("Expected fingerprint %q, but got %q", direct, wrapped)
+		}
+	})
+
+	t.Run("group by message ignores frames", func(t *testing.T) {
+		rules := &FingerprintRules{GroupByMessage: []*regexp.Regexp{regexp.MustCompile(`^Unable to resolve host "([^"]+)"`)}}
+		a := fingerprintWithRules(t, newJVMException("java.net.UnknownHostException", `Unable to resolve host "api.example.com"`, checkout), rules)
+		b := fingerprintWithRules(t, newJVMException("java.net.UnknownHostException", `Unable to resolve host "api.example.com": No address`, cart), rules)
+		c := fingerprintWithRules(t, newJVMException("java.net.UnknownHostException", `Unable to resolve host "cdn.example.com"`, checkout), rules)
+		if a != b {
+			t.Errorf("Expected same fingerprint for the same host, got %q and %q", a, b)
+		}
+		if a == c {
+			t.Errorf("Expected different fingerprints for different captured hosts, got %q", a)
+		}
+	})
+
+	t.Run("folded types group by type alone", func(t *testing.T) {
+		rules := &FingerprintRules{FoldTypes: []*regexp.Regexp{regexp.MustCompile(`^java\.lang\.OutOfMemoryError$`)}}
+		a := fingerprintWithRules(t, newJVMException("java.lang.OutOfMemoryError", "Failed to allocate", checkout), rules)
+		b := fingerprintWithRules(t, newJVMException("java.lang.OutOfMemoryError", "Java heap space", cart), rules)
+		if a != b {
+			t.Errorf("Expected same fingerprint for folded type, got %q and %q", a, b)
+		}
+	})
+
+	t.Run("ANR ignored frames are skipped", func(t *testing.T) {
+		rules := &FingerprintRules{IgnoreFrames: []*regexp.Regexp{regexp.MustCompile(`DispatchedTask\.kt$`)}}
+		wrapped := ANR{Exceptions: ExceptionUnits{{Type: "AppNotResponding", Frames: Frames{wrapper, checkout}}}}
+		direct := ANR{Exceptions: ExceptionUnits{{Type: "AppNotResponding", Frames: Frames{checkout}}}}
+		if err := wrapped.ComputeFingerprintWithRules(rules); err != nil {
+			t.Fatalf("Unexpected error computing ANR fingerprint: %v", err)
+		}
+		if err := direct.ComputeFingerprint(); err != nil {
+			t.Fatalf("Unexpected error computing ANR fingerprint: %v", err)
+		}
+		if wrapped.Fingerprint != direct.Fingerprint {
+			t.Errorf("Expected fingerprint %q, but got %q", direct.Fingerprint, wrapped.Fingerprint)
+		}
+	})
+}
@@ -3,7 +3,6 @@ package exprfilter
 import (
 	"context"
 	"fmt"
-	"strings"
 
 	"backend/libs/symbol"
 
@@ -34,12 +33,60 @@ type Entity struct {
 	// typed. Both pools are passed because which one an entity reads is its own
 	// choice.
 	SuggestKeyValues func(ctx context.Context, pgPool *pgxpool.Pool, chPool driver.Conn, appID uuid.UUID, key Key, valueRequest ValueRequest) (ValueList, error)
+
+	// MatchKey finds a key that is not among Keys because its name is only
+	// known from the data, such as a user defined attribute. Nil when the
+	// entity has no such keys.
+	MatchKey func(name string) (Key, bool)
+}
+
+// KeysByName indexes the entity's keys, along with the keys MatchKey finds
+// for the conditions of the tree.
+func (entity Entity) KeysByName(exprTree *ExprTree) map[string]Key {
+	byName := IndexKeysByName(entity.Keys)
+	if entity.MatchKey == nil {
+		return byName
+	}
+
+	var collect func(exprTree *ExprTree)
+	collect = func(exprTree *ExprTree) {
+		if exprTree == nil {
+			return
+		}
+		if exprTree.Condition != nil {
+			name := exprTree.Condition.KeyName
+			if _, ok := byName[name]; !ok {
+				if key, ok := entity.MatchKey(name); ok {
+					byName[name] = key
+				}
+			}
+			return
+		}
+		for i := range exprTree.Children {
+			collect(&exprTree.Children[i])
+		}
+	}
+	collect(exprTree)
+
+	return byName
 }
 
 func FindByName(name string) (Entity, error) {
 	switch name {
 	case BuildsEntity.Name:
 		return BuildsEntity, nil
+	case SessionsEntity.Name:
+		return SessionsEntity, nil
+	case ErrorGroupsEntity.Name:
+		return ErrorGroupsEntity, nil
+	case ErrorsEntity.Name:
+		return ErrorsEntity, nil
+	case SpansEntity.Name:
+		return SpansEntity, nil
+	case BugReportsEntity.Name:
+		return BugReportsEntity, nil
+	case AlertsEntity.Name:
+		return AlertsEntity, nil
 	}
 
 	return Entity{}, fmt.Errorf("Unknown filter entity %q", name)
@@ -47,12 +94,27 @@ func FindByName(name string) (Entity, error) {
 
 // The groups a key can belong to.
 const (
-	KeyGroupVersion KeyGroup = "Version"
-	KeyGroupBuild   KeyGroup = "Build"
+	KeyGroupVersion    KeyGroup = "Version"
+	KeyGroupBuild      KeyGroup = "Build"
+	KeyGroupOS         KeyGroup = "OS"
+	KeyGroupDevice     KeyGroup = "Device"
+	KeyGroupLocation   KeyGroup = "Location"
+	KeyGroupNetwork    KeyGroup = "Network"
+	KeyGroupUser       KeyGroup = "User"
+	KeyGroupSpan       KeyGroup = "Span"
+	KeyGroupHTTP       KeyGroup = "HTTP"
+	KeyGroupBugReport  KeyGroup = "Bug report"
+	KeyGroupAlert      KeyGroup = "Alert"
+	KeyGroupAttributes KeyGroup = "Attributes"
 )
 
 // keyGroupOrder is the order the filter bar shows groups in.
-var keyGroupOrder = []KeyGroup{KeyGroupVersion, KeyGroupBuild}
+var keyGroupOrder = []KeyGroup{
+	KeyGroupVersion, KeyGroupBuild,
+	KeyGroupOS, KeyGroupDevice, KeyGroupLocation, KeyGroupNetwork, KeyGroupUser,
+	KeyGroupSpan, KeyGroupHTTP, KeyGroupBugReport, KeyGroupAlert,
+	KeyGroupAttributes,
+}
 
 // ListKeyGroups lists the groups a set of keys falls into, in the order the
 // filter bar shows them.
@@ -127,7 +189,7 @@ var (
 	mappingType = Key{
 		Name:                "mapping_type",
 		Label:               "Mapping type",
-		Description:         "The kind of symbol file uploaded: proguard, dSYM, ELF debug or JS bundle.",
+		Description:         "The kind of symbol file uploaded: proguard, dSYM, ELF debug, JS bundle, Dart obfuscation map or Hermes source map.",
 		KeyGroup:            KeyGroupBuild,
 		ValueType:           ValueTypeEnum,
 		Operators:           []Operator{OperatorIn, OperatorNotIn},
@@ -166,7 +228,7 @@ var buildsKeys = []Key{
 // same name. Every key must answer each operator it offers, so a missing case
 // means a filter passed validation that cannot be written, and the request
 // fails.
-func bindBuildsKey(condition Condition) (*sqlf.Stmt, error) {
+func bindBuildsKey(condition Condition, _ Scope) (*sqlf.Stmt, error) {
 	switch condition.KeyName {
 	case versionName.Name:
 		switch condition.Operator {
@@ -234,15 +296,8 @@ func bindBuildsKey(condition Condition) (*sqlf.Stmt, error) {
 func fetchBuildsKeySuggestions(ctx context.Context, pgPool *pgxpool.Pool, chPool driver.Conn, appID uuid.UUID, key Key, valueRequest ValueRequest) (ValueList, error) {
 	// An enum key carries its values itself, so the search narrows that set here
 	// instead of a column.
-	if len(key.EnumValues) > 0 {
-		values := []Value{}
-		for _, text := range key.EnumValues {
-			if valueRequest.Search != "" && !strings.Contains(strings.ToLower(text), strings.ToLower(valueRequest.Search)) {
-				continue
-			}
-			values = append(values, Value{Text: text})
-		}
-		return ValueList{Values: values}, nil
+	if values, ok := suggestEnumValues(key, valueRequest); ok {
+		return values, nil
 	}
 
 	if key.ValueSuggestionMode == ValueSuggestionModeNone {
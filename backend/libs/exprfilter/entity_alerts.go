@@ -0,0 +1,128 @@
+package exprfilter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+var (
+	alertType = Key{
+		Name:                "alert_type",
+		Label:               "Alert type",
+		Description:         "What the alert is about, such as crash_spike or release_regression.",
+		KeyGroup:            KeyGroupAlert,
+		ValueType:           ValueTypeString,
+		Operators:           []Operator{OperatorIn, OperatorNotIn},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	alertMessage = Key{
+		Name:        "alert_message",
+		Label:       "Message",
+		Description: "The message the alert was sent with.",
+		KeyGroup:    KeyGroupAlert,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorContains, OperatorNotContains,
+		},
+		ValueSuggestionMode: ValueSuggestionModeNone,
+	}
+)
+
+// AlertsEntity is the alerts sent for an app. Both its filtering and its
+// value lists read the alerts rows in Postgres.
+var AlertsEntity = Entity{
+	Name:             "alerts",
+	Keys:             alertsKeys,
+	BindKey:          bindAlertsKey,
+	SuggestKeyValues: fetchAlertsKeySuggestions,
+}
+
+var alertsKeys = []Key{
+	alertType,
+	alertMessage,
+}
+
+// bindAlertsKey compares one key against its column of the alerts table.
+func bindAlertsKey(condition Condition, _ Scope) (*sqlf.Stmt, error) {
+	switch condition.KeyName {
+	case alertType.Name:
+		switch condition.Operator {
+		case OperatorIn:
+			return sqlf.New("type = any(?)", condition.TextValues()), nil
+		case OperatorNotIn:
+			return sqlf.New("type <> all(?)", condition.TextValues()), nil
+		}
+
+	case alertMessage.Name:
+		switch condition.Operator {
+		case OperatorContains:
+			return sqlf.New("message ilike ?", "%"+EscapeLikeWildcards(condition.TextValue())+"%"), nil
+		case OperatorNotContains:
+			return sqlf.New("message not ilike ?", "%"+EscapeLikeWildcards(condition.TextValue())+"%"), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrKeyNotSupported, condition.KeyName)
+	}
+
+	return nil, fmt.Errorf("Key %q cannot be filtered with %q", condition.KeyName, condition.Operator)
+}
+
+// fetchAlertsKeySuggestions lists the alert types sent for an app, most
+// recent first. Alerts are read from Postgres only, so the ClickHouse
+// connection is unused here.
+func fetchAlertsKeySuggestions(ctx context.Context, pgPool *pgxpool.Pool, chPool driver.Conn, appID uuid.UUID, key Key, valueRequest ValueRequest) (ValueList, error) {
+	if key.Name != alertType.Name {
+		return ValueList{}, fmt.Errorf("Key %q is typed in rather than picked from a list", key.Name)
+	}
+
+	limit := valueRequest.Limit
+	if limit <= 0 {
+		limit = DefaultValueLimit
+	}
+
+	stmt := sqlf.PostgreSQL.From("alerts").
+		Select("type").
+		Select("max(created_at) as recency").
+		Where("app_id = ?", appID).
+		GroupBy("type").
+		OrderBy("recency desc").
+		Limit(limit + 1)
+
+	defer stmt.Close()
+
+	if valueRequest.Search != "" {
+		stmt.Where("type ilike ?", "%"+EscapeLikeWildcards(valueRequest.Search)+"%")
+	}
+
+	rows, err := pgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+	}
+	defer rows.Close()
+
+	values := []Value{}
+	for rows.Next() {
+		var text string
+		var recency any
+		if err := rows.Scan(&text, &recency); err != nil {
+			return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+		}
+		values = append(values, Value{Text: text})
+	}
+	if err := rows.Err(); err != nil {
+		return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+	}
+
+	if len(values) > limit {
+		return ValueList{Values: values[:limit], Truncated: true}, nil
+	}
+
+	return ValueList{Values: values}, nil
+}
@@ -0,0 +1,47 @@
+package exprfilter
+
+import (
+	"backend/libs/config"
+)
+
+var bugReportStatus = Key{
+	Name:                "bug_report_status",
+	Label:               "Status",
+	Description:         "Whether the bug report is open or closed.",
+	KeyGroup:            KeyGroupBugReport,
+	ValueType:           ValueTypeEnum,
+	Operators:           []Operator{OperatorIn, OperatorNotIn},
+	ValueSuggestionMode: ValueSuggestionModeFullList,
+	EnumValues:          []string{"open", "closed"},
+}
+
+// BugReportsEntity is an app's bug reports. Filtering reads the bug_reports
+// table and value lists read the app_filters table.
+var BugReportsEntity = Entity{
+	Name:             "bug_reports",
+	Keys:             bugReportsKeys,
+	BindKey:          bindColumns(bugReportsKeys, bugReportsColumns, eventsAttrColumn),
+	SuggestKeyValues: suggestFromFilters(config.AppFiltersTable),
+	MatchKey:         matchAttrKey,
+}
+
+var bugReportsKeys = append(append([]Key{}, telemetryKeys...), bugReportStatus)
+
+var bugReportsColumns = map[string]column{
+	versionName.Name:        {expr: "app_version.1"},
+	versionCode.Name:        {expr: "app_version.2"},
+	osName.Name:             {expr: "os_version.1"},
+	osVersion.Name:          {expr: "os_version.2"},
+	deviceManufacturer.Name: {expr: "device_manufacturer"},
+	deviceName.Name:         {expr: "device_name"},
+	deviceLocale.Name:       {expr: "device_locale"},
+	country.Name:            {expr: "country_code"},
+	networkProvider.Name:    {expr: "network_provider"},
+	networkType.Name:        {expr: "network_type"},
+	networkGeneration.Name:  {expr: "network_generation"},
+	userID.Name:             {expr: "user_id"},
+	bugReportStatus.Name: {
+		expr: "status",
+		enum: map[string]any{"closed": 0, "open": 1},
+	},
+}
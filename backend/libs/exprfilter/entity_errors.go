@@ -0,0 +1,52 @@
+package exprfilter
+
+import (
+	"backend/libs/config"
+)
+
+// ErrorGroupsEntity is an app's error groups. Filtering reads the error
+// events of each group, so a group matches when any of its events does, and
+// its count is of the events that match. Value lists read the app_filters
+// table.
+var ErrorGroupsEntity = Entity{
+	Name:             "error_groups",
+	Keys:             telemetryKeys,
+	BindKey:          bindColumns(telemetryKeys, eventsColumns, eventsAttrColumn),
+	SuggestKeyValues: suggestFromFilters(config.AppFiltersTable),
+	MatchKey:         matchAttrKey,
+}
+
+// ErrorsEntity is the error events of an error group. Filtering reads the
+// events table and value lists read the app_filters table.
+var ErrorsEntity = Entity{
+	Name:             "errors",
+	Keys:             telemetryKeys,
+	BindKey:          bindColumns(telemetryKeys, eventsColumns, eventsAttrColumn),
+	SuggestKeyValues: suggestFromFilters(config.AppFiltersTable),
+	MatchKey:         matchAttrKey,
+}
+
+var eventsColumns = map[string]column{
+	versionName.Name:        {expr: "attribute.app_version"},
+	versionCode.Name:        {expr: "attribute.app_build"},
+	osName.Name:             {expr: "attribute.os_name"},
+	osVersion.Name:          {expr: "attribute.os_version"},
+	deviceManufacturer.Name: {expr: "attribute.device_manufacturer"},
+	deviceName.Name:         {expr: "attribute.device_name"},
+	deviceLocale.Name:       {expr: "attribute.device_locale"},
+	country.Name:            {expr: "inet.country_code"},
+	networkProvider.Name:    {expr: "attribute.network_provider"},
+	networkType.Name:        {expr: "attribute.network_type"},
+	networkGeneration.Name:  {expr: "attribute.network_generation"},
+	userID.Name:             {expr: "attribute.user_id"},
+}
+
+// eventsAttrColumn reads an attribute from the user_defined_attribute map of
+// the events, spans and bug_reports tables. A missing attribute reads as
+// empty text.
+func eventsAttrColumn(attr string) column {
+	return column{
+		expr: "tupleElement(user_defined_attribute[?], 2)",
+		args: []any{attr},
+	}
+}
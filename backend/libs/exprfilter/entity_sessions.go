@@ -0,0 +1,66 @@
+package exprfilter
+
+import (
+	"backend/libs/config"
+)
+
+var httpStatusCode = Key{
+	Name:        "http_status_code",
+	Label:       "HTTP status",
+	Description: "The status code of an HTTP response the app received.",
+	KeyGroup:    KeyGroupHTTP,
+	ValueType:   ValueTypeUInt32,
+	Operators: []Operator{
+		OperatorEq, OperatorNeq,
+		OperatorGt, OperatorGte, OperatorLt, OperatorLte,
+	},
+	ValueSuggestionMode: ValueSuggestionModeNone,
+}
+
+// SessionsEntity is an app's sessions. Filtering reads the sessions table,
+// whose rows hold every value a session has seen, so a session matches when
+// any of its values does. Value lists read the app_filters table.
+var SessionsEntity = Entity{
+	Name:             "sessions",
+	Keys:             sessionsKeys,
+	BindKey:          bindColumns(sessionsKeys, sessionsColumns, sessionsAttrColumn),
+	SuggestKeyValues: suggestFromFilters(config.AppFiltersTable),
+	MatchKey:         matchAttrKey,
+}
+
+var sessionsKeys = append(append([]Key{}, telemetryKeys...), httpStatusCode)
+
+var sessionsColumns = map[string]column{
+	versionName.Name:        {expr: "app_version.1"},
+	versionCode.Name:        {expr: "app_version.2"},
+	osName.Name:             {expr: "os_version.1"},
+	osVersion.Name:          {expr: "os_version.2"},
+	deviceManufacturer.Name: {expr: "device_manufacturer"},
+	deviceName.Name:         {expr: "device_name"},
+	deviceLocale.Name:       {expr: "device_locales", array: true},
+	country.Name:            {expr: "country_codes", array: true},
+	networkProvider.Name:    {expr: "network_providers", array: true},
+	networkType.Name:        {expr: "network_types", array: true},
+	networkGeneration.Name:  {expr: "network_generations", array: true},
+	userID.Name:             {expr: "user_ids", array: true},
+	httpStatusCode.Name: {
+		expr: "http.status_code",
+		lookup: &lookup{
+			id:    "session_id",
+			query: "select session_id from events where type = 'http'",
+		},
+	},
+}
+
+// sessionsAttrColumn reads a session's attribute from the user_def_attrs
+// table, which holds one row for each attribute of each event.
+func sessionsAttrColumn(attr string) column {
+	return column{
+		expr: "value",
+		lookup: &lookup{
+			id:    "session_id",
+			query: "select session_id from user_def_attrs where key = ?",
+			args:  []any{attr},
+		},
+	}
+}
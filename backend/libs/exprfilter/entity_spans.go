@@ -0,0 +1,47 @@
+package exprfilter
+
+import (
+	"backend/libs/config"
+)
+
+var spanStatus = Key{
+	Name:                "span_status",
+	Label:               "Span status",
+	Description:         "How the span ended: unset, ok or error.",
+	KeyGroup:            KeyGroupSpan,
+	ValueType:           ValueTypeEnum,
+	Operators:           []Operator{OperatorIn, OperatorNotIn},
+	ValueSuggestionMode: ValueSuggestionModeFullList,
+	EnumValues:          []string{"unset", "ok", "error"},
+}
+
+// SpansEntity is an app's spans. Filtering reads the spans table and value
+// lists read the span_filters table.
+var SpansEntity = Entity{
+	Name:             "spans",
+	Keys:             spansKeys,
+	BindKey:          bindColumns(spansKeys, spansColumns, eventsAttrColumn),
+	SuggestKeyValues: suggestFromFilters(config.SpanFiltersTable),
+	MatchKey:         matchAttrKey,
+}
+
+var spansKeys = append(append([]Key{}, telemetryKeys...), spanStatus)
+
+var spansColumns = map[string]column{
+	versionName.Name:        {expr: "tupleElement(attribute.app_version, 1)"},
+	versionCode.Name:        {expr: "tupleElement(attribute.app_version, 2)"},
+	osName.Name:             {expr: "tupleElement(attribute.os_version, 1)"},
+	osVersion.Name:          {expr: "tupleElement(attribute.os_version, 2)"},
+	deviceManufacturer.Name: {expr: "attribute.device_manufacturer"},
+	deviceName.Name:         {expr: "attribute.device_name"},
+	deviceLocale.Name:       {expr: "attribute.device_locale"},
+	country.Name:            {expr: "attribute.country_code"},
+	networkProvider.Name:    {expr: "attribute.network_provider"},
+	networkType.Name:        {expr: "attribute.network_type"},
+	networkGeneration.Name:  {expr: "attribute.network_generation"},
+	userID.Name:             {expr: "attribute.user_id"},
+	spanStatus.Name: {
+		expr: "status",
+		enum: map[string]any{"unset": 0, "ok": 1, "error": 2},
+	},
+}
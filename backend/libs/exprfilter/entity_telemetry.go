@@ -0,0 +1,465 @@
+package exprfilter
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// The keys of what an app's SDK sends, shared by every ClickHouse entity. Each
+// entity says which column of its table a key reads.
+var (
+	osName = Key{
+		Name:                "os_name",
+		Label:               "OS name",
+		Description:         "The name of the operating system, such as android or ios.",
+		KeyGroup:            KeyGroupOS,
+		ValueType:           ValueTypeString,
+		Operators:           []Operator{OperatorIn, OperatorNotIn},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	osVersion = Key{
+		Name:        "os_version",
+		Label:       "OS version",
+		Description: "The version of the operating system (API level on Android).",
+		KeyGroup:    KeyGroupOS,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn, OperatorStartsWith,
+		},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	deviceManufacturer = Key{
+		Name:        "device_manufacturer",
+		Label:       "Device manufacturer",
+		Description: "The manufacturer of the device.",
+		KeyGroup:    KeyGroupDevice,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn, OperatorContains,
+		},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	deviceName = Key{
+		Name:        "device_name",
+		Label:       "Device name",
+		Description: "The name of the device model, as the manufacturer calls it.",
+		KeyGroup:    KeyGroupDevice,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn,
+			OperatorContains, OperatorStartsWith,
+		},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	deviceLocale = Key{
+		Name:        "locale",
+		Label:       "Locale",
+		Description: "The locale of the device, such as en-US.",
+		KeyGroup:    KeyGroupDevice,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn, OperatorStartsWith,
+		},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	country = Key{
+		Name:                "country",
+		Label:               "Country",
+		Description:         "The two letter code of the country the device was in.",
+		KeyGroup:            KeyGroupLocation,
+		ValueType:           ValueTypeString,
+		Operators:           []Operator{OperatorIn, OperatorNotIn},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	networkProvider = Key{
+		Name:        "network_provider",
+		Label:       "Network provider",
+		Description: "The carrier of the mobile network.",
+		KeyGroup:    KeyGroupNetwork,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn, OperatorContains,
+		},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	networkType = Key{
+		Name:                "network_type",
+		Label:               "Network type",
+		Description:         "The kind of network, such as wifi or cellular.",
+		KeyGroup:            KeyGroupNetwork,
+		ValueType:           ValueTypeString,
+		Operators:           []Operator{OperatorIn, OperatorNotIn},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	networkGeneration = Key{
+		Name:                "network_generation",
+		Label:               "Network generation",
+		Description:         "The generation of the mobile network, such as 4g or 5g.",
+		KeyGroup:            KeyGroupNetwork,
+		ValueType:           ValueTypeString,
+		Operators:           []Operator{OperatorIn, OperatorNotIn},
+		ValueSuggestionMode: ValueSuggestionModeSample,
+	}
+
+	userID = Key{
+		Name:        "user_id",
+		Label:       "User id",
+		Description: "The id the app set for its user.",
+		KeyGroup:    KeyGroupUser,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn,
+			OperatorIsSet, OperatorIsNotSet,
+		},
+		ValueSuggestionMode: ValueSuggestionModeNone,
+	}
+)
+
+// telemetryKeys are the keys every ClickHouse entity offers.
+var telemetryKeys = []Key{
+	versionName,
+	versionCode,
+	osName,
+	osVersion,
+	deviceManufacturer,
+	deviceName,
+	deviceLocale,
+	country,
+	networkProvider,
+	networkType,
+	networkGeneration,
+	userID,
+}
+
+// AttrKeyPrefix starts the name of a key reading a user defined attribute,
+// as in attrs.plan:in:pro.
+const AttrKeyPrefix = "attrs."
+
+// attrNamePattern is the form of an attribute name the SDKs accept.
+var attrNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// matchAttrKey finds the key of a user defined attribute. An attribute is
+// stored as text whatever its type, so it is compared as text.
+func matchAttrKey(name string) (Key, bool) {
+	attr, ok := strings.CutPrefix(name, AttrKeyPrefix)
+	if !ok || !attrNamePattern.MatchString(attr) {
+		return Key{}, false
+	}
+
+	return Key{
+		Name:        name,
+		Label:       attr,
+		Description: fmt.Sprintf("The user defined attribute %q.", attr),
+		KeyGroup:    KeyGroupAttributes,
+		ValueType:   ValueTypeString,
+		Operators: []Operator{
+			OperatorIn, OperatorNotIn,
+			OperatorContains, OperatorStartsWith,
+			OperatorIsSet, OperatorIsNotSet,
+		},
+		ValueSuggestionMode: ValueSuggestionModeNone,
+	}, true
+}
+
+// column says where a key's value lives in the rows an entity filters.
+type column struct {
+	// expr reads the value. args are bound to its placeholders.
+	expr string
+	args []any
+
+	// array says expr holds every value a row has seen, as a session
+	// does, rather than one. A condition matches when any of them does.
+	array bool
+
+	// enum maps the values of an enum key to what the column stores.
+	enum map[string]any
+
+	// lookup, when set, says the value lives in another table.
+	lookup *lookup
+}
+
+// lookup finds the rows of another table holding a key's value. query selects
+// id from that table and ends in a where clause the condition is added to.
+// The table must have team_id, app_id and timestamp columns, which bound the
+// lookup to the filter's scope.
+type lookup struct {
+	id    string
+	query string
+	args  []any
+}
+
+// scoped writes the lookup's query bounded to the scope's team, app and,
+// when set, time range.
+func (l lookup) scoped(scope Scope) (string, []any) {
+	query := l.query + " and team_id = toUUID(?) and app_id = toUUID(?)"
+	args := append(append([]any{}, l.args...), scope.TeamID, scope.AppID)
+	if !scope.From.IsZero() && !scope.To.IsZero() {
+		query += " and timestamp >= ? and timestamp <= ?"
+		args = append(args, scope.From, scope.To)
+	}
+	return query, args
+}
+
+// bindColumns binds each key to the column of the same name in columns, and
+// each user defined attribute to the column attr returns for it. attr is nil
+// for an entity without attributes.
+func bindColumns(keys []Key, columns map[string]column, attr func(name string) column) KeyBinding {
+	keysByName := IndexKeysByName(keys)
+
+	return func(condition Condition, scope Scope) (*sqlf.Stmt, error) {
+		key, ok := keysByName[condition.KeyName]
+		col, hasColumn := columns[condition.KeyName]
+		if !ok || !hasColumn {
+			if attr == nil {
+				return nil, fmt.Errorf("%w: %q", ErrKeyNotSupported, condition.KeyName)
+			}
+			if key, ok = matchAttrKey(condition.KeyName); !ok {
+				return nil, fmt.Errorf("%w: %q", ErrKeyNotSupported, condition.KeyName)
+			}
+			col = attr(strings.TrimPrefix(condition.KeyName, AttrKeyPrefix))
+		}
+
+		return col.bind(key, condition, scope)
+	}
+}
+
+// bind writes one condition against the column. A lookup reads only the
+// other table's rows within scope.
+func (col column) bind(key Key, condition Condition, scope Scope) (*sqlf.Stmt, error) {
+	if col.lookup == nil {
+		return col.compare(key, condition)
+	}
+
+	query, lookupArgs := col.lookup.scoped(scope)
+
+	// A row without the value has no row in the other table, so "not set"
+	// is the rows missing there.
+	if condition.Operator == OperatorIsNotSet {
+		return sqlf.New(col.lookup.id+" not in ("+query+")", lookupArgs...), nil
+	}
+	if condition.Operator == OperatorIsSet {
+		return sqlf.New(col.lookup.id+" in ("+query+")", lookupArgs...), nil
+	}
+
+	compared, err := col.compare(key, condition)
+	if err != nil {
+		return nil, err
+	}
+	defer compared.Close()
+
+	args := append(lookupArgs, compared.Args()...)
+	return sqlf.New(col.lookup.id+" in ("+query+" and "+compared.String()+")", args...), nil
+}
+
+// compare writes one condition against the column's own expression.
+func (col column) compare(key Key, condition Condition) (*sqlf.Stmt, error) {
+	expr := col.expr
+	args := col.args
+
+	with := func(values ...any) []any {
+		return append(append([]any{}, args...), values...)
+	}
+
+	// anyOf matches when any value of an array column satisfies test,
+	// written against x.
+	anyOf := func(test string) string {
+		return "arrayExists(x -> " + test + ", " + expr + ")"
+	}
+
+	switch condition.Operator {
+	case OperatorIn, OperatorNotIn:
+		values, err := col.values(condition)
+		if err != nil {
+			return nil, err
+		}
+		text := expr + " in ?"
+		if col.array {
+			text = "hasAny(" + expr + ", ?)"
+		}
+		if condition.Operator == OperatorNotIn {
+			text = "not " + text
+		}
+		return sqlf.New(text, with(values)...), nil
+
+	case OperatorContains, OperatorNotContains, OperatorStartsWith, OperatorEndsWith:
+		pattern := EscapeLikeWildcards(condition.TextValue())
+		switch condition.Operator {
+		case OperatorContains, OperatorNotContains:
+			pattern = "%" + pattern + "%"
+		case OperatorStartsWith:
+			pattern += "%"
+		case OperatorEndsWith:
+			pattern = "%" + pattern
+		}
+		text := expr + " ilike ?"
+		if col.array {
+			text = anyOf("x ilike ?")
+		}
+		if condition.Operator == OperatorNotContains {
+			text = "not " + text
+		}
+		return sqlf.New(text, with(pattern)...), nil
+
+	case OperatorIsSet, OperatorIsNotSet:
+		text := expr + " != ''"
+		if col.array {
+			text = anyOf("x != ''")
+		}
+		if condition.Operator == OperatorIsNotSet {
+			text = "not " + text
+		}
+		return sqlf.New(text, args...), nil
+
+	case OperatorEq, OperatorNeq, OperatorGt, OperatorGte, OperatorLt, OperatorLte:
+		if col.array {
+			break
+		}
+		var number any
+		var err error
+		if key.ValueType == ValueTypeFloat64 {
+			number, err = condition.FloatValue()
+		} else {
+			number, err = condition.IntegerValue()
+		}
+		if err != nil {
+			return nil, err
+		}
+		symbols := map[Operator]string{
+			OperatorEq:  "=",
+			OperatorNeq: "!=",
+			OperatorGt:  ">",
+			OperatorGte: ">=",
+			OperatorLt:  "<",
+			OperatorLte: "<=",
+		}
+		return sqlf.New(expr+" "+symbols[condition.Operator]+" ?", with(number)...), nil
+	}
+
+	return nil, fmt.Errorf("Key %q cannot be filtered with %q", condition.KeyName, condition.Operator)
+}
+
+// values returns the values of a list condition as the column stores them.
+func (col column) values(condition Condition) (any, error) {
+	if col.enum == nil {
+		return condition.TextValues(), nil
+	}
+
+	values := make([]any, len(condition.Values))
+	for i, value := range condition.Values {
+		stored, ok := col.enum[value.Text]
+		if !ok {
+			return nil, fmt.Errorf("Key %q has no value %q", condition.KeyName, value.Text)
+		}
+		values[i] = stored
+	}
+	return values, nil
+}
+
+// filtersColumns are the columns of the app_filters and span_filters tables
+// the values of a key are suggested from.
+var filtersColumns = map[string]string{
+	versionName.Name:        "app_version.1",
+	versionCode.Name:        "app_version.2",
+	osName.Name:             "os_version.1",
+	osVersion.Name:          "os_version.2",
+	deviceManufacturer.Name: "device_manufacturer",
+	deviceName.Name:         "device_name",
+	deviceLocale.Name:       "device_locale",
+	country.Name:            "country_code",
+	networkProvider.Name:    "network_provider",
+	networkType.Name:        "network_type",
+	networkGeneration.Name:  "network_generation",
+}
+
+// suggestFromFilters lists the values of a key from the filters table that
+// the ingest pipeline keeps of every value seen, most recently seen first.
+func suggestFromFilters(table string) func(ctx context.Context, pgPool *pgxpool.Pool, chPool driver.Conn, appID uuid.UUID, key Key, valueRequest ValueRequest) (ValueList, error) {
+	return func(ctx context.Context, pgPool *pgxpool.Pool, chPool driver.Conn, appID uuid.UUID, key Key, valueRequest ValueRequest) (ValueList, error) {
+		if values, ok := suggestEnumValues(key, valueRequest); ok {
+			return values, nil
+		}
+
+		column, ok := filtersColumns[key.Name]
+		if !ok || key.ValueSuggestionMode == ValueSuggestionModeNone {
+			return ValueList{}, fmt.Errorf("Key %q is typed in rather than picked from a list", key.Name)
+		}
+
+		limit := valueRequest.Limit
+		if limit <= 0 {
+			limit = DefaultValueLimit
+		}
+
+		stmt := sqlf.
+			From(table).
+			Select(column+" as value").
+			Select("max(end_of_month) as recency").
+			Where("app_id = toUUID(?)", appID).
+			Where(column + " != ''").
+			GroupBy("value").
+			OrderBy("recency desc, value").
+			Limit(limit + 1)
+
+		defer stmt.Close()
+
+		if valueRequest.Search != "" {
+			stmt.Where(column+" ilike ?", "%"+EscapeLikeWildcards(valueRequest.Search)+"%")
+		}
+
+		rows, err := chPool.Query(ctx, stmt.String(), stmt.Args()...)
+		if err != nil {
+			return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+		}
+		defer rows.Close()
+
+		values := []Value{}
+		for rows.Next() {
+			var text string
+			var recency any
+			if err := rows.Scan(&text, &recency); err != nil {
+				return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+			}
+			values = append(values, Value{Text: text})
+		}
+		if err := rows.Err(); err != nil {
+			return ValueList{}, fmt.Errorf("Failed to read the values of key %q: %w", key.Name, err)
+		}
+
+		if len(values) > limit {
+			return ValueList{Values: values[:limit], Truncated: true}, nil
+		}
+
+		return ValueList{Values: values}, nil
+	}
+}
+
+// suggestEnumValues narrows the values an enum key carries by what has been
+// typed. It reports false for a key that is not an enum.
+func suggestEnumValues(key Key, valueRequest ValueRequest) (ValueList, bool) {
+	if len(key.EnumValues) == 0 {
+		return ValueList{}, false
+	}
+
+	values := []Value{}
+	for _, text := range key.EnumValues {
+		if valueRequest.Search != "" && !strings.Contains(strings.ToLower(text), strings.ToLower(valueRequest.Search)) {
+			continue
+		}
+		values = append(values, Value{Text: text})
+	}
+	return ValueList{Values: values}, true
+}
@@ -0,0 +1,169 @@
+package exprfilter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestColumnsBindAKeyToTheirEntitysTable(t *testing.T) {
+	scope := Scope{
+		TeamID: uuid.MustParse("7d5a3e54-0f6b-4d3a-9c8e-1f2b3c4d5e6f"),
+		AppID:  uuid.MustParse("1b2c3d4e-5f60-4718-8293-a4b5c6d7e8f9"),
+		From:   time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC),
+		To:     time.Date(2026, 10, 8, 0, 0, 0, 0, time.UTC),
+	}
+	scoped := " and team_id = toUUID(?) and app_id = toUUID(?) and timestamp >= ? and timestamp <= ?"
+
+	tests := []struct {
+		name      string
+		entity    Entity
+		condition *ExprTree
+		want      string
+		wantArgs  []any
+	}{
+		{
+			name:      "a single value column is compared directly",
+			entity:    ErrorsEntity,
+			condition: leafExprTree("country", OperatorIn, "IN", "US"),
+			want:      "inet.country_code in ?",
+			wantArgs:  []any{[]string{"IN", "US"}},
+		},
+		{
+			name:      "an array column matches when any of its values does",
+			entity:    SessionsEntity,
+			condition: leafExprTree("country", OperatorNotIn, "IN"),
+			want:      "not hasAny(country_codes, ?)",
+			wantArgs:  []any{[]string{"IN"}},
+		},
+		{
+			name:      "a text match on an array column tests each value",
+			entity:    SessionsEntity,
+			condition: leafExprTree("locale", OperatorStartsWith, "en"),
+			want:      "arrayExists(x -> x ilike ?, device_locales)",
+			wantArgs:  []any{"en%"},
+		},
+		{
+			name:      "an enum value is compared as the column stores it",
+			entity:    SpansEntity,
+			condition: leafExprTree("span_status", OperatorIn, "error"),
+			want:      "status in ?",
+			wantArgs:  []any{[]any{2}},
+		},
+		{
+			name:      "a number is bound as a number",
+			entity:    SessionsEntity,
+			condition: leafExprTree("http_status_code", OperatorGte, "500"),
+			want:      "session_id in (select session_id from events where type = 'http'" + scoped + " and http.status_code >= ?)",
+			wantArgs:  []any{scope.TeamID, scope.AppID, scope.From, scope.To, int64(500)},
+		},
+		{
+			name:      "an attribute reads the attribute map",
+			entity:    BugReportsEntity,
+			condition: leafExprTree("attrs.plan", OperatorIn, "pro"),
+			want:      "tupleElement(user_defined_attribute[?], 2) in ?",
+			wantArgs:  []any{"plan", []string{"pro"}},
+		},
+		{
+			name:      "a session attribute is looked up by its key",
+			entity:    SessionsEntity,
+			condition: leafExprTree("attrs.plan", OperatorContains, "pro"),
+			want:      "session_id in (select session_id from user_def_attrs where key = ?" + scoped + " and value ilike ?)",
+			wantArgs:  []any{"plan", scope.TeamID, scope.AppID, scope.From, scope.To, "%pro%"},
+		},
+		{
+			name:      "an unset session attribute has no row to look up",
+			entity:    SessionsEntity,
+			condition: leafExprTree("attrs.plan", OperatorIsNotSet),
+			want:      "session_id not in (select session_id from user_def_attrs where key = ?" + scoped + ")",
+			wantArgs:  []any{"plan", scope.TeamID, scope.AppID, scope.From, scope.To},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stmt, err := test.entity.BindKey(*test.condition.Condition, scope)
+			if err != nil {
+				t.Fatalf("BindKey: %v", err)
+			}
+			defer stmt.Close()
+
+			if got := stmt.String(); got != test.want {
+				t.Errorf("want %q, got %q", test.want, got)
+			}
+
+			if got := stmt.Args(); !reflect.DeepEqual(got, test.wantArgs) {
+				t.Errorf("want args %#v, got %#v", test.wantArgs, got)
+			}
+		})
+	}
+}
+
+func TestALookupWithoutATimeRangeIsBoundedByTeamAndApp(t *testing.T) {
+	scope := Scope{TeamID: uuid.New(), AppID: uuid.New()}
+
+	stmt, err := SessionsEntity.BindKey(*leafExprTree("attrs.plan", OperatorIsSet).Condition, scope)
+	if err != nil {
+		t.Fatalf("BindKey: %v", err)
+	}
+	defer stmt.Close()
+
+	want := "session_id in (select session_id from user_def_attrs where key = ? and team_id = toUUID(?) and app_id = toUUID(?))"
+	if got := stmt.String(); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, wantArgs := stmt.Args(), []any{"plan", scope.TeamID, scope.AppID}; !reflect.DeepEqual(got, wantArgs) {
+		t.Errorf("want args %#v, got %#v", wantArgs, got)
+	}
+}
+
+func TestAttributeKeysAreFoundByName(t *testing.T) {
+	exprTree := &ExprTree{
+		LogicalOperator: LogicalAnd,
+		Children: []ExprTree{
+			*leafExprTree("attrs.plan", OperatorIn, "pro"),
+			*leafExprTree("country", OperatorIn, "IN"),
+		},
+	}
+
+	byName := SessionsEntity.KeysByName(exprTree)
+	if key, ok := byName["attrs.plan"]; !ok || key.KeyGroup != KeyGroupAttributes {
+		t.Errorf("want attrs.plan found as an attribute key, got %+v", key)
+	}
+	if _, ok := byName["country"]; !ok {
+		t.Error("want the entity's own keys kept")
+	}
+
+	if _, ok := AlertsEntity.KeysByName(exprTree)["attrs.plan"]; ok {
+		t.Error("want no attribute keys on an entity without attributes")
+	}
+
+	for _, name := range []string{"attrs.", "attrs.a b", "plan"} {
+		if _, ok := matchAttrKey(name); ok {
+			t.Errorf("want %q refused as an attribute key", name)
+		}
+	}
+}
+
+func TestValidateAcceptsAttributeKeys(t *testing.T) {
+	ef := &ExprFilter{Entity: SessionsEntity}
+	ef.FilterExpr = "attrs.plan:in:pro OR attrs.plan:is_not_set"
+	if err := ef.BuildExprTree(); err != nil {
+		t.Fatalf("BuildExprTree: %v", err)
+	}
+	if err := ef.ValidateExprTree(); err != nil {
+		t.Fatalf("ValidateExprTree: %v", err)
+	}
+
+	ef.FilterExpr = "attrs.plan:gt:1"
+	if err := ef.BuildExprTree(); err != nil {
+		t.Fatalf("BuildExprTree: %v", err)
+	}
+	err := ef.ValidateExprTree()
+	if err == nil || !strings.Contains(err.Error(), "not offered") {
+		t.Errorf("want an operator attributes do not offer refused, got %v", err)
+	}
+}
@@ -9,7 +9,15 @@ import (
 	"github.com/google/uuid"
 )
 
-var allEntities = []Entity{BuildsEntity}
+var allEntities = []Entity{
+	BuildsEntity,
+	SessionsEntity,
+	ErrorGroupsEntity,
+	ErrorsEntity,
+	SpansEntity,
+	BugReportsEntity,
+	AlertsEntity,
+}
 
 func sampleValues(t *testing.T, key Key, operator Operator) []Value {
 	t.Helper()
@@ -174,7 +182,7 @@ func TestEntitiesBindEveryOperatorTheyOffer(t *testing.T) {
 							Values:   sampleValues(t, key, operator),
 						}
 
-						stmt, err := entity.BindKey(condition)
+						stmt, err := entity.BindKey(condition, Scope{})
 						if err != nil {
 							t.Errorf("Operator %q: %v", operator, err)
 							continue
@@ -195,7 +203,7 @@ func TestBindKeyRefusesAKeyTheEntityDoesNotHave(t *testing.T) {
 		KeyName:  "device_cohort",
 		Operator: OperatorIn,
 		Values:   []Value{{Text: "beta"}},
-	})
+	}, Scope{})
 
 	if err == nil {
 		t.Fatal("want a key the builds entity does not have refused")
@@ -89,6 +89,12 @@ func (ef *ExprFilter) Validate() error {
 		return errors.New("`offset` cannot be negative")
 	}
 
+	return ef.ValidateExprTree()
+}
+
+// ValidateExprTree validates the filter expression alone, for a caller that
+// pages and bounds time itself.
+func (ef *ExprFilter) ValidateExprTree() error {
 	if !ef.HasFilterExpr() {
 		return nil
 	}
@@ -97,5 +103,5 @@ func (ef *ExprFilter) Validate() error {
 		return errors.New("Filter entity is not set")
 	}
 
-	return ValidateFilterExpr(ef.ExprTree, IndexKeysByName(ef.Entity.Keys))
+	return ValidateFilterExpr(ef.ExprTree, ef.Entity.KeysByName(ef.ExprTree))
 }
@@ -2,14 +2,26 @@ package exprfilter
 
 import (
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/leporo/sqlf"
 )
 
 // KeyBinding says how a key maps to actual data: it turns one filter condition
 // into a boolean SQL expression, with the values to bind. An override written
-// for a single key needs to handle every operator that key offers.
-type KeyBinding func(condition Condition) (*sqlf.Stmt, error)
+// for a single key needs to handle every operator that key offers. A binding
+// that reads another table bounds that read by scope.
+type KeyBinding func(condition Condition, scope Scope) (*sqlf.Stmt, error)
+
+// Scope is the team, app and time range a filter reads. A condition checked
+// against another table only sees that table's rows within it.
+type Scope struct {
+	TeamID uuid.UUID
+	AppID  uuid.UUID
+	From   time.Time
+	To     time.Time
+}
 
 // Predicate turns the filter into a boolean SQL expression and its bind values.
 // The caller can use it in a WHERE, HAVING, or any other SQL clause:
@@ -21,11 +33,18 @@ type KeyBinding func(condition Condition) (*sqlf.Stmt, error)
 // keyBindingOverrides replaces the key binding for specific keys. Pass nil
 // when the entity's own binding should write every condition.
 func (ef *ExprFilter) Predicate(keyBindingOverrides map[string]KeyBinding) (*sqlf.Stmt, error) {
+	scope := Scope{
+		TeamID: ef.TeamID,
+		AppID:  ef.AppID,
+		From:   ef.From,
+		To:     ef.To,
+	}
+
 	bindLeaf := func(condition Condition) (*sqlf.Stmt, error) {
 		if keyBinding, overridden := keyBindingOverrides[condition.KeyName]; overridden {
-			return keyBinding(condition)
+			return keyBinding(condition, scope)
 		}
-		return ef.Entity.BindKey(condition)
+		return ef.Entity.BindKey(condition, scope)
 	}
 
 	// Wrap each child to preserve nested group semantics, then wrap the
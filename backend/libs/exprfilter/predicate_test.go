@@ -23,7 +23,7 @@ var testEntity = Entity{
 	SuggestKeyValues: fetchTestKeySuggestions,
 }
 
-func bindTestKey(condition Condition) (*sqlf.Stmt, error) {
+func bindTestKey(condition Condition, _ Scope) (*sqlf.Stmt, error) {
 	switch condition.KeyName {
 	case "version_name":
 		switch condition.Operator {
@@ -192,7 +192,7 @@ func TestPredicateTakesAnOverrideForOneKey(t *testing.T) {
 	}}
 
 	predicate, err := testFilters(exprTree).Predicate(map[string]KeyBinding{
-		"version_name": func(condition Condition) (*sqlf.Stmt, error) {
+		"version_name": func(condition Condition, _ Scope) (*sqlf.Stmt, error) {
 			return sqlf.New("b.version_name = any(?)", condition.TextValues()), nil
 		},
 	})
@@ -13,6 +13,7 @@ import (
 	"backend/libs/chquery"
 	"backend/libs/config"
 	"backend/libs/event"
+	"backend/libs/exprfilter"
 	"backend/libs/logcomment"
 	"backend/libs/pairs"
 	"backend/libs/text"
@@ -142,6 +143,33 @@ type AppFilter struct {
 	// ErrorTypes holds the parsed error type values, populated in Expand.
 	ErrorTypes []event.ErrorType
 
+	// ErrorGroupStatus is the raw comma-separated error group status
+	// filter value. Valid values: any combination of "open", "resolved",
+	// "ignored", "snoozed".
+	ErrorGroupStatus string `form:"status"`
+
+	// ErrorGroupStatuses holds the parsed error group status values,
+	// populated in Expand.
+	ErrorGroupStatuses []string
+
+	// ErrorGroupIDs holds the fingerprints of error groups matched
+	// by ErrorGroupStatuses, resolved from the persisted error group
+	// states.
+	ErrorGroupIDs []string
+
+	// ExcludeErrorGroupIDs indicates ErrorGroupIDs should be
+	// excluded instead of included.
+	ExcludeErrorGroupIDs bool
+
+	// MergedErrorGroupIDs holds the fingerprints of error
+	// groups merged into other groups, resolved from the
+	// persisted merges.
+	MergedErrorGroupIDs []string
+
+	// MergedErrorGroupTargets holds the fingerprint of the
+	// group each of MergedErrorGroupIDs is merged into.
+	MergedErrorGroupTargets []string
+
 	// CustomError indicates if the filtering should
 	// consider only custom errors.
 	CustomError bool `form:"custom"`
@@ -163,6 +191,15 @@ type AppFilter struct {
 	// attribute expression.
 	UDExpression *udattr.UDExpression
 
+	// FilterExpr is the text form of an expression
+	// filter, written against the keys of the entity
+	// being listed.
+	FilterExpr string `form:"filter_expr"`
+
+	// ExprFilter contains the parsed & validated
+	// FilterExpr.
+	ExprFilter *exprfilter.ExprFilter
+
 	// Span indicates the filtering should only
 	// consider spans.
 	Span bool `form:"span"`
@@ -229,6 +266,10 @@ const (
 	PlotTimeGroupMonths  = "months"
 )
 
+// errorGroupStatuses are the statuses error
+// groups can be filtered on.
+var errorGroupStatuses = []string{"open", "resolved", "ignored", "snoozed"}
+
 var validPlotTimeGroups = map[string]struct{}{
 	PlotTimeGroupMinutes: {},
 	PlotTimeGroupHours:   {},
@@ -351,6 +392,10 @@ func (af *AppFilter) Expand(ctx context.Context, pg *pgxpool.Pool) (err error) {
 			}
 		}
 
+		if af.ErrorGroupStatus != "" {
+			af.ErrorGroupStatuses = text.SplitTrimEmpty(af.ErrorGroupStatus, ",")
+		}
+
 		return
 	}
 
@@ -411,6 +456,10 @@ func (af *AppFilter) Expand(ctx context.Context, pg *pgxpool.Pool) (err error) {
 		}
 	}
 
+	if af.ErrorGroupStatus != "" {
+		af.ErrorGroupStatuses = text.SplitTrimEmpty(af.ErrorGroupStatus, ",")
+	}
+
 	if len(af.UDExpressionRaw) > 0 {
 		af.UDExpressionRaw = strings.TrimSpace(af.UDExpressionRaw)
 	}
@@ -497,9 +546,67 @@ func (af *AppFilter) Validate() error {
 		}
 	}
 
+	for _, status := range af.ErrorGroupStatuses {
+		if !slices.Contains(errorGroupStatuses, status) {
+			return fmt.Errorf("`status` must be any combination of: %s", strings.Join(errorGroupStatuses, ", "))
+		}
+	}
+
+	return nil
+}
+
+// BuildExprFilter parses the expression filter and
+// validates it against the keys of the entity being
+// listed. Leaves ExprFilter unset if no expression
+// filter was requested.
+func (af *AppFilter) BuildExprFilter(entity exprfilter.Entity) error {
+	af.ExprFilter = nil
+
+	if af.FilterExpr == "" {
+		return nil
+	}
+
+	ef := &exprfilter.ExprFilter{
+		AppID:      af.AppID,
+		Entity:     entity,
+		From:       af.From,
+		To:         af.To,
+		FilterExpr: af.FilterExpr,
+	}
+
+	if err := ef.BuildExprTree(); err != nil {
+		return err
+	}
+
+	if err := ef.ValidateExprTree(); err != nil {
+		return err
+	}
+
+	af.ExprFilter = ef
+
 	return nil
 }
 
+// ScopeExprFilter bounds what the expression
+// filter reads from other tables to the team and
+// the filter's time range. Call it once the time
+// range is final.
+func (af *AppFilter) ScopeExprFilter(teamID uuid.UUID) {
+	if af.ExprFilter == nil {
+		return
+	}
+
+	af.ExprFilter.TeamID = teamID
+	af.ExprFilter.From = af.From
+	af.ExprFilter.To = af.To
+}
+
+// HasExprFilter returns true if an expression
+// filter was requested.
+func (af *AppFilter) HasExprFilter() bool {
+	return af.ExprFilter != nil && af.ExprFilter.HasFilterExpr()
+}
+
 // HasUDExpression returns true if a user
 // defined expression was requested.
 func (af *AppFilter) HasUDExpression() bool {
@@ -654,6 +761,18 @@ func (af AppFilter) HasBugReportStatuses() bool {
 	return len(af.BugReportStatuses) > 0
 }
 
+// HasErrorGroupMerges returns true if at least
+// one error group is merged into another.
+func (af AppFilter) HasErrorGroupMerges() bool {
+	return len(af.MergedErrorGroupIDs) > 0
+}
+
+// HasErrorGroupStatuses returns true if at least
+// one error group status is requested.
+func (af AppFilter) HasErrorGroupStatuses() bool {
+	return len(af.ErrorGroupStatuses) > 0
+}
+
 // HasHttpMethods returns true if at least
 // one HTTP method is requested.
 func (af AppFilter) HasHttpMethods() bool {
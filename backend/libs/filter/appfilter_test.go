@@ -171,3 +171,27 @@ func TestAppFilterValidatePlotTimeGroupWithOtherFilters(t *testing.T) {
 		t.Fatalf("expected validation error for invalid plot_time_group with mixed filters")
 	}
 }
+
+func TestAppFilterValidateErrorGroupStatuses(t *testing.T) {
+	now := time.Now().UTC()
+
+	af := AppFilter{
+		AppID:              uuid.New(),
+		From:               now.Add(-time.Hour),
+		To:                 now,
+		Limit:              1,
+		ErrorGroupStatuses: []string{"open", "snoozed"},
+	}
+
+	if err := af.Validate(); err != nil {
+		t.Fatalf("expected status=open,snoozed to be valid, got %v", err)
+	}
+
+	af.ErrorGroupStatuses = []string{"open", "closed"}
+
+	if err := af.Validate(); err == nil {
+		t.Fatalf("expected invalid status to fail validation")
+	} else if !strings.Contains(err.Error(), "`status` must be any combination of:") {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+}
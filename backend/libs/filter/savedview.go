@@ -0,0 +1,440 @@
+package filter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"backend/libs/chrono"
+	"backend/libs/exprfilter"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// MaxSavedViewsPerApp is the maximum number of
+// saved views an app can have.
+const MaxSavedViewsPerApp = 200
+
+// maxSavedViewNameChars is the maximum length
+// of a saved view's name.
+const maxSavedViewNameChars = 256
+
+// ErrSavedViewNotFound is returned when a saved view
+// does not exist or is not visible to the user.
+var ErrSavedViewNotFound = errors.New("saved view not found")
+
+// ViewPage is the dashboard page a saved view opens.
+type ViewPage string
+
+const (
+	ViewPageOverview   ViewPage = "overview"
+	ViewPageCrashes    ViewPage = "crashes"
+	ViewPageANRs       ViewPage = "anrs"
+	ViewPageSessions   ViewPage = "sessions"
+	ViewPageTraces     ViewPage = "traces"
+	ViewPageBugReports ViewPage = "bug_reports"
+	ViewPageAlerts     ViewPage = "alerts"
+)
+
+// viewPageEntities maps each page to the filter entity
+// its filter expression is written against. Pages
+// without an entity cannot have a filter expression.
+var viewPageEntities = map[ViewPage]*exprfilter.Entity{
+	ViewPageOverview:   nil,
+	ViewPageCrashes:    &exprfilter.ErrorGroupsEntity,
+	ViewPageANRs:       &exprfilter.ErrorGroupsEntity,
+	ViewPageSessions:   &exprfilter.SessionsEntity,
+	ViewPageTraces:     &exprfilter.SpansEntity,
+	ViewPageBugReports: &exprfilter.BugReportsEntity,
+	ViewPageAlerts:     &exprfilter.AlertsEntity,
+}
+
+// TimeRange is the time range mode of a saved view.
+// Relative ranges end at the time the view is opened.
+type TimeRange string
+
+const (
+	TimeRangeLast15Mins  TimeRange = "last_15_mins"
+	TimeRangeLast30Mins  TimeRange = "last_30_mins"
+	TimeRangeLastHour    TimeRange = "last_hour"
+	TimeRangeLast3Hours  TimeRange = "last_3_hours"
+	TimeRangeLast6Hours  TimeRange = "last_6_hours"
+	TimeRangeLast12Hours TimeRange = "last_12_hours"
+	TimeRangeLast24Hours TimeRange = "last_24_hours"
+	TimeRangeLast7Days   TimeRange = "last_7_days"
+	TimeRangeLast15Days  TimeRange = "last_15_days"
+	TimeRangeLast30Days  TimeRange = "last_30_days"
+	TimeRangeLast3Months TimeRange = "last_3_months"
+	TimeRangeLast6Months TimeRange = "last_6_months"
+	TimeRangeLastYear    TimeRange = "last_year"
+	TimeRangeCustom      TimeRange = "custom"
+)
+
+// relativeTimeRanges maps each relative time
+// range to how far back it reaches.
+var relativeTimeRanges = map[TimeRange]time.Duration{
+	TimeRangeLast15Mins:  15 * time.Minute,
+	TimeRangeLast30Mins:  30 * time.Minute,
+	TimeRangeLastHour:    time.Hour,
+	TimeRangeLast3Hours:  3 * time.Hour,
+	TimeRangeLast6Hours:  6 * time.Hour,
+	TimeRangeLast12Hours: 12 * time.Hour,
+	TimeRangeLast24Hours: 24 * time.Hour,
+	TimeRangeLast7Days:   7 * 24 * time.Hour,
+	TimeRangeLast15Days:  15 * 24 * time.Hour,
+	TimeRangeLast30Days:  30 * 24 * time.Hour,
+	TimeRangeLast3Months: 90 * 24 * time.Hour,
+	TimeRangeLast6Months: 180 * 24 * time.Hour,
+	TimeRangeLastYear:    365 * 24 * time.Hour,
+}
+
+// ViewVisibility is who can see a saved view.
+type ViewVisibility string
+
+const (
+	// ViewVisibilityPrivate views are seen
+	// by their creator only.
+	ViewVisibilityPrivate ViewVisibility = "private"
+	// ViewVisibilityTeam views are seen by
+	// every member of the app's team.
+	ViewVisibilityTeam ViewVisibility = "team"
+)
+
+// SavedView is a named filter expression, time range
+// and page saved for an app's dashboard. Unlike short
+// filters, saved views are never purged.
+type SavedView struct {
+	ID         uuid.UUID
+	TeamID     uuid.UUID
+	AppID      uuid.UUID
+	Name       string
+	Page       ViewPage
+	FilterExpr string
+	TimeRange  TimeRange
+	From       *time.Time
+	To         *time.Time
+	Visibility ViewVisibility
+	IsDefault  bool
+	CreatedBy  *uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func (v SavedView) MarshalJSON() ([]byte, error) {
+	apiMap := make(map[string]any)
+	apiMap["id"] = v.ID
+	apiMap["app_id"] = v.AppID
+	apiMap["name"] = v.Name
+	apiMap["page"] = v.Page
+	apiMap["filter_expr"] = v.FilterExpr
+	apiMap["time_range"] = v.TimeRange
+	apiMap["from"] = nil
+	apiMap["to"] = nil
+	if v.From != nil {
+		apiMap["from"] = v.From.Format(chrono.ISOFormatJS)
+	}
+	if v.To != nil {
+		apiMap["to"] = v.To.Format(chrono.ISOFormatJS)
+	}
+	apiMap["visibility"] = v.Visibility
+	apiMap["is_default"] = v.IsDefault
+	apiMap["created_by"] = v.CreatedBy
+	apiMap["created_at"] = v.CreatedAt.Format(chrono.ISOFormatJS)
+	apiMap["updated_at"] = v.UpdatedAt.Format(chrono.ISOFormatJS)
+	return json.Marshal(apiMap)
+}
+
+// Validate validates the saved view.
+func (v SavedView) Validate() error {
+	if v.Name == "" {
+		return errors.New("`name` is required")
+	}
+
+	if len(v.Name) > maxSavedViewNameChars {
+		return fmt.Errorf("`name` must be at most %d characters", maxSavedViewNameChars)
+	}
+
+	entity, ok := viewPageEntities[v.Page]
+	if !ok {
+		return fmt.Errorf("`page` must be one of: %s, %s, %s, %s, %s, %s, %s", ViewPageOverview, ViewPageCrashes, ViewPageANRs, ViewPageSessions, ViewPageTraces, ViewPageBugReports, ViewPageAlerts)
+	}
+
+	if v.FilterExpr != "" {
+		if entity == nil {
+			return fmt.Errorf("`filter_expr` is not supported on the %s page", v.Page)
+		}
+
+		ef := &exprfilter.ExprFilter{
+			AppID:      v.AppID,
+			Entity:     *entity,
+			FilterExpr: v.FilterExpr,
+		}
+
+		if err := ef.BuildExprTree(); err != nil {
+			return fmt.Errorf("`filter_expr` is invalid: %w", err)
+		}
+
+		if err := ef.ValidateExprTree(); err != nil {
+			return fmt.Errorf("`filter_expr` is invalid: %w", err)
+		}
+	}
+
+	if v.TimeRange == TimeRangeCustom {
+		if v.From == nil || v.To == nil {
+			return errors.New("`from` and `to` are required for a custom time range")
+		}
+		if !v.From.Before(*v.To) {
+			return errors.New("`from` must be earlier than `to`")
+		}
+	} else if _, ok := relativeTimeRanges[v.TimeRange]; !ok {
+		return fmt.Errorf("`time_range` must be %s or a relative range such as %s", TimeRangeCustom, TimeRangeLast7Days)
+	} else if v.From != nil || v.To != nil {
+		return fmt.Errorf("`from` and `to` are only accepted for a %s time range", TimeRangeCustom)
+	}
+
+	if !slices.Contains([]ViewVisibility{ViewVisibilityPrivate, ViewVisibilityTeam}, v.Visibility) {
+		return fmt.Errorf("`visibility` must be one of: %s, %s", ViewVisibilityPrivate, ViewVisibilityTeam)
+	}
+
+	if v.IsDefault && v.Visibility != ViewVisibilityTeam {
+		return errors.New("only team views can be an app's default view")
+	}
+
+	return nil
+}
+
+// Range resolves the view's time range as of now.
+func (v SavedView) Range(now time.Time) (from, to time.Time) {
+	if v.TimeRange == TimeRangeCustom && v.From != nil && v.To != nil {
+		return *v.From, *v.To
+	}
+
+	return now.Add(-relativeTimeRanges[v.TimeRange]), now
+}
+
+// VisibleTo returns true if the user can see the view.
+func (v SavedView) VisibleTo(userID uuid.UUID) bool {
+	if v.Visibility == ViewVisibilityTeam {
+		return true
+	}
+
+	return v.IsCreatedBy(userID)
+}
+
+// IsCreatedBy returns true if the user created the view.
+func (v SavedView) IsCreatedBy(userID uuid.UUID) bool {
+	return v.CreatedBy != nil && *v.CreatedBy == userID
+}
+
+func savedViewsStmt() *sqlf.Stmt {
+	return sqlf.PostgreSQL.From("measure.saved_views").
+		Select("id").
+		Select("team_id").
+		Select("app_id").
+		Select("name").
+		Select("page").
+		Select("filter_expr").
+		Select("time_range").
+		Select("time_from").
+		Select("time_to").
+		Select("visibility").
+		Select("is_default").
+		Select("created_by").
+		Select("created_at").
+		Select("updated_at")
+}
+
+func scanSavedView(row pgx.Row) (v SavedView, err error) {
+	err = row.Scan(&v.ID, &v.TeamID, &v.AppID, &v.Name, &v.Page, &v.FilterExpr, &v.TimeRange, &v.From, &v.To, &v.Visibility, &v.IsDefault, &v.CreatedBy, &v.CreatedAt, &v.UpdatedAt)
+	return
+}
+
+// GetSavedViews fetches an app's saved views visible
+// to the user, optionally of one page only. Default
+// views come first, then views by name.
+func GetSavedViews(ctx context.Context, pg *pgxpool.Pool, appID, userID uuid.UUID, page ViewPage) (views []SavedView, err error) {
+	stmt := savedViewsStmt().
+		Where("app_id = ?", appID).
+		Where("(visibility = ? or created_by = ?)", ViewVisibilityTeam, userID).
+		OrderBy("is_default desc, lower(name), id")
+
+	defer stmt.Close()
+
+	if page != "" {
+		stmt.Where("page = ?", page)
+	}
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	views = []SavedView{}
+
+	for rows.Next() {
+		var v SavedView
+		if v, err = scanSavedView(rows); err != nil {
+			return
+		}
+		views = append(views, v)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// CountSavedViews counts all the saved views of an app.
+func CountSavedViews(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (count int, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.saved_views").
+		Select("count(*)").
+		Where("app_id = ?", appID)
+
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count)
+
+	return
+}
+
+// GetSavedView fetches a saved view of an app visible to
+// the user. Returns ErrSavedViewNotFound if there's no
+// such view or the user cannot see it.
+func GetSavedView(ctx context.Context, pg *pgxpool.Pool, appID, viewID, userID uuid.UUID) (view SavedView, err error) {
+	stmt := savedViewsStmt().
+		Where("app_id = ?", appID).
+		Where("id = ?", viewID)
+
+	defer stmt.Close()
+
+	view, err = scanSavedView(pg.QueryRow(ctx, stmt.String(), stmt.Args()...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return SavedView{}, ErrSavedViewNotFound
+	}
+	if err != nil {
+		return
+	}
+
+	if !view.VisibleTo(userID) {
+		return SavedView{}, ErrSavedViewNotFound
+	}
+
+	return
+}
+
+// Insert persists a new saved view.
+func (v SavedView) Insert(ctx context.Context, pg *pgxpool.Pool) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("measure.saved_views").
+		Set("id", v.ID).
+		Set("team_id", v.TeamID).
+		Set("app_id", v.AppID).
+		Set("name", v.Name).
+		Set("page", v.Page).
+		Set("filter_expr", v.FilterExpr).
+		Set("time_range", v.TimeRange).
+		Set("time_from", v.From).
+		Set("time_to", v.To).
+		Set("visibility", v.Visibility).
+		Set("is_default", false).
+		Set("created_by", v.CreatedBy).
+		Set("created_at", v.CreatedAt).
+		Set("updated_at", v.UpdatedAt)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// Update persists the saved view's name, page, filter
+// expression, time range and visibility. A view made
+// private or moved to another page stops being its
+// page's default.
+func (v SavedView) Update(ctx context.Context, pg *pgxpool.Pool) (err error) {
+	stmt := sqlf.PostgreSQL.Update("measure.saved_views").
+		Set("name", v.Name).
+		Set("page", v.Page).
+		Set("filter_expr", v.FilterExpr).
+		Set("time_range", v.TimeRange).
+		Set("time_from", v.From).
+		Set("time_to", v.To).
+		Set("visibility", v.Visibility).
+		Set("is_default", v.IsDefault).
+		Set("updated_at", v.UpdatedAt).
+		Where("app_id = ?", v.AppID).
+		Where("id = ?", v.ID)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// DeleteSavedView deletes a saved view of an app.
+func DeleteSavedView(ctx context.Context, pg *pgxpool.Pool, appID, viewID uuid.UUID) (err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("measure.saved_views").
+		Where("app_id = ?", appID).
+		Where("id = ?", viewID)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// SetDefaultSavedView pins the view as the default of
+// its page, unpinning the page's previous default. A nil
+// view id unpins the page's default without pinning
+// another.
+func SetDefaultSavedView(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, page ViewPage, viewID *uuid.UUID) (err error) {
+	tx, err := pg.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	now := time.Now()
+
+	unpinStmt := sqlf.PostgreSQL.Update("measure.saved_views").
+		Set("is_default", false).
+		Set("updated_at", now).
+		Where("app_id = ?", appID).
+		Where("page = ?", page).
+		Where("is_default")
+
+	defer unpinStmt.Close()
+
+	if _, err = tx.Exec(ctx, unpinStmt.String(), unpinStmt.Args()...); err != nil {
+		return
+	}
+
+	if viewID != nil {
+		pinStmt := sqlf.PostgreSQL.Update("measure.saved_views").
+			Set("is_default", true).
+			Set("updated_at", now).
+			Where("app_id = ?", appID).
+			Where("id = ?", *viewID)
+
+		defer pinStmt.Close()
+
+		if _, err = tx.Exec(ctx, pinStmt.String(), pinStmt.Args()...); err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+
+	return
+}
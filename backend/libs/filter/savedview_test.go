@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSavedViewValidate(t *testing.T) {
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	valid := SavedView{
+		Name:       "Crashes on Android 14",
+		Page:       ViewPageCrashes,
+		FilterExpr: "os_version:in:14",
+		TimeRange:  TimeRangeLast7Days,
+		Visibility: ViewVisibilityPrivate,
+	}
+
+	tests := []struct {
+		name    string
+		change  func(v *SavedView)
+		wantErr string
+	}{
+		{
+			name:   "a relative range with a filter",
+			change: func(v *SavedView) {},
+		},
+		{
+			name: "a custom range with its bounds",
+			change: func(v *SavedView) {
+				v.TimeRange = TimeRangeCustom
+				v.From, v.To = &from, &to
+			},
+		},
+		{
+			name: "a pinned team view",
+			change: func(v *SavedView) {
+				v.Visibility = ViewVisibilityTeam
+				v.IsDefault = true
+			},
+		},
+		{
+			name:    "a missing name",
+			change:  func(v *SavedView) { v.Name = "" },
+			wantErr: "`name` is required",
+		},
+		{
+			name:    "an unknown page",
+			change:  func(v *SavedView) { v.Page = "settings" },
+			wantErr: "`page` must be one of",
+		},
+		{
+			name:    "a filter on a page without filters",
+			change:  func(v *SavedView) { v.Page = ViewPageOverview },
+			wantErr: "not supported on the overview page",
+		},
+		{
+			name:    "a filter with a key the page does not offer",
+			change:  func(v *SavedView) { v.Page = ViewPageAlerts },
+			wantErr: "`filter_expr` is invalid",
+		},
+		{
+			name:    "an unknown time range",
+			change:  func(v *SavedView) { v.TimeRange = "last_decade" },
+			wantErr: "`time_range` must be",
+		},
+		{
+			name:    "a custom range without its bounds",
+			change:  func(v *SavedView) { v.TimeRange = TimeRangeCustom },
+			wantErr: "`from` and `to` are required",
+		},
+		{
+			name: "a custom range ending before it starts",
+			change: func(v *SavedView) {
+				v.TimeRange = TimeRangeCustom
+				v.From, v.To = &to, &from
+			},
+			wantErr: "`from` must be earlier than `to`",
+		},
+		{
+			name:    "bounds on a relative range",
+			change:  func(v *SavedView) { v.From, v.To = &from, &to },
+			wantErr: "only accepted for a custom time range",
+		},
+		{
+			name:    "a pinned private view",
+			change:  func(v *SavedView) { v.IsDefault = true },
+			wantErr: "only team views",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			view := valid
+			test.change(&view)
+
+			err := view.Validate()
+			if test.wantErr == "" {
+				if err != nil {
+					t.Errorf("want no error, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("want error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSavedViewRange(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	view := SavedView{TimeRange: TimeRangeLast7Days}
+	from, to := view.Range(now)
+	if !to.Equal(now) || !from.Equal(now.AddDate(0, 0, -7)) {
+		t.Errorf("want the last 7 days up to now, got %v to %v", from, to)
+	}
+
+	customFrom, customTo := now.AddDate(0, -1, 0), now.AddDate(0, 0, -20)
+	view = SavedView{TimeRange: TimeRangeCustom, From: &customFrom, To: &customTo}
+	from, to = view.Range(now)
+	if !from.Equal(customFrom) || !to.Equal(customTo) {
+		t.Errorf("want the custom bounds, got %v to %v", from, to)
+	}
+}
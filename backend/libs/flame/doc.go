@@ -0,0 +1,20 @@
+// Package flame aggregates CPU profiles into flamegraphs.
+//
+// Uploaded profiles are parsed with [Parse] into a [Profile], its samples
+// merged by thread and stack so that a profile stores each distinct stack
+// once along with the time spent on it. Supported formats are Android
+// method traces, as written by Debug.startMethodTracing, and pprof.
+//
+// Stacks of many profiles are read back with [GetStacks] and merged into a
+// [Node] tree with [Merge], the flamegraph. [Diff] compares the flamegraphs
+// of two sets of profiles, say two app versions, by each node's share of
+// the total sampled time so that profiles of differing lengths compare.
+//
+//	stacks, profiles, err := flame.GetStacks(ctx, rch, teamID, appID, flame.Query{
+//	    From:        from,
+//	    To:          to,
+//	    Version:     "1.2.0",
+//	    VersionCode: "120",
+//	})
+//	root := flame.Merge(stacks)
+package flame
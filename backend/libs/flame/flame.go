@@ -0,0 +1,348 @@
+package flame
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/libs/numeric"
+)
+
+// Profile formats, matching the type of
+// the profile event's attachment.
+const (
+	// FormatAndroidMethodTrace is an Android method
+	// trace, as written by Debug.startMethodTracing.
+	FormatAndroidMethodTrace = "android_method_trace"
+
+	// FormatPprof is a gzipped pprof protobuf.
+	FormatPprof = "pprof"
+)
+
+// rootName is the name of the
+// flamegraph's root node.
+const rootName = "root"
+
+// ErrUnsupportedFormat is returned when parsing
+// a profile of a format that isn't supported.
+var ErrUnsupportedFormat = errors.New("unsupported profile format")
+
+// Supported reports whether profiles of
+// format can be parsed.
+func Supported(format string) bool {
+	return format == FormatAndroidMethodTrace || format == FormatPprof
+}
+
+// Parse parses a profile of the given format.
+func Parse(format string, data []byte) (p Profile, err error) {
+	switch format {
+	case FormatAndroidMethodTrace:
+		return ParseMethodTrace(data)
+	case FormatPprof:
+		return ParsePprof(data)
+	}
+
+	err = fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+	return
+}
+
+// Frame is a function on a sampled stack.
+type Frame struct {
+	// ClassName is the function's class, empty
+	// for functions outside of classes.
+	ClassName string
+	// MethodName is the function's name.
+	MethodName string
+	// FileName is the function's source file,
+	// empty if unknown.
+	FileName string
+}
+
+// String formats the frame like
+// "com.example.Foo.bar".
+func (f Frame) String() string {
+	if f.ClassName == "" {
+		return f.MethodName
+	}
+	return f.ClassName + "." + f.MethodName
+}
+
+// Sample is the time a thread spent on a stack.
+type Sample struct {
+	// ThreadName is the name of the sampled
+	// thread, empty if unknown.
+	ThreadName string
+	// Stack is the sampled stack,
+	// outermost frame first.
+	Stack []Frame
+	// Weight is the time spent on the
+	// stack in nanoseconds.
+	Weight uint64
+	// Count is the number of times the
+	// stack was sampled.
+	Count uint64
+}
+
+// Profile is a parsed CPU profile, its samples
+// merged by thread and stack.
+type Profile struct {
+	// Start is when profiling started, zero if
+	// the format doesn't record it.
+	Start time.Time
+	// Duration is how long profiling
+	// ran, 0 if unknown.
+	Duration time.Duration
+	// Samples are the profile's samples,
+	// one per thread and stack.
+	Samples []Sample
+}
+
+// Frames provides each distinct frame
+// of the profile's samples.
+func (p Profile) Frames() (frames []Frame) {
+	seen := map[Frame]struct{}{}
+	for _, s := range p.Samples {
+		for _, f := range s.Stack {
+			if _, ok := seen[f]; ok {
+				continue
+			}
+			seen[f] = struct{}{}
+			frames = append(frames, f)
+		}
+	}
+	return
+}
+
+// Rewrite replaces each frame found in rewrites with
+// its frames, outermost first, like when symbolication
+// unfurls an inlined frame into many. Samples whose
+// stacks end up alike are merged.
+func (p *Profile) Rewrite(rewrites map[Frame][]Frame) {
+	if len(rewrites) == 0 {
+		return
+	}
+
+	var m merger
+	for _, s := range p.Samples {
+		stack := make([]Frame, 0, len(s.Stack))
+		for _, f := range s.Stack {
+			if r, ok := rewrites[f]; ok {
+				stack = append(stack, r...)
+				continue
+			}
+			stack = append(stack, f)
+		}
+		m.add(s.ThreadName, stack, s.Weight, s.Count)
+	}
+
+	p.Samples = m.samples
+}
+
+// merger merges samples by thread and stack.
+type merger struct {
+	samples []Sample
+	index   map[string]int
+}
+
+// add adds weight and count to the sample of
+// the thread and stack, creating it if new.
+func (m *merger) add(threadName string, stack []Frame, weight, count uint64) {
+	if m.index == nil {
+		m.index = map[string]int{}
+	}
+
+	var b strings.Builder
+	b.WriteString(threadName)
+	for _, f := range stack {
+		b.WriteByte(0)
+		b.WriteString(f.ClassName)
+		b.WriteByte(1)
+		b.WriteString(f.MethodName)
+		b.WriteByte(1)
+		b.WriteString(f.FileName)
+	}
+	key := b.String()
+
+	if i, ok := m.index[key]; ok {
+		m.samples[i].Weight += weight
+		m.samples[i].Count += count
+		return
+	}
+
+	m.index[key] = len(m.samples)
+	m.samples = append(m.samples, Sample{
+		ThreadName: threadName,
+		Stack:      stack,
+		Weight:     weight,
+		Count:      count,
+	})
+}
+
+// Stack is a stack merged across profiles.
+type Stack struct {
+	// Frames are the stack's frames,
+	// outermost first.
+	Frames []string
+	// Weight is the time spent on the
+	// stack in nanoseconds.
+	Weight uint64
+	// Count is the number of times the
+	// stack was sampled.
+	Count uint64
+}
+
+// Node is a function in a flamegraph.
+type Node struct {
+	// Name is the function's name.
+	Name string `json:"name"`
+	// Self is the time spent in the function
+	// itself, in nanoseconds.
+	Self uint64 `json:"self"`
+	// Total is the time spent in the function
+	// and its callees, in nanoseconds.
+	Total uint64 `json:"total"`
+	// Children are the function's
+	// callees, sorted by name.
+	Children []*Node `json:"children"`
+}
+
+// child provides the node's child named
+// name, adding it if missing.
+func (n *Node) child(name string) *Node {
+	i, found := slices.BinarySearchFunc(n.Children, name, func(c *Node, name string) int {
+		return strings.Compare(c.Name, name)
+	})
+	if found {
+		return n.Children[i]
+	}
+
+	c := &Node{Name: name, Children: []*Node{}}
+	n.Children = slices.Insert(n.Children, i, c)
+	return c
+}
+
+// Merge merges the stacks into a flamegraph. The root
+// node spans all stacks.
+func Merge(stacks []Stack) (root *Node) {
+	root = &Node{Name: rootName, Children: []*Node{}}
+
+	for _, s := range stacks {
+		node := root
+		node.Total += s.Weight
+		for _, name := range s.Frames {
+			node = node.child(name)
+			node.Total += s.Weight
+		}
+		node.Self += s.Weight
+	}
+
+	return
+}
+
+// DiffNode is a function in the
+// difference of two flamegraphs.
+type DiffNode struct {
+	// Name is the function's name.
+	Name string `json:"name"`
+	// BaseTotal is the time spent in the function and its
+	// callees in the base flamegraph, in nanoseconds.
+	BaseTotal uint64 `json:"base_total"`
+	// TargetTotal is the time spent in the function and its
+	// callees in the target flamegraph, in nanoseconds.
+	TargetTotal uint64 `json:"target_total"`
+	// BaseShare is the percent of the base
+	// flamegraph's time spent in the function.
+	BaseShare float64 `json:"base_share"`
+	// TargetShare is the percent of the target
+	// flamegraph's time spent in the function.
+	TargetShare float64 `json:"target_share"`
+	// Delta is the change in share from base to
+	// target, in percentage points.
+	Delta float64 `json:"delta"`
+	// Children are the function's
+	// callees, sorted by name.
+	Children []*DiffNode `json:"children"`
+}
+
+// Diff compares the target flamegraph against the base
+// flamegraph. Functions are compared by their share of
+// each flamegraph's total time, so that flamegraphs
+// merged from differing numbers of profiles compare.
+// Functions missing from one side have a share of 0.
+func Diff(base, target *Node) *DiffNode {
+	if base == nil {
+		base = Merge(nil)
+	}
+	if target == nil {
+		target = Merge(nil)
+	}
+
+	return diff(base, target, base.Total, target.Total)
+}
+
+func diff(base, target *Node, baseTotal, targetTotal uint64) *DiffNode {
+	name := rootName
+	if base != nil {
+		name = base.Name
+	} else if target != nil {
+		name = target.Name
+	}
+
+	d := &DiffNode{Name: name, Children: []*DiffNode{}}
+
+	var baseChildren, targetChildren []*Node
+	if base != nil {
+		d.BaseTotal = base.Total
+		baseChildren = base.Children
+	}
+	if target != nil {
+		d.TargetTotal = target.Total
+		targetChildren = target.Children
+	}
+
+	d.BaseShare = share(d.BaseTotal, baseTotal)
+	d.TargetShare = share(d.TargetTotal, targetTotal)
+	d.Delta = numeric.RoundTwoDecimalsFloat64(d.TargetShare - d.BaseShare)
+
+	// children are sorted by name, walk
+	// both sides like a merge join
+	i, j := 0, 0
+	for i < len(baseChildren) || j < len(targetChildren) {
+		var b, t *Node
+		switch {
+		case j >= len(targetChildren):
+			b = baseChildren[i]
+			i++
+		case i >= len(baseChildren):
+			t = targetChildren[j]
+			j++
+		default:
+			switch c := strings.Compare(baseChildren[i].Name, targetChildren[j].Name); {
+			case c < 0:
+				b = baseChildren[i]
+				i++
+			case c > 0:
+				t = targetChildren[j]
+				j++
+			default:
+				b, t = baseChildren[i], targetChildren[j]
+				i++
+				j++
+			}
+		}
+		d.Children = append(d.Children, diff(b, t, baseTotal, targetTotal))
+	}
+
+	return d
+}
+
+// share computes the percent of
+// total that part makes up.
+func share(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return numeric.RoundTwoDecimalsFloat64(float64(part) * 100 / float64(total))
+}
@@ -0,0 +1,151 @@
+package flame
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseUnsupported(t *testing.T) {
+	if _, err := Parse("perfetto_trace", nil); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("err = %v, want ErrUnsupportedFormat", err)
+	}
+	if Supported("heap_dump") {
+		t.Error("Supported(heap_dump) = true, want false")
+	}
+	if !Supported(FormatPprof) || !Supported(FormatAndroidMethodTrace) {
+		t.Error("Supported = false for a supported format, want true")
+	}
+}
+
+func TestFrameString(t *testing.T) {
+	tests := map[Frame]string{
+		{ClassName: "com.example.Foo", MethodName: "bar"}: "com.example.Foo.bar",
+		{MethodName: "runtime.main"}:                      "runtime.main",
+	}
+
+	for f, want := range tests {
+		if got := f.String(); got != want {
+			t.Errorf("%+v.String() = %q, want %q", f, got, want)
+		}
+	}
+}
+
+func TestProfileRewrite(t *testing.T) {
+	a := Frame{ClassName: "a.a", MethodName: "a"}
+	b := Frame{ClassName: "a.b", MethodName: "b"}
+	main := Frame{ClassName: "com.example.Main", MethodName: "main"}
+	run := Frame{ClassName: "com.example.Main", MethodName: "run"}
+	inlined := Frame{ClassName: "com.example.Util", MethodName: "work"}
+
+	p := Profile{
+		Samples: []Sample{
+			{ThreadName: "main", Stack: []Frame{a, b}, Weight: 10, Count: 1},
+			{ThreadName: "main", Stack: []Frame{main, run, inlined}, Weight: 5, Count: 2},
+			{ThreadName: "worker", Stack: []Frame{a, b}, Weight: 3, Count: 1},
+		},
+	}
+
+	if got := p.Frames(); len(got) != 5 {
+		t.Errorf("Frames() = %d frames, want 5", len(got))
+	}
+
+	p.Rewrite(map[Frame][]Frame{
+		a: {main},
+		b: {run, inlined},
+	})
+
+	want := []Sample{
+		{ThreadName: "main", Stack: []Frame{main, run, inlined}, Weight: 15, Count: 3},
+		{ThreadName: "worker", Stack: []Frame{main, run, inlined}, Weight: 3, Count: 1},
+	}
+	if !reflect.DeepEqual(p.Samples, want) {
+		t.Errorf("Samples = %+v, want %+v", p.Samples, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	root := Merge([]Stack{
+		{Frames: []string{"main", "b"}, Weight: 30},
+		{Frames: []string{"main", "a"}, Weight: 20},
+		{Frames: []string{"main"}, Weight: 10},
+		{Frames: []string{"main", "a", "c"}, Weight: 5},
+	})
+
+	if root.Name != "root" || root.Total != 65 || root.Self != 0 {
+		t.Fatalf("root = %s %d/%d, want root 65/0", root.Name, root.Total, root.Self)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("root children = %d, want 1", len(root.Children))
+	}
+
+	main := root.Children[0]
+	if main.Name != "main" || main.Total != 65 || main.Self != 10 {
+		t.Errorf("main = %s %d/%d, want main 65/10", main.Name, main.Total, main.Self)
+	}
+
+	var names []string
+	for _, c := range main.Children {
+		names = append(names, c.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Errorf("main children = %v, want [a b]", names)
+	}
+
+	a := main.Children[0]
+	if a.Total != 25 || a.Self != 20 || len(a.Children) != 1 || a.Children[0].Total != 5 {
+		t.Errorf("a = %d/%d with %d children, want 25/20 with c of 5", a.Total, a.Self, len(a.Children))
+	}
+}
+
+func TestDiff(t *testing.T) {
+	base := Merge([]Stack{
+		{Frames: []string{"main", "a"}, Weight: 50},
+		{Frames: []string{"main", "b"}, Weight: 50},
+	})
+	// twice the samples, so shares not
+	// totals are compared
+	target := Merge([]Stack{
+		{Frames: []string{"main", "a"}, Weight: 150},
+		{Frames: []string{"main", "c"}, Weight: 50},
+	})
+
+	d := Diff(base, target)
+
+	if d.BaseShare != 100 || d.TargetShare != 100 || d.Delta != 0 {
+		t.Errorf("root = %+v, want 100%% on both sides", d)
+	}
+
+	main := d.Children[0]
+	if len(main.Children) != 3 {
+		t.Fatalf("main children = %d, want 3", len(main.Children))
+	}
+
+	want := []struct {
+		name                string
+		baseShare, tgtShare float64
+		delta               float64
+	}{
+		{"a", 50, 75, 25},
+		{"b", 50, 0, -50},
+		{"c", 0, 25, 25},
+	}
+	for i, w := range want {
+		c := main.Children[i]
+		if c.Name != w.name || c.BaseShare != w.baseShare || c.TargetShare != w.tgtShare || c.Delta != w.delta {
+			t.Errorf("child %d = %s %v -> %v (%v), want %s %v -> %v (%v)", i, c.Name, c.BaseShare, c.TargetShare, c.Delta, w.name, w.baseShare, w.tgtShare, w.delta)
+		}
+	}
+
+	if b := main.Children[1]; b.BaseTotal != 50 || b.TargetTotal != 0 {
+		t.Errorf("b totals = %d -> %d, want 50 -> 0", b.BaseTotal, b.TargetTotal)
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	d := Diff(nil, Merge([]Stack{{Frames: []string{"main"}, Weight: 10}}))
+
+	if d.BaseTotal != 0 || d.TargetShare != 100 || len(d.Children) != 1 || d.Children[0].Delta != 100 {
+		t.Errorf("diff = %+v, want main new in target", d)
+	}
+}
@@ -0,0 +1,218 @@
+package flame
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// methodTraceMagic prefixes the binary
+// section of a method trace, "SLOW".
+const methodTraceMagic = 0x574f4c53
+
+// method trace actions, stored in the low
+// 2 bits of a record's method id.
+const (
+	actionEnter  = 0
+	actionExit   = 1
+	actionUnroll = 2
+)
+
+// methodTraceThread is a traced thread's state
+// while replaying the trace's records.
+type methodTraceThread struct {
+	// stack holds the ids of the entered
+	// methods, outermost first.
+	stack []uint32
+	// last is the time of the thread's
+	// previous record in microseconds.
+	last uint32
+	// seen is true once the thread
+	// had a record.
+	seen bool
+}
+
+// ParseMethodTrace parses an Android method trace, as written by
+// Debug.startMethodTracing. Time is attributed from thread CPU
+// time when the trace recorded it, wall time otherwise.
+//
+// Streaming method traces, which lack the leading text header,
+// aren't supported.
+func ParseMethodTrace(data []byte) (p Profile, err error) {
+	header, body, found := bytes.Cut(data, []byte("*end\n"))
+	if !found || !bytes.HasPrefix(header, []byte("*version\n")) {
+		err = errors.New("method trace: missing header, streaming traces aren't supported")
+		return
+	}
+
+	var (
+		clock   string
+		threads = map[uint16]string{}
+		methods = map[uint32]Frame{}
+		section string
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(header))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "*") {
+			section = line
+			continue
+		}
+
+		switch section {
+		case "*version":
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "clock":
+				clock = value
+			case "elapsed-time-usec":
+				if usec, errParse := strconv.ParseInt(value, 10, 64); errParse == nil {
+					p.Duration = time.Duration(usec) * time.Microsecond
+				}
+			}
+		case "*threads":
+			id, name, ok := strings.Cut(line, "\t")
+			if !ok {
+				continue
+			}
+			if tid, errParse := strconv.ParseUint(id, 10, 16); errParse == nil {
+				threads[uint16(tid)] = name
+			}
+		case "*methods":
+			fields := strings.Split(line, "\t")
+			if len(fields) < 3 {
+				continue
+			}
+			id, errParse := strconv.ParseUint(strings.TrimPrefix(fields[0], "0x"), 16, 32)
+			if errParse != nil {
+				continue
+			}
+			f := Frame{
+				// older runtimes write class
+				// descriptors with slashes
+				ClassName:  strings.ReplaceAll(fields[1], "/", "."),
+				MethodName: fields[2],
+			}
+			if len(fields) > 4 {
+				f.FileName = fields[4]
+			}
+			methods[uint32(id)] = f
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		err = fmt.Errorf("method trace: %w", err)
+		return
+	}
+
+	if len(body) < 16 || binary.LittleEndian.Uint32(body) != methodTraceMagic {
+		err = errors.New("method trace: invalid binary section")
+		return
+	}
+
+	version := binary.LittleEndian.Uint16(body[4:])
+	offset := int(binary.LittleEndian.Uint16(body[6:]))
+	p.Start = time.UnixMicro(int64(binary.LittleEndian.Uint64(body[8:]))).UTC()
+
+	dual := clock == "dual"
+
+	// record layout: thread id, method id and
+	// action, then one or two clock deltas
+	tidSize := 2
+	recordSize := 10
+	switch version {
+	case 1:
+		tidSize = 1
+		recordSize = 9
+	case 2:
+		if dual {
+			recordSize = 14
+		}
+	case 3:
+		if len(body) < 18 {
+			err = errors.New("method trace: invalid binary section")
+			return
+		}
+		recordSize = int(binary.LittleEndian.Uint16(body[16:]))
+	default:
+		err = fmt.Errorf("method trace: unsupported version %d", version)
+		return
+	}
+
+	minRecordSize := tidSize + 8
+	if dual {
+		minRecordSize += 4
+	}
+	if recordSize < minRecordSize || offset > len(body) {
+		err = errors.New("method trace: invalid binary section")
+		return
+	}
+
+	var m merger
+	states := map[uint16]*methodTraceThread{}
+
+	frame := func(id uint32) Frame {
+		if f, ok := methods[id]; ok {
+			return f
+		}
+		return Frame{MethodName: fmt.Sprintf("0x%x", id)}
+	}
+
+	for rec := body[offset:]; len(rec) >= recordSize; rec = rec[recordSize:] {
+		var tid uint16
+		if tidSize == 1 {
+			tid = uint16(rec[0])
+		} else {
+			tid = binary.LittleEndian.Uint16(rec)
+		}
+		value := binary.LittleEndian.Uint32(rec[tidSize:])
+
+		// dual clock records carry thread cpu
+		// time first, then wall time
+		t := binary.LittleEndian.Uint32(rec[tidSize+4:])
+
+		state, ok := states[tid]
+		if !ok {
+			state = &methodTraceThread{}
+			states[tid] = state
+		}
+
+		if state.seen && len(state.stack) > 0 && t > state.last {
+			stack := make([]Frame, len(state.stack))
+			for i, id := range state.stack {
+				stack[i] = frame(id)
+			}
+			m.add(threads[tid], stack, uint64(t-state.last)*uint64(time.Microsecond), 1)
+		}
+		state.seen = true
+		state.last = t
+
+		id := value &^ 3
+		switch value & 3 {
+		case actionEnter:
+			state.stack = append(state.stack, id)
+		case actionExit, actionUnroll:
+			// methods entered before tracing
+			// started exit on an empty stack
+			for i := len(state.stack) - 1; i >= 0; i-- {
+				if state.stack[i] == id {
+					state.stack = state.stack[:i]
+					break
+				}
+			}
+		}
+	}
+
+	p.Samples = m.samples
+
+	return
+}
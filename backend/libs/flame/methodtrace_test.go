@@ -0,0 +1,118 @@
+package flame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// methodTraceRecord is a record of
+// a dual clock, version 3 trace.
+type methodTraceRecord struct {
+	tid    uint16
+	method uint32
+	action uint32
+	cpu    uint32
+	wall   uint32
+}
+
+func makeMethodTrace(header string, start time.Time, records []methodTraceRecord) []byte {
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.WriteString("*end\n")
+
+	const headerSize = 32
+	const recordSize = 14
+
+	bin := make([]byte, headerSize)
+	binary.LittleEndian.PutUint32(bin, methodTraceMagic)
+	binary.LittleEndian.PutUint16(bin[4:], 3)
+	binary.LittleEndian.PutUint16(bin[6:], headerSize)
+	binary.LittleEndian.PutUint64(bin[8:], uint64(start.UnixMicro()))
+	binary.LittleEndian.PutUint16(bin[16:], recordSize)
+	b.Write(bin)
+
+	for _, r := range records {
+		rec := make([]byte, recordSize)
+		binary.LittleEndian.PutUint16(rec, r.tid)
+		binary.LittleEndian.PutUint32(rec[2:], r.method|r.action)
+		binary.LittleEndian.PutUint32(rec[6:], r.cpu)
+		binary.LittleEndian.PutUint32(rec[10:], r.wall)
+		b.Write(rec)
+	}
+
+	return b.Bytes()
+}
+
+func TestParseMethodTrace(t *testing.T) {
+	header := strings.Join([]string{
+		"*version",
+		"3",
+		"data-file-overflow=false",
+		"clock=dual",
+		"elapsed-time-usec=2000",
+		"vm=art",
+		"*threads",
+		"1\tmain",
+		"2\tworker",
+		"*methods",
+		"0x1000\tcom/example/Main\tmain\t()V\tMain.java",
+		"0x1004\tcom.example.a\tb\t(I)V\tSourceFile",
+		"0x1008\tcom.example.Worker\trun\t()V\tWorker.java",
+		"",
+	}, "\n")
+
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	data := makeMethodTrace(header, start, []methodTraceRecord{
+		{1, 0x1000, actionEnter, 0, 0},
+		{1, 0x1004, actionEnter, 100, 150},
+		{2, 0x1008, actionEnter, 0, 0},
+		{1, 0x1004, actionExit, 400, 500},
+		{2, 0x1008, actionExit, 50, 60},
+		// exit of a method entered before
+		// tracing started
+		{1, 0x100c, actionExit, 450, 550},
+		{1, 0x1000, actionExit, 500, 600},
+	})
+
+	p, err := ParseMethodTrace(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Start.Equal(start) || p.Duration != 2*time.Millisecond {
+		t.Errorf("start, duration = %s, %s, want %s, 2ms", p.Start, p.Duration, start)
+	}
+
+	main := Frame{ClassName: "com.example.Main", MethodName: "main", FileName: "Main.java"}
+	b := Frame{ClassName: "com.example.a", MethodName: "b", FileName: "SourceFile"}
+	run := Frame{ClassName: "com.example.Worker", MethodName: "run", FileName: "Worker.java"}
+
+	want := []Sample{
+		{ThreadName: "main", Stack: []Frame{main}, Weight: 200 * uint64(time.Microsecond), Count: 3},
+		{ThreadName: "main", Stack: []Frame{main, b}, Weight: 300 * uint64(time.Microsecond), Count: 1},
+		{ThreadName: "worker", Stack: []Frame{run}, Weight: 50 * uint64(time.Microsecond), Count: 1},
+	}
+	if !reflect.DeepEqual(p.Samples, want) {
+		t.Errorf("Samples = %+v, want %+v", p.Samples, want)
+	}
+}
+
+func TestParseMethodTraceInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"streaming": {0x53, 0x4c, 0x4f, 0x57, 0xf3, 0x00},
+		"no_binary": []byte("*version\n3\n*end\n"),
+		"bad_magic": append([]byte("*version\n3\n*end\n"), make([]byte, 32)...),
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseMethodTrace(data); err == nil {
+				t.Error("err = nil, want error")
+			}
+		})
+	}
+}
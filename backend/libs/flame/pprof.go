@@ -0,0 +1,157 @@
+package flame
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+// pprofThreadLabels are the sample labels
+// naming the sampled thread.
+var pprofThreadLabels = []string{"thread", "thread_name"}
+
+// ParsePprof parses a pprof profile, gzipped or not. Time is
+// read from the first sample type measured in time, preferring
+// "cpu", or else estimated from sample counts and the sampling
+// period.
+func ParsePprof(data []byte) (p Profile, err error) {
+	prof, err := profile.ParseData(data)
+	if err != nil {
+		err = fmt.Errorf("pprof: %w", err)
+		return
+	}
+
+	if len(prof.SampleType) == 0 {
+		err = fmt.Errorf("pprof: no sample types")
+		return
+	}
+
+	if prof.TimeNanos > 0 {
+		p.Start = time.Unix(0, prof.TimeNanos).UTC()
+	}
+	p.Duration = time.Duration(prof.DurationNanos)
+
+	weightIdx, weightScale := pprofWeight(prof)
+	countIdx := -1
+	for i, st := range prof.SampleType {
+		if st.Unit == "count" {
+			countIdx = i
+			break
+		}
+	}
+
+	var m merger
+	for _, s := range prof.Sample {
+		if weightIdx >= len(s.Value) || s.Value[weightIdx] <= 0 {
+			continue
+		}
+		weight := uint64(s.Value[weightIdx]) * weightScale
+
+		count := uint64(1)
+		if countIdx >= 0 && countIdx < len(s.Value) && s.Value[countIdx] > 0 {
+			count = uint64(s.Value[countIdx])
+		}
+
+		var threadName string
+		for _, label := range pprofThreadLabels {
+			if values := s.Label[label]; len(values) > 0 {
+				threadName = values[0]
+				break
+			}
+		}
+
+		// locations are innermost first, as are the
+		// lines of a location holding inlined calls
+		var stack []Frame
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			loc := s.Location[i]
+			if len(loc.Line) == 0 {
+				stack = append(stack, Frame{MethodName: fmt.Sprintf("0x%x", loc.Address)})
+				continue
+			}
+			for j := len(loc.Line) - 1; j >= 0; j-- {
+				line := loc.Line[j]
+				if line.Function == nil {
+					stack = append(stack, Frame{MethodName: fmt.Sprintf("0x%x", loc.Address)})
+					continue
+				}
+				f := splitFunctionName(line.Function.Name)
+				f.FileName = line.Function.Filename
+				stack = append(stack, f)
+			}
+		}
+
+		m.add(threadName, stack, weight, count)
+	}
+
+	p.Samples = m.samples
+
+	return
+}
+
+// pprofWeight picks the sample value to read time spent from
+// and the factor converting it to nanoseconds.
+func pprofWeight(prof *profile.Profile) (idx int, scale uint64) {
+	units := map[string]uint64{
+		"nanoseconds":  1,
+		"microseconds": uint64(time.Microsecond),
+		"milliseconds": uint64(time.Millisecond),
+		"seconds":      uint64(time.Second),
+	}
+
+	idx = -1
+	for i, st := range prof.SampleType {
+		if _, ok := units[st.Unit]; !ok {
+			continue
+		}
+		if idx < 0 || st.Type == "cpu" {
+			idx = i
+		}
+	}
+	if idx >= 0 {
+		return idx, units[prof.SampleType[idx].Unit]
+	}
+
+	// no time values, each sample
+	// stands for one period
+	idx = len(prof.SampleType) - 1
+	for i, st := range prof.SampleType {
+		if st.Type == prof.DefaultSampleType {
+			idx = i
+		}
+	}
+
+	scale = 1
+	if prof.PeriodType != nil && prof.Period > 0 {
+		if unit, ok := units[prof.PeriodType.Unit]; ok {
+			scale = uint64(prof.Period) * unit
+		}
+	}
+
+	return
+}
+
+// splitFunctionName splits a JVM style function name like
+// "com.example.Foo.bar" into its class and method, so the
+// class can be symbolicated. Other names are kept whole as
+// the method.
+func splitFunctionName(name string) Frame {
+	i := strings.LastIndexByte(name, '.')
+	if i <= 0 || i == len(name)-1 {
+		return Frame{MethodName: name}
+	}
+
+	class := name[:i]
+	for _, r := range class {
+		switch {
+		case r == '.' || r == '$' || r == '_':
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			return Frame{MethodName: name}
+		}
+	}
+
+	return Frame{ClassName: class, MethodName: name[i+1:]}
+}
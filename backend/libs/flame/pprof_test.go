@@ -0,0 +1,135 @@
+package flame
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+)
+
+func TestParsePprof(t *testing.T) {
+	start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+
+	mainFn := &profile.Function{ID: 1, Name: "com.example.Main.main", Filename: "Main.java"}
+	workFn := &profile.Function{ID: 2, Name: "com.example.a.b", Filename: "SourceFile"}
+	inlinedFn := &profile.Function{ID: 3, Name: "com.example.Util.work", Filename: "Util.java"}
+	nativeFn := &profile.Function{ID: 4, Name: "std::vector<int>::push_back", Filename: "vector"}
+
+	mainLoc := &profile.Location{ID: 1, Line: []profile.Line{{Function: mainFn}}}
+	// inlined calls are innermost first
+	workLoc := &profile.Location{ID: 2, Line: []profile.Line{{Function: inlinedFn}, {Function: workFn}}}
+	nativeLoc := &profile.Location{ID: 3, Line: []profile.Line{{Function: nativeFn}}}
+	addrLoc := &profile.Location{ID: 4, Address: 0xbeef}
+
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        10_000_000,
+		TimeNanos:     start.UnixNano(),
+		DurationNanos: int64(time.Second),
+		Function:      []*profile.Function{mainFn, workFn, inlinedFn, nativeFn},
+		Location:      []*profile.Location{mainLoc, workLoc, nativeLoc, addrLoc},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{workLoc, mainLoc}, Value: []int64{2, 20_000_000}, Label: map[string][]string{"thread": {"main"}}},
+			{Location: []*profile.Location{workLoc, mainLoc}, Value: []int64{1, 10_000_000}, Label: map[string][]string{"thread": {"main"}}},
+			{Location: []*profile.Location{addrLoc, nativeLoc}, Value: []int64{1, 10_000_000}},
+			{Location: []*profile.Location{mainLoc}, Value: []int64{1, 0}},
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := prof.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParsePprof(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Start.Equal(start) || p.Duration != time.Second {
+		t.Errorf("start, duration = %s, %s, want %s, 1s", p.Start, p.Duration, start)
+	}
+
+	want := []Sample{
+		{
+			ThreadName: "main",
+			Stack: []Frame{
+				{ClassName: "com.example.Main", MethodName: "main", FileName: "Main.java"},
+				{ClassName: "com.example.a", MethodName: "b", FileName: "SourceFile"},
+				{ClassName: "com.example.Util", MethodName: "work", FileName: "Util.java"},
+			},
+			Weight: 30_000_000,
+			Count:  3,
+		},
+		{
+			Stack: []Frame{
+				{MethodName: "std::vector<int>::push_back", FileName: "vector"},
+				{MethodName: "0xbeef"},
+			},
+			Weight: 10_000_000,
+			Count:  1,
+		},
+	}
+	if !reflect.DeepEqual(p.Samples, want) {
+		t.Errorf("Samples = %+v, want %+v", p.Samples, want)
+	}
+}
+
+func TestParsePprofCountsOnly(t *testing.T) {
+	fn := &profile.Function{ID: 1, Name: "main"}
+	loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "milliseconds"},
+		Period:     10,
+		Function:   []*profile.Function{fn},
+		Location:   []*profile.Location{loc},
+		Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{3}}},
+	}
+
+	var buf bytes.Buffer
+	if err := prof.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := ParsePprof(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Samples) != 1 || p.Samples[0].Weight != uint64(30*time.Millisecond) || p.Samples[0].Count != 3 {
+		t.Errorf("Samples = %+v, want 30ms over 3 samples", p.Samples)
+	}
+}
+
+func TestParsePprofInvalid(t *testing.T) {
+	if _, err := ParsePprof([]byte("not a profile")); err == nil {
+		t.Error("err = nil, want error")
+	}
+}
+
+func TestSplitFunctionName(t *testing.T) {
+	tests := map[string]Frame{
+		"com.example.Foo$Bar.baz":      {ClassName: "com.example.Foo$Bar", MethodName: "baz"},
+		"a.b":                          {ClassName: "a", MethodName: "b"},
+		"main":                         {MethodName: "main"},
+		"std::vector<int>::push_back":  {MethodName: "std::vector<int>::push_back"},
+		"main.(*Server).Serve":         {MethodName: "main.(*Server).Serve"},
+		"trailing.":                    {MethodName: "trailing."},
+		".leading":                     {MethodName: ".leading"},
+		"com.example.Foo.lambda$run$0": {ClassName: "com.example.Foo", MethodName: "lambda$run$0"},
+	}
+
+	for name, want := range tests {
+		if got := splitFunctionName(name); got != want {
+			t.Errorf("splitFunctionName(%q) = %+v, want %+v", name, got, want)
+		}
+	}
+}
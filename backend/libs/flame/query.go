@@ -0,0 +1,121 @@
+package flame
+
+import (
+	"context"
+	"time"
+
+	"backend/libs/chquery"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// maxStacks is the number of heaviest distinct
+// stacks a flamegraph is merged from.
+const maxStacks = 10_000
+
+// Query selects the profiles whose stacks are
+// merged into a flamegraph. Empty fields don't
+// filter.
+type Query struct {
+	// From and To bound the time
+	// profiles were taken at.
+	From time.Time
+	To   time.Time
+	// Version and VersionCode
+	// pick an app version.
+	Version     string
+	VersionCode string
+	// Screen picks profiles taken
+	// while the screen was shown.
+	Screen string
+	// SpanName picks profiles overlapping
+	// spans of the name.
+	SpanName string
+	// ThreadName picks the
+	// stacks of a thread.
+	ThreadName string
+	// Reason picks profiles taken
+	// for a reason, like "anr".
+	Reason string
+}
+
+// where applies the query's filters.
+func (q Query) where(stmt *sqlf.Stmt, teamID, appID uuid.UUID) {
+	stmt.
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= toDateTime64(?, 3, 'UTC')", q.From).
+		Where("timestamp <= toDateTime64(?, 3, 'UTC')", q.To)
+
+	if q.Version != "" && q.VersionCode != "" {
+		stmt.Where("app_version.1 = ? and app_version.2 = ?", q.Version, q.VersionCode)
+	}
+	if q.Screen != "" {
+		stmt.Where("screen = ?", q.Screen)
+	}
+	if q.SpanName != "" {
+		stmt.Where("has(span_names, ?)", q.SpanName)
+	}
+	if q.ThreadName != "" {
+		stmt.Where("thread_name = ?", q.ThreadName)
+	}
+	if q.Reason != "" {
+		stmt.Where("reason = ?", q.Reason)
+	}
+}
+
+// GetStacks fetches the stacks of the profiles the query
+// selects, merged across profiles, along with the number of
+// profiles. Only the heaviest stacks are fetched, enough for
+// the flamegraph's shape.
+func GetStacks(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, q Query) (stacks []Stack, profiles uint64, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	countStmt := sqlf.
+		From("profile_stacks").
+		Select("uniq(event_id)")
+	q.where(countStmt, teamID, appID)
+
+	defer countStmt.Close()
+
+	if err = rch.QueryRow(ctx, countStmt.String(), countStmt.Args()...).Scan(&profiles); err != nil {
+		return
+	}
+
+	if profiles == 0 {
+		return
+	}
+
+	stmt := sqlf.
+		From("profile_stacks final").
+		Select("stack").
+		Select("sum(weight) as total_weight").
+		Select("sum(samples) as total_samples").
+		GroupBy("stack").
+		OrderBy("total_weight desc").
+		Limit(maxStacks)
+	q.where(stmt, teamID, appID)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Stack
+		if err = rows.Scan(&s.Frames, &s.Weight, &s.Count); err != nil {
+			return
+		}
+		stacks = append(stacks, s)
+	}
+
+	err = rows.Err()
+
+	return
+}
@@ -0,0 +1,192 @@
+// Package funnel computes how sessions convert through an
+// ordered list of steps, like screens viewed & custom events
+// sent, and how many crash or hang along the way.
+package funnel
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"backend/libs/udattr"
+)
+
+// MinSteps & MaxSteps bound the
+// steps of a funnel.
+const (
+	MinSteps = 2
+	MaxSteps = 10
+)
+
+// maxStepNameChars is the maximum length
+// of a step's name, that of the longest
+// screen view name stored.
+const maxStepNameChars = 128
+
+// Kinds of funnel steps.
+const (
+	// KindScreenView matches screen view
+	// events by screen name.
+	KindScreenView = "screen_view"
+	// KindNavigation matches navigation
+	// events by destination.
+	KindNavigation = "navigation"
+	// KindCustom matches custom events by
+	// name & user defined attributes.
+	KindCustom = "custom"
+)
+
+// kinds are the valid kinds
+// of funnel steps.
+var kinds = []string{KindScreenView, KindNavigation, KindCustom}
+
+// Step is a step of a funnel.
+type Step struct {
+	// Kind is the kind of event
+	// matching the step.
+	Kind string `json:"kind"`
+	// Name is the screen name, navigation
+	// destination or custom event name.
+	Name string `json:"name"`
+	// Where are the user defined attribute
+	// comparisons a custom event must all
+	// satisfy.
+	Where []udattr.UDComparison `json:"where,omitempty"`
+}
+
+// Validate validates the step.
+func (s Step) Validate() error {
+	if !slices.Contains(kinds, s.Kind) {
+		return fmt.Errorf("step kind %q is not one of %v", s.Kind, kinds)
+	}
+	if s.Name == "" {
+		return errors.New("step name cannot be empty")
+	}
+	if len(s.Name) > maxStepNameChars {
+		return fmt.Errorf("step name exceeds maximum allowed characters of (%d)", maxStepNameChars)
+	}
+	if len(s.Where) > 0 && s.Kind != KindCustom {
+		return fmt.Errorf("only %q steps can have attribute comparisons", KindCustom)
+	}
+	for _, cmp := range s.Where {
+		if cmp.Empty() {
+			return errors.New("attribute comparison key cannot be empty")
+		}
+		if err := cmp.Validate(); err != nil {
+			return err
+		}
+		if _, err := comparisonValue(cmp); err != nil {
+			return fmt.Errorf("attribute %q: %w", cmp.Key, err)
+		}
+	}
+	return nil
+}
+
+// Funnel is an ordered list of steps sessions
+// convert through.
+type Funnel struct {
+	Steps []Step
+	// Window is the most time allowed from
+	// the first step to the last, zero for
+	// no limit.
+	Window time.Duration
+}
+
+// Validate validates the funnel.
+func (f Funnel) Validate() error {
+	if len(f.Steps) < MinSteps || len(f.Steps) > MaxSteps {
+		return fmt.Errorf("funnel must have between %d and %d steps", MinSteps, MaxSteps)
+	}
+	if f.Window < 0 {
+		return errors.New("funnel window cannot be negative")
+	}
+	for i, s := range f.Steps {
+		if err := s.Validate(); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// StepResult is how sessions
+// converted at a step.
+type StepResult struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Sessions is the count of sessions
+	// that reached the step.
+	Sessions uint64 `json:"sessions"`
+	// Conversion is the percentage of sessions
+	// entering the funnel that reached the step.
+	Conversion float64 `json:"conversion"`
+	// StepConversion is the percentage of sessions
+	// reaching the previous step that reached
+	// this one.
+	StepConversion float64 `json:"step_conversion"`
+	// DropOff is the count of sessions that reached
+	// the step, but not the next one.
+	DropOff uint64 `json:"drop_off"`
+	// MedianStepTime is the median time in
+	// milliseconds from the previous step.
+	MedianStepTime int64 `json:"median_step_time"`
+	// Crashes & ANRs are the count of sessions that
+	// crashed or hung after reaching the step,
+	// before reaching the next one.
+	Crashes uint64 `json:"crashes"`
+	ANRs    uint64 `json:"anrs"`
+}
+
+// counts are, for each step of a funnel,
+// how sessions converted at it.
+type counts struct {
+	// sessions are the counts of
+	// sessions reaching each step.
+	sessions []uint64
+	// stepTimes are the median times in
+	// milliseconds from the previous step.
+	stepTimes []int64
+	// crashes & anrs are the counts of sessions
+	// that crashed or hung after reaching each
+	// step, before reaching the next one.
+	crashes []uint64
+	anrs    []uint64
+}
+
+// results computes the funnel's
+// step results from its counts.
+func (f Funnel) results(c counts) (results []StepResult) {
+	n := len(f.Steps)
+	results = make([]StepResult, n)
+	for i, s := range f.Steps {
+		r := StepResult{
+			Kind:           s.Kind,
+			Name:           s.Name,
+			Sessions:       c.sessions[i],
+			MedianStepTime: c.stepTimes[i],
+			Crashes:        c.crashes[i],
+			ANRs:           c.anrs[i],
+		}
+		if c.sessions[0] > 0 {
+			r.Conversion = percent(c.sessions[i], c.sessions[0])
+		}
+		if i == 0 {
+			r.StepConversion = r.Conversion
+		} else if c.sessions[i-1] > 0 {
+			r.StepConversion = percent(c.sessions[i], c.sessions[i-1])
+		}
+		if i+1 < n {
+			r.DropOff = c.sessions[i] - c.sessions[i+1]
+		}
+		results[i] = r
+	}
+
+	return
+}
+
+// percent computes the percentage of part
+// in whole, rounded to 2 decimals.
+func percent(part, whole uint64) float64 {
+	return math.Round(float64(part)/float64(whole)*100*100) / 100
+}
@@ -0,0 +1,142 @@
+package funnel
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var checkout = Funnel{
+	Steps: []Step{
+		{Kind: KindScreenView, Name: "Cart"},
+		{Kind: KindNavigation, Name: "Checkout"},
+		{Kind: KindCustom, Name: "OrderPlaced"},
+	},
+	Window: 10 * time.Minute,
+}
+
+func TestResults(t *testing.T) {
+	c := counts{
+		sessions:  []uint64{3, 2, 1},
+		stepTimes: []int64{0, 150000, 120000},
+		crashes:   []uint64{0, 1, 0},
+		anrs:      []uint64{0, 1, 0},
+	}
+
+	expected := []StepResult{
+		{
+			Kind:           KindScreenView,
+			Name:           "Cart",
+			Sessions:       3,
+			Conversion:     100,
+			StepConversion: 100,
+			DropOff:        1,
+		},
+		{
+			Kind:           KindNavigation,
+			Name:           "Checkout",
+			Sessions:       2,
+			Conversion:     66.67,
+			StepConversion: 66.67,
+			DropOff:        1,
+			MedianStepTime: 150000,
+			Crashes:        1,
+			ANRs:           1,
+		},
+		{
+			Kind:           KindCustom,
+			Name:           "OrderPlaced",
+			Sessions:       1,
+			Conversion:     33.33,
+			StepConversion: 50,
+			MedianStepTime: 120000,
+		},
+	}
+
+	results := checkout.results(c)
+	if !reflect.DeepEqual(expected, results) {
+		t.Errorf("Expected %+v, got %+v", expected, results)
+	}
+}
+
+func TestResultsEmpty(t *testing.T) {
+	results := checkout.results(counts{
+		sessions:  make([]uint64, 3),
+		stepTimes: make([]int64, 3),
+		crashes:   make([]uint64, 3),
+		anrs:      make([]uint64, 3),
+	})
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 steps, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Sessions != 0 || r.Conversion != 0 || r.StepConversion != 0 {
+			t.Errorf("Expected no conversion, got %+v", r)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := checkout.Validate(); err != nil {
+		t.Errorf("Expected valid funnel, got %v", err)
+	}
+
+	var steps []Step
+	if err := json.Unmarshal([]byte(`[
+		{"kind": "custom", "name": "OrderPlaced", "where": [{"key": "total", "type": "float64", "op": "gte", "value": "9.99"}]},
+		{"kind": "custom", "name": "Refund", "where": [{"key": "reason", "type": "string", "op": "contains", "value": "late"}]}
+	]`), &steps); err != nil {
+		t.Fatal(err)
+	}
+	if err := (Funnel{Steps: steps}).Validate(); err != nil {
+		t.Errorf("Expected valid funnel, got %v", err)
+	}
+
+	invalid := map[string]Funnel{
+		"too few steps":       {Steps: checkout.Steps[:1]},
+		"negative window":     {Steps: checkout.Steps, Window: -time.Second},
+		"unknown kind":        {Steps: []Step{{Kind: "tap", Name: "Buy"}, checkout.Steps[0]}},
+		"empty name":          {Steps: []Step{{Kind: KindScreenView}, checkout.Steps[0]}},
+		"predicate on screen": {Steps: []Step{{Kind: KindScreenView, Name: "Cart", Where: steps[0].Where}, checkout.Steps[0]}},
+	}
+
+	var nonNumeric []Step
+	if err := json.Unmarshal([]byte(`[
+		{"kind": "custom", "name": "OrderPlaced", "where": [{"key": "total", "type": "int64", "op": "gt", "value": "many"}]},
+		{"kind": "custom", "name": "Refund"}
+	]`), &nonNumeric); err != nil {
+		t.Fatal(err)
+	}
+	invalid["non numeric value"] = Funnel{Steps: nonNumeric}
+
+	for name, f := range invalid {
+		if err := f.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestStepCondition(t *testing.T) {
+	var step Step
+	if err := json.Unmarshal([]byte(`{"kind": "custom", "name": "OrderPlaced", "where": [
+		{"key": "total", "type": "float64", "op": "gte", "value": "9.99"},
+		{"key": "coupon", "type": "string", "op": "startsWith", "value": "SALE"}
+	]}`), &step); err != nil {
+		t.Fatal(err)
+	}
+
+	expr, args := step.condition()
+
+	expectedExpr := "(type = ? and `custom.name` = ?" +
+		" and (mapContains(user_defined_attribute, ?) and user_defined_attribute[?].1 = ? and toFloat64OrNull(user_defined_attribute[?].2) >= ?)" +
+		" and (mapContains(user_defined_attribute, ?) and user_defined_attribute[?].1 = ? and user_defined_attribute[?].2 ilike ?))"
+	if expr != expectedExpr {
+		t.Errorf("Expected %q, got %q", expectedExpr, expr)
+	}
+
+	expectedArgs := []any{"custom", "OrderPlaced", "total", "total", "float64", "total", 9.99, "coupon", "coupon", "string", "coupon", "SALE%"}
+	if !reflect.DeepEqual(expectedArgs, args) {
+		t.Errorf("Expected %v, got %v", expectedArgs, args)
+	}
+}
@@ -0,0 +1,213 @@
+package funnel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+	"backend/libs/event"
+	"backend/libs/filter"
+	"backend/libs/udattr"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// GetFunnel converts the sessions the app
+// filter matches through the funnel.
+//
+// A session enters the funnel at an event matching
+// the first step, then reaches each next step at a
+// later event matching it, all within the window.
+// Step times & crashes are measured from the earliest
+// events reaching each step.
+func GetFunnel(ctx context.Context, rch driver.Conn, teamID uuid.UUID, af *filter.AppFilter, f Funnel) (results []StepResult, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	n := len(f.Steps)
+	conds := make([]string, n)
+	condArgs := make([][]any, n)
+	var allCondArgs []any
+	for i, s := range f.Steps {
+		conds[i], condArgs[i] = s.condition()
+		allCondArgs = append(allCondArgs, condArgs[i]...)
+	}
+
+	// no window is one spanning
+	// the whole time range
+	window := f.Window
+	if window == 0 {
+		window = af.To.Sub(af.From)
+	}
+
+	crashExpr := "(type = ? and " + config.FatalExceptionExpr + ")"
+
+	whereArgs := append([]any{}, allCondArgs...)
+	whereArgs = append(whereArgs, event.TypeException, event.TypeANR)
+
+	// for each session, the level of the funnel it
+	// reached, the time it reached each step at & the
+	// times it crashed or hung at
+	stmt := sqlf.From("events").
+		Select(fmt.Sprintf("windowFunnel(%d)(toUInt64(toUnixTimestamp64Milli(timestamp)), %s) as level", window.Milliseconds(), strings.Join(conds, ", ")), allCondArgs...)
+
+	for i, cond := range conds {
+		if i == 0 {
+			stmt.Select("minIf(timestamp, "+cond+") as t1", condArgs[i]...)
+			continue
+		}
+		stmt.Select(fmt.Sprintf("arrayMin(arrayFilter(x -> x > t%d, groupArrayIf(timestamp, %s))) as t%d", i, cond, i+1), condArgs[i]...)
+	}
+
+	stmt.
+		Select("groupArrayIf(timestamp, "+crashExpr+") as crash_times", event.TypeException).
+		Select("groupArrayIf(timestamp, type = ?) as anr_times", event.TypeANR).
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", af.AppID).
+		Where("timestamp >= ? and timestamp <= ?", af.From, af.To).
+		Where("("+strings.Join(conds, " or ")+" or "+crashExpr+" or type = ?)", whereArgs...).
+		GroupBy("session_id")
+
+	defer stmt.Close()
+
+	if af.HasVersions() {
+		stmt.Where("attribute.app_version in ?", af.Versions)
+		stmt.Where("attribute.app_build in ?", af.VersionCodes)
+	}
+	if af.HasOSVersions() {
+		stmt.Where("attribute.os_name in ?", af.OsNames)
+		stmt.Where("attribute.os_version in ?", af.OsVersions)
+	}
+	if af.HasCountries() {
+		stmt.Where("inet.country_code in ?", af.Countries)
+	}
+	if af.HasDeviceNames() {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+	if af.HasDeviceManufacturers() {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+	if af.HasDeviceLocales() {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+	if af.HasNetworkTypes() {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+	if af.HasNetworkProviders() {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+	if af.HasNetworkGenerations() {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if af.HasUDExpression() && !af.UDExpression.Empty() {
+		subQuery := sqlf.
+			From("user_def_attrs").
+			Select("distinct session_id").
+			Where("team_id = toUUID(?)", teamID).
+			Where("app_id = toUUID(?)", af.AppID).
+			Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+
+		af.UDExpression.Augment(subQuery)
+		stmt.SubQuery("session_id in (", ")", subQuery)
+	}
+
+	sessions := make([]string, n)
+	stepTimes := make([]string, n)
+	crashes := make([]string, n)
+	anrs := make([]string, n)
+	for i := range n {
+		step := i + 1
+		sessions[i] = fmt.Sprintf("countIf(level >= %d)", step)
+
+		stepTimes[i] = "toInt64(0)"
+		if step > 1 {
+			stepTimes[i] = fmt.Sprintf("toInt64(ifNotFinite(quantileExactExclusiveIf(0.5)(dateDiff('millisecond', t%d, t%d), level >= %d), 0))", step-1, step, step)
+		}
+
+		// crashes & hangs count against
+		// the last step reached
+		before := "1"
+		if step < n {
+			before = fmt.Sprintf("(level = %d or x < t%d)", step, step+1)
+		}
+		crashes[i] = fmt.Sprintf("countIf(level >= %d and arrayExists(x -> x >= t%d and %s, crash_times))", step, step, before)
+		anrs[i] = fmt.Sprintf("countIf(level >= %d and arrayExists(x -> x >= t%d and %s, anr_times))", step, step, before)
+	}
+
+	outer := sqlf.New(fmt.Sprintf("with funnel_sessions as (%s) select", stmt.String()), stmt.Args()...).
+		Select("[" + strings.Join(sessions, ", ") + "]").
+		Select("[" + strings.Join(stepTimes, ", ") + "]").
+		Select("[" + strings.Join(crashes, ", ") + "]").
+		Select("[" + strings.Join(anrs, ", ") + "]").
+		From("funnel_sessions")
+
+	defer outer.Close()
+
+	var c counts
+	if err = rch.QueryRow(ctx, outer.String(), outer.Args()...).Scan(&c.sessions, &c.stepTimes, &c.crashes, &c.anrs); err != nil {
+		return
+	}
+
+	results = f.results(c)
+	return
+}
+
+// condition builds the SQL expression
+// matching the step's events.
+func (s Step) condition() (expr string, args []any) {
+	switch s.Kind {
+	case KindScreenView:
+		return "(type = ? and `screen_view.name` = toFixedString(?, 128))", []any{event.TypeScreenView, s.Name}
+	case KindNavigation:
+		return "(type = ? and `navigation.to` = ?)", []any{event.TypeNavigation, s.Name}
+	}
+
+	exprs := []string{"type = ?", "`custom.name` = ?"}
+	args = []any{event.TypeCustom, s.Name}
+	for _, cmp := range s.Where {
+		// validated already
+		value, _ := comparisonValue(cmp)
+
+		attr := "user_defined_attribute[?].2"
+		switch cmp.Type {
+		case udattr.AttrInt64:
+			attr = "toInt64OrNull(" + attr + ")"
+		case udattr.AttrFloat64:
+			attr = "toFloat64OrNull(" + attr + ")"
+		}
+
+		exprs = append(exprs, fmt.Sprintf("(mapContains(user_defined_attribute, ?) and user_defined_attribute[?].1 = ? and %s %s ?)", attr, cmp.Op.Sql()))
+		args = append(args, cmp.Key, cmp.Key, cmp.Type.String(), cmp.Key, value)
+	}
+
+	return "(" + strings.Join(exprs, " and ") + ")", args
+}
+
+// comparisonValue gets the value a user defined attribute
+// is compared to, typed for its attribute type & with
+// wildcards for partial matches.
+func comparisonValue(cmp udattr.UDComparison) (value any, err error) {
+	switch cmp.Type {
+	case udattr.AttrInt64:
+		return strconv.ParseInt(cmp.Value, 10, 64)
+	case udattr.AttrFloat64:
+		return strconv.ParseFloat(cmp.Value, 64)
+	case udattr.AttrBool:
+		b, err := strconv.ParseBool(cmp.Value)
+		return strconv.FormatBool(b), err
+	}
+
+	switch cmp.Op {
+	case udattr.OpContains:
+		return "%" + cmp.EscapedValue() + "%", nil
+	case udattr.OpStartsWith:
+		return cmp.EscapedValue() + "%", nil
+	}
+
+	return cmp.Value, nil
+}
@@ -0,0 +1,443 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/event"
+	"backend/libs/filter"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// MaxFingerprintRulesPerApp is the maximum number
+// of fingerprint rules an app can have.
+const MaxFingerprintRulesPerApp = 50
+
+// maxFingerprintRulePatternChars is the maximum
+// length of a fingerprint rule's pattern.
+const maxFingerprintRulePatternChars = 256
+
+// MaxMergeGroups is the maximum number of error
+// groups merged in one go.
+const MaxMergeGroups = 100
+
+// ErrMergeIntoSelf is returned when an error group
+// is merged into itself.
+var ErrMergeIntoSelf = errors.New("error group cannot be merged into itself")
+
+// ErrMergeAcrossKinds is returned when error groups
+// of different kinds, like a crash & an ANR, are
+// merged.
+var ErrMergeAcrossKinds = errors.New("error groups of different kinds cannot be merged")
+
+// RuleType is the type of a fingerprint rule.
+type RuleType string
+
+const (
+	// RuleTypeIgnoreFrame skips frames matching the
+	// pattern when fingerprinting.
+	RuleTypeIgnoreFrame RuleType = "ignore_frame"
+	// RuleTypeGroupByMessage groups errors whose
+	// message matches the pattern.
+	RuleTypeGroupByMessage RuleType = "group_by_message"
+	// RuleTypeFoldType groups errors whose type
+	// matches the pattern by type alone.
+	RuleTypeFoldType RuleType = "fold_type"
+)
+
+// FingerprintRule is an app's rule customizing how
+// error fingerprints are computed at ingest. Rules
+// only affect errors ingested after they're created.
+type FingerprintRule struct {
+	ID        uuid.UUID `json:"id"`
+	TeamID    uuid.UUID `json:"team_id"`
+	AppID     uuid.UUID `json:"app_id"`
+	Type      RuleType  `json:"type"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Validate validates the fingerprint rule.
+func (r FingerprintRule) Validate() error {
+	switch r.Type {
+	case RuleTypeIgnoreFrame, RuleTypeGroupByMessage, RuleTypeFoldType:
+	default:
+		return fmt.Errorf("`type` must be one of: %s, %s, %s", RuleTypeIgnoreFrame, RuleTypeGroupByMessage, RuleTypeFoldType)
+	}
+
+	if r.Pattern == "" {
+		return errors.New("`pattern` is required")
+	}
+
+	if len(r.Pattern) > maxFingerprintRulePatternChars {
+		return fmt.Errorf("`pattern` must be at most %d characters", maxFingerprintRulePatternChars)
+	}
+
+	if _, err := regexp.Compile(r.Pattern); err != nil {
+		return fmt.Errorf("`pattern` must be a valid regular expression: %v", err)
+	}
+
+	return nil
+}
+
+// CompileFingerprintRules compiles the fingerprint
+// rules for computing fingerprints, preserving the
+// order of the rules.
+func CompileFingerprintRules(rules []FingerprintRule) (compiled *event.FingerprintRules, err error) {
+	compiled = &event.FingerprintRules{}
+
+	for _, r := range rules {
+		re, errCompile := regexp.Compile(r.Pattern)
+		if errCompile != nil {
+			return nil, fmt.Errorf("failed to compile fingerprint rule %q: %w", r.ID, errCompile)
+		}
+
+		switch r.Type {
+		case RuleTypeIgnoreFrame:
+			compiled.IgnoreFrames = append(compiled.IgnoreFrames, re)
+		case RuleTypeGroupByMessage:
+			compiled.GroupByMessage = append(compiled.GroupByMessage, re)
+		case RuleTypeFoldType:
+			compiled.FoldTypes = append(compiled.FoldTypes, re)
+		}
+	}
+
+	return
+}
+
+// GetFingerprintRules fetches the fingerprint
+// rules of an app in creation order.
+func GetFingerprintRules(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (rules []FingerprintRule, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.fingerprint_rules").
+		Select("id").
+		Select("team_id").
+		Select("app_id").
+		Select("type").
+		Select("pattern").
+		Select("created_at").
+		Where("app_id = ?", appID).
+		OrderBy("created_at, id")
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	rules = []FingerprintRule{}
+
+	for rows.Next() {
+		var r FingerprintRule
+		if err = rows.Scan(&r.ID, &r.TeamID, &r.AppID, &r.Type, &r.Pattern, &r.CreatedAt); err != nil {
+			return
+		}
+		rules = append(rules, r)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// InsertFingerprintRule persists a new
+// fingerprint rule on behalf of a user.
+func InsertFingerprintRule(ctx context.Context, pg *pgxpool.Pool, r FingerprintRule, userID uuid.UUID) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("measure.fingerprint_rules").
+		Set("id", r.ID).
+		Set("team_id", r.TeamID).
+		Set("app_id", r.AppID).
+		Set("type", r.Type).
+		Set("pattern", r.Pattern).
+		Set("created_by", userID).
+		Set("created_at", r.CreatedAt)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// DeleteFingerprintRule deletes a fingerprint rule
+// of an app. Returns false if there was no such rule.
+func DeleteFingerprintRule(ctx context.Context, pg *pgxpool.Pool, appID, ruleID uuid.UUID) (deleted bool, err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("measure.fingerprint_rules").
+		Where("app_id = ?", appID).
+		Where("id = ?", ruleID)
+
+	defer stmt.Close()
+
+	tag, err := pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	deleted = tag.RowsAffected() > 0
+
+	return
+}
+
+// GetMerges fetches the error groups merged in an
+// app, mapping each merged group to its target. When
+// ids are given, only those groups are looked up.
+func GetMerges(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, ids ...string) (merges map[string]string, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.error_group_merges").
+		Select("id").
+		Select("target_id").
+		Where("app_id = ?", appID)
+
+	defer stmt.Close()
+
+	if len(ids) > 0 {
+		stmt.Where("id = any(?)", unique(ids))
+	}
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	merges = map[string]string{}
+
+	for rows.Next() {
+		var id, targetID string
+		if err = rows.Scan(&id, &targetID); err != nil {
+			return
+		}
+		merges[id] = targetID
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// GetMergedIDs fetches the fingerprints of the
+// error groups merged into the target group.
+func GetMergedIDs(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, targetID string) (ids []string, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.error_group_merges").
+		Select("id").
+		Where("app_id = ?", appID).
+		Where("target_id = ?", targetID).
+		OrderBy("id")
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	ids = []string{}
+
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// MergeGroups merges the error groups into the target
+// group on behalf of a user. A target merged into another
+// group resolves to that group, and groups already merged
+// into the merged groups move along to the target, so
+// merges never chain. Groups must be of the target's kind.
+// Returns the resolved target.
+func MergeGroups(ctx context.Context, pg *pgxpool.Pool, rch driver.Conn, teamID, appID uuid.UUID, targetID string, ids []string, userID uuid.UUID) (resolvedTargetID string, err error) {
+	kinds, err := getKinds(ctx, rch, teamID, appID, append([]string{targetID}, ids...))
+	if err != nil {
+		return
+	}
+
+	if !sameKind(kinds, targetID, ids) {
+		return "", ErrMergeAcrossKinds
+	}
+
+	tx, err := pg.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	targetStmt := sqlf.PostgreSQL.From("measure.error_group_merges").
+		Select("target_id").
+		Where("app_id = ?", appID).
+		Where("id = ?", targetID)
+
+	defer targetStmt.Close()
+
+	err = tx.QueryRow(ctx, targetStmt.String(), targetStmt.Args()...).Scan(&resolvedTargetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		resolvedTargetID, err = targetID, nil
+	}
+	if err != nil {
+		return
+	}
+
+	if slices.Contains(ids, resolvedTargetID) {
+		return "", ErrMergeIntoSelf
+	}
+
+	moveStmt := sqlf.PostgreSQL.Update("measure.error_group_merges").
+		Set("target_id", resolvedTargetID).
+		Set("merged_by", userID).
+		Where("app_id = ?", appID).
+		Where("target_id = any(?)", ids)
+
+	defer moveStmt.Close()
+
+	if _, err = tx.Exec(ctx, moveStmt.String(), moveStmt.Args()...); err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	for _, id := range unique(ids) {
+		stmt := sqlf.PostgreSQL.InsertInto("measure.error_group_merges").
+			Set("team_id", teamID).
+			Set("app_id", appID).
+			Set("id", id).
+			Set("target_id", resolvedTargetID).
+			Set("merged_by", userID).
+			Set("created_at", now).
+			Clause("on conflict (app_id, id) do update set target_id = excluded.target_id, merged_by = excluded.merged_by, created_at = excluded.created_at")
+
+		_, err = tx.Exec(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+
+		if err != nil {
+			return
+		}
+	}
+
+	err = tx.Commit(ctx)
+
+	return
+}
+
+// getKinds looks up the kinds of the error groups,
+// keyed by id. A fingerprint bucketed both fatally
+// & nonfatally has both kinds.
+func getKinds(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, ids []string) (kinds map[string][]Kind, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+	ids = unique(ids)
+
+	var branches []string
+	var args []any
+	for _, kind := range []Kind{KindFatal, KindNonfatal, KindANR} {
+		branches = append(branches, "select distinct id, '"+string(kind)+"' from "+kind.table()+" where team_id = toUUID(?) and app_id = toUUID(?) and id in ?")
+		args = append(args, teamID, appID, ids)
+	}
+
+	stmt := sqlf.New(strings.Join(branches, " union all "), args...)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	kinds = map[string][]Kind{}
+
+	for rows.Next() {
+		var id, kind string
+		if err = rows.Scan(&id, &kind); err != nil {
+			return
+		}
+		kinds[id] = append(kinds[id], Kind(kind))
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// sameKind reports whether every group shares a kind
+// with the target. Groups with no known kind, not yet
+// bucketed, are let through.
+func sameKind(kinds map[string][]Kind, targetID string, ids []string) bool {
+	targetKinds, ok := kinds[targetID]
+	if !ok {
+		return true
+	}
+
+	for _, id := range ids {
+		idKinds, ok := kinds[id]
+		if !ok {
+			continue
+		}
+		if !slices.ContainsFunc(idKinds, func(k Kind) bool { return slices.Contains(targetKinds, k) }) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitGroup splits a merged error group back out of
+// its target group. Returns false if the group wasn't
+// merged.
+func SplitGroup(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, id string) (split bool, err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("measure.error_group_merges").
+		Where("app_id = ?", appID).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	tag, err := pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	split = tag.RowsAffected() > 0
+
+	return
+}
+
+// ResolveMerges resolves the error groups merged in
+// the filter's app, so queries treat merged groups as
+// part of their target groups.
+func ResolveMerges(ctx context.Context, pg *pgxpool.Pool, af *filter.AppFilter) (err error) {
+	merges, err := GetMerges(ctx, pg, af.AppID)
+	if err != nil {
+		return
+	}
+
+	af.MergedErrorGroupIDs = make([]string, 0, len(merges))
+	af.MergedErrorGroupTargets = make([]string, 0, len(merges))
+
+	for id, targetID := range merges {
+		af.MergedErrorGroupIDs = append(af.MergedErrorGroupIDs, id)
+		af.MergedErrorGroupTargets = append(af.MergedErrorGroupTargets, targetID)
+	}
+
+	return
+}
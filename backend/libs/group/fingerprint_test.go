@@ -0,0 +1,91 @@
+package group
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFingerprintRuleValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    FingerprintRule
+		wantErr string
+	}{
+		{"ignore frame", FingerprintRule{Type: RuleTypeIgnoreFrame, Pattern: `^kotlinx\.coroutines\.`}, ""},
+		{"group by message", FingerprintRule{Type: RuleTypeGroupByMessage, Pattern: `^timeout after (\d+)ms`}, ""},
+		{"fold type", FingerprintRule{Type: RuleTypeFoldType, Pattern: `OutOfMemoryError`}, ""},
+		{"unknown type", FingerprintRule{Type: "split", Pattern: `x`}, "`type` must be one of"},
+		{"empty pattern", FingerprintRule{Type: RuleTypeFoldType}, "`pattern` is required"},
+		{"invalid pattern", FingerprintRule{Type: RuleTypeFoldType, Pattern: `(`}, "valid regular expression"},
+		{"long pattern", FingerprintRule{Type: RuleTypeFoldType, Pattern: strings.Repeat("a", maxFingerprintRulePatternChars+1)}, "at most"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.rule.Validate()
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("Validate = %v, want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileFingerprintRules(t *testing.T) {
+	rules := []FingerprintRule{
+		{Type: RuleTypeIgnoreFrame, Pattern: `^a`},
+		{Type: RuleTypeFoldType, Pattern: `^b`},
+		{Type: RuleTypeIgnoreFrame, Pattern: `^c`},
+		{Type: RuleTypeGroupByMessage, Pattern: `^d`},
+	}
+
+	compiled, err := CompileFingerprintRules(rules)
+	if err != nil {
+		t.Fatalf("CompileFingerprintRules: %v", err)
+	}
+
+	if len(compiled.IgnoreFrames) != 2 || compiled.IgnoreFrames[1].String() != `^c` {
+		t.Errorf("IgnoreFrames = %v, want [^a ^c]", compiled.IgnoreFrames)
+	}
+	if len(compiled.FoldTypes) != 1 || len(compiled.GroupByMessage) != 1 {
+		t.Errorf("FoldTypes = %v, GroupByMessage = %v, want one each", compiled.FoldTypes, compiled.GroupByMessage)
+	}
+
+	if _, err := CompileFingerprintRules([]FingerprintRule{{Type: RuleTypeFoldType, Pattern: `(`}}); err == nil {
+		t.Error("CompileFingerprintRules = nil error, want an error for an invalid pattern")
+	}
+}
+
+func TestSameKind(t *testing.T) {
+	kinds := map[string][]Kind{
+		"crash":  {KindFatal},
+		"crash2": {KindFatal},
+		"shared": {KindFatal, KindNonfatal},
+		"anr":    {KindANR},
+	}
+
+	cases := []struct {
+		name     string
+		targetID string
+		ids      []string
+		want     bool
+	}{
+		{"same kind", "crash", []string{"crash2"}, true},
+		{"shares one of the kinds", "shared", []string{"crash"}, true},
+		{"crash into ANR", "anr", []string{"crash"}, false},
+		{"one of many differs", "crash", []string{"crash2", "anr"}, false},
+		{"unknown groups are let through", "crash", []string{"unknown"}, true},
+		{"unknown target", "unknown", []string{"anr"}, true},
+	}
+
+	for _, c := range cases {
+		if got := sameKind(kinds, c.targetID, c.ids); got != c.want {
+			t.Errorf("%s: sameKind = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
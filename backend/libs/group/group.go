@@ -90,6 +90,8 @@ type ErrorGroup struct {
 	Count      uint64         `json:"count"`
 	Percentage float64        `json:"percentage_contribution"`
 	UpdatedAt  time.Time      `json:"updated_at"`
+	Release    *Release       `json:"release"`
+	State      *State         `json:"state"`
 }
 
 // unique deduplicates the source slice of
@@ -112,6 +114,17 @@ func unique(source []string) []string {
 	return result
 }
 
+// Kind provides the kind of the error group.
+func (e ErrorGroup) Kind() Kind {
+	if e.ErrorType == string(event.ErrorTypeANR) {
+		return KindANR
+	}
+	if e.Severity == event.SeverityFatal {
+		return KindFatal
+	}
+	return KindNonfatal
+}
+
 // GetId provides the exception's
 // Id.
 func (e ExceptionGroup) GetId() string {
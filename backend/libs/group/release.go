@@ -0,0 +1,550 @@
+package group
+
+import (
+	"context"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// RegressionQuietVersions is the number of app versions
+// released since an error group's last occurrence, after
+// which its return counts as a regression.
+const RegressionQuietVersions = 3
+
+// releaseLookbackPeriod bounds how far back app
+// versions are looked up when counting releases.
+const releaseLookbackPeriod = 90 * 24 * time.Hour
+
+// Kind is the kind of an error group, matching
+// the table the group is bucketed in.
+type Kind string
+
+const (
+	KindFatal    Kind = "fatal"
+	KindNonfatal Kind = "nonfatal"
+	KindANR      Kind = "anr"
+)
+
+// table provides the ClickHouse table
+// groups of the kind are bucketed in.
+func (k Kind) table() string {
+	switch k {
+	case KindFatal:
+		return "fatal_exception_groups"
+	case KindANR:
+		return "anr_groups"
+	default:
+		return "nonfatal_exception_groups"
+	}
+}
+
+// ReleaseState is the state of an error group
+// relative to the app's releases.
+type ReleaseState string
+
+const (
+	// ReleaseStateNew marks a group that first
+	// appeared in the state version.
+	ReleaseStateNew ReleaseState = "new"
+	// ReleaseStateRegressed marks a group that came back
+	// in the state version after going quiet.
+	ReleaseStateRegressed ReleaseState = "regressed"
+	// ReleaseStateOngoing marks a group that has since
+	// been seen in other versions.
+	ReleaseStateOngoing ReleaseState = "ongoing"
+)
+
+// Release is the release state of an
+// error group.
+type Release struct {
+	State            ReleaseState `json:"state"`
+	Version          string       `json:"version"`
+	VersionCode      string       `json:"version_code"`
+	FirstVersion     string       `json:"first_version"`
+	FirstVersionCode string       `json:"first_version_code"`
+	lastVersion      string
+	lastVersionCode  string
+	firstSeenAt      time.Time
+	lastSeenAt       time.Time
+}
+
+// Sighting is an occurrence of an error
+// group in an app version.
+type Sighting struct {
+	TeamID      uuid.UUID
+	AppID       uuid.UUID
+	Kind        Kind
+	ID          string
+	Version     string
+	VersionCode string
+	Timestamp   time.Time
+	Count       uint64
+}
+
+// key identifies the group and version
+// of the sighting.
+func (s Sighting) key() [4]string {
+	return [4]string{string(s.Kind), s.ID, s.Version, s.VersionCode}
+}
+
+// DedupSightings merges the sightings of each group and
+// version, keeping the latest timestamp and summing the
+// counts, preserving order.
+func DedupSightings(sightings []Sighting) (result []Sighting) {
+	seen := make(map[[4]string]int, len(sightings))
+	for _, s := range sightings {
+		if i, ok := seen[s.key()]; ok {
+			if s.Timestamp.After(result[i].Timestamp) {
+				result[i].Timestamp = s.Timestamp
+			}
+			result[i].Count += s.Count
+			continue
+		}
+		seen[s.key()] = len(result)
+		result = append(result, s)
+	}
+	return
+}
+
+// nextState computes the state of a known group seen
+// again in another version. quietVersions is the number
+// of app versions released since its last occurrence.
+func (r Release) nextState(version, versionCode string, quietVersions int) (state ReleaseState, changed bool) {
+	if quietVersions >= RegressionQuietVersions {
+		return ReleaseStateRegressed, true
+	}
+
+	if r.State != ReleaseStateOngoing && (r.Version != version || r.VersionCode != versionCode) {
+		return ReleaseStateOngoing, true
+	}
+
+	return r.State, false
+}
+
+// TrackReleases records the sightings of error groups in app
+// versions and moves the groups' release state along. A group
+// not seen before is new in the version, unless the group tables
+// show it in other versions from before release tracking began. A
+// group returning after RegressionQuietVersions app versions without
+// it is regressed in the version. The sightings must be of one app.
+// The states entered by the sightings are returned in order, empty
+// where a sighting changed nothing.
+//
+// Lookups & writes are batched, so the round trips made don't grow
+// with the number of groups sighted.
+func TrackReleases(ctx context.Context, pg *pgxpool.Pool, rch driver.Conn, sightings []Sighting) (entered []ReleaseState, err error) {
+	if len(sightings) == 0 {
+		return
+	}
+
+	teamID, appID := sightings[0].TeamID, sightings[0].AppID
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	releases, err := getReleases(ctx, pg, appID, sightings)
+	if err != nil {
+		return
+	}
+
+	var untracked []Sighting
+	var since time.Time
+	for _, s := range sightings {
+		r, ok := releases[s.groupKey()]
+		if !ok {
+			untracked = append(untracked, s)
+			continue
+		}
+		if r.lastVersion != s.Version || r.lastVersionCode != s.VersionCode {
+			if since.IsZero() || r.lastSeenAt.Before(since) {
+				since = r.lastSeenAt
+			}
+		}
+	}
+
+	history, err := getHistory(ctx, rch, teamID, appID, untracked)
+	if err != nil {
+		return
+	}
+
+	var versions []versionSeen
+	if !since.IsZero() {
+		if versions, err = getVersions(ctx, rch, teamID, appID, since.Add(-releaseLookbackPeriod)); err != nil {
+			return
+		}
+	}
+
+	// statements are closed once the batch is sent,
+	// the batch holds on to their args until then
+	var stmts []*sqlf.Stmt
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+
+	batch := &pgx.Batch{}
+	entered = make([]ReleaseState, len(sightings))
+	inserts := map[int]bool{}
+
+	for i, s := range sightings {
+		r, ok := releases[s.groupKey()]
+		if !ok {
+			r = newRelease(s, history[s.groupKey()])
+			stmt := sqlf.PostgreSQL.InsertInto("measure.error_group_releases").
+				Set("team_id", s.TeamID).
+				Set("app_id", s.AppID).
+				Set("kind", s.Kind).
+				Set("id", s.ID).
+				Set("first_version", r.FirstVersion).
+				Set("first_version_code", r.FirstVersionCode).
+				Set("last_version", s.Version).
+				Set("last_version_code", s.VersionCode).
+				Set("state", r.State).
+				Set("state_version", r.Version).
+				Set("state_version_code", r.VersionCode).
+				Set("first_seen_at", r.firstSeenAt).
+				Set("last_seen_at", s.Timestamp).
+				Clause("on conflict (app_id, kind, id) do nothing")
+
+			stmts = append(stmts, stmt)
+			batch.Queue(stmt.String(), stmt.Args()...)
+			inserts[i] = true
+
+			if r.State == ReleaseStateNew {
+				entered[i] = r.State
+			}
+
+			// later sightings of the group in
+			// other versions update it instead
+			releases[s.groupKey()] = r
+			continue
+		}
+
+		stmt := sqlf.PostgreSQL.Update("measure.error_group_releases").
+			SetExpr("last_seen_at", "greatest(last_seen_at, ?)", s.Timestamp).
+			Set("updated_at", time.Now()).
+			Where("app_id = ?", s.AppID).
+			Where("kind = ?", s.Kind).
+			Where("id = ?", s.ID)
+
+		if r.lastVersion != s.Version || r.lastVersionCode != s.VersionCode {
+			quiet := countVersionsSince(versions, s, r.lastSeenAt)
+
+			stmt.
+				Set("last_version", s.Version).
+				Set("last_version_code", s.VersionCode)
+
+			if state, changed := r.nextState(s.Version, s.VersionCode, quiet); changed {
+				stmt.
+					Set("state", state).
+					Set("state_version", s.Version).
+					Set("state_version_code", s.VersionCode)
+
+				if state == ReleaseStateRegressed {
+					entered[i] = state
+				}
+
+				r.State, r.Version, r.VersionCode = state, s.Version, s.VersionCode
+			}
+
+			r.lastVersion, r.lastVersionCode = s.Version, s.VersionCode
+		}
+
+		if s.Timestamp.After(r.lastSeenAt) {
+			r.lastSeenAt = s.Timestamp
+		}
+		releases[s.groupKey()] = r
+
+		stmts = append(stmts, stmt)
+		batch.Queue(stmt.String(), stmt.Args()...)
+	}
+
+	results := pg.SendBatch(ctx, batch)
+	defer results.Close()
+
+	for i := range sightings {
+		tag, errExec := results.Exec()
+		if errExec != nil {
+			return nil, errExec
+		}
+
+		// another sighting won the race
+		// to start tracking the group
+		if inserts[i] && tag.RowsAffected() == 0 {
+			entered[i] = ""
+		}
+	}
+
+	return
+}
+
+// groupKey identifies the group
+// of the sighting.
+func (s Sighting) groupKey() [2]string {
+	return [2]string{string(s.Kind), s.ID}
+}
+
+// getReleases fetches the release state of the
+// error groups sighted, keyed by kind & id.
+func getReleases(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, sightings []Sighting) (releases map[[2]string]Release, err error) {
+	ids := make([]string, len(sightings))
+	for i := range sightings {
+		ids[i] = sightings[i].ID
+	}
+
+	stmt := sqlf.PostgreSQL.From("measure.error_group_releases").
+		Select("kind").
+		Select("id").
+		Select("state").
+		Select("state_version").
+		Select("state_version_code").
+		Select("first_version").
+		Select("first_version_code").
+		Select("last_version").
+		Select("last_version_code").
+		Select("first_seen_at").
+		Select("last_seen_at").
+		Where("app_id = ?", appID).
+		Where("id = any(?)", unique(ids))
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	releases = map[[2]string]Release{}
+
+	for rows.Next() {
+		var (
+			kind, id string
+			r        Release
+		)
+		if err = rows.Scan(
+			&kind,
+			&id,
+			&r.State,
+			&r.Version,
+			&r.VersionCode,
+			&r.FirstVersion,
+			&r.FirstVersionCode,
+			&r.lastVersion,
+			&r.lastVersionCode,
+			&r.firstSeenAt,
+			&r.lastSeenAt,
+		); err != nil {
+			return
+		}
+		releases[[2]string{kind, id}] = r
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// versionSeen is the earliest occurrence
+// of an app version.
+type versionSeen struct {
+	version     string
+	versionCode string
+	firstSeenAt time.Time
+}
+
+// getHistory looks up the app versions the sighted groups are
+// bucketed in, one query per kind, keyed by kind & id. It shows
+// groups that occurred before release tracking began.
+func getHistory(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, sightings []Sighting) (history map[[2]string][]versionSeen, err error) {
+	history = map[[2]string][]versionSeen{}
+
+	ids := map[Kind][]string{}
+	for _, s := range sightings {
+		ids[s.Kind] = append(ids[s.Kind], s.ID)
+	}
+
+	for kind, kindIDs := range ids {
+		stmt := sqlf.From(kind.table()+" final").
+			Select("id").
+			Select("app_version.1").
+			Select("app_version.2").
+			Select("min(timestamp)").
+			Where("team_id = toUUID(?)", teamID).
+			Where("app_id = toUUID(?)", appID).
+			Where("id in ?", unique(kindIDs)).
+			GroupBy("id, app_version.1, app_version.2")
+
+		rows, errQuery := rch.Query(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+		if errQuery != nil {
+			return nil, errQuery
+		}
+
+		for rows.Next() {
+			var id string
+			var v versionSeen
+			if err = rows.Scan(&id, &v.version, &v.versionCode, &v.firstSeenAt); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			key := [2]string{string(kind), id}
+			history[key] = append(history[key], v)
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// getVersions looks up the app's versions
+// seen since the given time.
+func getVersions(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, since time.Time) (versions []versionSeen, err error) {
+	stmt := sqlf.From(config.AppMetricsTable).
+		Select("app_version.1").
+		Select("app_version.2").
+		Select("min(timestamp)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= ?", since).
+		GroupBy("app_version")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var v versionSeen
+		if err = rows.Scan(&v.version, &v.versionCode, &v.firstSeenAt); err != nil {
+			return
+		}
+		versions = append(versions, v)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// newRelease computes the release state a group starts
+// tracking with, from the versions it is bucketed in.
+// Groups bucketed in other versions before tracking
+// began are ongoing since the earliest, rather than new.
+func newRelease(s Sighting, history []versionSeen) (r Release) {
+	r = Release{
+		State:            ReleaseStateNew,
+		Version:          s.Version,
+		VersionCode:      s.VersionCode,
+		FirstVersion:     s.Version,
+		FirstVersionCode: s.VersionCode,
+		lastVersion:      s.Version,
+		lastVersionCode:  s.VersionCode,
+		firstSeenAt:      s.Timestamp,
+		lastSeenAt:       s.Timestamp,
+	}
+
+	for _, v := range history {
+		if v.version == s.Version && v.versionCode == s.VersionCode {
+			continue
+		}
+		if r.State == ReleaseStateNew || v.firstSeenAt.Before(r.firstSeenAt) {
+			r.State = ReleaseStateOngoing
+			r.FirstVersion, r.FirstVersionCode = v.version, v.versionCode
+			r.Version, r.VersionCode = v.version, v.versionCode
+			r.firstSeenAt = v.firstSeenAt
+		}
+	}
+
+	return
+}
+
+// countVersionsSince counts the app versions, other than
+// the sighting's, first seen after the given time.
+func countVersionsSince(versions []versionSeen, s Sighting, since time.Time) (count int) {
+	for _, v := range versions {
+		if v.version == s.Version && v.versionCode == s.VersionCode {
+			continue
+		}
+		if v.firstSeenAt.After(since) {
+			count++
+		}
+	}
+	return
+}
+
+// PopulateReleases populates the release state of
+// the error groups of an app. Groups not tracked yet
+// are left without a release state.
+func PopulateReleases(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, groups []ErrorGroup) (err error) {
+	if len(groups) == 0 {
+		return
+	}
+
+	ids := make([]string, len(groups))
+	for i := range groups {
+		ids[i] = groups[i].ID
+	}
+
+	stmt := sqlf.PostgreSQL.From("measure.error_group_releases").
+		Select("kind").
+		Select("id").
+		Select("state").
+		Select("state_version").
+		Select("state_version_code").
+		Select("first_version").
+		Select("first_version_code").
+		Where("app_id = ?", appID).
+		Where("id = any(?)", unique(ids))
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	releases := map[[2]string]Release{}
+
+	for rows.Next() {
+		var (
+			kind, id string
+			r        Release
+		)
+		if err = rows.Scan(&kind, &id, &r.State, &r.Version, &r.VersionCode, &r.FirstVersion, &r.FirstVersionCode); err != nil {
+			return
+		}
+		releases[[2]string{kind, id}] = r
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for i := range groups {
+		if r, ok := releases[[2]string{string(groups[i].Kind()), groups[i].ID}]; ok {
+			groups[i].Release = &r
+		}
+	}
+
+	return
+}
@@ -0,0 +1,123 @@
+package group
+
+import (
+	"testing"
+	"time"
+
+	"backend/libs/event"
+)
+
+func TestReleaseNextState(t *testing.T) {
+	r := Release{
+		State:       ReleaseStateNew,
+		Version:     "4.12.0",
+		VersionCode: "412",
+	}
+
+	cases := []struct {
+		name        string
+		r           Release
+		version     string
+		versionCode string
+		quiet       int
+		want        ReleaseState
+		wantChanged bool
+	}{
+		{"new group seen in its version", r, "4.12.0", "412", 0, ReleaseStateNew, false},
+		{"new group seen in another version", r, "4.13.0", "413", 1, ReleaseStateOngoing, true},
+		{"ongoing group seen in another version", Release{State: ReleaseStateOngoing, Version: "4.12.0", VersionCode: "412"}, "4.13.0", "413", 2, ReleaseStateOngoing, false},
+		{"group back after going quiet", Release{State: ReleaseStateOngoing, Version: "4.9.0", VersionCode: "409"}, "4.13.0", "413", RegressionQuietVersions, ReleaseStateRegressed, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			state, changed := c.r.nextState(c.version, c.versionCode, c.quiet)
+			if state != c.want || changed != c.wantChanged {
+				t.Errorf("nextState = (%q, %v), want (%q, %v)", state, changed, c.want, c.wantChanged)
+			}
+		})
+	}
+}
+
+func TestDedupSightings(t *testing.T) {
+	now := time.Now()
+	sightings := []Sighting{
+		{Kind: KindFatal, ID: "a", Version: "1.0", VersionCode: "1", Timestamp: now, Count: 1},
+		{Kind: KindFatal, ID: "b", Version: "1.0", VersionCode: "1", Timestamp: now, Count: 1},
+		{Kind: KindFatal, ID: "a", Version: "1.0", VersionCode: "1", Timestamp: now.Add(time.Minute), Count: 1},
+		{Kind: KindNonfatal, ID: "a", Version: "1.0", VersionCode: "1", Timestamp: now},
+		{Kind: KindFatal, ID: "a", Version: "1.1", VersionCode: "2", Timestamp: now},
+	}
+
+	got := DedupSightings(sightings)
+	if len(got) != 4 {
+		t.Fatalf("len = %d, want 4", len(got))
+	}
+	if got[0].ID != "a" || !got[0].Timestamp.Equal(now.Add(time.Minute)) {
+		t.Errorf("first sighting = %+v, want the latest timestamp of a", got[0])
+	}
+	if got[0].Count != 2 {
+		t.Errorf("first sighting count = %d, want 2", got[0].Count)
+	}
+	if got[1].ID != "b" {
+		t.Errorf("second sighting = %+v, want b", got[1])
+	}
+}
+
+func TestErrorGroupKind(t *testing.T) {
+	cases := []struct {
+		g    ErrorGroup
+		want Kind
+	}{
+		{ErrorGroup{ErrorType: "anr", Severity: event.SeverityFatal}, KindANR},
+		{ErrorGroup{ErrorType: "exception", Severity: event.SeverityFatal}, KindFatal},
+		{ErrorGroup{ErrorType: "exception", Severity: event.SeverityHandled}, KindNonfatal},
+	}
+
+	for _, c := range cases {
+		if got := c.g.Kind(); got != c.want {
+			t.Errorf("Kind(%+v) = %q, want %q", c.g, got, c.want)
+		}
+	}
+}
+
+func TestNewRelease(t *testing.T) {
+	now := time.Now()
+	s := Sighting{Kind: KindFatal, ID: "a", Version: "4.12.0", VersionCode: "412", Timestamp: now}
+
+	r := newRelease(s, nil)
+	if r.State != ReleaseStateNew || r.FirstVersion != "4.12.0" || r.Version != "4.12.0" {
+		t.Errorf("release = %+v, want new in 4.12.0", r)
+	}
+
+	r = newRelease(s, []versionSeen{{version: "4.12.0", versionCode: "412", firstSeenAt: now.Add(-time.Hour)}})
+	if r.State != ReleaseStateNew {
+		t.Errorf("state = %q, want new when only bucketed in its own version", r.State)
+	}
+
+	r = newRelease(s, []versionSeen{
+		{version: "4.11.0", versionCode: "411", firstSeenAt: now.Add(-24 * time.Hour)},
+		{version: "4.10.0", versionCode: "410", firstSeenAt: now.Add(-48 * time.Hour)},
+	})
+	if r.State != ReleaseStateOngoing || r.FirstVersion != "4.10.0" || r.VersionCode != "410" {
+		t.Errorf("release = %+v, want ongoing since 4.10.0", r)
+	}
+	if !r.firstSeenAt.Equal(now.Add(-48 * time.Hour)) {
+		t.Errorf("first seen at = %v, want the earliest occurrence", r.firstSeenAt)
+	}
+}
+
+func TestCountVersionsSince(t *testing.T) {
+	now := time.Now()
+	s := Sighting{Version: "4.13.0", VersionCode: "413"}
+	versions := []versionSeen{
+		{version: "4.10.0", versionCode: "410", firstSeenAt: now.Add(-72 * time.Hour)},
+		{version: "4.11.0", versionCode: "411", firstSeenAt: now.Add(-24 * time.Hour)},
+		{version: "4.12.0", versionCode: "412", firstSeenAt: now.Add(-12 * time.Hour)},
+		{version: "4.13.0", versionCode: "413", firstSeenAt: now.Add(-time.Hour)},
+	}
+
+	if count := countVersionsSince(versions, s, now.Add(-48*time.Hour)); count != 2 {
+		t.Errorf("count = %d, want 2 versions other than the sighting's", count)
+	}
+}
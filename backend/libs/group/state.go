@@ -0,0 +1,618 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/filter"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// ErrAssigneeNotMember is returned when an error group
+// is assigned to a user outside the app's team.
+var ErrAssigneeNotMember = errors.New("assignee is not a member of the team")
+
+// Status is the triage status of an error group. Error
+// groups never triaged are open.
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusResolved Status = "resolved"
+	StatusIgnored  Status = "ignored"
+	StatusSnoozed  Status = "snoozed"
+)
+
+// IsValid reports whether the status is valid.
+func (s Status) IsValid() bool {
+	return slices.Contains([]Status{StatusOpen, StatusResolved, StatusIgnored, StatusSnoozed}, s)
+}
+
+// effectiveStatusExpr computes the status of an error group,
+// treating groups snoozed until a time now past as open.
+const effectiveStatusExpr = "case when status = 'snoozed' and snoozed_until is not null and snoozed_until <= now() then 'open' else status end"
+
+// State is the triage state of an error group.
+type State struct {
+	Status              Status     `json:"status"`
+	ResolvedVersion     *string    `json:"resolved_version"`
+	ResolvedVersionCode *string    `json:"resolved_version_code"`
+	ResolvedAt          *time.Time `json:"resolved_at"`
+	SnoozedUntil        *time.Time `json:"snoozed_until"`
+	SnoozeOccurrences   *int64     `json:"snooze_occurrences"`
+	AssigneeID          *uuid.UUID `json:"assignee_id"`
+	UpdatedBy           *uuid.UUID `json:"updated_by"`
+	UpdatedAt           *time.Time `json:"updated_at"`
+}
+
+// StateUpdate is a partial update of the triage state of
+// an error group. Setting the status replaces the resolve
+// and snooze details of the previous status. An empty
+// assignee id unassigns the group.
+type StateUpdate struct {
+	Status              *Status    `json:"status"`
+	ResolvedVersion     *string    `json:"resolved_version"`
+	ResolvedVersionCode *string    `json:"resolved_version_code"`
+	SnoozedUntil        *time.Time `json:"snoozed_until"`
+	SnoozeOccurrences   *int64     `json:"snooze_occurrences"`
+	AssigneeID          *string    `json:"assignee_id"`
+}
+
+// Validate validates the state update.
+func (u StateUpdate) Validate() error {
+	if u.Status == nil && u.AssigneeID == nil {
+		return errors.New("at least one of `status` or `assignee_id` is required")
+	}
+
+	if u.AssigneeID != nil && *u.AssigneeID != "" {
+		if _, err := uuid.Parse(*u.AssigneeID); err != nil {
+			return errors.New("`assignee_id` must be a valid user id")
+		}
+	}
+
+	hasResolve := u.ResolvedVersion != nil || u.ResolvedVersionCode != nil
+	hasSnooze := u.SnoozedUntil != nil || u.SnoozeOccurrences != nil
+
+	if u.Status == nil {
+		if hasResolve || hasSnooze {
+			return errors.New("`status` is required to resolve or snooze")
+		}
+		return nil
+	}
+
+	if !u.Status.IsValid() {
+		return fmt.Errorf("`status` must be one of: %s, %s, %s, %s", StatusOpen, StatusResolved, StatusIgnored, StatusSnoozed)
+	}
+
+	if hasResolve && *u.Status != StatusResolved {
+		return errors.New("`resolved_version` and `resolved_version_code` are only valid when resolving")
+	}
+
+	if (u.ResolvedVersion == nil) != (u.ResolvedVersionCode == nil) {
+		return errors.New("both `resolved_version` and `resolved_version_code` must be set")
+	}
+
+	if u.ResolvedVersion != nil && (*u.ResolvedVersion == "" || *u.ResolvedVersionCode == "") {
+		return errors.New("`resolved_version` and `resolved_version_code` cannot be empty")
+	}
+
+	if hasSnooze && *u.Status != StatusSnoozed {
+		return errors.New("`snoozed_until` and `snooze_occurrences` are only valid when snoozing")
+	}
+
+	if *u.Status == StatusSnoozed {
+		if !hasSnooze {
+			return errors.New("at least one of `snoozed_until` or `snooze_occurrences` is required when snoozing")
+		}
+
+		if u.SnoozedUntil != nil && !u.SnoozedUntil.After(time.Now()) {
+			return errors.New("`snoozed_until` must be in the future")
+		}
+
+		if u.SnoozeOccurrences != nil && *u.SnoozeOccurrences <= 0 {
+			return errors.New("`snooze_occurrences` must be greater than zero")
+		}
+	}
+
+	return nil
+}
+
+// GetState fetches the triage state of an error group.
+func GetState(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, id string) (state State, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.error_group_states").
+		Select(effectiveStatusExpr).
+		Select("resolved_version").
+		Select("resolved_version_code").
+		Select("resolved_at").
+		Select("snoozed_until").
+		Select("snooze_occurrences").
+		Select("assignee_id").
+		Select("updated_by").
+		Select("updated_at").
+		Where("app_id = ?", appID).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&state.Status,
+		&state.ResolvedVersion,
+		&state.ResolvedVersionCode,
+		&state.ResolvedAt,
+		&state.SnoozedUntil,
+		&state.SnoozeOccurrences,
+		&state.AssigneeID,
+		&state.UpdatedBy,
+		&state.UpdatedAt,
+	)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return State{Status: StatusOpen}, nil
+	}
+
+	return
+}
+
+// UpdateState applies the update to the triage state of
+// an error group of the team's app on behalf of a user.
+func UpdateState(ctx context.Context, pg *pgxpool.Pool, teamID, appID uuid.UUID, id string, u StateUpdate, userID uuid.UUID) (state State, err error) {
+	if u.AssigneeID != nil && *u.AssigneeID != "" {
+		var member bool
+		stmt := sqlf.PostgreSQL.
+			Select("exists(select 1 from measure.team_membership where team_id = ? and user_id = ?)", teamID, *u.AssigneeID)
+
+		defer stmt.Close()
+
+		if err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&member); err != nil {
+			return
+		}
+
+		if !member {
+			return state, ErrAssigneeNotMember
+		}
+	}
+
+	now := time.Now()
+	stmt := sqlf.PostgreSQL.InsertInto("measure.error_group_states").
+		Set("team_id", teamID).
+		Set("app_id", appID).
+		Set("id", id).
+		Set("updated_by", userID).
+		Set("created_at", now).
+		Set("updated_at", now)
+
+	updates := []string{"updated_by = excluded.updated_by", "updated_at = excluded.updated_at"}
+
+	set := func(column string, value any) {
+		stmt.Set(column, value)
+		updates = append(updates, column+" = excluded."+column)
+	}
+
+	if u.Status != nil {
+		var resolvedAt *time.Time
+		if *u.Status == StatusResolved {
+			resolvedAt = &now
+		}
+
+		set("status", *u.Status)
+		set("resolved_version", u.ResolvedVersion)
+		set("resolved_version_code", u.ResolvedVersionCode)
+		set("resolved_at", resolvedAt)
+		set("snoozed_until", u.SnoozedUntil)
+		set("snooze_occurrences", u.SnoozeOccurrences)
+	}
+
+	if u.AssigneeID != nil {
+		var assigneeID *string
+		if *u.AssigneeID != "" {
+			assigneeID = u.AssigneeID
+		}
+		set("assignee_id", assigneeID)
+	}
+
+	stmt.Clause("on conflict (app_id, id) do update set " + strings.Join(updates, ", "))
+
+	defer stmt.Close()
+
+	if _, err = pg.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+		return
+	}
+
+	return GetState(ctx, pg, appID, id)
+}
+
+// PopulateStates populates the triage state of the
+// error groups of an app.
+func PopulateStates(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, groups []ErrorGroup) (err error) {
+	if len(groups) == 0 {
+		return
+	}
+
+	ids := make([]string, len(groups))
+	for i := range groups {
+		ids[i] = groups[i].ID
+	}
+
+	stmt := sqlf.PostgreSQL.From("measure.error_group_states").
+		Select("id").
+		Select(effectiveStatusExpr).
+		Select("resolved_version").
+		Select("resolved_version_code").
+		Select("resolved_at").
+		Select("snoozed_until").
+		Select("snooze_occurrences").
+		Select("assignee_id").
+		Select("updated_by").
+		Select("updated_at").
+		Where("app_id = ?", appID).
+		Where("id = any(?)", unique(ids))
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	states := map[string]State{}
+
+	for rows.Next() {
+		var (
+			id    string
+			state State
+		)
+		if err = rows.Scan(
+			&id,
+			&state.Status,
+			&state.ResolvedVersion,
+			&state.ResolvedVersionCode,
+			&state.ResolvedAt,
+			&state.SnoozedUntil,
+			&state.SnoozeOccurrences,
+			&state.AssigneeID,
+			&state.UpdatedBy,
+			&state.UpdatedAt,
+		); err != nil {
+			return
+		}
+		states[id] = state
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	for i := range groups {
+		state, ok := states[groups[i].ID]
+		if !ok {
+			state = State{Status: StatusOpen}
+		}
+		groups[i].State = &state
+	}
+
+	return
+}
+
+// ResolveStatusFilter resolves the error group statuses of the
+// filter to the error groups to include, or to exclude when the
+// open status is requested, as never triaged groups are open.
+func ResolveStatusFilter(ctx context.Context, pg *pgxpool.Pool, af *filter.AppFilter) (err error) {
+	if !af.HasErrorGroupStatuses() {
+		return
+	}
+
+	af.ExcludeErrorGroupIDs = slices.Contains(af.ErrorGroupStatuses, string(StatusOpen))
+
+	stmt := sqlf.PostgreSQL.From("measure.error_group_states").
+		Select("id").
+		Where("app_id = ?", af.AppID)
+
+	if af.ExcludeErrorGroupIDs {
+		stmt.Where(effectiveStatusExpr+" != all(?)", af.ErrorGroupStatuses)
+	} else {
+		stmt.Where(effectiveStatusExpr+" = any(?)", af.ErrorGroupStatuses)
+	}
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	af.ErrorGroupIDs = nil
+
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			return
+		}
+		af.ErrorGroupIDs = append(af.ErrorGroupIDs, id)
+	}
+
+	return rows.Err()
+}
+
+// reopenReason computes why a sighting reopens a group in
+// the state, or an empty reason when it doesn't. newer
+// reports whether the sighting's version is the version
+// the group was resolved in or newer.
+func (s State) reopenReason(sighting Sighting, now time.Time, newer func() (bool, error)) (reason string, err error) {
+	switch s.Status {
+	case StatusResolved:
+		if s.ResolvedVersion == nil {
+			if s.ResolvedAt != nil && sighting.Timestamp.After(*s.ResolvedAt) {
+				return "recurred after being resolved", nil
+			}
+			return
+		}
+
+		ok, err := newer()
+		if err != nil || !ok {
+			return "", err
+		}
+		return fmt.Sprintf("recurred in %s (%s) after being resolved in %s (%s)", sighting.Version, sighting.VersionCode, *s.ResolvedVersion, *s.ResolvedVersionCode), nil
+	case StatusSnoozed:
+		if s.SnoozedUntil != nil && !now.Before(*s.SnoozedUntil) {
+			return "snooze expired", nil
+		}
+		if s.SnoozeOccurrences != nil && *s.SnoozeOccurrences <= int64(sighting.Count) {
+			return "recurred past snoozed occurrences", nil
+		}
+	}
+
+	return
+}
+
+// TrackStates moves the triage states of error groups along
+// on their sightings. A resolved group recurring in the version
+// it was resolved in or a newer one, and a snoozed group past its
+// snooze, reopen. Snoozed occurrences count down otherwise. The
+// sightings must be of one app. The reasons groups reopened are
+// returned in order, empty where a sighting didn't reopen its
+// group.
+//
+// Lookups & writes are batched, so the round trips made don't grow
+// with the number of groups sighted.
+func TrackStates(ctx context.Context, pg *pgxpool.Pool, rch driver.Conn, sightings []Sighting) (reopened []string, err error) {
+	if len(sightings) == 0 {
+		return
+	}
+
+	teamID, appID := sightings[0].TeamID, sightings[0].AppID
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	states, err := getTriageStates(ctx, pg, appID, sightings)
+	if err != nil {
+		return
+	}
+
+	// app versions are looked up once, for
+	// the first version codes not numeric
+	var versions []versionSeen
+	var versionsLoaded bool
+	loadVersions := func() ([]versionSeen, error) {
+		if !versionsLoaded {
+			if versions, err = getVersions(ctx, rch, teamID, appID, time.Now().Add(-releaseLookbackPeriod)); err != nil {
+				return nil, err
+			}
+			versionsLoaded = true
+		}
+		return versions, nil
+	}
+
+	// statuses the groups were in before the
+	// sightings, in order of first sighting
+	var ids []string
+	statuses := map[string]Status{}
+	reopens := map[string]bool{}
+	countdowns := map[string]int64{}
+
+	now := time.Now()
+	reopened = make([]string, len(sightings))
+
+	for i, s := range sightings {
+		state, ok := states[s.ID]
+		if !ok {
+			continue
+		}
+
+		newer := func() (bool, error) {
+			return isSameOrNewerVersion(s, *state.ResolvedVersion, *state.ResolvedVersionCode, loadVersions)
+		}
+
+		reason, errReason := state.reopenReason(s, now, newer)
+		if errReason != nil {
+			return nil, errReason
+		}
+
+		if _, ok := statuses[s.ID]; !ok {
+			ids = append(ids, s.ID)
+			statuses[s.ID] = state.Status
+		}
+
+		switch {
+		case reason != "":
+			reopened[i] = reason
+			reopens[s.ID] = true
+			states[s.ID] = State{Status: StatusOpen}
+		case state.Status == StatusSnoozed && state.SnoozeOccurrences != nil:
+			remaining := *state.SnoozeOccurrences - int64(s.Count)
+			state.SnoozeOccurrences = &remaining
+			states[s.ID] = state
+			countdowns[s.ID] += int64(s.Count)
+		}
+	}
+
+	// statements are closed once the batch is sent,
+	// the batch holds on to their args until then
+	var stmts []*sqlf.Stmt
+	defer func() {
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}()
+
+	batch := &pgx.Batch{}
+
+	for _, id := range ids {
+		update := sqlf.PostgreSQL.Update("measure.error_group_states").
+			Set("updated_at", now).
+			Where("app_id = ?", appID).
+			Where("id = ?", id).
+			Where("status = ?", statuses[id])
+
+		switch {
+		case reopens[id]:
+			update.
+				Set("status", StatusOpen).
+				Set("resolved_version", nil).
+				Set("resolved_version_code", nil).
+				Set("resolved_at", nil).
+				Set("snoozed_until", nil).
+				Set("snooze_occurrences", nil)
+		case countdowns[id] > 0:
+			update.SetExpr("snooze_occurrences", "snooze_occurrences - ?", countdowns[id])
+		default:
+			update.Close()
+			continue
+		}
+
+		stmts = append(stmts, update)
+		batch.Queue(update.String(), update.Args()...)
+	}
+
+	if batch.Len() == 0 {
+		return
+	}
+
+	err = pg.SendBatch(ctx, batch).Close()
+
+	return
+}
+
+// getTriageStates fetches the triage states of the error
+// groups sighted, keyed by id. Groups never triaged are
+// left out.
+func getTriageStates(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, sightings []Sighting) (states map[string]State, err error) {
+	ids := make([]string, len(sightings))
+	for i := range sightings {
+		ids[i] = sightings[i].ID
+	}
+
+	stmt := sqlf.PostgreSQL.From("measure.error_group_states").
+		Select("id").
+		Select("status").
+		Select("resolved_version").
+		Select("resolved_version_code").
+		Select("resolved_at").
+		Select("snoozed_until").
+		Select("snooze_occurrences").
+		Where("app_id = ?", appID).
+		Where("id = any(?)", unique(ids))
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	states = map[string]State{}
+
+	for rows.Next() {
+		var (
+			id    string
+			state State
+		)
+		if err = rows.Scan(
+			&id,
+			&state.Status,
+			&state.ResolvedVersion,
+			&state.ResolvedVersionCode,
+			&state.ResolvedAt,
+			&state.SnoozedUntil,
+			&state.SnoozeOccurrences,
+		); err != nil {
+			return
+		}
+		states[id] = state
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// compareVersionCodes compares two numeric version
+// codes. ok is false when either isn't numeric.
+func compareVersionCodes(a, b string) (cmp int, ok bool) {
+	x, errX := strconv.ParseInt(a, 10, 64)
+	y, errY := strconv.ParseInt(b, 10, 64)
+	if errX != nil || errY != nil {
+		return 0, false
+	}
+
+	switch {
+	case x < y:
+		return -1, true
+	case x > y:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// isSameOrNewerVersion reports whether the sighting's version is
+// the given version or newer. Numeric version codes are compared
+// as numbers, other versions by when the app first reported them,
+// as loaded by versions.
+func isSameOrNewerVersion(s Sighting, version, versionCode string, versions func() ([]versionSeen, error)) (bool, error) {
+	if s.Version == version && s.VersionCode == versionCode {
+		return true, nil
+	}
+
+	if cmp, ok := compareVersionCodes(s.VersionCode, versionCode); ok && cmp != 0 {
+		return cmp > 0, nil
+	}
+
+	seen, err := versions()
+	if err != nil {
+		return false, err
+	}
+
+	var seenAt, resolvedSeenAt time.Time
+	for _, v := range seen {
+		switch {
+		case v.version == s.Version && v.versionCode == s.VersionCode:
+			seenAt = v.firstSeenAt
+		case v.version == version && v.versionCode == versionCode:
+			resolvedSeenAt = v.firstSeenAt
+		}
+	}
+
+	// the version the group was resolved
+	// in hasn't been released yet
+	if resolvedSeenAt.IsZero() {
+		return false, nil
+	}
+
+	return !seenAt.Before(resolvedSeenAt), nil
+}
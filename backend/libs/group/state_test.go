@@ -0,0 +1,180 @@
+package group
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateUpdateValidate(t *testing.T) {
+	status := func(s Status) *Status { return &s }
+	str := func(s string) *string { return &s }
+	count := func(n int64) *int64 { return &n }
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	cases := []struct {
+		name    string
+		u       StateUpdate
+		wantErr string
+	}{
+		{"empty", StateUpdate{}, "at least one of"},
+		{"invalid status", StateUpdate{Status: status("closed")}, "`status` must be one of"},
+		{"resolve", StateUpdate{Status: status(StatusResolved)}, ""},
+		{"resolve in version", StateUpdate{Status: status(StatusResolved), ResolvedVersion: str("4.12.0"), ResolvedVersionCode: str("412")}, ""},
+		{"resolve without version code", StateUpdate{Status: status(StatusResolved), ResolvedVersion: str("4.12.0")}, "both `resolved_version`"},
+		{"resolved version when ignoring", StateUpdate{Status: status(StatusIgnored), ResolvedVersion: str("4.12.0"), ResolvedVersionCode: str("412")}, "only valid when resolving"},
+		{"snooze without until", StateUpdate{Status: status(StatusSnoozed)}, "required when snoozing"},
+		{"snooze until", StateUpdate{Status: status(StatusSnoozed), SnoozedUntil: &future}, ""},
+		{"snooze until past", StateUpdate{Status: status(StatusSnoozed), SnoozedUntil: &past}, "must be in the future"},
+		{"snooze occurrences", StateUpdate{Status: status(StatusSnoozed), SnoozeOccurrences: count(100)}, ""},
+		{"snooze zero occurrences", StateUpdate{Status: status(StatusSnoozed), SnoozeOccurrences: count(0)}, "greater than zero"},
+		{"snooze occurrences when opening", StateUpdate{Status: status(StatusOpen), SnoozeOccurrences: count(10)}, "only valid when snoozing"},
+		{"assign", StateUpdate{AssigneeID: str("0e3c5c4b-4c1f-4d0c-9f53-6d7d1f0b2a9e")}, ""},
+		{"unassign", StateUpdate{AssigneeID: str("")}, ""},
+		{"invalid assignee", StateUpdate{AssigneeID: str("someone")}, "valid user id"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.u.Validate()
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("Validate = %v, want error containing %q", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestStateReopenReason(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+	version, versionCode := "4.12.0", "412"
+	remaining := int64(5)
+
+	sighting := Sighting{Version: "4.13.0", VersionCode: "413", Timestamp: now, Count: 2}
+	newer := func(ok bool) func() (bool, error) {
+		return func() (bool, error) { return ok, nil }
+	}
+
+	cases := []struct {
+		name   string
+		state  State
+		s      Sighting
+		newer  func() (bool, error)
+		reopen bool
+	}{
+		{"open stays open", State{Status: StatusOpen}, sighting, newer(true), false},
+		{"ignored stays ignored", State{Status: StatusIgnored}, sighting, newer(true), false},
+		{"resolved recurring in newer version", State{Status: StatusResolved, ResolvedVersion: &version, ResolvedVersionCode: &versionCode}, sighting, newer(true), true},
+		{"resolved recurring in older version", State{Status: StatusResolved, ResolvedVersion: &version, ResolvedVersionCode: &versionCode}, sighting, newer(false), false},
+		{"resolved without version recurring", State{Status: StatusResolved, ResolvedAt: &earlier}, sighting, newer(false), true},
+		{"snooze expired", State{Status: StatusSnoozed, SnoozedUntil: &earlier}, sighting, newer(false), true},
+		{"snooze pending", State{Status: StatusSnoozed, SnoozedUntil: &later}, sighting, newer(false), false},
+		{"snoozed occurrences remaining", State{Status: StatusSnoozed, SnoozeOccurrences: &remaining}, sighting, newer(false), false},
+		{"snoozed occurrences exhausted", State{Status: StatusSnoozed, SnoozeOccurrences: &remaining}, Sighting{Timestamp: now, Count: 5}, newer(false), true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reason, err := c.state.reopenReason(c.s, now, c.newer)
+			if err != nil {
+				t.Fatalf("reopenReason: %v", err)
+			}
+			if (reason != "") != c.reopen {
+				t.Errorf("reopenReason = %q, want reopen %v", reason, c.reopen)
+			}
+		})
+	}
+
+	t.Run("version lookup failure", func(t *testing.T) {
+		state := State{Status: StatusResolved, ResolvedVersion: &version, ResolvedVersionCode: &versionCode}
+		failing := func() (bool, error) { return false, errors.New("boom") }
+		if _, err := state.reopenReason(sighting, now, failing); err == nil {
+			t.Error("reopenReason = nil error, want the lookup error")
+		}
+	})
+}
+
+func TestCompareVersionCodes(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		want   int
+		wantOK bool
+	}{
+		{"412", "413", -1, true},
+		{"1000", "999", 1, true},
+		{"7", "7", 0, true},
+		{"1.2.3", "413", 0, false},
+	}
+
+	for _, c := range cases {
+		got, ok := compareVersionCodes(c.a, c.b)
+		if got != c.want || ok != c.wantOK {
+			t.Errorf("compareVersionCodes(%q, %q) = (%d, %v), want (%d, %v)", c.a, c.b, got, ok, c.want, c.wantOK)
+		}
+	}
+}
+
+func TestIsSameOrNewerVersion(t *testing.T) {
+	now := time.Now()
+	versions := []versionSeen{
+		{"4.12.0", "a412", now.Add(-2 * time.Hour)},
+		{"4.13.0", "a413", now.Add(-time.Hour)},
+	}
+
+	cases := []struct {
+		name                 string
+		version, versionCode string
+		resolved, code       string
+		want                 bool
+		wantLoad             bool
+	}{
+		{"same version", "4.12.0", "a412", "4.12.0", "a412", true, false},
+		{"newer version code", "4.13.0", "413", "4.12.0", "412", true, false},
+		{"older version code", "4.11.0", "411", "4.12.0", "412", false, false},
+		{"released later", "4.13.0", "a413", "4.12.0", "a412", true, true},
+		{"released earlier", "4.12.0", "a412", "4.13.0", "a413", false, true},
+		{"resolved version unreleased", "4.13.0", "a413", "4.14.0", "a414", false, true},
+		{"version unseen", "4.14.0", "a414", "4.12.0", "a412", false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			loaded := false
+			load := func() ([]versionSeen, error) {
+				loaded = true
+				return versions, nil
+			}
+
+			s := Sighting{Version: c.version, VersionCode: c.versionCode}
+			got, err := isSameOrNewerVersion(s, c.resolved, c.code, load)
+			if err != nil {
+				t.Fatalf("isSameOrNewerVersion = %v, want nil error", err)
+			}
+			if got != c.want {
+				t.Errorf("isSameOrNewerVersion = %v, want %v", got, c.want)
+			}
+			if loaded != c.wantLoad {
+				t.Errorf("versions loaded = %v, want %v", loaded, c.wantLoad)
+			}
+		})
+	}
+
+	t.Run("load error", func(t *testing.T) {
+		wantErr := errors.New("no connection")
+		load := func() ([]versionSeen, error) { return nil, wantErr }
+
+		s := Sighting{Version: "4.13.0", VersionCode: "a413"}
+		if _, err := isSameOrNewerVersion(s, "4.12.0", "a412", load); !errors.Is(err, wantErr) {
+			t.Fatalf("isSameOrNewerVersion = %v, want %v", err, wantErr)
+		}
+	})
+}
@@ -0,0 +1,30 @@
+// Package heapdump analyzes HPROF heap dumps for leak suspects.
+//
+// Uploaded dumps are analyzed with [Analyze] into a [Report]. The object
+// graph is rebuilt from the dump, its dominator tree computed to find the
+// memory each object retains, that is the memory freed were it collected,
+// and the classes retaining the most memory summarized. References held by
+// weak, soft & phantom references are ignored. Classes count as reachable
+// so that their static fields act as GC roots.
+//
+// Leak suspects are reachable instances of:
+//
+//   - Activities that were destroyed
+//   - Fragments detached from their fragment manager
+//   - Bitmaps whose pixels outsize a full HD screen
+//   - Collections holding more than 10,000 elements
+//
+// Along with each suspect comes the shortest path of references from a GC
+// root keeping it alive.
+//
+// Reports are read back per session or event with [GetSessionDumps] &
+// [GetEventDumps], and leak suspects aggregated across the dumps of an
+// app version with [GetLeaks].
+//
+//	leaks, err := heapdump.GetLeaks(ctx, rch, teamID, appID, heapdump.Query{
+//	    From:        from,
+//	    To:          to,
+//	    Version:     "1.2.0",
+//	    VersionCode: "120",
+//	})
+package heapdump
@@ -0,0 +1,395 @@
+package heapdump
+
+import "strconv"
+
+// noNode marks a missing node, like the
+// dominator of the root.
+const noNode = -1
+
+// arrayElement labels references
+// held by array elements.
+const arrayElement = -1
+
+// referenceClass declares the referent field of
+// weak, soft & phantom references, which doesn't
+// keep the referent alive.
+const referenceClass = "java.lang.ref.Reference"
+
+// graph is the object graph of a heap dump. Node 0 is a
+// virtual root referencing every GC root, node i+1 is
+// the object at index i of the dump. References are
+// stored as compressed sparse rows.
+type graph struct {
+	h *hprof
+	// shallow is the estimated size
+	// of each node, in bytes.
+	shallow []uint64
+	// start indexes the first reference of
+	// each node in refs & labels.
+	start []int32
+	refs  []int32
+	// labels are the names of the fields holding
+	// references, indexes into names or
+	// arrayElement.
+	labels []int32
+	names  []string
+
+	// idom is the immediate dominator
+	// of each reachable node.
+	idom []int32
+	// order lists reachable nodes in
+	// depth-first order, root first.
+	order []int32
+	// retained is the size retained
+	// by each node, in bytes.
+	retained []uint64
+	// parent & parentLabel describe the reference
+	// to each node on its shortest path from the
+	// root.
+	parent      []int32
+	parentLabel []int32
+}
+
+// buildGraph builds the object graph of the heap dump.
+// Classes are always reachable, so their static fields
+// act as GC roots.
+func buildGraph(h *hprof) *graph {
+	n := len(h.objects) + 1
+	g := &graph{
+		h:       h,
+		shallow: make([]uint64, n),
+		start:   make([]int32, n+1),
+	}
+
+	nameIndex := map[string]int32{}
+	label := func(name string) int32 {
+		i, ok := nameIndex[name]
+		if !ok {
+			i = int32(len(g.names))
+			nameIndex[name] = i
+			g.names = append(g.names, name)
+		}
+		return i
+	}
+
+	addRef := func(id uint64, l int32) {
+		if id == 0 {
+			return
+		}
+		if i, ok := h.index[id]; ok {
+			g.refs = append(g.refs, i+1)
+			g.labels = append(g.labels, l)
+		}
+	}
+
+	// virtual root
+	seen := map[uint64]bool{}
+	for _, id := range h.roots {
+		if !seen[id] {
+			seen[id] = true
+			addRef(id, arrayElement)
+		}
+	}
+	for _, o := range h.objects {
+		if o.kind == kindClass && !seen[o.id] {
+			addRef(o.id, arrayElement)
+		}
+	}
+
+	for i, o := range h.objects {
+		node := i + 1
+		g.start[node] = int32(len(g.refs))
+
+		switch o.kind {
+		case kindInstance:
+			g.shallow[node] = uint64(o.length)
+			offset := o.offset
+			end := o.offset + int(o.length)
+			for id := o.classID; id != 0; {
+				c, ok := h.classes[id]
+				if !ok {
+					break
+				}
+				if uint64(c.size) > g.shallow[node] && id == o.classID {
+					g.shallow[node] = uint64(c.size)
+				}
+				weak := h.classNames[id] == referenceClass
+				for _, f := range c.fields {
+					size := typeSize(f.typ, h.idSize)
+					if size == 0 || offset+size > end {
+						break
+					}
+					if f.typ == typeObject && !(weak && f.name == "referent") {
+						addRef(readID(h.data[offset:], h.idSize), label(f.name))
+					}
+					offset += size
+				}
+				id = c.superID
+			}
+		case kindObjectArray:
+			g.shallow[node] = uint64(o.length) * uint64(h.idSize)
+			for j := range int(o.length) {
+				addRef(readID(h.data[o.offset+j*h.idSize:], h.idSize), arrayElement)
+			}
+		case kindPrimitiveArray:
+			g.shallow[node] = uint64(o.length) * uint64(typeSize(o.elemType, h.idSize))
+		case kindClass:
+			c := h.classes[o.id]
+			g.shallow[node] = c.staticSize
+			for j, f := range c.statics {
+				addRef(c.staticValues[j], label(f.name))
+			}
+		}
+	}
+	g.start[n] = int32(len(g.refs))
+
+	return g
+}
+
+// size is the count of nodes,
+// including the root.
+func (g *graph) size() int {
+	return len(g.shallow)
+}
+
+// references gets the references
+// held by a node.
+func (g *graph) references(node int32) []int32 {
+	return g.refs[g.start[node]:g.start[node+1]]
+}
+
+// object gets the object of a node.
+func (g *graph) object(node int32) object {
+	return g.h.objects[node-1]
+}
+
+// dominate computes the dominator tree using the
+// Lengauer-Tarjan algorithm & the size each node
+// retains, that is the size of the nodes it
+// dominates. Unreachable nodes retain nothing.
+func (g *graph) dominate() {
+	n := g.size()
+
+	dfnum := make([]int32, n)
+	for i := range dfnum {
+		dfnum[i] = noNode
+	}
+	parent := make([]int32, n)
+	vertex := make([]int32, 0, n)
+
+	// iterative depth-first search,
+	// cursor tracks the next reference
+	// to visit
+	type frame struct {
+		node   int32
+		cursor int32
+	}
+	stack := []frame{{node: 0, cursor: g.start[0]}}
+	dfnum[0] = 0
+	parent[0] = noNode
+	vertex = append(vertex, 0)
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.cursor == g.start[top.node+1] {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := g.refs[top.cursor]
+		top.cursor++
+		if dfnum[next] != noNode {
+			continue
+		}
+		dfnum[next] = int32(len(vertex))
+		parent[next] = top.node
+		vertex = append(vertex, next)
+		stack = append(stack, frame{node: next, cursor: g.start[next]})
+	}
+
+	// predecessors of reachable
+	// nodes, as sparse rows
+	predStart := make([]int32, n+1)
+	for v := range n {
+		if dfnum[v] == noNode {
+			continue
+		}
+		for _, w := range g.references(int32(v)) {
+			predStart[w+1]++
+		}
+	}
+	for v := range n {
+		predStart[v+1] += predStart[v]
+	}
+	preds := make([]int32, predStart[n])
+	fill := make([]int32, n)
+	copy(fill, predStart[:n])
+	for v := range n {
+		if dfnum[v] == noNode {
+			continue
+		}
+		for _, w := range g.references(int32(v)) {
+			preds[fill[w]] = int32(v)
+			fill[w]++
+		}
+	}
+
+	semi := make([]int32, n)
+	copy(semi, dfnum)
+	idom := make([]int32, n)
+	ancestor := make([]int32, n)
+	label := make([]int32, n)
+	bucketHead := make([]int32, n)
+	bucketNext := make([]int32, n)
+	for i := range n {
+		idom[i] = noNode
+		ancestor[i] = noNode
+		label[i] = int32(i)
+		bucketHead[i] = noNode
+		bucketNext[i] = noNode
+	}
+
+	var path []int32
+
+	for i := len(vertex) - 1; i > 0; i-- {
+		w := vertex[i]
+		for _, v := range preds[predStart[w]:predStart[w+1]] {
+			u := evaluate(v, ancestor, label, semi, &path)
+			if semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+
+		s := vertex[semi[w]]
+		bucketNext[w] = bucketHead[s]
+		bucketHead[s] = w
+
+		p := parent[w]
+		ancestor[w] = p
+
+		for v := bucketHead[p]; v != noNode; v = bucketNext[v] {
+			u := evaluate(v, ancestor, label, semi, &path)
+			if semi[u] < semi[v] {
+				idom[v] = u
+			} else {
+				idom[v] = p
+			}
+		}
+		bucketHead[p] = noNode
+	}
+
+	for _, w := range vertex[1:] {
+		if idom[w] != vertex[semi[w]] {
+			idom[w] = idom[idom[w]]
+		}
+	}
+
+	retained := make([]uint64, n)
+	for _, v := range vertex {
+		retained[v] = g.shallow[v]
+	}
+	for i := len(vertex) - 1; i > 0; i-- {
+		w := vertex[i]
+		retained[idom[w]] += retained[w]
+	}
+
+	g.idom = idom
+	g.order = vertex
+	g.retained = retained
+}
+
+// evaluate finds the node of least semi-dominator on the
+// path from v to its topmost linked ancestor, compressing
+// the path along the way.
+func evaluate(v int32, ancestor, label, semi []int32, path *[]int32) int32 {
+	if ancestor[v] == noNode {
+		return v
+	}
+
+	p := (*path)[:0]
+	for x := v; ancestor[ancestor[x]] != noNode; x = ancestor[x] {
+		p = append(p, x)
+	}
+	for i := len(p) - 1; i >= 0; i-- {
+		x := p[i]
+		a := ancestor[x]
+		if semi[label[a]] < semi[label[x]] {
+			label[x] = label[a]
+		}
+		ancestor[x] = ancestor[a]
+	}
+	*path = p
+
+	return label[v]
+}
+
+// shortestPaths finds the shortest reference path
+// from the root to every reachable node, breadth
+// first.
+func (g *graph) shortestPaths() {
+	n := g.size()
+	g.parent = make([]int32, n)
+	g.parentLabel = make([]int32, n)
+	for i := range n {
+		g.parent[i] = noNode
+	}
+
+	visited := make([]bool, n)
+	visited[0] = true
+	queue := []int32{0}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for i := g.start[v]; i < g.start[v+1]; i++ {
+			w := g.refs[i]
+			if visited[w] {
+				continue
+			}
+			visited[w] = true
+			g.parent[w] = v
+			g.parentLabel[w] = g.labels[i]
+			queue = append(queue, w)
+		}
+	}
+}
+
+// path gets the shortest reference path from a GC
+// root to the node, the root first. Each reference
+// names the field or array element holding the
+// next one, the last names the node's class.
+func (g *graph) path(node int32) (refs []Reference) {
+	for v := node; v > 0; v = g.parent[v] {
+		ref := Reference{ClassName: g.h.className(g.object(v))}
+		refs = append(refs, ref)
+	}
+
+	// name the reference each
+	// hop holds to the next
+	for i, v := 0, node; v > 0 && g.parent[v] > 0; i, v = i+1, g.parent[v] {
+		p := g.parent[v]
+		name := "[]"
+		if l := g.parentLabel[v]; l != arrayElement {
+			name = g.names[l]
+		} else if o := g.object(p); o.kind == kindObjectArray {
+			name = g.arrayIndex(o, g.object(v).id)
+		}
+		refs[i+1].Name = name
+	}
+
+	for i, j := 0, len(refs)-1; i < j; i, j = i+1, j-1 {
+		refs[i], refs[j] = refs[j], refs[i]
+	}
+
+	return
+}
+
+// arrayIndex names the element of the
+// array referencing the object, like
+// "[3]".
+func (g *graph) arrayIndex(array object, id uint64) string {
+	size := g.h.idSize
+	for j := range int(array.length) {
+		if readID(g.h.data[array.offset+j*size:], size) == id {
+			return "[" + strconv.Itoa(j) + "]"
+		}
+	}
+	return "[]"
+}
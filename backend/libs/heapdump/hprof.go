@@ -0,0 +1,577 @@
+package heapdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// hprofHeader is the format name every HPROF
+// file starts with, followed by the version.
+const hprofHeader = "JAVA PROFILE "
+
+// Record tags of the records
+// read, others are skipped.
+const (
+	tagString          = 0x01
+	tagLoadClass       = 0x02
+	tagHeapDump        = 0x0c
+	tagHeapDumpSegment = 0x1c
+)
+
+// Sub-record tags of heap dump records, including
+// the ones Android's runtime adds.
+const (
+	subRootUnknown          = 0xff
+	subRootJNIGlobal        = 0x01
+	subRootJNILocal         = 0x02
+	subRootJavaFrame        = 0x03
+	subRootNativeStack      = 0x04
+	subRootStickyClass      = 0x05
+	subRootThreadBlock      = 0x06
+	subRootMonitorUsed      = 0x07
+	subRootThreadObject     = 0x08
+	subClassDump            = 0x20
+	subInstanceDump         = 0x21
+	subObjectArrayDump      = 0x22
+	subPrimitiveArrayDump   = 0x23
+	subRootInternedString   = 0x89
+	subRootFinalizing       = 0x8a
+	subRootDebugger         = 0x8b
+	subRootReferenceCleanup = 0x8c
+	subRootVMInternal       = 0x8d
+	subRootJNIMonitor       = 0x8e
+	subUnreachable          = 0x90
+	subPrimitiveArrayNoData = 0xc3
+	subHeapDumpInfo         = 0xfe
+)
+
+// Basic types of fields & array elements.
+const (
+	typeObject  = 2
+	typeBoolean = 4
+	typeChar    = 5
+	typeFloat   = 6
+	typeDouble  = 7
+	typeByte    = 8
+	typeShort   = 9
+	typeInt     = 10
+	typeLong    = 11
+)
+
+// primitiveNames are the Java names
+// of the primitive types.
+var primitiveNames = map[byte]string{
+	typeBoolean: "boolean",
+	typeChar:    "char",
+	typeFloat:   "float",
+	typeDouble:  "double",
+	typeByte:    "byte",
+	typeShort:   "short",
+	typeInt:     "int",
+	typeLong:    "long",
+}
+
+// descriptorNames are the Java names of
+// primitive types in type descriptors.
+var descriptorNames = map[byte]string{
+	'Z': "boolean",
+	'C': "char",
+	'F': "float",
+	'D': "double",
+	'B': "byte",
+	'S': "short",
+	'I': "int",
+	'J': "long",
+}
+
+// ErrInvalidHprof is returned when the data
+// isn't a well formed HPROF file.
+var ErrInvalidHprof = errors.New("invalid hprof")
+
+// Kinds of objects on the heap.
+const (
+	kindInstance = iota
+	kindObjectArray
+	kindPrimitiveArray
+	kindClass
+)
+
+// field is an instance or static
+// field of a class.
+type field struct {
+	name string
+	typ  byte
+}
+
+// class is a class dump.
+type class struct {
+	superID uint64
+	// size is the size of an instance,
+	// as reported by the runtime.
+	size uint32
+	// fields are the instance fields declared
+	// by the class, not its superclasses.
+	fields []field
+	// statics are the static fields
+	// holding references.
+	statics []field
+	// staticValues are the references
+	// held by statics.
+	staticValues []uint64
+	// staticSize is the size of
+	// every static field.
+	staticSize uint64
+}
+
+// object is an object on the heap. Instance fields &
+// array elements aren't copied, their offset into the
+// dump is kept & read when needed.
+type object struct {
+	id   uint64
+	kind uint8
+	// elemType is the type of the elements
+	// of primitive arrays.
+	elemType byte
+	// classID is the class of instances, the array
+	// class of object arrays & the class itself of
+	// classes.
+	classID uint64
+	// offset is the offset of the instance's
+	// field values or the array's elements.
+	offset int
+	// length is the byte length of instance field
+	// values or the count of array elements.
+	length uint32
+}
+
+// hprof is a parsed HPROF heap dump.
+type hprof struct {
+	data    []byte
+	idSize  int
+	strings map[uint64]string
+	// classNames are the names of loaded
+	// classes, by class object id.
+	classNames map[uint64]string
+	classes    map[uint64]*class
+	objects    []object
+	// index maps object ids to
+	// their index in objects.
+	index map[uint64]int32
+	roots []uint64
+}
+
+// reader reads big-endian values
+// of an HPROF file.
+type reader struct {
+	data   []byte
+	pos    int
+	idSize int
+	err    error
+}
+
+func (r *reader) need(n int) bool {
+	if r.err != nil {
+		return false
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("%w: truncated at offset %d", ErrInvalidHprof, r.pos)
+		return false
+	}
+	return true
+}
+
+func (r *reader) skip(n int) {
+	if r.need(n) {
+		r.pos += n
+	}
+}
+
+func (r *reader) u1() byte {
+	if !r.need(1) {
+		return 0
+	}
+	v := r.data[r.pos]
+	r.pos++
+	return v
+}
+
+func (r *reader) u2() uint16 {
+	if !r.need(2) {
+		return 0
+	}
+	v := binary.BigEndian.Uint16(r.data[r.pos:])
+	r.pos += 2
+	return v
+}
+
+func (r *reader) u4() uint32 {
+	if !r.need(4) {
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v
+}
+
+func (r *reader) id() uint64 {
+	if !r.need(r.idSize) {
+		return 0
+	}
+	v := readID(r.data[r.pos:], r.idSize)
+	r.pos += r.idSize
+	return v
+}
+
+// readID reads an id of size
+// 4 or 8 bytes.
+func readID(b []byte, size int) uint64 {
+	if size == 4 {
+		return uint64(binary.BigEndian.Uint32(b))
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// typeSize is the size of a value of the basic
+// type, zero for unknown types.
+func typeSize(typ byte, idSize int) int {
+	switch typ {
+	case typeObject:
+		return idSize
+	case typeBoolean, typeByte:
+		return 1
+	case typeChar, typeShort:
+		return 2
+	case typeFloat, typeInt:
+		return 4
+	case typeDouble, typeLong:
+		return 8
+	}
+	return 0
+}
+
+// parseHprof parses the records of an HPROF file needed to
+// build the object graph, skipping the rest.
+func parseHprof(data []byte) (h *hprof, err error) {
+	if !bytes.HasPrefix(data, []byte(hprofHeader)) {
+		err = fmt.Errorf("%w: missing header", ErrInvalidHprof)
+		return
+	}
+
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		err = fmt.Errorf("%w: unterminated header", ErrInvalidHprof)
+		return
+	}
+
+	r := &reader{data: data, pos: end + 1}
+	idSize := int(r.u4())
+	// timestamp
+	r.skip(8)
+	if r.err != nil {
+		err = r.err
+		return
+	}
+	if idSize != 4 && idSize != 8 {
+		err = fmt.Errorf("%w: unsupported id size %d", ErrInvalidHprof, idSize)
+		return
+	}
+	r.idSize = idSize
+
+	h = &hprof{
+		data:       data,
+		idSize:     idSize,
+		strings:    map[uint64]string{},
+		classNames: map[uint64]string{},
+		classes:    map[uint64]*class{},
+		index:      map[uint64]int32{},
+	}
+
+	for r.pos < len(data) && r.err == nil {
+		tag := r.u1()
+		// time offset
+		r.skip(4)
+		length := int(r.u4())
+		if !r.need(length) {
+			break
+		}
+		body := r.pos
+		r.pos += length
+
+		switch tag {
+		case tagString:
+			if length < idSize {
+				return nil, fmt.Errorf("%w: short string record", ErrInvalidHprof)
+			}
+			id := readID(data[body:], idSize)
+			h.strings[id] = string(data[body+idSize : body+length])
+		case tagLoadClass:
+			lr := &reader{data: data[:body+length], pos: body, idSize: idSize}
+			// class serial
+			lr.skip(4)
+			classID := lr.id()
+			// stack trace serial
+			lr.skip(4)
+			nameID := lr.id()
+			if lr.err != nil {
+				return nil, lr.err
+			}
+			h.classNames[classID] = javaName(h.strings[nameID])
+		case tagHeapDump, tagHeapDumpSegment:
+			hr := &reader{data: data[:body+length], pos: body, idSize: idSize}
+			if err = h.parseHeapDump(hr); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return
+}
+
+// parseHeapDump parses the sub-records
+// of a heap dump segment.
+func (h *hprof) parseHeapDump(r *reader) error {
+	for r.pos < len(r.data) && r.err == nil {
+		tag := r.u1()
+		switch tag {
+		case subRootUnknown, subRootStickyClass, subRootMonitorUsed, subRootInternedString,
+			subRootFinalizing, subRootDebugger, subRootReferenceCleanup, subRootVMInternal:
+			h.roots = append(h.roots, r.id())
+		case subRootJNIGlobal:
+			h.roots = append(h.roots, r.id())
+			// global ref id
+			r.skip(r.idSize)
+		case subRootJNILocal, subRootJavaFrame, subRootThreadObject, subRootJNIMonitor:
+			h.roots = append(h.roots, r.id())
+			r.skip(8)
+		case subRootNativeStack, subRootThreadBlock:
+			h.roots = append(h.roots, r.id())
+			r.skip(4)
+		case subUnreachable:
+			r.skip(r.idSize)
+		case subHeapDumpInfo:
+			r.skip(4 + r.idSize)
+		case subClassDump:
+			h.parseClassDump(r)
+		case subInstanceDump:
+			id := r.id()
+			r.skip(4)
+			classID := r.id()
+			length := r.u4()
+			offset := r.pos
+			r.skip(int(length))
+			h.add(object{id: id, kind: kindInstance, classID: classID, offset: offset, length: length})
+		case subObjectArrayDump:
+			id := r.id()
+			r.skip(4)
+			count := r.u4()
+			classID := r.id()
+			offset := r.pos
+			r.skip(int(count) * r.idSize)
+			h.add(object{id: id, kind: kindObjectArray, classID: classID, offset: offset, length: count})
+		case subPrimitiveArrayDump:
+			id := r.id()
+			r.skip(4)
+			count := r.u4()
+			typ := r.u1()
+			size := typeSize(typ, r.idSize)
+			if size == 0 {
+				return fmt.Errorf("%w: unknown array type %d", ErrInvalidHprof, typ)
+			}
+			offset := r.pos
+			r.skip(int(count) * size)
+			h.add(object{id: id, kind: kindPrimitiveArray, elemType: typ, offset: offset, length: count})
+		case subPrimitiveArrayNoData:
+			id := r.id()
+			r.skip(4)
+			count := r.u4()
+			typ := r.u1()
+			h.add(object{id: id, kind: kindPrimitiveArray, elemType: typ, offset: -1, length: count})
+		default:
+			return fmt.Errorf("%w: unknown heap dump sub-record 0x%02x at offset %d", ErrInvalidHprof, tag, r.pos-1)
+		}
+	}
+
+	return r.err
+}
+
+// parseClassDump parses a class dump
+// sub-record.
+func (h *hprof) parseClassDump(r *reader) {
+	id := r.id()
+	// stack trace serial
+	r.skip(4)
+	c := &class{superID: r.id()}
+	// class loader, signers, protection
+	// domain & 2 reserved ids
+	r.skip(5 * r.idSize)
+	c.size = r.u4()
+
+	constants := int(r.u2())
+	for range constants {
+		// pool index
+		r.skip(2)
+		r.skip(typeSize(r.u1(), r.idSize))
+	}
+
+	statics := int(r.u2())
+	for range statics {
+		name := h.strings[r.id()]
+		typ := r.u1()
+		size := typeSize(typ, r.idSize)
+		c.staticSize += uint64(size)
+		if typ == typeObject {
+			c.statics = append(c.statics, field{name: name, typ: typ})
+			c.staticValues = append(c.staticValues, r.id())
+			continue
+		}
+		r.skip(size)
+	}
+
+	fields := int(r.u2())
+	c.fields = make([]field, 0, fields)
+	for range fields {
+		name := h.strings[r.id()]
+		c.fields = append(c.fields, field{name: name, typ: r.u1()})
+	}
+
+	if r.err != nil {
+		return
+	}
+
+	h.classes[id] = c
+	h.add(object{id: id, kind: kindClass, classID: id})
+}
+
+// add adds an object to the heap. Objects
+// dumped twice are kept once.
+func (h *hprof) add(o object) {
+	if _, ok := h.index[o.id]; ok {
+		return
+	}
+	h.index[o.id] = int32(len(h.objects))
+	h.objects = append(h.objects, o)
+}
+
+// className gets the name of
+// the object's class.
+func (h *hprof) className(o object) string {
+	switch o.kind {
+	case kindPrimitiveArray:
+		return primitiveNames[o.elemType] + "[]"
+	case kindClass:
+		return h.classNames[o.id]
+	}
+
+	if name, ok := h.classNames[o.classID]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown@0x%x", o.classID)
+}
+
+// isSubclass reports whether the class or any
+// of its superclasses has one of the names.
+func (h *hprof) isSubclass(classID uint64, names ...string) bool {
+	for id := classID; id != 0; {
+		for _, name := range names {
+			if h.classNames[id] == name {
+				return true
+			}
+		}
+		c, ok := h.classes[id]
+		if !ok {
+			return false
+		}
+		id = c.superID
+	}
+	return false
+}
+
+// superclass gets the first of the class or its
+// superclasses found in names, along with the
+// value it maps to.
+func superclass[V any](h *hprof, classID uint64, names map[string]V) (name string, v V, ok bool) {
+	for id := classID; id != 0; {
+		name = h.classNames[id]
+		if v, ok = names[name]; ok {
+			return
+		}
+		c, found := h.classes[id]
+		if !found {
+			break
+		}
+		id = c.superID
+	}
+	return
+}
+
+// fieldValue reads the value of an instance's field
+// named name, declared by the class or its
+// superclasses. Values are zero-extended.
+func (h *hprof) fieldValue(o object, name string) (value uint64, typ byte, ok bool) {
+	if o.kind != kindInstance {
+		return
+	}
+
+	offset := o.offset
+	end := o.offset + int(o.length)
+	for id := o.classID; id != 0; {
+		c, found := h.classes[id]
+		if !found {
+			return
+		}
+		for _, f := range c.fields {
+			size := typeSize(f.typ, h.idSize)
+			if size == 0 || offset+size > end {
+				return
+			}
+			if f.name == name {
+				return readValue(h.data[offset:], size), f.typ, true
+			}
+			offset += size
+		}
+		id = c.superID
+	}
+
+	return
+}
+
+// readValue reads a big-endian
+// value of size bytes.
+func readValue(b []byte, size int) (v uint64) {
+	for i := range size {
+		v = v<<8 | uint64(b[i])
+	}
+	return
+}
+
+// javaName converts a class name as written by the runtime
+// to a Java name, like `java.lang.String[]`. HotSpot writes
+// internal names, like `java/lang/String` or
+// `[Ljava/lang/String;`.
+func javaName(name string) string {
+	dims := 0
+	for dims < len(name) && name[dims] == '[' {
+		dims++
+	}
+
+	if dims > 0 {
+		elem := name[dims:]
+		switch {
+		case strings.HasPrefix(elem, "L") && strings.HasSuffix(elem, ";"):
+			elem = elem[1 : len(elem)-1]
+		case len(elem) == 1 && descriptorNames[elem[0]] != "":
+			elem = descriptorNames[elem[0]]
+		}
+		name = elem + strings.Repeat("[]", dims)
+	}
+
+	return strings.ReplaceAll(name, "/", ".")
+}
@@ -0,0 +1,211 @@
+package heapdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// hprofBuilder writes HPROF dumps with
+// 4 byte ids for tests.
+type hprofBuilder struct {
+	records bytes.Buffer
+	heap    bytes.Buffer
+	strings map[string]uint64
+	nextID  uint64
+}
+
+func newHprofBuilder() *hprofBuilder {
+	return &hprofBuilder{strings: map[string]uint64{}, nextID: 0x1000}
+}
+
+func u2(b *bytes.Buffer, v uint16) { binary.Write(b, binary.BigEndian, v) }
+func u4(b *bytes.Buffer, v uint32) { binary.Write(b, binary.BigEndian, v) }
+
+// str gets the id of a string,
+// writing its record once.
+func (b *hprofBuilder) str(s string) uint32 {
+	if id, ok := b.strings[s]; ok {
+		return uint32(id)
+	}
+	b.nextID++
+	b.strings[s] = b.nextID
+	b.record(tagString, append(binary.BigEndian.AppendUint32(nil, uint32(b.nextID)), s...))
+	return uint32(b.nextID)
+}
+
+func (b *hprofBuilder) record(tag byte, body []byte) {
+	b.records.WriteByte(tag)
+	u4(&b.records, 0)
+	u4(&b.records, uint32(len(body)))
+	b.records.Write(body)
+}
+
+// class loads & dumps a class. Static
+// fields all hold references.
+func (b *hprofBuilder) class(id, super uint32, name string, statics map[string]uint32, fields ...field) {
+	nameID := b.str(name)
+	var body bytes.Buffer
+	u4(&body, 1)
+	u4(&body, id)
+	u4(&body, 0)
+	u4(&body, nameID)
+	b.record(tagLoadClass, body.Bytes())
+
+	size := 0
+	for _, f := range fields {
+		size += typeSize(f.typ, 4)
+	}
+
+	h := &b.heap
+	h.WriteByte(subClassDump)
+	u4(h, id)
+	u4(h, 0)
+	u4(h, super)
+	for range 5 {
+		u4(h, 0)
+	}
+	u4(h, uint32(size))
+	u2(h, 0)
+	u2(h, uint16(len(statics)))
+	for name, value := range statics {
+		u4(h, b.str(name))
+		h.WriteByte(typeObject)
+		u4(h, value)
+	}
+	u2(h, uint16(len(fields)))
+	for _, f := range fields {
+		u4(h, b.str(f.name))
+		h.WriteByte(f.typ)
+	}
+}
+
+func (b *hprofBuilder) instance(id, classID uint32, values []byte) {
+	h := &b.heap
+	h.WriteByte(subInstanceDump)
+	u4(h, id)
+	u4(h, 0)
+	u4(h, classID)
+	u4(h, uint32(len(values)))
+	h.Write(values)
+}
+
+func (b *hprofBuilder) objectArray(id, classID uint32, elems ...uint32) {
+	h := &b.heap
+	h.WriteByte(subObjectArrayDump)
+	u4(h, id)
+	u4(h, 0)
+	u4(h, uint32(len(elems)))
+	u4(h, classID)
+	for _, e := range elems {
+		u4(h, e)
+	}
+}
+
+func (b *hprofBuilder) byteArray(id uint32, n int) {
+	h := &b.heap
+	h.WriteByte(subPrimitiveArrayDump)
+	u4(h, id)
+	u4(h, 0)
+	u4(h, uint32(n))
+	h.WriteByte(typeByte)
+	h.Write(make([]byte, n))
+}
+
+func (b *hprofBuilder) root(id uint32) {
+	b.heap.WriteByte(subRootStickyClass)
+	u4(&b.heap, id)
+}
+
+func (b *hprofBuilder) bytes() []byte {
+	var out bytes.Buffer
+	out.WriteString("JAVA PROFILE 1.0.3\x00")
+	u4(&out, 4)
+	out.Write(make([]byte, 8))
+	out.Write(b.records.Bytes())
+	out.WriteByte(tagHeapDumpSegment)
+	u4(&out, 0)
+	u4(&out, uint32(b.heap.Len()))
+	out.Write(b.heap.Bytes())
+	return out.Bytes()
+}
+
+// values encodes field values,
+// ids & ints as 4 bytes.
+func values(vs ...any) []byte {
+	var b bytes.Buffer
+	for _, v := range vs {
+		switch v := v.(type) {
+		case bool:
+			if v {
+				b.WriteByte(1)
+			} else {
+				b.WriteByte(0)
+			}
+		case uint32:
+			u4(&b, v)
+		case int32:
+			u4(&b, uint32(v))
+		}
+	}
+	return b.Bytes()
+}
+
+func TestJavaName(t *testing.T) {
+	tests := map[string]string{
+		"java.lang.String":     "java.lang.String",
+		"java/lang/String":     "java.lang.String",
+		"[Ljava/lang/Object;":  "java.lang.Object[]",
+		"[[I":                  "int[][]",
+		"java.lang.Object[]":   "java.lang.Object[]",
+		"com/example/Foo$Bar":  "com.example.Foo$Bar",
+		"[Lcom/example/Foo$1;": "com.example.Foo$1[]",
+	}
+
+	for name, want := range tests {
+		if got := javaName(name); got != want {
+			t.Errorf("javaName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestParseHprofInvalid(t *testing.T) {
+	if _, err := Analyze([]byte("not a heap dump")); !errors.Is(err, ErrInvalidHprof) {
+		t.Errorf("err = %v, want ErrInvalidHprof", err)
+	}
+
+	b := newHprofBuilder()
+	b.class(0x10, 0, "java.lang.Object", nil)
+	data := b.bytes()
+	if _, err := Analyze(data[:len(data)-3]); !errors.Is(err, ErrInvalidHprof) {
+		t.Errorf("err = %v, want ErrInvalidHprof for truncated dump", err)
+	}
+}
+
+func TestFieldValue(t *testing.T) {
+	b := newHprofBuilder()
+	b.class(0x10, 0, "java.lang.Object", nil)
+	b.class(0x11, 0x10, "com.example.Base", nil, field{"count", typeInt})
+	b.class(0x12, 0x11, "com.example.Child", nil, field{"done", typeBoolean}, field{"next", typeObject})
+	// fields of the class come
+	// before its superclass's
+	b.instance(0x100, 0x12, values(true, uint32(0x200), int32(42)))
+
+	h, err := parseHprof(b.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := h.objects[h.index[0x100]]
+	for name, want := range map[string]uint64{"done": 1, "next": 0x200, "count": 42} {
+		got, _, ok := h.fieldValue(o, name)
+		if !ok || got != want {
+			t.Errorf("field %q = %d, %v, want %d", name, got, ok, want)
+		}
+	}
+
+	if _, _, ok := h.fieldValue(o, "missing"); ok {
+		t.Error("missing field found")
+	}
+}
@@ -0,0 +1,252 @@
+package heapdump
+
+import (
+	"context"
+	"time"
+
+	"backend/libs/chquery"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// maxLeakSummaries is the number of leak
+// suspects aggregated across dumps fetched.
+const maxLeakSummaries = 100
+
+// Dump is the report of a heap dump
+// attached to an event.
+type Dump struct {
+	EventID     uuid.UUID `json:"event_id"`
+	EventType   string    `json:"event_type"`
+	SessionID   uuid.UUID `json:"session_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	VersionName string    `json:"version_name"`
+	VersionCode string    `json:"version_code"`
+	Report
+}
+
+// LeakSummary is a leak suspect aggregated
+// across the heap dumps of an app version.
+type LeakSummary struct {
+	VersionName string `json:"version_name"`
+	VersionCode string `json:"version_code"`
+	Kind        string `json:"kind"`
+	ClassName   string `json:"class_name"`
+	// Dumps is the count of heap dumps
+	// the suspect was found in.
+	Dumps uint64 `json:"dumps"`
+	// Sessions is the count of sessions
+	// the suspect was found in.
+	Sessions uint64 `json:"sessions"`
+	// Instances is the count of suspect
+	// instances across dumps.
+	Instances uint64 `json:"instances"`
+	// RetainedSize is the size the suspect
+	// retains across dumps, in bytes.
+	RetainedSize uint64 `json:"retained_size"`
+	// MaxRetainedSize is the most the suspect
+	// retains in a dump, in bytes.
+	MaxRetainedSize uint64 `json:"max_retained_size"`
+	// MaxElements is the element count of the
+	// largest suspect collection.
+	MaxElements uint64 `json:"max_elements"`
+}
+
+// Query selects the heap dumps whose leak
+// suspects are aggregated. Empty fields
+// don't filter.
+type Query struct {
+	// From and To bound the time
+	// dumps were taken at.
+	From time.Time
+	To   time.Time
+	// Version and VersionCode
+	// pick an app version.
+	Version     string
+	VersionCode string
+	// Kind picks a kind of leak,
+	// like "destroyed_activity".
+	Kind string
+}
+
+// GetSessionDumps fetches the reports of the
+// session's heap dumps, oldest first.
+func GetSessionDumps(ctx context.Context, rch driver.Conn, teamID, appID, sessionID uuid.UUID) (dumps []Dump, err error) {
+	stmt := dumpsStmt(teamID, appID).
+		Where("session_id = toUUID(?)", sessionID)
+
+	return getDumps(ctx, rch, teamID, stmt)
+}
+
+// GetEventDumps fetches the reports of the
+// heap dumps attached to the events, oldest
+// first.
+func GetEventDumps(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, eventIDs []uuid.UUID) (dumps []Dump, err error) {
+	if len(eventIDs) == 0 {
+		return
+	}
+
+	stmt := dumpsStmt(teamID, appID).
+		Where("event_id in ?", eventIDs)
+
+	return getDumps(ctx, rch, teamID, stmt)
+}
+
+// dumpsStmt selects the reports
+// of the app's heap dumps.
+func dumpsStmt(teamID, appID uuid.UUID) *sqlf.Stmt {
+	return sqlf.From("heap_dumps final").
+		Select("event_id").
+		Select("event_type").
+		Select("session_id").
+		Select("timestamp").
+		Select("app_version.1").
+		Select("app_version.2").
+		Select("heap_size").
+		Select("objects").
+		Select("class_names").
+		Select("class_instances").
+		Select("class_shallow_sizes").
+		Select("class_retained_sizes").
+		Select("leak_kinds").
+		Select("leak_class_names").
+		Select("leak_instances").
+		Select("leak_retained_sizes").
+		Select("leak_elements").
+		Select("leak_path_classes").
+		Select("leak_path_names").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		OrderBy("timestamp")
+}
+
+// getDumps runs a statement built
+// by dumpsStmt.
+func getDumps(ctx context.Context, rch driver.Conn, teamID uuid.UUID, stmt *sqlf.Stmt) (dumps []Dump, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Dump
+		var classNames, leakKinds, leakClassNames []string
+		var classInstances, classShallowSizes, classRetainedSizes []uint64
+		var leakInstances, leakRetainedSizes, leakElements []uint64
+		var leakPathClasses, leakPathNames [][]string
+
+		if err = rows.Scan(&d.EventID, &d.EventType, &d.SessionID, &d.Timestamp, &d.VersionName, &d.VersionCode,
+			&d.HeapSize, &d.Objects,
+			&classNames, &classInstances, &classShallowSizes, &classRetainedSizes,
+			&leakKinds, &leakClassNames, &leakInstances, &leakRetainedSizes, &leakElements,
+			&leakPathClasses, &leakPathNames); err != nil {
+			return
+		}
+
+		d.Classes = make([]ClassSize, len(classNames))
+		for i, name := range classNames {
+			d.Classes[i] = ClassSize{
+				ClassName:    name,
+				Instances:    at(classInstances, i),
+				ShallowSize:  at(classShallowSizes, i),
+				RetainedSize: at(classRetainedSizes, i),
+			}
+		}
+
+		d.Leaks = make([]Leak, len(leakKinds))
+		for i, kind := range leakKinds {
+			classes, names := at(leakPathClasses, i), at(leakPathNames, i)
+			path := make([]Reference, len(classes))
+			for j, class := range classes {
+				path[j] = Reference{ClassName: class, Name: at(names, j)}
+			}
+			d.Leaks[i] = Leak{
+				Kind:         kind,
+				ClassName:    at(leakClassNames, i),
+				Instances:    at(leakInstances, i),
+				RetainedSize: at(leakRetainedSizes, i),
+				Elements:     at(leakElements, i),
+				Path:         path,
+			}
+		}
+
+		dumps = append(dumps, d)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// GetLeaks aggregates the leak suspects of the heap dumps the
+// query selects by app version, kind & class, the suspects
+// retaining the most memory first.
+func GetLeaks(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, q Query) (leaks []LeakSummary, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	stmt := sqlf.
+		From(`heap_dumps final array join
+			leak_kinds as kind,
+			leak_class_names as class_name,
+			leak_instances as instances,
+			leak_retained_sizes as retained_size,
+			leak_elements as elements`).
+		Select("app_version.1 as version_name").
+		Select("app_version.2 as version_code").
+		Select("kind").
+		Select("class_name").
+		Select("uniq(event_id)").
+		Select("uniq(session_id)").
+		Select("sum(instances)").
+		Select("sum(retained_size) as total_retained_size").
+		Select("max(retained_size)").
+		Select("max(elements)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= toDateTime64(?, 3, 'UTC')", q.From).
+		Where("timestamp <= toDateTime64(?, 3, 'UTC')", q.To).
+		GroupBy("version_name, version_code, kind, class_name").
+		OrderBy("total_retained_size desc, version_name desc, version_code desc, kind, class_name").
+		Limit(maxLeakSummaries)
+
+	if q.Version != "" && q.VersionCode != "" {
+		stmt.Where("app_version.1 = ? and app_version.2 = ?", q.Version, q.VersionCode)
+	}
+	if q.Kind != "" {
+		stmt.Where("kind = ?", q.Kind)
+	}
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var l LeakSummary
+		if err = rows.Scan(&l.VersionName, &l.VersionCode, &l.Kind, &l.ClassName, &l.Dumps, &l.Sessions, &l.Instances, &l.RetainedSize, &l.MaxRetainedSize, &l.MaxElements); err != nil {
+			return
+		}
+		leaks = append(leaks, l)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// at gets the element at i or the zero
+// value, guarding against arrays of
+// mismatched lengths.
+func at[T any](s []T, i int) (v T) {
+	if i < len(s) {
+		v = s[i]
+	}
+	return
+}
@@ -0,0 +1,465 @@
+package heapdump
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// Kinds of leak suspects.
+const (
+	// LeakDestroyedActivity is an Activity still
+	// reachable after it was destroyed.
+	LeakDestroyedActivity = "destroyed_activity"
+
+	// LeakDestroyedFragment is a Fragment still
+	// reachable after it was detached from its
+	// fragment manager.
+	LeakDestroyedFragment = "destroyed_fragment"
+
+	// LeakLargeBitmap is a Bitmap whose pixels
+	// outsize a full HD screen.
+	LeakLargeBitmap = "large_bitmap"
+
+	// LeakLargeCollection is a collection holding
+	// more than largeCollectionSize elements. Growth
+	// shows when its size is compared across dumps.
+	LeakLargeCollection = "large_collection"
+)
+
+// maxClasses is the number of classes retaining
+// the most memory kept in a report.
+const maxClasses = 50
+
+// maxLeaks is the number of leak suspects
+// kept in a report.
+const maxLeaks = 50
+
+// largeBitmapSize is the pixel size above which a
+// bitmap is suspect, in bytes. A full HD ARGB
+// screen is just under 8 MiB.
+const largeBitmapSize = 8 << 20
+
+// largeCollectionSize is the element count above
+// which a collection is suspect.
+const largeCollectionSize = 10_000
+
+// activityClasses are the base classes
+// of activities.
+var activityClasses = []string{
+	"android.app.Activity",
+}
+
+// fragmentClasses are the base classes of
+// platform, support & androidx fragments.
+var fragmentClasses = []string{
+	"androidx.fragment.app.Fragment",
+	"android.support.v4.app.Fragment",
+	"android.app.Fragment",
+}
+
+// bitmapClass is the class of bitmaps.
+const bitmapClass = "android.graphics.Bitmap"
+
+// collectionSizes maps collection classes to the field
+// holding their element count. Subclasses inherit
+// the field.
+var collectionSizes = map[string]string{
+	"java.util.ArrayList":                        "size",
+	"java.util.LinkedList":                       "size",
+	"java.util.HashMap":                          "size",
+	"java.util.TreeMap":                          "size",
+	"java.util.IdentityHashMap":                  "size",
+	"java.util.WeakHashMap":                      "size",
+	"java.util.Vector":                           "elementCount",
+	"java.util.concurrent.ConcurrentHashMap":     "baseCount",
+	"java.util.concurrent.LinkedBlockingQueue":   "count",
+	"java.util.concurrent.ConcurrentLinkedQueue": "size",
+	"android.util.ArrayMap":                      "mSize",
+	"android.util.ArraySet":                      "mSize",
+	"android.util.SparseArray":                   "mSize",
+	"android.util.LongSparseArray":               "mSize",
+	"androidx.collection.ArrayMap":               "size",
+	"androidx.collection.SimpleArrayMap":         "size",
+	"androidx.collection.SparseArrayCompat":      "size",
+}
+
+// Report summarizes a heap dump.
+type Report struct {
+	// HeapSize is the estimated size of every
+	// reachable object, in bytes.
+	HeapSize uint64 `json:"heap_size"`
+	// Objects is the count of
+	// reachable objects.
+	Objects uint64 `json:"objects"`
+	// Classes are the classes retaining the
+	// most memory, most retained first.
+	Classes []ClassSize `json:"classes"`
+	// Leaks are the leak suspects, most
+	// retained first.
+	Leaks []Leak `json:"leaks"`
+}
+
+// ClassSize is the memory the reachable
+// instances of a class take.
+type ClassSize struct {
+	ClassName string `json:"class_name"`
+	// Instances is the count of
+	// reachable instances.
+	Instances uint64 `json:"instances"`
+	// ShallowSize is the size of the
+	// instances themselves, in bytes.
+	ShallowSize uint64 `json:"shallow_size"`
+	// RetainedSize is the size freed were the
+	// instances collected, in bytes.
+	RetainedSize uint64 `json:"retained_size"`
+}
+
+// Leak is a group of suspected leaking
+// instances of a class.
+type Leak struct {
+	// Kind is the kind of leak, like
+	// "destroyed_activity".
+	Kind      string `json:"kind"`
+	ClassName string `json:"class_name"`
+	// Instances is the count of
+	// suspect instances.
+	Instances uint64 `json:"instances"`
+	// RetainedSize is the size the suspect
+	// instances retain, in bytes. Bitmaps count
+	// their pixels, which may live outside the
+	// Java heap.
+	RetainedSize uint64 `json:"retained_size"`
+	// Elements is the element count of the
+	// largest suspect collection.
+	Elements uint64 `json:"elements"`
+	// Path is the shortest reference path from a
+	// GC root to the suspect instance retaining
+	// the most.
+	Path []Reference `json:"path"`
+}
+
+// Reference is a hop on a path of references.
+type Reference struct {
+	ClassName string `json:"class_name"`
+	// Name is the field or the array element, like
+	// "[3]", referencing the next hop. Empty for the
+	// last hop.
+	Name string `json:"name"`
+}
+
+// String formats the reference like
+// "com.example.Foo.bar" or "java.lang.Object[][3]".
+func (r Reference) String() string {
+	switch {
+	case r.Name == "":
+		return r.ClassName
+	case strings.HasPrefix(r.Name, "["):
+		return r.ClassName + r.Name
+	}
+	return r.ClassName + "." + r.Name
+}
+
+// suspect is a suspected leaking instance.
+type suspect struct {
+	node     int32
+	kind     string
+	retained uint64
+	elements uint64
+}
+
+// Analyze parses an HPROF heap dump, computes the memory
+// each reachable object retains & finds leak suspects:
+// destroyed activities & fragments still reachable,
+// large bitmaps & large collections.
+func Analyze(data []byte) (r Report, err error) {
+	h, err := parseHprof(data)
+	if err != nil {
+		return
+	}
+
+	g := buildGraph(h)
+	g.dominate()
+	g.shortestPaths()
+
+	r.Classes = g.classSizes()
+	if r.Classes == nil {
+		r.Classes = []ClassSize{}
+	}
+	r.Leaks = g.leaks()
+	if r.Leaks == nil {
+		r.Leaks = []Leak{}
+	}
+	for _, v := range g.order[1:] {
+		r.HeapSize += g.shallow[v]
+		r.Objects++
+	}
+
+	return
+}
+
+// classSizes sums the sizes of reachable instances by
+// class. An instance dominated by another instance of
+// its class adds to the count & shallow size but not
+// to the retained size, it's retained already. Class
+// objects are left out, their statics would otherwise
+// retain all that's reachable from them.
+func (g *graph) classSizes() (classes []ClassSize) {
+	byName := map[string]*ClassSize{}
+	for _, v := range g.order[1:] {
+		o := g.object(v)
+		if o.kind == kindClass {
+			continue
+		}
+
+		name := g.h.className(o)
+		c, ok := byName[name]
+		if !ok {
+			c = &ClassSize{ClassName: name}
+			byName[name] = c
+		}
+
+		c.Instances++
+		c.ShallowSize += g.shallow[v]
+		if d := g.idom[v]; d <= 0 || g.h.className(g.object(d)) != name {
+			c.RetainedSize += g.retained[v]
+		}
+	}
+
+	for _, c := range byName {
+		classes = append(classes, *c)
+	}
+
+	slices.SortFunc(classes, func(a, b ClassSize) int {
+		if n := cmp.Compare(b.RetainedSize, a.RetainedSize); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.ClassName, b.ClassName)
+	})
+
+	if len(classes) > maxClasses {
+		classes = classes[:maxClasses]
+	}
+
+	return
+}
+
+// suspectClass is how instances of a class are
+// checked for leaks, by kind. sizeField is the
+// element count field of collections.
+type suspectClass struct {
+	kind      string
+	sizeField string
+}
+
+// suspectClassOf gets how instances of the
+// class are checked for leaks, empty kind for
+// classes that aren't.
+func (h *hprof) suspectClassOf(classID uint64) suspectClass {
+	switch {
+	case h.isSubclass(classID, activityClasses...):
+		return suspectClass{kind: LeakDestroyedActivity}
+	case h.isSubclass(classID, fragmentClasses...):
+		return suspectClass{kind: LeakDestroyedFragment}
+	case h.isSubclass(classID, bitmapClass):
+		return suspectClass{kind: LeakLargeBitmap}
+	}
+
+	if _, sizeField, ok := superclass(h, classID, collectionSizes); ok {
+		return suspectClass{kind: LeakLargeCollection, sizeField: sizeField}
+	}
+
+	return suspectClass{}
+}
+
+// suspects finds the reachable
+// instances suspected to leak.
+func (g *graph) suspects() (suspects []suspect) {
+	h := g.h
+	classes := map[uint64]suspectClass{}
+	for _, v := range g.order[1:] {
+		o := g.object(v)
+		if o.kind != kindInstance {
+			continue
+		}
+
+		sc, ok := classes[o.classID]
+		if !ok {
+			sc = h.suspectClassOf(o.classID)
+			classes[o.classID] = sc
+		}
+
+		switch sc.kind {
+		case LeakDestroyedActivity:
+			if destroyed, _, ok := h.fieldValue(o, "mDestroyed"); ok && destroyed != 0 {
+				suspects = append(suspects, suspect{node: v, kind: sc.kind, retained: g.retained[v]})
+			}
+		case LeakDestroyedFragment:
+			if manager, _, ok := h.fieldValue(o, "mFragmentManager"); ok && manager == 0 {
+				suspects = append(suspects, suspect{node: v, kind: sc.kind, retained: g.retained[v]})
+			}
+		case LeakLargeBitmap:
+			width, _, wok := h.fieldValue(o, "mWidth")
+			height, _, hok := h.fieldValue(o, "mHeight")
+			if !wok || !hok || int32(width) <= 0 || int32(height) <= 0 {
+				continue
+			}
+			// ARGB_8888, the default config
+			pixels := uint64(int32(width)) * uint64(int32(height)) * 4
+			if pixels > largeBitmapSize {
+				suspects = append(suspects, suspect{node: v, kind: sc.kind, retained: max(g.retained[v], pixels)})
+			}
+		case LeakLargeCollection:
+			size, typ, ok := h.fieldValue(o, sc.sizeField)
+			if !ok {
+				continue
+			}
+			if typ == typeInt {
+				size = uint64(max(int32(size), 0))
+			}
+			if size > largeCollectionSize {
+				suspects = append(suspects, suspect{node: v, kind: sc.kind, retained: g.retained[v], elements: size})
+			}
+		}
+	}
+
+	return
+}
+
+// leaks groups the suspects by kind & class. Suspects
+// dominated by another suspect add to the count but
+// not to the retained size, it's retained already.
+func (g *graph) leaks() (leaks []Leak) {
+	suspects := g.suspects()
+
+	isSuspect := make(map[int32]bool, len(suspects))
+	for _, s := range suspects {
+		isSuspect[s.node] = true
+	}
+
+	type group struct {
+		leak Leak
+		// top is the suspect
+		// retaining the most
+		top     int32
+		topSize uint64
+	}
+	groups := map[[2]string]*group{}
+
+	for _, s := range suspects {
+		name := g.h.className(g.object(s.node))
+		key := [2]string{s.kind, name}
+		gr, ok := groups[key]
+		if !ok {
+			gr = &group{leak: Leak{Kind: s.kind, ClassName: name}}
+			groups[key] = gr
+		}
+
+		gr.leak.Instances++
+		gr.leak.Elements = max(gr.leak.Elements, s.elements)
+		if !g.dominatedBy(s.node, isSuspect) {
+			gr.leak.RetainedSize += s.retained
+		}
+		if gr.leak.Instances == 1 || s.retained > gr.topSize {
+			gr.top = s.node
+			gr.topSize = s.retained
+		}
+	}
+
+	for _, gr := range groups {
+		gr.leak.Path = g.path(gr.top)
+		leaks = append(leaks, gr.leak)
+	}
+
+	slices.SortFunc(leaks, func(a, b Leak) int {
+		if n := cmp.Compare(b.RetainedSize, a.RetainedSize); n != 0 {
+			return n
+		}
+		if n := cmp.Compare(a.Kind, b.Kind); n != 0 {
+			return n
+		}
+		return cmp.Compare(a.ClassName, b.ClassName)
+	})
+
+	if len(leaks) > maxLeaks {
+		leaks = leaks[:maxLeaks]
+	}
+
+	return
+}
+
+// dominatedBy reports whether any of the
+// node's dominators is in nodes.
+func (g *graph) dominatedBy(node int32, nodes map[int32]bool) bool {
+	for d := g.idom[node]; d > 0; d = g.idom[d] {
+		if nodes[d] {
+			return true
+		}
+	}
+	return false
+}
+
+// ClassNames gets the distinct names of the
+// classes the report refers to, array classes
+// by their element class.
+func (r Report) ClassNames() (names []string) {
+	seen := map[string]bool{}
+	add := func(name string) {
+		name = strings.TrimRight(name, "[]")
+		if name == "" || seen[name] || primitiveClass(name) {
+			return
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	for _, c := range r.Classes {
+		add(c.ClassName)
+	}
+	for _, l := range r.Leaks {
+		add(l.ClassName)
+		for _, ref := range l.Path {
+			add(ref.ClassName)
+		}
+	}
+
+	return
+}
+
+// Rewrite renames the classes the report refers to,
+// like when de-obfuscating. Array classes are renamed
+// by their element class.
+func (r *Report) Rewrite(classes map[string]string) {
+	if len(classes) == 0 {
+		return
+	}
+
+	rename := func(name string) string {
+		elem := strings.TrimRight(name, "[]")
+		if renamed, ok := classes[elem]; ok {
+			return renamed + name[len(elem):]
+		}
+		return name
+	}
+
+	for i := range r.Classes {
+		r.Classes[i].ClassName = rename(r.Classes[i].ClassName)
+	}
+	for i := range r.Leaks {
+		r.Leaks[i].ClassName = rename(r.Leaks[i].ClassName)
+		for j := range r.Leaks[i].Path {
+			r.Leaks[i].Path[j].ClassName = rename(r.Leaks[i].Path[j].ClassName)
+		}
+	}
+}
+
+// primitiveClass reports whether the
+// name is of a primitive type.
+func primitiveClass(name string) bool {
+	for _, p := range primitiveNames {
+		if name == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package heapdump
+
+import (
+	"slices"
+	"testing"
+)
+
+// leakyDump builds a dump of a destroyed activity
+// held by a static field, a weakly referenced
+// activity & a large list.
+func leakyDump() []byte {
+	b := newHprofBuilder()
+	b.class(0x10, 0, "java.lang.Object", nil)
+	b.class(0x11, 0x10, "android.app.Activity", nil, field{"mDestroyed", typeBoolean})
+	b.class(0x12, 0x11, "com.example.MainActivity", nil, field{"mBuffer", typeObject})
+	b.class(0x13, 0x10, "java.lang.ref.Reference", nil, field{"referent", typeObject})
+	b.class(0x14, 0x13, "java.lang.ref.WeakReference", nil)
+	b.class(0x15, 0x10, "java.util.ArrayList", nil, field{"elementData", typeObject}, field{"size", typeInt})
+	b.class(0x16, 0x10, "java.lang.Object[]", nil)
+	b.class(0x20, 0x10, "com.example.Registry", map[string]uint32{
+		"sActivity": 0x100,
+		"sWeak":     0x300,
+		"sItems":    0x400,
+	})
+
+	// leaking activity retains its buffer
+	b.instance(0x100, 0x12, values(uint32(0x101), true))
+	b.byteArray(0x101, 1000)
+
+	// weakly referenced destroyed activity
+	// isn't reachable
+	b.instance(0x300, 0x14, values(uint32(0x301)))
+	b.instance(0x301, 0x12, values(uint32(0), true))
+
+	// large list
+	b.instance(0x400, 0x15, values(uint32(0x401), int32(20_000)))
+	b.objectArray(0x401, 0x16, 0x100)
+
+	b.root(0x10)
+	return b.bytes()
+}
+
+func TestAnalyze(t *testing.T) {
+	r, err := Analyze(leakyDump())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.Leaks) != 2 {
+		t.Fatalf("leaks = %+v, want 2", r.Leaks)
+	}
+
+	activity := r.Leaks[0]
+	if activity.Kind != LeakDestroyedActivity || activity.ClassName != "com.example.MainActivity" || activity.Instances != 1 {
+		t.Errorf("leak = %+v, want 1 destroyed com.example.MainActivity", activity)
+	}
+
+	// the activity's fields & its buffer
+	if activity.RetainedSize != 5+1000 {
+		t.Errorf("retained = %d, want %d", activity.RetainedSize, 5+1000)
+	}
+
+	var path []string
+	for _, ref := range activity.Path {
+		path = append(path, ref.String())
+	}
+	if want := []string{"com.example.Registry.sActivity", "com.example.MainActivity"}; !slices.Equal(path, want) {
+		t.Errorf("path = %v, want %v", path, want)
+	}
+
+	list := r.Leaks[1]
+	if list.Kind != LeakLargeCollection || list.ClassName != "java.util.ArrayList" || list.Elements != 20_000 {
+		t.Errorf("leak = %+v, want a java.util.ArrayList of 20000 elements", list)
+	}
+
+	// the list references the activity, but the
+	// activity is also held by the registry
+	if list.RetainedSize != 8+4 {
+		t.Errorf("retained = %d, want %d", list.RetainedSize, 8+4)
+	}
+
+	// weakly referenced activity
+	// is unreachable
+	for _, c := range r.Classes {
+		if c.ClassName == "com.example.MainActivity" && c.Instances != 1 {
+			t.Errorf("activities = %d, want 1", c.Instances)
+		}
+	}
+
+	if r.Classes[0].ClassName != "com.example.MainActivity" {
+		t.Errorf("top class = %q, want com.example.MainActivity", r.Classes[0].ClassName)
+	}
+}
+
+func TestDominate(t *testing.T) {
+	// root -> a -> b -> d
+	//           \> c /
+	// a dominates d as it's reached
+	// through b or c
+	b := newHprofBuilder()
+	b.class(0x10, 0, "java.lang.Object", nil)
+	b.class(0x11, 0x10, "com.example.Node", nil, field{"left", typeObject}, field{"right", typeObject})
+	b.instance(0xa, 0x11, values(uint32(0xb), uint32(0xc)))
+	b.instance(0xb, 0x11, values(uint32(0xd), uint32(0)))
+	b.instance(0xc, 0x11, values(uint32(0xd), uint32(0)))
+	b.instance(0xd, 0x11, values(uint32(0), uint32(0)))
+	b.root(0xa)
+
+	h, err := parseHprof(b.bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := buildGraph(h)
+	g.dominate()
+
+	node := func(id uint64) int32 { return h.index[id] + 1 }
+	idoms := map[uint64]int32{0xb: node(0xa), 0xc: node(0xa), 0xd: node(0xa)}
+	for id, want := range idoms {
+		if got := g.idom[node(id)]; got != want {
+			t.Errorf("idom(0x%x) = %d, want %d", id, got, want)
+		}
+	}
+
+	if got := g.retained[node(0xa)]; got != 4*8 {
+		t.Errorf("retained(a) = %d, want %d", got, 4*8)
+	}
+	if got := g.retained[node(0xb)]; got != 8 {
+		t.Errorf("retained(b) = %d, want %d", got, 8)
+	}
+}
+
+func TestReportRewrite(t *testing.T) {
+	r := Report{
+		Classes: []ClassSize{{ClassName: "a.b"}, {ClassName: "a.b[]"}, {ClassName: "byte[]"}},
+		Leaks: []Leak{{
+			ClassName: "a.c",
+			Path:      []Reference{{ClassName: "a.b", Name: "x"}, {ClassName: "a.c"}},
+		}},
+	}
+
+	if got, want := r.ClassNames(), []string{"a.b", "a.c"}; !slices.Equal(got, want) {
+		t.Errorf("class names = %v, want %v", got, want)
+	}
+
+	r.Rewrite(map[string]string{"a.b": "com.example.Cache", "a.c": "com.example.MainActivity"})
+
+	var got []string
+	for _, c := range r.Classes {
+		got = append(got, c.ClassName)
+	}
+	if want := []string{"com.example.Cache", "com.example.Cache[]", "byte[]"}; !slices.Equal(got, want) {
+		t.Errorf("classes = %v, want %v", got, want)
+	}
+
+	if r.Leaks[0].ClassName != "com.example.MainActivity" || r.Leaks[0].Path[0].String() != "com.example.Cache.x" {
+		t.Errorf("leak = %+v, want rewritten class names", r.Leaks[0])
+	}
+}
@@ -2,3 +2,23 @@ package ingest
 
 // IngestBatchTopic is the name of the ingestion topic.
 const IngestBatchTopic = "ingest-batch"
+
+// IngestBatchDeadLetterTopic is the name of the topic ingest
+// batches the worker gave up on are moved to.
+const IngestBatchDeadLetterTopic = "ingest-batch-dead-letter"
+
+// ProfileTopic is the name of the topic profiles
+// awaiting aggregation are queued on.
+const ProfileTopic = "profile"
+
+// HeapDumpTopic is the name of the topic heap dumps
+// awaiting analysis are queued on.
+const HeapDumpTopic = "heap_dump"
+
+// PerfettoTraceTopic is the name of the topic perfetto
+// traces awaiting summarization are queued on.
+const PerfettoTraceTopic = "perfetto_trace"
+
+// ResymbolicateTopic is the name of the topic re-symbolication
+// jobs for mapping files that landed late are queued on.
+const ResymbolicateTopic = "resymbolicate"
@@ -0,0 +1,45 @@
+package ingest
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResymbolicateJob asks the ingest worker to re-symbolicate
+// the errors of an app version ingested before the version's
+// mapping file was uploaded.
+type ResymbolicateJob struct {
+	// AppID is the id of the app.
+	AppID uuid.UUID `json:"app_id"`
+	// VersionName is the app version
+	// the mapping file belongs to.
+	VersionName string `json:"version_name"`
+	// VersionCode is the app build
+	// the mapping file belongs to.
+	VersionCode string `json:"version_code"`
+	// PatchID is the Over-The-Air patch the
+	// mapping file belongs to, nil when the
+	// mapping file isn't scoped to a patch.
+	PatchID uuid.UUID `json:"patch_id"`
+	// MappingType is the type of the
+	// uploaded mapping file.
+	MappingType string `json:"mapping_type"`
+	// UploadedAt is when the mapping file landed.
+	// Errors ingested after it were symbolicated
+	// at ingestion & are left alone.
+	UploadedAt time.Time `json:"uploaded_at"`
+	// After is the last error an earlier run of
+	// the job looked at, nil for a new job. The
+	// job continues with the errors after it.
+	After *ResymbolicateCursor `json:"after,omitempty"`
+}
+
+// ResymbolicateCursor is the position of an error
+// in the order a re-symbolication job reads them.
+type ResymbolicateCursor struct {
+	// Timestamp is the error's timestamp.
+	Timestamp time.Time `json:"timestamp"`
+	// ID is the error's event id.
+	ID uuid.UUID `json:"id"`
+}
@@ -0,0 +1,185 @@
+// Package insight plots custom events over time, as counts,
+// unique sessions or users, or aggregations of a numeric user
+// defined attribute, optionally broken down by a standard or
+// user defined attribute.
+package insight
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Measures of custom events.
+const (
+	// MeasureCount counts events.
+	MeasureCount = "count"
+	// MeasureSessions counts unique
+	// sessions of events.
+	MeasureSessions = "sessions"
+	// MeasureUsers counts unique
+	// users of events.
+	MeasureUsers = "users"
+	// MeasureSum sums a numeric user
+	// defined attribute of events.
+	MeasureSum = "sum"
+	// MeasureAvg averages a numeric user
+	// defined attribute of events.
+	MeasureAvg = "avg"
+	// MeasureP95 computes the p95 of a numeric
+	// user defined attribute of events.
+	MeasureP95 = "p95"
+)
+
+// measures are the valid measures,
+// & numericMeasures those aggregating
+// a numeric attribute.
+var (
+	measures        = []string{MeasureCount, MeasureSessions, MeasureUsers, MeasureSum, MeasureAvg, MeasureP95}
+	numericMeasures = []string{MeasureSum, MeasureAvg, MeasureP95}
+)
+
+// Breakdowns of custom events.
+const (
+	// BreakdownAppVersion breaks down
+	// by app version.
+	BreakdownAppVersion = "app_version"
+	// BreakdownOSVersion breaks down
+	// by os version.
+	BreakdownOSVersion = "os_version"
+	// BreakdownCountry breaks down
+	// by country code.
+	BreakdownCountry = "country"
+	// BreakdownAttribute breaks down by
+	// a user defined attribute.
+	BreakdownAttribute = "attribute"
+)
+
+// breakdowns are the valid breakdowns.
+var breakdowns = []string{BreakdownAppVersion, BreakdownOSVersion, BreakdownCountry, BreakdownAttribute}
+
+// MaxSeries is the most series a breakdown
+// plots, those of the most events.
+const MaxSeries = 10
+
+// maxEventNameChars is the maximum
+// length of a custom event's name.
+const maxEventNameChars = 64
+
+// attrKeyPattern matches valid user
+// defined attribute keys.
+var attrKeyPattern = regexp.MustCompile("^[a-zA-Z0-9_-]{1,256}$")
+
+// Query selects the custom events
+// plotted & how.
+type Query struct {
+	// Event is the custom event's name.
+	Event string
+	// Measure is what's
+	// plotted of events.
+	Measure string
+	// Attribute is the numeric user defined
+	// attribute key numeric measures
+	// aggregate.
+	Attribute string
+	// Breakdown is what events are broken
+	// down by, none when empty.
+	Breakdown string
+	// BreakdownKey is the user defined attribute
+	// key attribute breakdowns break down by.
+	BreakdownKey string
+}
+
+// Validate validates the query.
+func (q Query) Validate() error {
+	if q.Event == "" {
+		return errors.New("event name cannot be empty")
+	}
+	if len(q.Event) > maxEventNameChars {
+		return fmt.Errorf("event name exceeds maximum allowed characters of (%d)", maxEventNameChars)
+	}
+	if !slices.Contains(measures, q.Measure) {
+		return fmt.Errorf("measure %q is not one of %v", q.Measure, measures)
+	}
+	if q.Numeric() && !attrKeyPattern.MatchString(q.Attribute) {
+		return fmt.Errorf("measure %q needs a valid numeric attribute key", q.Measure)
+	}
+	if !q.Numeric() && q.Attribute != "" {
+		return fmt.Errorf("measure %q does not aggregate an attribute", q.Measure)
+	}
+	if q.Breakdown != "" && !slices.Contains(breakdowns, q.Breakdown) {
+		return fmt.Errorf("breakdown %q is not one of %v", q.Breakdown, breakdowns)
+	}
+	if q.Breakdown == BreakdownAttribute && !attrKeyPattern.MatchString(q.BreakdownKey) {
+		return fmt.Errorf("breakdown %q needs a valid attribute key", q.Breakdown)
+	}
+	if q.Breakdown != BreakdownAttribute && q.BreakdownKey != "" {
+		return fmt.Errorf("breakdown %q does not take an attribute key", q.Breakdown)
+	}
+	return nil
+}
+
+// Numeric is true if the query's measure
+// aggregates a numeric attribute.
+func (q Query) Numeric() bool {
+	return slices.Contains(numericMeasures, q.Measure)
+}
+
+// Series is a plotted series
+// of custom events.
+type Series struct {
+	// ID is the breakdown value of the series,
+	// or the event's name without a breakdown.
+	ID   string  `json:"id"`
+	Data []Point `json:"data"`
+}
+
+// Point is a plotted point
+// of a series.
+type Point struct {
+	Datetime string  `json:"datetime"`
+	Value    float64 `json:"value"`
+}
+
+// bucket is a time bucket of a breakdown
+// value, as queried.
+type bucket struct {
+	datetime  string
+	breakdown string
+	events    uint64
+	value     float64
+}
+
+// plot groups the buckets, ordered by time, into
+// series, keeping the MaxSeries series of the
+// most events, most first.
+func plot(buckets []bucket) (series []Series) {
+	events := map[string]uint64{}
+	lut := map[string]int{}
+	for _, b := range buckets {
+		ndx, ok := lut[b.breakdown]
+		if !ok {
+			ndx = len(series)
+			lut[b.breakdown] = ndx
+			series = append(series, Series{ID: b.breakdown})
+		}
+
+		series[ndx].Data = append(series[ndx].Data, Point{
+			Datetime: b.datetime,
+			Value:    b.value,
+		})
+		events[b.breakdown] += b.events
+	}
+
+	slices.SortStableFunc(series, func(a, b Series) int {
+		return cmp.Compare(events[b.ID], events[a.ID])
+	})
+
+	if len(series) > MaxSeries {
+		series = series[:MaxSeries]
+	}
+
+	return
+}
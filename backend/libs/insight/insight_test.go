@@ -0,0 +1,104 @@
+package insight
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	valid := map[string]Query{
+		"count":                 {Event: "OrderPlaced", Measure: MeasureCount},
+		"users by version":      {Event: "OrderPlaced", Measure: MeasureUsers, Breakdown: BreakdownAppVersion},
+		"p95 by attribute":      {Event: "OrderPlaced", Measure: MeasureP95, Attribute: "total", Breakdown: BreakdownAttribute, BreakdownKey: "currency"},
+		"sum without breakdown": {Event: "OrderPlaced", Measure: MeasureSum, Attribute: "total"},
+	}
+
+	for name, q := range valid {
+		if err := q.Validate(); err != nil {
+			t.Errorf("%s: expected valid query, got %v", name, err)
+		}
+	}
+
+	invalid := map[string]Query{
+		"empty event":             {Measure: MeasureCount},
+		"unknown measure":         {Event: "OrderPlaced", Measure: "max"},
+		"sum without attribute":   {Event: "OrderPlaced", Measure: MeasureSum},
+		"count with attribute":    {Event: "OrderPlaced", Measure: MeasureCount, Attribute: "total"},
+		"invalid attribute":       {Event: "OrderPlaced", Measure: MeasureAvg, Attribute: "order total"},
+		"unknown breakdown":       {Event: "OrderPlaced", Measure: MeasureCount, Breakdown: "city"},
+		"attribute breakdown key": {Event: "OrderPlaced", Measure: MeasureCount, Breakdown: BreakdownAttribute},
+		"country breakdown key":   {Event: "OrderPlaced", Measure: MeasureCount, Breakdown: BreakdownCountry, BreakdownKey: "currency"},
+	}
+
+	for name, q := range invalid {
+		if err := q.Validate(); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestMeasureExpr(t *testing.T) {
+	expr, args := Query{Measure: MeasureAvg, Attribute: "total"}.measureExpr()
+	if expr != "avg(toFloat64OrNull(user_defined_attribute[?].2))" {
+		t.Errorf("Expected avg expression, got %q", expr)
+	}
+	if !reflect.DeepEqual([]any{"total"}, args) {
+		t.Errorf("Expected [total], got %v", args)
+	}
+
+	expr, args = Query{Measure: MeasureSessions}.measureExpr()
+	if expr != "uniq(session_id)" || args != nil {
+		t.Errorf("Expected unique sessions expression, got %q %v", expr, args)
+	}
+}
+
+func TestPlot(t *testing.T) {
+	buckets := []bucket{
+		{datetime: "2026-10-01", breakdown: "1.0 (1)", events: 2, value: 2},
+		{datetime: "2026-10-01", breakdown: "1.1 (2)", events: 5, value: 5},
+		{datetime: "2026-10-02", breakdown: "1.0 (1)", events: 1, value: 1},
+		{datetime: "2026-10-02", breakdown: "1.1 (2)", events: 4, value: 4},
+	}
+
+	expected := []Series{
+		{
+			ID: "1.1 (2)",
+			Data: []Point{
+				{Datetime: "2026-10-01", Value: 5},
+				{Datetime: "2026-10-02", Value: 4},
+			},
+		},
+		{
+			ID: "1.0 (1)",
+			Data: []Point{
+				{Datetime: "2026-10-01", Value: 2},
+				{Datetime: "2026-10-02", Value: 1},
+			},
+		},
+	}
+
+	if series := plot(buckets); !reflect.DeepEqual(expected, series) {
+		t.Errorf("Expected %+v, got %+v", expected, series)
+	}
+}
+
+func TestPlotMaxSeries(t *testing.T) {
+	var buckets []bucket
+	for i := range MaxSeries + 5 {
+		buckets = append(buckets, bucket{
+			datetime:  "2026-10-01",
+			breakdown: string(rune('a' + i)),
+			events:    uint64(i + 1),
+		})
+	}
+
+	series := plot(buckets)
+	if len(series) != MaxSeries {
+		t.Fatalf("Expected %d series, got %d", MaxSeries, len(series))
+	}
+
+	// most events first
+	if series[0].ID != string(rune('a'+MaxSeries+4)) {
+		t.Errorf("Expected series of most events first, got %q", series[0].ID)
+	}
+}
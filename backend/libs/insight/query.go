@@ -0,0 +1,166 @@
+package insight
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/event"
+	"backend/libs/filter"
+	"backend/libs/measure"
+	"backend/libs/udattr"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// GetPlot plots the custom events the query & app filter
+// select, bucketed by the app filter's plot time group.
+func GetPlot(ctx context.Context, rch driver.Conn, teamID uuid.UUID, af *filter.AppFilter, q Query) (series []Series, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+	if !af.HasTimezone() {
+		return nil, errors.New("timezone is required")
+	}
+
+	if !af.HasPlotTimeGroup() {
+		af.SetDefaultPlotTimeGroup()
+	}
+
+	groupExpr, err := measure.GetPlotTimeGroupExpr("timestamp", af.PlotTimeGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdown, breakdownArgs := q.breakdownExpr()
+	value, valueArgs := q.measureExpr()
+
+	stmt := sqlf.From("events").
+		Select(groupExpr.BucketExpr+" as datetime_bucket", af.Timezone).
+		Select("formatDateTime(datetime_bucket, ?) as datetime", groupExpr.DatetimeFormat).
+		Select(breakdown+" as breakdown", breakdownArgs...).
+		Select("count()").
+		Select("round(ifNull(toFloat64("+value+"), 0), 2)", valueArgs...).
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", af.AppID).
+		Where("type = ?", event.TypeCustom).
+		Where("`custom.name` = ?", q.Event).
+		Where("timestamp >= ? and timestamp <= ?", af.From, af.To).
+		GroupBy("datetime_bucket, datetime, breakdown").
+		OrderBy("datetime_bucket")
+
+	defer stmt.Close()
+
+	if q.Numeric() {
+		stmt.Where("mapContains(user_defined_attribute, ?)", q.Attribute)
+		stmt.Where("user_defined_attribute[?].1 in (?, ?)", q.Attribute, udattr.AttrInt64.String(), udattr.AttrFloat64.String())
+	}
+	if q.Breakdown == BreakdownAttribute {
+		stmt.Where("mapContains(user_defined_attribute, ?)", q.BreakdownKey)
+	}
+
+	if af.HasVersions() {
+		stmt.Where("attribute.app_version in ?", af.Versions)
+		stmt.Where("attribute.app_build in ?", af.VersionCodes)
+	}
+	if af.HasOSVersions() {
+		stmt.Where("attribute.os_name in ?", af.OsNames)
+		stmt.Where("attribute.os_version in ?", af.OsVersions)
+	}
+	if af.HasCountries() {
+		stmt.Where("inet.country_code in ?", af.Countries)
+	}
+	if af.HasDeviceNames() {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+	if af.HasDeviceManufacturers() {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+	if af.HasDeviceLocales() {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+	if af.HasNetworkTypes() {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+	if af.HasNetworkProviders() {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+	if af.HasNetworkGenerations() {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if af.HasUDExpression() && !af.UDExpression.Empty() {
+		subQuery := sqlf.
+			From("user_def_attrs").
+			Select("distinct event_id").
+			Where("team_id = toUUID(?)", teamID).
+			Where("app_id = toUUID(?)", af.AppID).
+			Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+
+		af.UDExpression.Augment(subQuery)
+		stmt.SubQuery("id in (", ")", subQuery)
+	}
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var buckets []bucket
+	for rows.Next() {
+		var b bucket
+		var datetimeBucket time.Time
+		if err = rows.Scan(&datetimeBucket, &b.datetime, &b.breakdown, &b.events, &b.value); err != nil {
+			return
+		}
+
+		if q.Breakdown == "" {
+			b.breakdown = q.Event
+		}
+		buckets = append(buckets, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	series = plot(buckets)
+	return
+}
+
+// measureExpr builds the SQL expression
+// of the query's measure.
+func (q Query) measureExpr() (expr string, args []any) {
+	attr := "toFloat64OrNull(user_defined_attribute[?].2)"
+	switch q.Measure {
+	case MeasureSessions:
+		return "uniq(session_id)", nil
+	case MeasureUsers:
+		return "uniqIf(attribute.user_id, attribute.user_id != '')", nil
+	case MeasureSum:
+		return "sum(" + attr + ")", []any{q.Attribute}
+	case MeasureAvg:
+		return "avg(" + attr + ")", []any{q.Attribute}
+	case MeasureP95:
+		return "quantile(0.95)(" + attr + ")", []any{q.Attribute}
+	}
+	return "count()", nil
+}
+
+// breakdownExpr builds the SQL expression
+// of the query's breakdown.
+func (q Query) breakdownExpr() (expr string, args []any) {
+	switch q.Breakdown {
+	case BreakdownAppVersion:
+		return "concat(attribute.app_version, ' (', attribute.app_build, ')')", nil
+	case BreakdownOSVersion:
+		return "concat(attribute.os_name, ' ', attribute.os_version)", nil
+	case BreakdownCountry:
+		return "inet.country_code", nil
+	case BreakdownAttribute:
+		return "user_defined_attribute[?].2", []any{q.BreakdownKey}
+	}
+	return "''", nil
+}
@@ -51,3 +51,43 @@ const BugReports = "bug_reports"
 // Network is the root key for the `network`
 // logcomment.
 const Network = "network"
+
+// Releases is the root key for the `releases`
+// logcomment.
+const Releases = "releases"
+
+// EndUsers is the root key for the `end_users`
+// logcomment.
+const EndUsers = "end_users"
+
+// MetricExport is the root key for the `metric_export`
+// logcomment.
+const MetricExport = "metric_export"
+
+// Profiles is the root key for the `profiles`
+// logcomment.
+const Profiles = "profiles"
+
+// Symbols is the root key for the `symbols`
+// logcomment.
+const Symbols = "symbols"
+
+// HeapDumps is the root key for the `heap_dumps`
+// logcomment.
+const HeapDumps = "heap_dumps"
+
+// Traces is the root key for the `traces`
+// logcomment.
+const Traces = "traces"
+
+// Funnels is the root key for the `funnels`
+// logcomment.
+const Funnels = "funnels"
+
+// Retention is the root key for the `retention`
+// logcomment.
+const Retention = "retention"
+
+// CustomEvents is the root key for the `custom_events`
+// logcomment.
+const CustomEvents = "custom_events"
@@ -38,6 +38,15 @@ func GetAlertsWithFilter(ctx context.Context, pg *pgxpool.Pool, af *filter.AppFi
 		Where("created_at >= ?", af.From).
 		Where("created_at <= ?", af.To)
 
+	if af.HasExprFilter() {
+		predicate, errFilter := af.ExprFilter.Predicate(nil)
+		if errFilter != nil {
+			return nil, false, false, errFilter
+		}
+		defer predicate.Close()
+		stmt.Where(predicate.String(), predicate.Args()...)
+	}
+
 	if af.Limit > 0 {
 		stmt.Limit(uint64(af.Limit) + 1)
 	}
@@ -295,6 +295,48 @@ func applyExceptionSeverityFilter(s *sqlf.Stmt, severities []event.Severity) {
 	}
 }
 
+// errorGroupFingerprints provides the fingerprint of the
+// error group & those of the groups merged into it.
+func errorGroupFingerprints(fingerprint string, af *filter.AppFilter) []string {
+	fingerprints := []string{fingerprint}
+	for i, targetID := range af.MergedErrorGroupTargets {
+		if targetID == fingerprint {
+			fingerprints = append(fingerprints, af.MergedErrorGroupIDs[i])
+		}
+	}
+	return fingerprints
+}
+
+// errorGroupIDExpr builds the expression of the error
+// group id of an event's fingerprint column, resolving
+// merged groups to their target groups.
+func errorGroupIDExpr(column string, af *filter.AppFilter) (string, []any) {
+	if !af.HasErrorGroupMerges() {
+		return column, nil
+	}
+	return "transform(" + column + ", ?, ?, " + column + ")", []any{af.MergedErrorGroupIDs, af.MergedErrorGroupTargets}
+}
+
+// applyErrorGroupStatusFilter keeps the error groups of the
+// filter's statuses, matching idExpr against the group ids
+// resolved from the statuses.
+func applyErrorGroupStatusFilter(s *sqlf.Stmt, af *filter.AppFilter, idExpr string, idArgs ...any) {
+	if !af.HasErrorGroupStatuses() {
+		return
+	}
+
+	switch {
+	case af.ExcludeErrorGroupIDs && len(af.ErrorGroupIDs) > 0:
+		s.Where(idExpr+" not in ?", append(idArgs, af.ErrorGroupIDs)...)
+	case !af.ExcludeErrorGroupIDs && len(af.ErrorGroupIDs) > 0:
+		s.Where(idExpr+" in ?", append(idArgs, af.ErrorGroupIDs)...)
+	case !af.ExcludeErrorGroupIDs:
+		// no error group has the
+		// requested statuses
+		s.Where("1 = 0")
+	}
+}
+
 // unionStmts combines one or more sqlf statements with UNION ALL.
 // Returns the single statement unchanged when len == 1.
 func unionStmts(stmts []*sqlf.Stmt) *sqlf.Stmt {
@@ -329,6 +371,19 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 
 	queryANR = anrAllowed(af, queryANR)
 
+	// the expression filter reads error events, so it
+	// applies to the counts & a group with no matching
+	// events drops out
+	var predicate *sqlf.Stmt
+	if af.HasExprFilter() {
+		af.ScopeExprFilter(a.TeamId)
+		predicate, err = af.ExprFilter.Predicate(nil)
+		if err != nil {
+			return
+		}
+		defer predicate.Close()
+	}
+
 	applyGroupFilters := func(s *sqlf.Stmt) error {
 		s.Where("team_id = toUUID(?)", a.TeamId).
 			Where("app_id = toUUID(?)", a.ID).
@@ -343,6 +398,8 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 			s.Where("app_version.2 in ?", af.VersionCodes)
 		}
 
+		applyErrorGroupStatusFilter(s, af, "id")
+
 		if af.HasOSVersions() {
 			osVersions, errOS := af.OSVersionPairs()
 			if errOS != nil {
@@ -382,9 +439,22 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 		return nil
 	}
 
+	// merged groups are folded into their target
+	// groups by rewriting their ids before grouping
+	groupsFrom := func(table string) *sqlf.Stmt {
+		if !af.HasErrorGroupMerges() {
+			return sqlf.From(table)
+		}
+		return sqlf.From("(select * replace (transform(id, ?, ?, id) as id) from "+table+")", af.MergedErrorGroupIDs, af.MergedErrorGroupTargets)
+	}
+
+	countsID := func(column string) (string, []any) {
+		expr, args := errorGroupIDExpr(column, af)
+		return expr + " as id", args
+	}
+
 	newGroupsBranch := func(table, sourceType, severityClass, severityExpr, isCustomExpr string) (*sqlf.Stmt, error) {
-		s := sqlf.
-			From(table).
+		s := groupsFrom(table).
 			Select("team_id").
 			Select("app_id").
 			Select("id").
@@ -438,11 +508,12 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 	var countsBranches []*sqlf.Stmt
 
 	if queryANR {
+		idExpr, idArgs := countsID("anr.fingerprint")
 		s := sqlf.
 			From("events").
 			Select("team_id").
 			Select("app_id").
-			Select("anr.fingerprint as id").
+			Select(idExpr, idArgs...).
 			Select("count() as event_count").
 			Select("'anr' as source_type").
 			Select("'fatal' as severity_class").
@@ -461,6 +532,10 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 			s.Where("attribute.app_build in ?", af.VersionCodes)
 		}
 
+		if predicate != nil {
+			s.Where(predicate.String(), predicate.Args()...)
+		}
+
 		countsBranches = append(countsBranches, s)
 	}
 
@@ -471,11 +546,12 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 	// Each branch counts only the events matching its bucket's severities; the
 	// groups-driven LEFT JOIN drops counts whose group is absent for that class.
 	newExceptionCountsBranch := func(severityClass string, severities []event.Severity) *sqlf.Stmt {
+		idExpr, idArgs := countsID("exception.fingerprint")
 		s := sqlf.
 			From("events").
 			Select("team_id").
 			Select("app_id").
-			Select("exception.fingerprint as id").
+			Select(idExpr, idArgs...).
 			Select("count() as event_count").
 			Select("'exception' as source_type").
 			Select("'"+severityClass+"' as severity_class").
@@ -500,6 +576,10 @@ func (a App) GetErrorGroupsWithFilter(ctx context.Context, rch driver.Conn, af *
 			s.Where("attribute.app_build in ?", af.VersionCodes)
 		}
 
+		if predicate != nil {
+			s.Where(predicate.String(), predicate.Args()...)
+		}
+
 		return s
 	}
 
@@ -676,6 +756,8 @@ func (a App) GetErrorPlotInstances(ctx context.Context, rch driver.Conn, af *fil
 
 	if queryANR {
 		s := newBranch().Where("type = ?", event.TypeANR)
+		idExpr, idArgs := errorGroupIDExpr("`anr.fingerprint`", af)
+		applyErrorGroupStatusFilter(s, af, idExpr, idArgs...)
 		applyCommonFilters(s)
 		branches = append(branches, s)
 	}
@@ -686,6 +768,8 @@ func (a App) GetErrorPlotInstances(ctx context.Context, rch driver.Conn, af *fil
 		if af.CustomError {
 			s.Where("`exception.is_custom` = true")
 		}
+		idExpr, idArgs := errorGroupIDExpr("`exception.fingerprint`", af)
+		applyErrorGroupStatusFilter(s, af, idExpr, idArgs...)
 		applyCommonFilters(s)
 		branches = append(branches, s)
 	}
@@ -731,9 +815,11 @@ func (a App) GetErrorPlotInstances(ctx context.Context, rch driver.Conn, af *fil
 // GetErrorGroupPlotInstances computes plot instances for a single
 // error group fingerprint, unioning across ANR, fatal exception and
 // nonfatal exception sources selected by the filter's severity flags.
-// When no severity flag is set, all sources are included.
+// When no severity flag is set, all sources are included. Groups
+// merged into the group are plotted as part of it.
 func (a App) GetErrorGroupPlotInstances(ctx context.Context, rch driver.Conn, fingerprint string, af *filter.AppFilter) (instances []event.IssueInstance, err error) {
 	ctx = chquery.WithTeamScope(ctx, a.TeamId)
+	fingerprints := errorGroupFingerprints(fingerprint, af)
 	if af.Timezone == "" {
 		return nil, errors.New("missing timezone filter")
 	}
@@ -801,7 +887,7 @@ func (a App) GetErrorGroupPlotInstances(ctx context.Context, rch driver.Conn, fi
 	if queryANR {
 		s := newBranch().
 			Where("type = ?", event.TypeANR).
-			Where("anr.fingerprint = ?", fingerprint)
+			Where("anr.fingerprint in ?", fingerprints)
 		applyCommonFilters(s)
 		branches = append(branches, s)
 	}
@@ -809,7 +895,7 @@ func (a App) GetErrorGroupPlotInstances(ctx context.Context, rch driver.Conn, fi
 	if wantHandledTrue || wantHandledFalse {
 		s := newBranch().
 			Where("type = ?", event.TypeException).
-			Where("`exception.fingerprint` = ?", fingerprint)
+			Where("`exception.fingerprint` in ?", fingerprints)
 		applyExceptionSeverityFilter(s, af.Severities)
 		if af.CustomError {
 			s.Where("`exception.is_custom` = true")
@@ -857,9 +943,10 @@ func (a App) GetErrorGroupPlotInstances(ctx context.Context, rch driver.Conn, fi
 // for a single error group fingerprint, unioning across ANR, fatal
 // exception and nonfatal exception sources selected by the filter's
 // severity flags. When no severity flag is set, all sources are
-// included.
+// included. Groups merged into the group are counted as part of it.
 func (a App) GetErrorGroupAttributesDistribution(ctx context.Context, rch driver.Conn, fingerprint string, af *filter.AppFilter) (distribution event.IssueDistribution, err error) {
 	ctx = chquery.WithTeamScope(ctx, a.TeamId)
+	fingerprints := errorGroupFingerprints(fingerprint, af)
 	queryANR, wantHandledTrue, wantHandledFalse := resolveErrorSources(af)
 
 	applyCommonFilters := func(s *sqlf.Stmt) {
@@ -921,7 +1008,7 @@ func (a App) GetErrorGroupAttributesDistribution(ctx context.Context, rch driver
 	if queryANR {
 		s := newBranch().
 			Where("type = ?", event.TypeANR).
-			Where("anr.fingerprint = ?", fingerprint)
+			Where("anr.fingerprint in ?", fingerprints)
 		applyCommonFilters(s)
 		branches = append(branches, s)
 	}
@@ -929,7 +1016,7 @@ func (a App) GetErrorGroupAttributesDistribution(ctx context.Context, rch driver
 	if wantHandledTrue || wantHandledFalse {
 		s := newBranch().
 			Where("type = ?", event.TypeException).
-			Where("`exception.fingerprint` = ?", fingerprint)
+			Where("`exception.fingerprint` in ?", fingerprints)
 		applyExceptionSeverityFilter(s, af.Severities)
 		if af.CustomError {
 			s.Where("`exception.is_custom` = true")
@@ -986,9 +1073,11 @@ func (a App) GetErrorGroupAttributesDistribution(ctx context.Context, rch driver
 // GetErrorsWithFilter fetches raw error events (exceptions and/or ANRs)
 // belonging to a single fingerprint, across the sources selected by
 // the filter's severity flags. When no severity flag is set, all
-// sources are queried.
+// sources are queried. Errors of groups merged into the group are
+// included.
 func (a App) GetErrorsWithFilter(ctx context.Context, rch driver.Conn, fingerprint string, af *filter.AppFilter) (events []any, next, previous bool, err error) {
 	ctx = chquery.WithTeamScope(ctx, a.TeamId)
+	fingerprints := errorGroupFingerprints(fingerprint, af)
 	includeANR := (len(af.ErrorTypes) == 0 && len(af.Severities) == 0) || slices.Contains(af.ErrorTypes, event.ErrorTypeANR)
 	includeError := len(af.ErrorTypes) == 0 || slices.Contains(af.ErrorTypes, event.ErrorTypeError)
 
@@ -1002,6 +1091,16 @@ func (a App) GetErrorsWithFilter(ctx context.Context, rch driver.Conn, fingerpri
 
 	queryANR = anrAllowed(af, queryANR)
 
+	var predicate *sqlf.Stmt
+	if af.HasExprFilter() {
+		af.ScopeExprFilter(a.TeamId)
+		predicate, err = af.ExprFilter.Predicate(nil)
+		if err != nil {
+			return
+		}
+		defer predicate.Close()
+	}
+
 	applyCommonFilters := func(s *sqlf.Stmt) {
 		s.Where("team_id = toUUID(?)", a.TeamId)
 		s.Where("app_id = toUUID(?)", a.ID)
@@ -1037,6 +1136,9 @@ func (a App) GetErrorsWithFilter(ctx context.Context, rch driver.Conn, fingerpri
 		if af.HasNetworkGenerations() {
 			s.Where("attribute.network_generation in ?", af.NetworkGenerations)
 		}
+		if predicate != nil {
+			s.Where(predicate.String(), predicate.Args()...)
+		}
 
 		if af.Limit > 0 {
 			s.Limit(uint64(af.Limit) + 1)
@@ -1070,7 +1172,7 @@ func (a App) GetErrorsWithFilter(ctx context.Context, rch driver.Conn, fingerpri
 			Select("attachments").
 			Select("user_defined_attribute").
 			Where("type = ?", event.TypeException).
-			Where("exception.fingerprint = ?", fingerprint)
+			Where("exception.fingerprint in ?", fingerprints)
 
 		applyExceptionSeverityFilter(stmt, af.Severities)
 
@@ -1159,7 +1261,7 @@ func (a App) GetErrorsWithFilter(ctx context.Context, rch driver.Conn, fingerpri
 			Select("anr.threads as threads").
 			Select("attachments").
 			Where("type = ?", event.TypeANR).
-			Where("anr.fingerprint = ?", fingerprint)
+			Where("anr.fingerprint in ?", fingerprints)
 
 		applyCommonFilters(stmt)
 		defer stmt.Close()
@@ -1937,6 +2039,16 @@ func (a App) GetSessionsWithFilter(ctx context.Context, rch driver.Conn, af *fil
 		base.SubQuery("session_id in (", ")", subQuery)
 	}
 
+	if af.HasExprFilter() {
+		af.ScopeExprFilter(a.TeamId)
+		predicate, errFilter := af.ExprFilter.Predicate(nil)
+		if errFilter != nil {
+			return sessions, next, previous, errFilter
+		}
+		defer predicate.Close()
+		base.Where(predicate.String(), predicate.Args()...)
+	}
+
 	base.
 		GroupBy("session_id").
 		GroupBy("app_version").
@@ -2373,6 +2485,16 @@ func (a App) GetSpansForSpanNameWithFilter(ctx context.Context, rch driver.Conn,
 		stmt.SubQuery("span_id in (", ")", subQuery)
 	}
 
+	if af.HasExprFilter() {
+		af.ScopeExprFilter(a.TeamId)
+		predicate, errFilter := af.ExprFilter.Predicate(nil)
+		if errFilter != nil {
+			return rootSpans, next, previous, errFilter
+		}
+		defer predicate.Close()
+		stmt.Where(predicate.String(), predicate.Args()...)
+	}
+
 	stmt.OrderBy("start_time desc")
 
 	if af.Limit > 0 {
@@ -2804,6 +2926,16 @@ func (a App) GetBugReportsWithFilter(ctx context.Context, rch driver.Conn, af *f
 		stmt.SubQuery("event_id in (", ")", subQuery)
 	}
 
+	if af.HasExprFilter() {
+		af.ScopeExprFilter(a.TeamId)
+		predicate, errFilter := af.ExprFilter.Predicate(nil)
+		if errFilter != nil {
+			return bugReports, next, previous, errFilter
+		}
+		defer predicate.Close()
+		stmt.Where(predicate.String(), predicate.Args()...)
+	}
+
 	stmt.OrderBy("timestamp desc")
 
 	if af.HasFreeText() {
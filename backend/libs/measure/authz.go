@@ -102,6 +102,7 @@ var (
 	ScopeAppRead                   = newScope("app", "read")
 	ScopeBugReportAll              = newScope("bugReport", "*")
 	ScopeBugReportRead             = newScope("bugReport", "read")
+	ScopeErrorGroupAll             = newScope("errorGroup", "*")
 )
 
 type scope struct {
@@ -129,9 +130,9 @@ func (s scope) GetRolesSameOrLower(r Rank) []Rank {
 }
 
 var ScopeMap = map[Rank][]scope{
-	owner:     {*ScopeBillingAll, *ScopeTeamAll, *ScopeAlertAll, *ScopeAppAll, *ScopeBugReportAll},
-	admin:     {*ScopeBillingAll, *ScopeAlertAll, *ScopeAppAll, *ScopeBugReportAll, *ScopeTeamInviteSameOrLower, *ScopeTeamChangeRoleSameOrLower},
-	developer: {*ScopeBillingRead, *ScopeAlertAll, *ScopeAppRead, *ScopeBugReportAll, *ScopeTeamInviteSameOrLower, *ScopeTeamChangeRoleSameOrLower},
+	owner:     {*ScopeBillingAll, *ScopeTeamAll, *ScopeAlertAll, *ScopeAppAll, *ScopeBugReportAll, *ScopeErrorGroupAll},
+	admin:     {*ScopeBillingAll, *ScopeAlertAll, *ScopeAppAll, *ScopeBugReportAll, *ScopeErrorGroupAll, *ScopeTeamInviteSameOrLower, *ScopeTeamChangeRoleSameOrLower},
+	developer: {*ScopeBillingRead, *ScopeAlertAll, *ScopeAppRead, *ScopeBugReportAll, *ScopeErrorGroupAll, *ScopeTeamInviteSameOrLower, *ScopeTeamChangeRoleSameOrLower},
 	viewer:    {*ScopeBillingRead, *ScopeAlertRead, *ScopeTeamRead, *ScopeTeamInviteSameOrLower, *ScopeAppRead, *ScopeBugReportRead},
 }
 
@@ -211,6 +212,12 @@ func PerformAuthz(pg *pgxpool.Pool, uid string, rid string, scope scope) (bool,
 			return true, nil
 		}
 
+		return false, nil
+	case *ScopeErrorGroupAll:
+		if slices.Contains(roleScope, *ScopeErrorGroupAll) {
+			return true, nil
+		}
+
 		return false, nil
 	case *ScopeTeamInviteSameOrLower:
 		if slices.Contains(roleScope, *ScopeTeamAll) {
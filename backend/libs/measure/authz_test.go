@@ -329,6 +329,10 @@ func TestPerformAuthzMatrix(t *testing.T) {
 		{name: "viewer bug report all denied", role: "viewer", scope: *ScopeBugReportAll, wantAllow: false},
 		{name: "viewer bug report read allowed", role: "viewer", scope: *ScopeBugReportRead, wantAllow: true},
 		{name: "developer bug report read allowed", role: "developer", scope: *ScopeBugReportRead, wantAllow: true},
+		{name: "owner error group all allowed", role: "owner", scope: *ScopeErrorGroupAll, wantAllow: true},
+		{name: "admin error group all allowed", role: "admin", scope: *ScopeErrorGroupAll, wantAllow: true},
+		{name: "developer error group all allowed", role: "developer", scope: *ScopeErrorGroupAll, wantAllow: true},
+		{name: "viewer error group all denied", role: "viewer", scope: *ScopeErrorGroupAll, wantAllow: false},
 	}
 
 	for _, tc := range tests {
@@ -242,9 +242,10 @@ func writeDsymBundleZip(w io.Writer, name, versionName, versionCode string, dwar
 
 // OpenBuildFileDownload opens the downloadable form of a build's
 // mapping file. The stored artifact already is what developers expect
-// for proguard, elf_debug and jsbundle mappings and streams through
-// as-is; dsym artifacts are stored as the DWARF binary, so the
-// download reconstructs the .dSYM bundle around it as a zip.
+// for proguard, elf_debug, jsbundle, dart_obfuscation and
+// hermes_sourcemap mappings and streams through as-is; dsym artifacts
+// are stored as the DWARF binary, so the download reconstructs the
+// .dSYM bundle around it as a zip.
 func OpenBuildFileDownload(ctx context.Context, config BuildFileDownloadConfig, file BuildFile) (*BuildFileDownload, error) {
 	body, size, metadata, err := openSymbolObject(ctx, config, file.Key)
 	if err != nil {
@@ -285,6 +286,26 @@ func OpenBuildFileDownload(ctx context.Context, config BuildFileDownloadConfig,
 			stream:        passthrough,
 			closer:        body.Close,
 		}, nil
+	case symbol.TypeDartObfuscation.String():
+		filename := metadata["original_file_name"]
+		if filename == "" {
+			filename = "obfuscation.json"
+		}
+		return &BuildFileDownload{
+			Filename:      filename,
+			ContentType:   "application/json",
+			ContentLength: size,
+			stream:        passthrough,
+			closer:        body.Close,
+		}, nil
+	case symbol.TypeHermesSourcemap.String():
+		return &BuildFileDownload{
+			Filename:      path.Base(file.Key),
+			ContentType:   "application/json",
+			ContentLength: size,
+			stream:        passthrough,
+			closer:        body.Close,
+		}, nil
 	case symbol.TypeDsym.String():
 		name, nameErr := dsymName(ctx, config, file.Key)
 		if nameErr != nil {
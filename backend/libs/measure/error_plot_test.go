@@ -117,6 +117,16 @@ func TestGetErrorPlotInstancesRoutesBySeverity(t *testing.T) {
 		{"type=error returns both exception events", func(af *filter.AppFilter) { af.ErrorTypes = []event.ErrorType{event.ErrorTypeError} }, 2},
 		{"type=anr returns only the ANR", func(af *filter.AppFilter) { af.ErrorTypes = []event.ErrorType{event.ErrorTypeANR} }, 1},
 		{"no flags defaults to all sources", func(af *filter.AppFilter) {}, 3},
+		{"status=resolved returns only the resolved groups", func(af *filter.AppFilter) {
+			af.ErrorGroupStatuses = []string{"resolved"}
+			af.ErrorGroupIDs = []string{fpErrHandled}
+		}, 1},
+		{"status=open excludes the triaged groups", func(af *filter.AppFilter) {
+			af.ErrorGroupStatuses = []string{"open"}
+			af.ErrorGroupIDs = []string{fpErrHandled}
+			af.ExcludeErrorGroupIDs = true
+		}, 2},
+		{"status without matching groups returns nothing", func(af *filter.AppFilter) { af.ErrorGroupStatuses = []string{"ignored"} }, 0},
 	}
 
 	for _, c := range cases {
@@ -61,7 +61,8 @@ func cleanNullBytes(s string) string {
 // GetIssueGroupCommonPath computes the most common user navigation path leading
 // to a specific crash or ANR group. It validates the group exists, queries
 // ClickHouse for session data, and returns JSON with sessions_analyzed and steps.
-func GetIssueGroupCommonPath(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, groupType group.GroupType, fingerprint string) (json.RawMessage, error) {
+// Fingerprints of groups merged into the group are matched along with it.
+func GetIssueGroupCommonPath(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, groupType group.GroupType, fingerprint string, merged ...string) (json.RawMessage, error) {
 	app := App{
 		ID:     &appID,
 		TeamId: teamID,
@@ -88,18 +89,31 @@ func GetIssueGroupCommonPath(ctx context.Context, rch driver.Conn, teamID, appID
 		}
 	}
 
+	// fp is the fingerprint, or the fingerprints
+	// of the group and its merged groups
+	var fp any = fingerprint
+	matchFingerprint := func(column string) string {
+		return column + " = fp"
+	}
+	if len(merged) > 0 {
+		fp = append([]string{fingerprint}, merged...)
+		matchFingerprint = func(column string) string {
+			return "has(fp, " + column + ")"
+		}
+	}
+
 	// Build the WHERE clause condition based on type
 	var fingerprintCondition string
 	var lcRootValue string
 	switch groupType {
 	case group.GroupTypeCrash:
-		fingerprintCondition = "exception.fingerprint = fp"
+		fingerprintCondition = matchFingerprint("exception.fingerprint")
 		lcRootValue = logcomment.Crashes
 	case group.GroupTypeANR:
-		fingerprintCondition = "anr.fingerprint = fp"
+		fingerprintCondition = matchFingerprint("anr.fingerprint")
 		lcRootValue = logcomment.ANRs
 	case group.GroupTypeError:
-		fingerprintCondition = "(exception.fingerprint = fp OR anr.fingerprint = fp)"
+		fingerprintCondition = "(" + matchFingerprint("exception.fingerprint") + " OR " + matchFingerprint("anr.fingerprint") + ")"
 		lcRootValue = logcomment.Errors
 	}
 
@@ -187,7 +201,7 @@ func GetIssueGroupCommonPath(ctx context.Context, rch driver.Conn, teamID, appID
     SELECT count(*) AS session_count
     FROM affected_sessions
 `,
-		fingerprint,
+		fp,
 		app.TeamId,
 		*app.ID,
 		app.TeamId,
@@ -401,7 +415,7 @@ func GetIssueGroupCommonPath(ctx context.Context, rch driver.Conn, teamID, appID
       FROM best_event_per_position
       ORDER BY position_from_end DESC
       `,
-		fingerprint,
+		fp,
 		app.TeamId,
 		*app.ID,
 		app.TeamId,
@@ -9,3 +9,13 @@ const (
 	DefaultErrorSpikeMinCountThreshold = 100
 	DefaultErrorSpikeMinRateThreshold  = 0.5
 )
+
+// Default network endpoint regression alert preference values, applied
+// when an app has no explicit threshold prefs configured.
+const (
+	DefaultNetworkAlertsEnabled                = true
+	DefaultNetworkLatencyRegressionThreshold   = 50.0 // percent
+	DefaultNetworkErrorRateRegressionThreshold = 5.0  // percentage points
+	DefaultNetworkTrafficDropThreshold         = 80.0 // percent
+	DefaultNetworkMinRequestCountThreshold     = 100
+)
@@ -0,0 +1,293 @@
+package metricexport
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/libs/chrono"
+	"backend/libs/webhook"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// scrapeKeyPrefix marks scrape keys so they are
+// easy to recognize when leaked or pasted.
+const scrapeKeyPrefix = "msrmx_"
+
+// maxHeaders is the maximum number of headers
+// sent with every push.
+const maxHeaders = 20
+
+// ErrExportNotFound is returned when the team
+// has not configured a metric export.
+var ErrExportNotFound = errors.New("metric export not found")
+
+// Export is a team's metric export configuration.
+type Export struct {
+	TeamID        uuid.UUID
+	OTLPEndpoint  *string
+	OTLPHeaders   map[string]string
+	ScrapeKeyHash *string
+	IsActive      bool
+	LastPushedAt  *time.Time
+	LastPushError *string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// MarshalJSON leaves out the values of the push headers
+// and the scrape key hash, which are credentials.
+func (e Export) MarshalJSON() ([]byte, error) {
+	apiMap := make(map[string]any)
+	apiMap["otlp_endpoint"] = e.OTLPEndpoint
+	apiMap["otlp_header_names"] = e.HeaderNames()
+	apiMap["has_scrape_key"] = e.ScrapeKeyHash != nil
+	apiMap["is_active"] = e.IsActive
+	apiMap["last_pushed_at"] = nil
+	if e.LastPushedAt != nil {
+		apiMap["last_pushed_at"] = e.LastPushedAt.Format(chrono.ISOFormatJS)
+	}
+	apiMap["last_push_error"] = e.LastPushError
+	apiMap["created_at"] = e.CreatedAt.Format(chrono.ISOFormatJS)
+	apiMap["updated_at"] = e.UpdatedAt.Format(chrono.ISOFormatJS)
+	return json.Marshal(apiMap)
+}
+
+// Validate validates the export's push configuration.
+func (e Export) Validate() error {
+	if e.OTLPEndpoint != nil {
+		if err := webhook.ValidateURL(*e.OTLPEndpoint); err != nil {
+			return fmt.Errorf("invalid `otlp_endpoint`: %w", err)
+		}
+	}
+
+	if len(e.OTLPHeaders) > maxHeaders {
+		return fmt.Errorf("`otlp_headers` must not have more than %d headers", maxHeaders)
+	}
+
+	for name := range e.OTLPHeaders {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("`otlp_headers` has invalid header name %q", name)
+		}
+		if strings.EqualFold(name, "Content-Type") {
+			return errors.New("`otlp_headers` must not set the Content-Type header")
+		}
+	}
+
+	return nil
+}
+
+// HeaderNames returns the canonical names of the headers
+// sent with every push. Header values often hold
+// credentials, so only names are shown back to users.
+func (e Export) HeaderNames() (names []string) {
+	names = []string{}
+	for name := range e.OTLPHeaders {
+		names = append(names, http.CanonicalHeaderKey(name))
+	}
+	slices.Sort(names)
+	return
+}
+
+// CanScrape reports whether key is allowed
+// to scrape the export's metrics.
+func (e Export) CanScrape(key string) bool {
+	if !e.IsActive || e.ScrapeKeyHash == nil || key == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(HashScrapeKey(key)), []byte(*e.ScrapeKeyHash)) == 1
+}
+
+// NewScrapeKey generates a random key
+// guarding a team's scrape endpoint.
+func NewScrapeKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return scrapeKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// HashScrapeKey hashes a scrape key for storage.
+// Scrape keys are random, so a plain hash is enough.
+func HashScrapeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func exportsStmt() *sqlf.Stmt {
+	return sqlf.PostgreSQL.From("measure.metric_exports").
+		Select("team_id").
+		Select("otlp_endpoint").
+		Select("otlp_headers").
+		Select("scrape_key_hash").
+		Select("is_active").
+		Select("last_pushed_at").
+		Select("last_push_error").
+		Select("created_at").
+		Select("updated_at")
+}
+
+func scanExport(row pgx.Row) (e Export, err error) {
+	err = row.Scan(&e.TeamID, &e.OTLPEndpoint, &e.OTLPHeaders, &e.ScrapeKeyHash, &e.IsActive, &e.LastPushedAt, &e.LastPushError, &e.CreatedAt, &e.UpdatedAt)
+	return
+}
+
+// GetExport fetches the team's metric export.
+func GetExport(ctx context.Context, pg *pgxpool.Pool, teamID uuid.UUID) (export Export, err error) {
+	stmt := exportsStmt().
+		Where("team_id = ?", teamID)
+
+	defer stmt.Close()
+
+	export, err = scanExport(pg.QueryRow(ctx, stmt.String(), stmt.Args()...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Export{}, ErrExportNotFound
+	}
+
+	return
+}
+
+// GetPushExports fetches every active
+// export with an OTLP endpoint.
+func GetPushExports(ctx context.Context, pg *pgxpool.Pool) (exports []Export, err error) {
+	stmt := exportsStmt().
+		Where("is_active = true").
+		Where("otlp_endpoint is not null")
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Export
+		if e, err = scanExport(rows); err != nil {
+			return
+		}
+		exports = append(exports, e)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// Upsert creates or updates the export's push configuration
+// and active state, leaving its scrape key as it is.
+func (e Export) Upsert(ctx context.Context, pg *pgxpool.Pool) (err error) {
+	headers := e.OTLPHeaders
+	if headers == nil {
+		headers = map[string]string{}
+	}
+
+	_, err = pg.Exec(ctx, `
+		insert into measure.metric_exports (team_id, otlp_endpoint, otlp_headers, is_active, created_at, updated_at)
+		values ($1, $2, $3, $4, now(), now())
+		on conflict (team_id) do update set
+			otlp_endpoint = excluded.otlp_endpoint,
+			otlp_headers = excluded.otlp_headers,
+			is_active = excluded.is_active,
+			last_push_error = null,
+			updated_at = now()`,
+		e.TeamID, e.OTLPEndpoint, headers, e.IsActive)
+
+	return
+}
+
+// SetScrapeKey stores the hash of the team's scrape key, creating
+// the export when missing. A nil key disables scrapes.
+func SetScrapeKey(ctx context.Context, pg *pgxpool.Pool, teamID uuid.UUID, key *string) (err error) {
+	var hash *string
+	if key != nil {
+		h := HashScrapeKey(*key)
+		hash = &h
+	}
+
+	_, err = pg.Exec(ctx, `
+		insert into measure.metric_exports (team_id, scrape_key_hash, created_at, updated_at)
+		values ($1, $2, now(), now())
+		on conflict (team_id) do update set
+			scrape_key_hash = excluded.scrape_key_hash,
+			updated_at = now()`,
+		teamID, hash)
+
+	return
+}
+
+// SetPushResult records the outcome of a push
+// attempt. A nil error records a success.
+func SetPushResult(ctx context.Context, pg *pgxpool.Pool, teamID uuid.UUID, at time.Time, pushErr error) (err error) {
+	var msg *string
+	if pushErr != nil {
+		m := pushErr.Error()
+		msg = &m
+	}
+
+	stmt := sqlf.PostgreSQL.Update("measure.metric_exports").
+		Set("last_pushed_at", at).
+		Set("last_push_error", msg).
+		Where("team_id = ?", teamID)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	return
+}
+
+// DeleteExport deletes the team's metric export,
+// stopping both pushes and scrapes.
+func DeleteExport(ctx context.Context, pg *pgxpool.Pool, teamID uuid.UUID) (err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("measure.metric_exports").
+		Where("team_id = ?", teamID)
+
+	defer stmt.Close()
+
+	_, err = pg.Exec(ctx, stmt.String(), stmt.Args()...)
+	return
+}
+
+// GetTeamApps fetches the team's apps whose
+// metrics are exported.
+func GetTeamApps(ctx context.Context, pg *pgxpool.Pool, teamID uuid.UUID) (apps []App, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.apps").
+		Select("id").
+		Select("coalesce(app_name, '')").
+		Where("team_id = ?", teamID).
+		OrderBy("app_name")
+
+	defer stmt.Close()
+
+	rows, err := pg.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a App
+		if err = rows.Scan(&a.ID, &a.Name); err != nil {
+			return
+		}
+		apps = append(apps, a)
+	}
+
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,137 @@
+package metricexport
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	labels := []Label{
+		{Name: "app_id", Value: "a"},
+		{Name: "app_name", Value: `say "hi"\` + "\n"},
+	}
+
+	samples := []Sample{
+		{Metric: Sessions, Labels: labels, Value: 42},
+		{Metric: CrashFreeSessions, Labels: labels, Value: 0.995},
+		{Metric: Sessions, Labels: []Label{{Name: "app_id", Value: "b"}}, Value: 0},
+		{Metric: SpanDuration, Value: math.NaN()},
+	}
+
+	var buf bytes.Buffer
+	if err := WritePrometheus(&buf, samples); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := strings.Join([]string{
+		"# HELP measure_sessions Number of sessions in the last hour.",
+		"# TYPE measure_sessions gauge",
+		`measure_sessions{app_id="a",app_name="say \"hi\"\\\n"} 42`,
+		`measure_sessions{app_id="b"} 0`,
+		"# HELP measure_crash_free_sessions_ratio Ratio of sessions without a crash in the last hour.",
+		"# TYPE measure_crash_free_sessions_ratio gauge",
+		`measure_crash_free_sessions_ratio{app_id="a",app_name="say \"hi\"\\\n"} 0.995`,
+		"# HELP measure_span_duration_milliseconds Quantiles of span durations in the last hour, by span name.",
+		"# TYPE measure_span_duration_milliseconds gauge",
+		"measure_span_duration_milliseconds NaN",
+		"",
+	}, "\n")
+
+	if got := buf.String(); got != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestQuantileSamples(t *testing.T) {
+	labels := []Label{{Name: "app_id", Value: "a"}}
+
+	samples := quantileSamples(HttpLatency, labels, []float64{10, 20, math.NaN(), 40, 50, 60})
+
+	if len(samples) != 4 {
+		t.Fatalf("Expected 4 samples, got %d", len(samples))
+	}
+
+	expected := []string{"0.5", "0.75", "0.95", "0.99"}
+	for i, s := range samples {
+		if len(s.Labels) != 2 {
+			t.Fatalf("Expected 2 labels, got %v", s.Labels)
+		}
+		if q := s.Labels[1]; q.Name != "quantile" || q.Value != expected[i] {
+			t.Errorf("Expected quantile %s, got %v", expected[i], q)
+		}
+	}
+
+	if len(labels) != 1 {
+		t.Errorf("Expected the shared labels to be left alone, got %v", labels)
+	}
+}
+
+func TestScrapeKey(t *testing.T) {
+	key, err := NewScrapeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, scrapeKeyPrefix) {
+		t.Errorf("Expected key to start with %q, got %q", scrapeKeyPrefix, key)
+	}
+
+	hash := HashScrapeKey(key)
+	export := Export{IsActive: true, ScrapeKeyHash: &hash}
+
+	if !export.CanScrape(key) {
+		t.Error("Expected key to be allowed to scrape")
+	}
+
+	if export.CanScrape(key + "x") {
+		t.Error("Expected wrong key to be denied")
+	}
+
+	if export.CanScrape("") {
+		t.Error("Expected empty key to be denied")
+	}
+
+	export.IsActive = false
+	if export.CanScrape(key) {
+		t.Error("Expected key of inactive export to be denied")
+	}
+
+	if (Export{IsActive: true}).CanScrape(key) {
+		t.Error("Expected export without a scrape key to deny scrapes")
+	}
+}
+
+func TestExportValidate(t *testing.T) {
+	endpoint := "https://otel.example.com/v1/metrics"
+	invalid := "ftp://otel.example.com"
+	private := "http://10.0.0.12:4318/v1/metrics"
+	loopback := "http://localhost:4318/v1/metrics"
+
+	cases := []struct {
+		name   string
+		export Export
+		valid  bool
+	}{
+		{"push", Export{OTLPEndpoint: &endpoint, OTLPHeaders: map[string]string{"Authorization": "Bearer x"}}, true},
+		{"scrape only", Export{}, true},
+		{"invalid endpoint", Export{OTLPEndpoint: &invalid}, false},
+		{"private endpoint", Export{OTLPEndpoint: &private}, false},
+		{"loopback endpoint", Export{OTLPEndpoint: &loopback}, false},
+		{"invalid header name", Export{OTLPEndpoint: &endpoint, OTLPHeaders: map[string]string{"X Key": "x"}}, false},
+		{"content type header", Export{OTLPEndpoint: &endpoint, OTLPHeaders: map[string]string{"content-type": "text/plain"}}, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.export.Validate()
+			if c.valid && err != nil {
+				t.Errorf("Expected valid, got %v", err)
+			}
+			if !c.valid && err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,341 @@
+// Package metricexport exports the aggregate metrics Measure derives from
+// a team's data, like crash free sessions, launch times and span and HTTP
+// latencies, to external metrics systems. The alerts service periodically
+// pushes them as OTLP metrics and the api service serves them to
+// Prometheus scrapes.
+//
+// Every metric is a gauge over the trailing Window, labeled with the app it
+// belongs to.
+package metricexport
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// Window is the trailing window every
+// metric is computed over.
+const Window = time.Hour
+
+// MaxSpanNames is the maximum number of span names, most
+// frequent first, exported per app. Caps the cardinality
+// of span metrics.
+const MaxSpanNames = 50
+
+// MaxHttpPatterns is the maximum number of URL patterns,
+// most requested first, exported per app. Caps the
+// cardinality of HTTP metrics.
+const MaxHttpPatterns = 100
+
+// quantiles are the quantiles exported for durations.
+// They match the quantile states stored in http_metrics.
+var quantiles = []float64{0.5, 0.75, 0.9, 0.95, 0.99}
+
+// quantilesExpr is the quantiles
+// aggregate function's parameters.
+const quantilesExpr = "quantiles(0.5, 0.75, 0.9, 0.95, 0.99)"
+
+// Metric describes an exported metric.
+type Metric struct {
+	Name string
+	Help string
+	Unit string
+}
+
+var (
+	Sessions = Metric{
+		Name: "measure_sessions",
+		Help: "Number of sessions in the last hour.",
+		Unit: "{session}",
+	}
+	CrashFreeSessions = Metric{
+		Name: "measure_crash_free_sessions_ratio",
+		Help: "Ratio of sessions without a crash in the last hour.",
+		Unit: "1",
+	}
+	ANRFreeSessions = Metric{
+		Name: "measure_anr_free_sessions_ratio",
+		Help: "Ratio of sessions without an ANR in the last hour.",
+		Unit: "1",
+	}
+	LaunchDuration = Metric{
+		Name: "measure_launch_duration_milliseconds",
+		Help: "Quantiles of app launch durations in the last hour, by launch type.",
+		Unit: "ms",
+	}
+	Spans = Metric{
+		Name: "measure_spans",
+		Help: "Number of spans in the last hour, by span name.",
+		Unit: "{span}",
+	}
+	SpanDuration = Metric{
+		Name: "measure_span_duration_milliseconds",
+		Help: "Quantiles of span durations in the last hour, by span name.",
+		Unit: "ms",
+	}
+	HttpRequests = Metric{
+		Name: "measure_http_requests",
+		Help: "Number of HTTP requests in the last hour, by URL pattern.",
+		Unit: "{request}",
+	}
+	HttpErrorRatio = Metric{
+		Name: "measure_http_error_ratio",
+		Help: "Ratio of HTTP requests with a 4xx or 5xx response in the last hour, by URL pattern and status class.",
+		Unit: "1",
+	}
+	HttpLatency = Metric{
+		Name: "measure_http_latency_milliseconds",
+		Help: "Quantiles of HTTP request latencies in the last hour, by URL pattern.",
+		Unit: "ms",
+	}
+)
+
+// Label is a name and value pair
+// identifying a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one value of a metric.
+type Sample struct {
+	Metric Metric
+	Labels []Label
+	Value  float64
+}
+
+// App is an app whose metrics are exported.
+type App struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// labels are the labels identifying the app,
+// followed by the extra labels.
+func (a App) labels(extra ...Label) []Label {
+	return append([]Label{
+		{Name: "app_id", Value: a.ID.String()},
+		{Name: "app_name", Value: a.Name},
+	}, extra...)
+}
+
+// Collect computes the metrics of the team's apps over
+// the Window ending at to.
+func Collect(ctx context.Context, rch driver.Conn, teamID uuid.UUID, apps []App, to time.Time) (samples []Sample, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+	from := to.Add(-Window)
+
+	collectors := []func(context.Context, driver.Conn, uuid.UUID, App, time.Time, time.Time) ([]Sample, error){
+		collectSessions,
+		collectLaunches,
+		collectSpans,
+		collectHttp,
+	}
+
+	for _, app := range apps {
+		for _, collect := range collectors {
+			s, err := collect(ctx, rch, teamID, app, from, to)
+			if err != nil {
+				return nil, fmt.Errorf("app %s: %w", app.ID, err)
+			}
+			samples = append(samples, s...)
+		}
+	}
+
+	return
+}
+
+// collectSessions computes the session
+// count and the crash and ANR free ratios.
+func collectSessions(ctx context.Context, rch driver.Conn, teamID uuid.UUID, app App, from, to time.Time) (samples []Sample, err error) {
+	stmt := sqlf.
+		From(config.EventsTable).
+		Select("uniq(session_id)").
+		Select("uniqIf(session_id, type = 'exception' and "+config.FatalExceptionExpr+")").
+		Select("uniqIf(session_id, type = 'anr')").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("timestamp >= ?", from).
+		Where("timestamp < ?", to)
+
+	defer stmt.Close()
+
+	var sessions, crashed, anred uint64
+	if err = rch.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&sessions, &crashed, &anred); err != nil {
+		return
+	}
+
+	samples = append(samples, Sample{Metric: Sessions, Labels: app.labels(), Value: float64(sessions)})
+
+	// free ratios of a window without
+	// sessions are undefined
+	if sessions == 0 {
+		return
+	}
+
+	samples = append(samples,
+		Sample{Metric: CrashFreeSessions, Labels: app.labels(), Value: 1 - float64(crashed)/float64(sessions)},
+		Sample{Metric: ANRFreeSessions, Labels: app.labels(), Value: 1 - float64(anred)/float64(sessions)},
+	)
+
+	return
+}
+
+// collectLaunches computes the launch
+// duration quantiles by launch type.
+func collectLaunches(ctx context.Context, rch driver.Conn, teamID uuid.UUID, app App, from, to time.Time) (samples []Sample, err error) {
+	stmt := sqlf.
+		From(config.EventsTable).
+		Select("type").
+		Select(quantilesExpr+"(multiIf(type = 'cold_launch', `cold_launch.duration`, type = 'warm_launch', `warm_launch.duration`, `hot_launch.duration`))").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("timestamp >= ?", from).
+		Where("timestamp < ?", to).
+		Where("type in ('cold_launch', 'warm_launch', 'hot_launch')").
+		Where("multiIf(type = 'cold_launch', `cold_launch.duration`, type = 'warm_launch', `warm_launch.duration`, `hot_launch.duration`) > 0").
+		GroupBy("type").
+		OrderBy("type")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var launchType string
+		var values []float64
+		if err = rows.Scan(&launchType, &values); err != nil {
+			return
+		}
+		samples = append(samples, quantileSamples(LaunchDuration, app.labels(Label{Name: "launch_type", Value: launchType}), values)...)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// collectSpans computes the span count and
+// the duration quantiles by span name.
+func collectSpans(ctx context.Context, rch driver.Conn, teamID uuid.UUID, app App, from, to time.Time) (samples []Sample, err error) {
+	stmt := sqlf.
+		From("spans final").
+		Select("span_name").
+		Select("count() as span_count").
+		Select(quantilesExpr+"(dateDiff('millisecond', start_time, end_time))").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("start_time >= ?", from).
+		Where("start_time < ?", to).
+		GroupBy("span_name").
+		OrderBy("span_count desc").
+		Limit(MaxSpanNames)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var count uint64
+		var values []float64
+		if err = rows.Scan(&name, &count, &values); err != nil {
+			return
+		}
+		labels := app.labels(Label{Name: "span_name", Value: name})
+		samples = append(samples, Sample{Metric: Spans, Labels: labels, Value: float64(count)})
+		samples = append(samples, quantileSamples(SpanDuration, labels, values)...)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// collectHttp computes the request count, error ratios
+// and latency quantiles by URL pattern, from the HTTP
+// metrics pre-aggregated by the alerts service.
+func collectHttp(ctx context.Context, rch driver.Conn, teamID uuid.UUID, app App, from, to time.Time) (samples []Sample, err error) {
+	stmt := sqlf.
+		From("http_metrics").
+		Select("domain").
+		Select("path").
+		Select("sum(request_count) as requests").
+		Select("sum(count_4xx)").
+		Select("sum(count_5xx)").
+		Select("quantilesMerge(0.5, 0.75, 0.90, 0.95, 0.99)(latency_percentiles)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", app.ID).
+		Where("timestamp >= ?", from).
+		Where("timestamp < ?", to).
+		GroupBy("domain").
+		GroupBy("path").
+		OrderBy("requests desc").
+		Limit(MaxHttpPatterns)
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var domain, path string
+		var requests, count4xx, count5xx uint64
+		var values []float64
+		if err = rows.Scan(&domain, &path, &requests, &count4xx, &count5xx, &values); err != nil {
+			return
+		}
+
+		labels := app.labels(Label{Name: "domain", Value: domain}, Label{Name: "path", Value: path})
+		samples = append(samples, Sample{Metric: HttpRequests, Labels: labels, Value: float64(requests)})
+
+		if requests > 0 {
+			samples = append(samples,
+				Sample{Metric: HttpErrorRatio, Labels: append(labels[:len(labels):len(labels)], Label{Name: "status_class", Value: "4xx"}), Value: float64(count4xx) / float64(requests)},
+				Sample{Metric: HttpErrorRatio, Labels: append(labels[:len(labels):len(labels)], Label{Name: "status_class", Value: "5xx"}), Value: float64(count5xx) / float64(requests)},
+			)
+		}
+
+		samples = append(samples, quantileSamples(HttpLatency, labels, values)...)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// quantileSamples labels each quantile value with
+// its quantile. Values of empty sets are skipped.
+func quantileSamples(metric Metric, labels []Label, values []float64) (samples []Sample) {
+	for i, value := range values {
+		if i >= len(quantiles) || math.IsNaN(value) {
+			continue
+		}
+
+		samples = append(samples, Sample{
+			Metric: metric,
+			Labels: append(labels[:len(labels):len(labels)], Label{Name: "quantile", Value: strconv.FormatFloat(quantiles[i], 'g', -1, 64)}),
+			Value:  value,
+		})
+	}
+
+	return
+}
@@ -0,0 +1,80 @@
+package metricexport
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PrometheusContentType is the content type of
+// the Prometheus text exposition format.
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// labelValueReplacer escapes label values
+// for the text exposition format.
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// helpReplacer escapes help text for
+// the text exposition format.
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// WritePrometheus writes the samples in the Prometheus text
+// exposition format. Samples of a metric are grouped under
+// the metric's help and type lines, in the order each metric
+// is first seen.
+func WritePrometheus(w io.Writer, samples []Sample) error {
+	var names []string
+	groups := map[string][]Sample{}
+
+	for _, s := range samples {
+		if _, ok := groups[s.Metric.Name]; !ok {
+			names = append(names, s.Metric.Name)
+		}
+		groups[s.Metric.Name] = append(groups[s.Metric.Name], s)
+	}
+
+	bw := bufio.NewWriter(w)
+
+	for _, name := range names {
+		group := groups[name]
+
+		bw.WriteString("# HELP " + name + " " + helpReplacer.Replace(group[0].Metric.Help) + "\n")
+		bw.WriteString("# TYPE " + name + " gauge\n")
+
+		for _, s := range group {
+			bw.WriteString(name)
+			if len(s.Labels) > 0 {
+				bw.WriteByte('{')
+				for i, l := range s.Labels {
+					if i > 0 {
+						bw.WriteByte(',')
+					}
+					bw.WriteString(l.Name + `="` + labelValueReplacer.Replace(l.Value) + `"`)
+				}
+				bw.WriteByte('}')
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+
+	return bw.Flush()
+}
+
+// formatValue formats a sample value, spelling
+// out infinities and NaN the way Prometheus does.
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
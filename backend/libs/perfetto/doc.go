@@ -0,0 +1,24 @@
+// Package perfetto summarizes perfetto traces of Android apps.
+//
+// Uploaded traces, gzipped or not, are summarized with [Summarize] into a
+// [Summary] of the app's process. Slices come from atrace markers written to
+// ftrace & from track events. A summary holds:
+//
+//   - Startup, from the process's first slice to the end of its first frame,
+//     broken down into phases & the main thread work done in them
+//   - Jank, the outermost main thread slices longer than 16ms
+//   - Binder transactions the app's threads sent & waited on
+//   - Garbage collection, & the time the main thread spent on it
+//   - The time weighted average frequency of each CPU
+//
+// Durations are in nanoseconds.
+//
+// Summaries are read back per session with [GetSessionSummaries], and
+// startups broken down across the traces of each app version with
+// [GetStartupBreakdowns].
+//
+//	breakdowns, err := perfetto.GetStartupBreakdowns(ctx, rch, teamID, appID, perfetto.Query{
+//	    From: from,
+//	    To:   to,
+//	})
+package perfetto
@@ -0,0 +1,188 @@
+package perfetto
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"backend/libs/chquery"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+// maxStartupBreakdowns is the number of app
+// versions startup is broken down for.
+const maxStartupBreakdowns = 100
+
+// TraceSummary is the summary of a perfetto
+// trace attached to an event.
+type TraceSummary struct {
+	EventID     uuid.UUID `json:"event_id"`
+	EventType   string    `json:"event_type"`
+	SessionID   uuid.UUID `json:"session_id"`
+	Timestamp   time.Time `json:"timestamp"`
+	VersionName string    `json:"version_name"`
+	VersionCode string    `json:"version_code"`
+	Summary
+}
+
+// StartupBreakdown breaks down the startups of an
+// app version captured by perfetto traces.
+type StartupBreakdown struct {
+	VersionName string `json:"version_name"`
+	VersionCode string `json:"version_code"`
+	// Launches is the count of traces
+	// that captured a startup.
+	Launches uint64 `json:"launches"`
+	// P50Duration & P95Duration are
+	// percentiles of startup time.
+	P50Duration time.Duration `json:"p50_duration"`
+	P95Duration time.Duration `json:"p95_duration"`
+	// Phases are the average time
+	// of each startup phase.
+	Phases []Work `json:"phases"`
+	// Work is the average main thread time
+	// spent on each kind of work during
+	// startup, most time first.
+	Work []Work `json:"work"`
+}
+
+// Query selects the trace summaries whose
+// startups are broken down. Empty fields
+// don't filter.
+type Query struct {
+	// From and To bound the time
+	// traces were taken at.
+	From time.Time
+	To   time.Time
+	// Version and VersionCode
+	// pick an app version.
+	Version     string
+	VersionCode string
+}
+
+// GetSessionSummaries fetches the summaries of
+// the session's perfetto traces, oldest first.
+func GetSessionSummaries(ctx context.Context, rch driver.Conn, teamID, appID, sessionID uuid.UUID) (summaries []TraceSummary, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	stmt := sqlf.From("trace_summaries final").
+		Select("event_id").
+		Select("event_type").
+		Select("session_id").
+		Select("timestamp").
+		Select("app_version.1").
+		Select("app_version.2").
+		Select("summary").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("session_id = toUUID(?)", sessionID).
+		OrderBy("timestamp")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s TraceSummary
+		var summary string
+		if err = rows.Scan(&s.EventID, &s.EventType, &s.SessionID, &s.Timestamp, &s.VersionName, &s.VersionCode, &summary); err != nil {
+			return
+		}
+		if err = json.Unmarshal([]byte(summary), &s.Summary); err != nil {
+			return
+		}
+		summaries = append(summaries, s)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// GetStartupBreakdowns breaks down the startups the
+// query selects by app version, latest version
+// first.
+func GetStartupBreakdowns(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, q Query) (breakdowns []StartupBreakdown, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	stmt := sqlf.From("trace_summaries final").
+		Select("app_version.1 as version_name").
+		Select("app_version.2 as version_code").
+		Select("count()").
+		Select("quantile(0.5)(startup_duration)").
+		Select("quantile(0.95)(startup_duration)").
+		Select("(sumMap(startup_phase_names, startup_phase_durations) as phases).1").
+		Select("phases.2").
+		Select("(sumMap(startup_work_kinds, startup_work_durations) as work).1").
+		Select("work.2").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= toDateTime64(?, 3, 'UTC')", q.From).
+		Where("timestamp <= toDateTime64(?, 3, 'UTC')", q.To).
+		Where("startup_duration > 0").
+		GroupBy("version_name, version_code").
+		OrderBy("version_name desc, version_code desc").
+		Limit(maxStartupBreakdowns)
+
+	if q.Version != "" && q.VersionCode != "" {
+		stmt.Where("app_version.1 = ? and app_version.2 = ?", q.Version, q.VersionCode)
+	}
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b StartupBreakdown
+		var p50, p95 float64
+		var phaseNames, workKinds []string
+		var phaseDurations, workDurations []uint64
+		if err = rows.Scan(&b.VersionName, &b.VersionCode, &b.Launches, &p50, &p95, &phaseNames, &phaseDurations, &workKinds, &workDurations); err != nil {
+			return
+		}
+
+		b.P50Duration = time.Duration(p50)
+		b.P95Duration = time.Duration(p95)
+		b.Phases = averages(phaseNames, phaseDurations, b.Launches)
+		b.Work = averages(workKinds, workDurations, b.Launches)
+
+		// phases in startup order,
+		// work most time first
+		slices.SortStableFunc(b.Phases, func(a, b Work) int {
+			return cmp.Compare(slices.Index(startupPhases, a.Kind), slices.Index(startupPhases, b.Kind))
+		})
+		slices.SortStableFunc(b.Work, func(a, b Work) int {
+			return cmp.Compare(b.Duration, a.Duration)
+		})
+
+		breakdowns = append(breakdowns, b)
+	}
+
+	err = rows.Err()
+	return
+}
+
+// averages averages the summed
+// durations over the launches.
+func averages(kinds []string, durations []uint64, launches uint64) []Work {
+	work := make([]Work, 0, len(kinds))
+	for i, kind := range kinds {
+		if i >= len(durations) || launches == 0 {
+			break
+		}
+		work = append(work, Work{Kind: kind, Duration: time.Duration(durations[i] / launches)})
+	}
+	return work
+}
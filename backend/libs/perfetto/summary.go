@@ -0,0 +1,500 @@
+package perfetto
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// jankThreshold is the time a main thread slice may
+// take before it drops a frame at 60 fps.
+const jankThreshold = 16 * time.Millisecond
+
+// maxLongest is the number of longest main thread
+// slices & binder transactions kept in a summary.
+const maxLongest = 10
+
+// Startup phases, the first slices of these
+// names on the app's main thread.
+const (
+	phaseBindApplication = "bindApplication"
+	phaseActivityStart   = "activityStart"
+	phaseActivityResume  = "activityResume"
+	phaseFirstFrame      = "Choreographer#doFrame"
+)
+
+// startupPhases are the startup
+// phases in order.
+var startupPhases = []string{
+	phaseBindApplication,
+	phaseActivityStart,
+	phaseActivityResume,
+	phaseFirstFrame,
+}
+
+// Kinds of work startup time is
+// broken down into.
+const (
+	WorkInflate        = "inflate"
+	WorkResources      = "resources"
+	WorkDexLoading     = "dex_loading"
+	WorkClassLoading   = "class_verification"
+	WorkBinder         = "binder"
+	WorkGC             = "gc"
+	WorkLockContention = "lock_contention"
+)
+
+// workPatterns match the slices of each
+// kind of startup work.
+var workPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{WorkInflate, regexp.MustCompile(`^(inflate|Inflate)`)},
+	{WorkResources, regexp.MustCompile(`^ResourcesManager#|^AssetManager::|^Theme::ApplyStyle`)},
+	{WorkDexLoading, regexp.MustCompile(`^OpenDexFilesFromOat|^Open dex file|^Open oat file`)},
+	{WorkClassLoading, regexp.MustCompile(`^VerifyClass|^verifyClass|^Class initialization`)},
+	{WorkBinder, regexp.MustCompile(`^binder transaction`)},
+	{WorkGC, gcPattern},
+	{WorkLockContention, regexp.MustCompile(`^Lock contention|^monitor contention|^Contending for`)},
+}
+
+// gcPattern matches slices of ART's garbage
+// collector & of threads waiting on it.
+var gcPattern = regexp.MustCompile(`(?i)(^|\s)GC($|\s|:)|WaitForGcToComplete|^CollectorTransition`)
+
+// Summary summarizes a perfetto trace for the
+// app's process.
+type Summary struct {
+	// ProcessName is the name of the app's process,
+	// empty if the trace doesn't name it.
+	ProcessName string `json:"process_name"`
+	// Duration is the time the trace spans.
+	Duration time.Duration `json:"duration"`
+	// Startup breaks down the app's startup,
+	// nil if the trace didn't capture one.
+	Startup *Startup `json:"startup"`
+	// Jank are the main thread slices
+	// long enough to drop frames.
+	Jank Jank `json:"jank"`
+	// Binder are the binder transactions
+	// the app's threads sent.
+	Binder Binder `json:"binder"`
+	// GC is the time spent on
+	// garbage collection.
+	GC GC `json:"gc"`
+	// CPUFrequencies are the frequencies
+	// of each CPU, by CPU.
+	CPUFrequencies []CPUFrequency `json:"cpu_frequencies"`
+}
+
+// Startup breaks down the time
+// the app took to start.
+type Startup struct {
+	// Duration is the time from the start of the
+	// process to the end of its first frame, or
+	// of its last phase if no frame was drawn.
+	Duration time.Duration `json:"duration"`
+	// Phases are the startup phases
+	// the trace captured, in order.
+	Phases []Span `json:"phases"`
+	// Work is the main thread time spent on each
+	// kind of work during startup, most time
+	// first.
+	Work []Work `json:"work"`
+}
+
+// Span is a named span of time. Start is the
+// offset from the start of the trace, or of
+// startup for startup phases.
+type Span struct {
+	Name     string        `json:"name"`
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Work is the time spent
+// on a kind of work.
+type Work struct {
+	// Kind is the kind of work,
+	// like "inflate".
+	Kind     string        `json:"kind"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Jank summarizes the main thread slices
+// long enough to drop frames.
+type Jank struct {
+	// Slices is the count of outermost main thread
+	// slices longer than 16ms.
+	Slices uint64 `json:"slices"`
+	// Duration is the time spent
+	// on those slices.
+	Duration time.Duration `json:"duration"`
+	// Longest are the longest
+	// of those slices.
+	Longest []Span `json:"longest"`
+}
+
+// Binder summarizes the binder
+// transactions the app sent.
+type Binder struct {
+	// Transactions is the count of
+	// transactions sent.
+	Transactions uint64 `json:"transactions"`
+	// MainThreadTransactions is the count of
+	// transactions the main thread sent.
+	MainThreadTransactions uint64 `json:"main_thread_transactions"`
+	// Duration is the time threads
+	// waited on replies.
+	Duration time.Duration `json:"duration"`
+	// MainThreadDuration is the time the
+	// main thread waited on replies.
+	MainThreadDuration time.Duration `json:"main_thread_duration"`
+	// Longest are the transactions waited on
+	// the longest, named after the process
+	// they were sent to.
+	Longest []Transaction `json:"longest"`
+}
+
+// Transaction is a binder transaction
+// waited on.
+type Transaction struct {
+	Span
+	// ThreadName is the name of
+	// the sending thread.
+	ThreadName string `json:"thread_name"`
+	// MainThread is true if the main
+	// thread sent the transaction.
+	MainThread bool `json:"main_thread"`
+}
+
+// GC summarizes the time spent
+// on garbage collection.
+type GC struct {
+	// Collections is the count of
+	// garbage collections.
+	Collections uint64 `json:"collections"`
+	// Duration is the time the
+	// collector ran.
+	Duration time.Duration `json:"duration"`
+	// Longest is the longest
+	// collection.
+	Longest time.Duration `json:"longest"`
+	// MainThreadDuration is the time the main
+	// thread spent collecting or waiting on
+	// the collector.
+	MainThreadDuration time.Duration `json:"main_thread_duration"`
+}
+
+// CPUFrequency is the frequency of a CPU
+// over the trace, in kHz.
+type CPUFrequency struct {
+	CPU uint32 `json:"cpu"`
+	// Average is weighted by the time
+	// spent at each frequency.
+	Average uint64 `json:"average"`
+	Max     uint64 `json:"max"`
+}
+
+// Summarize parses a perfetto trace & summarizes the
+// process of the app: jank, startup phases, binder
+// transactions & garbage collection, along with CPU
+// frequencies. The app's process is the process
+// named packageName, or else the process with the
+// most slices.
+func Summarize(data []byte, packageName string) (s Summary, err error) {
+	t, err := parseTrace(data)
+	if err != nil {
+		return
+	}
+
+	pid := t.appProcess(packageName)
+	s.ProcessName = t.processes[pid]
+	s.Duration = time.Duration(t.end - t.start)
+
+	var main []slice
+	var app []slice
+	for _, sl := range t.slices {
+		if sl.pid != pid {
+			continue
+		}
+		app = append(app, sl)
+		if sl.tid == pid {
+			main = append(main, sl)
+		}
+	}
+
+	s.Startup = t.startup(app, main)
+	s.Jank = t.jank(main)
+	s.Binder = t.binder(pid)
+	s.GC = gc(app, pid)
+	s.CPUFrequencies = t.cpuFrequencies()
+
+	return
+}
+
+// appProcess picks the app's process, by name
+// or else by the most slices.
+func (t *trace) appProcess(packageName string) (pid int32) {
+	if packageName != "" {
+		for p, name := range t.processes {
+			if name == packageName {
+				return p
+			}
+		}
+	}
+
+	counts := map[int32]int{}
+	for _, sl := range t.slices {
+		if sl.pid != 0 {
+			counts[sl.pid]++
+		}
+	}
+
+	most := 0
+	for p, count := range counts {
+		if count > most || (count == most && p < pid) {
+			pid, most = p, count
+		}
+	}
+
+	return
+}
+
+// startup breaks down the app's startup, from its
+// first slice to the end of its first frame.
+func (t *trace) startup(app, main []slice) *Startup {
+	first := map[string]slice{}
+	for _, sl := range main {
+		if !slices.Contains(startupPhases, sl.name) {
+			continue
+		}
+		// the first frame is the one
+		// after the activity resumed
+		if sl.name == phaseFirstFrame {
+			if resume, ok := first[phaseActivityResume]; ok && sl.start < resume.start {
+				continue
+			}
+		}
+		if f, ok := first[sl.name]; !ok || sl.start < f.start {
+			first[sl.name] = sl
+		}
+	}
+
+	if _, ok := first[phaseBindApplication]; !ok {
+		return nil
+	}
+
+	var start int64
+	for i, sl := range app {
+		if i == 0 || sl.start < start {
+			start = sl.start
+		}
+	}
+
+	startup := &Startup{}
+	var end int64
+	for _, name := range startupPhases {
+		sl, ok := first[name]
+		if !ok {
+			continue
+		}
+		startup.Phases = append(startup.Phases, Span{
+			Name:     name,
+			Start:    time.Duration(sl.start - start),
+			Duration: time.Duration(sl.dur),
+		})
+		end = max(end, sl.start+sl.dur)
+	}
+	startup.Duration = time.Duration(end - start)
+
+	work := map[string][][2]int64{}
+	for _, sl := range main {
+		if sl.start >= end || sl.start+sl.dur <= start {
+			continue
+		}
+		for _, p := range workPatterns {
+			if p.re.MatchString(sl.name) {
+				work[p.kind] = append(work[p.kind], [2]int64{max(sl.start, start), min(sl.start+sl.dur, end)})
+				break
+			}
+		}
+	}
+
+	for _, p := range workPatterns {
+		if spans, ok := work[p.kind]; ok {
+			startup.Work = append(startup.Work, Work{Kind: p.kind, Duration: time.Duration(union(spans))})
+		}
+	}
+
+	slices.SortStableFunc(startup.Work, func(a, b Work) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+
+	return startup
+}
+
+// jank finds the outermost main thread
+// slices long enough to drop frames.
+func (t *trace) jank(main []slice) (j Jank) {
+	for _, sl := range main {
+		if sl.depth != 0 || time.Duration(sl.dur) <= jankThreshold {
+			continue
+		}
+		j.Slices++
+		j.Duration += time.Duration(sl.dur)
+		j.Longest = append(j.Longest, Span{
+			Name:     sl.name,
+			Start:    time.Duration(sl.start - t.start),
+			Duration: time.Duration(sl.dur),
+		})
+	}
+
+	j.Longest = longest(j.Longest, func(s Span) time.Duration { return s.Duration })
+	return
+}
+
+// binder summarizes the binder
+// transactions the app sent.
+func (t *trace) binder(pid int32) (b Binder) {
+	for _, tx := range t.transactions {
+		if t.threadGroup[tx.tid] != pid && tx.pid != pid {
+			continue
+		}
+
+		mainThread := tx.tid == pid
+		b.Transactions++
+		b.Duration += time.Duration(tx.dur)
+		if mainThread {
+			b.MainThreadTransactions++
+			b.MainThreadDuration += time.Duration(tx.dur)
+		}
+
+		if tx.oneWay {
+			continue
+		}
+
+		name := t.processes[tx.toPID]
+		if name == "" {
+			name = "binder transaction"
+		}
+		b.Longest = append(b.Longest, Transaction{
+			Span: Span{
+				Name:     name,
+				Start:    time.Duration(tx.start - t.start),
+				Duration: time.Duration(tx.dur),
+			},
+			ThreadName: t.threads[tx.tid],
+			MainThread: mainThread,
+		})
+	}
+
+	b.Longest = longest(b.Longest, func(tx Transaction) time.Duration { return tx.Duration })
+	return
+}
+
+// gc sums the time spent on garbage collection by the
+// app's threads, counting nested slices once.
+func gc(app []slice, pid int32) (g GC) {
+	var all, main [][2]int64
+	for _, sl := range app {
+		if !gcPattern.MatchString(sl.name) {
+			continue
+		}
+
+		span := [2]int64{sl.start, sl.start + sl.dur}
+		if sl.tid == pid {
+			main = append(main, span)
+			continue
+		}
+
+		all = append(all, span)
+		if sl.depth == 0 && !strings.HasPrefix(sl.name, "WaitForGcToComplete") {
+			g.Collections++
+			g.Longest = max(g.Longest, time.Duration(sl.dur))
+		}
+	}
+
+	g.Duration = time.Duration(union(all))
+	g.MainThreadDuration = time.Duration(union(main))
+	return
+}
+
+// cpuFrequencies averages the frequency of each CPU,
+// weighted by the time spent at each frequency.
+func (t *trace) cpuFrequencies() (freqs []CPUFrequency) {
+	byCPU := map[uint32][]frequency{}
+	for _, f := range t.frequencies {
+		byCPU[f.cpu] = append(byCPU[f.cpu], f)
+	}
+
+	for cpu, fs := range byCPU {
+		slices.SortStableFunc(fs, func(a, b frequency) int {
+			return cmp.Compare(a.ts, b.ts)
+		})
+
+		freq := CPUFrequency{CPU: cpu}
+		var weighted, total float64
+		for i, f := range fs {
+			next := t.end
+			if i+1 < len(fs) {
+				next = fs[i+1].ts
+			}
+			d := float64(max(next-f.ts, 0))
+			weighted += float64(f.khz) * d
+			total += d
+			freq.Max = max(freq.Max, f.khz)
+		}
+
+		if total > 0 {
+			freq.Average = uint64(weighted / total)
+		} else {
+			freq.Average = fs[len(fs)-1].khz
+		}
+		freqs = append(freqs, freq)
+	}
+
+	slices.SortFunc(freqs, func(a, b CPUFrequency) int {
+		return cmp.Compare(a.CPU, b.CPU)
+	})
+
+	return
+}
+
+// longest keeps the maxLongest
+// longest items.
+func longest[T any](items []T, dur func(T) time.Duration) []T {
+	slices.SortStableFunc(items, func(a, b T) int {
+		return cmp.Compare(dur(b), dur(a))
+	})
+	if len(items) > maxLongest {
+		items = items[:maxLongest]
+	}
+	return items
+}
+
+// union sums the time covered by
+// spans, counting overlaps once.
+func union(spans [][2]int64) (total int64) {
+	slices.SortFunc(spans, func(a, b [2]int64) int {
+		return cmp.Compare(a[0], b[0])
+	})
+
+	var end int64
+	for i, s := range spans {
+		if i == 0 || s[0] > end {
+			total += s[1] - s[0]
+			end = s[1]
+			continue
+		}
+		if s[1] > end {
+			total += s[1] - end
+			end = s[1]
+		}
+	}
+
+	return
+}
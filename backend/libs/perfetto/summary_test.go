@@ -0,0 +1,245 @@
+package perfetto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	appPID     = 100
+	gcTID      = 101
+	systemPID  = 200
+	msInNanos  = int64(time.Millisecond)
+	traceStart = 1_000 * msInNanos
+)
+
+// traceBuilder writes perfetto
+// traces for tests.
+type traceBuilder struct {
+	packets []byte
+	events  []byte
+}
+
+// message encodes a message of fields,
+// each an int, string or []byte.
+func message(kv ...any) (b []byte) {
+	for i := 0; i < len(kv); i += 2 {
+		num := protowire.Number(kv[i].(int))
+		switch v := kv[i+1].(type) {
+		case int:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		case int64:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return
+}
+
+func (b *traceBuilder) packet(kv ...any) {
+	b.packets = protowire.AppendTag(b.packets, fieldTracePacket, protowire.BytesType)
+	b.packets = protowire.AppendBytes(b.packets, message(kv...))
+}
+
+func (b *traceBuilder) ftrace(ms int64, tid int, kind int, body []byte) {
+	event := message(fieldFtraceTimestamp, traceStart+ms*msInNanos, fieldFtracePID, tid, kind, body)
+	b.events = append(b.events, message(fieldBundleEvent, event)...)
+}
+
+func (b *traceBuilder) begin(ms int64, tid int, name string) {
+	b.ftrace(ms, tid, fieldFtracePrint, message(fieldPrintBuf, fmt.Sprintf("B|%d|%s\n", appPID, name)))
+}
+
+func (b *traceBuilder) end(ms int64, tid int) {
+	b.ftrace(ms, tid, fieldFtracePrint, message(fieldPrintBuf, "E|"+fmt.Sprint(appPID)+"\n"))
+}
+
+func (b *traceBuilder) bytes() []byte {
+	b.packet(fieldPacketTimestamp, traceStart, fieldPacketFtraceEvents, b.events)
+	return b.packets
+}
+
+// startupTrace builds a trace of a cold launch with
+// a binder transaction, a GC, a janky frame & CPU
+// frequency changes.
+func startupTrace() []byte {
+	b := &traceBuilder{}
+	b.packet(fieldPacketTimestamp, traceStart, fieldPacketProcessTree, message(
+		fieldTreeProcess, message(fieldProcessPID, appPID, fieldProcessCmdline, "com.example.app\x00"),
+		fieldTreeProcess, message(fieldProcessPID, systemPID, fieldProcessCmdline, "system_server"),
+		fieldTreeThread, message(fieldThreadTID, gcTID, fieldThreadName, "HeapTaskDaemon", fieldThreadTGID, appPID),
+		fieldTreeThread, message(fieldThreadTID, appPID, fieldThreadName, "com.example.app", fieldThreadTGID, appPID),
+	))
+
+	b.ftrace(0, 0, fieldFtraceCPUFrequency, message(fieldCPUFrequencyState, 1_000_000, fieldCPUFrequencyCPU, 0))
+
+	b.begin(0, appPID, phaseBindApplication)
+	b.begin(10, appPID, "OpenDexFilesFromOat(/data/app/base.apk)")
+	b.end(30, appPID)
+
+	// main thread waits 20ms on a
+	// binder reply
+	b.ftrace(40, appPID, fieldFtraceBinderTransaction, message(fieldBinderDebugID, 1, fieldBinderToProc, systemPID))
+	b.ftrace(45, systemPID, fieldFtraceBinderTransaction, message(fieldBinderDebugID, 2, fieldBinderReply, 1))
+	b.ftrace(60, appPID, fieldFtraceBinderTransactionReceived, message(fieldBinderDebugID, 2))
+	b.end(80, appPID)
+
+	b.ftrace(50, 0, fieldFtraceCPUFrequency, message(fieldCPUFrequencyState, 2_000_000, fieldCPUFrequencyCPU, 0))
+
+	b.begin(90, appPID, phaseActivityStart)
+	b.begin(95, appPID, "inflate")
+	b.end(115, appPID)
+	b.end(120, appPID)
+
+	b.begin(100, gcTID, "concurrent copying GC")
+	b.end(110, gcTID)
+
+	b.begin(130, appPID, phaseActivityResume)
+	b.end(140, appPID)
+
+	b.begin(150, appPID, phaseFirstFrame)
+	b.end(190, appPID)
+
+	b.begin(200, appPID, phaseFirstFrame)
+	b.end(210, appPID)
+
+	return b.bytes()
+}
+
+func TestSummarize(t *testing.T) {
+	s, err := Summarize(startupTrace(), "com.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.ProcessName != "com.example.app" {
+		t.Errorf("Expected process %q, got %q", "com.example.app", s.ProcessName)
+	}
+	if s.Duration != 210*time.Millisecond {
+		t.Errorf("Expected duration %v, got %v", 210*time.Millisecond, s.Duration)
+	}
+
+	if s.Startup == nil {
+		t.Fatal("Expected startup, got nil")
+	}
+	if s.Startup.Duration != 190*time.Millisecond {
+		t.Errorf("Expected startup duration %v, got %v", 190*time.Millisecond, s.Startup.Duration)
+	}
+	expectedPhases := []Span{
+		{phaseBindApplication, 0, 80 * time.Millisecond},
+		{phaseActivityStart, 90 * time.Millisecond, 30 * time.Millisecond},
+		{phaseActivityResume, 130 * time.Millisecond, 10 * time.Millisecond},
+		{phaseFirstFrame, 150 * time.Millisecond, 40 * time.Millisecond},
+	}
+	if fmt.Sprint(s.Startup.Phases) != fmt.Sprint(expectedPhases) {
+		t.Errorf("Expected phases %v, got %v", expectedPhases, s.Startup.Phases)
+	}
+	expectedWork := []Work{
+		{WorkInflate, 20 * time.Millisecond},
+		{WorkDexLoading, 20 * time.Millisecond},
+	}
+	if fmt.Sprint(s.Startup.Work) != fmt.Sprint(expectedWork) {
+		t.Errorf("Expected work %v, got %v", expectedWork, s.Startup.Work)
+	}
+
+	if s.Jank.Slices != 3 {
+		t.Errorf("Expected 3 janky slices, got %d", s.Jank.Slices)
+	}
+	if s.Jank.Duration != 150*time.Millisecond {
+		t.Errorf("Expected jank of %v, got %v", 150*time.Millisecond, s.Jank.Duration)
+	}
+	if len(s.Jank.Longest) != 3 || s.Jank.Longest[0].Name != phaseBindApplication {
+		t.Errorf("Expected %q to be the longest janky slice, got %v", phaseBindApplication, s.Jank.Longest)
+	}
+
+	if s.Binder.Transactions != 1 || s.Binder.MainThreadTransactions != 1 {
+		t.Errorf("Expected 1 main thread transaction, got %+v", s.Binder)
+	}
+	if s.Binder.MainThreadDuration != 20*time.Millisecond {
+		t.Errorf("Expected main thread binder time %v, got %v", 20*time.Millisecond, s.Binder.MainThreadDuration)
+	}
+	if len(s.Binder.Longest) != 1 || s.Binder.Longest[0].Name != "system_server" || !s.Binder.Longest[0].MainThread {
+		t.Errorf("Expected main thread transaction to system_server, got %+v", s.Binder.Longest)
+	}
+
+	if s.GC.Collections != 1 || s.GC.Duration != 10*time.Millisecond || s.GC.MainThreadDuration != 0 {
+		t.Errorf("Expected 1 collection of %v, got %+v", 10*time.Millisecond, s.GC)
+	}
+
+	// 50ms at 1GHz, then 160ms at 2GHz
+	expectedFreq := CPUFrequency{CPU: 0, Average: (50*1_000_000 + 160*2_000_000) / 210, Max: 2_000_000}
+	if len(s.CPUFrequencies) != 1 || s.CPUFrequencies[0] != expectedFreq {
+		t.Errorf("Expected CPU frequency %+v, got %+v", expectedFreq, s.CPUFrequencies)
+	}
+}
+
+func TestSummarizeGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(startupTrace())
+	gz.Close()
+
+	s, err := Summarize(buf.Bytes(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// picks the process with the most slices
+	if s.ProcessName != "com.example.app" {
+		t.Errorf("Expected process %q, got %q", "com.example.app", s.ProcessName)
+	}
+}
+
+func TestSummarizeTrackEvents(t *testing.T) {
+	b := &traceBuilder{}
+	b.packet(fieldPacketTimestamp, traceStart, fieldPacketTrackDescriptor, message(
+		fieldTrackUUID, 7,
+		fieldTrackThread, message(fieldThreadDescriptorPID, appPID, fieldThreadDescriptorTID, appPID, fieldThreadDescriptorName, "main"),
+	))
+	b.packet(
+		fieldPacketTimestamp, traceStart,
+		fieldPacketSequenceID, 1,
+		fieldPacketSequenceFlags, seqIncrementalStateCleared,
+		fieldPacketInternedData, message(fieldInternedEventNames, message(fieldEventNameIID, 1, fieldEventNameName, "RecyclerView#onLayout")),
+		fieldPacketTrackEvent, message(fieldTrackEventType, trackEventSliceBegin, fieldTrackEventTrackUUID, 7, fieldTrackEventNameIID, 1),
+	)
+	b.packet(
+		fieldPacketTimestamp, traceStart+40*msInNanos,
+		fieldPacketSequenceID, 1,
+		fieldPacketTrackEvent, message(fieldTrackEventType, trackEventSliceEnd, fieldTrackEventTrackUUID, 7),
+	)
+
+	s, err := Summarize(b.packets, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Startup != nil {
+		t.Errorf("Expected no startup, got %+v", s.Startup)
+	}
+	if s.Jank.Slices != 1 || s.Jank.Longest[0].Name != "RecyclerView#onLayout" || s.Jank.Longest[0].Duration != 40*time.Millisecond {
+		t.Errorf("Expected a janky 40ms layout, got %+v", s.Jank)
+	}
+}
+
+func TestSummarizeInvalid(t *testing.T) {
+	if _, err := Summarize([]byte("not a trace"), ""); !errors.Is(err, ErrInvalidTrace) {
+		t.Errorf("Expected %v, got %v", ErrInvalidTrace, err)
+	}
+	if _, err := Summarize(nil, ""); !errors.Is(err, ErrInvalidTrace) {
+		t.Errorf("Expected %v for empty trace, got %v", ErrInvalidTrace, err)
+	}
+}
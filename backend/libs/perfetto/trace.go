@@ -0,0 +1,771 @@
+package perfetto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the messages read, others are
+// skipped. See perfetto's protos/perfetto/trace.
+const (
+	// Trace
+	fieldTracePacket = 1
+
+	// TracePacket
+	fieldPacketFtraceEvents      = 1
+	fieldPacketProcessTree       = 2
+	fieldPacketTimestamp         = 8
+	fieldPacketSequenceID        = 10
+	fieldPacketTrackEvent        = 11
+	fieldPacketInternedData      = 12
+	fieldPacketSequenceFlags     = 13
+	fieldPacketIncrementalClear  = 41
+	fieldPacketCompressedPackets = 50
+	fieldPacketTrackDescriptor   = 60
+
+	// FtraceEventBundle
+	fieldBundleEvent = 2
+
+	// FtraceEvent
+	fieldFtraceTimestamp                 = 1
+	fieldFtracePID                       = 2
+	fieldFtracePrint                     = 3
+	fieldFtraceCPUFrequency              = 11
+	fieldFtraceBinderTransaction         = 61
+	fieldFtraceBinderTransactionReceived = 62
+
+	// PrintFtraceEvent
+	fieldPrintBuf = 2
+
+	// CpuFrequencyFtraceEvent
+	fieldCPUFrequencyState = 1
+	fieldCPUFrequencyCPU   = 2
+
+	// BinderTransactionFtraceEvent
+	fieldBinderDebugID = 1
+	fieldBinderToProc  = 3
+	fieldBinderReply   = 5
+	fieldBinderFlags   = 7
+
+	// ProcessTree
+	fieldTreeProcess = 1
+	fieldTreeThread  = 2
+
+	// ProcessTree.Process
+	fieldProcessPID     = 1
+	fieldProcessCmdline = 3
+
+	// ProcessTree.Thread
+	fieldThreadTID  = 1
+	fieldThreadName = 2
+	fieldThreadTGID = 5
+
+	// TrackEvent
+	fieldTrackEventType      = 9
+	fieldTrackEventNameIID   = 10
+	fieldTrackEventTrackUUID = 11
+	fieldTrackEventName      = 23
+
+	// TrackDescriptor
+	fieldTrackUUID    = 1
+	fieldTrackProcess = 3
+	fieldTrackThread  = 4
+
+	// ProcessDescriptor
+	fieldProcessDescriptorPID  = 1
+	fieldProcessDescriptorName = 6
+
+	// ThreadDescriptor
+	fieldThreadDescriptorPID  = 1
+	fieldThreadDescriptorTID  = 2
+	fieldThreadDescriptorName = 5
+
+	// InternedData
+	fieldInternedEventNames = 2
+
+	// EventName
+	fieldEventNameIID  = 1
+	fieldEventNameName = 2
+)
+
+// Track event types.
+const (
+	trackEventSliceBegin = 1
+	trackEventSliceEnd   = 2
+)
+
+// seqIncrementalStateCleared flags packets
+// after which interned data is reset.
+const seqIncrementalStateCleared = 1
+
+// binderOneWay flags binder transactions
+// that don't wait for a reply.
+const binderOneWay = 0x01
+
+// ErrInvalidTrace is returned when the data
+// isn't a well formed perfetto trace.
+var ErrInvalidTrace = errors.New("invalid perfetto trace")
+
+// slice is a span of time a thread spent
+// on a traced section.
+type slice struct {
+	name  string
+	pid   int32
+	tid   int32
+	start int64
+	dur   int64
+	depth int
+}
+
+// transaction is a binder transaction sent by
+// a thread, waiting on its reply unless one
+// way.
+type transaction struct {
+	pid    int32
+	tid    int32
+	toPID  int32
+	start  int64
+	dur    int64
+	oneWay bool
+}
+
+// frequency is a change of a
+// CPU's frequency.
+type frequency struct {
+	cpu uint32
+	ts  int64
+	khz uint64
+}
+
+// track is the thread a
+// track event belongs to.
+type track struct {
+	pid int32
+	tid int32
+}
+
+// stackKey keys the open slices of a thread, for
+// atrace markers, or of a track, for track
+// events.
+type stackKey struct {
+	uuid uint64
+	tid  int32
+}
+
+// openSlice is a slice begun but
+// not yet ended.
+type openSlice struct {
+	name  string
+	start int64
+}
+
+// pendingTransaction is a binder transaction
+// awaiting its reply.
+type pendingTransaction struct {
+	index int
+	start int64
+}
+
+// trace is a parsed perfetto trace.
+type trace struct {
+	// start & end bound the
+	// trace's timestamps.
+	start, end int64
+
+	processes   map[int32]string
+	threads     map[int32]string
+	threadGroup map[int32]int32
+
+	slices       []slice
+	transactions []transaction
+	frequencies  []frequency
+
+	tracks map[uint64]track
+	// names are interned event names,
+	// by sequence & iid.
+	names map[[2]uint64]string
+	// stacks are the open slices
+	// of each thread or track.
+	stacks map[stackKey][]openSlice
+	// awaiting are the binder transactions
+	// awaiting a reply, by sending thread.
+	awaiting map[int32]pendingTransaction
+	// replies are the debug ids of
+	// binder replies.
+	replies map[uint64]bool
+}
+
+// field is a decoded protobuf field.
+type field struct {
+	num    protowire.Number
+	varint uint64
+	bytes  []byte
+}
+
+// fields calls fn with each field of
+// a protobuf message.
+func fields(b []byte, fn func(f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidTrace, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.varint, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.varint = uint64(v)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrInvalidTrace, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseTrace parses a perfetto trace, gzipped or not.
+func parseTrace(data []byte) (t *trace, err error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+		}
+	}
+
+	t = &trace{
+		processes:   map[int32]string{},
+		threads:     map[int32]string{},
+		threadGroup: map[int32]int32{},
+		tracks:      map[uint64]track{},
+		names:       map[[2]uint64]string{},
+		stacks:      map[stackKey][]openSlice{},
+		awaiting:    map[int32]pendingTransaction{},
+		replies:     map[uint64]bool{},
+	}
+
+	if err = t.parsePackets(data); err != nil {
+		return nil, err
+	}
+
+	if t.start == 0 && t.end == 0 {
+		return nil, fmt.Errorf("%w: no timestamped packets", ErrInvalidTrace)
+	}
+
+	t.closeSlices()
+	return
+}
+
+// parsePackets parses the packets of a trace.
+func (t *trace) parsePackets(data []byte) error {
+	return fields(data, func(f field) error {
+		if f.num != fieldTracePacket {
+			return nil
+		}
+		return t.parsePacket(f.bytes)
+	})
+}
+
+// parsePacket parses a trace packet.
+func (t *trace) parsePacket(b []byte) error {
+	var ts, seq uint64
+	var cleared bool
+	var bundles, trees, trackEvents, interned, descriptors, compressed [][]byte
+
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case fieldPacketTimestamp:
+			ts = f.varint
+		case fieldPacketSequenceID:
+			seq = f.varint
+		case fieldPacketSequenceFlags:
+			cleared = cleared || f.varint&seqIncrementalStateCleared != 0
+		case fieldPacketIncrementalClear:
+			cleared = cleared || f.varint != 0
+		case fieldPacketFtraceEvents:
+			bundles = append(bundles, f.bytes)
+		case fieldPacketProcessTree:
+			trees = append(trees, f.bytes)
+		case fieldPacketTrackEvent:
+			trackEvents = append(trackEvents, f.bytes)
+		case fieldPacketInternedData:
+			interned = append(interned, f.bytes)
+		case fieldPacketTrackDescriptor:
+			descriptors = append(descriptors, f.bytes)
+		case fieldPacketCompressedPackets:
+			compressed = append(compressed, f.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if cleared {
+		for key := range t.names {
+			if key[0] == seq {
+				delete(t.names, key)
+			}
+		}
+	}
+
+	for _, c := range compressed {
+		zr, err := zlib.NewReader(bytes.NewReader(c))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+		}
+		packets, err := io.ReadAll(zr)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTrace, err)
+		}
+		if err := t.parsePackets(packets); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range interned {
+		if err := t.parseInternedData(seq, d); err != nil {
+			return err
+		}
+	}
+	for _, d := range descriptors {
+		if err := t.parseTrackDescriptor(d); err != nil {
+			return err
+		}
+	}
+	for _, tree := range trees {
+		if err := t.parseProcessTree(tree); err != nil {
+			return err
+		}
+	}
+	for _, bundle := range bundles {
+		if err := t.parseFtraceBundle(bundle); err != nil {
+			return err
+		}
+	}
+	for _, e := range trackEvents {
+		t.observe(int64(ts))
+		if err := t.parseTrackEvent(seq, int64(ts), e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// observe widens the trace's
+// bounds to the timestamp.
+func (t *trace) observe(ts int64) {
+	if ts <= 0 {
+		return
+	}
+	if t.start == 0 || ts < t.start {
+		t.start = ts
+	}
+	if ts > t.end {
+		t.end = ts
+	}
+}
+
+// parseProcessTree parses the
+// processes & threads of a tree.
+func (t *trace) parseProcessTree(b []byte) error {
+	return fields(b, func(f field) error {
+		switch f.num {
+		case fieldTreeProcess:
+			var pid int32
+			var cmdline string
+			err := fields(f.bytes, func(pf field) error {
+				switch pf.num {
+				case fieldProcessPID:
+					pid = int32(pf.varint)
+				case fieldProcessCmdline:
+					if cmdline == "" {
+						cmdline = string(pf.bytes)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			t.processes[pid] = processName(cmdline)
+		case fieldTreeThread:
+			var tid, tgid int32
+			var name string
+			err := fields(f.bytes, func(tf field) error {
+				switch tf.num {
+				case fieldThreadTID:
+					tid = int32(tf.varint)
+				case fieldThreadTGID:
+					tgid = int32(tf.varint)
+				case fieldThreadName:
+					name = string(tf.bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				t.threads[tid] = name
+			}
+			if tgid != 0 {
+				t.threadGroup[tid] = tgid
+			}
+		}
+		return nil
+	})
+}
+
+// processName gets the name of a process from
+// its first command line argument, which
+// may be nul terminated.
+func processName(cmdline string) string {
+	if i := strings.IndexByte(cmdline, 0); i >= 0 {
+		cmdline = cmdline[:i]
+	}
+	return cmdline
+}
+
+// parseFtraceBundle parses the
+// ftrace events of a bundle.
+func (t *trace) parseFtraceBundle(b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num != fieldBundleEvent {
+			return nil
+		}
+		return t.parseFtraceEvent(f.bytes)
+	})
+}
+
+// parseFtraceEvent parses the ftrace events
+// summarized: atrace markers, CPU frequency
+// changes & binder transactions.
+func (t *trace) parseFtraceEvent(b []byte) error {
+	var ts int64
+	var tid int32
+	var kind protowire.Number
+	var body []byte
+
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case fieldFtraceTimestamp:
+			ts = int64(f.varint)
+		case fieldFtracePID:
+			tid = int32(f.varint)
+		case fieldFtracePrint, fieldFtraceCPUFrequency, fieldFtraceBinderTransaction, fieldFtraceBinderTransactionReceived:
+			kind = f.num
+			body = f.bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	t.observe(ts)
+
+	switch kind {
+	case fieldFtracePrint:
+		return fields(body, func(f field) error {
+			if f.num == fieldPrintBuf {
+				t.parseAtrace(ts, tid, string(f.bytes))
+			}
+			return nil
+		})
+	case fieldFtraceCPUFrequency:
+		freq := frequency{ts: ts}
+		err := fields(body, func(f field) error {
+			switch f.num {
+			case fieldCPUFrequencyState:
+				freq.khz = f.varint
+			case fieldCPUFrequencyCPU:
+				freq.cpu = uint32(f.varint)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		t.frequencies = append(t.frequencies, freq)
+	case fieldFtraceBinderTransaction:
+		var debugID uint64
+		var toPID int32
+		var reply bool
+		var flags uint64
+		err := fields(body, func(f field) error {
+			switch f.num {
+			case fieldBinderDebugID:
+				debugID = f.varint
+			case fieldBinderToProc:
+				toPID = int32(f.varint)
+			case fieldBinderReply:
+				reply = f.varint != 0
+			case fieldBinderFlags:
+				flags = f.varint
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if reply {
+			t.replies[debugID] = true
+			return nil
+		}
+
+		tx := transaction{
+			pid:    t.threadGroup[tid],
+			tid:    tid,
+			toPID:  toPID,
+			start:  ts,
+			oneWay: flags&binderOneWay != 0,
+		}
+		t.transactions = append(t.transactions, tx)
+		if !tx.oneWay {
+			t.awaiting[tid] = pendingTransaction{index: len(t.transactions) - 1, start: ts}
+		}
+	case fieldFtraceBinderTransactionReceived:
+		var debugID uint64
+		err := fields(body, func(f field) error {
+			if f.num == fieldBinderDebugID {
+				debugID = f.varint
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// the sender received
+		// its reply
+		if p, ok := t.awaiting[tid]; ok && t.replies[debugID] {
+			t.transactions[p.index].dur = ts - p.start
+			delete(t.awaiting, tid)
+		}
+	}
+
+	return nil
+}
+
+// parseAtrace parses an atrace marker written by
+// a thread, like "B|1234|inflate" or "E|1234".
+// Async & counter markers are ignored.
+func (t *trace) parseAtrace(ts int64, tid int32, buf string) {
+	buf = strings.TrimRight(buf, "\n\x00")
+	parts := strings.SplitN(buf, "|", 3)
+	if len(parts) == 0 || len(parts[0]) != 1 {
+		return
+	}
+
+	key := stackKey{tid: tid}
+	switch parts[0] {
+	case "B":
+		if len(parts) < 3 {
+			return
+		}
+		if pid, err := strconv.ParseInt(parts[1], 10, 32); err == nil {
+			if _, ok := t.threadGroup[tid]; !ok {
+				t.threadGroup[tid] = int32(pid)
+			}
+		}
+		t.stacks[key] = append(t.stacks[key], openSlice{name: parts[2], start: ts})
+	case "E":
+		t.endSlice(key, t.threadGroup[tid], tid, ts)
+	}
+}
+
+// endSlice ends the innermost open
+// slice of the thread or track.
+func (t *trace) endSlice(key stackKey, pid, tid int32, ts int64) {
+	stack := t.stacks[key]
+	if len(stack) == 0 {
+		return
+	}
+
+	open := stack[len(stack)-1]
+	t.stacks[key] = stack[:len(stack)-1]
+	t.slices = append(t.slices, slice{
+		name:  open.name,
+		pid:   pid,
+		tid:   tid,
+		start: open.start,
+		dur:   ts - open.start,
+		depth: len(stack) - 1,
+	})
+}
+
+// closeSlices ends the slices left
+// open at the end of the trace.
+func (t *trace) closeSlices() {
+	for key, stack := range t.stacks {
+		for len(stack) > 0 {
+			pid, tid := t.owner(key)
+			t.endSlice(key, pid, tid, t.end)
+			stack = t.stacks[key]
+		}
+	}
+}
+
+// owner gets the thread of the
+// key of an open slice stack.
+func (t *trace) owner(key stackKey) (pid, tid int32) {
+	if key.uuid == 0 {
+		return t.threadGroup[key.tid], key.tid
+	}
+	tr := t.tracks[key.uuid]
+	return tr.pid, tr.tid
+}
+
+// parseInternedData parses the event
+// names interned on a sequence.
+func (t *trace) parseInternedData(seq uint64, b []byte) error {
+	return fields(b, func(f field) error {
+		if f.num != fieldInternedEventNames {
+			return nil
+		}
+		var iid uint64
+		var name string
+		err := fields(f.bytes, func(nf field) error {
+			switch nf.num {
+			case fieldEventNameIID:
+				iid = nf.varint
+			case fieldEventNameName:
+				name = string(nf.bytes)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		t.names[[2]uint64{seq, iid}] = name
+		return nil
+	})
+}
+
+// parseTrackDescriptor parses the
+// thread or process of a track.
+func (t *trace) parseTrackDescriptor(b []byte) error {
+	var uuid uint64
+	var tr track
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case fieldTrackUUID:
+			uuid = f.varint
+		case fieldTrackProcess:
+			return fields(f.bytes, func(pf field) error {
+				switch pf.num {
+				case fieldProcessDescriptorPID:
+					tr.pid = int32(pf.varint)
+				case fieldProcessDescriptorName:
+					if _, ok := t.processes[tr.pid]; !ok && tr.pid != 0 {
+						t.processes[tr.pid] = string(pf.bytes)
+					}
+				}
+				return nil
+			})
+		case fieldTrackThread:
+			var name string
+			err := fields(f.bytes, func(tf field) error {
+				switch tf.num {
+				case fieldThreadDescriptorPID:
+					tr.pid = int32(tf.varint)
+				case fieldThreadDescriptorTID:
+					tr.tid = int32(tf.varint)
+				case fieldThreadDescriptorName:
+					name = string(tf.bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				t.threads[tr.tid] = name
+			}
+			if tr.tid != 0 && tr.pid != 0 {
+				t.threadGroup[tr.tid] = tr.pid
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if uuid != 0 {
+		t.tracks[uuid] = tr
+	}
+	return nil
+}
+
+// parseTrackEvent parses a slice begin or end
+// track event. Events of unknown tracks are
+// ignored.
+func (t *trace) parseTrackEvent(seq uint64, ts int64, b []byte) error {
+	var typ, uuid, iid uint64
+	var name string
+	err := fields(b, func(f field) error {
+		switch f.num {
+		case fieldTrackEventType:
+			typ = f.varint
+		case fieldTrackEventTrackUUID:
+			uuid = f.varint
+		case fieldTrackEventNameIID:
+			iid = f.varint
+		case fieldTrackEventName:
+			name = string(f.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tr, ok := t.tracks[uuid]
+	if !ok || tr.tid == 0 {
+		return nil
+	}
+
+	key := stackKey{uuid: uuid}
+	switch typ {
+	case trackEventSliceBegin:
+		if name == "" {
+			name = t.names[[2]uint64{seq, iid}]
+		}
+		t.stacks[key] = append(t.stacks[key], openSlice{name: name, start: ts})
+	case trackEventSliceEnd:
+		t.endSlice(key, tr.pid, tr.tid, ts)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	valkey "github.com/valkey-io/valkey-go"
+)
+
+// dropsTTL is how long monthly drop counters are kept.
+// Long enough for the usage page's last three months.
+const dropsTTL = 100 * 24 * time.Hour
+
+// Drops counts what an app had
+// dropped by rate limits.
+type Drops struct {
+	Batches uint64 `json:"batches"`
+	Events  uint64 `json:"events"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// dropsKey is the Valkey key of the
+// app's drop counters in the month.
+func dropsKey(appID uuid.UUID, year int, month time.Month) string {
+	return fmt.Sprintf("ingest:drops:{%s}:%04d-%02d", appID, year, month)
+}
+
+// RecordDrop counts the batch as dropped in the UTC month
+// of now. A nil client is a no-op.
+func RecordDrop(ctx context.Context, vk valkey.Client, appID uuid.UUID, batch Batch, now time.Time) error {
+	if vk == nil {
+		return nil
+	}
+
+	now = now.UTC()
+	key := dropsKey(appID, now.Year(), now.Month())
+
+	for _, resp := range vk.DoMulti(ctx,
+		vk.B().Hincrby().Key(key).Field("batches").Increment(1).Build(),
+		vk.B().Hincrby().Key(key).Field("events").Increment(batch.Events).Build(),
+		vk.B().Hincrby().Key(key).Field("bytes").Increment(batch.Bytes).Build(),
+		vk.B().Expire().Key(key).Seconds(int64(dropsTTL/time.Second)).Build(),
+	) {
+		if err := resp.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDrops fetches what the app had dropped in the UTC
+// month. A nil client reports no drops.
+func GetDrops(ctx context.Context, vk valkey.Client, appID uuid.UUID, year int, month time.Month) (drops Drops, err error) {
+	if vk == nil {
+		return
+	}
+
+	fields, err := vk.Do(ctx, vk.B().Hgetall().Key(dropsKey(appID, year, month)).Build()).AsStrMap()
+	if err != nil {
+		return
+	}
+
+	for name, dst := range map[string]*uint64{
+		"batches": &drops.Batches,
+		"events":  &drops.Events,
+		"bytes":   &drops.Bytes,
+	} {
+		v, ok := fields[name]
+		if !ok {
+			continue
+		}
+		if *dst, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return Drops{}, fmt.Errorf("invalid %s drop count %q: %w", name, v, err)
+		}
+	}
+
+	return
+}
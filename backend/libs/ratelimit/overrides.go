@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// Validate validates the overrides.
+func (o Overrides) Validate() error {
+	for name, v := range map[Limit]*int64{
+		LimitEventsPerSecond:             o.EventsPerSecond,
+		LimitInstallationEventsPerSecond: o.InstallationEventsPerSecond,
+	} {
+		if v != nil && (*v < 0 || *v > math.MaxInt32) {
+			return fmt.Errorf("`%s` must be between 0 and %d", name, math.MaxInt32)
+		}
+	}
+
+	if o.BytesPerMinute != nil && *o.BytesPerMinute < 0 {
+		return fmt.Errorf("`%s` must not be negative", LimitBytesPerMinute)
+	}
+
+	return nil
+}
+
+// GetOverrides fetches the app's overrides. Apps
+// without overrides get empty overrides.
+func GetOverrides(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (o Overrides, err error) {
+	stmt := sqlf.PostgreSQL.From("measure.app_ingest_limits").
+		Select("events_per_second").
+		Select("bytes_per_minute").
+		Select("installation_events_per_second").
+		Where("app_id = ?", appID)
+
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&o.EventsPerSecond, &o.BytesPerMinute, &o.InstallationEventsPerSecond)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Overrides{}, nil
+	}
+
+	return
+}
+
+// SaveOverrides replaces the app's overrides.
+func SaveOverrides(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, o Overrides) (err error) {
+	_, err = pg.Exec(ctx, `
+		insert into measure.app_ingest_limits (app_id, events_per_second, bytes_per_minute, installation_events_per_second, created_at, updated_at)
+		values ($1, $2, $3, $4, now(), now())
+		on conflict (app_id) do update set
+			events_per_second = excluded.events_per_second,
+			bytes_per_minute = excluded.bytes_per_minute,
+			installation_events_per_second = excluded.installation_events_per_second,
+			updated_at = now()`,
+		appID, o.EventsPerSecond, o.BytesPerMinute, o.InstallationEventsPerSecond)
+
+	return
+}
@@ -0,0 +1,273 @@
+// Package ratelimit throttles ingestion per app and per installation
+// using sliding window counters in Valkey, and counts the batches it
+// drops so teams can see them in their usage.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	valkey "github.com/valkey-io/valkey-go"
+)
+
+// Window is the length of the sliding window every
+// limit is counted over. Per second limits are
+// averaged over the window, so that SDKs sending
+// batches every few seconds aren't throttled by
+// short bursts.
+//
+// Counts are kept per fixed window. The sliding
+// window's count is the current window's count
+// plus the previous window's, weighted by how
+// much of it the sliding window still overlaps.
+// Bursts at window boundaries can't pass twice
+// the limit that way.
+const Window = time.Minute
+
+// Limit names a limit a batch can exceed.
+type Limit string
+
+const (
+	LimitEventsPerSecond             Limit = "events_per_second"
+	LimitBytesPerMinute              Limit = "bytes_per_minute"
+	LimitInstallationEventsPerSecond Limit = "installation_events_per_second"
+)
+
+// Limits are the rates an app may ingest at.
+// A zero limit is no limit.
+type Limits struct {
+	EventsPerSecond             int64
+	BytesPerMinute              int64
+	InstallationEventsPerSecond int64
+}
+
+// Overrides are an app's overrides of the service's
+// default limits. A nil override keeps the default.
+type Overrides struct {
+	EventsPerSecond             *int64 `json:"events_per_second"`
+	BytesPerMinute              *int64 `json:"bytes_per_minute"`
+	InstallationEventsPerSecond *int64 `json:"installation_events_per_second"`
+}
+
+// Apply returns the limits with
+// the overrides applied.
+func (l Limits) Apply(o Overrides) Limits {
+	if o.EventsPerSecond != nil {
+		l.EventsPerSecond = *o.EventsPerSecond
+	}
+	if o.BytesPerMinute != nil {
+		l.BytesPerMinute = *o.BytesPerMinute
+	}
+	if o.InstallationEventsPerSecond != nil {
+		l.InstallationEventsPerSecond = *o.InstallationEventsPerSecond
+	}
+	return l
+}
+
+// Unlimited reports whether no limit is set.
+func (l Limits) Unlimited() bool {
+	return l.EventsPerSecond == 0 && l.BytesPerMinute == 0 && l.InstallationEventsPerSecond == 0
+}
+
+// Batch is what an ingest batch counts
+// against the limits.
+type Batch struct {
+	// Events is the number of
+	// events and spans.
+	Events int64
+	// Bytes is the size of the payload.
+	Bytes int64
+	// Installations maps each installation to
+	// its number of events and spans.
+	Installations map[uuid.UUID]int64
+}
+
+// Decision is the outcome of checking
+// a batch against the limits.
+type Decision struct {
+	Allowed bool
+	// Limit is the limit the batch
+	// exceeded, when not allowed.
+	Limit Limit
+	// RetryAfter is how long to wait for the
+	// window to make room for the batch, when
+	// not allowed.
+	RetryAfter time.Duration
+}
+
+// checkScript counts a batch against each counter only when
+// it fits all of them, so rejected batches don't use up the
+// window. A batch larger than a limit fits an empty window,
+// otherwise it could never be ingested. Returns the 1-based
+// index of the first exceeded counter with its current and
+// previous window counts, or 0.
+//
+// KEYS[2i-1], KEYS[2i] are the current and previous window
+// keys of counter i. ARGV[1] is the ttl in seconds, ARGV[2]
+// the weight of the previous window and ARGV[2i+1],
+// ARGV[2i+2] the amount and limit of counter i.
+var checkScript = valkey.NewLuaScript(`
+local ttl = tonumber(ARGV[1])
+local weight = tonumber(ARGV[2])
+local n = #KEYS / 2
+for i = 1, n do
+	local amount = tonumber(ARGV[2 * i + 1])
+	local limit = tonumber(ARGV[2 * i + 2])
+	local current = tonumber(redis.call('GET', KEYS[2 * i - 1]) or '0')
+	local previous = tonumber(redis.call('GET', KEYS[2 * i]) or '0')
+	local used = current + math.floor(previous * weight)
+	if used > 0 and used + amount > limit then
+		return {i, current, previous}
+	end
+end
+for i = 1, n do
+	redis.call('INCRBY', KEYS[2 * i - 1], ARGV[2 * i + 1])
+	redis.call('EXPIRE', KEYS[2 * i - 1], ttl)
+end
+return {0}
+`)
+
+// counter is one limit a batch is counted against.
+type counter struct {
+	key      string
+	previous string
+	limit    Limit
+	amount   int64
+	max      int64
+}
+
+// windowStart is the start of the window at now.
+func windowStart(now time.Time) time.Time {
+	return now.Truncate(Window)
+}
+
+// previousWeight is the share of the previous window the
+// sliding window ending at now still overlaps.
+func previousWeight(now time.Time) float64 {
+	return 1 - float64(now.Sub(windowStart(now)))/float64(Window)
+}
+
+// retryAfter is how long until the sliding window has room for
+// the counter's amount, given its current and previous window
+// counts at now. Rounded up to whole seconds for the Retry-After
+// header.
+func retryAfter(now time.Time, c counter, current, previous int64) time.Duration {
+	start := windowStart(now)
+
+	// what the window may hold for the batch to fit,
+	// a batch larger than the limit needs it empty
+	room := max(c.max-c.amount, 0)
+
+	var at time.Time
+	switch {
+	case current <= room && previous > 0:
+		// the previous window slides out
+		// over the current one
+		share := 1 - float64(room-current)/float64(previous)
+		at = start.Add(time.Duration(share * float64(Window)))
+	case current > 0:
+		// the current window has to slide out
+		// over the next one
+		share := 1 - float64(room)/float64(current)
+		at = start.Add(Window).Add(time.Duration(share * float64(Window)))
+	default:
+		at = now
+	}
+
+	d := at.Sub(now)
+	return max((d + time.Second - 1).Truncate(time.Second), time.Second)
+}
+
+// counterKey is the Valkey key of an app's counter in the fixed window
+// starting at start. The app id is brace-wrapped so all of an app's
+// counters hash to one cluster slot, as the check script requires.
+func counterKey(appID uuid.UUID, name string, start time.Time) string {
+	return fmt.Sprintf("ingest:ratelimit:{%s}:%s:%d", appID, name, start.Unix())
+}
+
+// counters lists the counters the batch is
+// counted against under the limits.
+func counters(appID uuid.UUID, limits Limits, batch Batch, now time.Time) (cs []counter) {
+	start := windowStart(now)
+	previous := start.Add(-Window)
+	seconds := int64(Window / time.Second)
+
+	if limits.EventsPerSecond > 0 {
+		cs = append(cs, counter{
+			key:      counterKey(appID, "events", start),
+			previous: counterKey(appID, "events", previous),
+			limit:    LimitEventsPerSecond,
+			amount:   batch.Events,
+			max:      limits.EventsPerSecond * seconds,
+		})
+	}
+
+	if limits.BytesPerMinute > 0 {
+		cs = append(cs, counter{
+			key:      counterKey(appID, "bytes", start),
+			previous: counterKey(appID, "bytes", previous),
+			limit:    LimitBytesPerMinute,
+			amount:   batch.Bytes,
+			max:      limits.BytesPerMinute * int64(Window/time.Minute),
+		})
+	}
+
+	if limits.InstallationEventsPerSecond > 0 {
+		for id, events := range batch.Installations {
+			cs = append(cs, counter{
+				key:      counterKey(appID, "installation:"+id.String(), start),
+				previous: counterKey(appID, "installation:"+id.String(), previous),
+				limit:    LimitInstallationEventsPerSecond,
+				amount:   events,
+				max:      limits.InstallationEventsPerSecond * seconds,
+			})
+		}
+	}
+
+	return
+}
+
+// Check counts the batch against the app's limits at now and
+// decides whether it may be ingested. Rejected batches are not
+// counted. A nil client allows every batch.
+func Check(ctx context.Context, vk valkey.Client, appID uuid.UUID, limits Limits, batch Batch, now time.Time) (Decision, error) {
+	cs := counters(appID, limits, batch, now)
+	if vk == nil || len(cs) == 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	keys := make([]string, 0, 2*len(cs))
+	args := make([]string, 0, 2+2*len(cs))
+	args = append(args,
+		strconv.Itoa(int(2*Window/time.Second)),
+		strconv.FormatFloat(previousWeight(now), 'f', -1, 64),
+	)
+
+	for _, c := range cs {
+		keys = append(keys, c.key, c.previous)
+		args = append(args, strconv.FormatInt(c.amount, 10), strconv.FormatInt(c.max, 10))
+	}
+
+	result, err := checkScript.Exec(ctx, vk, keys, args).AsIntSlice()
+	if err != nil {
+		return Decision{}, err
+	}
+
+	if len(result) == 1 && result[0] == 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	if len(result) != 3 || result[0] < 1 || int(result[0]) > len(cs) {
+		return Decision{}, fmt.Errorf("rate limit check returned unknown result %v", result)
+	}
+
+	c := cs[result[0]-1]
+
+	return Decision{
+		Limit:      c.limit,
+		RetryAfter: retryAfter(now, c, result[1], result[2]),
+	}, nil
+}
@@ -0,0 +1,136 @@
+//go:build integration
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"backend/testinfra"
+
+	"github.com/google/uuid"
+)
+
+// TestRateLimitIntegration exercises the check script and the
+// drop counters against a real Valkey container.
+func TestRateLimitIntegration(t *testing.T) {
+	ctx := context.Background()
+	vk, cleanup := testinfra.SetupValkey(ctx)
+	defer cleanup()
+
+	now := time.Date(2026, 10, 17, 9, 0, 30, 0, time.UTC)
+
+	t.Run("batches are admitted until the window is used up", func(t *testing.T) {
+		appID := uuid.New()
+		limits := Limits{EventsPerSecond: 1}
+
+		d, err := Check(ctx, vk, appID, limits, Batch{Events: 50}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Fatal("first batch rejected, want allowed")
+		}
+
+		d, err = Check(ctx, vk, appID, limits, Batch{Events: 20}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			t.Fatal("batch over the window's 60 events allowed, want rejected")
+		}
+		if d.Limit != LimitEventsPerSecond {
+			t.Errorf("Limit = %q, want %q", d.Limit, LimitEventsPerSecond)
+		}
+		if d.RetryAfter != 42*time.Second {
+			t.Errorf("RetryAfter = %s, want 42s", d.RetryAfter)
+		}
+
+		// the rejected batch must not have used up the window
+		d, err = Check(ctx, vk, appID, limits, Batch{Events: 10}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Error("batch fitting the window rejected, want allowed")
+		}
+
+		// half of the full window before
+		// slides over the next one
+		d, err = Check(ctx, vk, appID, limits, Batch{Events: 31}, now.Add(Window))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed {
+			t.Error("batch over the sliding window's room allowed, want rejected")
+		}
+
+		d, err = Check(ctx, vk, appID, limits, Batch{Events: 30}, now.Add(Window))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Error("batch fitting the sliding window rejected, want allowed")
+		}
+	})
+
+	t.Run("a batch larger than the limit fits an empty window", func(t *testing.T) {
+		d, err := Check(ctx, vk, uuid.New(), Limits{BytesPerMinute: 100}, Batch{Bytes: 1000}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed {
+			t.Error("oversized batch in an empty window rejected, want allowed")
+		}
+	})
+
+	t.Run("installations are limited on their own", func(t *testing.T) {
+		appID := uuid.New()
+		noisy := uuid.New()
+		quiet := uuid.New()
+		limits := Limits{InstallationEventsPerSecond: 1}
+
+		if d, _ := Check(ctx, vk, appID, limits, Batch{Events: 60, Installations: map[uuid.UUID]int64{noisy: 60}}, now); !d.Allowed {
+			t.Fatal("noisy installation's first batch rejected, want allowed")
+		}
+
+		d, err := Check(ctx, vk, appID, limits, Batch{Events: 1, Installations: map[uuid.UUID]int64{noisy: 1}}, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Allowed || d.Limit != LimitInstallationEventsPerSecond {
+			t.Errorf("noisy installation decision = %+v, want rejected by %q", d, LimitInstallationEventsPerSecond)
+		}
+
+		if d, _ := Check(ctx, vk, appID, limits, Batch{Events: 1, Installations: map[uuid.UUID]int64{quiet: 1}}, now); !d.Allowed {
+			t.Error("quiet installation rejected, want allowed")
+		}
+	})
+
+	t.Run("drops are counted per month", func(t *testing.T) {
+		appID := uuid.New()
+
+		for range 2 {
+			if err := RecordDrop(ctx, vk, appID, Batch{Events: 10, Bytes: 512}, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		drops, err := GetDrops(ctx, vk, appID, now.Year(), now.Month())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := (Drops{Batches: 2, Events: 20, Bytes: 1024}); drops != want {
+			t.Errorf("drops = %+v, want %+v", drops, want)
+		}
+
+		drops, err = GetDrops(ctx, vk, appID, now.Year(), now.Month()-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if drops != (Drops{}) {
+			t.Errorf("previous month drops = %+v, want none", drops)
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestCounterKey locks the counter key format, whose
+// brace-wrapped app id the check script relies on.
+func TestCounterKey(t *testing.T) {
+	appID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	start := time.Unix(1760000040, 0)
+
+	got := counterKey(appID, "events", start)
+	want := "ingest:ratelimit:{11111111-1111-1111-1111-111111111111}:events:1760000040"
+	if got != want {
+		t.Errorf("counterKey = %q, want %q", got, want)
+	}
+}
+
+func TestPreviousWeight(t *testing.T) {
+	for _, tc := range []struct {
+		now  time.Time
+		want float64
+	}{
+		{time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC), 1},
+		{time.Date(2026, 10, 17, 9, 0, 15, 0, time.UTC), 0.75},
+		{time.Date(2026, 10, 17, 9, 0, 45, 0, time.UTC), 0.25},
+	} {
+		if got := previousWeight(tc.now); got != tc.want {
+			t.Errorf("previousWeight(%s) = %v, want %v", tc.now.Format(time.StampMilli), got, tc.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	at := func(sec, msec int) time.Time {
+		return time.Date(2026, 10, 17, 9, 0, sec, msec*1_000_000, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		name              string
+		now               time.Time
+		amount            int64
+		current, previous int64
+		want              time.Duration
+	}{
+		{"previous window slides out", at(15, 0), 30, 0, 60, 15 * time.Second},
+		{"current window slides out", at(30, 0), 20, 50, 0, 42 * time.Second},
+		{"rounded up", at(59, 900), 20, 50, 0, 13 * time.Second},
+		{"oversized batch waits for an empty window", at(30, 0), 100, 5, 0, 90 * time.Second},
+		{"at least a second", at(29, 900), 30, 0, 60, time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := counter{amount: tc.amount, max: 60}
+			if got := retryAfter(tc.now, c, tc.current, tc.previous); got != tc.want {
+				t.Errorf("retryAfter = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLimitsApply(t *testing.T) {
+	zero := int64(0)
+	bytes := int64(1 << 20)
+
+	defaults := Limits{EventsPerSecond: 100, BytesPerMinute: 1 << 30, InstallationEventsPerSecond: 10}
+	got := defaults.Apply(Overrides{EventsPerSecond: &zero, BytesPerMinute: &bytes})
+
+	want := Limits{EventsPerSecond: 0, BytesPerMinute: 1 << 20, InstallationEventsPerSecond: 10}
+	if got != want {
+		t.Errorf("Apply = %+v, want %+v", got, want)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	appID := uuid.New()
+	installationID := uuid.New()
+	now := time.Date(2026, 10, 17, 9, 0, 30, 0, time.UTC)
+
+	batch := Batch{
+		Events:        20,
+		Bytes:         4096,
+		Installations: map[uuid.UUID]int64{installationID: 20},
+	}
+
+	t.Run("unset limits have no counters", func(t *testing.T) {
+		if cs := counters(appID, Limits{}, batch, now); len(cs) != 0 {
+			t.Errorf("counters = %v, want none", cs)
+		}
+	})
+
+	t.Run("per second limits are scaled to the window", func(t *testing.T) {
+		cs := counters(appID, Limits{EventsPerSecond: 5, BytesPerMinute: 1024, InstallationEventsPerSecond: 1}, batch, now)
+		if len(cs) != 3 {
+			t.Fatalf("counters = %d, want 3", len(cs))
+		}
+
+		want := []struct {
+			limit  Limit
+			amount int64
+			max    int64
+		}{
+			{LimitEventsPerSecond, 20, 300},
+			{LimitBytesPerMinute, 4096, 1024},
+			{LimitInstallationEventsPerSecond, 20, 60},
+		}
+
+		for i, w := range want {
+			if cs[i].limit != w.limit || cs[i].amount != w.amount || cs[i].max != w.max {
+				t.Errorf("counter %d = %+v, want %+v", i, cs[i], w)
+			}
+		}
+	})
+
+	t.Run("counters slide over the previous window", func(t *testing.T) {
+		cs := counters(appID, Limits{EventsPerSecond: 5}, batch, now)
+		if len(cs) != 1 {
+			t.Fatalf("counters = %d, want 1", len(cs))
+		}
+
+		start := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+		if want := counterKey(appID, "events", start); cs[0].key != want {
+			t.Errorf("key = %q, want %q", cs[0].key, want)
+		}
+		if want := counterKey(appID, "events", start.Add(-Window)); cs[0].previous != want {
+			t.Errorf("previous = %q, want %q", cs[0].previous, want)
+		}
+	})
+}
+
+func TestCheckNilClient(t *testing.T) {
+	d, err := Check(context.Background(), nil, uuid.New(), Limits{EventsPerSecond: 1}, Batch{Events: 1000}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Allowed {
+		t.Error("Allowed = false without a client, want true")
+	}
+}
+
+func TestOverridesValidate(t *testing.T) {
+	negative := int64(-1)
+	huge := int64(1 << 40)
+	ok := int64(100)
+
+	for _, tc := range []struct {
+		name  string
+		o     Overrides
+		valid bool
+	}{
+		{"empty", Overrides{}, true},
+		{"set", Overrides{EventsPerSecond: &ok, BytesPerMinute: &huge, InstallationEventsPerSecond: &ok}, true},
+		{"negative events", Overrides{EventsPerSecond: &negative}, false},
+		{"negative bytes", Overrides{BytesPerMinute: &negative}, false},
+		{"too many installation events", Overrides{InstallationEventsPerSecond: &huge}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.o.Validate()
+			if tc.valid && err != nil {
+				t.Errorf("want valid, got %v", err)
+			}
+			if !tc.valid && err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
+
+// TestDropsKey locks the drop counter key format, which
+// outlives deploys for as long as the counters are kept.
+func TestDropsKey(t *testing.T) {
+	appID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+
+	got := dropsKey(appID, 2026, time.March)
+	want := "ingest:drops:{11111111-1111-1111-1111-111111111111}:2026-03"
+	if got != want {
+		t.Errorf("dropsKey = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package release
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/config"
+	"backend/libs/event"
+	"backend/libs/group"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// versionExpr matches the app version of the app_metrics and
+// error group tables. Tuple elements are matched one by one, a
+// whole-tuple comparison crashes on ClickHouse >= 26.2 when a set
+// skip index exists on a tuple element subcolumn.
+const versionExpr = "app_version.1 = ? and app_version.2 = ?"
+
+// GetHealth computes the health of a release and, in the same pass,
+// of every other release of the app combined, over [from, to].
+// Size is left out, see GetBuildSize.
+func GetHealth(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, v Version, from, to time.Time) (target, others Health, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	stmt := sqlf.From(config.AppMetricsTable)
+
+	// each metric is selected once for the release
+	// and once for the others
+	pair := func(expr string) {
+		stmt.Select(fmt.Sprintf(expr, versionExpr), v.Name, v.Code)
+		stmt.Select(fmt.Sprintf(expr, "not ("+versionExpr+")"), v.Name, v.Code)
+	}
+
+	pair("uniqMergeIf(unique_sessions, %s)")
+	pair("uniqMergeIf(crash_sessions, %s)")
+	pair("uniqMergeIf(perceived_crash_sessions, %s)")
+	pair("uniqMergeIf(anr_sessions, %s)")
+	pair("uniqMergeIf(perceived_anr_sessions, %s)")
+	pair("quantileMergeIf(0.95)(cold_launch_p95, %s)")
+	pair("quantileMergeIf(0.95)(warm_launch_p95, %s)")
+	pair("quantileMergeIf(0.95)(hot_launch_p95, %s)")
+
+	stmt.
+		Select("uniqMerge(unique_sessions)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= ? and timestamp <= ?", from, to)
+
+	defer stmt.Close()
+
+	var (
+		sessions, otherSessions                         uint64
+		crashes, otherCrashes                           uint64
+		perceivedCrashes, otherPerceivedCrashes         uint64
+		anrs, otherANRs                                 uint64
+		perceivedANRs, otherPerceivedANRs               uint64
+		cold, otherCold, warm, otherWarm, hot, otherHot float64
+		allSessions                                     uint64
+	)
+
+	if err = rch.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&sessions, &otherSessions,
+		&crashes, &otherCrashes,
+		&perceivedCrashes, &otherPerceivedCrashes,
+		&anrs, &otherANRs,
+		&perceivedANRs, &otherPerceivedANRs,
+		&cold, &otherCold,
+		&warm, &otherWarm,
+		&hot, &otherHot,
+		&allSessions,
+	); err != nil {
+		return
+	}
+
+	target = Health{
+		Version:                    v,
+		Sessions:                   sessions,
+		Adoption:                   percent(sessions, allSessions),
+		CrashFreeSessions:          freePercent(crashes, sessions),
+		PerceivedCrashFreeSessions: freePercent(perceivedCrashes, sessions),
+		ANRFreeSessions:            freePercent(anrs, sessions),
+		PerceivedANRFreeSessions:   freePercent(perceivedANRs, sessions),
+		ColdLaunchP95:              quantile(cold),
+		WarmLaunchP95:              quantile(warm),
+		HotLaunchP95:               quantile(hot),
+	}
+
+	others = Health{
+		Sessions:                   otherSessions,
+		Adoption:                   percent(otherSessions, allSessions),
+		CrashFreeSessions:          freePercent(otherCrashes, otherSessions),
+		PerceivedCrashFreeSessions: freePercent(otherPerceivedCrashes, otherSessions),
+		ANRFreeSessions:            freePercent(otherANRs, otherSessions),
+		PerceivedANRFreeSessions:   freePercent(otherPerceivedANRs, otherSessions),
+		ColdLaunchP95:              quantile(otherCold),
+		WarmLaunchP95:              quantile(otherWarm),
+		HotLaunchP95:               quantile(otherHot),
+	}
+
+	return
+}
+
+// GetBuildSize fetches the build size of a release in
+// bytes, or nil when no build size was uploaded for it.
+// The largest size is picked across build types.
+func GetBuildSize(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID, v Version) (size *float64, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("build_sizes").
+		Select("max(build_size)::float8").
+		Where("app_id = ?", appID).
+		Where("version_name = ?", v.Name).
+		Where("version_code = ?", v.Code)
+
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&size)
+	return
+}
+
+// GetNewErrorGroups fetches the fatal exception and ANR groups
+// that occurred in the target release over [from, to] but never
+// occurred in the base release, most frequent first. Contribution
+// is the percent of occurrences among the new groups.
+func GetNewErrorGroups(ctx context.Context, rch driver.Conn, teamID, appID uuid.UUID, base, target Version, from, to time.Time, limit int) (groups []group.ErrorGroup, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	branch := func(table, sourceType, isCustomExpr string) *sqlf.Stmt {
+		return sqlf.
+			From(table+" final").
+			Select("app_id").
+			Select("id").
+			Select("argMax(type, timestamp) as type").
+			Select("'"+sourceType+"' as source_type").
+			Select(isCustomExpr+" as is_custom").
+			Select("argMax(message, timestamp) as message").
+			Select("argMax(method_name, timestamp) as method_name").
+			Select("argMax(file_name, timestamp) as file_name").
+			Select("argMax(line_number, timestamp) as line_number").
+			Select("max(timestamp) as last_occurrence").
+			Select("sumMerge(count) as event_count").
+			Where("team_id = toUUID(?)", teamID).
+			Where("app_id = toUUID(?)", appID).
+			Where(versionExpr, target.Name, target.Code).
+			Where("timestamp >= toDateTime64(?, 3, 'UTC')", from).
+			Where("timestamp <= toDateTime64(?, 3, 'UTC')", to).
+			Where("id not in (select id from "+table+" where team_id = toUUID(?) and app_id = toUUID(?) and "+versionExpr+")", teamID, appID, base.Name, base.Code).
+			GroupBy("app_id").
+			GroupBy("id")
+	}
+
+	anrs := branch("anr_groups", "anr", "false")
+	exceptions := branch("fatal_exception_groups", "exception", "argMax(is_custom, timestamp)")
+	defer anrs.Close()
+	defer exceptions.Close()
+
+	union := sqlf.New(anrs.String()+" UNION ALL "+exceptions.String(), append(anrs.Args(), exceptions.Args()...)...)
+
+	stmt := sqlf.
+		With("groups", union).
+		Select("app_id").
+		Select("id").
+		Select("type").
+		Select("source_type").
+		Select("is_custom").
+		Select("message").
+		Select("method_name").
+		Select("file_name").
+		Select("line_number").
+		Select("last_occurrence").
+		Select("event_count").
+		Select("round((event_count * 100.0) / sum(event_count) over (), 2) as contribution").
+		From("groups").
+		OrderBy("event_count desc, last_occurrence desc, id")
+
+	if limit > 0 {
+		stmt.Limit(uint64(limit))
+	}
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		g := group.ErrorGroup{Severity: event.SeverityFatal}
+		if err = rows.Scan(
+			&g.AppID,
+			&g.ID,
+			&g.Type,
+			&g.ErrorType,
+			&g.IsCustom,
+			&g.Message,
+			&g.MethodName,
+			&g.FileName,
+			&g.LineNumber,
+			&g.UpdatedAt,
+			&g.Count,
+			&g.Percentage,
+		); err != nil {
+			return
+		}
+		groups = append(groups, g)
+	}
+
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,258 @@
+// Package release compares the health of app releases. A release is
+// identified by its version name and version code, and its health is
+// computed from the aggregated app metrics over a time range.
+package release
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"backend/libs/numeric"
+)
+
+// minLaunchIncreaseMs is the minimum p95 launch time
+// increase in milliseconds for a launch regression, so
+// small absolute changes on fast launches don't count.
+const minLaunchIncreaseMs = 100
+
+// Version identifies a release by its version name
+// and version code.
+type Version struct {
+	Name string `json:"version"`
+	Code string `json:"version_code"`
+}
+
+// String formats the version like "1.2.0 (120)".
+func (v Version) String() string {
+	return fmt.Sprintf("%s (%s)", v.Name, v.Code)
+}
+
+// Validate validates the version.
+func (v Version) Validate() error {
+	if v.Name == "" || v.Code == "" {
+		return fmt.Errorf("version and version code are required")
+	}
+	return nil
+}
+
+// Health is the health of a release over a time range.
+// Metrics without any data are nil.
+type Health struct {
+	Version Version `json:"version"`
+
+	// Sessions is the number of sessions
+	// of the release.
+	Sessions uint64 `json:"sessions"`
+
+	// Adoption is the percent of all sessions
+	// of the app that belong to the release.
+	Adoption *float64 `json:"adoption"`
+
+	CrashFreeSessions          *float64 `json:"crash_free_sessions"`
+	PerceivedCrashFreeSessions *float64 `json:"perceived_crash_free_sessions"`
+	ANRFreeSessions            *float64 `json:"anr_free_sessions"`
+	PerceivedANRFreeSessions   *float64 `json:"perceived_anr_free_sessions"`
+
+	// Launch times are p95 durations
+	// in milliseconds.
+	ColdLaunchP95 *float64 `json:"cold_launch_p95"`
+	WarmLaunchP95 *float64 `json:"warm_launch_p95"`
+	HotLaunchP95  *float64 `json:"hot_launch_p95"`
+
+	// Size is the build size in bytes.
+	Size *float64 `json:"size"`
+}
+
+// ClearANR clears the ANR metrics, for apps
+// whose platform doesn't report ANRs.
+func (h *Health) ClearANR() {
+	h.ANRFreeSessions = nil
+	h.PerceivedANRFreeSessions = nil
+}
+
+// Diff is the change of each metric from a base release
+// to a target release. A change is nil when either
+// release has no data for the metric.
+type Diff struct {
+	Adoption                   *float64 `json:"adoption"`
+	CrashFreeSessions          *float64 `json:"crash_free_sessions"`
+	PerceivedCrashFreeSessions *float64 `json:"perceived_crash_free_sessions"`
+	ANRFreeSessions            *float64 `json:"anr_free_sessions"`
+	PerceivedANRFreeSessions   *float64 `json:"perceived_anr_free_sessions"`
+	ColdLaunchP95              *float64 `json:"cold_launch_p95"`
+	WarmLaunchP95              *float64 `json:"warm_launch_p95"`
+	HotLaunchP95               *float64 `json:"hot_launch_p95"`
+	Size                       *float64 `json:"size"`
+}
+
+// Compare computes the change of each metric
+// from base to target.
+func Compare(base, target Health) Diff {
+	return Diff{
+		Adoption:                   delta(base.Adoption, target.Adoption),
+		CrashFreeSessions:          delta(base.CrashFreeSessions, target.CrashFreeSessions),
+		PerceivedCrashFreeSessions: delta(base.PerceivedCrashFreeSessions, target.PerceivedCrashFreeSessions),
+		ANRFreeSessions:            delta(base.ANRFreeSessions, target.ANRFreeSessions),
+		PerceivedANRFreeSessions:   delta(base.PerceivedANRFreeSessions, target.PerceivedANRFreeSessions),
+		ColdLaunchP95:              delta(base.ColdLaunchP95, target.ColdLaunchP95),
+		WarmLaunchP95:              delta(base.WarmLaunchP95, target.WarmLaunchP95),
+		HotLaunchP95:               delta(base.HotLaunchP95, target.HotLaunchP95),
+		Size:                       delta(base.Size, target.Size),
+	}
+}
+
+// Metric is a release health metric checked
+// for regressions.
+type Metric string
+
+const (
+	MetricCrashFree  Metric = "crash_free_sessions"
+	MetricANRFree    Metric = "anr_free_sessions"
+	MetricColdLaunch Metric = "cold_launch_p95"
+	MetricWarmLaunch Metric = "warm_launch_p95"
+	MetricHotLaunch  Metric = "hot_launch_p95"
+)
+
+// IsLaunch reports whether the metric is
+// a launch time.
+func (m Metric) IsLaunch() bool {
+	return m == MetricColdLaunch || m == MetricWarmLaunch || m == MetricHotLaunch
+}
+
+// Thresholds tune regression detection.
+type Thresholds struct {
+	// CrashFreeDrop is the drop in percentage points of
+	// crash free sessions below the base.
+	CrashFreeDrop float64
+	// ANRFreeDrop is the drop in percentage points of
+	// ANR free sessions below the base.
+	ANRFreeDrop float64
+	// LaunchIncrease is the percent increase of a p95
+	// launch time over the base.
+	LaunchIncrease float64
+	// MinSessions is the minimum number of sessions of
+	// both releases for them to be compared.
+	MinSessions uint64
+}
+
+// Regression is a metric of the target release
+// that regressed from the base release.
+type Regression struct {
+	Metric Metric
+	Base   float64
+	Target float64
+}
+
+// Describe describes the regression in plain text,
+// like "crash free sessions dropped from 99.50% to 97.20%".
+func (r Regression) Describe() string {
+	if r.Metric.IsLaunch() {
+		name := map[Metric]string{
+			MetricColdLaunch: "cold",
+			MetricWarmLaunch: "warm",
+			MetricHotLaunch:  "hot",
+		}[r.Metric]
+		return fmt.Sprintf("p95 %s launch rose from %.0f ms to %.0f ms", name, r.Base, r.Target)
+	}
+
+	name := "crash free sessions"
+	if r.Metric == MetricANRFree {
+		name = "ANR free sessions"
+	}
+	return fmt.Sprintf("%s dropped from %.2f%% to %.2f%%", name, r.Base, r.Target)
+}
+
+// Regressions compares the target release against the base
+// and returns the metrics that regressed past the thresholds,
+// sorted by metric. Releases with too few sessions are not
+// compared.
+func Regressions(base, target Health, t Thresholds) []Regression {
+	if base.Sessions < t.MinSessions || target.Sessions < t.MinSessions || base.Sessions == 0 || target.Sessions == 0 {
+		return nil
+	}
+
+	var regressions []Regression
+
+	drops := []struct {
+		metric       Metric
+		base, target *float64
+		threshold    float64
+	}{
+		{MetricCrashFree, base.CrashFreeSessions, target.CrashFreeSessions, t.CrashFreeDrop},
+		{MetricANRFree, base.ANRFreeSessions, target.ANRFreeSessions, t.ANRFreeDrop},
+	}
+
+	for _, d := range drops {
+		if d.base == nil || d.target == nil || d.threshold <= 0 {
+			continue
+		}
+		if *d.base-*d.target >= d.threshold {
+			regressions = append(regressions, Regression{Metric: d.metric, Base: *d.base, Target: *d.target})
+		}
+	}
+
+	launches := []struct {
+		metric       Metric
+		base, target *float64
+	}{
+		{MetricColdLaunch, base.ColdLaunchP95, target.ColdLaunchP95},
+		{MetricWarmLaunch, base.WarmLaunchP95, target.WarmLaunchP95},
+		{MetricHotLaunch, base.HotLaunchP95, target.HotLaunchP95},
+	}
+
+	for _, l := range launches {
+		if l.base == nil || l.target == nil || *l.base <= 0 || t.LaunchIncrease <= 0 {
+			continue
+		}
+		if *l.target > *l.base*(1+t.LaunchIncrease/100) && *l.target-*l.base >= minLaunchIncreaseMs {
+			regressions = append(regressions, Regression{Metric: l.metric, Base: *l.base, Target: *l.target})
+		}
+	}
+
+	sort.SliceStable(regressions, func(i, j int) bool {
+		return regressions[i].Metric < regressions[j].Metric
+	})
+
+	return regressions
+}
+
+// delta computes target minus base, rounded
+// to two decimals.
+func delta(base, target *float64) *float64 {
+	if base == nil || target == nil {
+		return nil
+	}
+	d := numeric.RoundTwoDecimalsFloat64(*target - *base)
+	return &d
+}
+
+// percent computes count out of total as a percent
+// rounded to two decimals, or nil without a total.
+func percent(count, total uint64) *float64 {
+	if total == 0 {
+		return nil
+	}
+	p := numeric.RoundTwoDecimalsFloat64(float64(count) / float64(total) * 100)
+	return &p
+}
+
+// freePercent computes the percent of total
+// free of issues, or nil without a total.
+func freePercent(issues, total uint64) *float64 {
+	if total == 0 {
+		return nil
+	}
+	p := numeric.RoundTwoDecimalsFloat64((1 - float64(issues)/float64(total)) * 100)
+	return &p
+}
+
+// quantile rounds a quantile, or nil when
+// it was computed over no data.
+func quantile(q float64) *float64 {
+	if math.IsNaN(q) || q <= 0 {
+		return nil
+	}
+	r := numeric.RoundTwoDecimalsFloat64(q)
+	return &r
+}
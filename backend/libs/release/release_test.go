@@ -0,0 +1,127 @@
+package release
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func TestCompare(t *testing.T) {
+	base := Health{
+		Adoption:          ptr(60),
+		CrashFreeSessions: ptr(99.5),
+		ColdLaunchP95:     ptr(1200),
+		Size:              ptr(24_000_000),
+	}
+	target := Health{
+		Adoption:          ptr(25.5),
+		CrashFreeSessions: ptr(98.25),
+		ColdLaunchP95:     ptr(1450),
+	}
+
+	diff := Compare(base, target)
+
+	if diff.Adoption == nil || *diff.Adoption != -34.5 {
+		t.Errorf("adoption diff = %v, want -34.5", diff.Adoption)
+	}
+	if diff.CrashFreeSessions == nil || *diff.CrashFreeSessions != -1.25 {
+		t.Errorf("crash free diff = %v, want -1.25", diff.CrashFreeSessions)
+	}
+	if diff.ColdLaunchP95 == nil || *diff.ColdLaunchP95 != 250 {
+		t.Errorf("cold launch diff = %v, want 250", diff.ColdLaunchP95)
+	}
+	if diff.Size != nil {
+		t.Errorf("size diff = %v, want nil without the target's size", *diff.Size)
+	}
+	if diff.ANRFreeSessions != nil {
+		t.Errorf("anr free diff = %v, want nil without data", *diff.ANRFreeSessions)
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	thresholds := Thresholds{
+		CrashFreeDrop:  1,
+		ANRFreeDrop:    1,
+		LaunchIncrease: 20,
+		MinSessions:    100,
+	}
+
+	base := Health{
+		Sessions:          5000,
+		CrashFreeSessions: ptr(99.5),
+		ANRFreeSessions:   ptr(99.8),
+		ColdLaunchP95:     ptr(1000),
+		WarmLaunchP95:     ptr(300),
+	}
+
+	t.Run("flags metrics past the thresholds", func(t *testing.T) {
+		target := Health{
+			Sessions:          800,
+			CrashFreeSessions: ptr(97),
+			ANRFreeSessions:   ptr(99.5),
+			ColdLaunchP95:     ptr(1500),
+			WarmLaunchP95:     ptr(380),
+		}
+
+		got := Regressions(base, target, thresholds)
+		want := []Regression{
+			{Metric: MetricColdLaunch, Base: 1000, Target: 1500},
+			{Metric: MetricCrashFree, Base: 99.5, Target: 97},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Regressions = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("ignores releases with too few sessions", func(t *testing.T) {
+		target := Health{
+			Sessions:          50,
+			CrashFreeSessions: ptr(50),
+		}
+
+		if got := Regressions(base, target, thresholds); len(got) != 0 {
+			t.Errorf("Regressions = %+v, want none", got)
+		}
+	})
+
+	t.Run("ignores metrics without data", func(t *testing.T) {
+		target := Health{
+			Sessions:          800,
+			CrashFreeSessions: ptr(99.5),
+		}
+
+		if got := Regressions(base, target, thresholds); len(got) != 0 {
+			t.Errorf("Regressions = %+v, want none", got)
+		}
+	})
+}
+
+func TestRegressionDescribe(t *testing.T) {
+	cases := []struct {
+		r    Regression
+		want string
+	}{
+		{Regression{Metric: MetricCrashFree, Base: 99.5, Target: 97.2}, "crash free sessions dropped from 99.50% to 97.20%"},
+		{Regression{Metric: MetricANRFree, Base: 99.9, Target: 98}, "ANR free sessions dropped from 99.90% to 98.00%"},
+		{Regression{Metric: MetricWarmLaunch, Base: 300, Target: 420}, "p95 warm launch rose from 300 ms to 420 ms"},
+	}
+
+	for _, c := range cases {
+		if got := c.r.Describe(); got != c.want {
+			t.Errorf("Describe = %q, want %q", got, c.want)
+		}
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	if got := quantile(math.NaN()); got != nil {
+		t.Errorf("quantile(NaN) = %v, want nil", *got)
+	}
+	if got := quantile(1234.567); got == nil || *got != 1234.57 {
+		t.Errorf("quantile(1234.567) = %v, want 1234.57", got)
+	}
+}
@@ -0,0 +1,200 @@
+package symbol
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"backend/libs/chquery"
+	"backend/libs/numeric"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/leporo/sqlf"
+)
+
+// maxMissingDebugIDs is the number of missing
+// debug ids reported per app version.
+const maxMissingDebugIDs = 100
+
+// Coverage is the symbol upload status of an app version
+// along with how much of its error stacktraces were
+// symbolicated.
+type Coverage struct {
+	VersionName string `json:"version_name"`
+	VersionCode string `json:"version_code"`
+	// MappingTypes are the types of mapping
+	// files uploaded for the version.
+	MappingTypes []string `json:"mapping_types"`
+	// Frames is the count of error frames
+	// needing symbolication.
+	Frames uint64 `json:"frames"`
+	// SymbolicatedFrames is the count of error
+	// frames symbolicated.
+	SymbolicatedFrames uint64 `json:"symbolicated_frames"`
+	// SymbolicatedPercentage is the percent of
+	// error frames symbolicated, nil without
+	// errors.
+	SymbolicatedPercentage *float64 `json:"symbolicated_percentage"`
+	// MissingDebugIDs are the debug ids or UUIDs
+	// of the binaries errors referenced that the
+	// symbolicator found no symbols for.
+	MissingDebugIDs []string `json:"missing_debug_ids"`
+	// MissingSymbols is true when errors of the
+	// version couldn't be symbolicated for lack
+	// of symbols.
+	MissingSymbols bool `json:"missing_symbols"`
+	// LastSeen is the time the version last saw
+	// an error or a mapping file upload.
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// tally computes the symbolicated percentage & whether
+// the version is missing symbols. A version misses
+// symbols when errors referenced binaries without
+// symbols or when no mapping file was uploaded for a
+// version with errors to symbolicate.
+func (c *Coverage) tally() {
+	if c.MappingTypes == nil {
+		c.MappingTypes = []string{}
+	}
+
+	if c.MissingDebugIDs == nil {
+		c.MissingDebugIDs = []string{}
+	}
+
+	if c.Frames == 0 {
+		return
+	}
+
+	p := numeric.RoundTwoDecimalsFloat64(float64(c.SymbolicatedFrames) / float64(c.Frames) * 100)
+	c.SymbolicatedPercentage = &p
+	c.MissingSymbols = len(c.MissingDebugIDs) > 0 || len(c.MappingTypes) == 0
+}
+
+// GetCoverage fetches the symbol upload status & the
+// symbolication coverage of every app version that saw
+// errors or mapping file uploads over [from, to], most
+// recently seen first.
+func GetCoverage(ctx context.Context, rch driver.Conn, pg *pgxpool.Pool, teamID, appID uuid.UUID, from, to time.Time) (coverages []Coverage, err error) {
+	ctx = chquery.WithTeamScope(ctx, teamID)
+
+	stmt := sqlf.From("symbolication_coverage final").
+		Select("app_version.1").
+		Select("app_version.2").
+		Select("sum(frames)").
+		Select("sum(symbolicated_frames)").
+		Select("groupUniqArrayArray(?)(missing_debug_ids)", maxMissingDebugIDs).
+		Select("max(timestamp)").
+		Where("team_id = toUUID(?)", teamID).
+		Where("app_id = toUUID(?)", appID).
+		Where("timestamp >= ? and timestamp <= ?", from, to).
+		GroupBy("app_version.1, app_version.2")
+
+	defer stmt.Close()
+
+	rows, err := rch.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	versions := make(map[[2]string]*Coverage)
+	var names, codes []string
+	for rows.Next() {
+		var c Coverage
+		if err = rows.Scan(&c.VersionName, &c.VersionCode, &c.Frames, &c.SymbolicatedFrames, &c.MissingDebugIDs, &c.LastSeen); err != nil {
+			return
+		}
+		versions[[2]string{c.VersionName, c.VersionCode}] = &c
+		names = append(names, c.VersionName)
+		codes = append(codes, c.VersionCode)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// mapping files of versions that saw errors are picked
+	// whenever they were uploaded. Over-The-Air patches
+	// carry no version and are left out.
+	mappingStmt := sqlf.PostgreSQL.
+		From("build_mappings").
+		Select("version_name").
+		Select("version_code").
+		Select("array_agg(distinct mapping_type order by mapping_type)").
+		Select("max(last_updated)").
+		Where("app_id = ?", appID).
+		Where("key != ''").
+		Where("version_name != ''").
+		Where("((last_updated >= ? and last_updated <= ?) or (version_name, version_code) in (select * from unnest(?::text[], ?::text[])))", from, to, names, codes).
+		GroupBy("version_name, version_code")
+
+	defer mappingStmt.Close()
+
+	mappingRows, err := pg.Query(ctx, mappingStmt.String(), mappingStmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer mappingRows.Close()
+
+	for mappingRows.Next() {
+		var name, code string
+		var mappingTypes []string
+		var lastUpdated time.Time
+		if err = mappingRows.Scan(&name, &code, &mappingTypes, &lastUpdated); err != nil {
+			return
+		}
+
+		key := [2]string{name, code}
+		c, ok := versions[key]
+		if !ok {
+			c = &Coverage{
+				VersionName: name,
+				VersionCode: code,
+			}
+			versions[key] = c
+		}
+
+		c.MappingTypes = mappingTypes
+		if lastUpdated.After(c.LastSeen) {
+			c.LastSeen = lastUpdated
+		}
+	}
+
+	if err = mappingRows.Err(); err != nil {
+		return
+	}
+
+	coverages = make([]Coverage, 0, len(versions))
+	for _, c := range versions {
+		c.tally()
+		coverages = append(coverages, *c)
+	}
+
+	slices.SortFunc(coverages, func(a, b Coverage) int {
+		if n := b.LastSeen.Compare(a.LastSeen); n != 0 {
+			return n
+		}
+		if n := cmp.Compare(b.VersionName, a.VersionName); n != 0 {
+			return n
+		}
+		return cmp.Compare(b.VersionCode, a.VersionCode)
+	})
+
+	return
+}
+
+// HasMappings reports whether any mapping
+// file was ever uploaded for the app.
+func HasMappings(ctx context.Context, pg *pgxpool.Pool, appID uuid.UUID) (ok bool, err error) {
+	stmt := sqlf.PostgreSQL.
+		Select("exists (select 1 from build_mappings where app_id = ? and key != '')", appID)
+
+	defer stmt.Close()
+
+	err = pg.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&ok)
+	return
+}
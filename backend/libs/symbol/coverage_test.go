@@ -0,0 +1,67 @@
+package symbol
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCoverageTally(t *testing.T) {
+	cases := []struct {
+		name        string
+		coverage    Coverage
+		wantPercent *float64
+		wantMissing bool
+	}{
+		{
+			name:     "no errors",
+			coverage: Coverage{MappingTypes: []string{"proguard"}},
+		},
+		{
+			name:        "fully symbolicated",
+			coverage:    Coverage{MappingTypes: []string{"proguard"}, Frames: 8, SymbolicatedFrames: 8},
+			wantPercent: ptr(100),
+		},
+		{
+			name:        "missing debug ids",
+			coverage:    Coverage{MappingTypes: []string{"dsym"}, Frames: 3, SymbolicatedFrames: 1, MissingDebugIDs: []string{"c3b5a2d1"}},
+			wantPercent: ptr(33.34),
+			wantMissing: true,
+		},
+		{
+			name:        "no mappings uploaded",
+			coverage:    Coverage{Frames: 4},
+			wantPercent: ptr(0),
+			wantMissing: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.coverage.tally()
+
+			got := c.coverage.SymbolicatedPercentage
+			if (got == nil) != (c.wantPercent == nil) || (got != nil && *got != *c.wantPercent) {
+				t.Errorf("SymbolicatedPercentage = %s, want %s", fmtPercent(got), fmtPercent(c.wantPercent))
+			}
+
+			if c.coverage.MissingSymbols != c.wantMissing {
+				t.Errorf("MissingSymbols = %v, want %v", c.coverage.MissingSymbols, c.wantMissing)
+			}
+
+			if c.coverage.MappingTypes == nil || c.coverage.MissingDebugIDs == nil {
+				t.Error("expected non-nil slices")
+			}
+		})
+	}
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+func fmtPercent(f *float64) string {
+	if f == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%.2f", *f)
+}
@@ -0,0 +1,55 @@
+package symbol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ParseDartObfuscationMap parses a Flutter obfuscation map,
+// as written by `--extra-gen-snapshot-options=--save-obfuscation-map`,
+// into a lookup of obfuscated to original identifiers.
+//
+// The map is a flat JSON array of strings, where each
+// original identifier is followed by its obfuscated
+// form.
+func ParseDartObfuscationMap(data []byte) (names map[string]string, err error) {
+	var pairs []string
+	if err = json.Unmarshal(data, &pairs); err != nil {
+		err = fmt.Errorf("failed to parse dart obfuscation map: %w", err)
+		return
+	}
+
+	if len(pairs)%2 != 0 {
+		err = errors.New("dart obfuscation map must contain pairs of original and obfuscated names")
+		return
+	}
+
+	names = make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		names[pairs[i+1]] = pairs[i]
+	}
+
+	return
+}
+
+// ExtractDartObfuscationMap validates a Flutter obfuscation
+// map & assigns it a stable unified layout key derived from
+// SHA1 of its contents.
+func ExtractDartObfuscationMap(data []byte) (dif *Dif, err error) {
+	if _, err = ParseDartObfuscationMap(data); err != nil {
+		return
+	}
+
+	ns := uuid.NewSHA1(uuid.NameSpaceDNS, []byte("measure.sh"))
+	fileId := uuid.NewSHA1(ns, data)
+
+	dif = &Dif{
+		Data: data,
+		Key:  BuildUnifiedLayout(fileId.String()) + "/" + TypeDartObfuscation.String(),
+	}
+
+	return
+}
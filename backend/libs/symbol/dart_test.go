@@ -0,0 +1,29 @@
+package symbol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDartObfuscationMap(t *testing.T) {
+	names, err := ParseDartObfuscationMap([]byte(`["MaterialApp", "ex", "_HomeState", "ey"]`))
+	if err != nil {
+		t.Fatalf("ParseDartObfuscationMap error: %v", err)
+	}
+
+	if names["ex"] != "MaterialApp" || names["ey"] != "_HomeState" || len(names) != 2 {
+		t.Errorf("names = %v, want obfuscated to original lookup", names)
+	}
+
+	if _, err := ParseDartObfuscationMap([]byte(`["MaterialApp"]`)); err == nil {
+		t.Errorf("expected error for unpaired name")
+	}
+
+	dif, err := ExtractDartObfuscationMap([]byte(`["MaterialApp", "ex"]`))
+	if err != nil {
+		t.Fatalf("ExtractDartObfuscationMap error: %v", err)
+	}
+	if !strings.HasSuffix(dif.Key, "/dart_obfuscation") {
+		t.Errorf("key = %q, want dart_obfuscation suffix", dif.Key)
+	}
+}
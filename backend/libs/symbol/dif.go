@@ -351,6 +351,10 @@ func ParseMappingType(s string) MappingType {
 		return TypeElfDebug
 	case "jsbundle":
 		return TypeJsBundle
+	case "dart_obfuscation":
+		return TypeDartObfuscation
+	case "hermes_sourcemap":
+		return TypeHermesSourcemap
 	default:
 		return TypeUnknown
 	}
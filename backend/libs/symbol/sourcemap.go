@@ -0,0 +1,241 @@
+package symbol
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// base64VLQ maps base64 characters to
+// their 6 bit values.
+var base64VLQ = func() (table [128]int) {
+	for i := range table {
+		table[i] = -1
+	}
+	for i, c := range "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/" {
+		table[c] = i
+	}
+	return
+}()
+
+// SourceMapToken is a single decoded mapping
+// of a generated position to its original
+// position.
+type SourceMapToken struct {
+	// GenLine is the 0-based line in
+	// the generated file.
+	GenLine int
+	// GenCol is the 0-based column in
+	// the generated file.
+	GenCol int
+	// Source is the original source
+	// file, empty when unmapped.
+	Source string
+	// Line is the 1-based line in
+	// the original source.
+	Line int
+	// Col is the 1-based column in
+	// the original source.
+	Col int
+	// Name is the original name of
+	// the symbol, if known.
+	Name string
+}
+
+// SourceMap is a decoded version 3 source map.
+type SourceMap struct {
+	// tokens are sorted by generated
+	// line & column.
+	tokens []SourceMapToken
+}
+
+// rawSourceMap is the JSON form of
+// a version 3 source map.
+type rawSourceMap struct {
+	Version    int      `json:"version"`
+	SourceRoot string   `json:"sourceRoot"`
+	Sources    []string `json:"sources"`
+	Names      []string `json:"names"`
+	Mappings   *string  `json:"mappings"`
+}
+
+// ParseSourceMap decodes a version 3 source map.
+// Indexed source maps with sections are not
+// supported.
+func ParseSourceMap(data []byte) (sm *SourceMap, err error) {
+	var raw rawSourceMap
+	if err = json.Unmarshal(data, &raw); err != nil {
+		err = fmt.Errorf("failed to parse source map: %w", err)
+		return
+	}
+
+	if raw.Version != 3 {
+		err = fmt.Errorf("unsupported source map version %d", raw.Version)
+		return
+	}
+
+	if raw.Mappings == nil {
+		err = errors.New("source map has no mappings")
+		return
+	}
+
+	sources := make([]string, len(raw.Sources))
+	for i, source := range raw.Sources {
+		if raw.SourceRoot != "" {
+			source = strings.TrimSuffix(raw.SourceRoot, "/") + "/" + source
+		}
+		sources[i] = source
+	}
+
+	sm = &SourceMap{}
+
+	// source, line, column & name indexes are
+	// relative to the previous segment across
+	// lines, generated column resets per line
+	var source, line, col, name int
+
+	for genLine, group := range strings.Split(*raw.Mappings, ";") {
+		genCol := 0
+		for _, segment := range strings.Split(group, ",") {
+			if segment == "" {
+				continue
+			}
+
+			fields, decodeErr := decodeVLQ(segment)
+			if decodeErr != nil {
+				err = fmt.Errorf("failed to decode source map segment %q: %w", segment, decodeErr)
+				return
+			}
+
+			genCol += fields[0]
+			token := SourceMapToken{
+				GenLine: genLine,
+				GenCol:  genCol,
+			}
+
+			if len(fields) >= 4 {
+				source += fields[1]
+				line += fields[2]
+				col += fields[3]
+				if source >= 0 && source < len(sources) {
+					token.Source = sources[source]
+				}
+				token.Line = line + 1
+				token.Col = col + 1
+			}
+
+			if len(fields) >= 5 {
+				name += fields[4]
+				if name >= 0 && name < len(raw.Names) {
+					token.Name = raw.Names[name]
+				}
+			}
+
+			sm.tokens = append(sm.tokens, token)
+		}
+	}
+
+	sort.SliceStable(sm.tokens, func(i, j int) bool {
+		a, b := sm.tokens[i], sm.tokens[j]
+		if a.GenLine != b.GenLine {
+			return a.GenLine < b.GenLine
+		}
+		return a.GenCol < b.GenCol
+	})
+
+	return
+}
+
+// Lookup finds the original position of a generated
+// position, given as a 1-based line & a 0-based
+// column. The closest mapping at or before the
+// column on the same line wins.
+func (sm SourceMap) Lookup(line, col int) (token SourceMapToken, ok bool) {
+	genLine := line - 1
+	i := sort.Search(len(sm.tokens), func(i int) bool {
+		t := sm.tokens[i]
+		return t.GenLine > genLine || t.GenLine == genLine && t.GenCol > col
+	})
+
+	if i == 0 {
+		return
+	}
+
+	token = sm.tokens[i-1]
+	if token.GenLine != genLine || token.Source == "" {
+		return SourceMapToken{}, false
+	}
+
+	ok = true
+	return
+}
+
+// decodeVLQ decodes a base64 VLQ encoded
+// source map segment.
+func decodeVLQ(segment string) (fields []int, err error) {
+	value, shift := 0, 0
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if c >= 128 || base64VLQ[c] < 0 {
+			err = fmt.Errorf("invalid base64 character %q", c)
+			return
+		}
+
+		digit := base64VLQ[c]
+		value += (digit & 0x1f) << shift
+
+		if digit&0x20 != 0 {
+			shift += 5
+			continue
+		}
+
+		// least significant bit
+		// holds the sign
+		if value&1 != 0 {
+			fields = append(fields, -(value >> 1))
+		} else {
+			fields = append(fields, value>>1)
+		}
+		value, shift = 0, 0
+	}
+
+	if shift != 0 {
+		err = errors.New("incomplete VLQ value")
+	}
+
+	return
+}
+
+// ExtractHermesSourcemap validates a Hermes source map
+// & assigns it a stable unified layout key derived from
+// SHA1 of its contents. Like JS bundles, the key's last
+// segment is the original filename, always ending in
+// `.map`.
+func ExtractHermesSourcemap(filename string, data []byte) (dif *Dif, err error) {
+	if _, err = ParseSourceMap(data); err != nil {
+		return
+	}
+
+	name := path.Base(filename)
+	if name == "." || name == "/" {
+		name = "index.android.bundle.map"
+	}
+	if !strings.HasSuffix(name, ".map") {
+		name += ".map"
+	}
+
+	ns := uuid.NewSHA1(uuid.NameSpaceDNS, []byte("measure.sh"))
+	fileId := uuid.NewSHA1(ns, data)
+
+	dif = &Dif{
+		Data: data,
+		Key:  BuildUnifiedLayout(fileId.String()) + "/" + name,
+	}
+
+	return
+}
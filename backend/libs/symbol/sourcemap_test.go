@@ -0,0 +1,123 @@
+package symbol
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testSourceMap = `{
+	"version": 3,
+	"sourceRoot": "src",
+	"sources": ["App.tsx"],
+	"names": ["onPress"],
+	"mappings": "AAAAA,KACE;AAAA"
+}`
+
+func TestDecodeVLQ(t *testing.T) {
+	cases := []struct {
+		in   string
+		want []int
+	}{
+		{"AAAA", []int{0, 0, 0, 0}},
+		{"KACE", []int{5, 0, 1, 2}},
+		{"D", []int{-1}},
+		{"gB", []int{16}},
+	}
+
+	for _, c := range cases {
+		got, err := decodeVLQ(c.in)
+		if err != nil {
+			t.Fatalf("decodeVLQ(%q) error: %v", c.in, err)
+		}
+		if !slices.Equal(got, c.want) {
+			t.Errorf("decodeVLQ(%q) = %v, want %v", c.in, got, c.want)
+		}
+	}
+
+	if _, err := decodeVLQ("g"); err == nil {
+		t.Errorf("expected error for incomplete value")
+	}
+	if _, err := decodeVLQ("A!"); err == nil {
+		t.Errorf("expected error for invalid character")
+	}
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sm, err := ParseSourceMap([]byte(testSourceMap))
+	if err != nil {
+		t.Fatalf("ParseSourceMap error: %v", err)
+	}
+
+	cases := []struct {
+		name      string
+		line, col int
+		want      SourceMapToken
+		ok        bool
+	}{
+		{
+			name: "exact named mapping",
+			line: 1, col: 0,
+			want: SourceMapToken{GenLine: 0, GenCol: 0, Source: "src/App.tsx", Line: 1, Col: 1, Name: "onPress"},
+			ok:   true,
+		},
+		{
+			name: "closest mapping before column",
+			line: 1, col: 7,
+			want: SourceMapToken{GenLine: 0, GenCol: 5, Source: "src/App.tsx", Line: 2, Col: 3},
+			ok:   true,
+		},
+		{
+			name: "second line",
+			line: 2, col: 100,
+			want: SourceMapToken{GenLine: 1, GenCol: 0, Source: "src/App.tsx", Line: 2, Col: 3},
+			ok:   true,
+		},
+		{
+			name: "unmapped line",
+			line: 3, col: 0,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := sm.Lookup(c.line, c.col)
+			if ok != c.ok || got != c.want {
+				t.Errorf("Lookup(%d, %d) = %+v, %v, want %+v, %v", c.line, c.col, got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+func TestParseSourceMapInvalid(t *testing.T) {
+	for _, data := range []string{
+		`not json`,
+		`{"version": 2, "mappings": ""}`,
+		`{"version": 3}`,
+		`{"version": 3, "mappings": "A!"}`,
+	} {
+		if _, err := ParseSourceMap([]byte(data)); err == nil {
+			t.Errorf("expected error for %s", data)
+		}
+	}
+}
+
+func TestExtractHermesSourcemap(t *testing.T) {
+	dif, err := ExtractHermesSourcemap("build/index.android.bundle", []byte(testSourceMap))
+	if err != nil {
+		t.Fatalf("ExtractHermesSourcemap error: %v", err)
+	}
+
+	if !strings.HasSuffix(dif.Key, "/index.android.bundle.map") {
+		t.Errorf("key = %q, want .map filename suffix", dif.Key)
+	}
+
+	again, _ := ExtractHermesSourcemap("index.android.bundle.map", []byte(testSourceMap))
+	if again.Key != dif.Key {
+		t.Errorf("key = %q, want stable key %q", again.Key, dif.Key)
+	}
+
+	if _, err := ExtractHermesSourcemap("index.android.bundle.map", []byte(`{}`)); err == nil {
+		t.Errorf("expected error for invalid source map")
+	}
+}
@@ -16,6 +16,14 @@ const (
 	// TypeJsBundle represents the "javascript"
 	// bundle type of mapping symbolication.
 	TypeJsBundle
+	// TypeDartObfuscation represents the Flutter
+	// "obfuscation map" type of mapping
+	// de-obfuscation.
+	TypeDartObfuscation
+	// TypeHermesSourcemap represents the React
+	// Native "Hermes source map" type of mapping
+	// symbolication.
+	TypeHermesSourcemap
 )
 
 // MappingType represents the mapping
@@ -30,6 +38,8 @@ func MappingTypes() []MappingType {
 		TypeDsym,
 		TypeElfDebug,
 		TypeJsBundle,
+		TypeDartObfuscation,
+		TypeHermesSourcemap,
 	}
 }
 
@@ -47,5 +57,9 @@ func (m MappingType) String() string {
 		return "elf_debug"
 	case TypeJsBundle:
 		return "jsbundle"
+	case TypeDartObfuscation:
+		return "dart_obfuscation"
+	case TypeHermesSourcemap:
+		return "hermes_sourcemap"
 	}
 }
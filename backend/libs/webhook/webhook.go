@@ -0,0 +1,373 @@
+// Package webhook delivers alert events to a team's outbound webhook. Every
+// request carries a JSON event signed with the team's secret, so a receiver
+// can verify it came from Measure and was not replayed. The api service posts
+// through here for test sends and the alerts service for queued deliveries.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// HeaderSignature carries the timestamp and HMAC-SHA256 signature of
+	// a delivery, formatted as "t=<unix seconds>,v1=<hex digest>".
+	HeaderSignature = "X-Measure-Signature"
+
+	// HeaderEvent carries the event type of a delivery.
+	HeaderEvent = "X-Measure-Event"
+
+	// HeaderDelivery carries the delivery id. Retries of the same delivery
+	// reuse it, so receivers can drop duplicates.
+	HeaderDelivery = "X-Measure-Delivery"
+)
+
+// EventTest is the event type of deliveries sent from the dashboard to
+// check that a webhook is reachable.
+const EventTest = "test"
+
+// EventDailySummary is the event type of the team daily summary.
+const EventDailySummary = "daily_summary"
+
+// MaxAttempts is the number of times a delivery is attempted before it is
+// marked as failed.
+const MaxAttempts = 8
+
+// timeout bounds a single delivery attempt.
+const timeout = 10 * time.Second
+
+// maxBackoff caps the wait between two attempts of a delivery.
+const maxBackoff = time.Hour
+
+// secretPrefix marks webhook signing secrets so they are easy to recognize
+// when pasted into a receiver's configuration.
+const secretPrefix = "whsec_"
+
+// ErrNonPublicAddress is returned when a webhook url points at, or its
+// host resolves to, an address that is not routable on the public
+// internet, like loopback, private or link-local addresses.
+var ErrNonPublicAddress = errors.New("webhook url must point to a public address")
+
+// ErrSignatureMismatch is returned by Verify when the signature does not
+// match the body.
+var ErrSignatureMismatch = errors.New("webhook signature mismatch")
+
+// ErrSignatureExpired is returned by Verify when the signed timestamp is
+// outside the allowed tolerance.
+var ErrSignatureExpired = errors.New("webhook signature expired")
+
+// ErrSignatureMalformed is returned by Verify when the signature header
+// cannot be parsed.
+var ErrSignatureMalformed = errors.New("malformed webhook signature")
+
+// Event is the JSON body posted to a webhook.
+type Event struct {
+	ID           string        `json:"id"`
+	Type         string        `json:"type"`
+	CreatedAt    time.Time     `json:"created_at"`
+	Team         Team          `json:"team"`
+	App          *App          `json:"app,omitempty"`
+	Alert        *Alert        `json:"alert,omitempty"`
+	DailySummary *DailySummary `json:"daily_summary,omitempty"`
+}
+
+// Team identifies the team an event belongs to.
+type Team struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// App identifies the app an event belongs to.
+type App struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Alert describes the alert behind a crash spike, ANR spike or bug
+// report event.
+type Alert struct {
+	ID       string `json:"id"`
+	EntityID string `json:"entity_id"`
+	Message  string `json:"message"`
+	URL      string `json:"url"`
+}
+
+// DailySummary carries the team daily summary.
+type DailySummary struct {
+	Date string       `json:"date"`
+	URL  string       `json:"url"`
+	Apps []AppSummary `json:"apps"`
+}
+
+// AppSummary is one app's metrics in the daily summary.
+type AppSummary struct {
+	AppName string   `json:"app_name"`
+	Metrics []Metric `json:"metrics"`
+}
+
+// Metric is one metric card of the daily summary. Status is "good",
+// "caution" or "poor".
+type Metric struct {
+	Label    string `json:"label"`
+	Value    string `json:"value"`
+	Subtitle string `json:"subtitle"`
+	Status   string `json:"status"`
+}
+
+// StatusError is returned by Post when the receiver responds with a
+// non-2xx status. The response body is never kept, the receiver's
+// response is not ours to store.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d", e.StatusCode)
+}
+
+// NewSecret creates a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// ValidateURL reports whether rawURL can be used as a webhook
+// destination. Only absolute http and https URLs are accepted, and
+// hosts that are non-public IPs or localhost are rejected. Hostnames
+// are not resolved here, they can resolve elsewhere by the time a
+// delivery is sent. NewClient checks the address at connect time.
+func ValidateURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must use http or https")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("webhook url must have a host")
+	}
+	if u.User != nil {
+		return fmt.Errorf("webhook url must not contain credentials")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrNonPublicAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !IsPublicAddr(addr) {
+		return ErrNonPublicAddress
+	}
+
+	return nil
+}
+
+// sharedAddrSpace is the carrier-grade NAT range, private in practice
+// but not covered by netip's IsPrivate.
+var sharedAddrSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPublicAddr reports whether addr is routable on the public internet.
+// Loopback, private, link-local, shared, multicast and unspecified
+// addresses are not.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	switch {
+	case !addr.IsValid(),
+		addr.IsUnspecified(),
+		addr.IsLoopback(),
+		addr.IsPrivate(),
+		addr.IsLinkLocalUnicast(),
+		addr.IsLinkLocalMulticast(),
+		addr.IsInterfaceLocalMulticast(),
+		addr.IsMulticast(),
+		sharedAddrSpace.Contains(addr):
+		return false
+	}
+	// 0.0.0.0/8 means "this network" and is not routable either
+	if addr.Is4() && addr.As4()[0] == 0 {
+		return false
+	}
+	return true
+}
+
+// NewClient creates the http client deliveries are posted with. It
+// refuses to connect to non-public addresses. The check runs on the
+// address being dialed, after name resolution, so a hostname that
+// resolves to an internal address, now or after a DNS change, is
+// rejected too. Proxies are not used, a proxy would do the dialing.
+func NewClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !IsPublicAddr(addrPort.Addr()) {
+				return ErrNonPublicAddress
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// a redirect is followed through the same transport, so its
+		// target is checked as well
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 3 {
+				return errors.New("webhook url redirected too many times")
+			}
+			return nil
+		},
+	}
+}
+
+// Sign computes the signature header value for body sent at ts. The
+// digest covers "<unix seconds>.<body>", so a captured request cannot be
+// replayed with a different timestamp.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + unix + ",v1=" + digest(secret, unix, body)
+}
+
+// Verify checks a signature header created by Sign. Signatures older or
+// newer than tolerance relative to now are rejected.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var unix, mac string
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrSignatureMalformed
+		}
+		switch k {
+		case "t":
+			unix = v
+		case "v1":
+			mac = v
+		}
+	}
+	if unix == "" || mac == "" {
+		return ErrSignatureMalformed
+	}
+
+	secs, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return ErrSignatureMalformed
+	}
+
+	if !hmac.Equal([]byte(mac), []byte(digest(secret, unix, body))) {
+		return ErrSignatureMismatch
+	}
+
+	if d := now.Sub(time.Unix(secs, 0)); d > tolerance || d < -tolerance {
+		return ErrSignatureExpired
+	}
+
+	return nil
+}
+
+// digest computes the hex HMAC-SHA256 of "<unix>.<body>" under secret.
+func digest(secret, unix string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(unix))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Post sends one signed delivery of body to rawURL and returns the
+// response status. A non-2xx response is returned as a *StatusError along
+// with its status code. A nil client posts through a client from NewClient.
+func Post(ctx context.Context, client *http.Client, rawURL, secret, deliveryID, eventType string, body []byte) (int, error) {
+	if client == nil {
+		client = NewClient()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Measure-Webhook/1.0")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderSignature, Sign(secret, time.Now(), body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain a little of the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Retryable reports whether a failed delivery should be attempted again.
+// Network errors, timeouts, rate limiting and server errors are transient.
+// Any other client error means the receiver rejected the event and will
+// keep rejecting it, as does a url pointing to a non-public address.
+func Retryable(err error) bool {
+	if err == nil || errors.Is(err, ErrNonPublicAddress) {
+		return false
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	switch {
+	case statusErr.StatusCode == http.StatusRequestTimeout,
+		statusErr.StatusCode == http.StatusTooManyRequests,
+		statusErr.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// Backoff returns the wait before the next attempt of a delivery that
+// has failed attempts times. It doubles from one minute and is capped at
+// an hour.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return 0
+	}
+	// past the 7th doubling the wait is over the cap anyway, and stopping
+	// early keeps the shift from overflowing
+	if attempts > 7 {
+		return maxBackoff
+	}
+	d := time.Minute << (attempts - 1)
+	return min(d, maxBackoff)
+}
@@ -20,6 +20,10 @@ func main() {
 
 	defer server.Server.PgPool.Close()
 
+	if server.Server.ResymbolicateProducer != nil {
+		defer server.Server.ResymbolicateProducer.Close()
+	}
+
 	r := gin.Default()
 
 	closeTracer := config.InitTracer()
@@ -9,12 +9,14 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"symboloader/cipher"
 	"symboloader/objstore"
 	"symboloader/server"
 	"time"
 
+	"backend/libs/ingest"
 	"backend/libs/symbol"
 
 	"cloud.google.com/go/storage"
@@ -43,7 +45,7 @@ var processTracer = otel.Tracer("symboloader-process")
 
 type Mapping struct {
 	ID             uuid.UUID     `json:"id"`
-	Type           string        `json:"type" binding:"required,oneof=proguard dsym elf_debug jsbundle"`
+	Type           string        `json:"type" binding:"required,oneof=proguard dsym elf_debug jsbundle dart_obfuscation hermes_sourcemap"`
 	Key            string        `json:"key,omitempty"`
 	Location       string        `json:"location,omitempty"`
 	Checksum       string        `json:"checksum,omitempty"`
@@ -162,6 +164,20 @@ func (m *Mapping) extractDif() (err error) {
 		}
 		m.Difs = append(m.Difs, difs...)
 
+	case symbol.TypeDartObfuscation.String():
+		dif, errExtract := symbol.ExtractDartObfuscationMap(m.File)
+		if errExtract != nil {
+			return errExtract
+		}
+		m.Difs = append(m.Difs, dif)
+
+	case symbol.TypeHermesSourcemap.String():
+		dif, errExtract := symbol.ExtractHermesSourcemap(m.Filename, m.File)
+		if errExtract != nil {
+			return errExtract
+		}
+		m.Difs = append(m.Difs, dif)
+
 	default:
 		err = fmt.Errorf("failed to recognize mapping type %q", m.Type)
 	}
@@ -410,13 +426,13 @@ func (b *Build) upload(ctx context.Context) (err error) {
 					}
 				}
 			}
-		case symbol.TypeJsBundle.String():
+		case symbol.TypeJsBundle.String(), symbol.TypeDartObfuscation.String(), symbol.TypeHermesSourcemap.String():
 			mapping := b.Mappings[index]
 			if !mapping.ShouldUpload {
 				continue
 			}
 
-			metadata["mapping_type"] = symbol.TypeJsBundle.String()
+			metadata["mapping_type"] = mapping.Type
 			metadata["original_file_name"] = mapping.Filename
 
 			for _, dif := range mapping.Difs {
@@ -494,6 +510,12 @@ func (b *Build) artifactExists(key, checksum string, patchID uuid.UUID) bool {
 func (b *Build) recordArtifact(index int, dif *symbol.Dif, checksum string) (err error) {
 	location := buildLocation(dif.Key)
 
+	// errors ingested before this artifact
+	// landed need another symbolication pass
+	if !slices.Contains(b.Resymbolicate, b.Mappings[index].Type) {
+		b.Resymbolicate = append(b.Resymbolicate, b.Mappings[index].Type)
+	}
+
 	if !b.Mappings[index].UploadComplete {
 		b.Mappings[index].Key = dif.Key
 		b.Mappings[index].Location = location
@@ -516,6 +538,46 @@ func (b *Build) recordArtifact(index int, dif *symbol.Dif, checksum string) (err
 	return
 }
 
+// resymbolicateJobs creates a re-symbolication job for
+// each mapping type that had new artifacts recorded.
+func (b *Build) resymbolicateJobs(uploadedAt time.Time) (jobs []ingest.ResymbolicateJob) {
+	for _, mappingType := range b.Resymbolicate {
+		jobs = append(jobs, ingest.ResymbolicateJob{
+			AppID:       b.AppID,
+			VersionName: b.VersionName,
+			VersionCode: b.VersionCode,
+			PatchID:     b.PatchID,
+			MappingType: mappingType,
+			UploadedAt:  uploadedAt,
+		})
+	}
+
+	return
+}
+
+// queueResymbolication queues re-symbolication of the
+// errors ingested before the build's new artifacts
+// landed. Queueing is best effort, failures are logged
+// & don't fail processing of the build.
+func (b *Build) queueResymbolication(ctx context.Context) {
+	producer := server.Server.ResymbolicateProducer
+	if producer == nil {
+		return
+	}
+
+	for _, job := range b.resymbolicateJobs(time.Now()) {
+		data, err := json.Marshal(job)
+		if err != nil {
+			fmt.Printf("failed to marshal resymbolicate job for app %q: %v\n", b.AppID, err)
+			continue
+		}
+
+		if err := producer.Publish(ctx, data); err != nil {
+			fmt.Printf("failed to queue resymbolication of %s errors for app %q version %s (%s): %v\n", job.MappingType, b.AppID, b.VersionName, b.VersionCode, err)
+		}
+	}
+}
+
 // load reads existing build mappings from database.
 func (b *Build) load(ctx context.Context, id uuid.UUID) (err error) {
 	stmt := sqlf.PostgreSQL.
@@ -651,6 +713,10 @@ type Build struct {
 	// Extras holds additional artifact rows to insert when one
 	// mapping extracts to more than one object.
 	Extras []*Mapping
+	// Resymbolicate holds the mapping types that had new
+	// artifacts recorded, so errors already ingested for
+	// the build get symbolicated again.
+	Resymbolicate []string
 }
 
 type BuildResponse struct {
@@ -997,6 +1063,8 @@ func ProcessGCSSymbolNotification(c *gin.Context) {
 
 	updateSpan.End()
 
+	build.queueResymbolication(ctx)
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "symbol notification processed successfully",
 	})
@@ -1265,6 +1333,8 @@ func ProcessSymbolNotification(c *gin.Context) {
 			return
 		}
 
+		build.queueResymbolication(ctx)
+
 		// cleanup to remove the incoming file always
 		defer func() {
 			_, err = objstore.DeleteS3Object(ctx, s3Client, &s3.DeleteObjectInput{
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"backend/libs/symbol"
 
@@ -68,6 +70,58 @@ func TestRecordArtifact(t *testing.T) {
 	}
 }
 
+// TestResymbolicateJobs checks that recording artifacts queues one
+// re-symbolication job per mapping type, however many artifacts the
+// mapping extracted to.
+func TestResymbolicateJobs(t *testing.T) {
+	server.SetConfig(&server.ServerConfig{SymbolsBucket: "test", SymbolsBucketRegion: "us-east-1"})
+
+	patchID := uuid.MustParse("aaaaaaaa-0000-0000-0000-000000000001")
+	b := &Build{
+		AppID:       uuid.MustParse("cccccccc-0000-0000-0000-000000000003"),
+		VersionName: "1.0.0",
+		VersionCode: "100",
+		PatchID:     patchID,
+		Mappings: []*Mapping{
+			{Type: symbol.TypeProguard.String()},
+			{Type: symbol.TypeElfDebug.String()},
+		},
+	}
+
+	if jobs := b.resymbolicateJobs(time.Now()); len(jobs) != 0 {
+		t.Fatalf("jobs = %d before any artifact is recorded, want 0", len(jobs))
+	}
+
+	for i, key := range []string{"aa/01/proguard", "bb/02/debuginfo", "cc/03/debuginfo"} {
+		index := 0
+		if i > 0 {
+			index = 1
+		}
+		if err := b.recordArtifact(index, &symbol.Dif{Key: key, Data: []byte(key)}, "cksum"); err != nil {
+			t.Fatalf("recordArtifact[%d]: %v", i, err)
+		}
+	}
+
+	uploadedAt := time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)
+	jobs := b.resymbolicateJobs(uploadedAt)
+	if len(jobs) != 2 {
+		t.Fatalf("jobs = %d, want 2", len(jobs))
+	}
+
+	if jobs[0].MappingType != symbol.TypeProguard.String() || jobs[1].MappingType != symbol.TypeElfDebug.String() {
+		t.Fatalf("job mapping types = %q, %q", jobs[0].MappingType, jobs[1].MappingType)
+	}
+
+	for _, job := range jobs {
+		if job.AppID != b.AppID || job.VersionName != "1.0.0" || job.VersionCode != "100" || job.PatchID != patchID {
+			t.Errorf("job = %+v, want build's app, version & patch", job)
+		}
+		if !job.UploadedAt.Equal(uploadedAt) {
+			t.Errorf("job uploaded at = %v, want %v", job.UploadedAt, uploadedAt)
+		}
+	}
+}
+
 // TestArtifactExists is the dedup gate: an object is skipped only
 // when a sibling row matches on key, checksum & patch id. Matching
 // on key+checksum alone makes a redelivered notification
@@ -99,3 +153,41 @@ func TestArtifactExists(t *testing.T) {
 		t.Fatal("same key+checksum different patch must not match")
 	}
 }
+
+// TestExtractDifSingleFileMappings checks that Flutter obfuscation
+// maps & Hermes source maps extract to a single dif each, and that
+// malformed files are rejected before upload.
+func TestExtractDifSingleFileMappings(t *testing.T) {
+	dart := &Mapping{
+		Type:     symbol.TypeDartObfuscation.String(),
+		Filename: "obfuscation.json",
+		File:     []byte(`["MaterialApp", "ex"]`),
+	}
+	if err := dart.extractDif(); err != nil {
+		t.Fatalf("dart extractDif: %v", err)
+	}
+	if len(dart.Difs) != 1 || !strings.HasSuffix(dart.Difs[0].Key, "/dart_obfuscation") {
+		t.Fatalf("dart difs = %+v, want one dart_obfuscation dif", dart.Difs)
+	}
+
+	hermes := &Mapping{
+		Type:     symbol.TypeHermesSourcemap.String(),
+		Filename: "index.android.bundle.map",
+		File:     []byte(`{"version": 3, "sources": ["App.tsx"], "names": [], "mappings": "AAAA"}`),
+	}
+	if err := hermes.extractDif(); err != nil {
+		t.Fatalf("hermes extractDif: %v", err)
+	}
+	if len(hermes.Difs) != 1 || !strings.HasSuffix(hermes.Difs[0].Key, "/index.android.bundle.map") {
+		t.Fatalf("hermes difs = %+v, want one source map dif", hermes.Difs)
+	}
+
+	invalid := &Mapping{
+		Type:     symbol.TypeDartObfuscation.String(),
+		Filename: "obfuscation.json",
+		File:     []byte(`["MaterialApp"]`),
+	}
+	if err := invalid.extractDif(); err == nil {
+		t.Fatal("unpaired obfuscation map must be rejected")
+	}
+}
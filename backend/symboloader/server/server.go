@@ -8,6 +8,8 @@ import (
 	"os"
 	"strings"
 
+	"backend/libs/bus"
+	"backend/libs/ingest"
 	"backend/libs/secret"
 
 	"cloud.google.com/go/cloudsqlconn"
@@ -27,6 +29,9 @@ var Server *server
 type server struct {
 	PgPool *pgxpool.Pool
 	Config *ServerConfig
+	// ResymbolicateProducer queues re-symbolication of
+	// errors ingested before their mapping files landed.
+	ResymbolicateProducer bus.Producer
 }
 
 type PostgresConfig struct {
@@ -169,6 +174,13 @@ func Init(config *ServerConfig) {
 		PgPool: pgPool,
 		Config: config,
 	}
+
+	resymbolicateProducer, err := bus.NewPostgresProducer(pgPool, ingest.ResymbolicateTopic)
+	if err != nil {
+		log.Printf("failed to create resymbolicate producer: %v\n", err)
+	} else {
+		Server.ResymbolicateProducer = resymbolicateProducer
+	}
 }
 
 func (sc ServerConfig) InitTracer() func(context.Context) error {
@@ -0,0 +1,32 @@
+-- migrate:up
+create table if not exists profile_stacks
+(
+    `team_id` LowCardinality(UUID) comment 'associated team id' CODEC(LZ4),
+    `app_id` LowCardinality(UUID) comment 'associated app id' CODEC(LZ4),
+    `event_id` UUID comment 'id of the profile event' CODEC(LZ4),
+    `session_id` UUID comment 'id of the session the profile was taken in' CODEC(LZ4),
+    `timestamp` DateTime64(3, 'UTC') comment 'timestamp of the profile event' CODEC(DoubleDelta, ZSTD(3)),
+    `app_version` Tuple(
+        LowCardinality(String),
+        LowCardinality(String)) comment 'composite app version' CODEC(ZSTD(3)),
+    `reason` LowCardinality(String) comment 'reason the profile was taken, like anr' CODEC(ZSTD(3)),
+    `format` LowCardinality(String) comment 'format of the profile attachment' CODEC(ZSTD(3)),
+    `screen` String comment 'last screen viewed by the time the profile ended, empty if unknown' CODEC(ZSTD(3)),
+    `span_names` Array(LowCardinality(String)) comment 'names of the spans overlapping the profile' CODEC(ZSTD(3)),
+    `thread_name` LowCardinality(String) comment 'name of the sampled thread, empty if unknown' CODEC(ZSTD(3)),
+    `stack` Array(String) comment 'symbolicated frames of the stack, outermost first' CODEC(ZSTD(3)),
+    `stack_hash` UInt64 default cityHash64(`stack`) comment 'hash of the stack' CODEC(ZSTD(3)),
+    `weight` UInt64 comment 'time spent on the stack in nanoseconds' CODEC(T64, ZSTD(3)),
+    `samples` UInt64 comment 'number of times the stack was sampled' CODEC(T64, ZSTD(3)),
+    INDEX screen_bloom_idx `screen` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX span_names_bloom_idx `span_names` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX timestamp_minmax_idx `timestamp` TYPE minmax GRANULARITY 1
+)
+engine = ReplacingMergeTree
+partition by toYYYYMM(`timestamp`)
+order by (`team_id`, `app_id`, `app_version`.1, `app_version`.2, `event_id`, `thread_name`, `stack_hash`)
+settings index_granularity = 8192
+comment 'stacks of parsed cpu profiles, merged per profile, thread and stack';
+
+-- migrate:down
+drop table if exists profile_stacks;
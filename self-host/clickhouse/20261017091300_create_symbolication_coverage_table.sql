@@ -0,0 +1,23 @@
+-- migrate:up
+create table if not exists symbolication_coverage
+(
+    `team_id` LowCardinality(UUID) comment 'associated team id' CODEC(LZ4),
+    `app_id` LowCardinality(UUID) comment 'associated app id' CODEC(LZ4),
+    `batch_id` UUID comment 'id of the ingest batch the errors arrived in' CODEC(LZ4),
+    `timestamp` DateTime64(3, 'UTC') comment 'timestamp the batch was symbolicated at' CODEC(DoubleDelta, ZSTD(3)),
+    `app_version` Tuple(
+        LowCardinality(String),
+        LowCardinality(String)) comment 'composite app version' CODEC(ZSTD(3)),
+    `frames` UInt64 comment 'count of error frames needing symbolication' CODEC(T64, ZSTD(3)),
+    `symbolicated_frames` UInt64 comment 'count of error frames symbolicated' CODEC(T64, ZSTD(3)),
+    `missing_debug_ids` Array(String) comment 'debug ids or uuids of binaries referenced by errors without symbols' CODEC(ZSTD(3)),
+    INDEX timestamp_minmax_idx `timestamp` TYPE minmax GRANULARITY 1
+)
+engine = ReplacingMergeTree
+partition by toYYYYMM(`timestamp`)
+order by (`team_id`, `app_id`, `app_version`.1, `app_version`.2, `batch_id`)
+settings index_granularity = 8192
+comment 'symbolication coverage of errors per ingest batch and app version';
+
+-- migrate:down
+drop table if exists symbolication_coverage;
@@ -0,0 +1,37 @@
+-- migrate:up
+create table if not exists heap_dumps
+(
+    `team_id` LowCardinality(UUID) comment 'associated team id' CODEC(LZ4),
+    `app_id` LowCardinality(UUID) comment 'associated app id' CODEC(LZ4),
+    `event_id` UUID comment 'id of the event the heap dump was attached to' CODEC(LZ4),
+    `event_type` LowCardinality(String) comment 'type of the event the heap dump was attached to' CODEC(ZSTD(3)),
+    `session_id` UUID comment 'id of the session the heap dump was taken in' CODEC(LZ4),
+    `timestamp` DateTime64(3, 'UTC') comment 'timestamp of the event' CODEC(DoubleDelta, ZSTD(3)),
+    `app_version` Tuple(
+        LowCardinality(String),
+        LowCardinality(String)) comment 'composite app version' CODEC(ZSTD(3)),
+    `heap_size` UInt64 comment 'estimated size of reachable objects in bytes' CODEC(T64, ZSTD(3)),
+    `objects` UInt64 comment 'count of reachable objects' CODEC(T64, ZSTD(3)),
+    `class_names` Array(String) comment 'classes retaining the most memory' CODEC(ZSTD(3)),
+    `class_instances` Array(UInt64) comment 'count of reachable instances of each class' CODEC(ZSTD(3)),
+    `class_shallow_sizes` Array(UInt64) comment 'shallow size of the instances of each class in bytes' CODEC(ZSTD(3)),
+    `class_retained_sizes` Array(UInt64) comment 'size retained by the instances of each class in bytes' CODEC(ZSTD(3)),
+    `leak_kinds` Array(LowCardinality(String)) comment 'kind of each leak suspect, like destroyed_activity' CODEC(ZSTD(3)),
+    `leak_class_names` Array(String) comment 'class of each leak suspect' CODEC(ZSTD(3)),
+    `leak_instances` Array(UInt64) comment 'count of suspect instances of each leak suspect' CODEC(ZSTD(3)),
+    `leak_retained_sizes` Array(UInt64) comment 'size retained by each leak suspect in bytes' CODEC(ZSTD(3)),
+    `leak_elements` Array(UInt64) comment 'element count of the largest collection of each leak suspect' CODEC(ZSTD(3)),
+    `leak_path_classes` Array(Array(String)) comment 'classes on the shortest path from a gc root to each leak suspect' CODEC(ZSTD(3)),
+    `leak_path_names` Array(Array(String)) comment 'fields on the shortest path from a gc root to each leak suspect' CODEC(ZSTD(3)),
+    INDEX session_id_bloom_idx `session_id` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX event_id_bloom_idx `event_id` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX timestamp_minmax_idx `timestamp` TYPE minmax GRANULARITY 1
+)
+engine = ReplacingMergeTree
+partition by toYYYYMM(`timestamp`)
+order by (`team_id`, `app_id`, `app_version`.1, `app_version`.2, `event_id`)
+settings index_granularity = 8192
+comment 'summaries of analyzed heap dumps with leak suspects';
+
+-- migrate:down
+drop table if exists heap_dumps;
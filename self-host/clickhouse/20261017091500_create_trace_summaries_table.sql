@@ -0,0 +1,40 @@
+-- migrate:up
+create table if not exists trace_summaries
+(
+    `team_id` LowCardinality(UUID) comment 'associated team id' CODEC(LZ4),
+    `app_id` LowCardinality(UUID) comment 'associated app id' CODEC(LZ4),
+    `event_id` UUID comment 'id of the event the trace was attached to' CODEC(LZ4),
+    `event_type` LowCardinality(String) comment 'type of the event the trace was attached to' CODEC(ZSTD(3)),
+    `session_id` UUID comment 'id of the session the trace was taken in' CODEC(LZ4),
+    `timestamp` DateTime64(3, 'UTC') comment 'timestamp of the event' CODEC(DoubleDelta, ZSTD(3)),
+    `app_version` Tuple(
+        LowCardinality(String),
+        LowCardinality(String)) comment 'composite app version' CODEC(ZSTD(3)),
+    `process_name` LowCardinality(String) comment 'name of the app process summarized' CODEC(ZSTD(3)),
+    `duration` UInt64 comment 'time the trace spans in nanoseconds' CODEC(T64, ZSTD(3)),
+    `startup_duration` UInt64 comment 'time from process start to first frame in nanoseconds, 0 if no startup was traced' CODEC(T64, ZSTD(3)),
+    `startup_phase_names` Array(String) comment 'startup phases, like bindApplication' CODEC(ZSTD(3)),
+    `startup_phase_durations` Array(UInt64) comment 'duration of each startup phase in nanoseconds' CODEC(ZSTD(3)),
+    `startup_work_kinds` Array(String) comment 'kinds of main thread work during startup, like inflate' CODEC(ZSTD(3)),
+    `startup_work_durations` Array(UInt64) comment 'main thread time spent on each kind of work during startup in nanoseconds' CODEC(ZSTD(3)),
+    `jank_slices` UInt32 comment 'count of main thread slices longer than 16ms' CODEC(T64, ZSTD(3)),
+    `jank_duration` UInt64 comment 'time spent on main thread slices longer than 16ms in nanoseconds' CODEC(T64, ZSTD(3)),
+    `binder_transactions` UInt32 comment 'count of binder transactions sent' CODEC(T64, ZSTD(3)),
+    `binder_duration` UInt64 comment 'time waited on binder replies in nanoseconds' CODEC(T64, ZSTD(3)),
+    `binder_main_thread_duration` UInt64 comment 'time the main thread waited on binder replies in nanoseconds' CODEC(T64, ZSTD(3)),
+    `gc_collections` UInt32 comment 'count of garbage collections' CODEC(T64, ZSTD(3)),
+    `gc_duration` UInt64 comment 'time the garbage collector ran in nanoseconds' CODEC(T64, ZSTD(3)),
+    `gc_main_thread_duration` UInt64 comment 'time the main thread spent on garbage collection in nanoseconds' CODEC(T64, ZSTD(3)),
+    `summary` String comment 'trace summary as json' CODEC(ZSTD(3)),
+    INDEX session_id_bloom_idx `session_id` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX event_id_bloom_idx `event_id` TYPE bloom_filter(0.01) GRANULARITY 2,
+    INDEX timestamp_minmax_idx `timestamp` TYPE minmax GRANULARITY 1
+)
+engine = ReplacingMergeTree
+partition by toYYYYMM(`timestamp`)
+order by (`team_id`, `app_id`, `app_version`.1, `app_version`.2, `event_id`)
+settings index_granularity = 8192
+comment 'summaries of perfetto traces with jank, startup, binder & gc breakdowns';
+
+-- migrate:down
+drop table if exists trace_summaries;
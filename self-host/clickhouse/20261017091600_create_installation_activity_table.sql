@@ -0,0 +1,20 @@
+-- migrate:up
+create table if not exists installation_activity
+(
+    `team_id` LowCardinality(UUID) comment 'associated team id' CODEC(LZ4),
+    `app_id` LowCardinality(UUID) comment 'associated app id' CODEC(LZ4),
+    `installation_id` UUID comment 'unique id for an installation of an app, generated by sdk' CODEC(LZ4),
+    `day` Date comment 'utc day the installation was active on' CODEC(DoubleDelta, ZSTD(3)),
+    `first_event_timestamp` SimpleAggregateFunction(min, DateTime64(3, 'UTC')) comment 'timestamp of the first event of the day' CODEC(DoubleDelta, ZSTD(3)),
+    `first_session_id` AggregateFunction(argMin, UUID, DateTime64(9, 'UTC')) comment 'id of the first session of the day' CODEC(ZSTD(3)),
+    `first_app_version` AggregateFunction(argMin, Tuple(LowCardinality(String), LowCardinality(String)), DateTime64(9, 'UTC')) comment 'composite app version of the first event of the day' CODEC(ZSTD(3)),
+    index first_event_timestamp_minmax_idx first_event_timestamp type minmax granularity 1
+)
+engine = AggregatingMergeTree
+partition by toYYYYMM(day)
+order by (team_id, app_id, installation_id, day)
+settings index_granularity = 8192
+comment 'aggregated days app installations were active on';
+
+-- migrate:down
+drop table if exists installation_activity;
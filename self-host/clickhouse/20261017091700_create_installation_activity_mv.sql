@@ -0,0 +1,21 @@
+-- migrate:up
+create materialized view if not exists installation_activity_mv
+to installation_activity
+as select
+  team_id,
+  app_id,
+  attribute.installation_id as installation_id,
+  toDate(timestamp) as day,
+  min(timestamp) as first_event_timestamp,
+  argMinState(session_id, timestamp) as first_session_id,
+  argMinState(cast((attribute.app_version, attribute.app_build), 'Tuple(LowCardinality(String), LowCardinality(String))'), timestamp) as first_app_version
+from events
+group by
+  team_id,
+  app_id,
+  installation_id,
+  day;
+
+
+-- migrate:down
+drop view if exists installation_activity_mv;
@@ -0,0 +1,168 @@
+#!/usr/bin/env bash
+
+# exit on error
+set -e
+
+######################
+# Migration Settings #
+######################
+# Use these settings to tune the backfill
+# according to your needs.
+#
+# installation_activity_mv only sees events inserted
+# after it was created. This backfills the days apps'
+# installations were active on from older events, one
+# monthly partition of events at a time.
+#
+# installation_activity keeps the earliest event of each
+# day, so backfilling a month again aggregates to the same
+# rows. A backfill that fails can be resumed from the month
+# it failed in.
+#
+# - Complete:   Backfill all partitions of events
+#   - START_PARTITION=0
+# - Resume:     Backfill partitions from the one that failed
+#   - START_PARTITION=yyyymm
+
+# Run in cloud mode
+USE_CLOUD="${USE_CLOUD:-0}"
+# Test connectivity only, no data will be read
+# or written. Only tables will be listed.
+#
+# Exits early.
+CONNECTIVITY="${CONNECTIVITY:-0}"
+# Partition to resume from
+START_PARTITION="${START_PARTITION:-0}"
+
+# List of partitions to backfill, all partitions
+# of events from START_PARTITION when empty
+# PARTITIONS=(202509 202510 202511 202512 202601)
+PARTITIONS=()
+
+echo "Migration Settings"
+echo "=================="
+echo "USE_CLOUD: $USE_CLOUD"
+echo "START_PARTITION: $START_PARTITION"
+echo "PARTITIONS: ${PARTITIONS[@]}"
+echo
+
+############################
+# Core Migration Procedure #
+############################
+# Any modification beyond this point
+# should be done with care.
+
+clickhouse_query() {
+  local admin_user
+  local admin_password
+  local dbname
+
+  dbname=measure
+
+  if [[ "$USE_CLOUD" -eq 1 ]]; then
+    local host="$CLICKHOUSE_HOST"
+    admin_user="$CLICKHOUSE_ADMIN_USER"
+    admin_password="$CLICKHOUSE_ADMIN_PASSWORD"
+
+    clickhouse client \
+      --user "$admin_user" \
+      --password "$admin_password" \
+      --database "$dbname" \
+      --host "$host" \
+      --port 9440 \
+      --secure \
+      --progress \
+      --receive_timeout 3600 \
+      --format TabSeparatedRaw \
+      "$@"
+  else
+    admin_user=$(get_env_variable CLICKHOUSE_ADMIN_USER)
+    admin_password=$(get_env_variable CLICKHOUSE_ADMIN_PASSWORD)
+
+    clickhouse_client \
+      --user "$admin_user" \
+      --password "$admin_password" \
+      --database "$dbname" \
+      --progress \
+      --receive_timeout 3600 \
+      --format TabSeparatedRaw \
+      "$@"
+  fi
+}
+
+# Lists the partitions of events to backfill
+list_partitions() {
+  if [[ ${#PARTITIONS[@]} -gt 0 ]]; then
+    printf "%s\n" "${PARTITIONS[@]}"
+    return
+  fi
+
+  clickhouse_query --query "
+    select distinct partition
+    from system.parts
+    where
+      database = 'measure'
+      and table = 'events'
+      and active
+      and toUInt32(partition) >= $START_PARTITION
+    order by partition
+  "
+}
+
+backfill_installation_activity() {
+  echo "  ✔ Backfilling installation activity"
+
+  local partitions
+  partitions=$(list_partitions)
+
+  for part in $partitions; do
+    echo "  ✔ Starting partition $part"
+
+    if ! clickhouse_query --query "
+      insert into installation_activity
+      select
+        team_id,
+        app_id,
+        attribute.installation_id as installation_id,
+        toDate(timestamp) as day,
+        min(timestamp) as first_event_timestamp,
+        argMinState(session_id, timestamp) as first_session_id,
+        argMinState(cast((attribute.app_version, attribute.app_build), 'Tuple(LowCardinality(String), LowCardinality(String))'), timestamp) as first_app_version
+      from events
+      where toYYYYMM(timestamp) = $part
+      group by
+        team_id,
+        app_id,
+        installation_id,
+        day
+      settings
+        max_bytes_before_external_group_by = 4000000000,
+        max_threads = 8
+    "; then
+      echo "  ✗ Backfill failed in partition $part, resume with START_PARTITION=$part"
+      return 1
+    fi
+
+    echo "  ✔ Finished partition $part"
+  done
+
+  echo "  ✔ Finished backfilling all partitions"
+}
+
+# set up environment
+if [[ "$USE_CLOUD" -eq 0 ]]; then
+  SCRIPT_DIR="$(cd -- "$(dirname -- "${BASH_SOURCE[0]}")" && pwd)"
+  source "${SCRIPT_DIR}/../shared.sh"
+
+  check_base_dir
+  set_docker_compose
+fi
+
+# kick things off
+if [[ "$CONNECTIVITY" -eq 1 ]]; then
+  echo "Testing connectivity..."
+  clickhouse_query --query "show tables;"
+  exit 0
+fi
+
+backfill_installation_activity
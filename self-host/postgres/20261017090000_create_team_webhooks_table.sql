@@ -0,0 +1,21 @@
+-- migrate:up
+create table if not exists measure.team_webhooks (
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    url text not null,
+    secret text not null,
+    is_active boolean not null default true,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (team_id)
+);
+
+comment on table measure.team_webhooks is 'outbound alert webhook destination per team';
+comment on column measure.team_webhooks.team_id is 'id of the team the webhook belongs to';
+comment on column measure.team_webhooks.url is 'http or https url alert events are posted to';
+comment on column measure.team_webhooks.secret is 'secret used to sign the HMAC-SHA256 signature of every delivery';
+comment on column measure.team_webhooks.is_active is 'whether alert events are delivered to the webhook';
+comment on column measure.team_webhooks.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.team_webhooks.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.team_webhooks;
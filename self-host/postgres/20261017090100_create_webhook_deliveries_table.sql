@@ -0,0 +1,41 @@
+-- migrate:up
+create table if not exists measure.webhook_deliveries (
+    id uuid primary key not null,
+    team_id uuid not null references measure.team_webhooks(team_id) on delete cascade,
+    app_id uuid references measure.apps(id) on delete cascade,
+    event_type varchar(256) not null,
+    payload jsonb not null,
+    status varchar(32) not null default 'pending',
+    attempts int not null default 0,
+    response_status int,
+    last_error text,
+    next_attempt_at timestamptz not null default now(),
+    delivered_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint webhook_deliveries_status_check check (status in ('pending', 'delivered', 'failed'))
+);
+
+-- the sender polls for due pending deliveries
+create index if not exists webhook_deliveries_status_next_attempt_at_idx on measure.webhook_deliveries (status, next_attempt_at);
+
+-- the delivery log lists a team's deliveries newest first
+create index if not exists webhook_deliveries_team_id_created_at_idx on measure.webhook_deliveries (team_id, created_at desc);
+
+comment on table measure.webhook_deliveries is 'queue and log of alert events delivered to team webhooks';
+comment on column measure.webhook_deliveries.id is 'unique id of the delivery, sent to the receiver and kept across retries';
+comment on column measure.webhook_deliveries.team_id is 'id of the team whose webhook receives the delivery';
+comment on column measure.webhook_deliveries.app_id is 'id of the app the event is about, null for team wide events like the daily summary';
+comment on column measure.webhook_deliveries.event_type is 'event type, ex: "crash_spike", "anr_spike", "bug_report", "daily_summary", "test"';
+comment on column measure.webhook_deliveries.payload is 'json body posted to the webhook';
+comment on column measure.webhook_deliveries.status is 'delivery status: "pending", "delivered" or "failed"';
+comment on column measure.webhook_deliveries.attempts is 'number of delivery attempts made';
+comment on column measure.webhook_deliveries.response_status is 'http status of the last attempt, null if the request did not complete';
+comment on column measure.webhook_deliveries.last_error is 'error of the last failed attempt';
+comment on column measure.webhook_deliveries.next_attempt_at is 'utc timestamp after which a pending delivery is attempted';
+comment on column measure.webhook_deliveries.delivered_at is 'utc timestamp of the successful attempt';
+comment on column measure.webhook_deliveries.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.webhook_deliveries.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.webhook_deliveries;
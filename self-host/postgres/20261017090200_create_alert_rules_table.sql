@@ -0,0 +1,54 @@
+-- migrate:up
+create table if not exists measure.alert_rules (
+    id uuid primary key not null,
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    name text not null,
+    metric text not null,
+    aggregation text not null default '',
+    target text not null default '',
+    app_versions text[] not null default '{}',
+    operator text not null check (operator in ('gt', 'lt')),
+    threshold double precision not null,
+    comparison text not null default 'absolute' check (comparison in ('absolute', 'relative')),
+    baseline_offset_minutes integer not null default 0,
+    min_sample_count integer not null default 0,
+    window_minutes integer not null,
+    interval_minutes integer not null,
+    cooldown_minutes integer not null,
+    channels text[] not null default '{}',
+    is_active boolean not null default true,
+    last_evaluated_at timestamptz,
+    created_by uuid references measure.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create index if not exists alert_rules_app_id_idx on measure.alert_rules (app_id);
+
+comment on table measure.alert_rules is 'user defined alert rules evaluated by the alerts service';
+comment on column measure.alert_rules.id is 'unique id for each alert rule';
+comment on column measure.alert_rules.team_id is 'id of the team the rule belongs to';
+comment on column measure.alert_rules.app_id is 'id of the app the rule is evaluated against';
+comment on column measure.alert_rules.name is 'name of the rule, shown in alert messages';
+comment on column measure.alert_rules.metric is 'metric the rule evaluates, like cold_launch, http_5xx_rate or span_duration';
+comment on column measure.alert_rules.aggregation is 'aggregation applied to duration metrics, like p95 or avg, empty for rate and count metrics';
+comment on column measure.alert_rules.target is 'span name, url pattern or custom event name the metric is scoped to';
+comment on column measure.alert_rules.app_versions is 'app version names the metric is scoped to, empty for all versions';
+comment on column measure.alert_rules.operator is 'gt to alert above the threshold, lt to alert below it';
+comment on column measure.alert_rules.threshold is 'metric value, or percent change for relative rules, the rule alerts on';
+comment on column measure.alert_rules.comparison is 'absolute compares the metric to the threshold, relative compares its percent change against the baseline window';
+comment on column measure.alert_rules.baseline_offset_minutes is 'how far back the baseline window starts from the current window for relative rules';
+comment on column measure.alert_rules.min_sample_count is 'minimum number of samples in the window for the rule to be evaluated';
+comment on column measure.alert_rules.window_minutes is 'length of the window the metric is computed over';
+comment on column measure.alert_rules.interval_minutes is 'how often the rule is evaluated';
+comment on column measure.alert_rules.cooldown_minutes is 'minimum time between two alerts raised by the rule';
+comment on column measure.alert_rules.channels is 'channels alerts are sent to, any of email, slack and webhook';
+comment on column measure.alert_rules.is_active is 'whether the rule is evaluated';
+comment on column measure.alert_rules.last_evaluated_at is 'utc timestamp of the last evaluation of the rule';
+comment on column measure.alert_rules.created_by is 'id of the user who created the rule';
+comment on column measure.alert_rules.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.alert_rules.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.alert_rules;
@@ -0,0 +1,18 @@
+-- migrate:up
+alter table "measure"."app_threshold_prefs" add column if not exists network_alerts_enabled boolean not null default true;
+alter table "measure"."app_threshold_prefs" add column if not exists network_latency_regression_threshold numeric(6,2) not null default 50.00 check (network_latency_regression_threshold > 0 and network_latency_regression_threshold <= 1000);
+alter table "measure"."app_threshold_prefs" add column if not exists network_error_rate_regression_threshold numeric(5,2) not null default 5.00 check (network_error_rate_regression_threshold > 0 and network_error_rate_regression_threshold <= 100);
+alter table "measure"."app_threshold_prefs" add column if not exists network_traffic_drop_threshold numeric(5,2) not null default 80.00 check (network_traffic_drop_threshold > 0 and network_traffic_drop_threshold <= 100);
+alter table "measure"."app_threshold_prefs" add column if not exists network_min_request_count_threshold integer not null default 100 check (network_min_request_count_threshold >= 1);
+comment on column measure.app_threshold_prefs.network_alerts_enabled is 'whether network endpoint regression alerts are raised for the app';
+comment on column measure.app_threshold_prefs.network_latency_regression_threshold is 'percent increase of an endpoint''s p95 latency over its baseline that raises an alert';
+comment on column measure.app_threshold_prefs.network_error_rate_regression_threshold is 'percentage point increase of an endpoint''s 4xx or 5xx rate over its baseline that raises an alert';
+comment on column measure.app_threshold_prefs.network_traffic_drop_threshold is 'percent drop of an endpoint''s request count below its baseline that raises an alert';
+comment on column measure.app_threshold_prefs.network_min_request_count_threshold is 'minimum hourly request count of an endpoint for its regressions to be alerted on';
+
+-- migrate:down
+alter table "measure"."app_threshold_prefs" drop column if exists network_min_request_count_threshold;
+alter table "measure"."app_threshold_prefs" drop column if exists network_traffic_drop_threshold;
+alter table "measure"."app_threshold_prefs" drop column if exists network_error_rate_regression_threshold;
+alter table "measure"."app_threshold_prefs" drop column if exists network_latency_regression_threshold;
+alter table "measure"."app_threshold_prefs" drop column if exists network_alerts_enabled;
@@ -0,0 +1,41 @@
+-- migrate:up
+create table if not exists measure.error_group_releases (
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    kind text not null check (kind in ('fatal', 'nonfatal', 'anr')),
+    id text not null,
+    first_version text not null,
+    first_version_code text not null,
+    last_version text not null,
+    last_version_code text not null,
+    state text not null check (state in ('new', 'regressed', 'ongoing')),
+    state_version text not null,
+    state_version_code text not null,
+    first_seen_at timestamptz not null,
+    last_seen_at timestamptz not null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (app_id, kind, id)
+);
+
+create index if not exists error_group_releases_state_idx on measure.error_group_releases (app_id, state, state_version, state_version_code);
+
+comment on table measure.error_group_releases is 'release state of each error group, tracked at ingest';
+comment on column measure.error_group_releases.team_id is 'id of the team the error group belongs to';
+comment on column measure.error_group_releases.app_id is 'id of the app the error group belongs to';
+comment on column measure.error_group_releases.kind is 'fatal or nonfatal for exception groups, anr for ANR groups';
+comment on column measure.error_group_releases.id is 'fingerprint of the error group';
+comment on column measure.error_group_releases.first_version is 'app version the error group first appeared in';
+comment on column measure.error_group_releases.first_version_code is 'app version code the error group first appeared in';
+comment on column measure.error_group_releases.last_version is 'app version the error group was last seen in';
+comment on column measure.error_group_releases.last_version_code is 'app version code the error group was last seen in';
+comment on column measure.error_group_releases.state is 'new when introduced in the state version, regressed when it came back in the state version after going quiet, ongoing otherwise';
+comment on column measure.error_group_releases.state_version is 'app version the error group entered its state in';
+comment on column measure.error_group_releases.state_version_code is 'app version code the error group entered its state in';
+comment on column measure.error_group_releases.first_seen_at is 'utc timestamp of the first occurrence of the error group';
+comment on column measure.error_group_releases.last_seen_at is 'utc timestamp of the last occurrence of the error group';
+comment on column measure.error_group_releases.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.error_group_releases.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.error_group_releases;
@@ -0,0 +1,38 @@
+-- migrate:up
+create table if not exists measure.error_group_states (
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    id text not null,
+    status text not null default 'open' check (status in ('open', 'resolved', 'ignored', 'snoozed')),
+    resolved_version text,
+    resolved_version_code text,
+    resolved_at timestamptz,
+    snoozed_until timestamptz,
+    snooze_occurrences bigint check (snooze_occurrences > 0),
+    assignee_id uuid references measure.users(id) on delete set null,
+    updated_by uuid references measure.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (app_id, id)
+);
+
+create index if not exists error_group_states_status_idx on measure.error_group_states (app_id, status);
+create index if not exists error_group_states_assignee_id_idx on measure.error_group_states (assignee_id);
+
+comment on table measure.error_group_states is 'triage state of error groups, error groups without a row are open';
+comment on column measure.error_group_states.team_id is 'id of the team the error group belongs to';
+comment on column measure.error_group_states.app_id is 'id of the app the error group belongs to';
+comment on column measure.error_group_states.id is 'fingerprint of the error group, shared by its fatal and nonfatal groups';
+comment on column measure.error_group_states.status is 'one of open, resolved, ignored or snoozed';
+comment on column measure.error_group_states.resolved_version is 'app version the error group was resolved in, recurring in it or a newer version reopens the group';
+comment on column measure.error_group_states.resolved_version_code is 'app version code the error group was resolved in';
+comment on column measure.error_group_states.resolved_at is 'utc timestamp the error group was resolved at, recurring after it reopens a group resolved without a version';
+comment on column measure.error_group_states.snoozed_until is 'utc timestamp a snoozed error group reopens at';
+comment on column measure.error_group_states.snooze_occurrences is 'remaining occurrences after which a snoozed error group reopens';
+comment on column measure.error_group_states.assignee_id is 'id of the team member the error group is assigned to';
+comment on column measure.error_group_states.updated_by is 'id of the user who last updated the state';
+comment on column measure.error_group_states.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.error_group_states.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.error_group_states;
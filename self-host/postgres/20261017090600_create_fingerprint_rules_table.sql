@@ -0,0 +1,24 @@
+-- migrate:up
+create table if not exists measure.fingerprint_rules (
+    id uuid primary key not null,
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    type text not null check (type in ('ignore_frame', 'group_by_message', 'fold_type')),
+    pattern text not null,
+    created_by uuid references measure.users(id) on delete set null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists fingerprint_rules_app_id_idx on measure.fingerprint_rules (app_id);
+
+comment on table measure.fingerprint_rules is 'per app rules customizing how error fingerprints are computed at ingest';
+comment on column measure.fingerprint_rules.id is 'unique id for each fingerprint rule';
+comment on column measure.fingerprint_rules.team_id is 'id of the team the rule belongs to';
+comment on column measure.fingerprint_rules.app_id is 'id of the app the rule applies to';
+comment on column measure.fingerprint_rules.type is 'ignore_frame skips matching frames, group_by_message groups by a message pattern, fold_type groups by exception type alone';
+comment on column measure.fingerprint_rules.pattern is 'regular expression the rule matches frames, messages or exception types with';
+comment on column measure.fingerprint_rules.created_by is 'id of the user who created the rule';
+comment on column measure.fingerprint_rules.created_at is 'utc timestamp at the time of record creation';
+
+-- migrate:down
+drop table if exists measure.fingerprint_rules;
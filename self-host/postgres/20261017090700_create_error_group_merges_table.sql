@@ -0,0 +1,23 @@
+-- migrate:up
+create table if not exists measure.error_group_merges (
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    id text not null,
+    target_id text not null check (target_id != id),
+    merged_by uuid references measure.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    primary key (app_id, id)
+);
+
+create index if not exists error_group_merges_target_id_idx on measure.error_group_merges (app_id, target_id);
+
+comment on table measure.error_group_merges is 'error groups merged into another error group, queries treat a merged group as part of its target';
+comment on column measure.error_group_merges.team_id is 'id of the team the error groups belong to';
+comment on column measure.error_group_merges.app_id is 'id of the app the error groups belong to';
+comment on column measure.error_group_merges.id is 'fingerprint of the merged error group';
+comment on column measure.error_group_merges.target_id is 'fingerprint of the error group it is merged into';
+comment on column measure.error_group_merges.merged_by is 'id of the user who merged the error group';
+comment on column measure.error_group_merges.created_at is 'utc timestamp at the time of record creation';
+
+-- migrate:down
+drop table if exists measure.error_group_merges;
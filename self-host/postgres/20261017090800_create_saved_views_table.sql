@@ -0,0 +1,42 @@
+-- migrate:up
+create table if not exists measure.saved_views (
+    id uuid primary key not null,
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    name varchar(256) not null,
+    page text not null check (page in ('overview', 'crashes', 'anrs', 'sessions', 'traces', 'bug_reports', 'alerts')),
+    filter_expr text not null default '',
+    time_range text not null,
+    time_from timestamptz,
+    time_to timestamptz,
+    visibility text not null check (visibility in ('private', 'team')),
+    is_default boolean not null default false,
+    created_by uuid references measure.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    check (not is_default or visibility = 'team'),
+    check (time_range <> 'custom' or (time_from is not null and time_to is not null))
+);
+
+create index if not exists saved_views_app_id_idx on measure.saved_views (app_id);
+
+create unique index if not exists saved_views_app_id_page_default_idx on measure.saved_views (app_id, page) where is_default;
+
+comment on table measure.saved_views is 'named filters and time ranges saved for an app dashboard page';
+comment on column measure.saved_views.id is 'unique id for each saved view';
+comment on column measure.saved_views.team_id is 'id of the team the view belongs to';
+comment on column measure.saved_views.app_id is 'id of the app the view is for';
+comment on column measure.saved_views.name is 'name of the view';
+comment on column measure.saved_views.page is 'dashboard page the view opens';
+comment on column measure.saved_views.filter_expr is 'filter expression of the view, empty when unfiltered';
+comment on column measure.saved_views.time_range is 'time range mode, a relative range like last_7_days or custom';
+comment on column measure.saved_views.time_from is 'utc start of a custom time range';
+comment on column measure.saved_views.time_to is 'utc end of a custom time range';
+comment on column measure.saved_views.visibility is 'private views are seen by their creator only, team views by the whole team';
+comment on column measure.saved_views.is_default is 'whether the view opens by default on its page, one per app and page';
+comment on column measure.saved_views.created_by is 'id of the user who created the view';
+comment on column measure.saved_views.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.saved_views.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.saved_views;
@@ -0,0 +1,27 @@
+-- migrate:up
+create table if not exists measure.metric_exports (
+    team_id uuid not null references measure.teams(id) on delete cascade,
+    otlp_endpoint text,
+    otlp_headers jsonb not null default '{}'::jsonb,
+    scrape_key_hash text,
+    is_active boolean not null default true,
+    last_pushed_at timestamptz,
+    last_push_error text,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (team_id)
+);
+
+comment on table measure.metric_exports is 'export of derived metrics to external metrics systems per team';
+comment on column measure.metric_exports.team_id is 'id of the team the metric export belongs to';
+comment on column measure.metric_exports.otlp_endpoint is 'otlp/http metrics endpoint metrics are pushed to, null when metrics are not pushed';
+comment on column measure.metric_exports.otlp_headers is 'http headers sent with every push, like the authorization of the receiver';
+comment on column measure.metric_exports.scrape_key_hash is 'sha-256 hash of the key guarding the prometheus scrape endpoint, null when scrapes are disabled';
+comment on column measure.metric_exports.is_active is 'whether metrics are pushed and served to scrapes';
+comment on column measure.metric_exports.last_pushed_at is 'utc timestamp of the last push attempt';
+comment on column measure.metric_exports.last_push_error is 'error of the last push attempt, null when it succeeded';
+comment on column measure.metric_exports.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.metric_exports.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.metric_exports;
@@ -0,0 +1,21 @@
+-- migrate:up
+create table if not exists measure.app_ingest_limits (
+    app_id uuid not null references measure.apps(id) on delete cascade,
+    events_per_second integer check (events_per_second >= 0),
+    bytes_per_minute bigint check (bytes_per_minute >= 0),
+    installation_events_per_second integer check (installation_events_per_second >= 0),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (app_id)
+);
+
+comment on table measure.app_ingest_limits is 'per app overrides of the ingest rate limits';
+comment on column measure.app_ingest_limits.app_id is 'id of the app the limits apply to';
+comment on column measure.app_ingest_limits.events_per_second is 'events and spans per second the app may ingest, averaged over a minute, null for the service default, 0 for no limit';
+comment on column measure.app_ingest_limits.bytes_per_minute is 'payload bytes per minute the app may ingest, null for the service default, 0 for no limit';
+comment on column measure.app_ingest_limits.installation_events_per_second is 'events and spans per second a single installation of the app may ingest, averaged over a minute, null for the service default, 0 for no limit';
+comment on column measure.app_ingest_limits.created_at is 'utc timestamp at the time of record creation';
+comment on column measure.app_ingest_limits.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists measure.app_ingest_limits;
@@ -0,0 +1,26 @@
+-- migrate:up
+create table if not exists measure.bus_messages (
+    id bigint generated always as identity,
+    topic text not null,
+    ordering_key text,
+    data bytea not null,
+    attempts integer not null default 0,
+    available_at timestamptz not null default now(),
+    created_at timestamptz not null default now(),
+    primary key (id)
+);
+
+comment on table measure.bus_messages is 'message queue of the postgres message bus backend, a message is deleted once consumed';
+comment on column measure.bus_messages.id is 'sequential id of the message, orders messages within a topic';
+comment on column measure.bus_messages.topic is 'name of the topic the message was published to';
+comment on column measure.bus_messages.ordering_key is 'messages sharing an ordering key within a topic are delivered one at a time in publish order, null for unordered messages';
+comment on column measure.bus_messages.data is 'payload of the message';
+comment on column measure.bus_messages.attempts is 'number of times the message was handed to a consumer';
+comment on column measure.bus_messages.available_at is 'utc timestamp after which the message can be claimed, pushed ahead while a consumer holds it';
+comment on column measure.bus_messages.created_at is 'utc timestamp at the time of record creation';
+
+create index on measure.bus_messages(topic, available_at);
+create index on measure.bus_messages(topic, ordering_key, id) where ordering_key is not null;
+
+-- migrate:down
+drop table if exists measure.bus_messages;